@@ -0,0 +1,313 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+)
+
+// cacheEnv names the environment variable that sets the directory of the build
+// cache, or turns the cache off with "off", like GOCACHE
+const cacheEnv = "GOFSMGEN_CACHE"
+
+// buildCache remembers the files a generate run wrote under a key covering
+// everything the output depends on, so that a run whose inputs and outputs are
+// unchanged skips parsing and rendering, as go generate does in a repository
+// of many machines
+type buildCache struct {
+	dir string
+	key string
+}
+
+// cacheEntry is the record of a generate run in the cache
+type cacheEntry struct {
+	Files []cachedFile `json:"files"`
+}
+
+// cachedFile is a file a generate run wrote, with the checksum of its content
+type cachedFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// cacheDir returns the directory of the build cache, which is empty when the
+// cache is off
+func cacheDir() (string, error) {
+	switch dir := os.Getenv(cacheEnv); dir {
+	case "off":
+		return "", nil
+	case "":
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(base, "gofsm-gen"), nil
+	default:
+		return filepath.Abs(dir)
+	}
+}
+
+// openCache returns the build cache of the run, or nil when the run cannot use
+// it: when the cache is off, when the run writes somewhere other than its output
+// files or looks beyond them, as -dry-run, -force, -out=-, -prune, -registry, and
+// -debug-templates do, or when a key cannot be computed. Missing specs and other
+// errors are left to generation to report.
+func (f *generateFlags) openCache(extraSpecs []string, opts writeOptions) *buildCache {
+	if opts.dryRun || opts.force || f.out == stdoutPath || f.prune || f.registry != "" || f.debugTmpl {
+		return nil
+	}
+	dir, err := cacheDir()
+	if err != nil {
+		logger.Debug("build cache unavailable", "error", err)
+		return nil
+	}
+	if dir == "" {
+		return nil
+	}
+	key, err := f.cacheKey(extraSpecs)
+	if err != nil {
+		logger.Debug("build cache skipped", "error", err)
+		return nil
+	}
+	return &buildCache{dir: dir, key: key}
+}
+
+// cacheKey hashes the inputs of the run: the build of gofsm-gen, the working
+// directory, the flags, the -plugin programs, and for every spec its content, its
+// configuration, the go.mod of its module, and the templates it is rendered with
+func (f *generateFlags) cacheKey(extraSpecs []string) (string, error) {
+	h := sha256.New()
+	build, err := buildIdentity()
+	if err != nil {
+		return "", err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	hashField(h, "build", []byte(build))
+	hashField(h, "cwd", []byte(cwd))
+	hashField(h, "flags", fmt.Appendf(nil, "%#v %q", *f, extraSpecs))
+	for _, path := range f.plugins {
+		exe, err := exec.LookPath(path)
+		if err != nil {
+			return "", err
+		}
+		identity, err := executableIdentity(exe)
+		if err != nil {
+			return "", err
+		}
+		hashField(h, "plugin", []byte(identity))
+	}
+
+	specs, err := f.specPaths(extraSpecs)
+	if err != nil {
+		return "", err
+	}
+	configs := configs{}
+	templateDirs := make(map[string]bool)
+	modDirs := []string{}
+	if f.out != "" {
+		modDirs = append(modDirs, f.out)
+	}
+	for _, spec := range specs {
+		content, err := os.ReadFile(spec)
+		if err != nil {
+			return "", err
+		}
+		hashField(h, "spec "+spec, content)
+
+		config, err := configs.get(filepath.Dir(spec))
+		if err != nil {
+			return "", err
+		}
+		resolved, err := json.Marshal(config)
+		if err != nil {
+			return "", err
+		}
+		hashField(h, "config", resolved)
+		modDirs = append(modDirs, filepath.Dir(spec))
+
+		key := f.generatorKey(config)
+		if key.templates == "" {
+			if key.templates, err = generator.DefaultTemplateDir(); err != nil {
+				return "", err
+			}
+		}
+		templateDirs[key.templates] = true
+		if key.overrides != "" {
+			templateDirs[key.overrides] = true
+		}
+	}
+
+	for _, dir := range modDirs {
+		gomod, err := nearestGoMod(dir)
+		if err != nil {
+			return "", err
+		}
+		hashField(h, "go.mod", gomod)
+	}
+
+	dirs := make([]string, 0, len(templateDirs))
+	for dir := range templateDirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		templates, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return "", err
+		}
+		hashField(h, "templates", []byte(dir))
+		for _, path := range templates {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			hashField(h, "template "+filepath.Base(path), content)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashField writes a named, length-prefixed field to h, so that the fields of
+// different inputs cannot run together into the same key
+func hashField(h hash.Hash, name string, value []byte) {
+	fmt.Fprintf(h, "%s %d\n", name, len(value))
+	h.Write(value)
+}
+
+// buildIdentity identifies the running gofsm-gen. Development builds keep their
+// version as they change, so the executable is part of it too.
+func buildIdentity() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	identity, err := executableIdentity(exe)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s", generator.ReadBuild(), identity), nil
+}
+
+// executableIdentity identifies the program at path by its absolute path, size,
+// and modification time, which change when it is rebuilt
+func executableIdentity(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %d %d", abs, info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// nearestGoMod returns the content of the go.mod of the module containing dir,
+// which is empty outside a module
+func nearestGoMod(dir string) ([]byte, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for root := abs; ; root = filepath.Dir(root) {
+		gomod, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		switch {
+		case err == nil:
+			return gomod, nil
+		case !os.IsNotExist(err) && !os.IsPermission(err):
+			return nil, err
+		case filepath.Dir(root) == root:
+			return nil, nil
+		}
+	}
+}
+
+// path returns the file of the cache entry
+func (c *buildCache) path() string {
+	return filepath.Join(c.dir, c.key[:2], c.key+".json")
+}
+
+// lookup returns the files the run wrote before, provided they are all still on
+// disk with the same content, which makes generating again unnecessary
+func (c *buildCache) lookup() ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path())
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || len(entry.Files) == 0 {
+		return nil, false
+	}
+
+	paths := make([]string, 0, len(entry.Files))
+	for _, f := range entry.Files {
+		content, err := os.ReadFile(f.Path)
+		if err != nil || checksum(content) != f.SHA256 {
+			logger.Debug("build cache miss", "file", f.Path)
+			return nil, false
+		}
+		paths = append(paths, f.Path)
+	}
+	return paths, true
+}
+
+// store records the files the run wrote. Failing to is not an error of the run,
+// which only loses the next run its shortcut.
+func (c *buildCache) store(files []generator.PlannedFile) {
+	if c == nil {
+		return
+	}
+	entry := cacheEntry{Files: make([]cachedFile, 0, len(files))}
+	for _, f := range files {
+		entry.Files = append(entry.Files, cachedFile{Path: f.Path, SHA256: checksum(f.Content)})
+	}
+	if err := writeCacheEntry(c.path(), entry); err != nil {
+		logger.Debug("build cache not updated", "error", err)
+	}
+}
+
+// writeCacheEntry writes entry to path through a temporary file, so that
+// concurrent runs never read a partial entry
+func writeCacheEntry(path string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// checksum returns the hex-encoded SHA-256 of content
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
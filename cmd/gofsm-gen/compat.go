@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// compatCommand implements "gofsm-gen compat": it reports the changes between two
+// versions of a spec that break state values persisted by the old version, with a
+// migration map from old to new states, and fails when there are any
+func compatCommand(fs *flag.FlagSet) commandFunc {
+	format := fs.String("format", "text", "output format: text or json")
+	return func(args []string, stdout, stderr io.Writer) int {
+		if *format != "text" && *format != "json" {
+			fmt.Fprintf(stderr, "gofsm-gen compat: unknown -format %q (use text or json)\n", *format)
+			return 2
+		}
+		if len(args) != 2 {
+			fmt.Fprintf(stderr, "gofsm-gen compat: must specify the old and the new spec\n")
+			return 2
+		}
+
+		old, err := loadSingleSpec(args[:1])
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen compat: %v\n", err)
+			return 1
+		}
+		updated, err := loadSingleSpec(args[1:])
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen compat: %v\n", err)
+			return 1
+		}
+
+		report := model.CheckCompat(old, updated)
+		if *format == "json" {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen compat: %v\n", err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "%s\n", out)
+		} else {
+			writeCompat(stdout, report)
+		}
+
+		if len(report.Issues) > 0 {
+			return 1
+		}
+		return 0
+	}
+}
+
+// writeCompat prints the issues of a compatibility report followed by the
+// suggested migration map
+func writeCompat(w io.Writer, r *model.CompatReport) {
+	if len(r.Issues) == 0 {
+		fmt.Fprintf(w, "%s: persisted states stay compatible\n", r.Machine)
+		return
+	}
+
+	fmt.Fprintf(w, "%s: %d change(s) break persisted states\n", r.Machine, len(r.Issues))
+	for _, issue := range r.Issues {
+		fmt.Fprintf(w, "  %s\n", issue.Message)
+	}
+
+	fmt.Fprintf(w, "\nsuggested migration (old -> new):\n")
+	for _, m := range r.Migrations {
+		to := "?"
+		if m.To != "" {
+			to = fmt.Sprintf("%s (%d)", m.To, m.ToValue)
+		}
+		fmt.Fprintf(w, "  %s (%d) -> %s\n", m.From, m.FromValue, to)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// completionScripts are the completion script templates by shell
+var completionScripts = map[string]*template.Template{
+	"bash": completionTemplate(bashCompletion),
+	"fish": completionTemplate(fishCompletion),
+	"zsh":  completionTemplate(zshCompletion),
+}
+
+// completionShells returns the shells with a completion script, sorted
+func completionShells() []string {
+	shells := make([]string, 0, len(completionScripts))
+	for shell := range completionScripts {
+		shells = append(shells, shell)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+// completionCommand implements "gofsm-gen completion": it prints the completion
+// script of a shell, generated from the command table
+func completionCommand(fs *flag.FlagSet) commandFunc {
+	return func(args []string, stdout, stderr io.Writer) int {
+		if len(args) != 1 {
+			fmt.Fprintf(stderr, "gofsm-gen completion: must specify one shell: %s\n", strings.Join(completionShells(), ", "))
+			return 2
+		}
+		script, ok := completionScripts[args[0]]
+		if !ok {
+			fmt.Fprintf(stderr, "gofsm-gen completion: unknown shell %q (use %s)\n", args[0], strings.Join(completionShells(), ", "))
+			return 2
+		}
+
+		if err := script.Execute(stdout, describeCommands()); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen completion: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// commandDoc describes a command for completion scripts and man pages
+type commandDoc struct {
+	Name     string
+	Synopsis string
+	Summary  string
+	Flags    []flagDoc
+	Operands []string
+}
+
+// flagDoc describes a flag of a command
+type flagDoc struct {
+	Name string
+
+	// Arg names the value of the flag; empty for boolean flags
+	Arg string
+
+	Usage   string
+	Default string
+
+	// Repeated flags may be given more than once
+	Repeated bool
+}
+
+// describeCommands describes every command in the command table
+func describeCommands() []commandDoc {
+	docs := make([]commandDoc, len(commands))
+	for i, c := range commands {
+		doc := commandDoc{Name: c.name, Synopsis: c.synopsis, Summary: c.summary, Operands: c.operands}
+		c.flagSet().VisitAll(func(f *flag.Flag) {
+			arg, usage := flag.UnquoteUsage(f)
+			_, repeated := f.Value.(*specList)
+			fd := flagDoc{Name: f.Name, Arg: arg, Usage: usage, Repeated: repeated}
+			switch f.DefValue {
+			case "", "false", "0":
+			default:
+				fd.Default = f.DefValue
+			}
+			doc.Flags = append(doc.Flags, fd)
+		})
+		docs[i] = doc
+	}
+	return docs
+}
+
+// completionTemplate parses a completion script template
+func completionTemplate(text string) *template.Template {
+	return template.Must(template.New("completion").Funcs(template.FuncMap{
+		"join": strings.Join,
+		"names": func(docs []commandDoc) []string {
+			names := make([]string, len(docs))
+			for i, doc := range docs {
+				names[i] = doc.Name
+			}
+			return names
+		},
+		"flagNames": func(flags []flagDoc) string {
+			names := make([]string, len(flags))
+			for i, f := range flags {
+				names[i] = "-" + f.Name
+			}
+			return strings.Join(names, " ")
+		},
+		"shellQuote": func(s string) string {
+			return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+		},
+		"zshDescription": strings.NewReplacer("[", `\[`, "]", `\]`, "'", `'\''`).Replace,
+		"fishQuote": func(s string) string {
+			return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+		},
+	}).Parse(text))
+}
+
+const bashCompletion = `# bash completion for gofsm-gen
+_gofsm_gen() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" command={{(index . 0).Name}} flags operands
+    if [[ ${COMP_CWORD} -gt 1 ]]; then
+        case "${COMP_WORDS[1]}" in
+        {{join (names .) "|"}}) command="${COMP_WORDS[1]}" ;;
+        esac
+    fi
+
+    case "$command" in
+{{- range .}}
+    {{.Name}})
+        flags={{shellQuote (flagNames .Flags)}}
+        operands={{shellQuote (join .Operands " ")}}
+        ;;
+{{- end}}
+    esac
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    elif [[ ${COMP_CWORD} -eq 1 ]]; then
+        COMPREPLY=($(compgen -W {{shellQuote (join (names .) " ")}} -- "$cur"))
+    elif [[ ${COMP_CWORD} -eq 2 && -n "$operands" ]]; then
+        COMPREPLY=($(compgen -W "$operands" -- "$cur"))
+    fi
+}
+complete -o default -F _gofsm_gen gofsm-gen
+`
+
+const zshCompletion = `#compdef gofsm-gen
+{{range .}}
+_gofsm_gen_{{.Name}}() {
+  _arguments \
+{{- range .Flags}}
+    '{{if .Repeated}}*{{end}}-{{.Name}}[{{zshDescription .Usage}}]{{if .Arg}}:{{.Arg}}:_files{{end}}' \
+{{- end}}
+{{- if .Operands}}
+    '1:operand:({{join .Operands " "}})' \
+{{- end}}
+    '*:file:_files'
+}
+{{end}}
+_gofsm_gen() {
+  local -a commands
+  commands=(
+{{- range .}}
+    '{{.Name}}:{{zshDescription .Summary}}'
+{{- end}}
+  )
+
+  if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then
+    _describe -t commands 'gofsm-gen command' commands
+    _files
+    return
+  fi
+  if (( ! ${+functions[_gofsm_gen_$words[2]]} )) || [[ $words[2] == -* ]]; then
+    _gofsm_gen_{{(index . 0).Name}}
+    return
+  fi
+
+  local command=$words[2]
+  shift words
+  (( CURRENT-- ))
+  _gofsm_gen_$command
+}
+
+if [ "$funcstack[1]" = "_gofsm_gen" ]; then
+  _gofsm_gen "$@"
+else
+  compdef _gofsm_gen gofsm-gen
+fi
+`
+
+const fishCompletion = `# fish completion for gofsm-gen
+function __gofsm_gen_command
+    set -l words (commandline -opc)
+    if test (count $words) -gt 1; and contains -- $words[2] {{join (names .) " "}}
+        echo $words[2]
+    else
+        echo {{(index . 0).Name}}
+    end
+end
+{{range .}}
+complete -c gofsm-gen -n __fish_use_subcommand -a {{.Name}} -d {{fishQuote .Summary}}
+{{- $name := .Name}}
+{{- range .Flags}}
+complete -c gofsm-gen -n 'test (__gofsm_gen_command) = {{$name}}' -o {{.Name}}{{if .Arg}} -r{{end}} -d {{fishQuote .Usage}}
+{{- end}}
+{{- if .Operands}}
+complete -c gofsm-gen -n 'test (__gofsm_gen_command) = {{$name}}; and test (count (commandline -opc)) -eq 2' -f -a {{fishQuote (join .Operands " ")}}
+{{- end}}
+{{end -}}
+`
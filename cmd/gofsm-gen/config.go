@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/lint"
+)
+
+// configName is the configuration file looked up in each spec's directory and its
+// parents up to the project root
+const configName = ".gofsm.yaml"
+
+// config holds generation defaults read from configuration files. Flags take
+// precedence, and the spec's own package and header take precedence over the
+// package, copyright, and build tags configured here.
+type config struct {
+	Package          string   `yaml:"package"`
+	Templates        string   `yaml:"templates"`
+	Overrides        string   `yaml:"template_overrides"`
+	Sprig            *bool    `yaml:"sprig"`
+	OutputSuffix     string   `yaml:"output_suffix"`
+	Emit             []string `yaml:"emit"`
+	Copyright        string   `yaml:"copyright"`
+	BuildTags        string   `yaml:"build_tags"`
+	Stamp            *bool    `yaml:"stamp"`
+	Chaos            *bool    `yaml:"chaos"`
+	Coverage         *bool    `yaml:"coverage"`
+	Trace            *bool    `yaml:"trace"`
+	Publisher        *bool    `yaml:"publisher"`
+	SideBySide       *bool    `yaml:"side_by_side"`
+	Callbacks        *bool    `yaml:"callbacks"`
+	UserRegions      *bool    `yaml:"user_regions"`
+	ImmutableContext *bool    `yaml:"immutable_context"`
+	RecoverPanics    *bool    `yaml:"recover_panics"`
+	Backend          string   `yaml:"backend"`
+
+	// PostProcess are the commands run on every generated Go file, from
+	// postProcessDir, the directory of the configuration file declaring them
+	PostProcess    []string `yaml:"post_process"`
+	postProcessDir string
+
+	// Lint overrides the severity of lint rules by ID
+	Lint map[string]lint.Severity `yaml:"lint"`
+}
+
+// readConfig reads the configuration file in dir, which is empty when dir has
+// none. A relative templates directory is resolved against dir.
+func readConfig(dir string) (config, error) {
+	var c config
+	path := filepath.Join(dir, configName)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	for id, severity := range c.Lint {
+		if _, err := lint.ParseSeverity(string(severity)); err != nil {
+			return c, fmt.Errorf("%s: lint rule %s: %w", path, id, err)
+		}
+	}
+	if err := lint.CheckSeverities(c.Lint); err != nil {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.Templates != "" && !filepath.IsAbs(c.Templates) {
+		c.Templates = filepath.Join(dir, c.Templates)
+	}
+	if c.Overrides != "" && !filepath.IsAbs(c.Overrides) {
+		c.Overrides = filepath.Join(dir, c.Overrides)
+	}
+	for i, command := range c.PostProcess {
+		if len(strings.Fields(command)) == 0 {
+			return c, fmt.Errorf("%s: post_process command %d is empty", path, i+1)
+		}
+	}
+	c.postProcessDir = dir
+	return c, nil
+}
+
+// overlay returns c with the values set in nearer replacing its own
+func (c config) overlay(nearer config) config {
+	if nearer.Package != "" {
+		c.Package = nearer.Package
+	}
+	if nearer.Templates != "" {
+		c.Templates = nearer.Templates
+	}
+	if nearer.Overrides != "" {
+		c.Overrides = nearer.Overrides
+	}
+	if nearer.Sprig != nil {
+		c.Sprig = nearer.Sprig
+	}
+	if nearer.OutputSuffix != "" {
+		c.OutputSuffix = nearer.OutputSuffix
+	}
+	if nearer.Emit != nil {
+		c.Emit = nearer.Emit
+	}
+	if nearer.Copyright != "" {
+		c.Copyright = nearer.Copyright
+	}
+	if nearer.BuildTags != "" {
+		c.BuildTags = nearer.BuildTags
+	}
+	if nearer.Backend != "" {
+		c.Backend = nearer.Backend
+	}
+	if nearer.PostProcess != nil {
+		c.PostProcess = nearer.PostProcess
+		c.postProcessDir = nearer.postProcessDir
+	}
+	if nearer.Stamp != nil {
+		c.Stamp = nearer.Stamp
+	}
+	if nearer.Chaos != nil {
+		c.Chaos = nearer.Chaos
+	}
+	if nearer.Publisher != nil {
+		c.Publisher = nearer.Publisher
+	}
+	if nearer.SideBySide != nil {
+		c.SideBySide = nearer.SideBySide
+	}
+	if nearer.Callbacks != nil {
+		c.Callbacks = nearer.Callbacks
+	}
+	if nearer.UserRegions != nil {
+		c.UserRegions = nearer.UserRegions
+	}
+	if nearer.ImmutableContext != nil {
+		c.ImmutableContext = nearer.ImmutableContext
+	}
+	if nearer.RecoverPanics != nil {
+		c.RecoverPanics = nearer.RecoverPanics
+	}
+	if len(nearer.Lint) > 0 {
+		merged := make(map[string]lint.Severity, len(c.Lint)+len(nearer.Lint))
+		for id, severity := range c.Lint {
+			merged[id] = severity
+		}
+		for id, severity := range nearer.Lint {
+			merged[id] = severity
+		}
+		c.Lint = merged
+	}
+	return c
+}
+
+// isProjectRoot reports whether dir is the root of a module or repository, where
+// the configuration lookup stops
+func isProjectRoot(dir string) bool {
+	for _, marker := range []string{"go.mod", ".git"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// configs caches the merged configuration of each directory
+type configs map[string]config
+
+// get returns the configuration of dir: the configuration files of dir and its
+// parents up to the project root, with nearer files taking precedence
+func (c configs) get(dir string) (config, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return config{}, err
+	}
+	if merged, ok := c[abs]; ok {
+		return merged, nil
+	}
+
+	var merged config
+	if parent := filepath.Dir(abs); !isProjectRoot(abs) && parent != abs {
+		if merged, err = c.get(parent); err != nil {
+			return config{}, err
+		}
+	}
+	own, err := readConfig(abs)
+	if err != nil {
+		return config{}, err
+	}
+	merged = merged.overlay(own)
+	c[abs] = merged
+	return merged, nil
+}
+
+// generatorKey identifies the generators that generators caches
+type generatorKey struct {
+	// templates is the template directory, empty for the bundled templates,
+	// and overrides a directory of templates redefining some of them
+	templates string
+	overrides string
+
+	// sprig adds the Sprig template functions, and debug reports the
+	// templates executed to stderr
+	sprig bool
+	debug bool
+}
+
+// generators caches a code generator for each template directory
+type generators map[generatorKey]*generator.CodeGenerator
+
+// get returns the generator configured by key
+func (g generators) get(key generatorKey) (*generator.CodeGenerator, error) {
+	if gen, ok := g[key]; ok {
+		return gen, nil
+	}
+	var opts []generator.GeneratorOption
+	if key.overrides != "" {
+		opts = append(opts, generator.WithTemplateOverrides(key.overrides))
+	}
+	if key.sprig {
+		opts = append(opts, generator.WithSprigFuncs())
+	}
+	if key.debug {
+		opts = append(opts, generator.WithTemplateDebug(logger.stderr))
+	}
+	gen, err := generator.NewCodeGeneratorWithTemplateDir(key.templates, opts...)
+	if err != nil {
+		return nil, err
+	}
+	g[key] = gen
+	return gen, nil
+}
+
+// boolFlag is a boolean flag that records whether it was given, so that an
+// explicit -stamp=false overrides a configuration file
+type boolFlag struct {
+	value bool
+	set   bool
+}
+
+func (b *boolFlag) String() string { return strconv.FormatBool(b.value) }
+
+func (b *boolFlag) Set(s string) error {
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	b.value, b.set = v, true
+	return nil
+}
+
+func (b *boolFlag) IsBoolFlag() bool { return true }
+
+// or returns the flag value if it was given, and otherwise the configured value
+func (b boolFlag) or(configured *bool) bool {
+	if b.set || configured == nil {
+		return b.value
+	}
+	return *configured
+}
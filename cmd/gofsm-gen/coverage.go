@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/coverage"
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// coverageCommand implements "gofsm-gen coverage": it merges the coverage profiles
+// written by machines generated with the coverage option and reports which
+// transitions and states of the spec they exercised
+func coverageCommand(fs *flag.FlagSet) commandFunc {
+	var specs specList
+	fs.Var(&specs, "spec", "FSM specification file (YAML) the profiles were recorded against")
+	format := fs.String("format", "text", "output format: text or json")
+	merge := fs.String("merge", "", "also write the merged profile to this file")
+	minPercent := fs.Float64("min", 0, "fail when less than this percentage of transitions is covered")
+	return func(args []string, stdout, stderr io.Writer) int {
+		if *format != "text" && *format != "json" {
+			fmt.Fprintf(stderr, "gofsm-gen coverage: unknown -format %q (use text or json)\n", *format)
+			return 2
+		}
+		if len(args) == 0 {
+			fmt.Fprintf(stderr, "gofsm-gen coverage: no coverage profiles given\n")
+			return 2
+		}
+
+		fsm, err := loadSingleSpec(specs)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen coverage: %v\n", err)
+			return 1
+		}
+
+		var profiles []coverage.Profile
+		for _, path := range args {
+			read, err := readProfiles(path)
+			if err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen coverage: %v\n", err)
+				return 1
+			}
+			profiles = append(profiles, read...)
+		}
+		merged := coverage.Merge(fsm.Name, profiles)
+		if len(merged.Transitions) == 0 {
+			logger.Warn("no coverage recorded for machine", "machine", fsm.Name)
+		}
+
+		if *merge != "" {
+			var buf bytes.Buffer
+			if err := json.NewEncoder(&buf).Encode(merged); err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen coverage: %v\n", err)
+				return 1
+			}
+			if _, err := writeFile(generator.PlannedFile{Path: *merge, Content: buf.Bytes()}, false); err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen coverage: %v\n", err)
+				return 1
+			}
+			logger.Info("wrote merged profile", "path", *merge)
+		}
+
+		report := coverage.NewReport(fsm, merged)
+		if *format == "json" {
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen coverage: %v\n", err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "%s\n", out)
+		} else {
+			writeCoverage(stdout, report)
+		}
+
+		if report.Percent() < *minPercent {
+			fmt.Fprintf(stderr, "gofsm-gen coverage: %.1f%% of transitions covered, below -min %.1f%%\n", report.Percent(), *minPercent)
+			return 1
+		}
+		return 0
+	}
+}
+
+// readProfiles reads the coverage profiles in a file
+func readProfiles(path string) ([]coverage.Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles, err := coverage.ReadProfiles(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// writeCoverage prints the fire count of every transition, followed by the states
+// never visited and the profile transitions the spec no longer defines
+func writeCoverage(w io.Writer, r *coverage.Report) {
+	fmt.Fprintf(w, "%s: %d/%d transitions covered (%.1f%%), %d/%d states visited\n\n", r.Machine,
+		r.TransitionsCovered(), len(r.Transitions), r.Percent(), r.StatesVisited(), len(r.States))
+	for _, t := range r.Transitions {
+		fmt.Fprintf(w, "%8d  %s\n", t.Count, formatTransition(t))
+	}
+
+	var unvisited []string
+	for _, s := range r.States {
+		if !s.Visited {
+			unvisited = append(unvisited, s.Name)
+		}
+	}
+	if len(unvisited) > 0 {
+		fmt.Fprintf(w, "\nstates never visited: %s\n", strings.Join(unvisited, ", "))
+	}
+
+	if len(r.Stale) > 0 {
+		fmt.Fprintf(w, "\nnot in the spec (recorded against an older version?):\n")
+		for _, t := range r.Stale {
+			fmt.Fprintf(w, "%8d  %s\n", t.Count, formatTransition(t))
+		}
+	}
+}
+
+// formatTransition renders a profile transition like a one-step path
+func formatTransition(t coverage.Transition) string {
+	return model.FormatPath(t.From, []*model.Transition{{Event: t.Event, Guard: t.Guard, To: t.To}})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// diffCommand implements "gofsm-gen diff": it compares two versions of a spec and
+// prints the states, events, and transitions the new version adds, removes, or
+// modifies, as text or as a Graphviz digraph for design reviews
+func diffCommand(fs *flag.FlagSet) commandFunc {
+	format := fs.String("format", "text", "output format: text or dot")
+	templates := fs.String("templates", "", "template directory (default: bundled templates)")
+	detailed := fs.Bool("detailed-exitcode", false, "exit with status 2 when the versions differ")
+	return func(args []string, stdout, stderr io.Writer) int {
+		if *format != "text" && *format != "dot" {
+			fmt.Fprintf(stderr, "gofsm-gen diff: unknown -format %q (use text or dot)\n", *format)
+			return 2
+		}
+		if len(args) != 2 {
+			fmt.Fprintf(stderr, "gofsm-gen diff: must specify the old and the new spec\n")
+			return 2
+		}
+
+		old, err := loadSingleSpec(args[:1])
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen diff: %v\n", err)
+			return 1
+		}
+		updated, err := loadSingleSpec(args[1:])
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen diff: %v\n", err)
+			return 1
+		}
+
+		d := model.Compare(old, updated)
+		if *format == "dot" {
+			gen, err := generator.NewCodeGeneratorWithTemplateDir(*templates)
+			if err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen diff: %v\n", err)
+				return 1
+			}
+			out, err := gen.GenerateDOTDiff(old, updated)
+			if err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen diff: %v\n", err)
+				return 1
+			}
+			stdout.Write(out)
+		} else {
+			writeMachineDiff(stdout, updated.Name, d)
+		}
+
+		if *detailed && !d.Empty() {
+			return 2
+		}
+		return 0
+	}
+}
+
+// changeMarks prefix the lines of the text diff
+var changeMarks = map[model.Change]string{
+	model.Added:    "+",
+	model.Removed:  "-",
+	model.Modified: "~",
+}
+
+// writeMachineDiff prints every changed element of a machine diff on its own line
+func writeMachineDiff(w io.Writer, name string, d *model.MachineDiff) {
+	if d.Empty() {
+		fmt.Fprintf(w, "%s: no changes\n", name)
+		return
+	}
+
+	fmt.Fprintf(w, "%s:\n", name)
+	if d.OldInitial != d.NewInitial {
+		fmt.Fprintf(w, "~ initial %s -> %s\n", d.OldInitial, d.NewInitial)
+	}
+	for _, s := range d.States {
+		writeChange(w, s.Change, "state "+s.Name, s.Details)
+	}
+	for _, e := range d.Events {
+		writeChange(w, e.Change, "event "+e.Name, nil)
+	}
+	for _, t := range d.Transitions {
+		writeChange(w, t.Change, "transition "+model.FormatPath(t.From, []*model.Transition{t.Transition}), t.Details)
+	}
+}
+
+// writeChange prints a changed element with the details of a modification
+func writeChange(w io.Writer, change model.Change, element string, details []string) {
+	if change == model.Unchanged {
+		return
+	}
+	if len(details) > 0 {
+		element += " (" + strings.Join(details, ", ") + ")"
+	}
+	fmt.Fprintf(w, "%s %s\n", changeMarks[change], element)
+}
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+)
+
+// generatePrefix starts the directives go generate runs
+const generatePrefix = "//go:generate "
+
+// directiveCommand implements "gofsm-gen directive": it prints the canonical
+// //go:generate line that runs gofsm-gen with the given flags and specs from the
+// directory of a Go file, or with -w adds it to the file
+func directiveCommand(fs *flag.FlagSet) commandFunc {
+	var flags generateFlags
+	flags.register(fs)
+	dir := fs.String("dir", "", "directory of the Go file the directive goes in (default: the directory of the first spec)")
+	write := fs.String("w", "", "add the directive to this Go file, creating it if needed, instead of printing it")
+	return func(args []string, stdout, stderr io.Writer) int {
+		if err := flags.directive(fs, args, *dir, *write, stdout); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen directive: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// directive prints or writes the go:generate line of the generation flags set on
+// fs and the specs in args
+func (f *generateFlags) directive(fs *flag.FlagSet, args []string, dir, write string, stdout io.Writer) error {
+	switch {
+	case f.anchor != "":
+		return fmt.Errorf("-anchor-file reads a directive; it cannot be part of one")
+	case dir != "" && write != "":
+		return fmt.Errorf("-dir cannot be combined with -w, which writes into the directory of its file")
+	case write != "":
+		dir = filepath.Dir(write)
+	}
+	jobs, err := f.loadJobs(args)
+	if err != nil {
+		return err
+	}
+	if dir == "" {
+		dir = filepath.Dir(jobs[0].spec)
+	}
+
+	line, err := directiveLine(fs, args, dir)
+	if err != nil {
+		return err
+	}
+	if write == "" {
+		fmt.Fprintln(stdout, line)
+		return nil
+	}
+
+	pkg := inferPackageName(dir)
+	if j := jobs[0]; filepath.Clean(j.dir(f.split)) == filepath.Clean(dir) {
+		pkg = j.fsm.Package
+	}
+	written, err := addDirective(write, pkg, line)
+	if err != nil {
+		return err
+	}
+	if written {
+		logger.file(stdout, "wrote", write)
+	} else {
+		logger.file(stdout, "unchanged", write)
+	}
+	return nil
+}
+
+// directiveLine returns the go:generate line running gofsm-gen from dir with the
+// generation flags set on fs, in name order, and the specs in args. Paths are
+// made relative to dir, where go generate runs the command.
+func directiveLine(fs *flag.FlagSet, args []string, dir string) (string, error) {
+	generation := generationFlagNames()
+	words := []string{"gofsm-gen"}
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		if !generation[fl.Name] || err != nil {
+			return
+		}
+		values := []string{fl.Value.String()}
+		if list, ok := fl.Value.(*specList); ok {
+			values = *list
+		}
+		for _, value := range values {
+			if pathFlags[fl.Name] {
+				if value, err = relativeTo(dir, fl.Name, value); err != nil {
+					return
+				}
+			}
+			switch b, ok := fl.Value.(interface{ IsBoolFlag() bool }); {
+			case ok && b.IsBoolFlag() && value == "true":
+				words = append(words, "-"+fl.Name)
+			default:
+				words = append(words, quoteWord("-"+fl.Name+"="+value))
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, arg := range args {
+		rel, err := relativeTo(dir, "spec", arg)
+		if err != nil {
+			return "", err
+		}
+		words = append(words, quoteWord(rel))
+	}
+	return generatePrefix + strings.Join(words, " "), nil
+}
+
+// generationFlagNames returns the names of the flags generateFlags registers
+func generationFlagNames() map[string]bool {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	new(generateFlags).register(fs)
+	names := make(map[string]bool)
+	fs.VisitAll(func(fl *flag.Flag) { names[fl.Name] = true })
+	return names
+}
+
+// pathFlags are the generation flags whose values are paths, which directives
+// give relative to the directory of their Go file
+var pathFlags = map[string]bool{
+	"spec":               true,
+	"out":                true,
+	"templates":          true,
+	"template-overrides": true,
+	"registry":           true,
+	"plugin":             true,
+}
+
+// relativeTo returns the path value of the flag name relative to dir, keeping
+// "dir/..." patterns, -out=-, and plugins looked up in PATH as they are
+func relativeTo(dir, name, value string) (string, error) {
+	switch {
+	case name == "out" && value == stdoutPath:
+		return value, nil
+	case name == "plugin" && !strings.ContainsRune(value, filepath.Separator) && !strings.ContainsRune(value, '/'):
+		return value, nil
+	}
+	recursive := isRecursive(value)
+	if recursive {
+		value = strings.TrimSuffix(value, "...")
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	absValue, err := filepath.Abs(value)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absDir, absValue)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	switch {
+	case recursive && rel == ".":
+		return "./...", nil
+	case recursive:
+		return rel + "/...", nil
+	case name == "plugin" && !strings.Contains(rel, "/"):
+		// a bare name would be looked up in PATH instead
+		return "./" + rel, nil
+	}
+	return rel, nil
+}
+
+// quoteWord quotes a word of a directive that go generate would otherwise split
+// or expand. go generate only unquotes whole words, so flags are quoted with
+// their names.
+func quoteWord(word string) string {
+	if word == "" || strings.ContainsAny(word, " \t\"$") {
+		return strconv.Quote(strings.ReplaceAll(word, "$", "$DOLLAR"))
+	}
+	return word
+}
+
+// addDirective adds line after the package clause of the Go file at path, which
+// is created with package pkg if it does not exist. It reports whether the file
+// was written, which it is not when it already holds the line.
+func addDirective(path, pkg, line string) (bool, error) {
+	src, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return writeFile(generator.PlannedFile{Path: path, Content: []byte("package " + pkg + "\n\n" + line + "\n")}, false)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	lines := strings.SplitAfter(string(src), "\n")
+	clause := -1
+	for i, l := range lines {
+		if strings.TrimRight(l, "\r\n") == line {
+			return false, nil
+		}
+		if clause < 0 && strings.HasPrefix(l, "package ") {
+			clause = i
+		}
+	}
+	if clause < 0 {
+		return false, fmt.Errorf("%s has no package clause", path)
+	}
+	if !strings.HasSuffix(lines[clause], "\n") {
+		lines[clause] += "\n"
+	}
+	updated := strings.Join(lines[:clause+1], "") + "\n" + line + "\n" + strings.Join(lines[clause+1:], "")
+	return writeFile(generator.PlannedFile{Path: path, Content: []byte(updated)}, false)
+}
+
+// anchored returns the specs to generate. With -anchor-file, the flags and specs
+// are those of the gofsm-gen directive of the Go file, as go generate would run
+// it, so that other commands reuse the arguments of the directive instead of
+// repeating them. The directive cannot be combined with other generation flags
+// or specs.
+func (f *generateFlags) anchored(fs *flag.FlagSet, args []string) ([]string, error) {
+	anchor := f.anchor
+	if anchor == "" {
+		return args, nil
+	}
+	generation := generationFlagNames()
+	var conflict string
+	fs.Visit(func(fl *flag.Flag) {
+		if generation[fl.Name] && fl.Name != "anchor-file" && conflict == "" {
+			conflict = fl.Name
+		}
+	})
+	if conflict != "" {
+		return nil, fmt.Errorf("-anchor-file cannot be combined with -%s; the directive sets the flags", conflict)
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("-anchor-file cannot be combined with specs; the directive names them")
+	}
+
+	directives, err := generateDirectives(anchor)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(directives) == 0:
+		return nil, fmt.Errorf("%s has no //go:generate directive running gofsm-gen", anchor)
+	case len(directives) > 1:
+		return nil, fmt.Errorf("%s has %d //go:generate directives running gofsm-gen; -anchor-file needs exactly one", anchor, len(directives))
+	}
+
+	directive := flag.NewFlagSet("//go:generate gofsm-gen", flag.ContinueOnError)
+	directive.SetOutput(io.Discard)
+	f.register(directive)
+	if err := directive.Parse(directives[0]); err != nil {
+		return nil, fmt.Errorf("%s: %w", anchor, err)
+	}
+	f.anchor = anchor
+
+	// go generate runs the directive in the directory of its file
+	dir := filepath.Dir(anchor)
+	rebase := func(name, value string) string {
+		switch {
+		case value == "" || filepath.IsAbs(value):
+		case name == "out" && value == stdoutPath:
+		case name == "plugin" && !strings.Contains(value, "/"):
+		default:
+			return filepath.Join(dir, filepath.FromSlash(value))
+		}
+		return value
+	}
+	for i := range f.specs {
+		f.specs[i] = rebase("spec", f.specs[i])
+	}
+	for i := range f.plugins {
+		f.plugins[i] = rebase("plugin", f.plugins[i])
+	}
+	f.out = rebase("out", f.out)
+	f.templates = rebase("templates", f.templates)
+	f.overrides = rebase("template-overrides", f.overrides)
+	f.registry = rebase("registry", f.registry)
+
+	specs := directive.Args()
+	for i, spec := range specs {
+		specs[i] = rebase("spec", spec)
+	}
+	logger.Debug("read directive", "file", anchor, "args", strings.Join(directives[0], " "))
+	return specs, nil
+}
+
+// generateDirectives returns the arguments of the //go:generate directives of
+// the Go file at path that run gofsm-gen to generate code, split and expanded as
+// go generate does. Directives running other gofsm-gen commands, or reading
+// their own file with -anchor-file, are left out.
+func generateDirectives(path string) ([][]string, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var directives [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if !strings.HasPrefix(line, generatePrefix) {
+			continue
+		}
+		words, err := splitDirective(strings.TrimPrefix(line, generatePrefix), directiveEnv(path, src, n))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		args, ok := gofsmGenArgs(words)
+		if !ok {
+			continue
+		}
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			if lookupCommand(args[0]) != nil {
+				if args[0] != "generate" {
+					continue
+				}
+				args = args[1:]
+			}
+		}
+		if anchoredDirective(args) {
+			continue
+		}
+		directives = append(directives, args)
+	}
+	return directives, scanner.Err()
+}
+
+// gofsmGenArgs returns the arguments of a directive that runs gofsm-gen, either
+// installed or with "go run"
+func gofsmGenArgs(words []string) ([]string, bool) {
+	isGofsmGen := func(word string) bool {
+		word, _, _ = strings.Cut(word, "@")
+		return strings.TrimSuffix(filepath.Base(filepath.FromSlash(word)), ".exe") == "gofsm-gen"
+	}
+	switch {
+	case len(words) > 0 && isGofsmGen(words[0]):
+		return words[1:], true
+	case len(words) > 2 && words[0] == "go" && words[1] == "run":
+		for i, word := range words[2:] {
+			if !strings.HasPrefix(word, "-") {
+				return words[i+3:], isGofsmGen(word)
+			}
+		}
+	}
+	return nil, false
+}
+
+// anchoredDirective reports whether args read their directive with -anchor-file
+func anchoredDirective(args []string) bool {
+	for _, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if name != arg && (name == "anchor-file" || strings.HasPrefix(name, "anchor-file=")) {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveEnv returns the variables go generate sets for the directive on line
+// n of the Go file at path with source src
+func directiveEnv(path string, src []byte, n int) func(string) string {
+	return func(name string) string {
+		switch name {
+		case "GOFILE":
+			return filepath.Base(path)
+		case "GOLINE":
+			return strconv.Itoa(n)
+		case "GOPACKAGE":
+			return packageClause(src)
+		case "GOARCH":
+			return runtime.GOARCH
+		case "GOOS":
+			return runtime.GOOS
+		case "DOLLAR":
+			return "$"
+		}
+		return os.Getenv(name)
+	}
+}
+
+// packageClause returns the package name declared by Go source
+func packageClause(src []byte) string {
+	for _, line := range strings.Split(string(src), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "package "); ok {
+			name, _, _ := strings.Cut(rest, " ")
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// splitDirective splits the command of a directive into words at spaces and
+// tabs, with words starting with a double quote unquoted as Go strings, and
+// expands the variables of each word with env, as go generate does
+func splitDirective(command string, env func(string) string) ([]string, error) {
+	var words []string
+	for {
+		command = strings.TrimLeft(command, " \t")
+		if command == "" {
+			return words, nil
+		}
+		if command[0] == '"' {
+			end := 1
+			for ; end < len(command) && command[end] != '"'; end++ {
+				if command[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(command) {
+				return nil, fmt.Errorf("unterminated quoted string in //go:generate")
+			}
+			word, err := strconv.Unquote(command[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("bad quoted string in //go:generate: %w", err)
+			}
+			words = append(words, os.Expand(word, env))
+			command = command[end+1:]
+			if command != "" && command[0] != ' ' && command[0] != '\t' {
+				return nil, fmt.Errorf("text after quoted string in //go:generate")
+			}
+			continue
+		}
+		end := strings.IndexAny(command, " \t")
+		if end < 0 {
+			end = len(command)
+		}
+		words = append(words, os.Expand(command[:end], env))
+		command = command[end:]
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+)
+
+// Artifacts that can be selected with -emit
+const (
+	emitMachine  = "machine"
+	emitTests    = "tests"
+	emitTestkit  = "testkit"
+	emitDiagram  = "diagram"
+	emitGRPC     = "grpc"
+	emitHTTP     = "http"
+	emitTemporal = "temporal"
+	emitORM      = "orm"
+	emitStubs    = "stubs"
+)
+
+// emitTargetNames lists the supported -emit targets in generation order
+var emitTargetNames = []string{emitMachine, emitTests, emitTestkit, emitDiagram, emitGRPC, emitHTTP, emitTemporal, emitORM, emitStubs}
+
+// emitReplacements names what to use instead of -emit targets gofsm-gen does not
+// generate
+var emitReplacements = map[string]string{
+	"mocks": "testkit, whose spies stand in for guards and actions",
+	"docs":  `"gofsm-gen export markdown"`,
+}
+
+// emitTargets is the set of artifacts to generate
+type emitTargets map[string]bool
+
+// targets resolves the artifacts to generate from -emit, or else the configured
+// emit list, and the -gen-tests, -testkit, and -gen-stubs shorthands.
+// Without either list only the machine is generated.
+func (f *generateFlags) targets(config config) (emitTargets, error) {
+	emit := f.emit
+	if emit == "" {
+		emit = strings.Join(config.Emit, ",")
+	}
+
+	targets := emitTargets{}
+	if emit == "" {
+		targets[emitMachine] = true
+	} else {
+		for _, name := range strings.Split(emit, ",") {
+			name = strings.TrimSpace(name)
+			if replacement, ok := emitReplacements[name]; ok {
+				return nil, fmt.Errorf("-emit target %q is not supported; use %s", name, replacement)
+			}
+			if !isEmitTarget(name) {
+				return nil, fmt.Errorf("-emit target %q is not supported (use %s)", name, strings.Join(emitTargetNames, ", "))
+			}
+			targets[name] = true
+		}
+	}
+
+	if f.genTests {
+		targets[emitTests] = true
+	}
+	if f.testkit {
+		targets[emitTestkit] = true
+	}
+	if f.genStubs {
+		targets[emitStubs] = true
+	}
+	return targets, nil
+}
+
+// String lists the targets in generation order, comma-separated
+func (t emitTargets) String() string {
+	var names []string
+	for _, name := range emitTargetNames {
+		if t[name] {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// isEmitTarget reports whether name is a supported -emit target
+func isEmitTarget(name string) bool {
+	for _, target := range emitTargetNames {
+		if name == target {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMachineCurrent rejects generating artifacts that depend on the machine code
+// while the machine files on disk are missing or differ from what the spec produces
+func checkMachineCurrent(spec string, machine []generator.PlannedFile, dependents []string) error {
+	for _, f := range machine {
+		existing, err := os.ReadFile(f.Path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err != nil || !bytes.Equal(existing, f.Content) {
+			return fmt.Errorf("%s: %s depend on the generated machine, but %s is missing or out of date; add %s to -emit",
+				spec, strings.Join(dependents, " and "), f.Path, emitMachine)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+// exportRenderer renders a model in an export format
+type exportRenderer func(gen *generator.CodeGenerator, m *model.FSMModel) ([]byte, error)
+
+// exportFormat is a target of "gofsm-gen export"
+type exportFormat struct {
+	// description is shown in the usage of the export command
+	description string
+
+	// register binds the flags of the format to fs and returns its renderer
+	register func(fs *flag.FlagSet) exportRenderer
+
+	// outputName is the default file name written beside the spec
+	outputName func(m *model.FSMModel) string
+}
+
+// exportFormats are the supported export targets by name
+var exportFormats = map[string]exportFormat{
+	"csv": {
+		description: "state × event transition matrix for spreadsheets",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			var opts generator.CSVOptions
+			fs.BoolVar(&opts.BOM, "bom", false, "prefix a UTF-8 byte order mark so Excel detects the encoding")
+			return func(gen *generator.CodeGenerator, m *model.FSMModel) ([]byte, error) {
+				return gen.GenerateCSV(m, opts)
+			}
+		},
+		outputName: generator.CSVOutputName,
+	},
+	"dot": {
+		description: "Graphviz digraph with final, unreachable, clustered, and tag-styled states",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			var opts generator.DOTOptions
+			var tagStyles specList
+			fs.StringVar(&opts.ClusterBy, "cluster-by", generator.DefaultDOTClusterKey, "state metadata key whose values group states into clusters")
+			fs.Var(&tagStyles, "tag-style", "node attributes of states with a tag, as tag:attr=value,...; may be repeated")
+			return func(gen *generator.CodeGenerator, m *model.FSMModel) ([]byte, error) {
+				opts.TagStyles = make(map[string]string, len(tagStyles))
+				for _, style := range tagStyles {
+					tag, attrs, ok := strings.Cut(style, ":")
+					if !ok || tag == "" {
+						return nil, fmt.Errorf("-tag-style %q is not tag:attr=value,...", style)
+					}
+					opts.TagStyles[tag] = attrs
+				}
+				return gen.GenerateDOT(m, opts)
+			}
+		},
+		outputName: generator.DOTOutputName,
+	},
+	"html": {
+		description: "self-contained interactive graph for design reviews",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			return (*generator.CodeGenerator).GenerateHTML
+		},
+		outputName: generator.HTMLOutputName,
+	},
+	"markdown": {
+		description: "transition table and state reference for design docs",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			return (*generator.CodeGenerator).GenerateMarkdown
+		},
+		outputName: generator.MarkdownOutputName,
+	},
+	"openapi": {
+		description: "OpenAPI component schemas for the state and event enums and allowed events",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			var opts generator.OpenAPIOptions
+			fs.StringVar(&opts.Version, "api-version", "", "info.version of the exported document (default 1.0.0)")
+			return func(gen *generator.CodeGenerator, m *model.FSMModel) ([]byte, error) {
+				return gen.GenerateOpenAPI(m, opts)
+			}
+		},
+		outputName: generator.OpenAPIOutputName,
+	},
+	"proto": {
+		description: "Protocol Buffers enums matching the generated state and event constants",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			var opts generator.ProtoOptions
+			fs.StringVar(&opts.Package, "proto-package", "", "proto package name (default: Go package of the machine)")
+			fs.BoolVar(&opts.TransitionRecord, "transition-record", false, "also declare a <Machine>TransitionRecord message")
+			return func(gen *generator.CodeGenerator, m *model.FSMModel) ([]byte, error) {
+				return gen.GenerateProto(m, opts)
+			}
+		},
+		outputName: generator.ProtoOutputName,
+	},
+	"sql": {
+		description: "PostgreSQL CHECK constraint and trigger enforcing states and transitions",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			var opts generator.SQLOptions
+			fs.StringVar(&opts.Table, "table", "", "table storing the state, optionally schema-qualified (default: snake_case machine name)")
+			fs.StringVar(&opts.Column, "column", generator.DefaultSQLColumn, "column holding the state name")
+			return func(gen *generator.CodeGenerator, m *model.FSMModel) ([]byte, error) {
+				return gen.GenerateSQL(m, opts)
+			}
+		},
+		outputName: generator.SQLOutputName,
+	},
+	"tla": {
+		description: "TLA+ module with the transition relation for model checking",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			return (*generator.CodeGenerator).GenerateTLA
+		},
+		outputName: generator.TLAOutputName,
+	},
+	"typescript": {
+		description: "TypeScript state and event unions with a transition map",
+		register: func(fs *flag.FlagSet) exportRenderer {
+			return (*generator.CodeGenerator).GenerateTypeScript
+		},
+		outputName: generator.TypeScriptOutputName,
+	},
+}
+
+// exportFormatNames returns the names of the export formats, sorted
+func exportFormatNames() []string {
+	names := make([]string, 0, len(exportFormats))
+	for name := range exportFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exportUsage lists the export formats
+func exportUsage() string {
+	var b strings.Builder
+	b.WriteString("Usage: gofsm-gen export <format> [flags] [spec]\n\nFormats:\n")
+	for _, name := range exportFormatNames() {
+		fmt.Fprintf(&b, "  %-8s %s\n", name, exportFormats[name].description)
+	}
+	b.WriteString("\nRun \"gofsm-gen export <format> -h\" for format flags.\n")
+	return b.String()
+}
+
+// runExport implements "gofsm-gen export": it renders every spec in a format
+// other languages and tools consume, writing the result beside the spec. The
+// flags depend on the format, so it parses them itself.
+func runExport(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprint(stderr, exportUsage())
+		return 2
+	}
+	format, ok := exportFormats[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "gofsm-gen export: unknown format %q\n\n%s", args[0], exportUsage())
+		return 2
+	}
+
+	return exportCommand(format).run("gofsm-gen export "+args[0], args[1:], stdout, stderr)
+}
+
+// exportCommand returns the setup of "gofsm-gen export" for format
+func exportCommand(format exportFormat) commandSetup {
+	return func(fs *flag.FlagSet) commandFunc {
+		var specs specList
+		fs.Var(&specs, "spec", "FSM specification file (YAML); may be repeated")
+		out := fs.String("out", "", "output file path (single spec only; default beside the spec; - for stdout)")
+		templates := fs.String("templates", "", "template directory (default: from "+configName+" or bundled templates)")
+		force := fs.Bool("force", false, "rewrite output files even when their content is unchanged")
+		render := format.register(fs)
+		return func(args []string, stdout, stderr io.Writer) int {
+			specs = append(specs, args...)
+			if len(specs) == 0 {
+				fmt.Fprintf(stderr, "gofsm-gen export: must specify -spec\n")
+				return 1
+			}
+			if *out != "" && len(specs) > 1 {
+				fmt.Fprintf(stderr, "gofsm-gen export: -out cannot be used with multiple specs\n")
+				return 1
+			}
+
+			p := parser.NewYAMLParser()
+			configs := configs{}
+			gens := generators{}
+			for _, spec := range specs {
+				fsm, err := p.ParseFile(spec)
+				if err != nil {
+					fmt.Fprintf(stderr, "gofsm-gen export: %v\n", err)
+					return 1
+				}
+				logger.parsed(spec, fsm)
+
+				config, err := configs.get(filepath.Dir(spec))
+				if err != nil {
+					fmt.Fprintf(stderr, "gofsm-gen export: %v\n", err)
+					return 1
+				}
+				switch {
+				case fsm.Package != "":
+				case config.Package != "":
+					fsm.Package = config.Package
+				default:
+					fsm.Package = inferPackageName(filepath.Dir(spec))
+				}
+
+				dir := *templates
+				if dir == "" {
+					dir = config.Templates
+				}
+				gen, err := gens.get(generatorKey{templates: dir, overrides: config.Overrides, sprig: config.Sprig != nil && *config.Sprig})
+				if err != nil {
+					fmt.Fprintf(stderr, "gofsm-gen export: %v\n", err)
+					return 1
+				}
+
+				content, err := render(gen, fsm)
+				if err != nil {
+					fmt.Fprintf(stderr, "gofsm-gen export: %s: %v\n", spec, err)
+					return 1
+				}
+
+				if *out == stdoutPath {
+					if _, err := stdout.Write(content); err != nil {
+						fmt.Fprintf(stderr, "gofsm-gen export: %v\n", err)
+						return 1
+					}
+					continue
+				}
+
+				path := *out
+				if path == "" {
+					path = filepath.Join(filepath.Dir(spec), format.outputName(fsm))
+				}
+				written, err := writeFile(generator.PlannedFile{Path: path, Content: content}, *force)
+				if err != nil {
+					fmt.Fprintf(stderr, "gofsm-gen export: %v\n", err)
+					return 1
+				}
+				if written {
+					logger.file(stdout, "wrote", path)
+				} else {
+					logger.file(stdout, "unchanged", path)
+				}
+			}
+			return 0
+		}
+	}
+}
+
+// anyExportFormat registers the flags of every export format, so that man pages
+// and shell completions cover all of them
+var anyExportFormat = exportFormat{
+	register: func(fs *flag.FlagSet) exportRenderer {
+		for _, name := range exportFormatNames() {
+			exportFormats[name].register(fs)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,946 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+	"github.com/yourusername/gofsm-gen/pkg/plugin"
+)
+
+// specList collects repeated -spec flags
+type specList []string
+
+func (s *specList) String() string { return strings.Join(*s, ",") }
+
+func (s *specList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// generateFlags are the flags shared by every command that renders code
+type generateFlags struct {
+	specs       specList
+	out         string
+	pkg         string
+	templates   string
+	overrides   string
+	sprig       boolFlag
+	debugTmpl   bool
+	prune       bool
+	genTests    bool
+	testkit     bool
+	genStubs    bool
+	chaos       boolFlag
+	coverage    boolFlag
+	trace       boolFlag
+	publisher   boolFlag
+	versions    boolFlag
+	callbacks   boolFlag
+	userRegions boolFlag
+	immutable   boolFlag
+	recovery    boolFlag
+	reentrancy  string
+	unhandled   string
+	keys        int
+	constants   string
+	machineName boolFlag
+	acronyms    string
+	initialisms boolFlag
+	split       bool
+	copyright   string
+	buildTags   string
+	stamp       boolFlag
+	emit        string
+	pattern     string
+	backend     string
+	registry    string
+	plugins     specList
+	anchor      string
+}
+
+// register binds the generation flags to fs
+func (f *generateFlags) register(fs *flag.FlagSet) {
+	fs.Var(&f.specs, "spec", "FSM specification file (YAML); may be repeated")
+	fs.StringVar(&f.out, "out", "", "output file path (single spec only; default <machine>_fsm.gen.go beside the spec; - for stdout), or directory with -split or another backend")
+	fs.StringVar(&f.pkg, "package", "", "package name for generated code (default: from spec or output directory)")
+	fs.StringVar(&f.templates, "templates", "", "template directory (default: from "+configName+" or bundled templates)")
+	fs.StringVar(&f.overrides, "template-overrides", "", "directory of templates redefining extension blocks such as extra_machine_methods (default: from "+configName+")")
+	fs.Var(&f.sprig, "sprig", "make a subset of the Sprig template functions available to custom templates")
+	fs.BoolVar(&f.debugTmpl, "debug-templates", false, "report to stderr the data passed to each template, the output lines each template produced, and the template source of execution errors")
+	fs.BoolVar(&f.genTests, "gen-tests", false, "also generate a _test.go file exercising every transition")
+	fs.BoolVar(&f.testkit, "testkit", false, "also generate a <machine>_testkit.go with a test machine, assertions, and spies")
+	fs.BoolVar(&f.genStubs, "gen-stubs", false, "also create a <machine>_callbacks.go with stub callbacks to fill in, appending stubs for new callbacks to an existing one")
+	fs.Var(&f.chaos, "chaos", "generate FireRandomPermitted and RunChaos chaos-testing helpers")
+	fs.Var(&f.coverage, "coverage", "generate transition counters and a Write<Machine>Coverage function for \"gofsm-gen coverage\"")
+	fs.Var(&f.trace, "trace", "generate a <Machine>TraceRecorder whose JSON traces \"gofsm-gen replay\" checks against a spec")
+	fs.Var(&f.publisher, "publisher", "generate a <Machine>Publisher that WithPublisher invokes with a <Machine>TransitionRecord after each transition")
+	fs.Var(&f.versions, "side-by-side", "generate the machine as <Machine>V<version>, so that several versions of it share a package")
+	fs.Var(&f.callbacks, "callbacks", "generate a <Machine>Callbacks interface and New<Machine>WithCallbacks for dependency injection")
+	fs.Var(&f.userRegions, "user-regions", "add imports and helpers regions to the generated file whose code regeneration keeps")
+	fs.Var(&f.immutable, "immutable-context", "generate context fields with getters and copy-on-write With<Field> updaters that callbacks apply with Update<Machine>Context")
+	fs.Var(&f.recovery, "recover-panics", "recover panics of guards and actions, returning them as <Machine>ActionPanickedError")
+	fs.IntVar(&f.keys, "idempotency-keys", 0, "generate TransitionWithKey remembering this many idempotency keys per state to skip duplicate events (default: from spec or none)")
+	fs.StringVar(&f.unhandled, "unhandled-event", "", "what machines do with events the current state has no transition on: error, ignore, or log; states of the spec may override it (default: from spec or error)")
+	fs.StringVar(&f.reentrancy, "reentrancy", "", "handling of transitions fired from guards and actions of the same machine: error (return ErrReentrant<Machine>Transition) or queue (handle them after the current transition) (default: from spec or none)")
+	fs.StringVar(&f.constants, "constant-style", "", "where state and event constants name their kind: prefix (<Machine>StatePending) or suffix (<Machine>PendingState) (default: from spec or prefix)")
+	fs.Var(&f.machineName, "constant-machine-name", "include the machine name in state and event constants (default: from spec or true)")
+	fs.StringVar(&f.acronyms, "acronyms", "", "comma-separated words written in upper case in generated identifiers, e.g. ID,URL,HTTP (overrides the spec)")
+	fs.Var(&f.initialisms, "initialisms", "write common Go initialisms such as ID, HTTP, and SKU in upper case in generated identifiers (default: from spec or false)")
+	fs.BoolVar(&f.split, "split", false, "write states, events, callbacks, machine, and tests as separate <machine>_*.go files")
+	fs.StringVar(&f.copyright, "copyright", "", "banner added to the header of generated files (overrides the spec)")
+	fs.StringVar(&f.buildTags, "build-tags", "", "build constraint for generated files, e.g. '!fsm_stub' (overrides the spec)")
+	fs.Var(&f.stamp, "stamp", "record the spec path, spec checksum, and generator version in file headers")
+	fs.StringVar(&f.emit, "emit", "", "comma-separated artifacts to generate: machine, tests, testkit, diagram, grpc, http, temporal, orm, stubs (default: machine)")
+	fs.BoolVar(&f.prune, "prune", false, "remove previously generated files in output directories that are no longer produced")
+	fs.StringVar(&f.pattern, "pattern", defaultSpecPattern, "file name pattern of the specs found by dir/... arguments")
+	fs.StringVar(&f.backend, "backend", "", "output language: "+strings.Join(generator.BackendNames(), ", ")+" (default: from "+configName+" or go)")
+	fs.StringVar(&f.backend, "lang", "", "alias for -backend")
+	fs.StringVar(&f.registry, "registry", "", "also generate a package in this directory that creates every generated machine by name")
+	fs.Var(&f.plugins, "plugin", "program run for every spec that observes the model and contributes extra output files; may be repeated")
+	fs.StringVar(&f.anchor, "anchor-file", "", "take the flags and specs from the //go:generate directive running gofsm-gen in this Go file")
+}
+
+// stdoutPath is the -out value that streams generated code to stdout
+const stdoutPath = "-"
+
+// job is one spec to generate
+type job struct {
+	spec    string
+	out     string // output file, or output directory in split mode and for other backends
+	fsm     *model.FSMModel
+	targets emitTargets
+	gen     generatorKey
+	backend string
+	post    postProcessor
+}
+
+// dir returns the directory the job writes into
+func (j job) dir(split bool) string {
+	if split || j.backend != generator.GoBackendName {
+		return j.out
+	}
+	return filepath.Dir(j.out)
+}
+
+// generatorKey returns the generator the flags select for a spec with the given
+// configuration; flags take precedence
+func (f *generateFlags) generatorKey(config config) generatorKey {
+	key := generatorKey{templates: f.templates, overrides: f.overrides, sprig: f.sprig.or(config.Sprig), debug: f.debugTmpl}
+	if key.templates == "" {
+		key.templates = config.Templates
+	}
+	if key.overrides == "" {
+		key.overrides = config.Overrides
+	}
+	return key
+}
+
+// loadJobs parses every spec and resolves its output path and package
+func (f *generateFlags) loadJobs(extraSpecs []string) ([]job, error) {
+	specs, err := f.specPaths(extraSpecs)
+	if err != nil {
+		return nil, err
+	}
+	if f.out != "" && len(specs) > 1 && !f.split {
+		return nil, fmt.Errorf("-out cannot be used with multiple specs")
+	}
+	if f.prune && f.emit != "" {
+		return nil, fmt.Errorf("-prune cannot be combined with -emit, which leaves other artifacts untouched")
+	}
+	if f.out == stdoutPath && (f.split || f.prune || f.registry != "" || len(f.plugins) > 0) {
+		return nil, fmt.Errorf("-out=- cannot be combined with -split, -prune, -registry, or -plugin")
+	}
+
+	p := parser.NewYAMLParser()
+	configs := configs{}
+	jobs := make([]job, 0, len(specs))
+	for _, spec := range specs {
+		fsm, err := p.ParseFile(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		config, err := configs.get(filepath.Dir(spec))
+		if err != nil {
+			return nil, err
+		}
+		if f.prune && f.emit == "" && len(config.Emit) > 0 {
+			return nil, fmt.Errorf("%s: -prune cannot be combined with emit in %s, which leaves other artifacts untouched", spec, configName)
+		}
+		targets, err := f.targets(config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+		if f.out == stdoutPath && (len(targets) != 1 || !targets[emitMachine]) {
+			return nil, fmt.Errorf("%s: -out=- writes only the machine code; it cannot be combined with other artifacts", spec)
+		}
+		if f.split && targets[emitStubs] {
+			return nil, fmt.Errorf("%s: callback stubs cannot be combined with -split, which generates <machine>_callbacks.go", spec)
+		}
+		backend := f.backend
+		if backend == "" {
+			backend = config.Backend
+		}
+		if backend == "" {
+			backend = generator.GoBackendName
+		}
+		goBackend := backend == generator.GoBackendName
+		if !goBackend {
+			switch {
+			case f.split || f.prune || f.out == stdoutPath:
+				return nil, fmt.Errorf("%s: the %s backend cannot be combined with -split, -prune, or -out=-", spec, backend)
+			case len(targets) != 1 || !targets[emitMachine]:
+				return nil, fmt.Errorf("%s: the %s backend generates only the machine code; it cannot be combined with other artifacts", spec, backend)
+			}
+		}
+
+		if f.versions.or(config.SideBySide) || fsm.Options.SideBySide {
+			if err := fsm.UseVersionedName(); err != nil {
+				return nil, fmt.Errorf("%s: %w", spec, err)
+			}
+		}
+
+		out := f.out
+		switch {
+		case out != "":
+		case f.split || !goBackend:
+			out = filepath.Dir(spec)
+		case config.OutputSuffix != "":
+			if !strings.HasSuffix(config.OutputSuffix, ".go") {
+				return nil, fmt.Errorf("%s: output_suffix %q in %s must end in .go", spec, config.OutputSuffix, configName)
+			}
+			out = filepath.Join(filepath.Dir(spec), generator.OutputName(fsm, config.OutputSuffix))
+		default:
+			out = filepath.Join(filepath.Dir(spec), generator.DefaultOutputName(fsm))
+		}
+		j := job{spec: spec, out: out, fsm: fsm, targets: targets, gen: f.generatorKey(config), backend: backend, post: postProcessorOf(config)}
+
+		switch {
+		case f.pkg != "":
+			fsm.Package = f.pkg
+		case fsm.Package != "":
+		case config.Package != "":
+			fsm.Package = config.Package
+		case out == stdoutPath:
+			fsm.Package = inferPackageName(filepath.Dir(spec))
+		default:
+			fsm.Package = inferPackageName(j.dir(f.split))
+		}
+
+		if f.chaos.or(config.Chaos) {
+			fsm.Options.ChaosHelpers = true
+		}
+		if f.coverage.or(config.Coverage) {
+			fsm.Options.Coverage = true
+		}
+		if f.trace.or(config.Trace) {
+			fsm.Options.Trace = true
+		}
+		if f.publisher.or(config.Publisher) {
+			fsm.Options.Publisher = true
+		}
+		if f.callbacks.or(config.Callbacks) {
+			fsm.Options.Callbacks = true
+		}
+		if f.userRegions.or(config.UserRegions) {
+			fsm.Options.UserRegions = true
+		}
+		if f.immutable.or(config.ImmutableContext) {
+			fsm.Options.ImmutableContext = true
+		}
+		if f.recovery.or(config.RecoverPanics) {
+			fsm.Options.RecoverPanics = true
+		}
+		if f.keys != 0 {
+			fsm.Options.IdempotencyKeys = f.keys
+		}
+		if f.unhandled != "" {
+			fsm.Options.UnhandledEvent = model.UnhandledEventPolicy(f.unhandled)
+		}
+		if f.reentrancy != "" {
+			fsm.Options.Reentrancy = model.ReentrancyPolicy(f.reentrancy)
+		}
+		if f.constants != "" {
+			fsm.Options.Naming.Constants = model.ConstantStyle(f.constants)
+		}
+		if f.machineName.set {
+			fsm.Options.Naming.OmitMachineName = !f.machineName.value
+		}
+		if f.acronyms != "" {
+			fsm.Options.Naming.Acronyms = strings.Split(f.acronyms, ",")
+		}
+		if f.initialisms.set {
+			fsm.Options.Naming.Initialisms = f.initialisms.value
+		}
+		switch {
+		case f.copyright != "":
+			fsm.Header.Copyright = f.copyright
+		case fsm.Header.Copyright == "":
+			fsm.Header.Copyright = config.Copyright
+		}
+		switch {
+		case f.buildTags != "":
+			fsm.Header.BuildTags = f.buildTags
+		case fsm.Header.BuildTags == "":
+			fsm.Header.BuildTags = config.BuildTags
+		}
+		if f.stamp.or(config.Stamp) {
+			fsm.Header.Stamp = true
+		}
+		if err := fsm.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+		logger.parsed(spec, fsm)
+		logger.Debug("resolved output", "spec", spec, "out", out, "package", fsm.Package,
+			"templates", j.gen.templates, "emit", targets.String(), "backend", backend)
+
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// specPaths returns the spec files named by -spec and the arguments, with every
+// dir/... argument expanded to the matching specs below dir
+func (f *generateFlags) specPaths(extraSpecs []string) ([]string, error) {
+	args := append(append([]string{}, f.specs...), extraSpecs...)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("must specify -spec")
+	}
+	pattern := f.pattern
+	if pattern == "" {
+		pattern = defaultSpecPattern
+	}
+	return expandSpecs(args, pattern)
+}
+
+// render generates the planned output for every job
+func (f *generateFlags) render(jobs []job) ([]generator.PlannedFile, error) {
+	gens := generators{}
+	files := make([]generator.PlannedFile, 0, len(jobs))
+	for _, j := range jobs {
+		gen, err := gens.get(j.gen)
+		if err != nil {
+			return nil, err
+		}
+		dir := j.dir(f.split)
+		targets := j.targets
+
+		if j.backend != generator.GoBackendName {
+			start := len(files)
+			backend, err := gen.Backend(j.backend)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			outputs, err := backend.Generate(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			for _, output := range outputs {
+				files = append(files, generator.PlannedFile{Path: filepath.Join(dir, output.Path), Content: output.Content})
+			}
+			if files, err = f.runPlugins(j, dir, files); err != nil {
+				return nil, err
+			}
+			if err := j.post.process(files[start:]); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		outputs, err := generator.NewGoBackend(gen, f.split).Generate(j.fsm)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", j.spec, err)
+		}
+		var machine []generator.PlannedFile
+		testPath := generator.TestOutputName(j.out)
+		if f.split {
+			for _, output := range outputs {
+				machine = append(machine, generator.PlannedFile{Path: filepath.Join(dir, output.Path), Content: output.Content})
+			}
+			testPath = filepath.Join(dir, generator.SplitTestOutputName(j.fsm))
+		} else {
+			// the single file is written to the resolved output path, which honors
+			// -out and output_suffix
+			machine = append(machine, generator.PlannedFile{Path: j.out, Content: outputs[0].Content})
+		}
+		if err := preserveUserRegions(machine); err != nil {
+			return nil, fmt.Errorf("%s: %w", j.spec, err)
+		}
+		if err := j.post.process(machine); err != nil {
+			return nil, err
+		}
+
+		if j.fsm.Options.StableValues {
+			if err := checkStableValues(j.fsm, machine); err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+		}
+
+		if targets[emitMachine] {
+			files = append(files, machine...)
+		} else {
+			var dependents []string
+			for _, target := range []string{emitTests, emitTestkit, emitGRPC, emitHTTP, emitTemporal, emitORM, emitStubs} {
+				if targets[target] {
+					dependents = append(dependents, target)
+				}
+			}
+			if len(dependents) > 0 {
+				if err := checkMachineCurrent(j.spec, machine, dependents); err != nil {
+					return nil, err
+				}
+			}
+		}
+		start := len(files)
+
+		if targets[emitTests] {
+			tests, err := gen.GenerateTests(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files, generator.PlannedFile{Path: testPath, Content: tests})
+		}
+
+		if targets[emitTestkit] {
+			kit, err := gen.GenerateTestkit(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files, generator.PlannedFile{Path: filepath.Join(dir, generator.TestkitOutputName(j.fsm)), Content: kit})
+		}
+
+		if targets[emitDiagram] {
+			diagram, err := gen.GenerateDiagram(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files, generator.PlannedFile{Path: filepath.Join(dir, generator.DiagramOutputName(j.fsm)), Content: diagram})
+		}
+
+		if targets[emitGRPC] {
+			goPackage, err := goPackageOption(dir, j.fsm.Package)
+			if err != nil {
+				return nil, err
+			}
+			service, err := gen.GenerateGRPCService(j.fsm, generator.GRPCOptions{GoPackage: goPackage})
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			server, err := gen.GenerateGRPCServer(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files,
+				generator.PlannedFile{Path: filepath.Join(dir, generator.GRPCServiceOutputName(j.fsm)), Content: service},
+				generator.PlannedFile{Path: filepath.Join(dir, generator.GRPCServerOutputName(j.fsm)), Content: server})
+		}
+
+		if targets[emitHTTP] {
+			handler, err := gen.GenerateHTTPHandler(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files, generator.PlannedFile{Path: filepath.Join(dir, generator.HTTPHandlerOutputName(j.fsm)), Content: handler})
+		}
+
+		if targets[emitTemporal] {
+			adapter, err := gen.GenerateTemporal(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files, generator.PlannedFile{Path: filepath.Join(dir, generator.TemporalOutputName(j.fsm)), Content: adapter})
+		}
+
+		if targets[emitORM] {
+			binding, err := gen.GenerateBinding(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files, generator.PlannedFile{Path: filepath.Join(dir, generator.BindingOutputName(j.fsm)), Content: binding})
+		}
+
+		if targets[emitGRPC] || targets[emitHTTP] {
+			store, err := gen.GenerateStore(j.fsm)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files, generator.PlannedFile{Path: filepath.Join(dir, generator.StoreOutputName(j.fsm)), Content: store})
+		}
+
+		if files, err = f.runPlugins(j, dir, files); err != nil {
+			return nil, err
+		}
+		if err := j.post.process(files[start:]); err != nil {
+			return nil, err
+		}
+
+		// The stubs are the code of the user, which post_process commands leave alone
+		if targets[emitStubs] {
+			stubs, err := renderStubs(gen, j.fsm, filepath.Join(dir, generator.StubsOutputName(j.fsm)))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", j.spec, err)
+			}
+			files = append(files, stubs)
+		}
+	}
+
+	versions, err := f.renderVersions(jobs, gens)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, versions...)
+
+	if f.registry != "" {
+		registry, err := f.renderRegistry(jobs, gens)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, registry)
+	}
+	return files, nil
+}
+
+// runPlugins runs the -plugin programs for the job and returns files with the
+// files they contribute to dir added. A plugin cannot replace a file that is
+// already generated.
+func (f *generateFlags) runPlugins(j job, dir string, files []generator.PlannedFile) ([]generator.PlannedFile, error) {
+	if len(f.plugins) == 0 {
+		return files, nil
+	}
+	planned := make(map[string]bool, len(files))
+	for _, file := range files {
+		planned[filepath.Clean(file.Path)] = true
+	}
+
+	for _, path := range f.plugins {
+		req := &plugin.Request{GeneratorVersion: generator.Version, Spec: j.spec, OutputDir: dir, Model: j.fsm}
+		contributed, err := plugin.Exec(path, req, logger.stderr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", j.spec, err)
+		}
+		logger.Debug("ran plugin", "plugin", path, "spec", j.spec, "files", len(contributed))
+		for _, c := range contributed {
+			file := generator.PlannedFile{Path: filepath.Join(dir, filepath.FromSlash(c.Path)), Content: []byte(c.Content)}
+			if planned[file.Path] {
+				return nil, fmt.Errorf("%s: plugin %s: %s is already generated", j.spec, path, file.Path)
+			}
+			planned[file.Path] = true
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// stream writes the machine code of every job to stdout as it is rendered, so
+// that the code of a huge machine is never held in memory whole. -out=- allows
+// only the machine code of the go backend, which has no file to keep user
+// regions or enum values from.
+func (f *generateFlags) stream(jobs []job, stdout io.Writer) error {
+	gens := generators{}
+	for _, j := range jobs {
+		gen, err := gens.get(j.gen)
+		if err != nil {
+			return err
+		}
+		if err := gen.GenerateStream(j.fsm, stdout); err != nil {
+			return fmt.Errorf("%s: %w", j.spec, err)
+		}
+	}
+	return nil
+}
+
+// renderVersions generates the file shared by the versions of each machine
+// generated side by side into a directory
+func (f *generateFlags) renderVersions(jobs []job, gens generators) ([]generator.PlannedFile, error) {
+	type key struct{ dir, base string }
+	var keys []key
+	versions := make(map[key][]job)
+	for _, j := range jobs {
+		if j.fsm.Base == "" || j.backend != generator.GoBackendName || !j.targets[emitMachine] || j.out == stdoutPath {
+			continue
+		}
+		k := key{j.dir(f.split), j.fsm.Base}
+		if _, ok := versions[k]; !ok {
+			keys = append(keys, k)
+		}
+		versions[k] = append(versions[k], j)
+	}
+
+	files := make([]generator.PlannedFile, 0, len(keys))
+	for _, k := range keys {
+		models := make([]*model.FSMModel, 0, len(versions[k]))
+		for _, j := range versions[k] {
+			models = append(models, j.fsm)
+		}
+		last := versions[k][len(versions[k])-1]
+		gen, err := gens.get(last.gen)
+		if err != nil {
+			return nil, err
+		}
+		content, err := gen.GenerateVersions(models)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", last.spec, err)
+		}
+		logger.Debug("generated versions", "machine", k.base, "dir", k.dir, "versions", len(models))
+		file := []generator.PlannedFile{{Path: filepath.Join(k.dir, generator.VersionsOutputName(k.base)), Content: content}}
+		if err := last.post.process(file); err != nil {
+			return nil, err
+		}
+		files = append(files, file...)
+	}
+	return files, nil
+}
+
+// renderRegistry generates the registry package of -registry, which imports the
+// package of every job's machine
+func (f *generateFlags) renderRegistry(jobs []job, gens generators) (generator.PlannedFile, error) {
+	registryDir, err := filepath.Abs(f.registry)
+	if err != nil {
+		return generator.PlannedFile{}, err
+	}
+
+	machines := make([]generator.RegistryMachine, 0, len(jobs))
+	for _, j := range jobs {
+		if j.backend != generator.GoBackendName {
+			return generator.PlannedFile{}, fmt.Errorf("%s: -registry needs the go backend, not %s", j.spec, j.backend)
+		}
+		dir := j.dir(f.split)
+		if abs, err := filepath.Abs(dir); err == nil && abs == registryDir {
+			return generator.PlannedFile{}, fmt.Errorf("%s: -registry needs a directory of its own, not that of the machine", j.spec)
+		}
+		importPath, err := packageImportPath(dir)
+		if err != nil {
+			return generator.PlannedFile{}, err
+		}
+		if importPath == "" {
+			return generator.PlannedFile{}, fmt.Errorf("%s: -registry needs the machine to be generated inside a Go module", j.spec)
+		}
+		machines = append(machines, generator.RegistryMachine{ImportPath: importPath, FSM: j.fsm})
+	}
+
+	gen, err := gens.get(f.generatorKey(config{}))
+	if err != nil {
+		return generator.PlannedFile{}, err
+	}
+	content, err := gen.GenerateRegistry(inferPackageName(f.registry), machines)
+	if err != nil {
+		return generator.PlannedFile{}, err
+	}
+	logger.Debug("generated registry", "dir", f.registry, "machines", len(machines))
+	return generator.PlannedFile{Path: filepath.Join(f.registry, generator.RegistryOutputName), Content: content}, nil
+}
+
+// checkStableValues compares the enum constants of the machine files with the
+// previously generated files at the same paths
+func checkStableValues(fsm *model.FSMModel, machine []generator.PlannedFile) error {
+	for _, f := range machine {
+		existing, err := os.ReadFile(f.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := generator.CheckValueStability(fsm, existing, f.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staleFiles returns generated files in the output directories that are not produced by files
+func staleFiles(files []generator.PlannedFile) ([]string, error) {
+	produced := make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		produced[filepath.Clean(f.Path)] = true
+		dirs[filepath.Dir(f.Path)] = true
+	}
+
+	var stale []string
+	for dir := range dirs {
+		matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range matches {
+			if produced[filepath.Clean(path)] {
+				continue
+			}
+			src, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if generator.IsGenerated(src) {
+				stale = append(stale, path)
+			}
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
+
+// preserveUserRegions puts the code of the user regions of the files on disk
+// back into the regenerated files
+func preserveUserRegions(files []generator.PlannedFile) error {
+	for i, f := range files {
+		existing, err := os.ReadFile(f.Path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", f.Path, err)
+		}
+		content, err := generator.PreserveUserRegions(f.Content, existing)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Path, err)
+		}
+		files[i].Content = content
+	}
+	return nil
+}
+
+// renderStubs plans the callback stubs file at path, which keeps the content of
+// an existing file and only gains stubs for the callbacks it lacks. Stubs left
+// behind by callbacks removed or renamed in the spec are reported as warnings.
+func renderStubs(gen *generator.CodeGenerator, fsm *model.FSMModel, path string) (generator.PlannedFile, error) {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return generator.PlannedFile{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	content, err := gen.GenerateStubs(fsm, existing)
+	if err != nil {
+		return generator.PlannedFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if existing != nil {
+		orphans, err := gen.OrphanedStubs(fsm, existing)
+		if err != nil {
+			return generator.PlannedFile{}, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, orphan := range orphans {
+			logger.Warn("callback stub matches no callback of the spec", "file", fmt.Sprintf("%s:%d", path, orphan.Line), "method", orphan.Name)
+		}
+	}
+	return generator.PlannedFile{Path: path, Content: content}, nil
+}
+
+// writeFile writes f unless the file already holds identical content, leaving its
+// modification time alone so file watchers and build caches are not triggered.
+// With force the file is always written. It reports whether the file was written.
+func writeFile(f generator.PlannedFile, force bool) (bool, error) {
+	if !force {
+		existing, err := os.ReadFile(f.Path)
+		if err == nil && bytes.Equal(existing, f.Content) {
+			return false, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", filepath.Dir(f.Path), err)
+	}
+	if err := os.WriteFile(f.Path, f.Content, 0o644); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", f.Path, err)
+	}
+	return true, nil
+}
+
+// goPackageOption returns the go_package option that makes protoc generate into
+// dir, as "<import path>;<pkg>". It is empty when dir is not inside a module.
+func goPackageOption(dir, pkg string) (string, error) {
+	importPath, err := packageImportPath(dir)
+	if importPath == "" || err != nil {
+		return "", err
+	}
+	return importPath + ";" + pkg, nil
+}
+
+// packageImportPath returns the import path of the package in dir, derived from
+// the nearest go.mod. It is empty when dir is not inside a module.
+func packageImportPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for root := abs; ; root = filepath.Dir(root) {
+		src, err := os.ReadFile(filepath.Join(root, "go.mod"))
+		switch {
+		case err == nil:
+			module := modulePath(src)
+			if module == "" {
+				return "", nil
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(module, filepath.ToSlash(rel)), nil
+		case !os.IsNotExist(err):
+			return "", err
+		case filepath.Dir(root) == root:
+			return "", nil
+		}
+	}
+}
+
+// modulePath returns the module path declared by a go.mod file
+func modulePath(gomod []byte) string {
+	for _, line := range strings.Split(string(gomod), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
+
+// inferPackageName derives a package name from the output directory
+func inferPackageName(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "main"
+	}
+	name := strings.ReplaceAll(filepath.Base(abs), "-", "_")
+	if !token.IsIdentifier(name) {
+		return "main"
+	}
+	return name
+}
+
+// generateCommand implements the default generate command
+func generateCommand(fs *flag.FlagSet) commandFunc {
+	var flags generateFlags
+	flags.register(fs)
+	var opts writeOptions
+	fs.BoolVar(&opts.force, "force", false, "rewrite output files even when their content is unchanged")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "report what would be written, with a unified diff of each update, without writing")
+	return func(args []string, stdout, stderr io.Writer) int {
+		args, err := flags.anchored(fs, args)
+		if err == nil {
+			err = flags.generate(args, opts, stdout)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// writeOptions control how generate writes its output
+type writeOptions struct {
+	// force rewrites files whose content is unchanged
+	force bool
+
+	// dryRun reports the changes instead of writing them
+	dryRun bool
+}
+
+// generate renders every spec and writes the output, reporting each written,
+// unchanged, and pruned file to stdout. With -out=- the machine code is written
+// to stdout instead. A run whose inputs and output files are unchanged since a
+// run recorded in the build cache reports its files unchanged without rendering.
+func (f *generateFlags) generate(extraSpecs []string, opts writeOptions, stdout io.Writer) error {
+	start := time.Now()
+	cache := f.openCache(extraSpecs, opts)
+	if paths, ok := cache.lookup(); ok {
+		logger.Debug("build cache hit", "files", len(paths))
+		for _, path := range paths {
+			logger.file(stdout, "unchanged", path)
+		}
+		logger.phase("cache", start)
+		return nil
+	}
+
+	jobs, err := f.loadJobs(extraSpecs)
+	if err != nil {
+		return err
+	}
+	logger.phase("parse", start)
+
+	if f.out == stdoutPath {
+		defer logger.phase("render", time.Now())
+		return f.stream(jobs, stdout)
+	}
+
+	start = time.Now()
+	files, err := f.render(jobs)
+	if err != nil {
+		return err
+	}
+	logger.phase("render", start)
+	defer logger.phase("write", time.Now())
+
+	for _, file := range files {
+		if opts.dryRun {
+			if err := reportWrite(file, stdout); err != nil {
+				return err
+			}
+			continue
+		}
+
+		written, err := writeFile(file, opts.force)
+		if err != nil {
+			return err
+		}
+		if written {
+			logger.file(stdout, "wrote", file.Path)
+		} else {
+			logger.file(stdout, "unchanged", file.Path)
+		}
+	}
+
+	if f.prune {
+		stale, err := staleFiles(files)
+		if err != nil {
+			return err
+		}
+		for _, path := range stale {
+			if opts.dryRun {
+				fmt.Fprintf(stdout, "would remove %s\n", path)
+				continue
+			}
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+			logger.file(stdout, "removed", path)
+		}
+	}
+	if !opts.dryRun {
+		cache.store(files)
+	}
+	return nil
+}
+
+// reportWrite reports what writing f would do, followed by a unified diff when
+// it would update an existing file
+func reportWrite(f generator.PlannedFile, stdout io.Writer) error {
+	existing, err := os.ReadFile(f.Path)
+	switch {
+	case os.IsNotExist(err):
+		fmt.Fprintf(stdout, "would create %s\n", f.Path)
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to read %s: %w", f.Path, err)
+	case bytes.Equal(existing, f.Content):
+		fmt.Fprintf(stdout, "unchanged %s\n", f.Path)
+		return nil
+	}
+
+	diff, err := generator.UnifiedDiff(filepath.ToSlash(f.Path), existing, f.Content)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "would update %s\n%s", f.Path, diff)
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// logFlags are the verbosity and log format flags every command accepts
+type logFlags struct {
+	verbose bool
+	debug   bool
+	quiet   bool
+	format  string
+}
+
+// register binds the logging flags to fs
+func (l *logFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&l.verbose, "v", false, "log what was parsed and where output was written")
+	fs.BoolVar(&l.debug, "vv", false, "like -v, plus resolved configuration and the time taken by each phase")
+	fs.BoolVar(&l.quiet, "quiet", false, "report only errors; do not list the files written")
+	fs.StringVar(&l.format, "log-format", "text", "format of logs and file reports: text or json")
+}
+
+// logging is the log configuration of the running command. Logs go to stderr;
+// the files a command writes are reported on stdout, as JSON lines with
+// -log-format=json.
+type logging struct {
+	*slog.Logger
+
+	quiet bool
+	json  bool
+
+	// stderr receives diagnostics that are not log records, such as the output
+	// of -debug-templates
+	stderr io.Writer
+}
+
+// logger is configured from the logging flags of each command run
+var logger = logging{Logger: slog.New(slog.DiscardHandler), stderr: io.Discard}
+
+// logging returns the log configuration selected by the flags
+func (l *logFlags) logging(stderr io.Writer) (logging, error) {
+	level := slog.LevelWarn
+	switch {
+	case l.quiet && (l.verbose || l.debug):
+		return logging{}, fmt.Errorf("-quiet cannot be combined with -v or -vv")
+	case l.quiet:
+		level = slog.LevelError
+	case l.debug:
+		level = slog.LevelDebug
+	case l.verbose:
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	switch l.format {
+	case "text":
+		// Timestamps only add noise to the logs of a short-lived command
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		}
+		return logging{Logger: slog.New(slog.NewTextHandler(stderr, opts)), quiet: l.quiet, stderr: stderr}, nil
+	case "json":
+		return logging{Logger: slog.New(slog.NewJSONHandler(stderr, opts)), quiet: l.quiet, json: true, stderr: stderr}, nil
+	default:
+		return logging{}, fmt.Errorf("unknown -log-format %q (use text or json)", l.format)
+	}
+}
+
+// file reports what a command did to a file, such as "wrote" or "unchanged"
+func (l logging) file(w io.Writer, action, path string) {
+	if l.quiet {
+		return
+	}
+	if l.json {
+		line, _ := json.Marshal(struct {
+			Action string `json:"action"`
+			Path   string `json:"path"`
+		}{action, path})
+		fmt.Fprintf(w, "%s\n", line)
+		return
+	}
+	fmt.Fprintf(w, "%s %s\n", action, path)
+}
+
+// phase logs the time taken by a phase of the command at debug level
+func (l logging) phase(name string, start time.Time) {
+	l.Debug("phase done", "phase", name, "duration", time.Since(start))
+}
+
+// parsed logs the size of a parsed spec
+func (l logging) parsed(spec string, m *model.FSMModel) {
+	l.Info("parsed spec", "spec", spec, "machine", m.Name,
+		"states", len(m.States), "events", len(m.Events), "transitions", len(m.Transitions))
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/yourusername/gofsm-gen/pkg/lint"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+// lspCommand implements "gofsm-gen lsp": a language server for spec files speaking
+// the language server protocol over stdin and stdout. It publishes the findings
+// of validate as diagnostics, jumps from references to the states and events
+// they name, describes the transitions of a state or event on hover, and
+// completes state and event names.
+func lspCommand(fs *flag.FlagSet) commandFunc {
+	return func(args []string, stdout, stderr io.Writer) int {
+		if len(args) > 0 {
+			fmt.Fprintf(stderr, "gofsm-gen lsp: unexpected argument %q\n", args[0])
+			return 2
+		}
+
+		s := &lspServer{out: stdout, docs: make(map[string]*lspDocument), configs: configs{}}
+		code, err := s.serve(bufio.NewReader(stdin))
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen lsp: %v\n", err)
+			return 1
+		}
+		return code
+	}
+}
+
+// Error codes of the language server protocol
+const (
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+)
+
+// Kinds of completion items and severities of diagnostics
+const (
+	lspKindEnumMember = 20
+	lspKindEvent      = 23
+
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+)
+
+// lspMessage is a JSON-RPC request or notification
+type lspMessage struct {
+	ID     *json.RawMessage `json:"id"`
+	Method string           `json:"method"`
+	Params json.RawMessage  `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail"`
+}
+
+// lspPositionParams are the parameters of requests about a position in a document
+type lspPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position lspPosition `json:"position"`
+}
+
+// lspDocument is an open spec. Its symbols and model are those of the last
+// version that parsed, so that hover and completion keep working while an edit
+// leaves the spec briefly malformed.
+type lspDocument struct {
+	text    string
+	symbols []parser.Symbol
+	fsm     *model.FSMModel
+}
+
+// lspServer holds the open documents of a language server session
+type lspServer struct {
+	out      io.Writer
+	docs     map[string]*lspDocument
+	configs  configs
+	shutdown bool
+}
+
+// serve handles messages from in until the client exits, and returns the exit
+// code the protocol asks for: 0 after a shutdown request and 1 otherwise
+func (s *lspServer) serve(in *bufio.Reader) (int, error) {
+	for {
+		msg, err := readLSPMessage(in)
+		if errors.Is(err, io.EOF) {
+			return 1, nil
+		}
+		if err != nil {
+			return 1, err
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return 0, nil
+			}
+			return 1, nil
+		}
+
+		result, rpcErr := s.handle(msg)
+		if msg.ID == nil {
+			continue
+		}
+		response := map[string]any{"id": msg.ID, "result": result}
+		if rpcErr != nil {
+			response = map[string]any{"id": msg.ID, "error": rpcErr}
+		}
+		if err := s.write(response); err != nil {
+			return 1, err
+		}
+	}
+}
+
+// handle handles a request or notification and returns the result of a request
+func (s *lspServer) handle(msg *lspMessage) (any, *lspError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1,
+				"hoverProvider":      true,
+				"definitionProvider": true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{" ", "[", ","}},
+			},
+			"serverInfo": map[string]string{"name": "gofsm-gen"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument struct {
+				URI string `json:"uri"`
+			} `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		// Documents are synced in full, so the last change is the whole text
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params lspPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.publish(params.TextDocument.URI, []lspDiagnostic{})
+		return nil, nil
+	case "textDocument/definition", "textDocument/hover", "textDocument/completion":
+		var params lspPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		doc := s.docs[params.TextDocument.URI]
+		if doc == nil {
+			return nil, nil
+		}
+		switch msg.Method {
+		case "textDocument/definition":
+			return doc.definition(params.TextDocument.URI, params.Position), nil
+		case "textDocument/hover":
+			return doc.hover(params.Position), nil
+		default:
+			return doc.completion(params.Position), nil
+		}
+	}
+
+	if msg.ID != nil {
+		return nil, &lspError{Code: lspMethodNotFound, Message: fmt.Sprintf("method %q is not supported", msg.Method)}
+	}
+	return nil, nil
+}
+
+// update records the text of a document and publishes its diagnostics
+func (s *lspServer) update(uri, text string) {
+	doc := s.docs[uri]
+	if doc == nil {
+		doc = &lspDocument{}
+		s.docs[uri] = doc
+	}
+	doc.text = text
+	if symbols, err := parser.Symbols([]byte(text)); err == nil {
+		doc.symbols = symbols
+	}
+	if fsm, err := parser.NewYAMLParser().Parse(strings.NewReader(text)); err == nil {
+		doc.fsm = fsm
+	}
+	s.publish(uri, s.diagnose(uri, doc))
+}
+
+// diagnose checks a document as validate would, with the lint configuration of
+// its directory
+func (s *lspServer) diagnose(uri string, doc *lspDocument) []lspDiagnostic {
+	dir := "."
+	if u, err := url.Parse(uri); err == nil && u.Scheme == "file" {
+		dir = filepath.Dir(filepath.FromSlash(u.Path))
+	}
+
+	config, err := s.configs.get(dir)
+	var findings []lint.Finding
+	if err == nil {
+		findings, _, err = checkSpec([]byte(doc.text), config.Lint)
+	}
+	if err != nil {
+		findings = []lint.Finding{invalidSpec(err)}
+	}
+
+	diagnostics := []lspDiagnostic{}
+	for _, f := range findings {
+		severity := lspSeverityWarning
+		if f.Severity == lint.SeverityError {
+			severity = lspSeverityError
+		}
+		diagnostics = append(diagnostics, lspDiagnostic{
+			Range:    doc.findingRange(f),
+			Severity: severity,
+			Code:     f.Rule,
+			Source:   "gofsm-gen",
+			Message:  f.Message,
+		})
+	}
+	return diagnostics
+}
+
+// linePattern finds the line of parse errors that report one
+var linePattern = regexp.MustCompile(`\bline (\d+)\b`)
+
+// findingRange returns where a finding is shown: the declaration of its state or
+// event, the line its message reports, or else the first line
+func (d *lspDocument) findingRange(f lint.Finding) lspRange {
+	for _, want := range []struct {
+		kind parser.SymbolKind
+		name string
+	}{{parser.SymbolState, f.State}, {parser.SymbolEvent, f.Event}} {
+		if want.name == "" {
+			continue
+		}
+		if decl, ok := d.declaration(want.kind, want.name); ok {
+			return d.symbolRange(decl)
+		}
+	}
+
+	line := 0
+	if m := linePattern.FindStringSubmatch(f.Message); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > 0 {
+			line = n - 1
+		}
+	}
+	return lspRange{
+		Start: lspPosition{Line: line},
+		End:   lspPosition{Line: line, Character: utf16Len(d.line(line))},
+	}
+}
+
+// definition returns the declaration of the state or event at pos
+func (d *lspDocument) definition(uri string, pos lspPosition) any {
+	for _, sym := range d.symbolsAt(pos) {
+		if decl, ok := d.declaration(sym.Kind, sym.Name); ok {
+			return lspLocation{URI: uri, Range: d.symbolRange(decl)}
+		}
+	}
+	return nil
+}
+
+// hover describes the state or event at pos with the transitions it takes part in
+func (d *lspDocument) hover(pos lspPosition) any {
+	if d.fsm == nil {
+		return nil
+	}
+
+	for _, sym := range d.symbolsAt(pos) {
+		var b strings.Builder
+		switch {
+		case sym.Kind == parser.SymbolState && d.fsm.GetState(sym.Name) != nil:
+			state := d.fsm.GetState(sym.Name)
+			fmt.Fprintf(&b, "**state** `%s`", state.Name)
+			if state.Name == d.fsm.Initial {
+				b.WriteString(" (initial)")
+			}
+			if state.Final {
+				b.WriteString(" (final)")
+			}
+			writeHoverDeprecation(&b, state.Deprecated, state.DeprecationReason)
+			writeHoverDescription(&b, state.Description)
+			writeHoverTransitions(&b, "Transitions from it", d.fsm.GetTransitionsFrom(state.Name))
+			writeHoverTransitions(&b, "Transitions to it", d.fsm.GetTransitionsTo(state.Name))
+		case sym.Kind == parser.SymbolEvent && d.fsm.GetEvent(sym.Name) != nil:
+			event := d.fsm.GetEvent(sym.Name)
+			fmt.Fprintf(&b, "**event** `%s`", event.Name)
+			writeHoverDeprecation(&b, event.Deprecated, event.DeprecationReason)
+			writeHoverDescription(&b, event.Description)
+			var triggered []*model.Transition
+			for _, t := range d.fsm.Transitions {
+				if t.Event == event.Name {
+					triggered = append(triggered, t)
+				}
+			}
+			writeHoverTransitions(&b, "Transitions it triggers", triggered)
+		default:
+			continue
+		}
+
+		r := d.symbolRange(sym)
+		return map[string]any{
+			"contents": map[string]string{"kind": "markdown", "value": b.String()},
+			"range":    r,
+		}
+	}
+	return nil
+}
+
+func writeHoverDescription(b *strings.Builder, description string) {
+	if description != "" {
+		fmt.Fprintf(b, "\n\n%s", description)
+	}
+}
+
+func writeHoverDeprecation(b *strings.Builder, deprecated bool, reason string) {
+	if !deprecated {
+		return
+	}
+	b.WriteString(" (deprecated")
+	if reason != "" {
+		b.WriteString(": " + reason)
+	}
+	b.WriteString(")")
+}
+
+func writeHoverTransitions(b *strings.Builder, title string, transitions []*model.Transition) {
+	if len(transitions) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "\n\n%s:\n", title)
+	for _, t := range transitions {
+		fmt.Fprintf(b, "\n- `%s` → `%s` on `%s`", t.From, t.To, t.Event)
+		if t.Guard != "" {
+			fmt.Fprintf(b, " if `%s`", t.Guard)
+		}
+		if t.Action != "" {
+			fmt.Fprintf(b, " do `%s`", t.Action)
+		}
+	}
+}
+
+// completionKeyPattern finds the key whose value is being typed at the end of a
+// line, including inside a flow sequence such as "ignore: [flag, "
+var completionKeyPattern = regexp.MustCompile(`([A-Za-z_]+)\s*:\s*(?:\[(?:[^\]]*,)?\s*)?["']?$`)
+
+// completionKinds are the kinds of names each key takes
+var completionKinds = map[string][]parser.SymbolKind{
+	"initial":          {parser.SymbolState},
+	"from":             {parser.SymbolState},
+	"to":               {parser.SymbolState},
+	"state":            {parser.SymbolState},
+	"then":             {parser.SymbolState},
+	"unknown_state":    {parser.SymbolState},
+	"quarantine_state": {parser.SymbolState},
+	"on":               {parser.SymbolEvent},
+	"event":            {parser.SymbolEvent},
+	"ignore":           {parser.SymbolEvent},
+	"ignores":          {parser.SymbolEvent},
+	"eventually":       {parser.SymbolState, parser.SymbolEvent},
+	"never":            {parser.SymbolState, parser.SymbolEvent},
+}
+
+// completion returns the state or event names the key before pos takes
+func (d *lspDocument) completion(pos lspPosition) any {
+	line := d.line(pos.Line)
+	prefix := line[:runeOffset(line, pos.Character)]
+	prefix = strings.TrimRightFunc(prefix, isNameRune)
+	m := completionKeyPattern.FindStringSubmatch(prefix)
+	if m == nil {
+		return []lspCompletionItem{}
+	}
+
+	items := []lspCompletionItem{}
+	for _, kind := range completionKinds[m[1]] {
+		itemKind := lspKindEnumMember
+		if kind == parser.SymbolEvent {
+			itemKind = lspKindEvent
+		}
+		var names []string
+		for _, sym := range d.symbols {
+			if sym.Kind == kind && sym.Declaration {
+				names = append(names, sym.Name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			items = append(items, lspCompletionItem{Label: name, Kind: itemKind, Detail: string(kind)})
+		}
+	}
+	return items
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// symbolsAt returns the symbols at pos, those naming a declared state or event first
+func (d *lspDocument) symbolsAt(pos lspPosition) []parser.Symbol {
+	line := d.line(pos.Line)
+	column := utf8.RuneCountInString(line[:runeOffset(line, pos.Character)]) + 1
+
+	var found []parser.Symbol
+	for _, sym := range d.symbols {
+		if sym.Line == pos.Line+1 && sym.Column <= column && column <= sym.End() {
+			found = append(found, sym)
+		}
+	}
+	declared := func(sym parser.Symbol) bool {
+		_, ok := d.declaration(sym.Kind, sym.Name)
+		return ok
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return declared(found[i]) && !declared(found[j])
+	})
+	return found
+}
+
+// declaration returns the symbol declaring the state or event name
+func (d *lspDocument) declaration(kind parser.SymbolKind, name string) (parser.Symbol, bool) {
+	for _, sym := range d.symbols {
+		if sym.Kind == kind && sym.Name == name && sym.Declaration {
+			return sym, true
+		}
+	}
+	return parser.Symbol{}, false
+}
+
+// symbolRange returns the range of a symbol in UTF-16 code units, as the
+// protocol counts characters
+func (d *lspDocument) symbolRange(sym parser.Symbol) lspRange {
+	line := d.line(sym.Line - 1)
+	start := utf16Len(runePrefix(line, sym.Column-1))
+	return lspRange{
+		Start: lspPosition{Line: sym.Line - 1, Character: start},
+		End:   lspPosition{Line: sym.Line - 1, Character: start + utf16Len(sym.Name)},
+	}
+}
+
+// line returns the 0-based line n of the document without its line ending
+func (d *lspDocument) line(n int) string {
+	lines := strings.Split(d.text, "\n")
+	if n < 0 || n >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[n], "\r")
+}
+
+// runePrefix returns the first n characters of line
+func runePrefix(line string, n int) string {
+	for i := range line {
+		if n == 0 {
+			return line[:i]
+		}
+		n--
+	}
+	return line
+}
+
+// runeOffset returns the byte offset of the UTF-16 code unit units of line
+func runeOffset(line string, units int) int {
+	for i, r := range line {
+		if units <= 0 {
+			return i
+		}
+		units -= len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
+
+// utf16Len returns the length of s in UTF-16 code units
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// readLSPMessage reads a message framed by a Content-Length header
+func readLSPMessage(in *bufio.Reader) (*lspMessage, error) {
+	header, err := textproto.NewReader(in).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read message header: %w", err)
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(in, body); err != nil {
+		return nil, fmt.Errorf("failed to read message body: %w", err)
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+	return &msg, nil
+}
+
+// publish sends the diagnostics of a document
+func (s *lspServer) publish(uri string, diagnostics []lspDiagnostic) {
+	_ = s.write(map[string]any{
+		"method": "textDocument/publishDiagnostics",
+		"params": map[string]any{"uri": uri, "diagnostics": diagnostics},
+	})
+}
+
+// write sends a message framed by a Content-Length header
+func (s *lspServer) write(msg map[string]any) error {
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
@@ -0,0 +1,161 @@
+// Command gofsm-gen generates type-safe state machine code from YAML definitions.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// commandFunc runs a command with the arguments left after its flags and
+// returns the process exit code
+type commandFunc func(args []string, stdout, stderr io.Writer) int
+
+// commandSetup registers the flags of a command on fs and returns the function
+// that runs it once they are parsed
+type commandSetup func(fs *flag.FlagSet) commandFunc
+
+// run parses args into a flag set named name and runs the command
+func (setup commandSetup) run(name string, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var logFlags logFlags
+	logFlags.register(fs)
+	body := setup(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	l, err := logFlags.logging(stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", name, err)
+		return 2
+	}
+	logger = l
+	return body(fs.Args(), stdout, stderr)
+}
+
+// command is a gofsm-gen subcommand. The usage, shell completions, and man
+// pages are all derived from the command table.
+type command struct {
+	// name selects the command on the command line
+	name string
+
+	// synopsis describes the arguments that follow the name
+	synopsis string
+
+	// summary is the one-line description shown in the usage
+	summary string
+
+	// setup registers the flags of the command
+	setup commandSetup
+
+	// operands are the values the first argument takes, such as export formats
+	operands []string
+
+	// dispatch replaces the parsing of setup's flags, for commands whose flags
+	// depend on their first argument
+	dispatch commandFunc
+}
+
+// run runs the command with the arguments after its name
+func (c *command) run(args []string, stdout, stderr io.Writer) int {
+	if c.dispatch != nil {
+		return c.dispatch(args, stdout, stderr)
+	}
+	return c.setup.run("gofsm-gen "+c.name, args, stdout, stderr)
+}
+
+// flagSet returns the flags of the command without running it
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("gofsm-gen "+c.name, flag.ContinueOnError)
+	new(logFlags).register(fs)
+	c.setup(fs)
+	return fs
+}
+
+// commands are the gofsm-gen subcommands in usage order; the first one runs
+// when no command is named
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{name: "generate", synopsis: "[flags] [spec]", summary: "generate code from a spec", setup: generateCommand},
+		{name: "plan", synopsis: "[flags] [spec]", summary: "show what generation would change without writing", setup: planCommand},
+		{name: "verify", synopsis: "[flags] [spec]", summary: "check generated files against their spec checksums", setup: verifyCommand},
+		{name: "validate", synopsis: "[flags] [spec]", summary: "check specs for errors and lint findings", setup: validateCommand},
+		{name: "export", synopsis: "<format> [spec]", summary: "export states and events for other languages and tools",
+			setup: exportCommand(anyExportFormat), operands: exportFormatNames(), dispatch: runExport},
+		{name: "watch", synopsis: "[flags] [spec]", summary: "regenerate whenever a spec or template changes", setup: watchCommand},
+		{name: "directive", synopsis: "[flags] [spec]", summary: "print or add the //go:generate line running gofsm-gen with the flags", setup: directiveCommand},
+		{name: "simulate", synopsis: "[flags] spec", summary: "fire events against a spec interactively", setup: simulateCommand},
+		{name: "path", synopsis: "-to state spec", summary: "print the shortest event sequence to a state", setup: pathCommand},
+		{name: "stats", synopsis: "[flags] spec", summary: "print the size of a spec and its strongly connected components", setup: statsCommand},
+		{name: "diff", synopsis: "[flags] old new", summary: "compare two versions of a spec as text or a colored diagram", setup: diffCommand},
+		{name: "compat", synopsis: "[flags] old new", summary: "check that a new spec version still loads persisted states", setup: compatCommand},
+		{name: "rename-state", synopsis: "old new [flags] spec", summary: "rename a state and every reference to it in a spec", setup: renameStateCommand},
+		{name: "rename-event", synopsis: "old new [flags] spec", summary: "rename an event and every reference to it in a spec", setup: renameEventCommand},
+		{name: "remove-state", synopsis: "state [flags] spec", summary: "remove a state and its transitions from a spec", setup: removeStateCommand},
+		{name: "coverage", synopsis: "-spec spec [flags] profile...", summary: "report which transitions of a spec recorded coverage profiles exercised", setup: coverageCommand},
+		{name: "replay", synopsis: "-spec spec trace...", summary: "check recorded traces against a spec for behavioral regressions", setup: replayCommand},
+		{name: "serve", synopsis: "[-addr addr] spec", summary: "edit a spec in a local web UI with a live diagram", setup: serveCommand},
+		{name: "lsp", summary: "serve the language server protocol for spec files over stdio", setup: lspCommand},
+		{name: "completion", synopsis: "bash|zsh|fish", summary: "print a shell completion script", setup: completionCommand, operands: completionShells()},
+		{name: "man", synopsis: "[-dir dir]", summary: "write man pages for every command", setup: manCommand},
+		{name: "version", summary: "print the version, commit, and build date", setup: versionCommand},
+	}
+}
+
+// lookupCommand returns the command named name, or nil
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// usage lists the commands
+func usage() string {
+	lines := make([]string, len(commands))
+	width := 0
+	for i, c := range commands {
+		lines[i] = strings.TrimSpace("gofsm-gen " + c.name + " " + c.synopsis)
+		if i == 0 {
+			lines[i] = "gofsm-gen [" + c.name + "] " + c.synopsis
+		}
+		width = max(width, len(lines[i]))
+	}
+
+	var b strings.Builder
+	b.WriteString("Usage:\n")
+	for i, c := range commands {
+		fmt.Fprintf(&b, "  %-*s %s\n", width, lines[i], c.summary)
+	}
+	b.WriteString("\nRun \"gofsm-gen <command> -h\" for command flags.\n")
+	return b.String()
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run dispatches to a subcommand and returns the process exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			fmt.Fprint(stdout, usage())
+			return 0
+		case "-version", "--version":
+			return lookupCommand("version").run(args[1:], stdout, stderr)
+		}
+		if c := lookupCommand(args[0]); c != nil {
+			return c.run(args[1:], stdout, stderr)
+		}
+	}
+	return commands[0].run(args, stdout, stderr)
+}
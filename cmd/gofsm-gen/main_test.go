@@ -0,0 +1,2530 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/gofsm-gen/pkg/coverage"
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/lint"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/plugin"
+)
+
+const doorSpec = `
+machine:
+  name: DoorLock
+  initial: locked
+
+states:
+  - name: locked
+  - name: unlocked
+
+events:
+  - lock
+  - unlock
+
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+  - from: unlocked
+    to: locked
+    on: lock
+`
+
+// TestMain turns the build cache off, so that tests neither write to the cache of
+// the user nor skip the generation they check; the cache tests turn it on. With
+// testPluginEnv or testPostProcessEnv set the test binary acts as a -plugin
+// program or a post_process command instead.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(testPostProcessEnv); mode != "" {
+		os.Exit(testPostProcess(mode, os.Args[1:]))
+	}
+	if mode := os.Getenv(testPluginEnv); mode != "" {
+		if err := plugin.Serve(os.Stdin, os.Stdout, testPlugin(mode)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Setenv(cacheEnv, "off")
+	os.Exit(m.Run())
+}
+
+// testPostProcessEnv selects the post_process command the test binary acts as
+const testPostProcessEnv = "GOFSMGEN_TEST_POST_PROCESS"
+
+// testPostProcess runs the post_process command of mode on the file named by the
+// last argument: "license" adds a license header after the arguments before it,
+// and "fail" rejects the file
+func testPostProcess(mode string, args []string) int {
+	path := args[len(args)-1]
+	if mode == "fail" {
+		fmt.Fprintf(os.Stderr, "%s: line too long\n", path)
+		return 2
+	}
+	src, err := os.ReadFile(path)
+	if err == nil {
+		err = os.WriteFile(path, append([]byte("// License: "+strings.Join(args[:len(args)-1], " ")+"\n\n"), src...), 0o600)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// testPluginEnv selects the plugin the test binary acts as
+const testPluginEnv = "GOFSMGEN_TEST_PLUGIN"
+
+// testPlugin returns the plugin of mode: "audit" contributes an audit file
+// listing the states, "fail" reports an error, and "clash" contributes a file
+// that gofsm-gen already generates
+func testPlugin(mode string) plugin.Func {
+	return func(req *plugin.Request) ([]plugin.File, error) {
+		name := generator.OutputName(req.Model, "_audit.go")
+		switch mode {
+		case "fail":
+			return nil, fmt.Errorf("%s has no auditor", req.Model.Name)
+		case "clash":
+			name = generator.DefaultOutputName(req.Model)
+		}
+		return []plugin.File{{
+			Path:    name,
+			Content: fmt.Sprintf("package %s\n\n// Audited states: %s\n", req.Model.Package, strings.Join(req.Model.GetStateNames(), ", ")),
+		}}, nil
+	}
+}
+
+// writeSpec writes a spec file into a fresh package directory and returns its path
+func writeSpec(t *testing.T, content string) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "security")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	path := filepath.Join(dir, "door.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// runCLI runs the CLI with args and returns the exit code and captured output
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr strings.Builder
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_GenerateBesideSpec(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, stdout, stderr := runCLI("-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+	assert.Contains(t, stdout, "wrote "+out)
+
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "package security", "Package should be inferred from the output directory")
+	assert.Contains(t, string(generated), "type DoorLockState int")
+}
+
+func TestRun_GenerateRequiresSpec(t *testing.T) {
+	code, _, stderr := runCLI()
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "must specify -spec")
+}
+
+func TestRun_PlanDoesNotWrite(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, stdout, stderr := runCLI("plan", "-detailed-exitcode", spec)
+	require.Equal(t, 2, code, stderr)
+	assert.Contains(t, stdout, "+ "+out+" (create)")
+	assert.Contains(t, stdout, "+ func NewDoorLock")
+	assert.Contains(t, stdout, "Plan: 1 to create, 0 to update, 0 to delete, 0 unchanged.")
+
+	_, err := os.Stat(out)
+	assert.True(t, os.IsNotExist(err), "plan must not write output")
+}
+
+func TestRun_PlanAfterGenerate(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	code, _, stderr := runCLI("-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	code, stdout, stderr := runCLI("plan", "-detailed-exitcode", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "No changes.")
+
+	// Adding a state changes the API surface of the existing file
+	updated := strings.Replace(doorSpec, "  - name: unlocked\n", "  - name: unlocked\n  - name: jammed\n", 1)
+	require.NoError(t, os.WriteFile(spec, []byte(updated), 0o600))
+
+	code, stdout, stderr = runCLI("plan", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "(update)")
+	assert.Contains(t, stdout, "+ const DoorLockStateJammed")
+}
+
+func TestRun_PlanPruneReportsStaleFiles(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	stale := filepath.Join(filepath.Dir(spec), "old_door_fsm.gen.go")
+	require.NoError(t, os.WriteFile(stale, []byte("// Code generated by gofsm-gen. DO NOT EDIT.\npackage security\n"), 0o600))
+	handWritten := filepath.Join(filepath.Dir(spec), "door.go")
+	require.NoError(t, os.WriteFile(handWritten, []byte("package security\n"), 0o600))
+
+	code, stdout, stderr := runCLI("plan", "-prune", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "- "+stale+" (delete)")
+	assert.NotContains(t, stdout, handWritten)
+
+	code, stdout, stderr = runCLI("-prune", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "removed "+stale)
+	_, err := os.Stat(handWritten)
+	assert.NoError(t, err, "hand-written files must never be pruned")
+}
+
+func TestRun_GenerateTests(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, stdout, stderr := runCLI("-gen-tests", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	testFile := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen_test.go")
+	assert.Contains(t, stdout, "wrote "+testFile)
+
+	generated, err := os.ReadFile(testFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func TestDoorLock_Transitions(t *testing.T)")
+}
+
+func TestRun_GenerateChaosHelpers(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-chaos", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func (sm *DoorLock) RunChaos(")
+}
+
+func TestRun_GenerateCoverage(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-coverage", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func WriteDoorLockCoverage(w io.Writer) error")
+}
+
+func TestRun_GenerateTrace(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-trace", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func NewDoorLockTraceRecorder(limit int) *DoorLockTraceRecorder")
+}
+
+func TestRun_GeneratePublisher(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-publisher", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func WithPublisher(p DoorLockPublisher) DoorLockOption")
+}
+
+func TestRun_GenerateCallbacks(t *testing.T) {
+	spec := writeSpec(t, strings.Replace(doorSpec, "    on: unlock", "    on: unlock\n    guard: hasKey", 1))
+
+	code, _, stderr := runCLI("-callbacks", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "\tHasKey(ctx context.Context, c *DoorLockContext) bool\n")
+	assert.Contains(t, string(generated), "func NewDoorLockWithCallbacks(callbacks DoorLockCallbacks, opts ...DoorLockOption) *DoorLock")
+}
+
+func TestRun_GenerateNaming(t *testing.T) {
+	spec := writeSpec(t, strings.Replace(doorSpec, "    on: unlock", "    on: unlock\n    guard: hasKeyId", 1))
+
+	code, _, stderr := runCLI("-constant-style=suffix", "-constant-machine-name=false", "-acronyms=ID", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "\tLockedState DoorLockState = 0\n")
+	assert.Contains(t, string(generated), "\tUnlockEvent DoorLockEvent = ")
+	assert.Contains(t, string(generated), "\tHasKeyID func(ctx context.Context, c *DoorLockContext) bool\n")
+
+	code, _, stderr = runCLI("-initialisms", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err = os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "\tHasKeyID func(ctx context.Context, c *DoorLockContext) bool\n")
+
+	code, _, stderr = runCLI("-constant-style=infix", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `constant style "infix" is not one of "prefix", "suffix"`)
+}
+
+func TestRun_GenerateTestkit(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, stdout, stderr := runCLI("-testkit", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	kit := filepath.Join(filepath.Dir(spec), "door_lock_testkit.go")
+	assert.Contains(t, stdout, "wrote "+kit)
+
+	generated, err := os.ReadFile(kit)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func NewDoorLockTestMachine(t testing.TB")
+}
+
+func TestRun_GenerateStubs(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+	stubs := filepath.Join(dir, "door_lock_callbacks.go")
+
+	code, _, stderr := runCLI("-gen-stubs", "-spec", spec)
+	assert.Equal(t, 0, code, stderr)
+
+	// The user implements the callbacks of a spec that has none yet, and the spec
+	// then gains a guard
+	implemented := "package main\n\nimport \"context\"\n\ntype DoorLockCallbacksImpl struct{}\n\n" +
+		"func (cb *DoorLockCallbacksImpl) HasKey(ctx context.Context, c *DoorLockContext) bool {\n\treturn false\n}\n"
+	require.NoError(t, os.WriteFile(stubs, []byte(implemented), 0o644))
+	guarded := strings.Replace(doorSpec, "    on: unlock\n", "    on: unlock\n    guard: hasKey\n", 1)
+	guarded = strings.Replace(guarded, "    on: lock\n", "    on: lock\n    guard: isClosed\n", 1)
+	require.NoError(t, os.WriteFile(spec, []byte(guarded), 0o644))
+
+	code, stdout, stderr := runCLI("-gen-stubs", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+stubs)
+	content, err := os.ReadFile(stubs)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), implemented), "the implemented guard is kept")
+	assert.Contains(t, string(content), "func (cb *DoorLockCallbacksImpl) IsClosed(ctx context.Context, c *DoorLockContext) bool {\n\t// TODO: implement the isClosed guard\n\treturn true\n}")
+	assert.Equal(t, 1, strings.Count(string(content), ") HasKey("))
+
+	code, stdout, stderr = runCLI("-gen-stubs", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "unchanged "+stubs)
+	assert.Empty(t, stderr)
+
+	// Renaming a guard in the spec leaves its stub behind, which is reported
+	renamed := strings.Replace(guarded, "guard: hasKey", "guard: hasBadge", 1)
+	require.NoError(t, os.WriteFile(spec, []byte(renamed), 0o644))
+	code, _, stderr = runCLI("-gen-stubs", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, "callback stub matches no callback of the spec")
+	assert.Contains(t, stderr, stubs+":7")
+	assert.Contains(t, stderr, "method=HasKey")
+	content, err = os.ReadFile(stubs)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), ") HasKey(", "the orphaned stub is kept")
+	assert.Contains(t, string(content), ") HasBadge(")
+
+	code, _, stderr = runCLI("-gen-stubs", "-split", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "callback stubs cannot be combined with -split")
+}
+
+func TestRun_GenerateSplit(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Join(filepath.Dir(spec), "lock")
+
+	code, stdout, stderr := runCLI("-split", "-gen-tests", "-out", dir, "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	for _, name := range []string{
+		"door_lock_states.go",
+		"door_lock_events.go",
+		"door_lock_callbacks.go",
+		"door_lock_machine.go",
+		"door_lock_test.go",
+	} {
+		path := filepath.Join(dir, name)
+		assert.Contains(t, stdout, "wrote "+path)
+		generated, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(generated), "package lock", "Package should be inferred from the target directory")
+	}
+
+	// Switching back to a single file prunes the split files
+	code, stdout, stderr = runCLI("-prune", "-out", filepath.Join(dir, "door_lock_fsm.gen.go"), "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "removed "+filepath.Join(dir, "door_lock_machine.go"))
+}
+
+func TestRun_GenerateBackend(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Join(filepath.Dir(spec), "web")
+
+	code, stdout, stderr := runCLI("-backend", "typescript", "-out", dir, "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	path := filepath.Join(dir, "door_lock.ts")
+	assert.Contains(t, stdout, "wrote "+path)
+	generated, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "export type DoorLockState")
+	assert.Contains(t, string(generated), "export class DoorLock {")
+	_, err = os.Stat(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	assert.True(t, os.IsNotExist(err), "Only the selected backend should generate code")
+
+	code, _, stderr = runCLI("-backend", "typescript", "-gen-tests", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "the typescript backend generates only the machine code")
+
+	code, stdout, stderr = runCLI("-lang=python", "-out", dir, "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	path = filepath.Join(dir, "door_lock.py")
+	assert.Contains(t, stdout, "wrote "+path)
+	generated, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "class DoorLock:")
+
+	code, _, stderr = runCLI("-backend", "cobol", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `backend "cobol" is not registered`)
+}
+
+func TestRun_GenerateGRPC(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(dir), "go.mod"), []byte("module example.com/building\n\ngo 1.21\n"), 0o600))
+
+	code, stdout, stderr := runCLI("-emit", "machine,grpc", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	proto, err := os.ReadFile(filepath.Join(dir, "door_lock_service.proto"))
+	require.NoError(t, err)
+	assert.Contains(t, string(proto), "package security;")
+	assert.Contains(t, string(proto), `option go_package = "example.com/building/security;security";`)
+	assert.Contains(t, string(proto), "service DoorLockService {")
+
+	server := filepath.Join(dir, "door_lock_grpc_server.go")
+	assert.Contains(t, stdout, "wrote "+server)
+	generated, err := os.ReadFile(server)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "package security")
+	assert.Contains(t, string(generated), "type DoorLockServer struct {")
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_store.go"))
+}
+
+func TestRun_GenerateHTTP(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+
+	code, stdout, stderr := runCLI("-emit", "machine,http", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	handler := filepath.Join(dir, "door_lock_http.go")
+	assert.Contains(t, stdout, "wrote "+handler)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_store.go"))
+	assert.NotContains(t, stdout, "door_lock_grpc_server.go")
+	generated, err := os.ReadFile(handler)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "package security")
+	assert.Contains(t, string(generated), "func (h *DoorLockHandler) Register(mux *http.ServeMux, prefix string) {")
+}
+
+func TestRun_GenerateTemporal(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+
+	code, stdout, stderr := runCLI("-emit", "machine,temporal", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	adapter := filepath.Join(dir, "door_lock_temporal.go")
+	assert.Contains(t, stdout, "wrote "+adapter)
+	assert.NotContains(t, stdout, "door_lock_store.go")
+	generated, err := os.ReadFile(adapter)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func RunDoorLockWorkflow(ctx workflow.Context, sm *DoorLock, opts DoorLockWorkflowOptions) (DoorLockState, error) {")
+}
+
+func TestRun_GenerateORM(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-emit", "machine,orm", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "machine DoorLock has no binding section")
+
+	spec = writeSpec(t, doorSpec+`
+binding:
+  orm: gorm
+  model: Door
+  field: LockState
+`)
+	code, stdout, stderr := runCLI("-emit", "machine,orm", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	binding := filepath.Join(filepath.Dir(spec), "door_lock_gorm.go")
+	assert.Contains(t, stdout, "wrote "+binding)
+	generated, err := os.ReadFile(binding)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func (b *DoorLockBinding) Fire(ctx context.Context, m *Door, event DoorLockEvent, fn func(tx *gorm.DB, m *Door) error) error {")
+}
+
+func TestRun_GenerateHeaderFlags(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-stamp", "-build-tags", "!fsm_stub", "-copyright", "Copyright 2026 Acme Corp.", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "// Copyright 2026 Acme Corp.\n")
+	assert.Contains(t, string(generated), "// Source: "+filepath.ToSlash(spec)+" (sha256:")
+	assert.Contains(t, string(generated), "//go:build !fsm_stub\n")
+
+	code, _, stderr = runCLI("-build-tags", "linux &&", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "invalid build tags")
+}
+
+func TestRun_EmitSelectsArtifacts(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+	machine := filepath.Join(dir, "door_lock_fsm.gen.go")
+
+	code, stdout, stderr := runCLI("-emit", "diagram", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_fsm.mmd"))
+	assert.NotContains(t, stdout, machine, "Only the selected artifacts are written")
+
+	diagram, err := os.ReadFile(filepath.Join(dir, "door_lock_fsm.mmd"))
+	require.NoError(t, err)
+	assert.Contains(t, string(diagram), "locked --> unlocked: unlock")
+
+	code, stdout, stderr = runCLI("-emit", "machine,tests", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+machine)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_fsm.gen_test.go"))
+}
+
+func TestRun_EmitRejectsStaleCombinations(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-emit", "tests", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "tests depend on the generated machine")
+
+	code, _, stderr = runCLI("-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	code, _, stderr = runCLI("-emit", "tests,testkit", "-spec", spec)
+	require.Equal(t, 0, code, stderr, "the machine on disk is current")
+
+	// Changing the spec makes the machine on disk stale for dependent artifacts
+	updated := strings.Replace(doorSpec, "  - name: unlocked\n", "  - name: unlocked\n  - name: jammed\n", 1)
+	require.NoError(t, os.WriteFile(spec, []byte(updated), 0o600))
+	code, _, stderr = runCLI("-emit", "testkit", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "missing or out of date; add machine to -emit")
+
+	code, _, stderr = runCLI("-emit", "diagram", "-spec", spec)
+	assert.Equal(t, 0, code, "diagrams do not depend on the machine: %s", stderr)
+
+	code, _, stderr = runCLI("-emit", "mocks", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `-emit target "mocks" is not supported; use testkit`)
+
+	code, _, stderr = runCLI("-emit", "docs", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `-emit target "docs" is not supported; use "gofsm-gen export markdown"`)
+
+	code, _, stderr = runCLI("-emit", "machine", "-prune", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-prune cannot be combined with -emit")
+}
+
+func TestRun_Verify(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, stdout, _ := runCLI("verify", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "missing  "+out)
+
+	code, _, stderr := runCLI("-gen-tests", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	code, stdout, stderr = runCLI("verify", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "ok       "+out)
+	assert.Contains(t, stdout, "ok       "+filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen_test.go"))
+
+	// Hand edits are detected
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	edited := strings.Replace(string(generated), "return \"locked\"", "return \"LOCKED\"", 1)
+	require.NoError(t, os.WriteFile(out, []byte(edited), 0o600))
+
+	code, stdout, stderr = runCLI("verify", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "edited   "+out)
+	assert.Contains(t, stderr, "1 generated file(s) out of date")
+
+	// Spec changes make unedited output stale
+	require.NoError(t, os.WriteFile(out, generated, 0o600))
+	updated := strings.Replace(doorSpec, "  - name: unlocked\n", "  - name: unlocked\n  - name: jammed\n", 1)
+	require.NoError(t, os.WriteFile(spec, []byte(updated), 0o600))
+
+	code, stdout, _ = runCLI("verify", "-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "stale    "+out)
+}
+
+func TestRun_VerifySplit(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-split", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+
+	code, stdout, stderr := runCLI("verify", "-split", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "ok       "+filepath.Join(filepath.Dir(spec), "door_lock_machine.go"))
+}
+
+func TestRun_GenerateSkipsUnchangedFiles(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, stdout, stderr := runCLI("-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(out, past, past))
+
+	code, stdout, stderr = runCLI("-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "unchanged "+out)
+	info, err := os.Stat(out)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(past), "Identical output must not be rewritten")
+
+	code, stdout, stderr = runCLI("-force", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+	info, err = os.Stat(out)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().After(past), "-force must rewrite identical output")
+}
+
+func TestRun_GenerateStableValues(t *testing.T) {
+	spec := writeSpec(t, doorSpec+"options:\n  stable_values: true\n")
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, _, stderr := runCLI("-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	before, err := os.ReadFile(out)
+	require.NoError(t, err)
+
+	// "jammed" sorts before "unlocked" and would renumber it
+	jammed := strings.Replace(doorSpec, "  - name: unlocked\n", "  - name: jammed\n  - name: unlocked\n", 1)
+	require.NoError(t, os.WriteFile(spec, []byte(jammed+"options:\n  stable_values: true\n"), 0o600))
+
+	code, _, stderr = runCLI("-spec", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "DoorLockStateUnlocked would change value from 1 to 2; pin it with `value: 1`")
+	after, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "A rejected generation must not write")
+
+	pinned := strings.Replace(jammed, "  - name: unlocked\n", "  - name: unlocked\n    value: 1\n", 1)
+	require.NoError(t, os.WriteFile(spec, []byte(pinned+"options:\n  stable_values: true\n"), 0o600))
+
+	code, _, stderr = runCLI("-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "DoorLockStateJammed DoorLockState = 2")
+}
+
+func TestRun_ExportSQL(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock.sql")
+
+	code, stdout, stderr := runCLI("export", "sql", "-table", "security.doors", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	sql, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(sql), "ADD CONSTRAINT doors_state_valid CHECK (state IN ('locked', 'unlocked'));")
+	assert.Contains(t, string(sql), "('locked', 'unlocked'), -- unlock")
+	assert.Contains(t, string(sql), "BEFORE UPDATE OF state ON security.doors")
+
+	code, _, stderr = runCLI("export", "sql", "-column", "1state", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `column "1state" is not a plain SQL identifier`)
+}
+
+func TestRun_ExportProto(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock.proto")
+
+	code, stdout, stderr := runCLI("export", "proto", "-transition-record", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	proto, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(proto), "package security;")
+	assert.Contains(t, string(proto), "DOOR_LOCK_STATE_LOCKED = 0;")
+	assert.Contains(t, string(proto), "message DoorLockTransitionRecord {")
+
+	code, stdout, stderr = runCLI("export", "proto", "-transition-record", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "unchanged "+out)
+
+	custom := filepath.Join(t.TempDir(), "door.proto")
+	code, _, stderr = runCLI("export", "proto", "-proto-package", "acme.security.v1", "-out", custom, "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	proto, err = os.ReadFile(custom)
+	require.NoError(t, err)
+	assert.Contains(t, string(proto), "package acme.security.v1;")
+}
+
+func TestRun_ExportTLA(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "DoorLock.tla")
+
+	code, stdout, stderr := runCLI("export", "tla", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	module, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(module), "---- MODULE DoorLock ----\n")
+	assert.Contains(t, string(module), `<<"locked", "unlock", "unlocked">>`)
+}
+
+func TestRun_ExportTypeScript(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock.ts")
+
+	code, stdout, stderr := runCLI("export", "typescript", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	ts, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(ts), `export type DoorLockState =`)
+	assert.Contains(t, string(ts), `locked: {
+    unlock: ["unlocked"],
+  },`)
+}
+
+func TestRun_ExportOpenAPI(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock.openapi.yaml")
+
+	code, stdout, stderr := runCLI("export", "openapi", "-api-version", "3.0.0", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	doc, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(doc), `version: "3.0.0"`)
+	assert.Contains(t, string(doc), "    DoorLockAllowedEvents:\n")
+}
+
+func TestRun_ExportDOT(t *testing.T) {
+	spec := writeSpec(t, strings.Replace(doorSpec, "  - name: unlocked\n", "  - name: unlocked\n    tags: [open]\n", 1))
+	out := filepath.Join(filepath.Dir(spec), "door_lock.dot")
+
+	code, stdout, stderr := runCLI("export", "dot", "-tag-style", "open:color=green", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	dot, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(dot), `"unlocked" [color="green"];`)
+
+	code, _, stderr = runCLI("export", "dot", "-tag-style", "color=green", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `-tag-style "color=green" is not tag:attr=value,...`)
+}
+
+func TestRun_ExportHTML(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock.html")
+
+	code, stdout, stderr := runCLI("export", "html", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	page, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(page), `const graph = {"name":"DoorLock"`)
+}
+
+func TestRun_ExportMarkdown(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(t.TempDir(), "docs", "door.md")
+
+	code, stdout, stderr := runCLI("export", "markdown", "-out", out, spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	doc, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(doc), "| `locked` | `unlock` | `unlocked` | - | - |")
+}
+
+func TestRun_ExportCSV(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_matrix.csv")
+
+	code, stdout, stderr := runCLI("export", "csv", "-bom", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	matrix, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "\ufeffstate,lock,unlock\nlocked,,unlocked\nunlocked,locked,\n", string(matrix))
+}
+
+func TestRun_ExportRejectsUnknownFormat(t *testing.T) {
+	code, _, stderr := runCLI("export", "avro")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown format "avro"`)
+	assert.Contains(t, stderr, "proto ")
+
+	code, _, stderr = runCLI("export", "proto")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "must specify -spec")
+}
+
+// syncBuilder is a strings.Builder that is safe for concurrent use
+type syncBuilder struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (s *syncBuilder) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuilder) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestRun_WatchRegeneratesOnChange(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stdout, stderr syncBuilder
+	done := make(chan error)
+	go func() {
+		var flags generateFlags
+		done <- flags.watch(ctx, []string{spec}, &stdout, &stderr)
+	}()
+	defer func() {
+		cancel()
+		require.NoError(t, <-done)
+	}()
+
+	require.Eventually(t, func() bool { return strings.Contains(stdout.String(), "watching 1 spec(s)") }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, stdout.String(), "wrote "+out)
+
+	// An invalid spec is reported and watching continues
+	require.NoError(t, os.WriteFile(spec, []byte(strings.Replace(doorSpec, "initial: locked", "initial: ajar", 1)), 0o600))
+	require.Eventually(t, func() bool { return strings.Contains(stderr.String(), `initial state "ajar" is not defined`) }, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, os.WriteFile(spec, []byte(strings.Replace(doorSpec, "DoorLock", "DoorLatch", 1)), 0o600))
+	latch := filepath.Join(filepath.Dir(spec), "door_latch_fsm.gen.go")
+	require.Eventually(t, func() bool { return strings.Contains(stdout.String(), "wrote "+latch) }, 5*time.Second, 10*time.Millisecond)
+	assert.Contains(t, stdout.String(), "changed "+spec)
+}
+
+func TestRun_WatchRequiresSpec(t *testing.T) {
+	code, _, stderr := runCLI("watch")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "must specify -spec")
+}
+
+func TestRun_GenerateRecursive(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write("security/door.fsm.yaml", doorSpec)
+	write("billing/invoices/invoice.fsm.yaml", strings.Replace(doorSpec, "DoorLock", "Invoice", 1))
+	write("billing/invoices/.gofsm.yaml", "package: billing\nemit: [machine, tests]\ncopyright: Copyright Acme\n")
+	write("billing/invoices/notes.yaml", "not a spec")
+	write("vendor/lib/vendored.fsm.yaml", "not a spec")
+	write("security/testdata/broken.fsm.yaml", "not a spec")
+
+	code, stdout, stderr := runCLI(filepath.Join(root, "..."))
+	require.Equal(t, 0, code, stderr)
+
+	door := filepath.Join(root, "security", "door_lock_fsm.gen.go")
+	invoice := filepath.Join(root, "billing", "invoices", "invoice_fsm.gen.go")
+	assert.Equal(t, "wrote "+invoice+"\nwrote "+generator.TestOutputName(invoice)+"\nwrote "+door+"\n", stdout)
+
+	generated, err := os.ReadFile(door)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "package security\n")
+
+	generated, err = os.ReadFile(invoice)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "// Copyright Acme\n")
+	assert.Contains(t, string(generated), "package billing\n")
+}
+
+func TestRun_GenerateRegistry(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write("go.mod", "module example.com/shop\n\ngo 1.21\n")
+	write("security/door.fsm.yaml", doorSpec)
+	write("billing/invoice.fsm.yaml", strings.Replace(doorSpec, "DoorLock", "Invoice", 1))
+
+	registry := filepath.Join(root, "fsmregistry")
+	code, stdout, stderr := runCLI("-registry", registry, filepath.Join(root, "..."))
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(registry, generator.RegistryOutputName)+"\n")
+
+	generated, err := os.ReadFile(filepath.Join(registry, generator.RegistryOutputName))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "package fsmregistry\n")
+	assert.Contains(t, string(generated), "\tbilling \"example.com/shop/billing\"\n\tsecurity \"example.com/shop/security\"\n")
+	assert.Contains(t, string(generated), "\t\t\"DoorLock\": newDoorLock,\n")
+
+	code, _, stderr = runCLI("-registry", filepath.Join(root, "security"), filepath.Join(root, "..."))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-registry needs a directory of its own, not that of the machine")
+
+	spec := writeSpec(t, doorSpec)
+	code, _, stderr = runCLI("-registry", filepath.Join(filepath.Dir(spec), "registry"), spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-registry needs the machine to be generated inside a Go module")
+}
+
+func TestRun_GenerateSideBySide(t *testing.T) {
+	v1 := writeSpec(t, strings.Replace(doorSpec, "initial: locked", "initial: locked\n  version: 1", 1))
+	dir := filepath.Dir(v1)
+	v2 := filepath.Join(dir, "door_v2.fsm.yaml")
+	require.NoError(t, os.WriteFile(v2, []byte(strings.Replace(doorSpec, "initial: locked", "initial: locked\n  version: 2", 1)), 0o600))
+
+	code, stdout, stderr := runCLI("-side-by-side", v1, v2)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_v1_fsm.gen.go")+"\n")
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_v2_fsm.gen.go")+"\n")
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_versions_fsm.gen.go")+"\n")
+
+	generated, err := os.ReadFile(filepath.Join(dir, "door_lock_v2_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func NewDoorLockV2(")
+	assert.Contains(t, string(generated), "func WithLoggerV2(")
+	versions, err := os.ReadFile(filepath.Join(dir, "door_lock_versions_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(versions), "type DoorLock interface {")
+	assert.Contains(t, string(versions), "var DoorLockVersions = []int{1, 2}")
+
+	unversioned := writeSpec(t, doorSpec)
+	code, _, stderr = runCLI("-side-by-side", unversioned)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "machine DoorLock needs a version to be generated side by side")
+}
+
+func TestRun_GenerateRecursivePattern(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	root := filepath.Dir(filepath.Dir(spec))
+
+	code, _, stderr := runCLI(filepath.Join(root, "..."))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "matched no spec files named *.fsm.yaml")
+
+	code, stdout, stderr := runCLI("-pattern", "door.yaml", filepath.Join(root, "..."))
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+}
+
+func TestRun_GenerateRejectsUnknownDirConfigKeys(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), ".gofsm.yaml"), []byte("pakage: doors\n"), 0o600))
+
+	code, _, stderr := runCLI(spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "field pakage not found")
+}
+
+func TestRun_GenerateProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write("go.mod", "module example.com/acme\n")
+	write(".gofsm.yaml", "output_suffix: _machine.go\ncopyright: Copyright Acme\nstamp: true\ntemplates: tools/templates\n")
+	write("internal/locks/door.yaml", doorSpec)
+	write("internal/locks/.gofsm.yaml", "copyright: Copyright Acme Locks\n")
+
+	// A parent of the project root is not consulted
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(root), configName), []byte("package: outside\n"), 0o600))
+
+	spec := filepath.Join(root, "internal", "locks", "door.yaml")
+	code, _, stderr := runCLI(spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, filepath.Join(root, "tools", "templates"), "templates are resolved against the config file")
+
+	templates, err := filepath.Abs(filepath.Join("..", "..", "templates"))
+	require.NoError(t, err)
+	code, stdout, stderr := runCLI("-templates", templates, "-stamp=false", spec)
+	require.Equal(t, 0, code, stderr)
+	out := filepath.Join(root, "internal", "locks", "door_lock_machine.go")
+	assert.Contains(t, stdout, "wrote "+out)
+
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "// Copyright Acme Locks\n")
+	assert.Contains(t, string(generated), "package locks\n")
+	assert.NotContains(t, string(generated), "// Generator: gofsm-gen")
+}
+
+func TestRun_GenerateSprig(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	templates := filepath.Join(filepath.Dir(spec), "templates")
+	require.NoError(t, os.Mkdir(templates, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templates, "state_machine.tmpl"),
+		[]byte("package {{.Package}}\n\n// {{.Name | kebabcase}}\n"), 0o600))
+
+	code, _, stderr := runCLI("-templates", templates, spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `function "kebabcase" not defined`)
+
+	code, _, stderr = runCLI("-templates", templates, "-sprig", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "// door-lock\n")
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), configName), []byte("templates: templates\nsprig: true\n"), 0o600))
+	code, _, stderr = runCLI(spec)
+	require.Equal(t, 0, code, stderr)
+}
+
+func TestRun_GenerateTemplateOverrides(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	overrides := filepath.Join(filepath.Dir(spec), "overrides")
+	require.NoError(t, os.Mkdir(overrides, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(overrides, "methods.tmpl"),
+		[]byte("{{define \"extra_machine_methods\"}}\n\n// Door is an extension\nfunc (sm *{{.Name}}) Door() {}\n{{- end}}\n"), 0o600))
+
+	code, _, stderr := runCLI("-template-overrides", overrides, spec)
+	require.Equal(t, 0, code, stderr)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func (sm *DoorLock) Door() {}")
+
+	require.NoError(t, os.Remove(out))
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), configName), []byte("template_overrides: overrides\n"), 0o600))
+	code, _, stderr = runCLI(spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err = os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func (sm *DoorLock) Door() {}", "overrides are resolved against the config file")
+}
+
+func TestRun_GenerateUserRegions(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, _, stderr := runCLI("-user-regions", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	helper := "//gofsm-gen:user-begin helpers\nfunc Locked(s DoorLockState) bool { return s == DoorLockStateLocked }\n"
+	edited := strings.Replace(string(generated), "//gofsm-gen:user-begin helpers\n", helper, 1)
+	require.NotEqual(t, string(generated), edited)
+	require.NoError(t, os.WriteFile(out, []byte(edited), 0o600))
+
+	code, _, stderr = runCLI("verify", spec)
+	assert.Equal(t, 0, code, "code in user regions is not a hand edit: %s", stderr)
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), configName), []byte("user_regions: true\n"), 0o600))
+	code, _, stderr = runCLI("-force", "-gen-tests", spec)
+	require.Equal(t, 0, code, stderr)
+	regenerated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, edited, string(regenerated), "regeneration keeps user code")
+
+	code, _, stderr = runCLI("-user-regions=false", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `user region "helpers" is no longer generated; move its code elsewhere first`)
+}
+
+func TestRun_GenerateImmutableContext(t *testing.T) {
+	spec := writeSpec(t, doorSpec+"context:\n  - name: attempts\n    type: int\n")
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, _, stderr := runCLI("-immutable-context", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "func (c *DoorLockContext) WithAttempts(v int) *DoorLockContext {")
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), configName), []byte("immutable_context: true\n"), 0o600))
+	code, _, stderr = runCLI("-immutable-context=false", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err = os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "\tAttempts int\n", "the flag overrides the configuration")
+}
+
+func TestRun_GenerateRecoverPanics(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), configName), []byte("recover_panics: true\n"), 0o600))
+	code, _, stderr := runCLI(spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "type DoorLockActionPanickedError struct {")
+
+	code, _, stderr = runCLI("-recover-panics=false", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err = os.ReadFile(out)
+	require.NoError(t, err)
+	assert.NotContains(t, string(generated), "recover()", "the flag overrides the configuration")
+}
+
+func TestRun_GenerateUnhandledEvent(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, _, stderr := runCLI("-unhandled-event=log", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), `sm.logger.Info("Ignored unhandled event", "state", currentState, "event", event)`)
+	assert.NotContains(t, string(generated), "invalid event %s for state %s")
+
+	code, _, stderr = runCLI("-unhandled-event=drop", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `unhandled event policy "drop" is not one of "error", "ignore", "log"`)
+}
+
+func TestRun_GenerateIdempotencyKeys(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-idempotency-keys=64", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "\tfor len(order) > 64 {\n")
+}
+
+func TestRun_GenerateReentrancy(t *testing.T) {
+	spec := writeSpec(t, doorSpec+"options:\n  reentrancy: error\n")
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, _, stderr := runCLI(spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "var ErrReentrantDoorLockTransition = ")
+
+	code, _, stderr = runCLI("-reentrancy=queue", spec)
+	require.Equal(t, 0, code, stderr)
+	generated, err = os.ReadFile(out)
+	require.NoError(t, err)
+	assert.NotContains(t, string(generated), "ErrReentrantDoorLockTransition", "the flag overrides the spec")
+	assert.Contains(t, string(generated), "\tqueued          []DoorLockEvent\n")
+
+	code, _, stderr = runCLI("-reentrancy=ignore", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `reentrancy policy "ignore" is not one of "error", "queue"`)
+}
+
+func TestRun_GenerateDebugTemplates(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("-debug-templates", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, "=== template state_machine.tmpl\n--- data\n")
+	assert.Contains(t, stderr, `"Name": "DoorLock"`)
+	assert.Regexp(t, `--- output lines\n  1-\d+ +state_machine.tmpl\n`, stderr)
+
+	generated, err := os.ReadFile(filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(generated), "\x1e")
+
+	templates := filepath.Join(filepath.Dir(spec), "templates")
+	require.NoError(t, os.Mkdir(templates, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(templates, "state_machine.tmpl"), []byte("package {{.Package}}\n// {{.Nmae}}\n"), 0o600))
+	code, _, stderr = runCLI("-debug-templates", "-templates", templates, spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "    2 | // {{.Nmae}}\n      |      ^\n")
+}
+
+func TestRun_GenerateRejectsConfigOutputSuffix(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), configName), []byte("output_suffix: .txt\n"), 0o600))
+
+	code, _, stderr := runCLI(spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `output_suffix ".txt" in .gofsm.yaml must end in .go`)
+}
+
+func TestRun_Validate(t *testing.T) {
+	spec := writeSpec(t, strings.Replace(doorSpec, "  - unlock\n", "  - unlock\n  - alarm\n", 1))
+
+	code, stdout, stderr := runCLI("validate", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, spec+`: warning: event "alarm" does not trigger any transition (unused-event)`+"\n"+
+		"1 problem(s) (0 error(s), 1 warning(s)) in 1 spec(s)\n", stdout)
+
+	code, _, _ = runCLI("validate", "-fail-on", "warning", spec)
+	assert.Equal(t, 1, code)
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), configName), []byte("lint:\n  unused-event: off\n"), 0o600))
+	code, stdout, stderr = runCLI("validate", "-fail-on", "warning", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "1 spec(s) valid, no problems found\n", stdout)
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+}
+
+func TestRun_ValidateNondeterminism(t *testing.T) {
+	spec := writeSpec(t, doorSpec+"  - from: locked\n    to: locked\n    on: unlock\n")
+
+	code, stdout, _ := runCLI("validate", spec)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, spec+`: error: state "locked" has 2 unguarded transitions on "unlock" (to "unlocked", "locked"); the generated machine cannot choose between them (nondeterministic-transition)`+"\n"+
+		"1 problem(s) (1 error(s), 0 warning(s)) in 1 spec(s)\n", stdout)
+
+	code, _, stderr := runCLI(spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "nondeterministic transitions")
+}
+
+func TestRun_ValidateTrapCycle(t *testing.T) {
+	spec := strings.Replace(doorSpec, "  - name: unlocked\n", "  - name: unlocked\n  - name: removed\n    final: true\n", 1)
+	spec = strings.Replace(spec, "  - unlock\n", "  - unlock\n  - remove\n", 1)
+	path := writeSpec(t, spec)
+
+	code, stdout, _ := runCLI("validate", path)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, path+`: warning: no final state is reachable from "locked", "unlocked" (cycle locked --unlock--> unlocked --lock--> locked) (trap-cycle)`+"\n"+
+		path+`: warning: state "removed" is not reachable from the initial state "locked" (unreachable-state)`+"\n"+
+		path+`: warning: event "remove" does not trigger any transition (unused-event)`+"\n"+
+		"3 problem(s) (0 error(s), 3 warning(s)) in 1 spec(s)\n", stdout)
+}
+
+func TestRun_ValidateJSON(t *testing.T) {
+	spec := writeSpec(t, strings.Replace(doorSpec, "initial: locked", "initial: ajar", 1))
+
+	code, stdout, _ := runCLI("validate", "-format", "json", spec)
+	assert.Equal(t, 1, code)
+
+	var findings []map[string]string
+	require.NoError(t, json.Unmarshal([]byte(stdout), &findings))
+	assert.Equal(t, []map[string]string{{
+		"file":     spec,
+		"rule":     "invalid-spec",
+		"severity": "error",
+		"message":  `initial state "ajar" is not defined`,
+	}}, findings)
+}
+
+func TestRun_ValidateSARIF(t *testing.T) {
+	spec := writeSpec(t, strings.Replace(doorSpec, "  - unlock\n", "  - unlock\n  - alarm\n", 1))
+
+	code, stdout, stderr := runCLI("validate", "-format", "sarif", spec)
+	require.Equal(t, 0, code, stderr)
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	assert.Equal(t, "gofsm-gen", log.Runs[0].Tool.Driver.Name)
+	assert.Len(t, log.Runs[0].Tool.Driver.Rules, len(lint.Rules())+1)
+	require.Len(t, log.Runs[0].Results, 1)
+	assert.Equal(t, "unused-event", log.Runs[0].Results[0].RuleID)
+	assert.Equal(t, "warning", log.Runs[0].Results[0].Level)
+	assert.Equal(t, filepath.ToSlash(spec), log.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+}
+
+func TestRun_ValidateRejectsUnknownFormat(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, _, stderr := runCLI("validate", "-format", "xml", spec)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown -format "xml"`)
+}
+
+func TestRun_GenerateDryRun(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, stdout, stderr := runCLI("-dry-run", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "would create "+out+"\n", stdout)
+	assert.NoFileExists(t, out)
+
+	code, _, stderr = runCLI(spec)
+	require.Equal(t, 0, code, stderr)
+	original, err := os.ReadFile(out)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(spec, []byte(strings.Replace(doorSpec, "  - unlock\n", "  - unlock\n  - jam\n", 1)), 0o600))
+	code, stdout, stderr = runCLI("-dry-run", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "would update "+out+"\n--- "+filepath.ToSlash(out)+"\n+++ "+filepath.ToSlash(out)+"\n@@ "), stdout)
+	assert.Contains(t, stdout, "\n+\tDoorLockEventJam DoorLockEvent = 0\n")
+
+	current, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, original, current)
+}
+
+func TestRun_GeneratePlugin(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+
+	t.Setenv(testPluginEnv, "audit")
+	code, stdout, stderr := runCLI("-plugin", exe, spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_fsm.gen.go"))
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "door_lock_audit.go"))
+	audit, err := os.ReadFile(filepath.Join(dir, "door_lock_audit.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package security\n\n// Audited states: locked, unlocked\n", string(audit),
+		"the plugin sees the model with the resolved package")
+
+	t.Setenv(testPluginEnv, "fail")
+	code, _, stderr = runCLI("-plugin", exe, spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "plugin "+exe+": DoorLock has no auditor")
+
+	t.Setenv(testPluginEnv, "clash")
+	code, _, stderr = runCLI("-plugin", exe, spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, filepath.Join(dir, "door_lock_fsm.gen.go")+" is already generated")
+
+	code, _, stderr = runCLI("-plugin", exe, "-out=-", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-out=- cannot be combined with -split, -prune, -registry, or -plugin")
+}
+
+func TestRun_GeneratePostProcess(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+	out := filepath.Join(dir, "door_lock_fsm.gen.go")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, configName),
+		[]byte(fmt.Sprintf("post_process:\n  - %s MIT {file}\n", exe)), 0o600))
+
+	t.Setenv(testPostProcessEnv, "license")
+	code, stdout, stderr := runCLI("-gen-tests", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+out)
+	for _, path := range []string{out, filepath.Join(dir, "door_lock_fsm.gen_test.go")} {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(content), "// License: MIT\n\n"+generator.GeneratedMarker+"\n"), path)
+		_, intact, err := generator.ReadChecksums(content)
+		require.NoError(t, err)
+		assert.True(t, intact, "the checksum records the processed content of %s", path)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 4, "the copies the commands ran on are removed")
+
+	code, stdout, stderr = runCLI("-gen-tests", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "unchanged "+out+"\nunchanged "+filepath.Join(dir, "door_lock_fsm.gen_test.go")+"\n", stdout,
+		"processed files are compared after processing")
+
+	code, _, stderr = runCLI("verify", spec)
+	assert.Equal(t, 0, code, stderr)
+
+	t.Setenv(testPostProcessEnv, "fail")
+	code, _, stderr = runCLI(spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "post_process \""+exe+" MIT {file}\" on "+out+": exit status 2\n"+out+": line too long")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, configName), []byte("post_process: [\" \"]\n"), 0o600))
+	code, _, stderr = runCLI(spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "post_process command 1 is empty")
+}
+
+func TestRun_GeneratePostProcessGofmt(t *testing.T) {
+	gofmt, err := exec.LookPath("gofmt")
+	if err != nil {
+		t.Skip("gofmt not available")
+	}
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+	out := filepath.Join(dir, "door_lock_fsm.gen.go")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, configName),
+		[]byte(fmt.Sprintf("post_process:\n  - %s -w {file}\n", gofmt)), 0o600))
+
+	code, _, stderr := runCLI("-gen-tests", spec)
+	require.Equal(t, 0, code, stderr)
+	formatted, err := exec.Command(gofmt, "-l", dir).CombinedOutput()
+	require.NoError(t, err, string(formatted))
+	assert.Empty(t, string(formatted), "the generated files are formatted")
+
+	code, stdout, stderr := runCLI("verify", "-gen-tests", spec)
+	assert.Equal(t, 0, code, stdout+stderr)
+	code, stdout, stderr = runCLI("-gen-tests", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "unchanged "+out+"\nunchanged "+filepath.Join(dir, "door_lock_fsm.gen_test.go")+"\n", stdout)
+}
+
+func TestRun_Directive(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+
+	code, stdout, stderr := runCLI("directive", "-gen-tests", "-chaos", "-copyright", "Acme Corp", "-out", filepath.Join(dir, "gen", "door.go"), spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "//go:generate gofsm-gen -chaos \"-copyright=Acme Corp\" -gen-tests -out=gen/door.go door.yaml\n", stdout,
+		"flags are in name order, with paths relative to the directory of the spec")
+
+	code, stdout, stderr = runCLI("directive", "-dir", filepath.Join(dir, "cmd"), "-pattern", "*.yaml", "-spec", spec, filepath.Dir(dir)+"/...")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "//go:generate gofsm-gen -pattern=*.yaml -spec=../door.yaml ../../...\n", stdout)
+
+	anchor := filepath.Join(dir, "generate.go")
+	code, stdout, stderr = runCLI("directive", "-w", anchor, "-gen-tests", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "wrote "+anchor+"\n", stdout)
+	src, err := os.ReadFile(anchor)
+	require.NoError(t, err)
+	assert.Equal(t, "package security\n\n//go:generate gofsm-gen -gen-tests door.yaml\n", string(src))
+
+	code, stdout, stderr = runCLI("directive", "-w", anchor, "-gen-tests", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "unchanged "+anchor+"\n", stdout)
+
+	existing := filepath.Join(dir, "doc.go")
+	require.NoError(t, os.WriteFile(existing, []byte("// Package security locks doors.\npackage security\n\nconst x = 1\n"), 0o600))
+	code, _, stderr = runCLI("directive", "-w", existing, spec)
+	require.Equal(t, 0, code, stderr)
+	src, err = os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "// Package security locks doors.\npackage security\n\n//go:generate gofsm-gen door.yaml\n\nconst x = 1\n", string(src))
+
+	code, _, stderr = runCLI("directive", "-anchor-file", anchor, spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-anchor-file reads a directive; it cannot be part of one")
+}
+
+func TestRun_AnchorFile(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	dir := filepath.Dir(spec)
+	anchor := filepath.Join(dir, "generate.go")
+	require.NoError(t, os.WriteFile(anchor, []byte(`package security
+
+//go:generate gofsm-gen -gen-tests "-copyright=Generated for $GOPACKAGE" door.yaml
+//go:generate gofsm-gen export markdown door.yaml
+//go:generate gofsm-gen verify -anchor-file=$GOFILE
+//go:generate go run example.com/tools/lint ./...
+`), 0o600))
+
+	code, stdout, stderr := runCLI("-anchor-file", anchor)
+	require.Equal(t, 0, code, stderr)
+	out := filepath.Join(dir, "door_lock_fsm.gen.go")
+	assert.Equal(t, "wrote "+out+"\nwrote "+filepath.Join(dir, "door_lock_fsm.gen_test.go")+"\n", stdout,
+		"the directive runs as go generate would, from the directory of its file")
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "Generated for security", "variables are expanded as go generate does")
+
+	code, stdout, stderr = runCLI("verify", "-anchor-file", anchor)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "ok       "+out)
+
+	code, _, stderr = runCLI("plan", "-anchor-file", anchor)
+	require.Equal(t, 0, code, stderr)
+
+	code, _, stderr = runCLI("-anchor-file", anchor, "-chaos")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-anchor-file cannot be combined with -chaos")
+
+	code, _, stderr = runCLI("-anchor-file", anchor, spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-anchor-file cannot be combined with specs")
+
+	require.NoError(t, os.WriteFile(anchor, []byte("package security\n\n//go:generate gofsm-gen door.yaml\n//go:generate go run github.com/yourusername/gofsm-gen/cmd/gofsm-gen@latest -gen-tests door.yaml\n"), 0o600))
+	code, _, stderr = runCLI("-anchor-file", anchor)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "has 2 //go:generate directives running gofsm-gen; -anchor-file needs exactly one")
+
+	require.NoError(t, os.WriteFile(anchor, []byte("package security\n"), 0o600))
+	code, _, stderr = runCLI("-anchor-file", anchor)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "has no //go:generate directive running gofsm-gen")
+}
+
+func TestSplitDirective(t *testing.T) {
+	env := func(name string) string { return map[string]string{"GOFILE": "fsm.go", "DOLLAR": "$"}[name] }
+	words, err := splitDirective(`gofsm-gen  -copyright="A \"B\"	C" $GOFILE "$DOLLAR"`, env)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gofsm-gen", `-copyright="A`, `\"B\"`, `C"`, "fsm.go", "$"}, words,
+		"quotes only group whole words")
+
+	words, err = splitDirective(`gofsm-gen "-copyright=A \"B\"	C" $GOFILE`, env)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gofsm-gen", "-copyright=A \"B\"\tC", "fsm.go"}, words)
+
+	_, err = splitDirective(`gofsm-gen "door.yaml`, env)
+	assert.ErrorContains(t, err, "unterminated quoted string")
+	_, err = splitDirective(`gofsm-gen "door".yaml`, env)
+	assert.ErrorContains(t, err, "text after quoted string")
+}
+
+func TestRun_GenerateCache(t *testing.T) {
+	t.Setenv(cacheEnv, t.TempDir())
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+	generate := func(args ...string) (stdout string, hit bool) {
+		t.Helper()
+		code, stdout, stderr := runCLI(append(append([]string{"-vv"}, args...), spec)...)
+		require.Equal(t, 0, code, stderr)
+		return stdout, strings.Contains(stderr, "build cache hit")
+	}
+
+	stdout, hit := generate()
+	assert.False(t, hit)
+	assert.Contains(t, stdout, "wrote "+out)
+
+	stdout, hit = generate()
+	assert.True(t, hit, "nothing changed")
+	assert.Equal(t, "unchanged "+out+"\n", stdout)
+
+	_, hit = generate("-gen-tests")
+	assert.False(t, hit, "the flags changed")
+	_, hit = generate("-gen-tests")
+	assert.True(t, hit)
+
+	require.NoError(t, os.WriteFile(spec, []byte(strings.Replace(doorSpec, "  - unlock\n", "  - unlock\n  - jam\n", 1)), 0o600))
+	stdout, hit = generate()
+	assert.False(t, hit, "the spec changed")
+	assert.Contains(t, stdout, "wrote "+out)
+
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(spec), configName), []byte("copyright: Example Corp\n"), 0o600))
+	_, hit = generate()
+	assert.False(t, hit, "the configuration changed")
+
+	require.NoError(t, os.WriteFile(out, []byte("// edited\n"), 0o600))
+	stdout, hit = generate()
+	assert.False(t, hit, "the output was edited")
+	assert.Contains(t, stdout, "wrote "+out)
+	code, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "Example Corp")
+
+	_, hit = generate("-force")
+	assert.False(t, hit, "-force always generates")
+
+	t.Setenv(cacheEnv, "off")
+	_, hit = generate()
+	assert.False(t, hit)
+}
+
+func TestRun_GenerateCache_Templates(t *testing.T) {
+	t.Setenv(cacheEnv, t.TempDir())
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+	overrides := t.TempDir()
+	override := filepath.Join(overrides, "audit.tmpl")
+	require.NoError(t, os.WriteFile(override, []byte(`{{define "extra_machine_methods"}}
+// Audit is added by the override
+{{end}}`), 0o600))
+
+	for i, want := range []bool{false, true} {
+		code, _, stderr := runCLI("-vv", "-template-overrides", overrides, spec)
+		require.Equal(t, 0, code, stderr)
+		assert.Equal(t, want, strings.Contains(stderr, "build cache hit"), "run %d", i)
+	}
+
+	require.NoError(t, os.WriteFile(override, []byte(`{{define "extra_machine_methods"}}
+// Audited is added by the override
+{{end}}`), 0o600))
+	code, _, stderr := runCLI("-vv", "-template-overrides", overrides, spec)
+	require.Equal(t, 0, code, stderr)
+	assert.NotContains(t, stderr, "build cache hit", "the templates changed")
+	generated, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(generated), "// Audited is added by the override")
+}
+
+func TestRun_GenerateToStdout(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, stdout, stderr := runCLI("-out=-", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.True(t, strings.HasPrefix(stdout, generator.GeneratedMarker+"\n"))
+	assert.Contains(t, stdout, "package security\n")
+	assert.NoFileExists(t, filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+	code, _, stderr = runCLI("-package=security", "-out", out, spec)
+	require.Equal(t, 0, code, stderr)
+	written, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, string(written), stdout, "the streamed code must match the written file")
+
+	code, _, stderr = runCLI("-out=-", "-gen-tests", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "-out=- writes only the machine code")
+
+	code, stdout, stderr = runCLI("export", "csv", "-out=-", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "state,lock,unlock\nlocked,,unlocked\nunlocked,locked,\n", stdout)
+}
+
+func TestRun_Simulate(t *testing.T) {
+	spec := writeSpec(t, `
+machine:
+  name: Order
+  initial: pending
+states:
+  - name: pending
+    exit: logExit
+  - name: approved
+  - name: shipped
+    final: true
+events: [approve, ship]
+transitions:
+  - {from: pending, to: approved, on: approve, guard: hasPayment, action: chargeCard}
+  - {from: approved, to: shipped, on: ship}
+`)
+	defer func(old io.Reader) { stdin = old }(stdin)
+	stdin = strings.NewReader("events\nship\napprove\nmaybe\nn\napprove\ny\nship\ntrace\nreset\nquit\n")
+
+	code, stdout, stderr := runCLI("simulate", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, `Simulating Order from state pending. Type an event to fire it, or "help".
+pending>   approve -> approved [hasPayment]
+pending> error: event "ship" is not permitted in state "pending"
+pending> does guard hasPayment pass? [y/n] does guard hasPayment pass? [y/n] error: event "approve" is rejected in state "pending": guard "hasPayment" failed
+pending> does guard hasPayment pass? [y/n] pending --approve--> approved
+  exit action logExit
+  action chargeCard
+approved> approved --ship--> shipped
+shipped (final)> 1. pending --approve [hasPayment]--> approved
+2. approved --ship--> shipped
+shipped (final)> back in pending
+pending> `, stdout)
+}
+
+func TestRun_SimulateAssumeGuards(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	defer func(old io.Reader) { stdin = old }(stdin)
+	stdin = strings.NewReader("unlock\n")
+
+	code, stdout, stderr := runCLI("simulate", "-assume-guards", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "locked> locked --unlock--> unlocked\nunlocked> \n")
+}
+
+func TestRun_SimulateScript(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	script := filepath.Join(filepath.Dir(spec), "door.scenarios.yaml")
+	require.NoError(t, os.WriteFile(script, []byte(`
+scenarios:
+  - name: unlock and relock
+    steps:
+      - {fire: unlock, expect: unlocked}
+      - {fire: lock, expect: locked}
+  - name: double unlock
+    steps:
+      - {fire: unlock, expect: unlocked}
+      - {fire: unlock, expect: unlocked}
+`), 0o600))
+
+	code, stdout, stderr := runCLI("simulate", "-script", script, spec)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, `ok   scenario "unlock and relock"`+"\n"+
+		`FAIL scenario "double unlock": step 2 (unlock): event "unlock" is not permitted in state "unlocked"`+"\n", stdout)
+	assert.Contains(t, stderr, "1 of 2 scenario(s) failed")
+}
+
+func TestRun_Path(t *testing.T) {
+	spec := writeSpec(t, `
+machine:
+  name: Order
+  initial: pending
+states: [{name: pending}, {name: review}, {name: approved}, {name: shipped}]
+events: [approve, flag, clear, ship]
+transitions:
+  - {from: pending, to: approved, on: approve, guard: hasPayment}
+  - {from: pending, to: review, on: flag}
+  - {from: review, to: approved, on: clear}
+  - {from: approved, to: shipped, on: ship}
+`)
+
+	code, stdout, stderr := runCLI("path", "-to", "shipped", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "pending --approve [hasPayment]--> approved --ship--> shipped\n", stdout)
+
+	code, stdout, stderr = runCLI("path", "-from", "review", "-to", "shipped", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "review --clear--> approved --ship--> shipped\n", stdout)
+
+	code, stdout, stderr = runCLI("path", "-all", "-to", "shipped", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "pending --approve [hasPayment]--> approved --ship--> shipped\n"+
+		"pending --flag--> review --clear--> approved --ship--> shipped\n", stdout)
+
+	code, stdout, _ = runCLI("path", "-all", "-max", "2", "-to", "shipped", spec)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "pending --approve [hasPayment]--> approved --ship--> shipped\n", stdout)
+
+	code, _, stderr = runCLI("path", "-from", "shipped", "-to", "pending", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "no path from shipped to pending")
+
+	code, _, stderr = runCLI("path", spec)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "must specify -to")
+}
+
+func TestRun_Stats(t *testing.T) {
+	spec := writeSpec(t, `
+machine:
+  name: Order
+  initial: pending
+states: [{name: pending}, {name: review}, {name: approved}, {name: shipped, final: true}, {name: archived}]
+events: [approve, flag, clear, ship]
+transitions:
+  - {from: pending, to: review, on: flag}
+  - {from: review, to: pending, on: clear}
+  - {from: pending, to: approved, on: approve, guard: hasPayment}
+  - {from: approved, to: shipped, on: ship}
+`)
+
+	code, stdout, stderr := runCLI("stats", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, `machine      Order
+states       5 (1 final, 1 unreachable)
+events       4
+transitions  4 (1 guarded)
+components   4 (1 cyclic)
+phases       3
+
+components in topological order:
+  0: archived
+  1: pending, review (cyclic) -> 2
+  2: approved -> 3
+  3: shipped
+`, stdout)
+
+	code, stdout, stderr = runCLI("stats", "-format", "json", spec)
+	require.Equal(t, 0, code, stderr)
+	var stats specStats
+	require.NoError(t, json.Unmarshal([]byte(stdout), &stats))
+	assert.Equal(t, 3, stats.Phases)
+	assert.Equal(t, componentStats{States: []string{"pending", "review"}, Cyclic: true, Next: []int{2}}, stats.Components[1])
+
+	code, _, stderr = runCLI("stats", "-format", "yaml", spec)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown -format "yaml"`)
+}
+
+func TestRun_Diff(t *testing.T) {
+	old := writeSpec(t, `
+machine:
+  name: Order
+  initial: pending
+states: [{name: pending}, {name: review}, {name: approved}, {name: shipped}]
+events: [approve, flag, ship]
+transitions:
+  - {from: pending, to: approved, on: approve, guard: hasPayment}
+  - {from: pending, to: review, on: flag}
+  - {from: review, to: approved, on: approve}
+  - {from: approved, to: shipped, on: ship}
+`)
+	updated := writeSpec(t, `
+machine:
+  name: Order
+  initial: pending
+states: [{name: pending}, {name: approved}, {name: shipped, entry: notifyCustomer}, {name: cancelled, final: true}]
+events: [approve, ship, cancel]
+transitions:
+  - {from: pending, to: approved, on: approve, guard: hasPayment, action: chargeCard}
+  - {from: approved, to: shipped, on: ship}
+  - {from: pending, to: cancelled, on: cancel}
+`)
+
+	code, stdout, stderr := runCLI("diff", old, updated)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, `Order:
++ state cancelled
+- state review
+~ state shipped (entry action none -> notifyCustomer)
++ event cancel
+- event flag
+~ transition pending --approve [hasPayment]--> approved (action none -> chargeCard)
++ transition pending --cancel--> cancelled
+- transition pending --flag--> review
+- transition review --approve--> approved
+`, stdout)
+
+	code, stdout, stderr = runCLI("diff", "-format", "dot", "-detailed-exitcode", old, updated)
+	require.Equal(t, 2, code, stderr)
+	assert.Contains(t, stdout, `  "cancelled" [peripheries="2", color="#38761d", fontcolor="#38761d", penwidth="2"];`)
+	assert.Contains(t, stdout, `  "pending" -> "review" [label="flag", style="dashed", color="#cc0000", fontcolor="#cc0000"];`)
+
+	code, stdout, _ = runCLI("diff", "-detailed-exitcode", old, old)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "Order: no changes\n", stdout)
+
+	code, _, stderr = runCLI("diff", old)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "must specify the old and the new spec")
+
+	code, _, stderr = runCLI("diff", "-format", "svg", old, updated)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown -format "svg"`)
+}
+
+func TestRun_Compat(t *testing.T) {
+	old := writeSpec(t, `
+machine: {name: Order, initial: pending}
+states: [{name: pending}, {name: review}, {name: approved}, {name: shipped}]
+events: [approve, flag, ship]
+transitions:
+  - {from: pending, to: review, on: flag}
+  - {from: review, to: approved, on: approve}
+  - {from: approved, to: shipped, on: ship}
+`)
+	updated := writeSpec(t, `
+machine: {name: Order, initial: pending}
+states: [{name: pending}, {name: in_review}, {name: approved}, {name: shipped}]
+events: [approve, flag, ship]
+transitions:
+  - {from: pending, to: in_review, on: flag}
+  - {from: in_review, to: approved, on: approve}
+  - {from: approved, to: shipped, on: ship}
+`)
+
+	code, stdout, stderr := runCLI("compat", old, updated)
+	require.Equal(t, 1, code, stderr)
+	assert.Equal(t, `Order: 1 change(s) break persisted states
+  state review (value 2) was removed; persisted "review" no longer loads and 2 now loads as in_review (renamed to in_review?)
+
+suggested migration (old -> new):
+  review (2) -> in_review (2)
+`, stdout)
+
+	code, stdout, _ = runCLI("compat", "-format", "json", old, updated)
+	assert.Equal(t, 1, code)
+	var report model.CompatReport
+	require.NoError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, []model.StateMigration{{From: "review", FromValue: 2, To: "in_review", ToValue: 2}}, report.Migrations)
+
+	code, stdout, stderr = runCLI("compat", old, old)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "Order: persisted states stay compatible\n", stdout)
+
+	code, _, stderr = runCLI("compat", old)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "must specify the old and the new spec")
+}
+
+func TestRun_RenameState(t *testing.T) {
+	spec := writeSpec(t, `# Order lifecycle
+machine:
+  name: Order
+  initial: pending # where orders start
+states:
+  - name: pending
+  - name: approved   # paid
+events: [approve]
+transitions:
+  - {from: pending, to: approved, on: approve}
+`)
+
+	code, stdout, stderr := runCLI("rename-state", "-dry-run", "approved", "accepted", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "-  - name: approved   # paid\n+  - name: accepted   # paid\n")
+	src, err := os.ReadFile(spec)
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "approved", "-dry-run does not write")
+
+	code, stdout, stderr = runCLI("rename-state", "approved", "accepted", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "rewrote "+spec+"\n", stdout)
+	src, err = os.ReadFile(spec)
+	require.NoError(t, err)
+	assert.Equal(t, `# Order lifecycle
+machine:
+  name: Order
+  initial: pending # where orders start
+states:
+  - name: pending
+  - name: accepted   # paid
+events: [approve]
+transitions:
+  - {from: pending, to: accepted, on: approve}
+`, string(src))
+
+	code, _, stderr = runCLI("rename-event", "approve", "pay", "-spec", spec)
+	require.Equal(t, 0, code, stderr)
+	src, err = os.ReadFile(spec)
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "events: [pay]\n")
+	assert.Contains(t, string(src), "  - {from: pending, to: accepted, on: pay}\n")
+
+	code, _, stderr = runCLI("rename-state", "accepted", "pending", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `state "pending" is already defined`)
+
+	code, _, stderr = runCLI("rename-state", "accepted", "-spec", spec)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "must specify old and new")
+}
+
+func TestRun_RemoveState(t *testing.T) {
+	spec := writeSpec(t, `machine: {name: Order, initial: pending}
+states: [{name: pending}, {name: review}, {name: approved}]
+events: [approve, flag]
+transitions:
+  - {from: pending, to: approved, on: approve}
+  - {from: pending, to: review, on: flag}
+  - {from: review, to: approved, on: approve}
+`)
+
+	code, _, stderr := runCLI("remove-state", "pending", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `state "pending" is still referenced by machine (line 1)`)
+
+	code, stdout, stderr := runCLI("remove-state", "review", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "rewrote "+spec+"\n", stdout)
+	src, err := os.ReadFile(spec)
+	require.NoError(t, err)
+	assert.Equal(t, `machine: {name: Order, initial: pending}
+states: [{name: pending}, {name: approved}]
+events: [approve, flag]
+transitions:
+  - {from: pending, to: approved, on: approve}
+`, string(src))
+}
+
+func TestRun_RemoveStateKeepsSpecWhenResultIsInvalid(t *testing.T) {
+	content := `machine: {name: Order, initial: pending}
+context: [{name: amount, type: int}]
+guards: [{name: paid, when: amount > 0}]
+states: [{name: pending}, {name: approved}, {name: shipped}]
+events: [approve, ship]
+transitions:
+  - {from: pending, to: approved, on: approve}
+  - {from: approved, to: shipped, on: ship, guard: paid}
+`
+	spec := writeSpec(t, content)
+
+	code, _, stderr := runCLI("remove-state", "shipped", spec)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `the rewritten spec would be invalid: invalid guard: guard "paid" is not used by any transition`)
+
+	src, err := os.ReadFile(spec)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(src))
+}
+
+func TestRun_Coverage(t *testing.T) {
+	spec := writeSpec(t, `
+machine:
+  name: Order
+  initial: pending
+states: [{name: pending}, {name: approved}, {name: shipped, final: true}, {name: rejected, final: true}]
+events: [approve, ship, reject]
+transitions:
+  - {from: pending, to: approved, on: approve, guard: hasPayment}
+  - {from: approved, to: shipped, on: ship}
+  - {from: pending, to: rejected, on: reject}
+`)
+	dir := filepath.Dir(spec)
+	first := filepath.Join(dir, "unit.cov.json")
+	require.NoError(t, os.WriteFile(first, []byte(`{"machine":"Order","transitions":[{"from":"pending","event":"approve","to":"approved","guard":"hasPayment","count":2},{"from":"approved","event":"ship","to":"shipped","count":0},{"from":"pending","event":"reject","to":"rejected","count":0}]}
+{"machine":"Payment","transitions":[{"from":"pending","event":"reject","to":"rejected","count":9}]}
+`), 0o600))
+	second := filepath.Join(dir, "e2e.cov.json")
+	require.NoError(t, os.WriteFile(second, []byte(`{"machine":"Order","transitions":[{"from":"pending","event":"approve","to":"approved","guard":"hasPayment","count":1},{"from":"approved","event":"cancel","to":"rejected","count":1}]}
+`), 0o600))
+
+	code, stdout, stderr := runCLI("coverage", "-spec", spec, first, second)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, `Order: 1/3 transitions covered (33.3%), 2/4 states visited
+
+       3  pending --approve [hasPayment]--> approved
+       0  approved --ship--> shipped
+       0  pending --reject--> rejected
+
+states never visited: rejected, shipped
+
+not in the spec (recorded against an older version?):
+       1  approved --cancel--> rejected
+`, stdout)
+
+	merged := filepath.Join(dir, "merged.cov.json")
+	code, stdout, stderr = runCLI("coverage", "-spec", spec, "-format", "json", "-merge", merged, first, second)
+	require.Equal(t, 0, code, stderr)
+	var report coverage.Report
+	require.NoError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, uint64(3), report.Transitions[0].Count)
+	assert.Len(t, report.Stale, 1)
+
+	content, err := os.ReadFile(merged)
+	require.NoError(t, err)
+	assert.Equal(t, `{"machine":"Order","transitions":[{"from":"pending","event":"approve","to":"approved","guard":"hasPayment","count":3},{"from":"approved","event":"ship","to":"shipped","count":0},{"from":"pending","event":"reject","to":"rejected","count":0},{"from":"approved","event":"cancel","to":"rejected","count":1}]}
+`, string(content))
+
+	code, _, stderr = runCLI("coverage", "-spec", spec, "-min", "50", first)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "33.3% of transitions covered, below -min 50.0%")
+
+	code, _, stderr = runCLI("coverage", "-spec", spec)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "no coverage profiles given")
+
+	code, _, stderr = runCLI("coverage", "-spec", spec, filepath.Join(dir, "missing.json"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "missing.json")
+}
+
+func TestRun_Replay(t *testing.T) {
+	spec := writeSpec(t, `
+machine:
+  name: Order
+  initial: pending
+states: [{name: pending}, {name: approved}, {name: shipped, final: true}, {name: rejected, final: true}]
+events: [approve, ship, reject]
+transitions:
+  - {from: pending, to: approved, on: approve, guard: hasPayment}
+  - {from: approved, to: shipped, on: ship}
+  - {from: pending, to: rejected, on: reject}
+  - {from: approved, to: rejected, on: reject}
+`)
+	dir := filepath.Dir(spec)
+	good := filepath.Join(dir, "good.json")
+	require.NoError(t, os.WriteFile(good, []byte(`{"machine": "Order", "entries": [
+  {"time": "2026-03-01T10:00:00Z", "event": "approve", "from": "pending", "to": "approved"},
+  {"time": "2026-03-01T10:00:01Z", "event": "approve", "from": "approved", "to": "approved", "error": "invalid event approve for state approved"},
+  {"time": "2026-03-01T10:00:02Z", "event": "ship", "from": "approved", "to": "shipped"}
+]}`), 0o600))
+	incident := filepath.Join(dir, "incident.json")
+	require.NoError(t, os.WriteFile(incident, []byte(`{"machine": "Order", "entries": [
+  {"time": "2026-03-02T08:15:00Z", "event": "approve", "payload_hash": "sha256:9f2c", "from": "pending", "to": "shipped"},
+  {"time": "2026-03-02T08:15:01Z", "event": "reject", "from": "approved", "to": "approved", "error": "invalid event reject for state approved"}
+]}`), 0o600))
+
+	code, stdout, stderr := runCLI("replay", "-spec", spec, good)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, "ok   "+good+" (3 events)\n", stdout)
+
+	code, stdout, stderr = runCLI("replay", "-spec", spec, good, incident)
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "ok   "+good+" (3 events)\n"+
+		"FAIL "+incident+" (2 of 2 events)\n"+
+		"     entry 1 (approve): moved pending -> shipped, but the event now leads to approved [recorded 2026-03-02T08:15:00Z, payload sha256:9f2c]\n"+
+		"     entry 2 (reject): was rejected in approved (invalid event reject for state approved), but the event now always moves to rejected [recorded 2026-03-02T08:15:01Z]\n", stdout)
+	assert.Contains(t, stderr, "1 of 2 trace(s) failed")
+
+	other := filepath.Join(dir, "other.json")
+	require.NoError(t, os.WriteFile(other, []byte(`{"machine": "Payment", "entries": []}`), 0o600))
+	code, _, stderr = runCLI("replay", "-spec", spec, other)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "recorded by machine Payment, not Order")
+
+	code, _, stderr = runCLI("replay", "-spec", spec)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "no traces given")
+}
+
+// postEdit posts an edit to the web editor as its page does and returns the status
+// and decoded body
+func postEdit(t *testing.T, editor *specEditor, edit map[string]any) (int, map[string]any) {
+	t.Helper()
+	body, err := json.Marshal(edit)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/edit", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Origin", "http://example.com")
+	req.Header.Set(editorTokenHeader, editor.token)
+	rec := httptest.NewRecorder()
+	editor.ServeHTTP(rec, req)
+	var out map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &out))
+	return rec.Code, out
+}
+
+func TestServe_Editor(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	gen, err := generator.NewCodeGenerator()
+	require.NoError(t, err)
+	editor := newSpecEditor(spec, gen, nil, "example.com")
+
+	rec := httptest.NewRecorder()
+	editor.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<title>gofsm-gen editor</title>")
+	assert.Contains(t, rec.Body.String(), `<meta name="gofsm-token" content="`+editor.token+`">`)
+
+	rec = httptest.NewRecorder()
+	editor.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/diagram", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "DoorLock")
+
+	rec = httptest.NewRecorder()
+	editor.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/spec", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var loaded editorSpec
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loaded))
+	assert.Equal(t, "DoorLock", loaded.Name)
+	assert.Equal(t, []editorState{{Name: "locked", Initial: true}, {Name: "unlocked"}}, loaded.States)
+	assert.Equal(t, []string{"lock", "unlock"}, loaded.Events)
+	assert.Equal(t, editorTransition{From: "locked", To: "unlocked", On: "unlock"}, loaded.Transitions[0])
+	assert.Empty(t, loaded.Findings)
+
+	// Edits are applied in order, each against the version the last returned
+	version := loaded.Version
+	for _, edit := range []map[string]any{
+		{"op": "add-state", "name": "jammed"},
+		{"op": "add-event", "name": "jam"},
+		{"op": "add-transition", "transition": map[string]string{"from": "locked", "to": "jammed", "on": "jam", "guard": "forced"}},
+		{"op": "remove-transition", "index": 1},
+		{"op": "rename-event", "name": "unlock", "new_name": "open"},
+	} {
+		edit["version"] = version
+		code, out := postEdit(t, editor, edit)
+		require.Equal(t, http.StatusOK, code, out)
+		version = out["version"].(string)
+	}
+	content, err := os.ReadFile(spec)
+	require.NoError(t, err)
+	assert.Equal(t, `
+machine:
+  name: DoorLock
+  initial: locked
+
+states:
+  - name: locked
+  - name: unlocked
+  - name: jammed
+
+events:
+  - lock
+  - open
+  - jam
+
+transitions:
+  - from: locked
+    to: unlocked
+    on: open
+  - from: locked
+    to: jammed
+    on: jam
+    guard: forced
+`, string(content))
+
+	code, out := postEdit(t, editor, map[string]any{"op": "add-state", "name": "broken", "version": loaded.Version})
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Contains(t, out["error"], "the spec changed since it was loaded")
+
+	code, out = postEdit(t, editor, map[string]any{"op": "add-transition", "version": version,
+		"transition": map[string]string{"from": "jammed", "to": "nowhere", "on": "lock"}})
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Contains(t, out["error"], "the edited spec would be invalid")
+
+	code, out = postEdit(t, editor, map[string]any{"op": "launch", "version": version})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, `unknown edit "launch"`, out["error"])
+
+	after, err := os.ReadFile(spec)
+	require.NoError(t, err)
+	assert.Equal(t, content, after, "refused edits leave the spec alone")
+}
+
+func TestServe_ForeignRequests(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	gen, err := generator.NewCodeGenerator()
+	require.NoError(t, err)
+	editor := newSpecEditor(spec, gen, nil, "devbox:8080")
+	assert.NotEqual(t, editor.token, newSpecEditor(spec, gen, nil, "devbox:8080").token, "every session has its own token")
+
+	for host, served := range map[string]bool{
+		"devbox:8080":     true,
+		"localhost:8080":  true,
+		"127.0.0.1:8080":  true,
+		"[::1]:8080":      true,
+		"localhost:9090":  false,
+		"evil.test:8080":  false,
+		"devbox.evil.com": false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/spec", nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		editor.ServeHTTP(rec, req)
+		if served {
+			assert.Equal(t, http.StatusOK, rec.Code, host)
+		} else {
+			assert.Equal(t, http.StatusMisdirectedRequest, rec.Code, "%s may be a rebound DNS name", host)
+		}
+	}
+
+	src, err := os.ReadFile(spec)
+	require.NoError(t, err)
+	body := `{"op": "add-state", "name": "jammed", "version": "` + specVersion(src) + `"}`
+	for _, tt := range []struct {
+		name        string
+		origin      string
+		contentType string
+		token       string
+		status      int
+		err         string
+	}{
+		{"other origin", "https://evil.test", "application/json", editor.token, http.StatusForbidden, "edits from https://evil.test are not allowed"},
+		{"form post", "", "text/plain", editor.token, http.StatusUnsupportedMediaType, "edits must be application/json"},
+		{"no token", "http://localhost:8080", "application/json", "", http.StatusForbidden, "missing or wrong editor token"},
+		{"wrong token", "", "application/json; charset=utf-8", "guess", http.StatusForbidden, "missing or wrong editor token"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/edit", strings.NewReader(body))
+		req.Host = "localhost:8080"
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		req.Header.Set("Content-Type", tt.contentType)
+		req.Header.Set(editorTokenHeader, tt.token)
+		rec := httptest.NewRecorder()
+		editor.ServeHTTP(rec, req)
+		assert.Equal(t, tt.status, rec.Code, tt.name)
+		assert.Contains(t, rec.Body.String(), tt.err, tt.name)
+	}
+	after, err := os.ReadFile(spec)
+	require.NoError(t, err)
+	assert.Equal(t, src, after, "refused edits leave the spec alone")
+}
+
+func TestServe_InvalidSpec(t *testing.T) {
+	spec := writeSpec(t, strings.Replace(doorSpec, "initial: locked", "initial: open", 1))
+	gen, err := generator.NewCodeGenerator()
+	require.NoError(t, err)
+	editor := newSpecEditor(spec, gen, nil, "example.com")
+
+	rec := httptest.NewRecorder()
+	editor.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/diagram", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	rec = httptest.NewRecorder()
+	editor.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/spec", nil))
+	var loaded editorSpec
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &loaded))
+	require.Len(t, loaded.Findings, 1)
+	assert.Equal(t, invalidSpecRule, loaded.Findings[0].Rule)
+	assert.Equal(t, `initial state "open" is not defined`, loaded.Error)
+	assert.Empty(t, loaded.States)
+
+	code, stdout, stderr := runCLI("serve")
+	assert.Equal(t, 2, code, stdout)
+	assert.Contains(t, stderr, "gofsm-gen serve: must specify exactly one spec")
+}
+
+// lspFrame returns a language server message framed by its Content-Length header
+func lspFrame(t *testing.T, id int, method string, params any) string {
+	t.Helper()
+	msg := map[string]any{"jsonrpc": "2.0", "method": method, "params": params}
+	if id > 0 {
+		msg["id"] = id
+	}
+	body, err := json.Marshal(msg)
+	require.NoError(t, err)
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// lspMessages splits the output of a language server session into its messages
+func lspMessages(t *testing.T, out string) []map[string]any {
+	t.Helper()
+	var msgs []map[string]any
+	for out != "" {
+		header, rest, ok := strings.Cut(out, "\r\n\r\n")
+		require.True(t, ok, out)
+		length, err := strconv.Atoi(strings.TrimPrefix(header, "Content-Length: "))
+		require.NoError(t, err)
+		var msg map[string]any
+		require.NoError(t, json.Unmarshal([]byte(rest[:length]), &msg))
+		msgs = append(msgs, msg)
+		out = rest[length:]
+	}
+	return msgs
+}
+
+func TestRun_LSP(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	uri := "file://" + filepath.ToSlash(spec)
+	text := strings.Replace(doorSpec, "  - name: unlocked\n", "  - name: unlocked\n  - name: jammed\n", 1)
+	doc := map[string]any{"uri": uri}
+	at := func(line, character int) map[string]any {
+		return map[string]any{"textDocument": doc, "position": map[string]int{"line": line, "character": character}}
+	}
+
+	defer func(old io.Reader) { stdin = old }(stdin)
+	stdin = strings.NewReader(lspFrame(t, 1, "initialize", map[string]any{}) +
+		lspFrame(t, 0, "initialized", map[string]any{}) +
+		lspFrame(t, 0, "textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "text": text}}) +
+		lspFrame(t, 2, "textDocument/definition", at(16, 8)) +
+		lspFrame(t, 3, "textDocument/hover", at(7, 11)) +
+		lspFrame(t, 4, "textDocument/completion", at(17, 8)) +
+		lspFrame(t, 5, "textDocument/completion", at(12, 4)) +
+		lspFrame(t, 6, "workspace/symbol", map[string]any{}) +
+		lspFrame(t, 0, "textDocument/didClose", map[string]any{"textDocument": doc}) +
+		lspFrame(t, 7, "shutdown", nil) +
+		lspFrame(t, 0, "exit", nil))
+
+	code, stdout, stderr := runCLI("lsp")
+	require.Equal(t, 0, code, stderr)
+	msgs := lspMessages(t, stdout)
+	require.Len(t, msgs, 9)
+
+	capabilities := msgs[0]["result"].(map[string]any)["capabilities"].(map[string]any)
+	assert.Equal(t, true, capabilities["definitionProvider"])
+
+	assert.Equal(t, "textDocument/publishDiagnostics", msgs[1]["method"])
+	diagnostics := msgs[1]["params"].(map[string]any)["diagnostics"].([]any)
+	require.NotEmpty(t, diagnostics)
+	for _, d := range diagnostics {
+		assert.Equal(t, map[string]any{
+			"start": map[string]any{"line": 8.0, "character": 10.0},
+			"end":   map[string]any{"line": 8.0, "character": 16.0},
+		}, d.(map[string]any)["range"], "findings about jammed point at its declaration")
+	}
+
+	// From "to: unlocked" in the first transition to the state
+	assert.Equal(t, map[string]any{"uri": uri, "range": map[string]any{
+		"start": map[string]any{"line": 7.0, "character": 10.0},
+		"end":   map[string]any{"line": 7.0, "character": 18.0},
+	}}, msgs[2]["result"])
+
+	hover := msgs[3]["result"].(map[string]any)["contents"].(map[string]any)["value"]
+	assert.Equal(t, "**state** `unlocked`\n\nTransitions from it:\n\n- `unlocked` → `locked` on `lock`"+
+		"\n\nTransitions to it:\n\n- `locked` → `unlocked` on `unlock`", hover)
+
+	assert.Equal(t, []any{
+		map[string]any{"label": "lock", "kind": 23.0, "detail": "event"},
+		map[string]any{"label": "unlock", "kind": 23.0, "detail": "event"},
+	}, msgs[4]["result"], "completes events after on")
+	assert.Len(t, msgs[5]["result"], 0, "nothing completes a list item")
+
+	assert.Equal(t, -32601.0, msgs[6]["error"].(map[string]any)["code"])
+	assert.Equal(t, map[string]any{"uri": uri, "diagnostics": []any{}}, msgs[7]["params"], "closing clears diagnostics")
+	assert.Nil(t, msgs[8]["result"])
+	assert.Contains(t, msgs[8], "result")
+}
+
+func TestRun_LSPExitWithoutShutdown(t *testing.T) {
+	defer func(old io.Reader) { stdin = old }(stdin)
+	stdin = strings.NewReader(lspFrame(t, 0, "exit", nil))
+	code, _, _ := runCLI("lsp")
+	assert.Equal(t, 1, code)
+
+	stdin = strings.NewReader("Content-Length: nope\r\n\r\n")
+	code, _, stderr := runCLI("lsp")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, `gofsm-gen lsp: invalid Content-Length "nope"`)
+}
+
+func TestRun_HelpListsCommands(t *testing.T) {
+	code, stdout, _ := runCLI("help")
+	require.Equal(t, 0, code)
+	for _, c := range commands {
+		assert.Contains(t, stdout, " "+c.name+" ")
+	}
+	assert.Contains(t, stdout, "gofsm-gen [generate] [flags] [spec]")
+}
+
+func TestRun_GenerateByName(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, stdout, stderr := runCLI("generate", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"))
+}
+
+func TestRun_Completion(t *testing.T) {
+	code, stdout, stderr := runCLI("completion", "bash")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "complete -o default -F _gofsm_gen gofsm-gen")
+	assert.Contains(t, stdout, "-detailed-exitcode", "Command flags should be completed")
+	assert.Contains(t, stdout, "operands='csv dot html markdown openapi proto sql tla typescript'")
+
+	code, stdout, stderr = runCLI("completion", "zsh")
+	require.Equal(t, 0, code, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "#compdef gofsm-gen\n"))
+	assert.Contains(t, stdout, `'*-spec[FSM specification file (YAML); may be repeated]:value:_files'`)
+	assert.Contains(t, stdout, `'-all[print every path that visits no state twice instead of the shortest]'`)
+
+	code, stdout, stderr = runCLI("completion", "fish")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "complete -c gofsm-gen -n __fish_use_subcommand -a path -d 'print the shortest event sequence to a state'")
+	assert.Contains(t, stdout, "complete -c gofsm-gen -n 'test (__gofsm_gen_command) = path' -o to -r -d 'state to reach'")
+
+	code, _, stderr = runCLI("completion", "powershell")
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown shell "powershell" (use bash, fish, zsh)`)
+}
+
+func TestRun_Man(t *testing.T) {
+	dir := t.TempDir()
+
+	code, stdout, stderr := runCLI("man", "-dir", dir)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "wrote "+filepath.Join(dir, "gofsm-gen.1"))
+
+	index, err := os.ReadFile(filepath.Join(dir, "gofsm-gen.1"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), ".TP\n\\fBgofsm\\-gen\\-validate\\fP(1)\ncheck specs for errors and lint findings\n")
+
+	page, err := os.ReadFile(filepath.Join(dir, "gofsm-gen-validate.1"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), ".SH SYNOPSIS\n\\fBgofsm\\-gen validate\\fP [flags] [spec]\n")
+	assert.Contains(t, string(page), ".TP\n\\fB\\-format\\fP \\fIstring\\fP\noutput format: text, json, or sarif (default text)\n")
+
+	page, err = os.ReadFile(filepath.Join(dir, "gofsm-gen-export.1"))
+	require.NoError(t, err)
+	assert.Contains(t, string(page), "\\fB\\-bom\\fP", "Export pages should cover the flags of every format")
+
+	code, stdout, _ = runCLI("man", "-dir", dir)
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, "unchanged "+filepath.Join(dir, "gofsm-gen.1"))
+}
+
+func TestRun_Verbosity(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+	out := filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go")
+
+	code, stdout, stderr := runCLI("-v", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, `level=INFO msg="parsed spec" spec=`+spec+" machine=DoorLock states=2 events=2 transitions=2")
+	assert.NotContains(t, stderr, "level=DEBUG")
+	assert.Contains(t, stdout, "wrote "+out)
+
+	code, _, stderr = runCLI("-vv", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stderr, `level=DEBUG msg="phase done" phase=render`)
+	assert.Contains(t, stderr, "emit=machine")
+
+	code, stdout, stderr = runCLI("-quiet", "-force", spec)
+	require.Equal(t, 0, code, stderr)
+	assert.Empty(t, stdout)
+	assert.Empty(t, stderr)
+
+	code, _, stderr = runCLI("-quiet", "-v", spec)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, "-quiet cannot be combined with -v or -vv")
+}
+
+func TestRun_LogFormatJSON(t *testing.T) {
+	spec := writeSpec(t, doorSpec)
+
+	code, stdout, stderr := runCLI("-v", "-log-format", "json", spec)
+	require.Equal(t, 0, code, stderr)
+
+	var report struct {
+		Action string `json:"action"`
+		Path   string `json:"path"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(stdout), &report))
+	assert.Equal(t, "wrote", report.Action)
+	assert.Equal(t, filepath.Join(filepath.Dir(spec), "door_lock_fsm.gen.go"), report.Path)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal([]byte(strings.SplitN(stderr, "\n", 2)[0]), &record))
+	assert.Equal(t, "parsed spec", record["msg"])
+	assert.Equal(t, float64(2), record["states"])
+
+	code, _, stderr = runCLI("validate", "-log-format", "xml", spec)
+	assert.Equal(t, 2, code)
+	assert.Contains(t, stderr, `unknown -log-format "xml" (use text or json)`)
+}
+
+func TestRun_Version(t *testing.T) {
+	for _, arg := range []string{"version", "--version"} {
+		code, stdout, _ := runCLI(arg)
+		require.Equal(t, 0, code)
+		assert.True(t, strings.HasPrefix(stdout, "gofsm-gen "+generator.Version), stdout)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// pathCommand implements "gofsm-gen path": it prints the shortest event sequence
+// between two states, or every simple path up to a length with -all
+func pathCommand(fs *flag.FlagSet) commandFunc {
+	var specs specList
+	fs.Var(&specs, "spec", "FSM specification file (YAML)")
+	from := fs.String("from", "", "state to start from (default: the initial state)")
+	to := fs.String("to", "", "state to reach")
+	all := fs.Bool("all", false, "print every path that visits no state twice instead of the shortest")
+	maxLen := fs.Int("max", 0, "longest path printed with -all, in transitions (default: the number of states)")
+	return func(args []string, stdout, stderr io.Writer) int {
+		fsm, err := loadSingleSpec(append(specs, args...))
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen path: %v\n", err)
+			return 1
+		}
+		if *from == "" {
+			*from = fsm.Initial
+		}
+		for _, state := range []string{*from, *to} {
+			if fsm.GetState(state) == nil {
+				if state == "" {
+					fmt.Fprintf(stderr, "gofsm-gen path: must specify -to\n")
+					return 2
+				}
+				fmt.Fprintf(stderr, "gofsm-gen path: state %q is not defined\n", state)
+				return 1
+			}
+		}
+
+		graph := model.NewStateGraph(fsm)
+		if err := graph.Build(); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen path: %v\n", err)
+			return 1
+		}
+
+		var paths [][]*model.Transition
+		if *all {
+			if *maxLen <= 0 {
+				*maxLen = len(fsm.States)
+			}
+			paths = graph.SimplePaths(*from, *to, *maxLen)
+		} else if path := graph.ShortestPath(*from, *to); path != nil {
+			paths = append(paths, path)
+		}
+
+		if len(paths) == 0 {
+			fmt.Fprintf(stderr, "gofsm-gen path: no path from %s to %s\n", *from, *to)
+			return 1
+		}
+		for _, path := range paths {
+			fmt.Fprintln(stdout, model.FormatPath(*from, path))
+		}
+		return 0
+	}
+}
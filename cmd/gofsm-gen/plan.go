@@ -0,0 +1,59 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+)
+
+// planCommand implements "gofsm-gen plan": it renders every spec and reports the
+// file and API-surface changes generation would make, without writing anything.
+func planCommand(fs *flag.FlagSet) commandFunc {
+	var flags generateFlags
+	flags.register(fs)
+	detailed := fs.Bool("detailed-exitcode", false, "exit with status 2 when the plan contains changes")
+	return func(args []string, stdout, stderr io.Writer) int {
+		args, err := flags.anchored(fs, args)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen plan: %v\n", err)
+			return 1
+		}
+		jobs, err := flags.loadJobs(args)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen plan: %v\n", err)
+			return 1
+		}
+
+		files, err := flags.render(jobs)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen plan: %v\n", err)
+			return 1
+		}
+
+		var stale []string
+		if flags.prune {
+			if stale, err = staleFiles(files); err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen plan: %v\n", err)
+				return 1
+			}
+		}
+
+		plan, err := generator.NewPlan(files, stale)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen plan: %v\n", err)
+			return 1
+		}
+
+		if err := plan.Render(stdout); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen plan: %v\n", err)
+			return 1
+		}
+
+		if *detailed && plan.HasChanges() {
+			return 2
+		}
+		return 0
+	}
+}
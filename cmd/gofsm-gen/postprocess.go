@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+)
+
+// filePlaceholder is replaced by the path of the processed file in post_process
+// commands; commands without it get the path as their last argument
+const filePlaceholder = "{file}"
+
+// postProcessor runs the post_process commands of a configuration
+type postProcessor struct {
+	commands []string
+
+	// dir is the directory the commands run in
+	dir string
+}
+
+// postProcessorOf returns the post-processor configured by c
+func postProcessorOf(c config) postProcessor {
+	return postProcessor{commands: c.PostProcess, dir: c.postProcessDir}
+}
+
+// process replaces the content of the Go files among files with their content
+// after the commands ran on it. The commands run on a copy beside each file, so
+// that a file on disk is only written, like any other, if its processed content
+// changed, and so that -dry-run can show the result.
+func (p postProcessor) process(files []generator.PlannedFile) error {
+	if len(p.commands) == 0 {
+		return nil
+	}
+	for i, f := range files {
+		if filepath.Ext(f.Path) != ".go" {
+			continue
+		}
+		content, err := p.processFile(f)
+		if err != nil {
+			return err
+		}
+		files[i].Content = content
+	}
+	return nil
+}
+
+// processFile runs the commands on a copy of f and returns the processed content,
+// with its checksum directive updated to record it
+func (p postProcessor) processFile(f generator.PlannedFile) ([]byte, error) {
+	// The copy keeps the name of the file after a prefix hiding it from the go
+	// tool, and stays in the package of the file when its directory exists, for
+	// tools such as gci that look at the module around the file
+	dir := filepath.Dir(f.Path)
+	if _, err := os.Stat(dir); err != nil {
+		dir = ""
+	}
+	tmp, err := os.CreateTemp(dir, ".gofsm-gen-*-"+filepath.Base(f.Path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(f.Content); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, command := range p.commands {
+		args := strings.Fields(command)
+		substituted := false
+		for i, arg := range args {
+			if strings.Contains(arg, filePlaceholder) {
+				args[i] = strings.ReplaceAll(arg, filePlaceholder, path)
+				substituted = true
+			}
+		}
+		if !substituted {
+			args = append(args, path)
+		}
+
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Dir = p.dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			report := strings.ReplaceAll(strings.TrimSpace(string(out)), path, f.Path)
+			if report != "" {
+				report = "\n" + report
+			}
+			return nil, fmt.Errorf("post_process %q on %s: %w%s", command, f.Path, err, report)
+		}
+		logger.Debug("post-processed", "file", f.Path, "command", command)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return generator.Reseal(content), nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+// specRewrite rewrites the source of a spec for the operands of a refactoring
+// command and returns log attributes describing what it changed
+type specRewrite func(src []byte, operands []string) ([]byte, []any, error)
+
+// refactorCommand returns the setup of a command that rewrites a spec in place.
+// The rewritten spec must still be valid; it replaces the original in one rename,
+// so the file never holds a partial rewrite.
+func refactorCommand(operands []string, rewrite specRewrite) commandSetup {
+	return func(fs *flag.FlagSet) commandFunc {
+		var specs specList
+		fs.Var(&specs, "spec", "FSM specification file (YAML) to rewrite")
+		dryRun := fs.Bool("dry-run", false, "print a unified diff of the rewrite without writing")
+		return func(args []string, stdout, stderr io.Writer) int {
+			name := fs.Name()
+			args, err := parseInterspersed(fs, args)
+			if err != nil {
+				return 2
+			}
+			if len(args) < len(operands) {
+				fmt.Fprintf(stderr, "%s: must specify %s\n", name, strings.Join(operands, " and "))
+				return 2
+			}
+			specs = append(specs, args[len(operands):]...)
+			if len(specs) != 1 {
+				fmt.Fprintf(stderr, "%s: must specify exactly one spec\n", name)
+				return 2
+			}
+			path := specs[0]
+
+			src, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", name, err)
+				return 1
+			}
+			out, changes, err := rewrite(src, args[:len(operands)])
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %s: %v\n", name, path, err)
+				return 1
+			}
+
+			fsm, err := parser.NewYAMLParser().Parse(bytes.NewReader(out))
+			if err == nil {
+				err = fsm.Validate()
+			}
+			if err != nil {
+				fmt.Fprintf(stderr, "%s: %s: the rewritten spec would be invalid: %v\n", name, path, err)
+				return 1
+			}
+
+			if *dryRun {
+				diff, err := generator.UnifiedDiff(filepath.ToSlash(path), src, out)
+				if err != nil {
+					fmt.Fprintf(stderr, "%s: %v\n", name, err)
+					return 1
+				}
+				fmt.Fprint(stdout, diff)
+				return 0
+			}
+
+			if err := replaceFile(path, out); err != nil {
+				fmt.Fprintf(stderr, "%s: %v\n", name, err)
+				return 1
+			}
+			logger.Info("rewrote spec", append([]any{"spec", path}, changes...)...)
+			logger.file(stdout, "rewrote", path)
+			return 0
+		}
+	}
+}
+
+// renameStateCommand implements "gofsm-gen rename-state"
+var renameStateCommand = refactorCommand([]string{"old", "new"}, func(src []byte, operands []string) ([]byte, []any, error) {
+	out, n, err := parser.RenameState(src, operands[0], operands[1])
+	return out, []any{"state", operands[0], "renamed_to", operands[1], "references", n}, err
+})
+
+// renameEventCommand implements "gofsm-gen rename-event"
+var renameEventCommand = refactorCommand([]string{"old", "new"}, func(src []byte, operands []string) ([]byte, []any, error) {
+	out, n, err := parser.RenameEvent(src, operands[0], operands[1])
+	return out, []any{"event", operands[0], "renamed_to", operands[1], "references", n}, err
+})
+
+// removeStateCommand implements "gofsm-gen remove-state"
+var removeStateCommand = refactorCommand([]string{"state"}, func(src []byte, operands []string) ([]byte, []any, error) {
+	out, n, err := parser.RemoveState(src, operands[0])
+	return out, []any{"removed_state", operands[0], "removed_transitions", n}, err
+})
+
+// parseInterspersed parses the flags of fs that follow operands, so that
+// "rename-state old new -spec order.yaml" works like the flags came first, and
+// returns the operands
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var operands []string
+	for len(args) > 0 {
+		if !strings.HasPrefix(args[0], "-") || args[0] == "-" {
+			operands = append(operands, args[0])
+			args = args[1:]
+			continue
+		}
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+	}
+	return operands, nil
+}
+
+// replaceFile replaces the content of path at once by renaming a temporary file
+// over it, keeping its permissions
+func replaceFile(path string, content []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/simulator"
+)
+
+// replayCommand implements "gofsm-gen replay": it checks traces exported by the
+// TraceRecorder of a generated machine against a spec, reporting every recorded
+// event the spec would now handle differently
+func replayCommand(fs *flag.FlagSet) commandFunc {
+	var specs specList
+	fs.Var(&specs, "spec", "FSM specification file (YAML) to replay the traces against")
+	return func(args []string, stdout, stderr io.Writer) int {
+		if len(args) == 0 {
+			fmt.Fprintf(stderr, "gofsm-gen replay: no traces given\n")
+			return 2
+		}
+
+		fsm, err := loadSingleSpec(specs)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen replay: %v\n", err)
+			return 1
+		}
+
+		failed := 0
+		for _, path := range args {
+			if !replayTrace(fsm, path, stdout, stderr) {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			fmt.Fprintf(stderr, "gofsm-gen replay: %d of %d trace(s) failed\n", failed, len(args))
+			return 1
+		}
+		return 0
+	}
+}
+
+// replayTrace replays the trace in path against fsm and reports the outcome
+func replayTrace(fsm *model.FSMModel, path string, stdout, stderr io.Writer) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(stderr, "gofsm-gen replay: %v\n", err)
+		return false
+	}
+	defer f.Close()
+
+	trace, err := simulator.ReadTrace(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "gofsm-gen replay: %s: %v\n", path, err)
+		return false
+	}
+	if trace.Machine != fsm.Name {
+		fmt.Fprintf(stderr, "gofsm-gen replay: %s: recorded by machine %s, not %s\n", path, trace.Machine, fsm.Name)
+		return false
+	}
+
+	regressions := trace.Replay(fsm)
+	if len(regressions) == 0 {
+		fmt.Fprintf(stdout, "ok   %s (%d events)\n", path, len(trace.Entries))
+		return true
+	}
+
+	fmt.Fprintf(stdout, "FAIL %s (%d of %d events)\n", path, len(regressions), len(trace.Entries))
+	for _, r := range regressions {
+		entry := trace.Entries[r.Entry-1]
+		line := "     " + r.String()
+		if !entry.Time.IsZero() {
+			line += " [recorded " + entry.Time.Format(time.RFC3339Nano)
+			if entry.PayloadHash != "" {
+				line += ", payload " + entry.PayloadHash
+			}
+			line += "]"
+		}
+		fmt.Fprintln(stdout, line)
+	}
+	return false
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/lint"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+// editorPage is the single page of the web editor; it loads the spec from the
+// API and polls it for changes
+//
+//go:embed serve.html
+var editorPage []byte
+
+// serveCommand implements "gofsm-gen serve": a local web UI showing the live
+// diagram and findings of a spec beside a form-based editor that writes back to
+// it, for collaborators who would rather not edit YAML. Edits go through the
+// same rewrites as the refactoring commands, so comments and formatting are kept.
+func serveCommand(fs *flag.FlagSet) commandFunc {
+	var specs specList
+	fs.Var(&specs, "spec", "FSM specification file (YAML) to edit")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	return func(args []string, stdout, stderr io.Writer) int {
+		specs = append(specs, args...)
+		if len(specs) != 1 {
+			fmt.Fprintln(stderr, "gofsm-gen serve: must specify exactly one spec")
+			return 2
+		}
+		path := specs[0]
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen serve: %v\n", err)
+			return 1
+		}
+
+		config, err := configs{}.get(filepath.Dir(path))
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen serve: %v\n", err)
+			return 1
+		}
+		gen, err := generators{}.get(generatorKey{templates: config.Templates, overrides: config.Overrides, sprig: config.Sprig != nil && *config.Sprig})
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen serve: %v\n", err)
+			return 1
+		}
+
+		ln, err := net.Listen("tcp", *addr)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen serve: %v\n", err)
+			return 1
+		}
+		host, _, _ := net.SplitHostPort(*addr)
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		server := &http.Server{Handler: newSpecEditor(path, gen, config.Lint, net.JoinHostPort(host, port))}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			server.Close()
+		}()
+
+		fmt.Fprintf(stdout, "serving %s at http://%s; press Ctrl-C to stop\n", path, ln.Addr())
+		if err := server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(stderr, "gofsm-gen serve: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// editorTokenHeader carries the session token of the editor with every edit
+const editorTokenHeader = "X-Gofsm-Token"
+
+// specEditor serves the web editor of one spec file
+type specEditor struct {
+	path       string
+	gen        *generator.CodeGenerator
+	severities map[string]lint.Severity
+
+	// addr is the address the editor is served at. Requests must name it, or
+	// localhost or an IP address with its port, as their host, so that pages of
+	// other sites cannot reach the editor by rebinding their DNS names to it.
+	addr string
+
+	// token is the random token of the session, which the page passes with every
+	// edit, so that pages of other sites cannot make edits
+	token string
+
+	// mu serializes edits, each of which reads, rewrites, and replaces the file
+	mu  sync.Mutex
+	mux *http.ServeMux
+}
+
+// editorState is a state as the editor lists it
+type editorState struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Initial     bool   `json:"initial,omitempty"`
+	Final       bool   `json:"final,omitempty"`
+}
+
+// editorTransition is a transition as the editor lists and adds it
+type editorTransition struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	On     string `json:"on"`
+	Guard  string `json:"guard,omitempty"`
+	Action string `json:"action,omitempty"`
+}
+
+// editorSpec is the spec as the editor shows it. Transitions are in spec order,
+// so their indices identify them for removal. Error is set, and the lists are
+// empty, when the spec is invalid.
+type editorSpec struct {
+	Version     string             `json:"version"`
+	Name        string             `json:"name,omitempty"`
+	States      []editorState      `json:"states"`
+	Events      []string           `json:"events"`
+	Transitions []editorTransition `json:"transitions"`
+	Findings    []lint.Finding     `json:"findings"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// editorEdit is an edit the editor posts. Version is that of the spec the edit
+// was made against; an edit of an outdated version is refused, so that changes
+// made to the file meanwhile are not lost.
+type editorEdit struct {
+	Op         string            `json:"op"`
+	Version    string            `json:"version"`
+	Name       string            `json:"name,omitempty"`
+	NewName    string            `json:"new_name,omitempty"`
+	Index      int               `json:"index,omitempty"`
+	Transition *editorTransition `json:"transition,omitempty"`
+}
+
+// editorOps rewrite the source of a spec for an edit and return log attributes
+// describing the change
+var editorOps = map[string]func(src []byte, edit editorEdit) ([]byte, []any, error){
+	"add-state": func(src []byte, edit editorEdit) ([]byte, []any, error) {
+		out, err := parser.AddState(src, edit.Name)
+		return out, []any{"added_state", edit.Name}, err
+	},
+	"rename-state": func(src []byte, edit editorEdit) ([]byte, []any, error) {
+		out, n, err := parser.RenameState(src, edit.Name, edit.NewName)
+		return out, []any{"state", edit.Name, "renamed_to", edit.NewName, "references", n}, err
+	},
+	"remove-state": func(src []byte, edit editorEdit) ([]byte, []any, error) {
+		out, n, err := parser.RemoveState(src, edit.Name)
+		return out, []any{"removed_state", edit.Name, "removed_transitions", n}, err
+	},
+	"add-event": func(src []byte, edit editorEdit) ([]byte, []any, error) {
+		out, err := parser.AddEvent(src, edit.Name)
+		return out, []any{"added_event", edit.Name}, err
+	},
+	"rename-event": func(src []byte, edit editorEdit) ([]byte, []any, error) {
+		out, n, err := parser.RenameEvent(src, edit.Name, edit.NewName)
+		return out, []any{"event", edit.Name, "renamed_to", edit.NewName, "references", n}, err
+	},
+	"add-transition": func(src []byte, edit editorEdit) ([]byte, []any, error) {
+		if edit.Transition == nil {
+			return nil, nil, fmt.Errorf("add-transition needs a transition")
+		}
+		t := edit.Transition
+		out, err := parser.AddTransition(src, &model.Transition{From: t.From, To: t.To, Event: t.On, Guard: t.Guard, Action: t.Action})
+		return out, []any{"added_transition", t.From + " --" + t.On + "--> " + t.To}, err
+	},
+	"remove-transition": func(src []byte, edit editorEdit) ([]byte, []any, error) {
+		out, err := parser.RemoveTransition(src, edit.Index)
+		return out, []any{"removed_transition", edit.Index}, err
+	},
+}
+
+// newSpecEditor returns the handler of the web editor of the spec at path,
+// served at addr, linting it with the given severities
+func newSpecEditor(path string, gen *generator.CodeGenerator, severities map[string]lint.Severity, addr string) *specEditor {
+	e := &specEditor{path: path, gen: gen, severities: severities, addr: addr, token: rand.Text(), mux: http.NewServeMux()}
+	page := bytes.ReplaceAll(editorPage, []byte("{{token}}"), []byte(e.token))
+	e.mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+	e.mux.HandleFunc("GET /diagram", e.serveDiagram)
+	e.mux.HandleFunc("GET /api/spec", e.serveSpec)
+	e.mux.HandleFunc("POST /api/edit", e.serveEdit)
+	return e
+}
+
+func (e *specEditor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !e.servesHost(r.Host) {
+		http.Error(w, fmt.Sprintf("unknown host %q", r.Host), http.StatusMisdirectedRequest)
+		return
+	}
+	e.mux.ServeHTTP(w, r)
+}
+
+// servesHost reports whether host names the editor: its address, or localhost or
+// an IP address with its port. Other names may be those of other sites resolving
+// to the editor.
+func (e *specEditor) servesHost(host string) bool {
+	if host == e.addr {
+		return true
+	}
+	name, port, err := net.SplitHostPort(host)
+	_, editorPort, _ := net.SplitHostPort(e.addr)
+	if err != nil || port != editorPort {
+		return false
+	}
+	return name == "localhost" || net.ParseIP(name) != nil
+}
+
+// checkEditRequest returns the status and error refusing an edit request that
+// may not come from the editor page: one from another origin, one that is not
+// JSON, which pages of other sites can post without asking, or one without the
+// token of the session
+func (e *specEditor) checkEditRequest(r *http.Request) (int, error) {
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+		return http.StatusForbidden, fmt.Errorf("edits from %s are not allowed", origin)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, fmt.Errorf("edits must be application/json")
+	}
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(editorTokenHeader)), []byte(e.token)) != 1 {
+		return http.StatusForbidden, fmt.Errorf("missing or wrong editor token; reload the page")
+	}
+	return 0, nil
+}
+
+// serveDiagram serves the interactive HTML visualization of the spec
+func (e *specEditor) serveDiagram(w http.ResponseWriter, r *http.Request) {
+	src, err := os.ReadFile(e.path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fsm, err := parser.NewYAMLParser().Parse(bytes.NewReader(src))
+	if err == nil {
+		err = fsm.Validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	page, err := e.gen.GenerateHTML(fsm)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+// serveSpec serves the spec as the editor shows it
+func (e *specEditor) serveSpec(w http.ResponseWriter, r *http.Request) {
+	src, err := os.ReadFile(e.path)
+	if err != nil {
+		writeEditorError(w, http.StatusInternalServerError, err)
+		return
+	}
+	spec, err := e.describe(src)
+	if err != nil {
+		writeEditorError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeEditorJSON(w, http.StatusOK, spec)
+}
+
+// serveEdit applies an edit to the spec file and serves the edited spec. The
+// edited spec must still be valid.
+func (e *specEditor) serveEdit(w http.ResponseWriter, r *http.Request) {
+	if status, err := e.checkEditRequest(r); err != nil {
+		writeEditorError(w, status, err)
+		return
+	}
+
+	var edit editorEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil {
+		writeEditorError(w, http.StatusBadRequest, fmt.Errorf("invalid edit: %w", err))
+		return
+	}
+	op, ok := editorOps[edit.Op]
+	if !ok {
+		writeEditorError(w, http.StatusBadRequest, fmt.Errorf("unknown edit %q", edit.Op))
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	src, err := os.ReadFile(e.path)
+	if err != nil {
+		writeEditorError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if edit.Version != specVersion(src) {
+		writeEditorError(w, http.StatusConflict, fmt.Errorf("the spec changed since it was loaded; review the changes and try again"))
+		return
+	}
+
+	out, changes, err := op(src, edit)
+	if err != nil {
+		writeEditorError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	fsm, err := parser.NewYAMLParser().Parse(bytes.NewReader(out))
+	if err == nil {
+		err = fsm.Validate()
+	}
+	if err != nil {
+		writeEditorError(w, http.StatusUnprocessableEntity, fmt.Errorf("the edited spec would be invalid: %w", err))
+		return
+	}
+
+	if err := replaceFile(e.path, out); err != nil {
+		writeEditorError(w, http.StatusInternalServerError, err)
+		return
+	}
+	logger.Info("rewrote spec", append([]any{"spec", e.path}, changes...)...)
+
+	spec, err := e.describe(out)
+	if err != nil {
+		writeEditorError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeEditorJSON(w, http.StatusOK, spec)
+}
+
+// describe returns the spec src as the editor shows it. The error is for
+// invalid lint severities.
+func (e *specEditor) describe(src []byte) (editorSpec, error) {
+	spec := editorSpec{
+		Version:     specVersion(src),
+		States:      []editorState{},
+		Events:      []string{},
+		Transitions: []editorTransition{},
+	}
+	findings, _, err := checkSpec(src, e.severities)
+	if err != nil {
+		return editorSpec{}, err
+	}
+	spec.Findings = append([]lint.Finding{}, findings...)
+
+	fsm, err := parser.NewYAMLParser().Parse(bytes.NewReader(src))
+	if err != nil {
+		spec.Error = err.Error()
+		return spec, nil
+	}
+	spec.Name = fsm.Name
+	for _, state := range fsm.GetStatesSlice() {
+		spec.States = append(spec.States, editorState{
+			Name:        state.Name,
+			Description: state.Description,
+			Initial:     state.Name == fsm.Initial,
+			Final:       state.Final,
+		})
+	}
+	spec.Events = append(spec.Events, fsm.GetEventNames()...)
+	for _, t := range fsm.Transitions {
+		spec.Transitions = append(spec.Transitions, editorTransition{From: t.From, To: t.To, On: t.Event, Guard: t.Guard, Action: t.Action})
+	}
+	return spec, nil
+}
+
+// specVersion identifies a version of a spec's source
+func specVersion(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeEditorJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeEditorError(w http.ResponseWriter, status int, err error) {
+	writeEditorJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+	"github.com/yourusername/gofsm-gen/pkg/simulator"
+)
+
+// stdin is the input of interactive commands
+var stdin io.Reader = os.Stdin
+
+const simulateHelp = `Commands:
+  <event>  fire an event
+  events   list the events permitted in the current state
+  trace    print the transitions taken so far
+  reset    return to the initial state
+  help     show this help
+  quit     leave the simulator
+`
+
+// simulateCommand implements "gofsm-gen simulate": an interactive session that fires
+// events against a spec and shows where the machine goes, without generating code.
+// With -script it plays scenarios from a file instead.
+func simulateCommand(fs *flag.FlagSet) commandFunc {
+	var specs specList
+	fs.Var(&specs, "spec", "FSM specification file (YAML)")
+	assumeGuards := fs.Bool("assume-guards", false, "treat every guard as passing instead of asking")
+	script := fs.String("script", "", "run the scenarios in this YAML file instead of an interactive session")
+	return func(args []string, stdout, stderr io.Writer) int {
+		fsm, err := loadSingleSpec(append(specs, args...))
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen simulate: %v\n", err)
+			return 1
+		}
+
+		if *script != "" {
+			return runScenarios(fsm, *script, stdout, stderr)
+		}
+
+		in := bufio.NewScanner(stdin)
+		guards := simulator.AssumeGuards
+		if !*assumeGuards {
+			guards = askGuard(in, stdout)
+		}
+
+		sim := simulator.New(fsm)
+		fmt.Fprintf(stdout, "Simulating %s from state %s. Type an event to fire it, or \"help\".\n", fsm.Name, sim.State())
+		for {
+			fmt.Fprintf(stdout, "%s> ", prompt(sim))
+			if !in.Scan() {
+				fmt.Fprintln(stdout)
+				return 0
+			}
+
+			switch command := strings.TrimSpace(in.Text()); command {
+			case "":
+			case "help", "?":
+				fmt.Fprint(stdout, simulateHelp)
+			case "quit", "exit":
+				return 0
+			case "events":
+				printPermitted(stdout, sim)
+			case "trace":
+				printTrace(stdout, sim.Trace())
+			case "reset":
+				sim.Reset()
+				fmt.Fprintf(stdout, "back in %s\n", sim.State())
+			default:
+				step, err := sim.Fire(command, guards)
+				if err != nil {
+					fmt.Fprintf(stdout, "error: %v\n", err)
+					continue
+				}
+				printStep(stdout, step)
+			}
+		}
+	}
+}
+
+// runScenarios plays every scenario in the script against fsm, reporting each
+// scenario and the first divergence of those that fail
+func runScenarios(fsm *model.FSMModel, script string, stdout, stderr io.Writer) int {
+	f, err := os.Open(script)
+	if err != nil {
+		fmt.Fprintf(stderr, "gofsm-gen simulate: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	scenarios, err := simulator.ParseScenarios(f)
+	if err != nil {
+		fmt.Fprintf(stderr, "gofsm-gen simulate: %s: %v\n", script, err)
+		return 1
+	}
+
+	failed := 0
+	for _, scenario := range scenarios {
+		if err := scenario.Run(fsm); err != nil {
+			failed++
+			fmt.Fprintf(stdout, "FAIL %v\n", err)
+			continue
+		}
+		fmt.Fprintf(stdout, "ok   scenario %q\n", scenario.Name)
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(stderr, "gofsm-gen simulate: %d of %d scenario(s) failed\n", failed, len(scenarios))
+		return 1
+	}
+	return 0
+}
+
+// loadSingleSpec parses and validates the one spec a command works on
+func loadSingleSpec(specs []string) (*model.FSMModel, error) {
+	if len(specs) != 1 {
+		return nil, fmt.Errorf("must specify exactly one spec")
+	}
+	fsm, err := parser.NewYAMLParser().ParseFile(specs[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := fsm.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", specs[0], err)
+	}
+	logger.parsed(specs[0], fsm)
+	return fsm, nil
+}
+
+// askGuard returns a GuardFunc that asks whether each guard passes
+func askGuard(in *bufio.Scanner, out io.Writer) simulator.GuardFunc {
+	return func(guard string) (bool, error) {
+		for {
+			fmt.Fprintf(out, "does guard %s pass? [y/n] ", guard)
+			if !in.Scan() {
+				return false, errors.New("no answer for guard " + guard)
+			}
+			switch strings.ToLower(strings.TrimSpace(in.Text())) {
+			case "y", "yes":
+				return true, nil
+			case "n", "no":
+				return false, nil
+			}
+		}
+	}
+}
+
+// prompt returns the current state, marked when it is final
+func prompt(sim *simulator.Simulator) string {
+	if sim.IsFinal() {
+		return sim.State() + " (final)"
+	}
+	return sim.State()
+}
+
+// printPermitted lists the events permitted in the current state with their targets
+func printPermitted(w io.Writer, sim *simulator.Simulator) {
+	events := sim.Permitted()
+	if len(events) == 0 {
+		fmt.Fprintf(w, "no events are permitted in %s\n", sim.State())
+		return
+	}
+	for _, event := range events {
+		var targets []string
+		for _, t := range sim.Candidates(event) {
+			if t.Guard != "" {
+				targets = append(targets, t.To+" ["+t.Guard+"]")
+			} else {
+				targets = append(targets, t.To)
+			}
+		}
+		fmt.Fprintf(w, "  %s -> %s\n", event, strings.Join(targets, ", "))
+	}
+}
+
+// printStep describes a transition and the actions it ran, in the order the
+// generated machine runs them
+func printStep(w io.Writer, step simulator.Step) {
+	fmt.Fprintf(w, "%s --%s--> %s\n", step.From, step.Event, step.To)
+	if step.ExitAction != "" {
+		fmt.Fprintf(w, "  exit action %s\n", step.ExitAction)
+	}
+	if step.Action != "" {
+		fmt.Fprintf(w, "  action %s\n", step.Action)
+	}
+	if step.EntryAction != "" {
+		fmt.Fprintf(w, "  entry action %s\n", step.EntryAction)
+	}
+}
+
+// printTrace lists the transitions taken so far
+func printTrace(w io.Writer, trace []simulator.Step) {
+	if len(trace) == 0 {
+		fmt.Fprintln(w, "no transitions yet")
+		return
+	}
+	for i, step := range trace {
+		guard := ""
+		if step.Guard != "" {
+			guard = " [" + step.Guard + "]"
+		}
+		fmt.Fprintf(w, "%d. %s --%s%s--> %s\n", i+1, step.From, step.Event, guard, step.To)
+	}
+}
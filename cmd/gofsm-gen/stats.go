@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// specStats summarizes the size and structure of a machine
+type specStats struct {
+	Machine     string `json:"machine"`
+	States      int    `json:"states"`
+	Final       int    `json:"final"`
+	Unreachable int    `json:"unreachable"`
+	Events      int    `json:"events"`
+	Transitions int    `json:"transitions"`
+	Guarded     int    `json:"guarded"`
+
+	// Phases is the number of components on the longest chain of the condensation
+	Phases int `json:"phases"`
+
+	// Components are the strongly connected components in topological order
+	Components []componentStats `json:"components"`
+}
+
+// componentStats describes a strongly connected component of a machine
+type componentStats struct {
+	States []string `json:"states"`
+	Cyclic bool     `json:"cyclic"`
+
+	// Next are the indices of the components transitions lead to from this one
+	Next []int `json:"next"`
+}
+
+// statsCommand implements "gofsm-gen stats": it prints the size of a machine and
+// its strongly connected components, the phases runs pass through
+func statsCommand(fs *flag.FlagSet) commandFunc {
+	var specs specList
+	fs.Var(&specs, "spec", "FSM specification file (YAML)")
+	format := fs.String("format", "text", "output format: text or json")
+	return func(args []string, stdout, stderr io.Writer) int {
+		if *format != "text" && *format != "json" {
+			fmt.Fprintf(stderr, "gofsm-gen stats: unknown -format %q (use text or json)\n", *format)
+			return 2
+		}
+
+		fsm, err := loadSingleSpec(append(specs, args...))
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen stats: %v\n", err)
+			return 1
+		}
+		graph := model.NewStateGraph(fsm)
+		if err := graph.Build(); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen stats: %v\n", err)
+			return 1
+		}
+		stats := collectStats(fsm, graph)
+
+		if *format == "json" {
+			out, err := json.MarshalIndent(stats, "", "  ")
+			if err != nil {
+				fmt.Fprintf(stderr, "gofsm-gen stats: %v\n", err)
+				return 1
+			}
+			fmt.Fprintf(stdout, "%s\n", out)
+			return 0
+		}
+		writeStats(stdout, stats)
+		return 0
+	}
+}
+
+// collectStats summarizes a machine
+func collectStats(fsm *model.FSMModel, graph *model.StateGraph) specStats {
+	c := graph.Condense()
+	stats := specStats{
+		Machine:     fsm.Name,
+		States:      len(fsm.States),
+		Final:       len(fsm.GetFinalStateNames()),
+		Unreachable: len(fsm.GetUnreachableStateNames()),
+		Events:      len(fsm.Events),
+		Transitions: len(fsm.Transitions),
+		Phases:      c.Depth(),
+	}
+	for _, t := range fsm.Transitions {
+		if t.Guard != "" {
+			stats.Guarded++
+		}
+	}
+	for i, states := range c.Components {
+		stats.Components = append(stats.Components, componentStats{States: states, Cyclic: c.Cyclic[i], Next: c.Edges[i]})
+	}
+	return stats
+}
+
+// writeStats prints stats as aligned text
+func writeStats(w io.Writer, stats specStats) {
+	cyclic := 0
+	for _, component := range stats.Components {
+		if component.Cyclic {
+			cyclic++
+		}
+	}
+
+	fmt.Fprintf(w, "%-12s %s\n", "machine", stats.Machine)
+	fmt.Fprintf(w, "%-12s %d (%d final, %d unreachable)\n", "states", stats.States, stats.Final, stats.Unreachable)
+	fmt.Fprintf(w, "%-12s %d\n", "events", stats.Events)
+	fmt.Fprintf(w, "%-12s %d (%d guarded)\n", "transitions", stats.Transitions, stats.Guarded)
+	fmt.Fprintf(w, "%-12s %d (%d cyclic)\n", "components", len(stats.Components), cyclic)
+	fmt.Fprintf(w, "%-12s %d\n", "phases", stats.Phases)
+
+	fmt.Fprintf(w, "\ncomponents in topological order:\n")
+	for i, component := range stats.Components {
+		line := fmt.Sprintf("  %d: %s", i, strings.Join(component.States, ", "))
+		if component.Cyclic {
+			line += " (cyclic)"
+		}
+		if len(component.Next) > 0 {
+			next := make([]string, len(component.Next))
+			for j, n := range component.Next {
+				next[j] = fmt.Sprint(n)
+			}
+			line += " -> " + strings.Join(next, ", ")
+		}
+		fmt.Fprintln(w, line)
+	}
+}
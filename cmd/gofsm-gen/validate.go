@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/lint"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+// invalidSpecRule is the rule ID of findings for specs that fail to parse or validate
+const invalidSpecRule = "invalid-spec"
+
+// specFinding is a finding in one spec file
+type specFinding struct {
+	File string `json:"file"`
+	lint.Finding
+}
+
+// validateCommand implements "gofsm-gen validate": it checks every spec for errors and
+// lint findings without generating code, and exits with status 1 when a finding
+// is at least as severe as -fail-on.
+func validateCommand(fs *flag.FlagSet) commandFunc {
+	var flags generateFlags
+	fs.Var(&flags.specs, "spec", "FSM specification file (YAML); may be repeated")
+	fs.StringVar(&flags.pattern, "pattern", defaultSpecPattern, "file name pattern of the specs found by dir/... arguments")
+	format := fs.String("format", "text", "output format: text, json, or sarif")
+	failOn := fs.String("fail-on", string(lint.SeverityError), "lowest severity that fails validation: error or warning")
+	return func(args []string, stdout, stderr io.Writer) int {
+		report, ok := validateReports[*format]
+		if !ok {
+			fmt.Fprintf(stderr, "gofsm-gen validate: unknown -format %q (use text, json, or sarif)\n", *format)
+			return 2
+		}
+		if *failOn != string(lint.SeverityError) && *failOn != string(lint.SeverityWarning) {
+			fmt.Fprintf(stderr, "gofsm-gen validate: unknown -fail-on %q (use error or warning)\n", *failOn)
+			return 2
+		}
+
+		specs, err := flags.specPaths(args)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen validate: %v\n", err)
+			return 1
+		}
+
+		findings, err := validateSpecs(specs)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen validate: %v\n", err)
+			return 1
+		}
+
+		if err := report(stdout, specs, findings); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen validate: %v\n", err)
+			return 1
+		}
+
+		for _, f := range findings {
+			if f.Severity == lint.SeverityError || lint.Severity(*failOn) == lint.SeverityWarning {
+				return 1
+			}
+		}
+		return 0
+	}
+}
+
+// validateSpecs parses, validates, and lints every spec
+func validateSpecs(specs []string) ([]specFinding, error) {
+	configs := configs{}
+
+	var findings []specFinding
+	for _, spec := range specs {
+		config, err := configs.get(filepath.Dir(spec))
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(spec)
+		if err != nil {
+			findings = append(findings, specFinding{File: spec, Finding: invalidSpec(err)})
+			continue
+		}
+		specFindings, fsm, err := checkSpec(data, config.Lint)
+		if err != nil {
+			return nil, err
+		}
+		if fsm != nil {
+			logger.parsed(spec, fsm)
+			logger.Info("linted spec", "spec", spec, "findings", len(specFindings))
+		}
+		for _, f := range specFindings {
+			findings = append(findings, specFinding{File: spec, Finding: f})
+		}
+	}
+	return findings, nil
+}
+
+// checkSpec parses, validates, and lints the spec src and returns its findings
+// and, if it is valid, its model. A spec that fails to parse or validate is
+// reported as an invalid-spec finding and is not linted, except that conflicting
+// transitions are reported as nondeterministic-transition findings. The error is
+// for invalid lint severities.
+func checkSpec(src []byte, severities map[string]lint.Severity) ([]lint.Finding, *model.FSMModel, error) {
+	fsm, err := parser.NewYAMLParser().Parse(bytes.NewReader(src))
+	var nondeterminism *model.NondeterminismError
+	if errors.As(err, &nondeterminism) {
+		// Reported by its lint rule, with the state and event of each conflict
+		findings, err := lint.Nondeterminism(nondeterminism, severities)
+		return findings, nil, err
+	}
+	if err == nil {
+		err = fsm.Validate()
+	}
+	if err != nil {
+		return []lint.Finding{invalidSpec(err)}, nil, nil
+	}
+
+	findings, err := lint.Run(fsm, severities)
+	if err != nil {
+		return nil, nil, err
+	}
+	return findings, fsm, nil
+}
+
+// invalidSpec returns the finding for a spec that cannot be read, parsed, or validated
+func invalidSpec(err error) lint.Finding {
+	return lint.Finding{Rule: invalidSpecRule, Severity: lint.SeverityError, Message: err.Error()}
+}
+
+// validateReports writes findings in each -format
+var validateReports = map[string]func(w io.Writer, specs []string, findings []specFinding) error{
+	"text":  reportText,
+	"json":  reportJSON,
+	"sarif": reportSARIF,
+}
+
+// reportText writes one line per finding followed by a summary
+func reportText(w io.Writer, specs []string, findings []specFinding) error {
+	errors, warnings := 0, 0
+	for _, f := range findings {
+		if f.Severity == lint.SeverityError {
+			errors++
+		} else {
+			warnings++
+		}
+		fmt.Fprintf(w, "%s: %s: %s (%s)\n", f.File, f.Severity, f.Message, f.Rule)
+	}
+
+	if len(findings) == 0 {
+		_, err := fmt.Fprintf(w, "%d spec(s) valid, no problems found\n", len(specs))
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d problem(s) (%d error(s), %d warning(s)) in %d spec(s)\n", len(findings), errors, warnings, len(specs))
+	return err
+}
+
+// reportJSON writes the findings as a JSON array
+func reportJSON(w io.Writer, _ []string, findings []specFinding) error {
+	if findings == nil {
+		findings = []specFinding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(findings)
+}
+
+// SARIF 2.1.0 document structure, limited to what validate reports
+type (
+	sarifLog struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name    string      `json:"name"`
+		Version string      `json:"version"`
+		Rules   []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   string       `json:"id"`
+		ShortDescription     sarifMessage `json:"shortDescription"`
+		DefaultConfiguration sarifConfig  `json:"defaultConfiguration"`
+	}
+	sarifConfig struct {
+		Level string `json:"level"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifLocation struct {
+		PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	}
+	sarifPhysicalLocation struct {
+		ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	}
+	sarifArtifactLocation struct {
+		URI string `json:"uri"`
+	}
+)
+
+// sarifLevel returns the SARIF level of a severity
+func sarifLevel(severity lint.Severity) string {
+	if severity == lint.SeverityOff {
+		return "none"
+	}
+	return string(severity)
+}
+
+// reportSARIF writes the findings as a SARIF 2.1.0 log for code scanning tools
+func reportSARIF(w io.Writer, _ []string, findings []specFinding) error {
+	driver := sarifDriver{
+		Name:    "gofsm-gen",
+		Version: generator.Version,
+		Rules: []sarifRule{{
+			ID:                   invalidSpecRule,
+			ShortDescription:     sarifMessage{Text: "the spec cannot be parsed or is invalid"},
+			DefaultConfiguration: sarifConfig{Level: string(lint.SeverityError)},
+		}},
+	}
+	for _, rule := range lint.Rules() {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: rule.Description},
+			DefaultConfiguration: sarifConfig{Level: sarifLevel(rule.Severity)},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, f := range findings {
+		results = append(results, sarifResult{
+			RuleID:  f.Rule,
+			Level:   string(f.Severity),
+			Message: sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(f.File)},
+			}}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	})
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+)
+
+// versionCommand implements "gofsm-gen version": it prints the version the
+// binary was released as or built from, the commit, the date, and the Go version
+func versionCommand(fs *flag.FlagSet) commandFunc {
+	return func(args []string, stdout, stderr io.Writer) int {
+		fmt.Fprintln(stdout, generator.ReadBuild())
+		return 0
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// defaultSpecPattern matches the spec files found by "dir/..." arguments
+const defaultSpecPattern = "*.fsm.yaml"
+
+// isRecursive reports whether arg is a "dir/..." pattern
+func isRecursive(arg string) bool {
+	return arg == "..." || strings.HasSuffix(arg, "/...")
+}
+
+// expandSpecs replaces every "dir/..." argument with the files below dir whose names
+// match pattern, in lexical order. Like the go command, the walk skips vendor and
+// testdata directories and directories whose names begin with "." or "_".
+func expandSpecs(args []string, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid -pattern %q: %w", pattern, err)
+	}
+
+	seen := make(map[string]bool)
+	var specs []string
+	add := func(spec string) {
+		if !seen[filepath.Clean(spec)] {
+			seen[filepath.Clean(spec)] = true
+			specs = append(specs, spec)
+		}
+	}
+
+	for _, arg := range args {
+		if !isRecursive(arg) {
+			add(arg)
+			continue
+		}
+
+		root := filepath.Clean(strings.TrimSuffix(arg, "..."))
+		found := 0
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && skipDir(d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ok, _ := filepath.Match(pattern, d.Name()); ok {
+				add(path)
+				found++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if found == 0 {
+			return nil, fmt.Errorf("%s matched no spec files named %s", arg, pattern)
+		}
+	}
+	return specs, nil
+}
+
+// skipDir reports whether a recursive walk ignores the directory name
+func skipDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
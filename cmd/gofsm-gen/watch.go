@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce collects the burst of events an editor save produces into one
+// regeneration
+const watchDebounce = 100 * time.Millisecond
+
+// watchCommand implements "gofsm-gen watch": it generates once, then regenerates
+// whenever a spec or template changes until interrupted. Generation errors are
+// reported without exiting so the spec can be fixed and saved again.
+func watchCommand(fs *flag.FlagSet) commandFunc {
+	var flags generateFlags
+	flags.register(fs)
+	return func(args []string, stdout, stderr io.Writer) int {
+		args, err := flags.anchored(fs, args)
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen watch: %v\n", err)
+			return 1
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if err := flags.watch(ctx, args, stdout, stderr); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen watch: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// watch regenerates the specs on every change until ctx is done
+func (f *generateFlags) watch(ctx context.Context, extraSpecs []string, stdout, stderr io.Writer) error {
+	specs, err := f.specPaths(extraSpecs)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start watching: %w", err)
+	}
+	defer watcher.Close()
+
+	// Editors often save by replacing the file, so watch the directories and
+	// filter their events by path
+	watched := make(map[string]string, len(specs))
+	dirs := make(map[string]bool)
+	for _, spec := range specs {
+		abs, err := filepath.Abs(spec)
+		if err != nil {
+			return err
+		}
+		watched[abs] = spec
+		dirs[filepath.Dir(abs)] = true
+	}
+	templates := make(map[string]bool)
+	configs := configs{}
+	for _, spec := range specs {
+		config, err := configs.get(filepath.Dir(spec))
+		if err != nil {
+			return err
+		}
+		key := f.generatorKey(config)
+		for _, dir := range []string{key.templates, key.overrides} {
+			if dir == "" {
+				continue
+			}
+			abs, err := filepath.Abs(dir)
+			if err != nil {
+				return err
+			}
+			templates[abs] = true
+			dirs[abs] = true
+		}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	regenerate := func() {
+		if err := f.generate(extraSpecs, writeOptions{}, stdout); err != nil {
+			fmt.Fprintf(stderr, "gofsm-gen watch: %v\n", err)
+		}
+	}
+	regenerate()
+	fmt.Fprintf(stdout, "watching %d spec(s) for changes; press Ctrl-C to stop\n", len(specs))
+
+	changed := make(map[string]bool)
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+				continue
+			}
+			abs, err := filepath.Abs(event.Name)
+			if err != nil {
+				continue
+			}
+			if spec, ok := watched[abs]; ok {
+				changed[spec] = true
+			} else if templates[filepath.Dir(abs)] && filepath.Ext(abs) == ".tmpl" {
+				changed[abs] = true
+			} else {
+				continue
+			}
+			debounce = time.After(watchDebounce)
+
+		case <-debounce:
+			names := make([]string, 0, len(changed))
+			for name := range changed {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				logger.file(stdout, "changed", name)
+			}
+			clear(changed)
+			debounce = nil
+			regenerate()
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintf(stderr, "gofsm-gen watch: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Command gofsm-vet reports misuse of gofsm-gen generated machines. It is a go
+// vet tool:
+//
+//	go vet -vettool=$(which gofsm-vet) ./...
+//
+// Run with package patterns instead, it runs go vet with itself as the tool.
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/vet"
+)
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") || strings.HasSuffix(args[0], ".cfg") {
+		os.Exit(vet.Main(args, os.Stdout, os.Stderr))
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gofsm-vet: %v\n", err)
+		os.Exit(1)
+	}
+	cmd := exec.Command("go", append([]string{"vet", "-vettool=" + exe}, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exit, ok := err.(*exec.ExitError); ok {
+			os.Exit(exit.ExitCode())
+		}
+		fmt.Fprintf(os.Stderr, "gofsm-vet: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,25 @@
+// Command protoc-gen-gofsm is a protoc plugin generating type-safe state machines
+// from enums annotated with the options of proto/gofsm/options.proto.
+//
+// Install it on the PATH and run protoc with --gofsm_out:
+//
+//	protoc --go_out=. --gofsm_out=. orders.proto
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/yourusername/gofsm-gen/pkg/protoplugin"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		fmt.Fprintln(os.Stderr, "protoc-gen-gofsm: this program is run by protoc, not directly")
+		os.Exit(2)
+	}
+	if err := protoplugin.Run(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "protoc-gen-gofsm: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// orderIDKey is the context key of the ID of the order a transition belongs to
+type orderIDKey struct{}
+
+// withOrderID returns a context carrying the ID of the order whose machine is
+// transitioned with it
+func withOrderID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, orderIDKey{}, id)
+}
+
+// newKafkaPublisher publishes transition records to the topic of w as JSON,
+// keyed by order ID so that the records of one order stay in order on a single
+// partition
+func newKafkaPublisher(w *kafka.Writer) *OrderStateMachineMessagePublisher {
+	return &OrderStateMachineMessagePublisher{
+		Send: func(ctx context.Context, record OrderStateMachineTransitionRecord, message []byte) error {
+			id, _ := ctx.Value(orderIDKey{}).(string)
+			return w.WriteMessages(ctx, kafka.Message{
+				Key:   []byte(id),
+				Value: message,
+				Time:  record.OccurredAt,
+				Headers: []kafka.Header{
+					{Key: "machine", Value: []byte(record.Machine)},
+					{Key: "event", Value: []byte(record.Event)},
+				},
+			})
+		},
+	}
+}
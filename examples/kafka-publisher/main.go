@@ -0,0 +1,49 @@
+// Command kafka-publisher publishes the transitions of an order state machine to
+// a Kafka topic. Start a broker, for example with
+//
+//	docker run -p 9092:9092 apache/kafka
+//
+// and run it with the broker address and topic:
+//
+//	go run . -brokers localhost:9092 -topic order-transitions
+package main
+
+// The generator lives in the parent module, which this one does not depend on
+//go:generate go -C ../.. run ./cmd/gofsm-gen examples/kafka-publisher/order.yaml
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func main() {
+	brokers := flag.String("brokers", "localhost:9092", "comma-separated Kafka broker addresses")
+	topic := flag.String("topic", "order-transitions", "topic the transition records are written to")
+	flag.Parse()
+
+	w := &kafka.Writer{
+		Addr:                   kafka.TCP(strings.Split(*brokers, ",")...),
+		Topic:                  *topic,
+		Balancer:               &kafka.Hash{},
+		AllowAutoTopicCreation: true,
+	}
+	defer w.Close()
+
+	sm := NewOrderStateMachine(
+		OrderStateMachineGuards{},
+		OrderStateMachineActions{},
+		WithPublisher(newKafkaPublisher(w)),
+	)
+
+	ctx := withOrderID(context.Background(), "order-42")
+	for _, event := range []OrderStateMachineEvent{OrderStateMachineEventApprove, OrderStateMachineEventShip} {
+		if err := sm.Transition(ctx, event); err != nil {
+			log.Fatalf("%s: %v", event, err)
+		}
+		log.Printf("%s: published, now %s", event, sm.State())
+	}
+}
@@ -0,0 +1,569 @@
+// Code generated by gofsm-gen. DO NOT EDIT.
+//gofsmgen:checksum spec=49156530a32528858cbcd7c6139162e64314546053ebac5240e4f2f7ed89678e content=74e3cb766406454f6d2ce8ca6e02457f3251c7dfbd12e256698bb7b06f4099b9
+
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// OrderStateMachineState represents all possible states.
+// The zero value is the initial state, OrderStateMachineStatePending.
+type OrderStateMachineState int
+
+//exhaustive:enforce
+const (
+	OrderStateMachineStateApproved OrderStateMachineState = 1
+	OrderStateMachineStatePending OrderStateMachineState = 0
+	OrderStateMachineStateRejected OrderStateMachineState = 2
+	OrderStateMachineStateShipped OrderStateMachineState = 3
+)
+
+// String returns the string representation of the state
+func (s OrderStateMachineState) String() string {
+	//exhaustive:enforce
+	switch s {
+	case OrderStateMachineStateApproved:
+		return "approved"
+	case OrderStateMachineStatePending:
+		return "pending"
+	case OrderStateMachineStateRejected:
+		return "rejected"
+	case OrderStateMachineStateShipped:
+		return "shipped"
+	default:
+		return fmt.Sprintf("UnknownOrderStateMachineState(%d)", s)
+	}
+}
+
+// IsValid reports whether s is one of the declared states
+func (s OrderStateMachineState) IsValid() bool {
+	//exhaustive:enforce
+	switch s {
+	case OrderStateMachineStateApproved:
+		return true
+	case OrderStateMachineStatePending:
+		return true
+	case OrderStateMachineStateRejected:
+		return true
+	case OrderStateMachineStateShipped:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsFinal reports whether s is a final state, in which runs of the machine end
+func (s OrderStateMachineState) IsFinal() bool {
+	return false
+}
+
+// ErrUnknownOrderStateMachineState is returned when a persisted value does not name a declared state
+var ErrUnknownOrderStateMachineState = errors.New("unknown OrderStateMachine state")
+
+// ParseOrderStateMachineState converts a persisted state name into a state.
+// Names that are not declared are resolved by the unknown-state policy (error).
+func ParseOrderStateMachineState(name string) (OrderStateMachineState, error) {
+	switch name {
+	case "approved":
+		return OrderStateMachineStateApproved, nil
+	case "pending":
+		return OrderStateMachineStatePending, nil
+	case "rejected":
+		return OrderStateMachineStateRejected, nil
+	case "shipped":
+		return OrderStateMachineStateShipped, nil
+	default:
+		return resolveUnknownOrderStateMachineState(name)
+	}
+}
+
+// resolveUnknownOrderStateMachineState applies the unknown-state policy to a persisted value
+func resolveUnknownOrderStateMachineState(value any) (OrderStateMachineState, error) {
+	return 0, fmt.Errorf("%w: %v", ErrUnknownOrderStateMachineState, value)
+}
+
+// Scan implements sql.Scanner. It accepts a state name or its integer value
+// and resolves unknown values with the unknown-state policy.
+func (s *OrderStateMachineState) Scan(src any) error {
+	var (
+		state OrderStateMachineState
+		err   error
+	)
+
+	switch v := src.(type) {
+	case string:
+		state, err = ParseOrderStateMachineState(v)
+	case []byte:
+		state, err = ParseOrderStateMachineState(string(v))
+	case int64:
+		if candidate := OrderStateMachineState(v); candidate.IsValid() {
+			state = candidate
+		} else {
+			state, err = resolveUnknownOrderStateMachineState(v)
+		}
+	case OrderStateMachineState:
+		if v.IsValid() {
+			state = v
+		} else {
+			state, err = resolveUnknownOrderStateMachineState(v)
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into OrderStateMachineState", src)
+	}
+
+	if err != nil {
+		return err
+	}
+	*s = state
+	return nil
+}
+
+// Value implements driver.Valuer, persisting the state by name
+func (s OrderStateMachineState) Value() (driver.Value, error) {
+	if !s.IsValid() {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownOrderStateMachineState, int(s))
+	}
+	return s.String(), nil
+}
+
+// OrderStateMachineEvent represents all possible events
+type OrderStateMachineEvent int
+
+//exhaustive:enforce
+const (
+	OrderStateMachineEventApprove OrderStateMachineEvent = 0
+	OrderStateMachineEventReject OrderStateMachineEvent = 1
+	OrderStateMachineEventShip OrderStateMachineEvent = 2
+)
+
+// String returns the string representation of the event
+func (s OrderStateMachineEvent) String() string {
+	//exhaustive:enforce
+	switch s {
+	case OrderStateMachineEventApprove:
+		return "approve"
+	case OrderStateMachineEventReject:
+		return "reject"
+	case OrderStateMachineEventShip:
+		return "ship"
+	default:
+		return fmt.Sprintf("UnknownOrderStateMachineEvent(%d)", s)
+	}
+}
+
+// OrderStateMachineContext is the context passed through state transitions
+type OrderStateMachineContext struct {
+	// Add your custom fields here
+}
+
+// OrderStateMachineGuards contains all guard functions
+type OrderStateMachineGuards struct {
+}
+
+// OrderStateMachineActions contains all action functions
+type OrderStateMachineActions struct {
+}
+
+// OrderStateMachineEntryActions contains all state entry actions
+type OrderStateMachineEntryActions struct {
+}
+
+// OrderStateMachineExitActions contains all state exit actions
+type OrderStateMachineExitActions struct {
+}
+
+// OrderStateMachineOption is a functional option for configuring the state machine
+type OrderStateMachineOption func(*OrderStateMachine)
+
+// WithLogger sets a custom logger for the state machine
+func WithLogger(logger Logger) OrderStateMachineOption {
+	return func(sm *OrderStateMachine) {
+		sm.logger = logger
+	}
+}
+
+// WithValidationMode enables strict validation mode
+func WithValidationMode(enabled bool) OrderStateMachineOption {
+	return func(sm *OrderStateMachine) {
+		sm.validationMode = enabled
+	}
+}
+
+// WithPublisher publishes every successful transition of the machine with p
+func WithPublisher(p OrderStateMachinePublisher) OrderStateMachineOption {
+	return func(sm *OrderStateMachine) {
+		sm.publisher = p
+	}
+}
+
+// WithZeroAllocation enables zero-allocation mode for performance
+func WithZeroAllocation(enabled bool) OrderStateMachineOption {
+	return func(sm *OrderStateMachine) {
+		sm.zeroAllocation = enabled
+	}
+}
+
+// Logger interface for state machine logging
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+	Debug(msg string, args ...interface{})
+}
+
+// OrderStateMachine is the generated state machine
+type OrderStateMachine struct {
+	mu              sync.RWMutex
+	currentState    OrderStateMachineState
+	context         *OrderStateMachineContext
+	guards          OrderStateMachineGuards
+	actions         OrderStateMachineActions
+	entryActions    OrderStateMachineEntryActions
+	exitActions     OrderStateMachineExitActions
+	logger          Logger
+	validationMode  bool
+	zeroAllocation  bool
+	publisher       OrderStateMachinePublisher
+}
+
+// NewOrderStateMachine creates a new state machine instance
+func NewOrderStateMachine(
+	guards OrderStateMachineGuards,
+	actions OrderStateMachineActions,
+	opts ...OrderStateMachineOption,
+) *OrderStateMachine {
+	sm := &OrderStateMachine{
+		currentState: OrderStateMachineStatePending,
+		context:      &OrderStateMachineContext{},
+		guards:       guards,
+		actions:      actions,
+		logger:       &noopLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(sm)
+	}
+
+	return sm
+}
+
+// State returns the current state
+func (sm *OrderStateMachine) State() OrderStateMachineState {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.currentState
+}
+
+// Context returns the state machine context
+func (sm *OrderStateMachine) Context() *OrderStateMachineContext {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.context
+}
+
+// SetContext updates the state machine context
+func (sm *OrderStateMachine) SetContext(ctx *OrderStateMachineContext) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.context = ctx
+}
+
+// RestoreState sets the current state from a persisted value (a state name, its
+// integer value, or a OrderStateMachineState), applying the unknown-state policy.
+// No guards, actions, or entry/exit actions are run.
+func (sm *OrderStateMachine) RestoreState(value any) error {
+	var state OrderStateMachineState
+	if err := state.Scan(value); err != nil {
+		return err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.currentState = state
+	return nil
+}
+
+// OrderStateMachineSnapshot is a checkpoint of a machine written by Snapshot
+type OrderStateMachineSnapshot struct {
+	Machine string `json:"machine"`
+	State   string `json:"state"`
+	Context *OrderStateMachineContext `json:"context,omitempty"`
+}
+
+// Snapshot captures the current state and context of the machine as JSON, so that
+// it can be checkpointed and resumed with Restore, possibly by another process.
+// Guards, actions, and options are not captured.
+func (sm *OrderStateMachine) Snapshot() ([]byte, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	state, err := sm.currentState.Value()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(OrderStateMachineSnapshot{Machine: "OrderStateMachine", State: state.(string), Context: sm.context})
+}
+
+// Restore resumes the machine from a snapshot written by Snapshot. The state is
+// restored like RestoreState, applying the unknown-state policy, and no guards,
+// actions, or entry/exit actions are run.
+func (sm *OrderStateMachine) Restore(data []byte) error {
+	var snapshot OrderStateMachineSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("invalid OrderStateMachine snapshot: %w", err)
+	}
+	if snapshot.Machine != "OrderStateMachine" {
+		return fmt.Errorf("a snapshot of %s cannot be restored into OrderStateMachine", snapshot.Machine)
+	}
+	var state OrderStateMachineState
+	if err := state.Scan(snapshot.State); err != nil {
+		return err
+	}
+	if snapshot.Context == nil {
+		snapshot.Context = &OrderStateMachineContext{}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.currentState = state
+	sm.context = snapshot.Context
+	return nil
+}
+// Transition triggers a state transition
+func (sm *OrderStateMachine) Transition(ctx context.Context, event OrderStateMachineEvent) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.transition(ctx, event)
+}
+
+// TransitionAll makes the transitions on events in order, holding the machine so
+// that no other transition comes in between, and stops at the first that fails.
+// It returns the number of events whose transitions were made and the error of the
+// failed one. Use it to replay queued events, such as on startup.
+func (sm *OrderStateMachine) TransitionAll(ctx context.Context, events ...OrderStateMachineEvent) (int, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for i, event := range events {
+		if err := sm.transition(ctx, event); err != nil {
+			return i, fmt.Errorf("event %d (%s): %w", i+1, event, err)
+		}
+	}
+	return len(events), nil
+}
+
+// transition makes the transition on event from the current state; the caller holds sm.mu
+func (sm *OrderStateMachine) transition(ctx context.Context, event OrderStateMachineEvent) error {
+	currentState := sm.currentState
+	sm.logger.Debug("Attempting transition", "from", currentState, "event", event)
+
+	// Find valid transition based on current state and event
+	//exhaustive:enforce
+	switch currentState {
+	case OrderStateMachineStateApproved:
+		//exhaustive:enforce
+		switch event {
+		case OrderStateMachineEventShip:
+
+			// Update state
+			sm.currentState = OrderStateMachineStateShipped
+			sm.logger.Info("State transition completed", "from", currentState, "to", sm.currentState, "event", event)
+
+			// Publish the transition
+			if err := sm.publish(ctx, currentState, event); err != nil {
+				return err
+			}
+
+			return nil
+		default:
+			return fmt.Errorf("invalid event %s for state %s", event, currentState)
+		}
+	case OrderStateMachineStatePending:
+		//exhaustive:enforce
+		switch event {
+		case OrderStateMachineEventApprove:
+
+			// Update state
+			sm.currentState = OrderStateMachineStateApproved
+			sm.logger.Info("State transition completed", "from", currentState, "to", sm.currentState, "event", event)
+
+			// Publish the transition
+			if err := sm.publish(ctx, currentState, event); err != nil {
+				return err
+			}
+
+			return nil
+		case OrderStateMachineEventReject:
+
+			// Update state
+			sm.currentState = OrderStateMachineStateRejected
+			sm.logger.Info("State transition completed", "from", currentState, "to", sm.currentState, "event", event)
+
+			// Publish the transition
+			if err := sm.publish(ctx, currentState, event); err != nil {
+				return err
+			}
+
+			return nil
+		default:
+			return fmt.Errorf("invalid event %s for state %s", event, currentState)
+		}
+	case OrderStateMachineStateRejected:
+		return fmt.Errorf("no transitions defined from state %s", currentState)
+	case OrderStateMachineStateShipped:
+		return fmt.Errorf("no transitions defined from state %s", currentState)
+	default:
+		return fmt.Errorf("unknown state: %s", currentState)
+	}
+}
+
+// PermittedEvents returns all events that can be triggered from the current state
+func (sm *OrderStateMachine) PermittedEvents() []OrderStateMachineEvent {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	var events []OrderStateMachineEvent
+
+	//exhaustive:enforce
+	switch sm.currentState {
+	case OrderStateMachineStateApproved:
+		events = []OrderStateMachineEvent{
+			OrderStateMachineEventShip,
+		}
+	case OrderStateMachineStatePending:
+		events = []OrderStateMachineEvent{
+			OrderStateMachineEventApprove,
+			OrderStateMachineEventReject,
+		}
+	case OrderStateMachineStateRejected:
+	case OrderStateMachineStateShipped:
+	}
+
+	return events
+}
+
+// CanTransition checks if a transition is possible without executing it
+func (sm *OrderStateMachine) CanTransition(ctx context.Context, event OrderStateMachineEvent) bool {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	currentState := sm.currentState
+
+	//exhaustive:enforce
+	switch currentState {
+	case OrderStateMachineStateApproved:
+		//exhaustive:enforce
+		switch event {
+		case OrderStateMachineEventShip:
+			return true
+		default:
+			return false
+		}
+	case OrderStateMachineStatePending:
+		//exhaustive:enforce
+		switch event {
+		case OrderStateMachineEventApprove:
+			return true
+		case OrderStateMachineEventReject:
+			return true
+		default:
+			return false
+		}
+	case OrderStateMachineStateRejected:
+		return false
+	case OrderStateMachineStateShipped:
+		return false
+	default:
+		return false
+	}
+}
+
+// OrderStateMachineTransitionRecord describes one successful transition. Machine, states,
+// and events are named as in the spec, and so as in the OrderStateMachineTransitionRecord
+// message of "gofsm-gen export proto -transition-record".
+type OrderStateMachineTransitionRecord struct {
+	Machine    string    `json:"machine"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	Event      string    `json:"event"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// OrderStateMachinePublisher publishes the transitions of the machines configured with
+// WithPublisher so that other systems can react to them. Publish is called after
+// the entry action of the target state, while the machine is locked; an error is
+// returned by Transition, with the machine already in the target state.
+type OrderStateMachinePublisher interface {
+	Publish(ctx context.Context, record OrderStateMachineTransitionRecord) error
+}
+
+// OrderStateMachinePublisherFunc adapts a function to a OrderStateMachinePublisher
+type OrderStateMachinePublisherFunc func(ctx context.Context, record OrderStateMachineTransitionRecord) error
+
+// Publish calls f
+func (f OrderStateMachinePublisherFunc) Publish(ctx context.Context, record OrderStateMachineTransitionRecord) error {
+	return f(ctx, record)
+}
+
+// OrderStateMachineRecordEncoder serializes a transition record for a message broker
+type OrderStateMachineRecordEncoder func(record OrderStateMachineTransitionRecord) ([]byte, error)
+
+// EncodeOrderStateMachineRecordJSON serializes record as JSON
+func EncodeOrderStateMachineRecordJSON(record OrderStateMachineTransitionRecord) ([]byte, error) {
+	return json.Marshal(record)
+}
+
+// OrderStateMachineMessagePublisher is a OrderStateMachinePublisher that serializes records and
+// hands them to Send, such as a function writing them to a Kafka topic
+type OrderStateMachineMessagePublisher struct {
+	// Encode serializes records; nil uses EncodeOrderStateMachineRecordJSON
+	Encode OrderStateMachineRecordEncoder
+
+	// Send delivers a serialized record; the record is passed along to pick a key
+	// or headers from
+	Send func(ctx context.Context, record OrderStateMachineTransitionRecord, message []byte) error
+}
+
+// Publish serializes record and sends it
+func (p *OrderStateMachineMessagePublisher) Publish(ctx context.Context, record OrderStateMachineTransitionRecord) error {
+	encode := p.Encode
+	if encode == nil {
+		encode = EncodeOrderStateMachineRecordJSON
+	}
+	message, err := encode(record)
+	if err != nil {
+		return fmt.Errorf("encode OrderStateMachine transition record: %w", err)
+	}
+	return p.Send(ctx, record, message)
+}
+
+// publish hands the transition from 'from' on event to the publisher, if any
+func (sm *OrderStateMachine) publish(ctx context.Context, from OrderStateMachineState, event OrderStateMachineEvent) error {
+	if sm.publisher == nil {
+		return nil
+	}
+	record := OrderStateMachineTransitionRecord{
+		Machine:    "OrderStateMachine",
+		From:       from.String(),
+		To:         sm.currentState.String(),
+		Event:      event.String(),
+		OccurredAt: time.Now().UTC(),
+	}
+	if err := sm.publisher.Publish(ctx, record); err != nil {
+		return fmt.Errorf("publish failed: %w", err)
+	}
+	return nil
+}
+
+// noopLogger is a no-op logger implementation
+type noopLogger struct{}
+
+func (l *noopLogger) Info(msg string, args ...interface{})  {}
+func (l *noopLogger) Error(msg string, args ...interface{}) {}
+func (l *noopLogger) Debug(msg string, args ...interface{}) {}
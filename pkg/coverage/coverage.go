@@ -0,0 +1,162 @@
+// Package coverage reads the transition coverage profiles written by machines
+// generated with the coverage option, merges them, and reports which parts of a
+// model they exercised.
+package coverage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// Transition is the count of one transition in a profile
+type Transition struct {
+	From  string `json:"from"`
+	Event string `json:"event"`
+	To    string `json:"to"`
+	Guard string `json:"guard,omitempty"`
+	Count uint64 `json:"count"`
+}
+
+// key identifies the transition regardless of its count
+func (t Transition) key() Transition {
+	t.Count = 0
+	return t
+}
+
+// Profile is the coverage of one machine, as written by Write<Machine>Coverage
+type Profile struct {
+	Machine     string       `json:"machine"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// ReadProfiles decodes the profiles in r. A dump may hold any number of profiles,
+// such as one appended by every test binary of a suite.
+func ReadProfiles(r io.Reader) ([]Profile, error) {
+	var profiles []Profile
+	dec := json.NewDecoder(r)
+	for {
+		var p Profile
+		err := dec.Decode(&p)
+		if errors.Is(err, io.EOF) {
+			return profiles, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid coverage profile: %w", err)
+		}
+		if p.Machine == "" {
+			return nil, fmt.Errorf("invalid coverage profile: missing machine name")
+		}
+		profiles = append(profiles, p)
+	}
+}
+
+// Merge sums the counts of the profiles of machine, keeping transitions in the
+// order they are first seen. Profiles of other machines are skipped.
+func Merge(machine string, profiles []Profile) Profile {
+	merged := Profile{Machine: machine, Transitions: []Transition{}}
+	index := make(map[Transition]int)
+	for _, p := range profiles {
+		if p.Machine != machine {
+			continue
+		}
+		for _, t := range p.Transitions {
+			i, ok := index[t.key()]
+			if !ok {
+				i = len(merged.Transitions)
+				index[t.key()] = i
+				merged.Transitions = append(merged.Transitions, t.key())
+			}
+			merged.Transitions[i].Count += t.Count
+		}
+	}
+	return merged
+}
+
+// Report is the coverage of a model by a merged profile
+type Report struct {
+	Machine string `json:"machine"`
+
+	// Transitions are the transitions of the model in declaration order with the
+	// number of times they fired
+	Transitions []Transition `json:"transitions"`
+
+	// States are the states of the model sorted by name
+	States []StateCoverage `json:"states"`
+
+	// Stale are the profile transitions the model does not define, which happens
+	// when the profile was recorded against an older spec
+	Stale []Transition `json:"stale,omitempty"`
+}
+
+// StateCoverage records whether a run visited a state
+type StateCoverage struct {
+	Name    string `json:"name"`
+	Visited bool   `json:"visited"`
+}
+
+// NewReport matches the transitions of a merged profile against the model. A
+// state counts as visited when it is the initial state or a fired transition
+// leads to it.
+func NewReport(m *model.FSMModel, p Profile) *Report {
+	counts := make(map[Transition]uint64, len(p.Transitions))
+	for _, t := range p.Transitions {
+		counts[t.key()] += t.Count
+	}
+
+	r := &Report{Machine: m.Name, Transitions: make([]Transition, 0, len(m.Transitions))}
+	visited := map[string]bool{m.Initial: true}
+	defined := make(map[Transition]bool, len(m.Transitions))
+	for _, mt := range m.Transitions {
+		t := Transition{From: mt.From, Event: mt.Event, To: mt.To, Guard: mt.Guard}
+		defined[t] = true
+		t.Count = counts[t]
+		if t.Count > 0 {
+			visited[t.To] = true
+		}
+		r.Transitions = append(r.Transitions, t)
+	}
+	for _, s := range m.GetStatesSlice() {
+		r.States = append(r.States, StateCoverage{Name: s.Name, Visited: visited[s.Name]})
+	}
+	for _, t := range p.Transitions {
+		if !defined[t.key()] {
+			r.Stale = append(r.Stale, t)
+		}
+	}
+	return r
+}
+
+// TransitionsCovered returns the number of transitions that fired at least once
+func (r *Report) TransitionsCovered() int {
+	covered := 0
+	for _, t := range r.Transitions {
+		if t.Count > 0 {
+			covered++
+		}
+	}
+	return covered
+}
+
+// StatesVisited returns the number of visited states
+func (r *Report) StatesVisited() int {
+	visited := 0
+	for _, s := range r.States {
+		if s.Visited {
+			visited++
+		}
+	}
+	return visited
+}
+
+// Percent returns the share of transitions covered as a percentage. A model
+// without transitions is fully covered.
+func (r *Report) Percent() float64 {
+	if len(r.Transitions) == 0 {
+		return 100
+	}
+	return 100 * float64(r.TransitionsCovered()) / float64(len(r.Transitions))
+}
@@ -0,0 +1,141 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+const orderSpec = `
+machine:
+  name: OrderStateMachine
+  initial: pending
+
+states:
+  - name: pending
+  - name: approved
+  - name: shipped
+    final: true
+  - name: cancelled
+    final: true
+
+events:
+  - approve
+  - ship
+  - cancel
+
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+    guard: hasPayment
+  - from: approved
+    to: shipped
+    on: ship
+  - from: pending
+    to: cancelled
+    on: cancel
+  - from: approved
+    to: cancelled
+    on: cancel
+`
+
+func parseSpec(t *testing.T) *model.FSMModel {
+	t.Helper()
+	fsm, err := parser.NewYAMLParser().Parse(strings.NewReader(orderSpec))
+	require.NoError(t, err)
+	return fsm
+}
+
+func TestReadProfiles(t *testing.T) {
+	dump := `{"machine":"OrderStateMachine","transitions":[{"from":"pending","event":"approve","to":"approved","guard":"hasPayment","count":2}]}
+{"machine":"OrderStateMachine","transitions":[]}
+`
+	profiles, err := ReadProfiles(strings.NewReader(dump))
+	require.NoError(t, err)
+	require.Len(t, profiles, 2)
+	assert.Equal(t, []Transition{{From: "pending", Event: "approve", To: "approved", Guard: "hasPayment", Count: 2}}, profiles[0].Transitions)
+
+	profiles, err = ReadProfiles(strings.NewReader(""))
+	require.NoError(t, err)
+	assert.Empty(t, profiles)
+
+	_, err = ReadProfiles(strings.NewReader(`{"machine":`))
+	assert.ErrorContains(t, err, "invalid coverage profile")
+
+	_, err = ReadProfiles(strings.NewReader(`{"transitions":[]}`))
+	assert.ErrorContains(t, err, "missing machine name")
+}
+
+func TestMerge(t *testing.T) {
+	profiles := []Profile{
+		{Machine: "OrderStateMachine", Transitions: []Transition{
+			{From: "pending", Event: "approve", To: "approved", Guard: "hasPayment", Count: 2},
+			{From: "approved", Event: "ship", To: "shipped", Count: 0},
+		}},
+		{Machine: "PaymentStateMachine", Transitions: []Transition{
+			{From: "pending", Event: "approve", To: "approved", Guard: "hasPayment", Count: 5},
+		}},
+		{Machine: "OrderStateMachine", Transitions: []Transition{
+			{From: "approved", Event: "ship", To: "shipped", Count: 1},
+			{From: "pending", Event: "approve", To: "approved", Guard: "hasPayment", Count: 3},
+			{From: "pending", Event: "cancel", To: "cancelled", Count: 1},
+		}},
+	}
+
+	merged := Merge("OrderStateMachine", profiles)
+	assert.Equal(t, Profile{Machine: "OrderStateMachine", Transitions: []Transition{
+		{From: "pending", Event: "approve", To: "approved", Guard: "hasPayment", Count: 5},
+		{From: "approved", Event: "ship", To: "shipped", Count: 1},
+		{From: "pending", Event: "cancel", To: "cancelled", Count: 1},
+	}}, merged)
+
+	assert.Empty(t, Merge("ReturnStateMachine", profiles).Transitions)
+}
+
+func TestNewReport(t *testing.T) {
+	fsm := parseSpec(t)
+
+	r := NewReport(fsm, Profile{Machine: "OrderStateMachine", Transitions: []Transition{
+		{From: "pending", Event: "approve", To: "approved", Guard: "hasPayment", Count: 4},
+		{From: "approved", Event: "cancel", To: "cancelled", Count: 1},
+		{From: "approved", Event: "refund", To: "refunded", Count: 2},
+	}})
+
+	assert.Equal(t, []Transition{
+		{From: "pending", Event: "approve", To: "approved", Guard: "hasPayment", Count: 4},
+		{From: "approved", Event: "ship", To: "shipped"},
+		{From: "pending", Event: "cancel", To: "cancelled"},
+		{From: "approved", Event: "cancel", To: "cancelled", Count: 1},
+	}, r.Transitions)
+	assert.Equal(t, []StateCoverage{
+		{Name: "approved", Visited: true},
+		{Name: "cancelled", Visited: true},
+		{Name: "pending", Visited: true},
+		{Name: "shipped", Visited: false},
+	}, r.States)
+	assert.Equal(t, []Transition{{From: "approved", Event: "refund", To: "refunded", Count: 2}}, r.Stale)
+
+	assert.Equal(t, 2, r.TransitionsCovered())
+	assert.Equal(t, 3, r.StatesVisited())
+	assert.InDelta(t, 50.0, r.Percent(), 0.001)
+}
+
+func TestNewReport_GuardMustMatch(t *testing.T) {
+	fsm := parseSpec(t)
+
+	r := NewReport(fsm, Profile{Machine: "OrderStateMachine", Transitions: []Transition{
+		{From: "pending", Event: "approve", To: "approved", Count: 1},
+	}})
+
+	assert.Equal(t, 0, r.TransitionsCovered())
+	assert.Len(t, r.Stale, 1)
+}
+
+func TestReport_PercentWithoutTransitions(t *testing.T) {
+	assert.InDelta(t, 100.0, (&Report{}).Percent(), 0.001)
+}
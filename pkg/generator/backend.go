@@ -0,0 +1,141 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// GoBackendName is the name of the default backend, which generates Go
+const GoBackendName = "go"
+
+// OutputFile is a file a backend generates. Its path is relative to the output
+// directory.
+type OutputFile = PlannedFile
+
+// Backend generates the code of a machine in one output language
+type Backend interface {
+	// Name identifies the backend, e.g. on the command line
+	Name() string
+
+	// Generate renders the files of the machine
+	Generate(m *model.FSMModel) ([]OutputFile, error)
+}
+
+// BackendFactory creates a backend rendering with the templates of gen, so that
+// backends pick up custom template directories
+type BackendFactory func(gen *CodeGenerator) Backend
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFactory{
+		GoBackendName: func(gen *CodeGenerator) Backend { return &GoBackend{gen: gen} },
+		"typescript":  func(gen *CodeGenerator) Backend { return typescriptBackend{gen: gen} },
+		"python":      func(gen *CodeGenerator) Backend { return pythonBackend{gen: gen} },
+	}
+)
+
+// RegisterBackend makes a backend available under name. It panics if a backend
+// is already registered under the name, like the registries of the standard
+// library, since that is a programming error.
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if factory == nil {
+		panic("generator: RegisterBackend factory is nil")
+	}
+	if _, dup := backends[name]; dup {
+		panic("generator: RegisterBackend called twice for backend " + name)
+	}
+	backends[name] = factory
+}
+
+// BackendNames returns the names of the registered backends, sorted
+func BackendNames() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Backend returns the registered backend with the given name, rendering with the
+// templates of g
+func (g *CodeGenerator) Backend(name string) (Backend, error) {
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("backend %q is not registered (use %s)", name, strings.Join(BackendNames(), ", "))
+	}
+	return factory(g), nil
+}
+
+// GoBackend generates the machine as Go code, in a single file or, with Split,
+// one file per section
+type GoBackend struct {
+	gen *CodeGenerator
+
+	// Split writes the states, events, callbacks, and machine as separate files
+	Split bool
+}
+
+// NewGoBackend creates a Go backend rendering with the templates of gen
+func NewGoBackend(gen *CodeGenerator, split bool) *GoBackend {
+	return &GoBackend{gen: gen, Split: split}
+}
+
+// Name implements Backend
+func (b *GoBackend) Name() string { return GoBackendName }
+
+// Generate implements Backend. The single file is named by DefaultOutputName.
+func (b *GoBackend) Generate(m *model.FSMModel) ([]OutputFile, error) {
+	if b.Split {
+		return b.gen.GenerateSplit(m)
+	}
+
+	code, err := b.gen.Generate(m)
+	if err != nil {
+		return nil, err
+	}
+	return []OutputFile{{Path: DefaultOutputName(m), Content: code}}, nil
+}
+
+// typescriptBackend generates a TypeScript machine mirroring the Go machine
+type typescriptBackend struct {
+	gen *CodeGenerator
+}
+
+func (b typescriptBackend) Name() string { return "typescript" }
+
+func (b typescriptBackend) Generate(m *model.FSMModel) ([]OutputFile, error) {
+	code, err := b.gen.GenerateTypeScriptMachine(m)
+	if err != nil {
+		return nil, err
+	}
+	return []OutputFile{{Path: TypeScriptOutputName(m), Content: code}}, nil
+}
+
+// pythonBackend generates a Python machine mirroring the Go machine
+type pythonBackend struct {
+	gen *CodeGenerator
+}
+
+func (b pythonBackend) Name() string { return "python" }
+
+func (b pythonBackend) Generate(m *model.FSMModel) ([]OutputFile, error) {
+	code, err := b.gen.GeneratePython(m)
+	if err != nil {
+		return nil, err
+	}
+	return []OutputFile{{Path: PythonOutputName(m), Content: code}}, nil
+}
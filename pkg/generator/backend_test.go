@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// stateListBackend lists the states of a machine, one per line
+type stateListBackend struct{}
+
+func (stateListBackend) Name() string { return "state-list" }
+
+func (stateListBackend) Generate(m *model.FSMModel) ([]OutputFile, error) {
+	names := m.GetStateNames()
+	sort.Strings(names)
+	var out []byte
+	for _, name := range names {
+		out = append(out, name+"\n"...)
+	}
+	return []OutputFile{{Path: snakeCase(m.Name) + ".states", Content: out}}, nil
+}
+
+func TestCodeGenerator_Backend(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	t.Run("go is the default", func(t *testing.T) {
+		backend, err := gen.Backend(GoBackendName)
+		require.NoError(t, err)
+		assert.Equal(t, "go", backend.Name())
+
+		files, err := backend.Generate(fsm)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		assert.Equal(t, DefaultOutputName(fsm), files[0].Path)
+
+		code, err := gen.Generate(fsm)
+		require.NoError(t, err)
+		assert.Equal(t, string(code), string(files[0].Content))
+	})
+
+	t.Run("go split", func(t *testing.T) {
+		files, err := NewGoBackend(gen, true).Generate(fsm)
+		require.NoError(t, err)
+		sections, err := gen.GenerateSplit(fsm)
+		require.NoError(t, err)
+		assert.Equal(t, sections, files)
+	})
+
+	t.Run("typescript", func(t *testing.T) {
+		backend, err := gen.Backend("typescript")
+		require.NoError(t, err)
+		files, err := backend.Generate(fsm)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		assert.Equal(t, "order_state_machine.ts", files[0].Path)
+		machine, err := gen.GenerateTypeScriptMachine(fsm)
+		require.NoError(t, err)
+		assert.Equal(t, string(machine), string(files[0].Content))
+	})
+
+	t.Run("python", func(t *testing.T) {
+		backend, err := gen.Backend("python")
+		require.NoError(t, err)
+		files, err := backend.Generate(fsm)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		assert.Equal(t, "order_state_machine.py", files[0].Path)
+		assert.Contains(t, string(files[0].Content), "class OrderStateMachine:")
+	})
+
+	t.Run("registered", func(t *testing.T) {
+		RegisterBackend("state-list", func(*CodeGenerator) Backend { return stateListBackend{} })
+		t.Cleanup(func() {
+			backendsMu.Lock()
+			delete(backends, "state-list")
+			backendsMu.Unlock()
+		})
+		assert.Contains(t, BackendNames(), "state-list")
+		assert.Panics(t, func() {
+			RegisterBackend("state-list", func(*CodeGenerator) Backend { return stateListBackend{} })
+		})
+
+		backend, err := gen.Backend("state-list")
+		require.NoError(t, err)
+		files, err := backend.Generate(fsm)
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		assert.Equal(t, "order_state_machine.states", files[0].Path)
+		assert.Equal(t, "approved\npending\nrejected\nshipped\n", string(files[0].Content))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := gen.Backend("cobol")
+		assert.ErrorContains(t, err, `backend "cobol" is not registered (use go, `)
+	})
+}
@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// bindingData is the template data of binding.tmpl
+type bindingData struct {
+	*model.FSMModel
+}
+
+// LoadExpr returns the expression passing the state field v to RestoreState,
+// which accepts state names as strings and values as int64
+func (d bindingData) LoadExpr(v string) string {
+	b := d.Binding
+	switch {
+	case b.StorageOrDefault() == model.BindingStorageValue && b.TypeOrDefault() != "int64":
+		return "int64(" + v + ")"
+	case b.StorageOrDefault() == model.BindingStorageName && b.TypeOrDefault() != "string":
+		return "string(" + v + ")"
+	}
+	return v
+}
+
+// StoreExpr returns the expression converting the machine state v to the type of
+// the state field
+func (d bindingData) StoreExpr(v string) string {
+	b := d.Binding
+	switch {
+	case b.StorageOrDefault() == model.BindingStorageName && b.TypeOrDefault() == "string":
+		return v + ".String()"
+	case b.StorageOrDefault() == model.BindingStorageName:
+		return b.TypeOrDefault() + "(" + v + ".String())"
+	case b.TypeOrDefault() == d.Name+"State":
+		return v
+	}
+	return b.TypeOrDefault() + "(" + v + ")"
+}
+
+// GenerateBinding generates a {Name}Binding for the ORM model of the binding
+// section: it loads a model, fires an event on a machine restored from its state
+// field, and saves the state it moves to within a transaction
+func (g *CodeGenerator) GenerateBinding(m *model.FSMModel) ([]byte, error) {
+	m, err := prepare(m)
+	if err != nil {
+		return nil, err
+	}
+	if m.Binding == nil {
+		return nil, fmt.Errorf("machine %s has no binding section", m.Name)
+	}
+	return g.executeData("binding.tmpl", m, bindingData{FSMModel: m})
+}
+
+// BindingOutputName returns the conventional binding file name for a model with
+// a binding, which names its ORM
+func BindingOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + "_" + string(m.Binding.ORM) + ".go"
+}
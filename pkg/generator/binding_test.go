@@ -0,0 +1,325 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// gormStubs stand in for the gorm packages the gorm binding uses: a DB keeping
+// rows in memory by their ID field, whose transactions roll back on error
+var gormStubs = map[string][]byte{
+	"go.mod": []byte(`module generated
+
+go 1.21
+
+require gorm.io/gorm v0.0.0
+
+replace gorm.io/gorm => ./gormstub
+`),
+	"gormstub/go.mod": []byte("module gorm.io/gorm\n\ngo 1.21\n"),
+	"gormstub/clause/clause.go": []byte(`package clause
+
+type Expression interface{}
+
+type Locking struct {
+	Strength string
+}
+`),
+	"gormstub/gorm.go": []byte(`package gorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm/clause"
+)
+
+var ErrRecordNotFound = errors.New("record not found")
+
+type DB struct {
+	Error  error
+	Rows   map[any]any
+	Locked bool
+}
+
+func (db *DB) WithContext(ctx context.Context) *DB { return db }
+
+func (db *DB) Clauses(conds ...clause.Expression) *DB {
+	db.Locked = true
+	return db
+}
+
+func (db *DB) Transaction(fc func(tx *DB) error) error {
+	saved := make(map[any]any, len(db.Rows))
+	for id, row := range db.Rows {
+		saved[id] = row
+	}
+	if err := fc(db); err != nil {
+		db.Rows = saved
+		return err
+	}
+	return nil
+}
+
+func id(dest any) any { return reflect.ValueOf(dest).Elem().FieldByName("ID").Interface() }
+
+func (db *DB) First(dest any, conds ...any) *DB {
+	row, ok := db.Rows[id(dest)]
+	if !ok {
+		return &DB{Error: ErrRecordNotFound}
+	}
+	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(row))
+	return &DB{}
+}
+
+func (db *DB) Save(value any) *DB {
+	db.Rows[id(value)] = reflect.ValueOf(value).Elem().Interface()
+	return &DB{}
+}
+`),
+}
+
+// entStubs stand in for the code ent generates from an Order schema with a
+// Status enum field, keeping entities in memory
+var entStubs = map[string][]byte{
+	"go.mod": []byte(`module generated
+
+go 1.21
+
+require example.com/shop v0.0.0
+
+replace example.com/shop => ./entstub
+`),
+	"entstub/go.mod": []byte("module example.com/shop\n\ngo 1.21\n"),
+	"entstub/ent/order/order.go": []byte(`package order
+
+type Status string
+`),
+	"entstub/ent/ent.go": []byte(`package ent
+
+import (
+	"context"
+	"errors"
+
+	"example.com/shop/ent/order"
+)
+
+type Order struct {
+	ID     int
+	Status order.Status
+}
+
+type Client struct {
+	Rows      map[int]Order
+	Committed int
+}
+
+type Tx struct {
+	Order  *OrderClient
+	client *Client
+}
+
+func (c *Client) Tx(ctx context.Context) (*Tx, error) {
+	rows := make(map[int]Order, len(c.Rows))
+	for id, row := range c.Rows {
+		rows[id] = row
+	}
+	return &Tx{Order: &OrderClient{rows: rows}, client: c}, nil
+}
+
+func (tx *Tx) Commit() error {
+	tx.client.Rows = tx.Order.rows
+	tx.client.Committed++
+	return nil
+}
+
+func (tx *Tx) Rollback() error { return nil }
+
+type OrderClient struct{ rows map[int]Order }
+
+func (c *OrderClient) Get(ctx context.Context, id int) (*Order, error) {
+	row, ok := c.rows[id]
+	if !ok {
+		return nil, errors.New("ent: order not found")
+	}
+	return &row, nil
+}
+
+func (c *OrderClient) UpdateOne(o *Order) *OrderUpdateOne { return &OrderUpdateOne{c, *o} }
+
+type OrderUpdateOne struct {
+	c     *OrderClient
+	order Order
+}
+
+func (u *OrderUpdateOne) SetStatus(s order.Status) *OrderUpdateOne {
+	u.order.Status = s
+	return u
+}
+
+func (u *OrderUpdateOne) Save(ctx context.Context) (*Order, error) {
+	u.c.rows[u.order.ID] = u.order
+	return &u.order, nil
+}
+`),
+}
+
+func TestCodeGenerator_GenerateBinding(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	_, err = gen.GenerateBinding(fsm)
+	assert.ErrorContains(t, err, "machine OrderStateMachine has no binding section")
+
+	fsm.Binding = &model.Binding{ORM: model.BindingGorm, Model: "Order", Field: "State", Type: "int", Storage: model.BindingStorageValue}
+	require.NoError(t, fsm.Validate())
+
+	binding, err := gen.GenerateBinding(fsm)
+	require.NoError(t, err)
+	src := string(binding)
+	assert.True(t, IsGenerated(binding))
+	assert.Equal(t, "order_state_machine_gorm.go", BindingOutputName(fsm))
+	assert.Contains(t, src, "func (b *OrderStateMachineBinding) Fire(ctx context.Context, m *Order, event OrderStateMachineEvent, fn func(tx *gorm.DB, m *Order) error) error {")
+	assert.Contains(t, src, "sm.RestoreState(int64(m.State))")
+	assert.Contains(t, src, "m.State = int(sm.State())")
+
+	machine, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"order_state_machine_fsm.go":  machine,
+		"order_state_machine_gorm.go": binding,
+		"binding_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+type Order struct {
+	ID    uint
+	State int
+	Note  string
+}
+
+func TestGormBinding(t *testing.T) {
+	ctx := context.Background()
+	db := &gorm.DB{Rows: map[any]any{uint(1): Order{ID: 1, State: int(OrderStateMachineStatePending)}}}
+	b := NewOrderStateMachineBinding(db, nil)
+
+	m := &Order{ID: 1}
+	if err := b.Fire(ctx, m, OrderStateMachineEventApprove, func(tx *gorm.DB, m *Order) error {
+		m.Note = "approved"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Locked {
+		t.Error("the row must be locked")
+	}
+	if row := db.Rows[uint(1)].(Order); row.State != int(OrderStateMachineStateApproved) || row.Note != "approved" {
+		t.Fatalf("saved %+v", row)
+	}
+	if m.State != int(OrderStateMachineStateApproved) {
+		t.Errorf("m was not updated: %+v", m)
+	}
+
+	if err := b.Fire(ctx, m, OrderStateMachineEventReject, nil); err == nil {
+		t.Error("reject should be invalid in approved")
+	}
+	failure := errors.New("audit failed")
+	if err := b.Fire(ctx, m, OrderStateMachineEventShip, func(tx *gorm.DB, m *Order) error { return failure }); !errors.Is(err, failure) {
+		t.Fatalf("got %v, want the callback error", err)
+	}
+	if row := db.Rows[uint(1)].(Order); row.State != int(OrderStateMachineStateApproved) {
+		t.Fatalf("a failed callback must roll the transition back, saved %+v", row)
+	}
+	if err := b.Fire(ctx, &Order{ID: 2}, OrderStateMachineEventApprove, nil); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("got %v for a missing row", err)
+	}
+}
+`),
+	}
+	for name, content := range gormStubs {
+		files[name] = content
+	}
+	runGeneratedPackage(t, files)
+}
+
+func TestCodeGenerator_GenerateBinding_Ent(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Imports = []string{"example.com/shop/ent", "example.com/shop/ent/order"}
+	fsm.Binding = &model.Binding{ORM: model.BindingEnt, Model: "ent.Order", Field: "Status", Type: "order.Status"}
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	binding, err := gen.GenerateBinding(fsm)
+	require.NoError(t, err)
+	src := string(binding)
+	assert.Equal(t, "order_state_machine_ent.go", BindingOutputName(fsm))
+	assert.Contains(t, src, "\t\"example.com/shop/ent\"\n\t\"example.com/shop/ent/order\"\n)")
+	assert.Contains(t, src, "func (b *OrderStateMachineBinding) Fire(ctx context.Context, id int, event OrderStateMachineEvent, fn func(tx *ent.Tx, m *ent.Order) error) (*ent.Order, error) {")
+	assert.Contains(t, src, "tx.Order.UpdateOne(m).SetStatus(order.Status(sm.State().String())).Save(ctx)")
+
+	machine, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"order_state_machine_fsm.go": machine,
+		"order_state_machine_ent.go": binding,
+		"binding_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"example.com/shop/ent"
+)
+
+func TestEntBinding(t *testing.T) {
+	ctx := context.Background()
+	client := &ent.Client{Rows: map[int]ent.Order{1: {ID: 1, Status: "pending"}}}
+	b := NewOrderStateMachineBinding(client, nil)
+
+	m, err := b.Fire(ctx, 1, OrderStateMachineEventApprove, func(tx *ent.Tx, m *ent.Order) error {
+		if m.Status != "approved" {
+			t.Errorf("the callback must see the saved entity, got %+v", m)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Status != "approved" || client.Rows[1].Status != "approved" || client.Committed != 1 {
+		t.Fatalf("returned %+v, stored %+v", m, client.Rows[1])
+	}
+
+	if _, err := b.Fire(ctx, 1, OrderStateMachineEventReject, nil); err == nil {
+		t.Error("reject should be invalid in approved")
+	}
+	failure := errors.New("audit failed")
+	if _, err := b.Fire(ctx, 1, OrderStateMachineEventShip, func(tx *ent.Tx, m *ent.Order) error { return failure }); !errors.Is(err, failure) {
+		t.Fatalf("got %v, want the callback error", err)
+	}
+	if client.Rows[1].Status != "approved" || client.Committed != 1 {
+		t.Fatalf("a failed callback must roll the transition back, stored %+v", client.Rows[1])
+	}
+}
+`),
+	}
+	for name, content := range entStubs {
+		files[name] = content
+	}
+	runGeneratedPackage(t, files)
+}
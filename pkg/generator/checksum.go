@@ -0,0 +1,130 @@
+package generator
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// checksumDirective starts the line that records the spec and content checksums
+// of a generated file. Its name has no hyphen, so gofmt keeps it a directive.
+const checksumDirective = "//gofsmgen:checksum "
+
+// checksumPrefixes are the starts of checksum lines findChecksums accepts: the
+// directive, the form gofmt rewrites it to where it is not a directive, and the
+// hyphenated name of earlier versions in both forms
+var checksumPrefixes = []string{
+	checksumDirective,
+	"// gofsmgen:checksum ",
+	"//gofsm-gen:checksum ",
+	"// gofsm-gen:checksum ",
+}
+
+// ErrNoChecksum is returned for generated files that carry no checksum directive
+var ErrNoChecksum = errors.New("no gofsm-gen checksum")
+
+// Checksums are the checksums recorded in a generated file
+type Checksums struct {
+	// Spec is the hex-encoded SHA-256 of the spec the file was generated from
+	Spec string
+
+	// Content is the hex-encoded SHA-256 of the file without its checksum directive
+	// and without the code of its user regions, which users may edit
+	Content string
+}
+
+// seal inserts a checksum directive after the generated marker of src. Files
+// without the marker or without a known spec checksum are returned unchanged.
+func seal(src []byte, specChecksum string) []byte {
+	if specChecksum == "" || !bytes.HasPrefix(src, []byte(GeneratedMarker+"\n")) {
+		return src
+	}
+
+	directive := checksumLine(specChecksum, contentChecksum(withoutUserCode(src)))
+
+	sealed := make([]byte, 0, len(src)+len(directive))
+	sealed = append(sealed, GeneratedMarker+"\n"...)
+	sealed = append(sealed, directive...)
+	sealed = append(sealed, src[len(GeneratedMarker)+1:]...)
+	return sealed
+}
+
+// checksumLine returns the checksum directive line recording the given checksums
+func checksumLine(specChecksum, contentChecksum string) string {
+	return checksumDirective + "spec=" + specChecksum + " content=" + contentChecksum + "\n"
+}
+
+// ReadChecksums extracts the checksums recorded in src and reports whether the
+// content of src still matches them, i.e. whether the file is unedited
+func ReadChecksums(src []byte) (sums Checksums, intact bool, err error) {
+	lines := strings.SplitAfter(string(src), "\n")
+	i, sums, ok := findChecksums(lines)
+	if !ok {
+		return Checksums{}, false, ErrNoChecksum
+	}
+	return sums, contentChecksumWithout(lines, i) == sums.Content, nil
+}
+
+// Reseal records the current content of src in its checksum directive, keeping
+// the spec checksum, for files that are changed on purpose after generation,
+// such as by post-processing commands. Files without a directive are returned
+// unchanged.
+func Reseal(src []byte) []byte {
+	lines := strings.SplitAfter(string(src), "\n")
+	i, sums, ok := findChecksums(lines)
+	if !ok {
+		return src
+	}
+	lines[i] = checksumLine(sums.Spec, contentChecksumWithout(lines, i))
+	return []byte(strings.Join(lines, ""))
+}
+
+// findChecksums returns the index of the checksum directive among lines and
+// the checksums it records
+func findChecksums(lines []string) (int, Checksums, bool) {
+	for i, line := range lines {
+		rest, ok := cutChecksumPrefix(line)
+		if !ok {
+			continue
+		}
+
+		var sums Checksums
+		for _, field := range strings.Fields(rest) {
+			key, value, _ := strings.Cut(field, "=")
+			switch key {
+			case "spec":
+				sums.Spec = value
+			case "content":
+				sums.Content = value
+			}
+		}
+		return i, sums, true
+	}
+	return 0, Checksums{}, false
+}
+
+// cutChecksumPrefix returns line without its checksum line prefix and reports
+// whether it has one
+func cutChecksumPrefix(line string) (string, bool) {
+	for _, prefix := range checksumPrefixes {
+		if rest, ok := strings.CutPrefix(line, prefix); ok {
+			return rest, true
+		}
+	}
+	return "", false
+}
+
+// contentChecksumWithout returns the content checksum of lines without the
+// directive at index i
+func contentChecksumWithout(lines []string, i int) string {
+	rest := strings.Join(lines[:i], "") + strings.Join(lines[i+1:], "")
+	return contentChecksum(withoutUserCode([]byte(rest)))
+}
+
+// contentChecksum returns the hex-encoded SHA-256 of src
+func contentChecksum(src []byte) string {
+	sum := sha256.Sum256(src)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"go/format"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeal_RoundTrip(t *testing.T) {
+	src := []byte(GeneratedMarker + "\npackage orders\n\nconst Answer = 42\n")
+
+	sealed := seal(src, "specsum")
+	lines := strings.Split(string(sealed), "\n")
+	require.GreaterOrEqual(t, len(lines), 3)
+	assert.Equal(t, GeneratedMarker, lines[0], "The generated marker must stay on the first line")
+	assert.True(t, strings.HasPrefix(lines[1], checksumDirective+"spec=specsum content="))
+	assert.True(t, IsGenerated(sealed))
+
+	sums, intact, err := ReadChecksums(sealed)
+	require.NoError(t, err)
+	assert.True(t, intact)
+	assert.Equal(t, "specsum", sums.Spec)
+
+	edited := strings.Replace(string(sealed), "42", "43", 1)
+	_, intact, err = ReadChecksums([]byte(edited))
+	require.NoError(t, err)
+	assert.False(t, intact, "Hand edits must be detected")
+}
+
+func TestSeal_Unchanged(t *testing.T) {
+	src := []byte(GeneratedMarker + "\npackage orders\n")
+	assert.Equal(t, src, seal(src, ""), "Models without a spec file are not sealed")
+
+	diagram := []byte("%% Code generated by gofsm-gen. DO NOT EDIT.\nstateDiagram-v2\n")
+	assert.Equal(t, diagram, seal(diagram, "specsum"), "Only Go files are sealed")
+
+	_, _, err := ReadChecksums(src)
+	assert.ErrorIs(t, err, ErrNoChecksum)
+}
+
+func TestReseal(t *testing.T) {
+	sealed := seal([]byte(GeneratedMarker+"\npackage orders\n\nconst Answer = 42\n"), "specsum")
+	processed := "// Copyright 2026 Acme Corp.\n\n" + strings.Replace(string(sealed), "42", "43", 1)
+	_, intact, err := ReadChecksums([]byte(processed))
+	require.NoError(t, err)
+	require.False(t, intact)
+
+	resealed := Reseal([]byte(processed))
+	sums, intact, err := ReadChecksums(resealed)
+	require.NoError(t, err)
+	assert.True(t, intact, "the new content is recorded")
+	assert.Equal(t, "specsum", sums.Spec, "the spec checksum is kept")
+	assert.Equal(t, len(processed), len(resealed))
+	assert.Equal(t, resealed, Reseal(resealed))
+
+	plain := []byte(GeneratedMarker + "\npackage orders\n")
+	assert.Equal(t, plain, Reseal(plain), "files without a directive are not sealed")
+}
+
+func TestSeal_Gofmt(t *testing.T) {
+	sealed := seal([]byte(GeneratedMarker+"\n\npackage orders\n\nconst Answer = 42\n"), "specsum")
+	formatted, err := format.Source(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, string(sealed), string(formatted), "gofmt keeps the directive as it is")
+
+	for _, prefix := range []string{"// gofsmgen:checksum ", "//gofsm-gen:checksum ", "// gofsm-gen:checksum "} {
+		rewritten := []byte(strings.Replace(string(sealed), checksumDirective, prefix, 1))
+		sums, intact, err := ReadChecksums(rewritten)
+		require.NoError(t, err, prefix)
+		assert.True(t, intact, prefix)
+		assert.Equal(t, "specsum", sums.Spec, prefix)
+		assert.Contains(t, string(Reseal(rewritten)), "\n"+checksumDirective+"spec=specsum ", "resealing writes the directive")
+	}
+}
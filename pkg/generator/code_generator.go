@@ -6,75 +6,243 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/yourusername/gofsm-gen/pkg/model"
 )
 
-// CodeGenerator generates Go code from FSM models
+// GeneratedMarker is the comment that identifies files written by gofsm-gen
+const GeneratedMarker = "// Code generated by gofsm-gen. DO NOT EDIT."
+
+// CodeGenerator generates Go code from FSM models. A CodeGenerator is safe for
+// concurrent use: its parsed templates are executed but never modified, and what
+// depends on a model, such as the functions following its naming options, lives
+// in per-call state or in clones cached under a lock. Generation does not modify
+// models either, so one model can be generated by several goroutines at once.
 type CodeGenerator struct {
 	templates *template.Template
+
+	// funcs are the template functions added with WithTemplateFuncs
+	funcs template.FuncMap
+
+	// sprig adds SprigFuncs, with WithSprigFuncs
+	sprig bool
+
+	// dir is the template directory, and overrides are the directories of
+	// WithTemplateOverrides
+	dir       string
+	overrides []string
+
+	// debug receives the output of WithTemplateDebug, which debugMu keeps whole
+	// for each template when models are generated concurrently
+	debug   io.Writer
+	debugMu sync.Mutex
+
+	// namingTemplates caches the templates cloned for naming options, keyed by
+	// the options, so that they are cloned once rather than for every model
+	namingMu        sync.Mutex
+	namingTemplates map[string]*template.Template
+}
+
+// GeneratorOption configures a CodeGenerator
+type GeneratorOption func(*CodeGenerator)
+
+// WithTemplateFuncs adds functions for custom templates to call. They override
+// the built-in functions of the same name, even those following the naming
+// options of a model.
+func WithTemplateFuncs(funcs map[string]any) GeneratorOption {
+	return func(g *CodeGenerator) {
+		if g.funcs == nil {
+			g.funcs = template.FuncMap{}
+		}
+		for name, fn := range funcs {
+			g.funcs[name] = fn
+		}
+	}
+}
+
+// WithTemplateOverrides parses the templates in dir after the others, so that
+// they can redefine the extension blocks of the default templates, such as
+// extra_machine_methods, without copying the template set. Any other named
+// template can be redefined the same way.
+func WithTemplateOverrides(dir string) GeneratorOption {
+	return func(g *CodeGenerator) {
+		g.overrides = append(g.overrides, dir)
+	}
 }
 
 // NewCodeGenerator creates a new code generator
-func NewCodeGenerator() (*CodeGenerator, error) {
-	return NewCodeGeneratorWithTemplateDir("")
+func NewCodeGenerator(opts ...GeneratorOption) (*CodeGenerator, error) {
+	return NewCodeGeneratorWithTemplateDir("", opts...)
 }
 
 // NewCodeGeneratorWithTemplateDir creates a new code generator with a custom template directory
-func NewCodeGeneratorWithTemplateDir(templateDir string) (*CodeGenerator, error) {
+func NewCodeGeneratorWithTemplateDir(templateDir string, opts ...GeneratorOption) (*CodeGenerator, error) {
+	g := &CodeGenerator{}
+	for _, opt := range opts {
+		opt(g)
+	}
+
 	if templateDir == "" {
-		// Find the templates directory relative to the current working directory
-		cwd, err := os.Getwd()
+		dir, err := DefaultTemplateDir()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get working directory: %w", err)
+			return nil, err
 		}
+		templateDir = dir
+	}
 
-		// Try to find templates directory
-		possiblePaths := []string{
-			filepath.Join(cwd, "templates"),
-			filepath.Join(cwd, "..", "..", "templates"),
-			filepath.Join(cwd, "..", "..", "..", "templates"),
+	tmpl := template.New("").Funcs(TemplateFuncs())
+	if g.sprig {
+		tmpl.Funcs(SprigFuncs())
+	}
+	tmpl, err := tmpl.Funcs(g.funcs).ParseGlob(filepath.Join(templateDir, "*.tmpl"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse templates from %s: %w", templateDir, err)
+	}
+	for _, dir := range g.overrides {
+		if tmpl, err = tmpl.ParseGlob(filepath.Join(dir, "*.tmpl")); err != nil {
+			return nil, fmt.Errorf("failed to parse template overrides from %s: %w", dir, err)
 		}
+	}
 
-		for _, path := range possiblePaths {
-			if _, err := os.Stat(path); err == nil {
-				templateDir = path
-				break
-			}
-		}
+	g.templates = tmpl
+	g.dir = templateDir
+	return g, nil
+}
 
-		if templateDir == "" {
-			return nil, fmt.Errorf("could not find templates directory")
-		}
+// DefaultTemplateDir returns the directory of the bundled templates, which
+// NewCodeGenerator uses, looked up relative to the current working directory
+func DefaultTemplateDir() (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
 	}
 
-	tmpl, err := template.New("").Funcs(TemplateFuncs()).ParseGlob(filepath.Join(templateDir, "*.tmpl"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse templates from %s: %w", templateDir, err)
+	// Try to find templates directory
+	possiblePaths := []string{
+		filepath.Join(cwd, "templates"),
+		filepath.Join(cwd, "..", "..", "templates"),
+		filepath.Join(cwd, "..", "..", "..", "templates"),
 	}
 
-	return &CodeGenerator{
-		templates: tmpl,
-	}, nil
+	for _, path := range possiblePaths {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("could not find templates directory")
 }
 
 // Generate generates code for the given FSM model
 func (g *CodeGenerator) Generate(model *model.FSMModel) ([]byte, error) {
-	if model == nil {
-		return nil, fmt.Errorf("model cannot be nil")
+	return g.execute("state_machine.tmpl", model)
+}
+
+// GenerateTests generates table-driven tests exercising every transition,
+// guard rejection, and invalid event of the given FSM model
+func (g *CodeGenerator) GenerateTests(model *model.FSMModel) ([]byte, error) {
+	return g.execute("test.tmpl", model)
+}
+
+// GenerateTestkit generates test helpers for consumers of the machine: a test
+// machine wrapper with assertions and guard/action spies recording call order
+func (g *CodeGenerator) GenerateTestkit(model *model.FSMModel) ([]byte, error) {
+	return g.execute("testkit.tmpl", model)
+}
+
+// GenerateDiagram generates a Mermaid state diagram of the given FSM model
+func (g *CodeGenerator) GenerateDiagram(model *model.FSMModel) ([]byte, error) {
+	return g.execute("diagram.tmpl", model)
+}
+
+// execute renders the named template for the given model
+func (g *CodeGenerator) execute(name string, model *model.FSMModel) ([]byte, error) {
+	model, err := prepare(model)
+	if err != nil {
+		return nil, err
+	}
+	return g.executeData(name, model, model)
+}
+
+// executeData renders the named template with data, which wraps the given model.
+// Templates that render the package of the model need data to wrap the model
+// prepare returns.
+func (g *CodeGenerator) executeData(name string, model *model.FSMModel, data any) ([]byte, error) {
+	model, err := prepare(model)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIdentifiers(model); err != nil {
+		return nil, err
 	}
 
-	if model.Package == "" {
-		model.Package = "main"
+	tmpl, err := g.templatesFor(model)
+	if err != nil {
+		return nil, err
 	}
 
-	var buf bytes.Buffer
-	if err := g.templates.ExecuteTemplate(&buf, "state_machine.tmpl", model); err != nil {
+	buf := buffers.Get().(*bytes.Buffer)
+	defer buffers.Put(buf)
+	buf.Reset()
+	if err := g.executeTemplate(tmpl, buf, name, data); err != nil {
 		return nil, fmt.Errorf("failed to execute template: %w", err)
 	}
 
-	return buf.Bytes(), nil
+	return seal(bytes.Clone(buf.Bytes()), model.Source.Checksum), nil
+}
+
+// buffers holds output buffers for reuse, so that generating many files does not
+// grow a buffer to the size of each from scratch
+var buffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// templatesFor returns the templates with the functions following the naming
+// options of model, which are cloned only when the options change them, once
+// for each distinct set of options
+func (g *CodeGenerator) templatesFor(model *model.FSMModel) (*template.Template, error) {
+	naming := model.Options.Naming
+	if len(naming.Acronyms) == 0 && !naming.Initialisms {
+		return g.templates, nil
+	}
+
+	key := fmt.Sprintf("%#v", naming)
+	g.namingMu.Lock()
+	defer g.namingMu.Unlock()
+	if tmpl, ok := g.namingTemplates[key]; ok {
+		return tmpl, nil
+	}
+
+	tmpl, err := g.templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	funcs := modelFuncs(model)
+	for name := range g.funcs {
+		delete(funcs, name)
+	}
+	tmpl = tmpl.Funcs(funcs)
+	if g.namingTemplates == nil {
+		g.namingTemplates = make(map[string]*template.Template)
+	}
+	g.namingTemplates[key] = tmpl
+	return tmpl, nil
+}
+
+// prepare checks the model and returns it with generation defaults filled in.
+// The defaults go into a copy rather than the model of the caller, which may be
+// generating it in other goroutines at the same time.
+func prepare(m *model.FSMModel) (*model.FSMModel, error) {
+	if m == nil {
+		return nil, fmt.Errorf("model cannot be nil")
+	}
+
+	if m.Package == "" {
+		defaulted := *m
+		defaulted.Package = "main"
+		return &defaulted, nil
+	}
+	return m, nil
 }
 
 // GenerateTo generates code and writes it to the given writer
@@ -87,3 +255,41 @@ func (g *CodeGenerator) GenerateTo(model *model.FSMModel, w io.Writer) error {
 	_, err = w.Write(code)
 	return err
 }
+
+// DefaultOutputSuffix is appended to the machine name to form the default output file name
+const DefaultOutputSuffix = "_fsm.gen.go"
+
+// DefaultOutputName returns the conventional output file name for a model
+func DefaultOutputName(model *model.FSMModel) string {
+	return OutputName(model, DefaultOutputSuffix)
+}
+
+// OutputName returns the output file name for a model with the given suffix
+func OutputName(model *model.FSMModel, suffix string) string {
+	return snakeCase(model.Name) + suffix
+}
+
+// TestOutputName returns the test file name that accompanies the given output file
+func TestOutputName(out string) string {
+	return strings.TrimSuffix(out, ".go") + "_test.go"
+}
+
+// TestkitOutputName returns the conventional testkit file name for a model
+func TestkitOutputName(model *model.FSMModel) string {
+	return snakeCase(model.Name) + "_testkit.go"
+}
+
+// DiagramOutputName returns the conventional Mermaid diagram file name for a model
+func DiagramOutputName(model *model.FSMModel) string {
+	return snakeCase(model.Name) + "_fsm.mmd"
+}
+
+// IsGenerated reports whether src was produced by gofsm-gen
+func IsGenerated(src []byte) bool {
+	for _, line := range strings.SplitN(string(src), "\n", 10) {
+		if strings.TrimSpace(line) == GeneratedMarker {
+			return true
+		}
+	}
+	return false
+}
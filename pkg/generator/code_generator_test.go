@@ -1,12 +1,21 @@
 package generator
 
 import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+	"github.com/yourusername/gofsm-gen/pkg/simulator"
 )
 
 // createOrderStateMachine creates a realistic order state machine model for testing
@@ -276,3 +285,3079 @@ func TestTemplateFunctions(t *testing.T) {
 		})
 	}
 }
+
+func TestTemplateFunctions_Helpers(t *testing.T) {
+	funcs := TemplateFuncs()
+
+	pluralize := funcs["pluralize"].(func(string) string)
+	for input, expected := range map[string]string{
+		"event":  "events",
+		"status": "statuses",
+		"box":    "boxes",
+		"batch":  "batches",
+		"entry":  "entries",
+		"day":    "days",
+		"":       "",
+	} {
+		assert.Equal(t, expected, pluralize(input), input)
+	}
+
+	assert.Equal(t, `"say \"hi\""`, funcs["quote"].(func(string) string)(`say "hi"`))
+
+	wrap := funcs["commentWrap"].(func(int, string) string)
+	assert.Equal(t, "// The order is paid\n// and ready to ship.", wrap(22, "The order is paid and ready to ship."))
+	assert.Equal(t, "// averyveryverylongword\n// fits", wrap(10, "averyveryverylongword fits"))
+	assert.Equal(t, "", wrap(80, "  "))
+
+	doc := funcs["docComment"].(func(string, string) string)
+	assert.Equal(t, "\t// First paragraph.\n\t//\n\t// Second paragraph.", doc("\t", "First paragraph.\n\n  Second\nparagraph."))
+	assert.Equal(t, "", doc("\t", "\n"))
+
+	receiver := funcs["receiverLetter"].(func(string) string)
+	assert.Equal(t, "o", receiver("OrderStateMachine"))
+	assert.Equal(t, "é", receiver("État"))
+	assert.Equal(t, "", receiver(""))
+
+	goType := funcs["goType"].(func(any) string)
+	assert.Equal(t, "float64", goType(model.FieldFloat))
+	assert.Equal(t, "int", goType("int"))
+
+	duration := funcs["goDuration"].(func(time.Duration) string)
+	assert.Equal(t, "48 * time.Hour", duration(48*time.Hour))
+	assert.Equal(t, "90 * time.Minute", duration(90*time.Minute))
+	assert.Equal(t, "1500 * time.Millisecond", duration(1500*time.Millisecond))
+	assert.Equal(t, "7 * time.Nanosecond", duration(7))
+
+	groups := funcs["eventGroups"].(func([]*model.Transition) [][]*model.Transition)
+	fsm := createOrderStateMachine(t)
+	fallback := &model.Transition{From: "pending", To: "rejected", Event: "approve"}
+	fsm.AddTransition(fallback)
+	pending := fsm.GetTransitionsByState()["pending"]
+	assert.Equal(t, [][]*model.Transition{{fsm.Transitions[0], fallback}, {fsm.Transitions[1]}}, groups(pending))
+	assert.Empty(t, groups(nil))
+}
+
+func TestWithTemplateFuncs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "state_machine.tmpl"),
+		[]byte(`{{shout .Name}} {{title "order_id"}} {{pluralize "entry"}}`), 0o600))
+
+	gen, err := NewCodeGeneratorWithTemplateDir(dir,
+		WithTemplateFuncs(map[string]any{"shout": strings.ToUpper}),
+		WithTemplateFuncs(map[string]any{"title": func(s string) string { return "T(" + s + ")" }}),
+	)
+	require.NoError(t, err)
+
+	fsm := createOrderStateMachine(t)
+	fsm.Options.Naming.Initialisms = true
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Equal(t, "ORDERSTATEMACHINE T(order_id) entries", string(code))
+
+	_, err = NewCodeGeneratorWithTemplateDir(dir)
+	assert.ErrorContains(t, err, `function "shout" not defined`)
+}
+
+func TestWithTemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "audit.tmpl"), []byte(`
+{{define "extra_imports"}}
+	"strings"
+{{- end}}
+{{define "extra_state_methods"}}
+
+// Label returns the state name in upper case
+func (s {{.Name}}State) Label() string {
+	return strings.ToUpper(s.String())
+}
+{{- end}}
+{{define "extra_event_methods"}}
+
+// IsApprove reports whether the event approves
+func (e {{.Name}}Event) IsApprove() bool {
+	return e == {{eventConst . "approve"}}
+}
+{{- end}}
+{{define "extra_context_fields"}}
+	Note string
+{{- end}}
+{{define "extra_machine_fields"}}
+	audit []string
+{{- end}}
+{{define "extra_machine_methods"}}
+
+// Audit records a line in the audit log of the machine
+func (sm *{{.Name}}) Audit(line string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.audit = append(sm.audit, line)
+}
+{{- end}}
+`), 0o600))
+
+	gen, err := NewCodeGenerator(WithTemplateOverrides(dir))
+	require.NoError(t, err)
+	fsm := createOrderStateMachine(t)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "\t\"strings\"\n)")
+	assert.Contains(t, codeStr, "func (s OrderStateMachineState) Label() string {")
+	assert.Contains(t, codeStr, "func (e OrderStateMachineEvent) IsApprove() bool {")
+	assert.Contains(t, codeStr, "\tNote string\n}")
+	assert.Contains(t, codeStr, "\taudit []string\n}")
+	assert.Contains(t, codeStr, "func (sm *OrderStateMachine) Audit(line string) {")
+
+	split, err := gen.GenerateSplit(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(split[3].Content), "func (sm *OrderStateMachine) Audit(line string) {")
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go":      code,
+		"order_state_machine_fsm.gen_test.go": tests,
+	})
+
+	plain, err := NewCodeGenerator()
+	require.NoError(t, err)
+	unchanged, err := plain.Generate(fsm)
+	require.NoError(t, err)
+	assert.NotContains(t, string(unchanged), "Audit", "the extension blocks are empty by default")
+
+	_, err = NewCodeGenerator(WithTemplateOverrides(filepath.Join(dir, "missing")))
+	assert.ErrorContains(t, err, "failed to parse template overrides")
+}
+
+func TestConstantNames(t *testing.T) {
+	fsm, err := model.NewFSMModel("Order", "pending")
+	require.NoError(t, err)
+
+	assert.Equal(t, "OrderStatePending", stateConst(fsm, "pending"))
+	assert.Equal(t, "OrderEventFetchUrl", eventConst(fsm, "fetch_url"))
+
+	fsm.Options.Naming = model.Naming{Constants: model.ConstantSuffix, Acronyms: []string{"URL"}}
+	assert.Equal(t, "OrderPendingState", stateConst(fsm, "pending"))
+	assert.Equal(t, "OrderFetchURLEvent", eventConst(fsm, "fetch_url"))
+	assert.Equal(t, "FetchURL", modelFuncs(fsm)["title"].(func(string) string)("fetchUrl"))
+
+	fsm.Options.Naming.OmitMachineName = true
+	assert.Equal(t, "PendingState", stateConst(fsm, "pending"))
+}
+
+func TestPascalCase_Initialisms(t *testing.T) {
+	initialisms := model.Naming{Initialisms: true}
+	tests := []struct {
+		input    string
+		naming   model.Naming
+		expected string
+	}{
+		{"order_id_received", model.Naming{}, "OrderIdReceived"},
+		{"order_id_received", initialisms, "OrderIDReceived"},
+		{"call_api", initialisms, "CallAPI"},
+		{"sku-updated", initialisms, "SKUUpdated"},
+		{"fetchHttpUrl", initialisms, "FetchHTTPURL"},
+		{"orderIDReceived", initialisms, "OrderIDReceived"},
+		{"identify", initialisms, "Identify"},
+		{"card_declined", model.Naming{Initialisms: true, Acronyms: []string{"card"}}, "CARDDeclined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			assert.Equal(t, tt.expected, pascalCase(tt.input, tt.naming))
+		})
+	}
+
+	fsm, err := model.NewFSMModel("Order", "pending")
+	require.NoError(t, err)
+	fsm.Options.Naming = initialisms
+	assert.Equal(t, "OrderEventOrderIDReceived", eventConst(fsm, "order_id_received"))
+	assert.Equal(t, "OrderIDReceived", modelFuncs(fsm)["title"].(func(string) string)("order_id_received"))
+}
+
+func TestCodeGenerator_Generate_NamingTemplatesCached(t *testing.T) {
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	newModel := func(acronyms ...string) *model.FSMModel {
+		fsm, err := model.NewFSMModel("Fetcher", "idle")
+		require.NoError(t, err)
+		require.NoError(t, fsm.AddState(&model.State{Name: "idle"}))
+		require.NoError(t, fsm.AddState(&model.State{Name: "done"}))
+		require.NoError(t, fsm.AddEvent(&model.Event{Name: "fetch"}))
+		require.NoError(t, fsm.AddTransition(&model.Transition{From: "idle", To: "done", Event: "fetch", Guard: "api_up", Action: "call_url"}))
+		fsm.Options.Naming.Acronyms = acronyms
+		return fsm
+	}
+
+	for _, tt := range []struct {
+		acronyms []string
+		want     string
+	}{
+		{[]string{"API"}, "APIUp"},
+		{[]string{"URL"}, "CallURL"},
+		{[]string{"API"}, "APIUp"},
+	} {
+		code, err := gen.Generate(newModel(tt.acronyms...))
+		require.NoError(t, err)
+		assert.Contains(t, string(code), tt.want, "the templates cloned for %v follow its acronyms", tt.acronyms)
+	}
+	assert.Len(t, gen.namingTemplates, 2, "the templates are cloned once for each set of naming options")
+}
+
+func TestCodeGenerator_Concurrent(t *testing.T) {
+	sequential, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	// Every goroutine generates these same models, so that the race detector sees
+	// generation share them as well as the generator
+	withAcronyms := createOrderStateMachine(t)
+	withAcronyms.Options.Naming.Acronyms = []string{"ID"}
+	withInitialisms := createOrderStateMachine(t)
+	withInitialisms.Options.Naming.Initialisms = true
+	withoutPackage := createOrderStateMachine(t)
+	withoutPackage.Package = ""
+	models := []*model.FSMModel{withAcronyms, withInitialisms, withoutPackage}
+	specs, err := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	require.NoError(t, err)
+	for _, spec := range specs {
+		fsm, err := parser.NewYAMLParser().ParseFile(spec)
+		require.NoError(t, err)
+		models = append(models, fsm)
+	}
+
+	renders := map[string]func(*CodeGenerator, *model.FSMModel) ([]byte, error){
+		"code":     (*CodeGenerator).Generate,
+		"tests":    (*CodeGenerator).GenerateTests,
+		"testkit":  (*CodeGenerator).GenerateTestkit,
+		"markdown": (*CodeGenerator).GenerateMarkdown,
+		"ts":       (*CodeGenerator).GenerateTypeScript,
+		"stream": func(g *CodeGenerator, m *model.FSMModel) ([]byte, error) {
+			var buf bytes.Buffer
+			err := g.GenerateStream(m, &buf)
+			return buf.Bytes(), err
+		},
+	}
+	want := make(map[string][]byte)
+	for name, render := range renders {
+		for i, m := range models {
+			code, err := render(sequential, m)
+			require.NoError(t, err)
+			want[fmt.Sprintf("%s/%d", name, i)] = code
+		}
+	}
+
+	// The shared generator clones its naming templates while other goroutines
+	// execute them
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	const workers = 8
+	var wg sync.WaitGroup
+	for w := range workers {
+		for name, render := range renders {
+			for i, m := range models {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					code, err := render(gen, m)
+					if assert.NoError(t, err) {
+						assert.Equal(t, string(want[fmt.Sprintf("%s/%d", name, i)]), string(code), "%s of model %d in worker %d", name, i, w)
+					}
+				}()
+			}
+		}
+	}
+	wg.Wait()
+
+	assert.Empty(t, withoutPackage.Package, "the package defaults to main without changing the model")
+	assert.Len(t, gen.namingTemplates, 2)
+}
+
+// runGeneratedPackage writes the generated files into a throwaway module and runs
+// "go test" on it, proving the output compiles and its tests pass
+func runGeneratedPackage(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+
+	out, err := testGeneratedPackage(t, files)
+	require.NoError(t, err, "generated package failed:\n%s", out)
+	return out
+}
+
+// testGeneratedPackage is like runGeneratedPackage but returns the go test error to the caller
+func testGeneratedPackage(t *testing.T, files map[string][]byte) (string, error) {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("compiling generated code is skipped in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module generated\n\ngo 1.21\n"), 0o600))
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+	}
+
+	cmd := exec.Command(goBin, "test", "-v", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestCodeGenerator_GenerateTests_OrderStateMachine(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+
+	testStr := string(tests)
+	assert.Contains(t, testStr, "package orders")
+	assert.Contains(t, testStr, "func TestOrderStateMachine_Transitions(t *testing.T)")
+	assert.Contains(t, testStr, "func TestOrderStateMachine_GuardRejections(t *testing.T)")
+	assert.Contains(t, testStr, "func TestOrderStateMachine_InvalidEvents(t *testing.T)")
+	assert.Contains(t, testStr, `name:  "pending on approve rejected by hasPayment"`,
+		"Guarded transitions should get a rejection case")
+	assert.Contains(t, testStr, `name:    "shipped on approve"`,
+		"Unhandled state/event pairs should get an invalid-event case")
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	out := runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go":      code,
+		"order_state_machine_fsm.gen_test.go": tests,
+	})
+	assert.Contains(t, out, "--- PASS: TestOrderStateMachine_Transitions/pending_on_approve")
+	assert.Contains(t, out, "--- PASS: TestOrderStateMachine_GuardRejections")
+	assert.Contains(t, out, "--- PASS: TestOrderStateMachine_InvalidEvents/approved_on_reject")
+}
+
+func TestCodeGenerator_Generate_Naming(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Options.ZeroState = model.ZeroStateUnspecified
+	fsm.Options.Naming = model.Naming{Constants: model.ConstantSuffix, OmitMachineName: true, Acronyms: []string{"CARD"}}
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "\tUnspecifiedState OrderStateMachineState = 0\n")
+	assert.Contains(t, codeStr, "\tPendingState OrderStateMachineState = ")
+	assert.Contains(t, codeStr, "\tApproveEvent OrderStateMachineEvent = ")
+	assert.Contains(t, codeStr, "\tChargeCARD func(ctx context.Context, from, to OrderStateMachineState, c *OrderStateMachineContext) error\n")
+	assert.NotContains(t, codeStr, "OrderStateMachineStatePending")
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	split, err := gen.GenerateSplit(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(split[0].Content), "\t\treturn PendingState, nil\n")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go":      code,
+		"order_state_machine_fsm.gen_test.go": tests,
+	})
+}
+
+func TestCodeGenerator_Generate_NonASCIINames(t *testing.T) {
+	fsm, err := model.NewFSMModel("Bestellung", "offen")
+	require.NoError(t, err)
+	fsm.Package = "orders"
+	for _, name := range []string{"offen", "übermittelt", "état_payé"} {
+		state, err := model.NewState(name)
+		require.NoError(t, err)
+		require.NoError(t, fsm.AddState(state))
+	}
+	for _, name := range []string{"übermitteln", "payer"} {
+		event, err := model.NewEvent(name)
+		require.NoError(t, err)
+		require.NoError(t, fsm.AddEvent(event))
+	}
+	submit, _ := model.NewTransition("offen", "übermittelt", "übermitteln")
+	submit.Guard = "äußerstGültig"
+	pay, _ := model.NewTransition("übermittelt", "état_payé", "payer")
+	require.NoError(t, fsm.AddTransition(submit))
+	require.NoError(t, fsm.AddTransition(pay))
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "BestellungStateÜbermittelt")
+	assert.Contains(t, codeStr, "BestellungStateÉtatPayé")
+	assert.Contains(t, codeStr, "\tÄußerstGültig func(")
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	runGeneratedPackage(t, map[string][]byte{
+		"bestellung_fsm.gen.go":      code,
+		"bestellung_fsm.gen_test.go": tests,
+	})
+}
+
+func TestCodeGenerator_Generate_Descriptions(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Description = "OrderStateMachine tracks an order.\n\nIt ends when the order ships."
+	fsm.GetState("pending").Description = "Awaits payment."
+	fsm.GetEvent("approve").Description = "Payment arrived."
+	for _, tr := range fsm.Transitions {
+		if tr.Action == "chargeCard" {
+			tr.Description = "Charges the card on file."
+		}
+	}
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "// OrderStateMachine is the generated state machine\n//\n"+
+		"// OrderStateMachine tracks an order.\n//\n// It ends when the order ships.\ntype OrderStateMachine struct {")
+	assert.Contains(t, codeStr, "\t// OrderStateMachineStatePending is the \"pending\" state. Awaits payment.\n"+
+		"\tOrderStateMachineStatePending OrderStateMachineState")
+	assert.Contains(t, codeStr, "\t// OrderStateMachineEventApprove is the \"approve\" event. Payment arrived.\n")
+	assert.Contains(t, codeStr, "\t// On approve from pending to approved: Charges the card on file.\n\tChargeCard func(")
+	assert.Contains(t, codeStr, "\t// On approve from pending to approved: Charges the card on file.\n\tHasPayment func(")
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	runGeneratedPackage(t, map[string][]byte{
+		"order_fsm.gen.go":      code,
+		"order_fsm.gen_test.go": tests,
+	})
+}
+
+func TestCodeGenerator_Generate_Deprecated(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	rejected := fsm.GetState("rejected")
+	rejected.Description = "Declined."
+	rejected.Deprecated = true
+	rejected.DeprecationReason = "Declined orders are cancelled instead."
+	fsm.GetEvent("ship").Deprecated = true
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "\t// OrderStateMachineStateRejected is the \"rejected\" state. Declined.\n"+
+		"\t//\n\t// Deprecated: Declined orders are cancelled instead.\n\tOrderStateMachineStateRejected ")
+	assert.Contains(t, codeStr, "\t// Deprecated: the \"ship\" event is no longer used.\n\tOrderStateMachineEventShip ")
+}
+
+func TestCheckIdentifiers(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	require.NoError(t, checkIdentifiers(fsm))
+
+	state, err := model.NewState("_1")
+	require.NoError(t, err)
+	require.NoError(t, fsm.AddState(state))
+	assert.EqualError(t, checkIdentifiers(fsm), `state "_1" becomes "1", which is not a Go identifier`)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	_, err = gen.Generate(fsm)
+	assert.ErrorContains(t, err, "not a Go identifier")
+
+	fsm = createOrderStateMachine(t)
+	fsm.Name = "Order Machine"
+	assert.EqualError(t, checkIdentifiers(fsm), `machine name "Order Machine" is not a Go identifier`)
+}
+
+func TestPascalCase_NonASCII(t *testing.T) {
+	assert.Equal(t, "ÉtatPayé", toPascalCase("état_payé"))
+	assert.Equal(t, "ÜberGang", toPascalCase("überGang"))
+	assert.Equal(t, "保留中", toPascalCase("保留中"))
+	assert.Equal(t, "étatPayé", camelCase("état_payé"))
+	assert.Equal(t, "état_payé", snakeCase("ÉtatPayé"))
+
+	assert.True(t, isIdentifier("ÉtatPayé"))
+	assert.True(t, isIdentifier("_x1"))
+	assert.False(t, isIdentifier("1x"))
+	assert.False(t, isIdentifier(""))
+	assert.False(t, isIdentifier("a-b"))
+}
+
+func TestCodeGenerator_GenerateTests_SharedCallbacks(t *testing.T) {
+	// The same guard and action protect two transitions; each must be declared once
+	fsm, err := model.NewFSMModel("Ticket", "open")
+	require.NoError(t, err)
+	fsm.Package = "support"
+
+	for _, name := range []string{"open", "escalated", "closed"} {
+		state, _ := model.NewState(name)
+		require.NoError(t, fsm.AddState(state))
+	}
+	for _, name := range []string{"escalate", "close"} {
+		event, _ := model.NewEvent(name)
+		require.NoError(t, fsm.AddEvent(event))
+	}
+	for _, tr := range [][3]string{{"open", "escalated", "escalate"}, {"open", "closed", "close"}, {"escalated", "closed", "close"}} {
+		transition, _ := model.NewTransition(tr[0], tr[1], tr[2])
+		transition.Guard = "isAgent"
+		transition.Action = "recordHistory"
+		require.NoError(t, fsm.AddTransition(transition))
+	}
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(code), "IsAgent func("), "Guard fields must be deduplicated")
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+
+	runGeneratedPackage(t, map[string][]byte{
+		"ticket_fsm.gen.go":      code,
+		"ticket_fsm.gen_test.go": tests,
+	})
+}
+
+func TestCodeGenerator_Generate_ChaosHelpers(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.NotContains(t, string(code), "FireRandomPermitted", "Chaos helpers are opt-in")
+	assert.NotContains(t, string(code), `"math/rand"`)
+
+	fsm.Options.ChaosHelpers = true
+	fsm.GetEvent("reject").Weight = 3
+
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "func (sm *OrderStateMachine) FireRandomPermitted(ctx context.Context, r *rand.Rand) (OrderStateMachineEvent, bool, error)")
+	assert.Contains(t, codeStr, "func (sm *OrderStateMachine) RunChaos(ctx context.Context, cfg OrderStateMachineChaosConfig) (int, error)")
+	assert.Contains(t, codeStr, "OrderStateMachineEventReject: 3,")
+
+	// Drive many random walks and check that only legal workflow orderings occur
+	chaosTest := []byte(`package orders
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestChaosWalksOnlyLegalPaths(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+		var trail []OrderStateMachineEvent
+
+		fired, err := sm.RunChaos(context.Background(), OrderStateMachineChaosConfig{
+			Rand: rand.New(rand.NewSource(seed)),
+			OnFire: func(event OrderStateMachineEvent, err error) {
+				if err != nil {
+					t.Fatalf("seed %d: %s failed: %v", seed, event, err)
+				}
+				trail = append(trail, event)
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fired != len(trail) || fired == 0 {
+			t.Fatalf("seed %d: fired %d events, trail %v", seed, fired, trail)
+		}
+		switch sm.State() {
+		case OrderStateMachineStateShipped, OrderStateMachineStateRejected:
+		default:
+			t.Fatalf("seed %d: chaos run stopped in non-terminal state %s", seed, sm.State())
+		}
+	}
+}
+
+func TestChaosSkipsZeroWeightEvents(t *testing.T) {
+	OrderStateMachineChaosWeights[OrderStateMachineEventApprove] = 0
+	defer func() { OrderStateMachineChaosWeights[OrderStateMachineEventApprove] = 1 }()
+
+	for seed := int64(0); seed < 20; seed++ {
+		sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+		event, ok, err := sm.FireRandomPermitted(context.Background(), rand.New(rand.NewSource(seed)))
+		if err != nil || !ok || event != OrderStateMachineEventReject {
+			t.Fatalf("seed %d: fired %s ok=%v err=%v, want reject", seed, event, ok, err)
+		}
+	}
+}
+`)
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"chaos_test.go":                  chaosTest,
+	})
+}
+
+func TestCodeGenerator_Generate_Coverage(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.NotContains(t, string(code), "WriteOrderStateMachineCoverage", "Coverage counters are opt-in")
+	assert.NotContains(t, string(code), `"sync/atomic"`)
+
+	fsm.Options.Coverage = true
+
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "var orderStateMachineCoverage [3]atomic.Uint64")
+	assert.Contains(t, codeStr, "func WriteOrderStateMachineCoverage(w io.Writer) error")
+	assert.Contains(t, codeStr, "func ResetOrderStateMachineCoverage()")
+
+	// Only transitions that complete are counted, and counts survive across machines
+	coverageTest := []byte(`package orders
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCoverageCountsCompletedTransitions(t *testing.T) {
+	ctx := context.Background()
+	paid := true
+	guards := OrderStateMachineGuards{HasPayment: func(context.Context, *OrderStateMachineContext) bool { return paid }}
+
+	for i := 0; i < 2; i++ {
+		sm := NewOrderStateMachine(guards, OrderStateMachineActions{})
+		if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if err := sm.Transition(ctx, OrderStateMachineEventShip); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	paid = false
+	sm := NewOrderStateMachine(guards, OrderStateMachineActions{})
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err == nil {
+		t.Fatal("guard should have rejected approve")
+	}
+
+	var b strings.Builder
+	if err := WriteOrderStateMachineCoverage(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := ` + "`" + `{"machine":"OrderStateMachine","transitions":[` +
+		`{"from":"pending","event":"approve","to":"approved","guard":"hasPayment","count":2},` +
+		`{"from":"pending","event":"reject","to":"rejected","count":0},` +
+		`{"from":"approved","event":"ship","to":"shipped","count":1}]}` + "`" + `
+	if got := strings.TrimSpace(b.String()); got != want {
+		t.Fatalf("profile:\n got %s\nwant %s", got, want)
+	}
+
+	ResetOrderStateMachineCoverage()
+	b.Reset()
+	if err := WriteOrderStateMachineCoverage(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), ` + "`" + `"count":1` + "`" + `) || strings.Contains(b.String(), ` + "`" + `"count":2` + "`" + `) {
+		t.Fatalf("counts not reset: %s", b.String())
+	}
+}
+`)
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"coverage_test.go":               coverageTest,
+	})
+}
+
+func TestCodeGenerator_Generate_TraceRecorder(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.NotContains(t, string(code), "TraceRecorder", "Trace recording is opt-in")
+	assert.Contains(t, string(code), "func (sm *OrderStateMachine) Transition(ctx context.Context, event OrderStateMachineEvent) error {\n\tsm.mu.Lock()")
+
+	fsm.Options.Trace = true
+
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "func WithTraceRecorder(r *OrderStateMachineTraceRecorder) OrderStateMachineOption")
+	assert.Contains(t, codeStr, "func (sm *OrderStateMachine) TransitionWithPayload(ctx context.Context, event OrderStateMachineEvent, payload any) (err error)")
+	assert.Contains(t, codeStr, "func (r *OrderStateMachineTraceRecorder) Export(w io.Writer) error")
+
+	// The exported trace must be readable by "gofsm-gen replay"
+	traceTest := []byte(`package orders
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestTraceRecorder(t *testing.T) {
+	ctx := context.Background()
+	recorder := NewOrderStateMachineTraceRecorder(3)
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{}, WithTraceRecorder(recorder))
+
+	if err := sm.TransitionWithPayload(ctx, OrderStateMachineEventApprove, map[string]int{"order": 7}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Transition(ctx, OrderStateMachineEventReject); err == nil {
+		t.Fatal("reject should be invalid in approved")
+	}
+	if err := sm.Transition(ctx, OrderStateMachineEventShip); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := recorder.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("recorded %d entries, want 3", len(entries))
+	}
+	if e := entries[0]; e.Event != "approve" || e.From != "pending" || e.To != "approved" || e.PayloadHash == "" || e.Error != "" {
+		t.Fatalf("unexpected first entry %+v", e)
+	}
+	if e := entries[1]; e.From != "approved" || e.To != "approved" || e.Error == "" || e.PayloadHash != "" {
+		t.Fatalf("unexpected rejection entry %+v", e)
+	}
+
+	// The oldest entry is dropped once the limit is reached
+	other := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{}, WithTraceRecorder(recorder))
+	if err := other.Transition(ctx, OrderStateMachineEventReject); err != nil {
+		t.Fatal(err)
+	}
+	entries = recorder.Entries()
+	if len(entries) != 3 || entries[0].Event != "reject" || entries[2].To != "rejected" {
+		t.Fatalf("unexpected entries after the limit %+v", entries)
+	}
+
+	f, err := os.Create(os.Getenv("ORDER_TRACE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := recorder.Export(f); err != nil {
+		t.Fatal(err)
+	}
+}
+`)
+
+	tracePath := filepath.Join(t.TempDir(), "trace.json")
+	t.Setenv("ORDER_TRACE", tracePath)
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"trace_test.go":                  traceTest,
+	})
+
+	f, err := os.Open(tracePath)
+	require.NoError(t, err)
+	defer f.Close()
+	trace, err := simulator.ReadTrace(f)
+	require.NoError(t, err)
+	assert.Equal(t, "OrderStateMachine", trace.Machine)
+	require.Len(t, trace.Entries, 3)
+	assert.Empty(t, trace.Replay(fsm), "A trace recorded by the machine agrees with its own spec")
+}
+
+func TestCodeGenerator_Generate_Publisher(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.NotContains(t, string(code), "Publisher", "Publishing is opt-in")
+
+	fsm.Options.Publisher = true
+
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "func WithPublisher(p OrderStateMachinePublisher) OrderStateMachineOption")
+	assert.Contains(t, codeStr, "type OrderStateMachineTransitionRecord struct {")
+	assert.Contains(t, codeStr, "func (p *OrderStateMachineMessagePublisher) Publish(ctx context.Context, record OrderStateMachineTransitionRecord) error")
+
+	publisherTest := []byte(`package orders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestPublisher(t *testing.T) {
+	ctx := context.Background()
+	var entered bool
+	var messages [][]byte
+	publisher := &OrderStateMachineMessagePublisher{
+		Send: func(ctx context.Context, record OrderStateMachineTransitionRecord, message []byte) error {
+			if !entered {
+				t.Fatal("the record must be published after the entry action")
+			}
+			messages = append(messages, message)
+			return nil
+		},
+	}
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{}, WithPublisher(publisher))
+	sm.entryActions.NotifyCustomer = func(ctx context.Context, c *OrderStateMachineContext) error {
+		entered = true
+		return nil
+	}
+	entered = true
+
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Transition(ctx, OrderStateMachineEventReject); err == nil {
+		t.Fatal("reject should be invalid in approved")
+	}
+	entered = false
+	if err := sm.Transition(ctx, OrderStateMachineEventShip); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("published %d messages, want 2", len(messages))
+	}
+
+	var record OrderStateMachineTransitionRecord
+	if err := json.Unmarshal(messages[1], &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Machine != "OrderStateMachine" || record.From != "approved" || record.To != "shipped" || record.Event != "ship" || record.OccurredAt.IsZero() {
+		t.Fatalf("unexpected record %+v", record)
+	}
+
+	// A failed publish is returned after the state has changed
+	failure := errors.New("broker down")
+	other := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{}, WithPublisher(OrderStateMachinePublisherFunc(
+		func(ctx context.Context, record OrderStateMachineTransitionRecord) error { return failure },
+	)))
+	if err := other.Transition(ctx, OrderStateMachineEventReject); !errors.Is(err, failure) {
+		t.Fatalf("got %v, want the publish error", err)
+	}
+	if other.State() != OrderStateMachineStateRejected {
+		t.Fatalf("state %s, want rejected", other.State())
+	}
+}
+`)
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"publisher_test.go":              publisherTest,
+	})
+}
+
+func TestCodeGenerator_Generate_Callbacks(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.NotContains(t, string(code), "OrderStateMachineCallbacks", "Callbacks are opt-in")
+
+	fsm.Options.Callbacks = true
+
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "type OrderStateMachineCallbacks interface {")
+	assert.Contains(t, codeStr, "\tHasPayment(ctx context.Context, c *OrderStateMachineContext) bool\n")
+	assert.Contains(t, codeStr, "\tLogEntry(ctx context.Context, c *OrderStateMachineContext) error\n")
+	assert.Contains(t, codeStr, "func NewOrderStateMachineWithCallbacks(callbacks OrderStateMachineCallbacks, opts ...OrderStateMachineOption) *OrderStateMachine")
+	assert.Contains(t, codeStr, "func NewOrderStateMachineNoopCallbacks() OrderStateMachineCallbacks")
+	assert.Contains(t, codeStr, "func NewOrderStateMachineStrictCallbacks() OrderStateMachineCallbacks")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"callbacks_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type orderCallbacks struct {
+	OrderStateMachineCallbacksUnimplemented
+	calls []string
+}
+
+func (c *orderCallbacks) HasPayment(context.Context, *OrderStateMachineContext) bool {
+	return true
+}
+
+func (c *orderCallbacks) ChargeCard(_ context.Context, from, to OrderStateMachineState, _ *OrderStateMachineContext) error {
+	c.calls = append(c.calls, "chargeCard "+from.String()+" "+to.String())
+	return nil
+}
+
+func (c *orderCallbacks) LogExit(context.Context, *OrderStateMachineContext) error {
+	c.calls = append(c.calls, "logExit")
+	return nil
+}
+
+func TestCallbacks(t *testing.T) {
+	callbacks := &orderCallbacks{}
+	sm := NewOrderStateMachineWithCallbacks(callbacks)
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"logExit", "chargeCard pending approved"}; !reflect.DeepEqual(callbacks.calls, want) {
+		t.Fatalf("calls = %v, want %v", callbacks.calls, want)
+	}
+	if err := sm.Transition(context.Background(), OrderStateMachineEventShip); !errors.Is(err, ErrUnimplementedOrderStateMachineCallback) {
+		t.Fatalf("ship with an unimplemented action: %v", err)
+	}
+
+	if (OrderStateMachineCallbacksUnimplemented{}).HasPayment(context.Background(), nil) {
+		t.Fatal("unimplemented guards must reject")
+	}
+}
+
+func TestNoopCallbacks(t *testing.T) {
+	sm := NewOrderStateMachineWithCallbacks(NewOrderStateMachineNoopCallbacks())
+	for _, event := range []OrderStateMachineEvent{OrderStateMachineEventApprove, OrderStateMachineEventShip} {
+		if err := sm.Transition(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sm.State() != OrderStateMachineStateShipped {
+		t.Fatalf("state = %s", sm.State())
+	}
+}
+
+type partialCallbacks struct {
+	OrderStateMachineCallbacks
+}
+
+func (partialCallbacks) HasPayment(context.Context, *OrderStateMachineContext) bool {
+	return true
+}
+
+func TestStrictCallbacks(t *testing.T) {
+	sm := NewOrderStateMachineWithCallbacks(partialCallbacks{NewOrderStateMachineStrictCallbacks()})
+	defer func() {
+		if r := recover(); r != "OrderStateMachine: state action logExit is not implemented" {
+			t.Fatalf("recovered %v", r)
+		}
+	}()
+	sm.Transition(context.Background(), OrderStateMachineEventApprove)
+	t.Fatal("the unimplemented exit action did not panic")
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_ContextFields(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "type OrderStateMachineContext struct {\n\t// Add your custom fields here\n}")
+
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "amount", Type: model.FieldInt, Description: "Amount is the order total in cents"}))
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "express", Type: model.FieldBool}))
+	require.NoError(t, fsm.AddGuardCondition(&model.GuardCondition{Name: "hasPayment", When: "amount > 0"}))
+	require.NoError(t, fsm.Validate())
+
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "\t// Amount is the order total in cents\n\tAmount int\n")
+	assert.Contains(t, codeStr, "\tExpress bool\n")
+	assert.NotContains(t, codeStr, "Add your custom fields here")
+	assert.Contains(t, codeStr, "\t// HasPayment must pass when amount > 0\n\tHasPayment func(ctx context.Context, c *OrderStateMachineContext) bool")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"context_test.go": []byte(`package orders
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGuardReadsContextField(t *testing.T) {
+	sm := NewOrderStateMachine(OrderStateMachineGuards{
+		HasPayment: func(_ context.Context, c *OrderStateMachineContext) bool { return c.Amount > 0 },
+	}, OrderStateMachineActions{})
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err == nil {
+		t.Fatal("approve should be rejected without an amount")
+	}
+	sm.SetContext(&OrderStateMachineContext{Amount: 1200})
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_ImmutableContext(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "amount", Type: model.FieldInt, Description: "the order total in cents"}))
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "express", Type: model.FieldBool}))
+	fsm.Options.ImmutableContext = true
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "\t// the order total in cents\n\tamount int\n")
+	assert.Contains(t, codeStr, "// Amount returns the amount field: the order total in cents\nfunc (c *OrderStateMachineContext) Amount() int {")
+	assert.Contains(t, codeStr, "func (c *OrderStateMachineContext) WithExpress(v bool) *OrderStateMachineContext {")
+	assert.Contains(t, codeStr, "func UpdateOrderStateMachineContext(ctx context.Context, next *OrderStateMachineContext) error {")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"context_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestImmutableContext(t *testing.T) {
+	sm := NewOrderStateMachine(OrderStateMachineGuards{
+		HasPayment: func(_ context.Context, c *OrderStateMachineContext) bool { return c.Amount() > 0 },
+	}, OrderStateMachineActions{
+		ChargeCard: func(ctx context.Context, from, to OrderStateMachineState, c *OrderStateMachineContext) error {
+			return UpdateOrderStateMachineContext(ctx, c.WithAmount(0).WithExpress(true))
+		},
+		SendRejectionEmail: func(ctx context.Context, from, to OrderStateMachineState, c *OrderStateMachineContext) error {
+			if err := UpdateOrderStateMachineContext(ctx, c.WithAmount(-1)); err != nil {
+				return err
+			}
+			return errors.New("mail server down")
+		},
+	})
+	initial := (&OrderStateMachineContext{}).WithAmount(1200)
+	sm.SetContext(initial)
+
+	if err := sm.Transition(context.Background(), OrderStateMachineEventReject); err == nil {
+		t.Fatal("reject should fail")
+	}
+	if sm.Context() != initial {
+		t.Fatal("the update of a failed action must be discarded")
+	}
+
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if got := sm.Context(); got.Amount() != 0 || !got.Express() {
+		t.Fatalf("context = %+v, want the update of the action", got)
+	}
+	if initial.Amount() != 1200 || initial.Express() {
+		t.Fatalf("initial context changed to %+v", initial)
+	}
+
+	data, err := sm.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if err := restored.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Context().Express() {
+		t.Fatalf("snapshot %s lost the context", data)
+	}
+
+	if err := UpdateOrderStateMachineContext(context.Background(), initial); !errors.Is(err, ErrNoOrderStateMachineTransition) {
+		t.Fatalf("update outside a transition: error = %v", err)
+	}
+}
+`),
+	})
+
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "type", Type: model.FieldString}))
+	_, err = gen.Generate(fsm)
+	assert.ErrorContains(t, err, `context field "type" becomes "type", which is not a Go identifier`)
+}
+
+func TestCodeGenerator_Generate_RequiredContext(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "trackingNumber", Type: model.FieldString}))
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "confirmed", Type: model.FieldBool}))
+	fsm.GetState("approved").RequiredContext = []string{"trackingNumber", "confirmed"}
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "type OrderStateMachineContextRequirementError struct {")
+	assert.Contains(t, codeStr, "\t\t\tif sm.context.TrackingNumber == \"\" {\n"+
+		"\t\t\t\treturn &OrderStateMachineContextRequirementError{State: OrderStateMachineStateApproved, Field: \"trackingNumber\"}\n")
+	assert.Contains(t, codeStr, "\t\t\tif !sm.context.Confirmed {\n")
+	assert.Equal(t, 2, strings.Count(codeStr, "return &OrderStateMachineContextRequirementError{"), "only transitions into approved check")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"requires_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRequiredContext(t *testing.T) {
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{
+		ChargeCard: func(_ context.Context, _, _ OrderStateMachineState, c *OrderStateMachineContext) error {
+			c.TrackingNumber = "1Z999"
+			return nil
+		},
+	})
+
+	err := sm.Transition(context.Background(), OrderStateMachineEventApprove)
+	var missing *OrderStateMachineContextRequirementError
+	if !errors.As(err, &missing) || missing.State != OrderStateMachineStateApproved || missing.Field != "confirmed" {
+		t.Fatalf("error = %v, want confirmed to be missing", err)
+	}
+	if err.Error() != "state approved requires context field confirmed" {
+		t.Fatalf("message = %q", err)
+	}
+	if sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("state = %v, want the transition to be rejected", sm.State())
+	}
+
+	sm.Context().Confirmed = true
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateApproved {
+		t.Fatalf("state = %v", sm.State())
+	}
+}
+`),
+	})
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	testStr := string(tests)
+	assert.Contains(t, testStr, "\tsm.context.TrackingNumber = \"set\"\n\tsm.context.Confirmed = true\n")
+	assert.Contains(t, testStr, `name:  "pending on approve without confirmed"`)
+	out := runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go":      code,
+		"order_state_machine_fsm.gen_test.go": tests,
+	})
+	assert.Contains(t, out, "--- PASS: TestOrderStateMachine_Transitions/pending_on_approve")
+	assert.Contains(t, out, "--- PASS: TestOrderStateMachine_ContextRequirements/pending_on_approve_without_trackingNumber")
+
+	fsm.Options.ImmutableContext = true
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "\t\t\tif sm.context.trackingNumber == \"\" {\n")
+	tests, err = gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(tests), "unset: func(c *OrderStateMachineContext) { c.trackingNumber = \"\" },")
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go":      code,
+		"order_state_machine_fsm.gen_test.go": tests,
+	})
+}
+
+func TestCodeGenerator_Generate_Reentrancy(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.NotContains(t, string(code), "TransitionKey", "reentrancy detection is opt-in")
+
+	fsm.Options.Reentrancy = model.ReentrancyError
+	require.NoError(t, fsm.Validate())
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "var ErrReentrantOrderStateMachineTransition = errors.New(\"reentrant OrderStateMachine transition\")")
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"reentrancy_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReentrantTransition(t *testing.T) {
+	var sm *OrderStateMachine
+	sm = NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{
+		ChargeCard: func(ctx context.Context, _, _ OrderStateMachineState, _ *OrderStateMachineContext) error {
+			return sm.Transition(ctx, OrderStateMachineEventShip)
+		},
+	})
+	err := sm.Transition(context.Background(), OrderStateMachineEventApprove)
+	if !errors.Is(err, ErrReentrantOrderStateMachineTransition) {
+		t.Fatalf("error = %v, want ErrReentrantOrderStateMachineTransition", err)
+	}
+	if sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("state = %v", sm.State())
+	}
+
+	other := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	sm = NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{
+		ChargeCard: func(ctx context.Context, _, _ OrderStateMachineState, _ *OrderStateMachineContext) error {
+			return other.Transition(ctx, OrderStateMachineEventApprove)
+		},
+	})
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatalf("transitions of other machines are not reentrant: %v", err)
+	}
+	if other.State() != OrderStateMachineStateApproved {
+		t.Fatalf("other state = %v", other.State())
+	}
+}
+`),
+	})
+
+	fsm.Options.Reentrancy = model.ReentrancyQueue
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "func (sm *OrderStateMachine) transition(ctx context.Context, event OrderStateMachineEvent) error {")
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"reentrancy_test.go": []byte(`package orders
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQueuedTransition(t *testing.T) {
+	var sm *OrderStateMachine
+	queue := []OrderStateMachineEvent{OrderStateMachineEventShip}
+	sm = NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{
+		ChargeCard: func(ctx context.Context, _, _ OrderStateMachineState, _ *OrderStateMachineContext) error {
+			for _, event := range queue {
+				if err := sm.Transition(ctx, event); err != nil {
+					return err
+				}
+			}
+			if sm.currentState != OrderStateMachineStatePending {
+				t.Errorf("queued events must wait for the transition, state = %v", sm.currentState)
+			}
+			return nil
+		},
+	})
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateShipped {
+		t.Fatalf("state = %v, want the queued ship to be handled", sm.State())
+	}
+
+	queue = []OrderStateMachineEvent{OrderStateMachineEventReject, OrderStateMachineEventShip}
+	sm = NewOrderStateMachine(OrderStateMachineGuards{}, sm.actions)
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err == nil {
+		t.Fatal("the failed queued reject must be returned")
+	}
+	if sm.State() != OrderStateMachineStateApproved || len(sm.queued) != 0 {
+		t.Fatalf("state = %v, queued = %v; want the events after reject dropped", sm.State(), sm.queued)
+	}
+}
+`),
+	})
+
+	fsm.Options.Trace = true
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "sm.queued = append(sm.queued, orderStateMachineQueuedEvent{event: event, payload: payload})")
+	runGeneratedPackage(t, map[string][]byte{"order_state_machine_fsm.gen.go": code})
+
+	fsm.Options.Reentrancy = "retry"
+	assert.EqualError(t, fsm.Validate(), `invalid options: reentrancy policy "retry" is not one of "error", "queue"`)
+}
+
+func TestCodeGenerator_Generate_RecoverPanics(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Options.RecoverPanics = true
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "type OrderStateMachineActionPanickedError struct {")
+	assert.Contains(t, string(code), "\t\"runtime/debug\"\n")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"panic_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRecoverPanics(t *testing.T) {
+	sm := NewOrderStateMachine(OrderStateMachineGuards{
+		HasPayment: func(context.Context, *OrderStateMachineContext) bool { panic("nil wallet") },
+	}, OrderStateMachineActions{
+		SendRejectionEmail: func(context.Context, OrderStateMachineState, OrderStateMachineState, *OrderStateMachineContext) error {
+			panic(io.ErrClosedPipe)
+		},
+	})
+
+	err := sm.Transition(context.Background(), OrderStateMachineEventApprove)
+	var panicked *OrderStateMachineActionPanickedError
+	if !errors.As(err, &panicked) || panicked.Action != "hasPayment" || panicked.Value != "nil wallet" {
+		t.Fatalf("error = %v, want the panic of hasPayment", err)
+	}
+	if !strings.Contains(string(panicked.Stack), "TestRecoverPanics") {
+		t.Fatalf("stack misses the callback:\n%s", panicked.Stack)
+	}
+	if sm.CanTransition(context.Background(), OrderStateMachineEventApprove) {
+		t.Fatal("a panicking guard does not pass")
+	}
+
+	err = sm.Transition(context.Background(), OrderStateMachineEventReject)
+	if !errors.Is(err, io.ErrClosedPipe) || err.Error() != "transition action failed: sendRejectionEmail panicked: io: read/write on closed pipe" {
+		t.Fatalf("error = %v, want the panic of sendRejectionEmail", err)
+	}
+	if sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("state = %v, want the machine left as on an error", sm.State())
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_UnhandledEvents(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Options.UnhandledEvent = model.UnhandledEventIgnore
+	fsm.GetState("approved").UnhandledEvent = model.UnhandledEventLog
+	fsm.GetState("rejected").UnhandledEvent = model.UnhandledEventError
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(code), "return fmt.Errorf(\"no transitions defined from state %s\", currentState)"), "only rejected keeps the error")
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	testStr := string(tests)
+	assert.Contains(t, testStr, "\t\t\tname:    \"approved on reject\",\n"+
+		"\t\t\tfrom:    OrderStateMachineStateApproved,\n\t\t\tevent:   OrderStateMachineEventReject,\n\t\t\tignored: true,\n")
+	assert.Contains(t, testStr, "\t\t\tname:    \"rejected on ship\",\n"+
+		"\t\t\tfrom:    OrderStateMachineStateRejected,\n\t\t\tevent:   OrderStateMachineEventShip,\n\t\t},\n")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go":      code,
+		"order_state_machine_fsm.gen_test.go": tests,
+		"unhandled_test.go": []byte(`package orders
+
+import (
+	"context"
+	"testing"
+)
+
+type recordingLogger struct{ infos []string }
+
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.infos = append(l.infos, msg) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) {}
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {}
+
+func TestUnhandledEvents(t *testing.T) {
+	logger := &recordingLogger{}
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{}, WithLogger(logger))
+	ctx := context.Background()
+
+	if err := sm.Transition(ctx, OrderStateMachineEventShip); err != nil || sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("pending ignores ship: error = %v, state = %v", err, sm.State())
+	}
+	if len(logger.infos) != 0 {
+		t.Fatalf("ignored events are not logged: %v", logger.infos)
+	}
+
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	logger.infos = nil
+	if err := sm.Transition(ctx, OrderStateMachineEventReject); err != nil || sm.State() != OrderStateMachineStateApproved {
+		t.Fatalf("approved drops reject: error = %v, state = %v", err, sm.State())
+	}
+	if len(logger.infos) != 1 || logger.infos[0] != "Ignored unhandled event" {
+		t.Fatalf("infos = %v, want the dropped event logged", logger.infos)
+	}
+
+	rejected := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if err := rejected.Transition(ctx, OrderStateMachineEventReject); err != nil {
+		t.Fatal(err)
+	}
+	if err := rejected.Transition(ctx, OrderStateMachineEventShip); err == nil {
+		t.Fatal("rejected overrides the policy with error")
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_IgnoredEvents(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.GetState("approved").IgnoredEvents = []string{"reject"}
+	fsm.GetState("approved").UnhandledEvent = model.UnhandledEventLog
+	fsm.GetState("rejected").IgnoredEvents = []string{"ship", "approve"}
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "\t\tcase OrderStateMachineEventShip, OrderStateMachineEventApprove:\n\t\t\t// Ignored in this state\n\t\t\treturn nil\n")
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	testStr := string(tests)
+	assert.Contains(t, testStr, `name:  "rejected ignores ship"`)
+	assert.NotContains(t, testStr, `name:    "rejected on ship"`, "ignored events are not invalid")
+	assert.Contains(t, testStr, `name:    "rejected on reject"`)
+
+	out := runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go":      code,
+		"order_state_machine_fsm.gen_test.go": tests,
+		"ignored_test.go": []byte(`package orders
+
+import (
+	"context"
+	"testing"
+)
+
+type recordingLogger struct{ infos []string }
+
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.infos = append(l.infos, msg) }
+func (l *recordingLogger) Error(msg string, args ...interface{}) {}
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {}
+
+func TestIgnoredEvents(t *testing.T) {
+	logger := &recordingLogger{}
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{}, WithLogger(logger))
+	ctx := context.Background()
+
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	logger.infos = nil
+	if err := sm.Transition(ctx, OrderStateMachineEventReject); err != nil || sm.State() != OrderStateMachineStateApproved {
+		t.Fatalf("approved ignores reject: error = %v, state = %v", err, sm.State())
+	}
+	if len(logger.infos) != 0 {
+		t.Fatalf("infos = %v, want ignored events not logged even under the log policy", logger.infos)
+	}
+	if sm.CanTransition(ctx, OrderStateMachineEventReject) {
+		t.Fatal("ignored events make no transition")
+	}
+
+	rejected := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if err := rejected.Transition(ctx, OrderStateMachineEventReject); err != nil {
+		t.Fatal(err)
+	}
+	if err := rejected.Transition(ctx, OrderStateMachineEventShip); err != nil || rejected.State() != OrderStateMachineStateRejected {
+		t.Fatalf("rejected ignores ship: error = %v, state = %v", err, rejected.State())
+	}
+	if err := rejected.Transition(ctx, OrderStateMachineEventReject); err == nil || err.Error() != "no transitions defined from state rejected" {
+		t.Fatalf("error = %v, want events not ignored rejected", err)
+	}
+}
+`),
+	})
+	assert.Contains(t, out, "--- PASS: TestOrderStateMachine_IgnoredEvents/approved_ignores_reject")
+}
+
+func TestCodeGenerator_Generate_TransitionInterceptors(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.BeforeTransition = "audit"
+	fsm.AfterTransition = "touchUpdatedAt"
+	require.NoError(t, fsm.Validate())
+	assert.Equal(t, []string{"audit", "chargeCard", "notifyShipping", "sendRejectionEmail", "touchUpdatedAt"}, fsm.GetActionNames())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(code), "// Execute the before_transition action"), "once for every transition")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"interceptors_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInterceptors(t *testing.T) {
+	var calls []string
+	var auditErr error
+	record := func(name string) func(context.Context, OrderStateMachineState, OrderStateMachineState, *OrderStateMachineContext) error {
+		return func(_ context.Context, from, to OrderStateMachineState, _ *OrderStateMachineContext) error {
+			calls = append(calls, name+" "+from.String()+"->"+to.String())
+			if name == "audit" {
+				return auditErr
+			}
+			return nil
+		}
+	}
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{
+		Audit:          record("audit"),
+		ChargeCard:     record("charge"),
+		TouchUpdatedAt: record("touch"),
+	})
+	ctx := context.Background()
+
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"audit pending->approved", "charge pending->approved", "touch pending->approved"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	calls, auditErr = nil, errors.New("audit log unavailable")
+	if err := sm.Transition(ctx, OrderStateMachineEventShip); !errors.Is(err, auditErr) {
+		t.Fatalf("error = %v, want the failed before_transition action", err)
+	}
+	if sm.State() != OrderStateMachineStateApproved || len(calls) != 1 {
+		t.Fatalf("state = %v, calls = %v; want the transition aborted", sm.State(), calls)
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_GuardedCandidates(t *testing.T) {
+	for _, recoverPanics := range []bool{false, true} {
+		t.Run(fmt.Sprintf("recover panics %v", recoverPanics), func(t *testing.T) {
+			fsm := createOrderStateMachine(t)
+			fsm.Options.RecoverPanics = recoverPanics
+			fraud, _ := model.NewTransition("pending", "rejected", "approve")
+			fraud.Guard = "isFraudulent"
+			fsm.AddTransition(fraud)
+			require.NoError(t, fsm.Validate())
+			assert.Len(t, fsm.GetCandidates("pending", "approve"), 2)
+
+			gen, err := NewCodeGenerator()
+			require.NoError(t, err)
+
+			code, err := gen.Generate(fsm)
+			require.NoError(t, err)
+			assert.Equal(t, 2, strings.Count(string(code), "case OrderStateMachineEventApprove:\n\t\t\t// Check the guard of the transition to approved"),
+				"the candidates share one case in Transition and in CanTransition")
+			tests, err := gen.GenerateTests(fsm)
+			require.NoError(t, err)
+			assert.Contains(t, string(tests), `name:  "pending on approve rejected by hasPayment and isFraudulent",`)
+			assert.Contains(t, string(tests), `name:  "pending on approve to approved",`)
+			assert.Contains(t, string(tests), "name:     \"pending on approve to rejected\",\n"+
+				"\t\t\tfrom:     OrderStateMachineStatePending,\n"+
+				"\t\t\tevent:    OrderStateMachineEventApprove,\n"+
+				"\t\t\twant:     OrderStateMachineStateRejected,\n"+
+				"\t\t\trejected: []string{\"hasPayment\"},",
+				"each candidate has a row rejecting the guards of the candidates before it")
+
+			runGeneratedPackage(t, map[string][]byte{
+				"order_state_machine_fsm.gen.go":  code,
+				"order_state_machine_fsm_test.go": tests,
+				"candidates_test.go": []byte(`package orders
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCandidates(t *testing.T) {
+	calls := map[string]int{}
+	var paid, fraudulent bool
+	sm := NewOrderStateMachine(OrderStateMachineGuards{
+		HasPayment: func(context.Context, *OrderStateMachineContext) bool {
+			calls["hasPayment"]++
+			return paid
+		},
+		IsFraudulent: func(context.Context, *OrderStateMachineContext) bool {
+			calls["isFraudulent"]++
+			return fraudulent
+		},
+	}, OrderStateMachineActions{})
+	ctx := context.Background()
+
+	if got := sm.PermittedEvents(); len(got) != 2 || got[0] != OrderStateMachineEventApprove || got[1] != OrderStateMachineEventReject {
+		t.Fatalf("PermittedEvents = %v, want approve once and reject", got)
+	}
+	if sm.CanTransition(ctx, OrderStateMachineEventApprove) {
+		t.Fatal("CanTransition = true with every guard failing")
+	}
+	calls = map[string]int{}
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err == nil {
+		t.Fatal("Transition succeeded with every guard failing")
+	}
+	if calls["hasPayment"] != 1 || calls["isFraudulent"] != 1 {
+		t.Fatalf("guard calls = %v, want each candidate tried once", calls)
+	}
+
+	fraudulent = true
+	calls = map[string]int{}
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateRejected {
+		t.Fatalf("state = %v, want the transition of the guard that passed", sm.State())
+	}
+	if calls["hasPayment"] != 1 || calls["isFraudulent"] != 1 {
+		t.Fatalf("guard calls = %v, want each candidate tried once", calls)
+	}
+
+	sm = NewOrderStateMachine(OrderStateMachineGuards{
+		HasPayment: func(context.Context, *OrderStateMachineContext) bool {
+			calls["hasPayment"]++
+			return true
+		},
+		IsFraudulent: func(context.Context, *OrderStateMachineContext) bool {
+			calls["isFraudulent"]++
+			return true
+		},
+	}, OrderStateMachineActions{})
+	calls = map[string]int{}
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateApproved || calls["isFraudulent"] != 0 {
+		t.Fatalf("state = %v, guard calls = %v; want the first candidate taken", sm.State(), calls)
+	}
+}
+`),
+			})
+		})
+	}
+}
+
+func TestCodeGenerator_Generate_PureGuards(t *testing.T) {
+	for _, recoverPanics := range []bool{false, true} {
+		t.Run(fmt.Sprintf("recover panics %v", recoverPanics), func(t *testing.T) {
+			fsm := createOrderStateMachine(t)
+			fsm.Options.RecoverPanics = recoverPanics
+			fsm.Options.ChaosHelpers = true
+			fsm.Options.Reentrancy = model.ReentrancyQueue
+			fsm.AfterTransition = "audit"
+			require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "paid", Type: model.FieldBool}))
+			require.NoError(t, fsm.AddGuardCondition(&model.GuardCondition{Name: "hasPayment", When: "paid", Pure: true}))
+			expedite, _ := model.NewEvent("expedite")
+			fsm.AddEvent(expedite)
+			rush, _ := model.NewTransition("pending", "shipped", "expedite")
+			rush.Guard = "hasPayment"
+			fsm.AddTransition(rush)
+			fsm.Transitions[2].Guard = "hasPayment"
+			require.NoError(t, fsm.AddGuardCondition(&model.GuardCondition{Name: "canReject", Pure: true}))
+			fsm.Transitions[1].Guard = "canReject"
+			require.NoError(t, fsm.Validate())
+
+			gen, err := NewCodeGenerator()
+			require.NoError(t, err)
+
+			code, err := gen.Generate(fsm)
+			require.NoError(t, err)
+			codeStr := string(code)
+			assert.Contains(t, codeStr, "\t// It is pure: each evaluation of an event runs it at most once.\n\tHasPayment func(")
+			assert.Contains(t, codeStr, "\t// CanReject is pure: each evaluation of an event runs it at most once.\n\tCanReject func(")
+			assert.Contains(t, codeStr, "func (sm *OrderStateMachine) pureGuard(")
+			assert.Equal(t, 2, strings.Count(codeStr, "ctx = sm.withGuardResults(ctx)"),
+				"transition and CanTransition start an evaluation")
+			assert.NotContains(t, codeStr, "sm.guards.HasPayment(ctx, sm.context)")
+			assert.NotContains(t, codeStr, "sm.guards.CanReject(ctx, sm.context)")
+
+			tests, err := gen.GenerateTests(fsm)
+			require.NoError(t, err)
+
+			runGeneratedPackage(t, map[string][]byte{
+				"order_state_machine_fsm.gen.go":  code,
+				"order_state_machine_fsm_test.go": tests,
+				"pure_test.go": []byte(`package orders
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+func TestPureGuards(t *testing.T) {
+	calls, otherCalls, rejectCalls := 0, 0, 0
+	var audit func(ctx context.Context, to OrderStateMachineState) error
+	var sm *OrderStateMachine
+	newMachine := func() *OrderStateMachine {
+		calls = 0
+		audit = nil
+		sm = NewOrderStateMachine(OrderStateMachineGuards{
+			HasPayment: func(_ context.Context, c *OrderStateMachineContext) bool {
+				calls++
+				return c.Paid
+			},
+			CanReject: func(context.Context, *OrderStateMachineContext) bool {
+				rejectCalls++
+				return true
+			},
+		}, OrderStateMachineActions{
+			ChargeCard: func(_ context.Context, _, _ OrderStateMachineState, c *OrderStateMachineContext) error {
+				c.Paid = false
+				return nil
+			},
+			Audit: func(ctx context.Context, _, to OrderStateMachineState, _ *OrderStateMachineContext) error {
+				if audit != nil {
+					return audit(ctx, to)
+				}
+				return nil
+			},
+		})
+		sm.context.Paid = true
+		return sm
+	}
+	ctx := context.Background()
+
+	newMachine()
+	for i := 0; i < 2; i++ {
+		if !sm.CanTransition(ctx, OrderStateMachineEventApprove) {
+			t.Fatal("CanTransition = false with the guard passing")
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("guard calls = %d, want one per evaluation", calls)
+	}
+	if err := sm.Transition(ctx, OrderStateMachineEventReject); err != nil || rejectCalls != 1 {
+		t.Fatalf("Transition = %v with %d calls of the callback guard, want one", err, rejectCalls)
+	}
+
+	newMachine()
+	done, err := sm.TransitionAll(ctx, OrderStateMachineEventApprove, OrderStateMachineEventShip)
+	if err == nil || done != 1 || calls != 2 {
+		t.Fatalf("TransitionAll = %d, %v with %d guard calls; want ship to see the payment chargeCard spent", done, err, calls)
+	}
+
+	newMachine()
+	audit = func(ctx context.Context, to OrderStateMachineState) error {
+		if to == OrderStateMachineStateApproved {
+			sm.context.Paid = true
+			return sm.Transition(ctx, OrderStateMachineEventShip)
+		}
+		return nil
+	}
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateShipped || calls != 2 {
+		t.Fatalf("state = %v, guard calls = %d; want the event the interceptor fired evaluated on its own", sm.State(), calls)
+	}
+
+	other := NewOrderStateMachine(OrderStateMachineGuards{
+		HasPayment: func(context.Context, *OrderStateMachineContext) bool {
+			otherCalls++
+			return false
+		},
+	}, OrderStateMachineActions{})
+	newMachine()
+	audit = func(ctx context.Context, _ OrderStateMachineState) error {
+		if other.CanTransition(ctx, OrderStateMachineEventApprove) {
+			t.Error("another machine reused the result of the guard of sm")
+		}
+		if err := other.Transition(ctx, OrderStateMachineEventApprove); err == nil {
+			t.Error("another machine reused the result of the guard of sm")
+		}
+		return nil
+	}
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 || otherCalls != 2 {
+		t.Fatalf("guard calls = %d and %d, want each machine to run its own guard", calls, otherCalls)
+	}
+
+	for seed := int64(0); seed < 10; seed++ {
+		newMachine()
+		event, ok, err := sm.FireRandomPermitted(ctx, rand.New(rand.NewSource(seed)))
+		if !ok || err != nil {
+			t.Fatalf("FireRandomPermitted = %v, %v, %v", event, ok, err)
+		}
+		if event != OrderStateMachineEventReject && calls != 3 {
+			t.Fatalf("guard calls = %d firing %v, want the checks of approve and expedite and the transition to run it", calls, event)
+		}
+	}
+}
+`),
+			})
+		})
+	}
+}
+
+func TestCodeGenerator_Generate_IdempotencyKeys(t *testing.T) {
+	for _, reentrancy := range []model.ReentrancyPolicy{"", model.ReentrancyQueue} {
+		t.Run(fmt.Sprintf("reentrancy %q", reentrancy), func(t *testing.T) {
+			fsm := createOrderStateMachine(t)
+			fsm.Options.IdempotencyKeys = 2
+			fsm.Options.Reentrancy = reentrancy
+			track, _ := model.NewEvent("track")
+			fsm.AddEvent(track)
+			tracked, _ := model.NewTransition("shipped", "shipped", "track")
+			fsm.AddTransition(tracked)
+			require.NoError(t, fsm.Validate())
+
+			gen, err := NewCodeGenerator()
+			require.NoError(t, err)
+
+			code, err := gen.Generate(fsm)
+			require.NoError(t, err)
+			assert.Contains(t, string(code), "func (sm *OrderStateMachine) TransitionWithKey(ctx context.Context, event OrderStateMachineEvent, key string) error {")
+
+			files := map[string][]byte{
+				"order_state_machine_fsm.gen.go": code,
+				"keys_test.go": []byte(`package orders
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTransitionWithKey(t *testing.T) {
+	charged := 0
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{
+		ChargeCard: func(context.Context, OrderStateMachineState, OrderStateMachineState, *OrderStateMachineContext) error {
+			charged++
+			return nil
+		},
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := sm.TransitionWithKey(ctx, OrderStateMachineEventApprove, "msg-1"); err != nil {
+			t.Fatalf("delivery %d: %v", i+1, err)
+		}
+	}
+	if charged != 1 || sm.State() != OrderStateMachineStateApproved {
+		t.Fatalf("charged %d times, state = %v; want one transition", charged, sm.State())
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := sm.TransitionWithKey(ctx, OrderStateMachineEventReject, "msg-2"); err == nil {
+			t.Fatalf("delivery %d: reject is invalid in approved", i+1)
+		}
+	}
+	if sm.keys["msg-2"] || len(sm.keyOrder[OrderStateMachineStateApproved]) != 0 {
+		t.Fatal("a failed transition forgets its key")
+	}
+
+	if err := sm.TransitionWithKey(ctx, OrderStateMachineEventShip, "msg-3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.TransitionWithKey(ctx, OrderStateMachineEventApprove, "msg-1"); err != nil {
+		t.Fatalf("error = %v, want the key remembered by pending", err)
+	}
+
+	for _, key := range []string{"msg-4", "msg-5", "msg-6"} {
+		if err := sm.TransitionWithKey(ctx, OrderStateMachineEventTrack, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := sm.keyOrder[OrderStateMachineStateShipped]; len(got) != 2 || got[0] != "msg-5" {
+		t.Fatalf("keys of shipped = %v, want only the last 2", got)
+	}
+	if len(sm.keys) != 4 || sm.keys["msg-4"] || !sm.keys["msg-1"] || !sm.keys["msg-3"] {
+		t.Fatalf("keys = %v, want shipped to forget its oldest key and keep the keys of the others", sm.keys)
+	}
+}
+`),
+			}
+			if reentrancy == model.ReentrancyQueue {
+				files["callback_keys_test.go"] = []byte(`package orders
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTransitionWithKeyFromCallback(t *testing.T) {
+	var sm *OrderStateMachine
+	sm = NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{
+		ChargeCard: func(ctx context.Context, _, _ OrderStateMachineState, _ *OrderStateMachineContext) error {
+			for i := 0; i < 2; i++ {
+				if err := sm.TransitionWithKey(ctx, OrderStateMachineEventShip, "ship-1"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	ctx := context.Background()
+
+	if err := sm.TransitionWithKey(ctx, OrderStateMachineEventApprove, "approve-1"); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateShipped {
+		t.Fatalf("state = %v, want the keyed event the callback fired handled once", sm.State())
+	}
+	if got := sm.keyOrder[OrderStateMachineStatePending]; len(got) != 2 {
+		t.Fatalf("keys of pending = %v, want both keys remembered by the state they arrived in", got)
+	}
+}
+`)
+			}
+			runGeneratedPackage(t, files)
+		})
+	}
+}
+
+func TestCodeGenerator_Generate_TransitionAll(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	unapprove, _ := model.NewEvent("unapprove")
+	fsm.AddEvent(unapprove)
+	back, _ := model.NewTransition("approved", "pending", "unapprove")
+	fsm.AddTransition(back)
+	fsm.Transitions[0].Inverse = "unapprove"
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "func (sm *OrderStateMachine) TransitionAll(ctx context.Context, events ...OrderStateMachineEvent) (int, error) {")
+	assert.Contains(t, string(code), "func orderStateMachineInverse(from OrderStateMachineState, event OrderStateMachineEvent, to OrderStateMachineState) (OrderStateMachineEvent, bool) {")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"batch_test.go": []byte(`package orders
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTransitionAll(t *testing.T) {
+	ctx := context.Background()
+
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	n, err := sm.TransitionAll(ctx, OrderStateMachineEventApprove, OrderStateMachineEventShip)
+	if n != 2 || err != nil || sm.State() != OrderStateMachineStateShipped {
+		t.Fatalf("TransitionAll = %d, %v in %v; want both made", n, err, sm.State())
+	}
+
+	sm = NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	n, err = sm.TransitionAll(ctx, OrderStateMachineEventApprove, OrderStateMachineEventReject)
+	if n != 0 || err == nil || sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("TransitionAll = %d, %v in %v; want approve rolled back", n, err, sm.State())
+	}
+	if !strings.Contains(err.Error(), "event 2 (reject)") || !strings.HasSuffix(err.Error(), "; rolled back 1 transitions") {
+		t.Fatalf("error = %v", err)
+	}
+
+	sm = NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	n, err = sm.TransitionAll(ctx, OrderStateMachineEventApprove, OrderStateMachineEventShip, OrderStateMachineEventReject)
+	if n != 2 || err == nil || sm.State() != OrderStateMachineStateShipped {
+		t.Fatalf("TransitionAll = %d, %v in %v; want a stop at reject, as ship has no inverse", n, err, sm.State())
+	}
+	if !strings.HasPrefix(err.Error(), "event 3 (reject): ") {
+		t.Fatalf("error = %v", err)
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_Compensate(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	for _, name := range []string{"unapprove", "unship"} {
+		event, _ := model.NewEvent(name)
+		fsm.AddEvent(event)
+	}
+	unapprove, _ := model.NewTransition("approved", "pending", "unapprove")
+	fsm.AddTransition(unapprove)
+	unship, _ := model.NewTransition("shipped", "approved", "unship")
+	unship.Action = "cancelShipment"
+	fsm.AddTransition(unship)
+	fsm.Transitions[0].Compensate = "unapprove"
+	fsm.Transitions[2].Compensate = "unship"
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "func (sm *OrderStateMachine) Compensate(ctx context.Context) error {")
+	assert.Contains(t, string(code), "sm.compensations = append(sm.compensations, OrderStateMachineEventUnship)")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"compensate_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompensate(t *testing.T) {
+	ctx := context.Background()
+	cancelErr := errors.New("carrier unreachable")
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{
+		CancelShipment: func(context.Context, OrderStateMachineState, OrderStateMachineState, *OrderStateMachineContext) error {
+			return cancelErr
+		},
+	})
+
+	if _, err := sm.TransitionAll(ctx, OrderStateMachineEventApprove, OrderStateMachineEventShip); err != nil {
+		t.Fatal(err)
+	}
+	err := sm.Compensate(ctx)
+	if !errors.Is(err, cancelErr) || !strings.HasPrefix(err.Error(), "compensating with unship: ") {
+		t.Fatalf("Compensate = %v, want the failed unship", err)
+	}
+	if sm.State() != OrderStateMachineStateShipped {
+		t.Fatalf("state = %v, want shipped", sm.State())
+	}
+
+	cancelErr = nil
+	if err := sm.Compensate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("state = %v, want pending after compensating ship and approve", sm.State())
+	}
+	if err := sm.Compensate(ctx); err != nil || sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("Compensate = %v in %v; want nothing left to compensate", err, sm.State())
+	}
+
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.RestoreState("shipped"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Compensate(ctx); err != nil || sm.State() != OrderStateMachineStateShipped {
+		t.Fatalf("Compensate = %v in %v; want the history forgotten on restore", err, sm.State())
+	}
+}
+
+func TestCompensateRestored(t *testing.T) {
+	ctx := context.Background()
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if _, err := sm.TransitionAll(ctx, OrderStateMachineEventApprove, OrderStateMachineEventShip); err != nil {
+		t.Fatal(err)
+	}
+	data, err := sm.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), ` + "`" + `"compensations":["unapprove","unship"]` + "`" + `) {
+		t.Fatalf("snapshot = %s, want the compensations", data)
+	}
+
+	resumed := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if err := resumed.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := resumed.Compensate(ctx); err != nil || resumed.State() != OrderStateMachineStatePending {
+		t.Fatalf("Compensate = %v in %v; want the restored history compensated", err, resumed.State())
+	}
+
+	bad := strings.Replace(string(data), "unship", "ship", 1)
+	if err := resumed.Restore([]byte(bad)); err == nil || !strings.Contains(err.Error(), ` + "`" + `"ship" compensates no transition` + "`" + `) {
+		t.Fatalf("Restore = %v, want the unknown compensation rejected", err)
+	}
+	if resumed.State() != OrderStateMachineStatePending {
+		t.Fatalf("state = %v, want a rejected snapshot to change nothing", resumed.State())
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_SLA(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.GetState("approved").MaxDuration = time.Hour
+	fsm.Transitions[0].MaxDuration = 10 * time.Millisecond
+	require.NoError(t, fsm.Validate())
+	assert.True(t, fsm.HasSLAs())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "\tcase OrderStateMachineStateApproved:\n\t\treturn 1 * time.Hour\n")
+	assert.Contains(t, string(code), "sm.enteredAt, sm.sla = time.Now(), 10 * time.Millisecond")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"sla_test.go": []byte(`package orders
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSLA(t *testing.T) {
+	if OrderStateMachineStateSLA(OrderStateMachineStateApproved) != time.Hour || OrderStateMachineStateSLA(OrderStateMachineStatePending) != 0 {
+		t.Fatal("SLAs differ from the spec")
+	}
+
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if _, ok := sm.SLADeadline(); ok {
+		t.Fatal("pending has no SLA")
+	}
+
+	var mu sync.Mutex
+	var breaches []OrderStateMachineState
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- sm.WatchSLA(ctx, time.Millisecond, func(state OrderStateMachineState, overdue time.Duration) {
+			mu.Lock()
+			defer mu.Unlock()
+			breaches = append(breaches, state)
+		})
+	}()
+
+	if err := sm.Transition(ctx, OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	deadline, ok := sm.SLADeadline()
+	if !ok || time.Until(deadline) > 10*time.Millisecond {
+		t.Fatalf("deadline in %v, want the 10ms of the approve transition", time.Until(deadline))
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("WatchSLA = %v", err)
+	}
+	if len(breaches) != 1 || breaches[0] != OrderStateMachineStateApproved {
+		t.Fatalf("breaches = %v, want approved once", breaches)
+	}
+
+	if err := sm.Transition(context.Background(), OrderStateMachineEventShip); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sm.SLADeadline(); ok {
+		t.Fatal("shipped has no SLA")
+	}
+	if err := sm.RestoreState("approved"); err != nil {
+		t.Fatal(err)
+	}
+	if deadline, ok := sm.SLADeadline(); !ok || time.Until(deadline) < 59*time.Minute {
+		t.Fatalf("deadline in %v, want the hour of approved", time.Until(deadline))
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_Snapshot(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "amount", Type: model.FieldInt}))
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.Contains(t, string(code), "func (sm *OrderStateMachine) Snapshot() ([]byte, error)")
+	assert.Contains(t, string(code), "func (sm *OrderStateMachine) Restore(data []byte) error")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"snapshot_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	guards := OrderStateMachineGuards{HasPayment: func(context.Context, *OrderStateMachineContext) bool { return true }}
+	sm := NewOrderStateMachine(guards, OrderStateMachineActions{})
+	sm.SetContext(&OrderStateMachineContext{Amount: 1200})
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := sm.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), ` + "`" + `{"machine":"OrderStateMachine","state":"approved","context":{"Amount":1200}}` + "`" + `; got != want {
+		t.Fatalf("Snapshot() = %s, want %s", got, want)
+	}
+
+	resumed := NewOrderStateMachine(guards, OrderStateMachineActions{})
+	if err := resumed.Restore(data); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.State() != OrderStateMachineStateApproved || resumed.Context().Amount != 1200 {
+		t.Fatalf("restored %s with %+v", resumed.State(), resumed.Context())
+	}
+
+	if err := resumed.Restore([]byte(` + "`" + `{"machine":"Invoice","state":"approved"}` + "`" + `)); err == nil || err.Error() != "a snapshot of Invoice cannot be restored into OrderStateMachine" {
+		t.Fatalf("Restore(Invoice) = %v", err)
+	}
+	if err := resumed.Restore([]byte(` + "`" + `{"machine":"OrderStateMachine","state":"on_hold"}` + "`" + `)); !errors.Is(err, ErrUnknownOrderStateMachineState) {
+		t.Fatalf("Restore(on_hold) = %v", err)
+	}
+	if resumed.State() != OrderStateMachineStateApproved {
+		t.Fatalf("a failed restore changed the state to %s", resumed.State())
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_StateMigrations(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.NotContains(t, string(code), "MigrateOrderStateMachineState", "Machines without migrations have no migration helper")
+
+	value := 7
+	require.NoError(t, fsm.AddLegacyState(&model.LegacyState{Name: "review", To: "approved", Description: "renamed in v2"}))
+	require.NoError(t, fsm.AddLegacyState(&model.LegacyState{Name: "cancelled", Value: &value, To: "rejected"}))
+	require.NoError(t, fsm.Validate())
+
+	code, err = gen.Generate(fsm)
+	require.NoError(t, err)
+
+	codeStr := string(code)
+	assert.Contains(t, codeStr, "func MigrateOrderStateMachineState(name string) (OrderStateMachineState, error) {")
+	assert.Contains(t, codeStr, "\tcase \"review\": // renamed in v2\n\t\treturn OrderStateMachineStateApproved, nil\n")
+	assert.Contains(t, codeStr, "\t\t} else if v == 7 {\n\t\t\tstate = OrderStateMachineStateRejected\n")
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"migrate_test.go": []byte(`package orders
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMigrateLegacyStates(t *testing.T) {
+	for name, want := range map[string]OrderStateMachineState{
+		"review":    OrderStateMachineStateApproved,
+		"cancelled": OrderStateMachineStateRejected,
+		"shipped":   OrderStateMachineStateShipped,
+	} {
+		got, err := MigrateOrderStateMachineState(name)
+		if err != nil || got != want {
+			t.Errorf("MigrateOrderStateMachineState(%q) = %v, %v; want %v", name, got, err, want)
+		}
+	}
+	if _, err := MigrateOrderStateMachineState("lost"); !errors.Is(err, ErrUnknownOrderStateMachineState) {
+		t.Errorf("unknown names should be rejected, got %v", err)
+	}
+	if _, err := ParseOrderStateMachineState("review"); err == nil {
+		t.Error("ParseOrderStateMachineState should only accept declared states")
+	}
+
+	for src, want := range map[any]OrderStateMachineState{
+		"review":    OrderStateMachineStateApproved,
+		int64(7):    OrderStateMachineStateRejected,
+		int64(3):    OrderStateMachineStateShipped,
+	} {
+		var s OrderStateMachineState
+		if err := s.Scan(src); err != nil || s != want {
+			t.Errorf("Scan(%v) = %v, %v; want %v", src, s, err, want)
+		}
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_Generate_UnknownStatePolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  model.UnknownStatePolicy
+		runtime string
+	}{
+		{
+			name:   "error policy rejects unknown values",
+			policy: model.UnknownStateError,
+			runtime: `
+func TestUnknownStates(t *testing.T) {
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if err := sm.RestoreState("on_hold"); !errors.Is(err, ErrUnknownOrderStateMachineState) {
+		t.Fatalf("RestoreState(on_hold) error = %v, want ErrUnknownOrderStateMachineState", err)
+	}
+	var s OrderStateMachineState
+	if err := s.Scan(int64(99)); !errors.Is(err, ErrUnknownOrderStateMachineState) {
+		t.Fatalf("Scan(99) error = %v", err)
+	}
+}
+`,
+		},
+		{
+			name:   "quarantine policy maps unknown values to legacy",
+			policy: model.UnknownStateQuarantine,
+			runtime: `
+func TestUnknownStates(t *testing.T) {
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if err := sm.RestoreState([]byte("on_hold")); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateLegacy {
+		t.Fatalf("State() = %s, want legacy", sm.State())
+	}
+}
+`,
+		},
+		{
+			name:   "handler policy delegates unknown values",
+			policy: model.UnknownStateHandler,
+			runtime: `
+func TestUnknownStates(t *testing.T) {
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if err := sm.RestoreState("on_hold"); !errors.Is(err, ErrUnknownOrderStateMachineState) {
+		t.Fatalf("unset handler must reject, got %v", err)
+	}
+
+	OrderStateMachineUnknownStateHandler = func(value any) (OrderStateMachineState, error) {
+		if value == "on_hold" {
+			return OrderStateMachineStatePending, nil
+		}
+		return 0, errors.New("unmapped")
+	}
+	if err := sm.RestoreState("on_hold"); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("State() = %s, want pending", sm.State())
+	}
+}
+`,
+		},
+	}
+
+	// Behavior shared by every policy: known values round-trip through Scan and Value
+	const roundTrip = `
+func TestKnownStatesRoundTrip(t *testing.T) {
+	for _, want := range []OrderStateMachineState{OrderStateMachineStatePending, OrderStateMachineStateShipped} {
+		persisted, err := want.Value()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got OrderStateMachineState
+		if err := got.Scan(persisted); err != nil || got != want {
+			t.Fatalf("Scan(%v) = %s, %v; want %s", persisted, got, err, want)
+		}
+		if err := got.Scan(int64(want)); err != nil || got != want {
+			t.Fatalf("Scan(int64) = %s, %v; want %s", got, err, want)
+		}
+	}
+	if _, err := OrderStateMachineState(42).Value(); err == nil {
+		t.Fatal("Value() of an undeclared state must fail")
+	}
+	var s OrderStateMachineState
+	if err := s.Scan(3.5); err == nil {
+		t.Fatal("Scan(float64) must fail")
+	}
+}
+`
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := createOrderStateMachine(t)
+			legacy, _ := model.NewState("legacy")
+			require.NoError(t, fsm.AddState(legacy))
+			fsm.Options.UnknownState = tt.policy
+			if tt.policy == model.UnknownStateQuarantine {
+				fsm.Options.QuarantineState = "legacy"
+			}
+			require.NoError(t, fsm.Validate())
+
+			code, err := gen.Generate(fsm)
+			require.NoError(t, err)
+			assert.Contains(t, string(code), "func ParseOrderStateMachineState(name string) (OrderStateMachineState, error)")
+			assert.Contains(t, string(code), "func (sm *OrderStateMachine) RestoreState(value any) error")
+
+			runGeneratedPackage(t, map[string][]byte{
+				"order_state_machine_fsm.gen.go": code,
+				"restore_test.go":                []byte("package orders\n\nimport (\n\t\"errors\"\n\t\"testing\"\n)\n\nvar _ = errors.New\n" + tt.runtime + roundTrip),
+			})
+		})
+	}
+}
+
+func TestCodeGenerator_Generate_ZeroStatePolicies(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   model.ZeroStatePolicy
+		contains []string
+		runtime  string
+	}{
+		{
+			name:   "zero value is the initial state",
+			policy: model.ZeroStateInitial,
+			contains: []string{
+				"OrderStateMachineStatePending OrderStateMachineState = 0",
+				"The zero value is the initial state, OrderStateMachineStatePending.",
+			},
+			runtime: `
+func TestZeroState(t *testing.T) {
+	var s OrderStateMachineState
+	if s != OrderStateMachineStatePending || !s.IsValid() {
+		t.Fatalf("zero value = %s, want pending", s)
+	}
+}
+`,
+		},
+		{
+			name:   "zero value is an unspecified sentinel",
+			policy: model.ZeroStateUnspecified,
+			contains: []string{
+				"OrderStateMachineStateUnspecified OrderStateMachineState = 0",
+				"OrderStateMachineStateApproved OrderStateMachineState = 1",
+			},
+			runtime: `
+func TestZeroState(t *testing.T) {
+	var s OrderStateMachineState
+	if s != OrderStateMachineStateUnspecified || s.IsValid() || s.String() != "unspecified" {
+		t.Fatalf("zero value = %s, want unspecified", s)
+	}
+	if _, err := s.Value(); err == nil {
+		t.Fatal("Value() of the unspecified state must fail")
+	}
+
+	sm := &OrderStateMachine{logger: &noopLogger{}}
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); !errors.Is(err, ErrUninitializedOrderStateMachineState) {
+		t.Fatalf("Transition() error = %v, want ErrUninitializedOrderStateMachineState", err)
+	}
+	if sm.CanTransition(context.Background(), OrderStateMachineEventApprove) || len(sm.PermittedEvents()) != 0 {
+		t.Fatal("an unspecified machine must not permit events")
+	}
+}
+`,
+		},
+		{
+			name:   "zero value is invalid",
+			policy: model.ZeroStateInvalid,
+			contains: []string{
+				"OrderStateMachineStateApproved OrderStateMachineState = 1",
+				"The zero value is not a declared state",
+			},
+			runtime: `
+func TestZeroState(t *testing.T) {
+	var s OrderStateMachineState
+	if s.IsValid() {
+		t.Fatal("zero value must not be valid")
+	}
+	if _, err := s.Value(); err == nil {
+		t.Fatal("Value() of the zero state must fail")
+	}
+
+	sm := &OrderStateMachine{logger: &noopLogger{}}
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); !errors.Is(err, ErrUninitializedOrderStateMachineState) {
+		t.Fatalf("Transition() error = %v, want ErrUninitializedOrderStateMachineState", err)
+	}
+}
+`,
+		},
+	}
+
+	// Behavior shared by every policy: constructed machines start in the initial state
+	const initial = `
+func TestNewStartsInInitialState(t *testing.T) {
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if sm.State() != OrderStateMachineStatePending {
+		t.Fatalf("State() = %s, want pending", sm.State())
+	}
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+}
+`
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := createOrderStateMachine(t)
+			fsm.Options.ZeroState = tt.policy
+			require.NoError(t, fsm.Validate())
+
+			code, err := gen.Generate(fsm)
+			require.NoError(t, err)
+			for _, want := range tt.contains {
+				assert.Contains(t, string(code), want)
+			}
+
+			runGeneratedPackage(t, map[string][]byte{
+				"order_state_machine_fsm.gen.go": code,
+				"zero_test.go":                   []byte("package orders\n\nimport (\n\t\"context\"\n\t\"errors\"\n\t\"testing\"\n)\n\nvar _ = errors.New\n" + tt.runtime + initial),
+			})
+		})
+	}
+}
+
+func TestCodeGenerator_GenerateTests_Properties(t *testing.T) {
+	newFSM := func(t *testing.T) *model.FSMModel {
+		fsm := createOrderStateMachine(t)
+		fsm.GetState("shipped").Final = true
+		fsm.GetState("rejected").Final = true
+		require.NoError(t, fsm.AddProperty(&model.Property{
+			Name:        "no_reopen_after_ship",
+			Kind:        model.PropertyNeverFollowedBy,
+			State:       "shipped",
+			Then:        "pending",
+			Description: "Shipped orders never return to pending",
+		}))
+		require.NoError(t, fsm.AddProperty(&model.Property{
+			Name:   "terminates",
+			Kind:   model.PropertyReachesFinal,
+			Within: 3,
+		}))
+		require.NoError(t, fsm.AddProperty(&model.Property{
+			Name:     "no_reject_after_approval",
+			Kind:     model.PropertyNever,
+			Sequence: []string{"approved", "reject"},
+		}))
+		return fsm
+	}
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	t.Run("properties hold", func(t *testing.T) {
+		fsm := newFSM(t)
+		require.NoError(t, fsm.Validate())
+
+		tests, err := gen.GenerateTests(fsm)
+		require.NoError(t, err)
+		assert.Contains(t, string(tests), "func TestOrderStateMachine_Properties(t *testing.T)")
+		assert.Contains(t, string(tests), "// Shipped orders never return to pending")
+
+		code, err := gen.Generate(fsm)
+		require.NoError(t, err)
+
+		out := runGeneratedPackage(t, map[string][]byte{
+			"order_state_machine_fsm.gen.go":      code,
+			"order_state_machine_fsm.gen_test.go": tests,
+		})
+		assert.Contains(t, out, "--- PASS: TestOrderStateMachine_Properties/no_reopen_after_ship")
+		assert.Contains(t, out, "--- PASS: TestOrderStateMachine_Properties/terminates")
+		assert.Contains(t, out, "--- PASS: TestOrderStateMachine_Properties/no_reject_after_approval")
+	})
+
+	t.Run("violation reports minimal counterexample", func(t *testing.T) {
+		fsm := newFSM(t)
+		// Sending approved orders back for review allows runs that never terminate
+		require.NoError(t, fsm.AddTransition(&model.Transition{From: "approved", To: "pending", Event: "reject"}))
+		require.NoError(t, fsm.Validate())
+
+		tests, err := gen.GenerateTests(fsm)
+		require.NoError(t, err)
+		code, err := gen.Generate(fsm)
+		require.NoError(t, err)
+
+		out, err := testGeneratedPackage(t, map[string][]byte{
+			"order_state_machine_fsm.gen.go":      code,
+			"order_state_machine_fsm.gen_test.go": tests,
+		})
+		require.Error(t, err, out)
+		assert.Contains(t, out, "--- PASS: TestOrderStateMachine_Properties/no_reopen_after_ship")
+		assert.Contains(t, out, "property terminates violated")
+		assert.Contains(t, out, "minimal counterexample (3 events)")
+		assert.Contains(t, out, "pending --approve--> approved --reject--> pending --approve--> approved")
+		assert.Contains(t, out, "property no_reject_after_approval violated")
+		assert.Contains(t, out, "minimal counterexample (2 events)")
+		assert.Contains(t, out, "pending --approve--> approved --reject--> pending\n")
+	})
+
+	t.Run("eventually reports a run that ends without the target", func(t *testing.T) {
+		fsm := newFSM(t)
+		require.NoError(t, fsm.AddProperty(&model.Property{Name: "ships", Kind: model.PropertyEventually, Target: "ship"}))
+		require.NoError(t, fsm.Validate())
+
+		tests, err := gen.GenerateTests(fsm)
+		require.NoError(t, err)
+		code, err := gen.Generate(fsm)
+		require.NoError(t, err)
+
+		out, err := testGeneratedPackage(t, map[string][]byte{
+			"order_state_machine_fsm.gen.go":      code,
+			"order_state_machine_fsm.gen_test.go": tests,
+		})
+		require.Error(t, err, out)
+		assert.Contains(t, out, "--- PASS: TestOrderStateMachine_Properties/no_reject_after_approval")
+		assert.Contains(t, out, "property ships violated")
+		assert.Contains(t, out, "pending --reject--> rejected")
+	})
+}
+
+func TestCodeGenerator_GenerateTestkit(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	kit, err := gen.GenerateTestkit(fsm)
+	require.NoError(t, err)
+
+	kitStr := string(kit)
+	assert.Contains(t, kitStr, "package orders")
+	assert.Contains(t, kitStr, "func NewOrderStateMachineTestMachine(t testing.TB, opts ...OrderStateMachineOption) *OrderStateMachineTestMachine")
+	assert.Contains(t, kitStr, `HasPayment: func(ctx context.Context, c *OrderStateMachineContext) bool { return s.guard("hasPayment") }`)
+	assert.Equal(t, "order_state_machine_testkit.go", TestkitOutputName(fsm))
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	// A consumer test written against the testkit
+	consumer := `package orders
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckout(t *testing.T) {
+	m := NewOrderStateMachineTestMachine(t)
+	m.Drive(OrderStateMachineEventApprove, OrderStateMachineEventShip).
+		AssertState(OrderStateMachineStateShipped).
+		AssertCalls("guard:hasPayment", "action:chargeCard", "action:notifyShipping")
+}
+
+func TestCheckoutWithoutPayment(t *testing.T) {
+	m := NewOrderStateMachineTestMachine(t)
+	m.Spy.GuardResults["hasPayment"] = false
+	m.AssertRejects(OrderStateMachineEventApprove).
+		AssertState(OrderStateMachineStatePending).
+		AssertCalls("guard:hasPayment")
+
+	m.Spy.Reset()
+	m.Spy.ActionErrors["sendRejectionEmail"] = errors.New("smtp down")
+	m.AssertRejects(OrderStateMachineEventReject).
+		AssertCalls("action:sendRejectionEmail")
+}
+`
+
+	out := runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		TestkitOutputName(fsm):           kit,
+		"checkout_test.go":               []byte(consumer),
+	})
+	assert.Contains(t, out, "--- PASS: TestCheckout ")
+	assert.Contains(t, out, "--- PASS: TestCheckoutWithoutPayment")
+}
+
+func TestCodeGenerator_Generate_DomainEvents(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Imports = []string{"generated/billing", "generated/warehouse"}
+	require.NoError(t, fsm.AddDomainEvent(&model.DomainEvent{Type: "billing.PaymentCaptured", Event: "approve"}))
+	require.NoError(t, fsm.AddDomainEvent(&model.DomainEvent{Type: "*billing.PaymentDeclined", Event: "reject"}))
+	require.NoError(t, fsm.AddDomainEvent(&model.DomainEvent{Type: "ParcelDispatched", Event: "ship"}))
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	codeStr := string(code)
+	assert.Contains(t, codeStr, `"generated/billing"`)
+	assert.NotContains(t, codeStr, `"generated/warehouse"`, "Unused imports must not be emitted")
+	assert.Contains(t, codeStr, "//   - billing.PaymentCaptured fires OrderStateMachineEventApprove")
+	assert.Contains(t, codeStr, "func (sm *OrderStateMachine) HandleDomainEvent(ctx context.Context, domainEvent any) error")
+
+	const billing = `package billing
+
+type PaymentCaptured struct{ Amount int }
+
+type PaymentDeclined struct{ Reason string }
+`
+
+	const dispatch = `package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"generated/billing"
+)
+
+type ParcelDispatched struct{ Carrier string }
+
+func TestHandleDomainEvent(t *testing.T) {
+	ctx := context.Background()
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+
+	if err := sm.HandleDomainEvent(ctx, billing.PaymentCaptured{Amount: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.HandleDomainEvent(ctx, ParcelDispatched{Carrier: "dhl"}); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateShipped {
+		t.Fatalf("State() = %s, want shipped", sm.State())
+	}
+
+	if err := sm.HandleDomainEvent(ctx, billing.PaymentDeclined{}); !errors.Is(err, ErrUnmappedOrderStateMachineDomainEvent) {
+		t.Fatalf("value of pointer-mapped type: error = %v, want ErrUnmappedOrderStateMachineDomainEvent", err)
+	}
+	if event, err := OrderStateMachineEventForDomainEvent(&billing.PaymentDeclined{}); err != nil || event != OrderStateMachineEventReject {
+		t.Fatalf("EventForDomainEvent(*PaymentDeclined) = %s, %v", event, err)
+	}
+}
+`
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"billing/billing.go":             []byte(billing),
+		"dispatch_test.go":               []byte(dispatch),
+	})
+}
+
+func TestCodeGenerator_Generate_EventPayloads(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Imports = []string{"generated/billing"}
+	require.NoError(t, fsm.AddDomainEvent(&model.DomainEvent{Type: "billing.PaymentCaptured", Event: "approve"}))
+	fsm.Events["approve"].Payload = &model.EventPayload{Type: "ApproveRequest", Required: []string{"ApproverID", "Amount"}}
+	fsm.Events["reject"].Payload = &model.EventPayload{Type: "billing.Rejection"}
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	codeStr := string(code)
+	assert.Equal(t, 1, strings.Count(codeStr, `"generated/billing"`), "imports shared with domain events are emitted once")
+	assert.Contains(t, codeStr, "// NewOrderStateMachineApproveEvent returns the approve event with payload p, which\n// must not be nil and must set ApproverID, Amount\n")
+	assert.Contains(t, codeStr, "func NewOrderStateMachineRejectEvent(p *billing.Rejection) (OrderStateMachinePayloadEvent, error) {")
+	assert.NotContains(t, codeStr, "NewOrderStateMachineShipEvent")
+
+	const billing = `package billing
+
+type PaymentCaptured struct{ Amount int }
+
+type Rejection struct{ Reason string }
+`
+
+	const approve = `package orders
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"generated/billing"
+)
+
+type ApproveRequest struct {
+	ApproverID string
+	Amount     int
+	Note       string
+}
+
+func TestEventConstructors(t *testing.T) {
+	for _, tc := range []struct {
+		payload *ApproveRequest
+		err     string
+	}{
+		{nil, "approve: payload is nil"},
+		{&ApproveRequest{Amount: 10}, "approve: ApproverID is required"},
+		{&ApproveRequest{ApproverID: "ann"}, "approve: Amount is required"},
+	} {
+		_, err := NewOrderStateMachineApproveEvent(tc.payload)
+		if !errors.Is(err, ErrInvalidOrderStateMachinePayload) || !strings.HasSuffix(err.Error(), tc.err) {
+			t.Fatalf("NewOrderStateMachineApproveEvent(%+v) error = %v, want %q", tc.payload, err, tc.err)
+		}
+	}
+
+	payload := &ApproveRequest{ApproverID: "ann", Amount: 10}
+	event, err := NewOrderStateMachineApproveEvent(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.Event() != OrderStateMachineEventApprove || event.Payload() != payload {
+		t.Fatalf("event = %s with %v", event.Event(), event.Payload())
+	}
+	sm := NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{})
+	if err := sm.TransitionWithEvent(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateApproved {
+		t.Fatalf("State() = %s, want approved", sm.State())
+	}
+
+	if _, err := NewOrderStateMachineRejectEvent(&billing.Rejection{}); err != nil {
+		t.Fatalf("payload without required fields: %v", err)
+	}
+}
+`
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go": code,
+		"billing/billing.go":             []byte(billing),
+		"approve_test.go":                []byte(approve),
+	})
+}
+
+func TestCodeGenerator_GenerateSplit(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Options.ChaosHelpers = true
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	sections, err := gen.GenerateSplit(fsm)
+	require.NoError(t, err)
+
+	files := make(map[string][]byte, len(sections)+1)
+	var names []string
+	for _, section := range sections {
+		files[section.Path] = section.Content
+		names = append(names, section.Path)
+		assert.True(t, IsGenerated(section.Content), "%s must carry the generated marker", section.Path)
+	}
+	assert.Equal(t, []string{
+		"order_state_machine_states.go",
+		"order_state_machine_events.go",
+		"order_state_machine_callbacks.go",
+		"order_state_machine_machine.go",
+	}, names)
+
+	states := string(files["order_state_machine_states.go"])
+	assert.Contains(t, states, "type OrderStateMachineState int")
+	assert.Contains(t, states, `"database/sql/driver"`)
+	assert.NotContains(t, states, "type OrderStateMachineEvent int")
+	assert.NotContains(t, states, `"sync"`, "Imports unused by a section must be pruned")
+
+	callbacks := string(files["order_state_machine_callbacks.go"])
+	assert.Contains(t, callbacks, "type OrderStateMachineGuards struct")
+	assert.Contains(t, callbacks, `"context"`)
+	assert.NotContains(t, callbacks, `"fmt"`)
+
+	machine := string(files["order_state_machine_machine.go"])
+	assert.Contains(t, machine, "func NewOrderStateMachine(")
+	assert.Contains(t, machine, `"math/rand"`)
+
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	files[SplitTestOutputName(fsm)] = tests
+
+	out := runGeneratedPackage(t, files)
+	assert.Contains(t, out, "--- PASS: TestOrderStateMachine_Transitions")
+}
+
+func TestPruneImports(t *testing.T) {
+	src := `// Code generated by gofsm-gen. DO NOT EDIT.
+package orders
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+var errNotFound = errors.New("not found")
+
+func describe(r *rand.Rand) string { return fmt.Sprint(r.Int()) }
+`
+
+	pruned, err := pruneImports([]byte(src))
+	require.NoError(t, err)
+	assert.Contains(t, string(pruned), `"math/rand"`)
+	assert.Contains(t, string(pruned), `"errors"`)
+	assert.NotContains(t, string(pruned), `"context"`)
+
+	_, err = pruneImports([]byte("package orders\nfunc {"))
+	assert.ErrorContains(t, err, "failed to parse generated code")
+}
+
+func TestCodeGenerator_Generate_CustomHeader(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Header = model.Header{
+		Copyright: "Copyright 2026 Acme Corp.",
+		BuildTags: "!fsm_stub",
+		Stamp:     true,
+	}
+	fsm.Source = model.Source{Path: "orders/order.yaml", Checksum: "abc123"}
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	tests, err := gen.GenerateTests(fsm)
+	require.NoError(t, err)
+	kit, err := gen.GenerateTestkit(fsm)
+	require.NoError(t, err)
+
+	for name, src := range map[string][]byte{"machine": code, "tests": tests, "testkit": kit} {
+		assert.True(t, IsGenerated(src), "%s must keep the generated marker", name)
+		assert.Contains(t, string(src), "// Copyright 2026 Acme Corp.\n", name)
+		assert.Contains(t, string(src), "// Source: orders/order.yaml (sha256:abc123)\n", name)
+		assert.Contains(t, string(src), "\n//go:build !fsm_stub\n\npackage orders\n", name)
+	}
+
+	runGeneratedPackage(t, map[string][]byte{
+		"order_state_machine_fsm.gen.go":      code,
+		"order_state_machine_fsm.gen_test.go": tests,
+		"order_state_machine_testkit.go":      kit,
+	})
+}
+
+func TestCodeGenerator_GenerateDiagram(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.GetState("shipped").Final = true
+	fsm.GetState("rejected").Description = "Order was declined"
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	diagram, err := gen.GenerateDiagram(fsm)
+	require.NoError(t, err)
+
+	want := `%% Code generated by gofsm-gen. DO NOT EDIT.
+stateDiagram-v2
+    rejected: Order was declined
+    [*] --> pending
+    pending --> approved: approve [hasPayment] / chargeCard
+    pending --> rejected: reject / sendRejectionEmail
+    approved --> shipped: ship / notifyShipping
+    shipped --> [*]
+`
+	assert.Equal(t, want, string(diagram))
+	assert.Equal(t, "order_state_machine_fsm.mmd", DiagramOutputName(fsm))
+}
+
+func TestCodeGenerator_Generate_PinnedValues(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	shipped, ship := 10, 7
+	fsm.GetState("shipped").Value = &shipped
+	fsm.GetEvent("ship").Value = &ship
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	for _, want := range []string{
+		"OrderStateMachineStatePending OrderStateMachineState = 0",
+		"OrderStateMachineStateApproved OrderStateMachineState = 1",
+		"OrderStateMachineStateRejected OrderStateMachineState = 2",
+		"OrderStateMachineStateShipped OrderStateMachineState = 10",
+		"OrderStateMachineEventApprove OrderStateMachineEvent = 0",
+		"OrderStateMachineEventReject OrderStateMachineEvent = 1",
+		"OrderStateMachineEventShip OrderStateMachineEvent = 7",
+	} {
+		assert.Contains(t, string(code), want)
+	}
+}
+
+// newLargeModel builds a machine of n states in a chain, where every tenth
+// state can also return to the start of its block under a guard, with an action
+func newLargeModel(b testing.TB, n int) *model.FSMModel {
+	b.Helper()
+	name := func(i int) string { return fmt.Sprintf("step_%05d", i) }
+	fsm, err := model.NewFSMModel("Configurator", name(0))
+	require.NoError(b, err)
+	fsm.Package = "configurator"
+	require.NoError(b, fsm.AddEvent(&model.Event{Name: "next"}))
+	require.NoError(b, fsm.AddEvent(&model.Event{Name: "back"}))
+	for i := 0; i < n; i++ {
+		require.NoError(b, fsm.AddState(&model.State{Name: name(i), Final: i == n-1}))
+	}
+	for i := 0; i < n-1; i++ {
+		require.NoError(b, fsm.AddTransition(&model.Transition{From: name(i), To: name(i + 1), Event: "next"}))
+		if i%10 == 9 {
+			require.NoError(b, fsm.AddTransition(&model.Transition{
+				From: name(i), To: name(i - 9), Event: "back",
+				Guard: fmt.Sprintf("can_restart_%d", i/10), Action: fmt.Sprintf("restart_%d", i/10),
+			}))
+		}
+	}
+	require.NoError(b, fsm.Validate())
+	return fsm
+}
+
+func BenchmarkCodeGenerator_Generate(b *testing.B) {
+	gen, err := NewCodeGenerator()
+	require.NoError(b, err)
+	for _, n := range []int{10, 100, 1000, 5000} {
+		fsm := newLargeModel(b, n)
+		b.Run(fmt.Sprintf("states=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := gen.Generate(fsm); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
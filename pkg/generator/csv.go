@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// utf8BOM lets spreadsheet applications such as Excel detect UTF-8 CSV files
+const utf8BOM = "\ufeff"
+
+// CSVOptions controls the CSV transition matrix export
+type CSVOptions struct {
+	// BOM prefixes the file with a UTF-8 byte order mark
+	BOM bool
+}
+
+// GenerateCSV generates the state × event matrix of the given FSM model as CSV.
+// Each row is a state and each column an event; a cell lists the target states of
+// the event in that state with their guards, and is blank if the event is rejected.
+func (g *CodeGenerator) GenerateCSV(m *model.FSMModel, opts CSVOptions) ([]byte, error) {
+	m, err := prepare(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if opts.BOM {
+		buf.WriteString(utf8BOM)
+	}
+
+	w := csv.NewWriter(&buf)
+	events := m.GetEventsSlice()
+
+	header := make([]string, 0, len(events)+1)
+	header = append(header, "state")
+	for _, event := range events {
+		header = append(header, event.Name)
+	}
+	if err := w.Write(header); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	transitionsFrom := m.GetTransitionsByState()
+	for _, state := range m.GetStatesSlice() {
+		row := make([]string, 0, len(events)+1)
+		row = append(row, state.Name)
+		for _, event := range events {
+			row = append(row, matrixCell(transitionsFrom[state.Name], event.Name))
+		}
+		if err := w.Write(row); err != nil {
+			return nil, fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// CSVOutputName returns the conventional CSV matrix file name for a model
+func CSVOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + "_matrix.csv"
+}
+
+// matrixCell describes the targets of event among the transitions from a state
+// in declaration order, e.g. "approved [hasPayment]; rejected"
+func matrixCell(transitions []*model.Transition, event string) string {
+	var targets []string
+	for _, t := range transitions {
+		if t.Event != event {
+			continue
+		}
+		target := t.To
+		if t.Guard != "" {
+			target += " [" + t.Guard + "]"
+		}
+		targets = append(targets, target)
+	}
+	return strings.Join(targets, "; ")
+}
@@ -0,0 +1,36 @@
+package generator
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateCSV(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fallback, _ := model.NewTransition("pending", "rejected", "approve")
+	fsm.AddTransition(fallback)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	matrix, err := gen.GenerateCSV(fsm, CSVOptions{})
+	require.NoError(t, err)
+
+	records, err := csv.NewReader(strings.NewReader(string(matrix))).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"state", "approve", "reject", "ship"},
+		{"approved", "", "", "shipped"},
+		{"pending", "approved [hasPayment]; rejected", "rejected", ""},
+		{"rejected", "", "", ""},
+		{"shipped", "", "", ""},
+	}, records)
+
+	withBOM, err := gen.GenerateCSV(fsm, CSVOptions{BOM: true})
+	require.NoError(t, err)
+	assert.Equal(t, utf8BOM+string(matrix), string(withBOM))
+}
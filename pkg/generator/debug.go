@@ -0,0 +1,220 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// WithTemplateDebug makes the generator report to w, for every template it
+// executes, the data passed to it and the output lines each template produced,
+// and show execution errors with the template source they occurred at. It is
+// meant for authoring custom templates.
+func WithTemplateDebug(w io.Writer) GeneratorOption {
+	return func(g *CodeGenerator) {
+		g.debug = w
+	}
+}
+
+// Trace markers delimit the output of each template while debugging; they are
+// control characters that generated code does not contain
+const (
+	traceStart = '\x1e'
+	traceEnd   = '\x1f'
+)
+
+// executeTemplate executes the template name of tmpl with data into buf. With
+// WithTemplateDebug it also reports the data and the output lines of each
+// template.
+func (g *CodeGenerator) executeTemplate(tmpl *template.Template, buf *bytes.Buffer, name string, data any) error {
+	if g.debug == nil {
+		return tmpl.ExecuteTemplate(buf, name, data)
+	}
+
+	g.debugMu.Lock()
+	defer g.debugMu.Unlock()
+	fmt.Fprintf(g.debug, "=== template %s\n--- data\n", name)
+	dump, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		dump = []byte(fmt.Sprintf("%+v", data))
+	}
+	fmt.Fprintf(g.debug, "%s\n", dump)
+
+	traced, err := traceTemplates(tmpl)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	execErr := traced.ExecuteTemplate(&out, name, data)
+
+	fmt.Fprintln(g.debug, "--- output lines")
+	for _, span := range untrace(out.Bytes(), buf) {
+		lines := strconv.Itoa(span.start)
+		if span.end != span.start {
+			lines += "-" + strconv.Itoa(span.end)
+		}
+		if span.open {
+			lines += "?"
+		}
+		fmt.Fprintf(g.debug, "  %-11s %s%s\n", lines, strings.Repeat("  ", span.depth), span.name)
+	}
+	if execErr != nil {
+		fmt.Fprintf(g.debug, "--- error\n%v\n%s", execErr, g.errorSource(execErr))
+	}
+	return execErr
+}
+
+// traceTemplates returns a copy of tmpl whose templates write trace markers
+// around their output
+func traceTemplates(tmpl *template.Template) (*template.Template, error) {
+	traced, err := tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range traced.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		// The clone shares the parse trees of tmpl, which must stay untouched
+		t.Tree = t.Tree.Copy()
+		root := t.Tree.Root
+		nodes := make([]parse.Node, 0, len(root.Nodes)+2)
+		nodes = append(nodes, traceText(traceStart, t.Name()))
+		nodes = append(nodes, root.Nodes...)
+		root.Nodes = append(nodes, traceText(traceEnd, t.Name()))
+	}
+	return traced, nil
+}
+
+// traceText returns a text node writing a trace marker for the template name
+func traceText(marker rune, name string) *parse.TextNode {
+	return &parse.TextNode{NodeType: parse.NodeText, Text: []byte(string(marker) + name + "\n")}
+}
+
+// templateSpan is the lines of output a template produced
+type templateSpan struct {
+	name string
+
+	// depth is the number of templates the template was called from
+	depth int
+
+	// start and end are the first and last line, counted from 1
+	start, end int
+
+	// offset and size locate the output of the template
+	offset, size int
+
+	// open reports that the template did not finish, as on an execution error
+	open bool
+}
+
+// untrace writes traced without its trace markers to out and returns the spans
+// of the templates that produced output, in the order they started
+func untrace(traced []byte, out *bytes.Buffer) []templateSpan {
+	var spans []templateSpan
+	var open []int
+	line := 1
+	lineStart := true
+	for len(traced) > 0 {
+		i := bytes.IndexAny(traced, string([]rune{traceStart, traceEnd}))
+		if i < 0 {
+			i = len(traced)
+		}
+		text := traced[:i]
+		out.Write(text)
+		line += bytes.Count(text, []byte("\n"))
+		if len(text) > 0 {
+			lineStart = text[len(text)-1] == '\n'
+		}
+		if i == len(traced) {
+			break
+		}
+
+		marker := traced[i]
+		eol := bytes.IndexByte(traced[i:], '\n')
+		name := string(traced[i+1 : i+eol])
+		traced = traced[i+eol+1:]
+
+		if marker == traceStart {
+			open = append(open, len(spans))
+			spans = append(spans, templateSpan{name: name, depth: len(open) - 1, start: line, offset: out.Len(), open: true})
+			continue
+		}
+		span := &spans[open[len(open)-1]]
+		open = open[:len(open)-1]
+		span.open = false
+		span.size = out.Len() - span.offset
+		span.end = line
+		if lineStart && span.end > span.start {
+			span.end--
+		}
+	}
+	for _, i := range open {
+		spans[i].end = line
+	}
+
+	produced := spans[:0]
+	for _, span := range spans {
+		if span.open || span.size > 0 {
+			produced = append(produced, span)
+		}
+	}
+	return produced
+}
+
+// errorLocation matches the template, line, and column of an execution error
+var errorLocation = regexp.MustCompile(`template: ([^:\s]+):(\d+):(\d+):`)
+
+// errorSource returns the template source around where err occurred, with a
+// caret under the failing action, or "" when err has no location
+func (g *CodeGenerator) errorSource(err error) string {
+	m := errorLocation.FindStringSubmatch(err.Error())
+	if m == nil {
+		return ""
+	}
+	// Overrides come last, so a file of theirs replaces that of the template directory
+	var src []byte
+	for _, dir := range append([]string{g.dir}, g.overrides...) {
+		if data, err := os.ReadFile(filepath.Join(dir, m[1])); err == nil {
+			src = data
+		}
+	}
+	if src == nil {
+		return ""
+	}
+	lines := strings.Split(string(src), "\n")
+	lineNum, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	if lineNum < 1 || lineNum > len(lines) {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:%d:\n", m[1], lineNum)
+	for n := max(lineNum-1, 1); n <= min(lineNum+1, len(lines)); n++ {
+		fmt.Fprintf(&b, "%5d | %s\n", n, lines[n-1])
+		if n == lineNum {
+			line := lines[n-1]
+			if col > len(line) {
+				col = len(line)
+			}
+			// Keep tabs so that the caret lines up with the action
+			pad := strings.Map(func(r rune) rune {
+				if r == '\t' {
+					return r
+				}
+				return ' '
+			}, line[:col])
+			fmt.Fprintf(&b, "      | %s^\n", pad)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTemplateDebug(t *testing.T) {
+	plain, err := NewCodeGenerator()
+	require.NoError(t, err)
+	want, err := plain.Generate(createOrderStateMachine(t))
+	require.NoError(t, err)
+
+	var report bytes.Buffer
+	gen, err := NewCodeGenerator(WithTemplateDebug(&report))
+	require.NoError(t, err)
+	got, err := gen.Generate(createOrderStateMachine(t))
+	require.NoError(t, err)
+	assert.Equal(t, string(want), string(got), "debugging does not change the output")
+
+	out := report.String()
+	assert.True(t, strings.HasPrefix(out, "=== template state_machine.tmpl\n--- data\n"), out)
+	assert.Contains(t, out, `"Name": "OrderStateMachine"`)
+	assert.Contains(t, out, "--- output lines\n")
+
+	lines := strings.Count(string(want), "\n")
+	assert.Contains(t, out, "  1-"+strconv.Itoa(lines)+" ")
+	assert.Regexp(t, `\n  \d+-\d+ +  states\n`, out, "called templates are indented under their caller")
+}
+
+func TestUntrace(t *testing.T) {
+	traced := "\x1eouter\nline 1\n\x1einner\nline 2\nline 3\n\x1finner\n\x1eempty\n\x1fempty\nline 4\n\x1fouter\n"
+
+	var out bytes.Buffer
+	spans := untrace([]byte(traced), &out)
+	assert.Equal(t, "line 1\nline 2\nline 3\nline 4\n", out.String())
+	require.Len(t, spans, 2, "templates without output are left out")
+	assert.Equal(t, templateSpan{name: "outer", start: 1, end: 4, offset: 0, size: 28}, spans[0])
+	assert.Equal(t, templateSpan{name: "inner", depth: 1, start: 2, end: 3, offset: 7, size: 14}, spans[1])
+}
+
+func TestWithTemplateDebug_ErrorSource(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "state_machine.tmpl"),
+		[]byte("package {{.Package}}\n\n\tconst x = {{.Missing}}\n// end\n"), 0o600))
+
+	var report bytes.Buffer
+	gen, err := NewCodeGeneratorWithTemplateDir(dir, WithTemplateDebug(&report))
+	require.NoError(t, err)
+	_, err = gen.Generate(createOrderStateMachine(t))
+	require.Error(t, err)
+
+	out := report.String()
+	assert.Contains(t, out, "--- output lines\n  1-3?        state_machine.tmpl\n")
+	assert.Contains(t, out, "--- error\n")
+	assert.Contains(t, out, "state_machine.tmpl:3:\n"+
+		"    2 | \n"+
+		"    3 | \tconst x = {{.Missing}}\n"+
+		"      | \t            ^\n"+
+		"    4 | // end\n")
+}
+
+func TestWithTemplateDebug_Concurrent(t *testing.T) {
+	var single bytes.Buffer
+	gen, err := NewCodeGenerator(WithTemplateDebug(&single))
+	require.NoError(t, err)
+	_, err = gen.Generate(createOrderStateMachine(t))
+	require.NoError(t, err)
+
+	const workers = 4
+	var report bytes.Buffer
+	gen, err = NewCodeGenerator(WithTemplateDebug(&report))
+	require.NoError(t, err)
+	fsm := createOrderStateMachine(t)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := gen.Generate(fsm)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, strings.Repeat(single.String(), workers), report.String(), "the report of each template is kept whole")
+}
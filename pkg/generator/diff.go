@@ -0,0 +1,28 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// UnifiedDiff returns a unified diff of path that turns existing into generated,
+// as printed by diff -u, or "" when they are equal
+func UnifiedDiff(path string, existing, generated []byte) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(existing),
+		B:        diffLines(generated),
+		FromFile: path,
+		ToFile:   path,
+		Context:  3,
+	})
+}
+
+// diffLines splits src into lines that keep their line endings
+func diffLines(src []byte) []string {
+	lines := strings.SplitAfter(string(src), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,18 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	diff, err := UnifiedDiff("door.go", []byte("package door\n\nconst A = 1\n"), []byte("package door\n\nconst A = 2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "--- door.go\n+++ door.go\n@@ -1,3 +1,3 @@\n package door\n \n-const A = 1\n+const A = 2\n", diff)
+
+	diff, err = UnifiedDiff("door.go", []byte("package door\n"), []byte("package door\n"))
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+}
@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// DefaultDOTClusterKey is the state metadata key whose values group states into clusters
+const DefaultDOTClusterKey = "group"
+
+// dotMetadataPrefix marks state metadata keys that set Graphviz node attributes,
+// e.g. "dot.fillcolor"
+const dotMetadataPrefix = "dot."
+
+// DOTOptions controls the Graphviz DOT export
+type DOTOptions struct {
+	// ClusterBy is the state metadata key grouping states into clusters; empty means
+	// DefaultDOTClusterKey
+	ClusterBy string
+
+	// TagStyles maps a state tag to the node attributes of tagged states,
+	// written as a comma-separated attr=value list
+	TagStyles map[string]string
+}
+
+// dotAttr is a single Graphviz attribute
+type dotAttr struct {
+	Key   string
+	Value string
+}
+
+// dotNode is a state node with its resolved attributes
+type dotNode struct {
+	Name  string
+	Attrs string
+}
+
+// dotCluster groups the nodes sharing a cluster metadata value
+type dotCluster struct {
+	Label string
+	Nodes []dotNode
+}
+
+// dotData is the template data of dot.tmpl
+type dotData struct {
+	*model.FSMModel
+	Ungrouped []dotNode
+	Clusters  []dotCluster
+}
+
+// GenerateDOT generates a Graphviz digraph of the given FSM model. Final states are
+// drawn with a double border, states unreachable from the initial state are
+// highlighted, states are clustered by metadata, and tags and "dot." metadata style
+// individual states.
+func (g *CodeGenerator) GenerateDOT(m *model.FSMModel, opts DOTOptions) ([]byte, error) {
+	m, err := prepare(m)
+	if err != nil {
+		return nil, err
+	}
+
+	tagStyles := make(map[string][]dotAttr, len(opts.TagStyles))
+	for tag, style := range opts.TagStyles {
+		attrs, err := parseDOTAttrs(style)
+		if err != nil {
+			return nil, fmt.Errorf("style of tag %q: %w", tag, err)
+		}
+		tagStyles[tag] = attrs
+	}
+
+	clusterBy := opts.ClusterBy
+	if clusterBy == "" {
+		clusterBy = DefaultDOTClusterKey
+	}
+
+	unreachable := make(map[string]bool)
+	for _, name := range m.GetUnreachableStateNames() {
+		unreachable[name] = true
+	}
+
+	data := dotData{FSMModel: m}
+	clusters := make(map[string]*dotCluster)
+	for _, state := range m.GetStatesSlice() {
+		node := dotNode{Name: state.Name, Attrs: formatDOTAttrs(stateDOTAttrs(state, unreachable[state.Name], tagStyles))}
+
+		label := state.Metadata[clusterBy]
+		if label == "" {
+			data.Ungrouped = append(data.Ungrouped, node)
+			continue
+		}
+		if clusters[label] == nil {
+			clusters[label] = &dotCluster{Label: label}
+		}
+		clusters[label].Nodes = append(clusters[label].Nodes, node)
+	}
+
+	for _, cluster := range clusters {
+		data.Clusters = append(data.Clusters, *cluster)
+	}
+	sort.Slice(data.Clusters, func(i, j int) bool { return data.Clusters[i].Label < data.Clusters[j].Label })
+
+	return g.executeData("dot.tmpl", m, data)
+}
+
+// DOTOutputName returns the conventional Graphviz file name for a model
+func DOTOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + ".dot"
+}
+
+// stateDOTAttrs returns the node attributes of a state. Later attributes override
+// earlier ones: the final and unreachable styles come first, then tag styles in tag
+// order, then "dot." metadata.
+func stateDOTAttrs(state *model.State, unreachable bool, tagStyles map[string][]dotAttr) []dotAttr {
+	var attrs []dotAttr
+	if state.Description != "" {
+		attrs = append(attrs, dotAttr{"tooltip", state.Description})
+	}
+	if state.Final {
+		attrs = append(attrs, dotAttr{"peripheries", "2"}, dotAttr{"style", "rounded,filled"}, dotAttr{"fillcolor", "#d9ead3"})
+	}
+	if unreachable {
+		attrs = append(attrs, dotAttr{"style", "rounded,dashed"}, dotAttr{"color", "#cc0000"}, dotAttr{"fontcolor", "#cc0000"})
+	}
+	for _, tag := range state.Tags {
+		attrs = append(attrs, tagStyles[tag]...)
+	}
+
+	keys := make([]string, 0, len(state.Metadata))
+	for key := range state.Metadata {
+		if strings.HasPrefix(key, dotMetadataPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		attrs = append(attrs, dotAttr{strings.TrimPrefix(key, dotMetadataPrefix), state.Metadata[key]})
+	}
+	return attrs
+}
+
+// formatDOTAttrs renders attributes as a DOT attribute list, keeping the last value
+// of each key at the position of its first occurrence
+func formatDOTAttrs(attrs []dotAttr) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+
+	var keys []string
+	values := make(map[string]string, len(attrs))
+	for _, attr := range attrs {
+		if _, seen := values[attr.Key]; !seen {
+			keys = append(keys, attr.Key)
+		}
+		values[attr.Key] = attr.Value
+	}
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+"="+dotQuote(values[key]))
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+// parseDOTAttrs parses a comma-separated attr=value list
+func parseDOTAttrs(s string) ([]dotAttr, error) {
+	var attrs []dotAttr
+	for _, part := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("attribute %q is not attr=value", part)
+		}
+		attrs = append(attrs, dotAttr{key, value})
+	}
+	return attrs, nil
+}
+
+// dotQuote returns s as a DOT double-quoted string
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// transitionLabel describes a transition as "event [guard] / action"
+func transitionLabel(t *model.Transition) string {
+	label := t.Event
+	if t.Guard != "" {
+		label += " [" + t.Guard + "]"
+	}
+	if t.Action != "" {
+		label += " / " + t.Action
+	}
+	return label
+}
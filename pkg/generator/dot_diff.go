@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// Colors of the DOT diff: added elements are green, removed ones red, and
+// modified ones orange
+const (
+	dotAddedColor    = "#38761d"
+	dotRemovedColor  = "#cc0000"
+	dotModifiedColor = "#b45f06"
+)
+
+// dotEdge is a transition edge with its resolved attributes
+type dotEdge struct {
+	From  string
+	To    string
+	Attrs string
+}
+
+// dotDiffData is the template data of dot_diff.tmpl
+type dotDiffData struct {
+	*model.FSMModel
+	Nodes []dotNode
+	Edges []dotEdge
+}
+
+// GenerateDOTDiff generates a Graphviz digraph of two versions of a machine, drawing
+// the states, transitions, and initial state the updated version adds in green, those
+// it removes dashed in red, and those it modifies in orange
+func (g *CodeGenerator) GenerateDOTDiff(old, updated *model.FSMModel) ([]byte, error) {
+	updated, err := prepare(updated)
+	if err != nil {
+		return nil, err
+	}
+	if old, err = prepare(old); err != nil {
+		return nil, err
+	}
+
+	d := model.Compare(old, updated)
+	data := dotDiffData{FSMModel: updated}
+	for _, s := range d.States {
+		attrs := changeDOTAttrs(s.Change, s.Details)
+		if s.Change == model.Removed {
+			// Keep the rounded corners of the default node style
+			attrs = append(attrs, dotAttr{"style", "rounded,dashed"})
+		}
+		if s.Final {
+			attrs = append([]dotAttr{{"peripheries", "2"}}, attrs...)
+		}
+		data.Nodes = append(data.Nodes, dotNode{Name: s.Name, Attrs: formatDOTAttrs(attrs)})
+	}
+
+	if d.OldInitial == d.NewInitial {
+		data.Edges = append(data.Edges, dotEdge{From: "__start", To: d.NewInitial})
+	} else {
+		data.Edges = append(data.Edges,
+			dotEdge{From: "__start", To: d.OldInitial, Attrs: formatDOTAttrs(changeDOTAttrs(model.Removed, nil))},
+			dotEdge{From: "__start", To: d.NewInitial, Attrs: formatDOTAttrs(changeDOTAttrs(model.Added, nil))})
+	}
+
+	for _, t := range d.Transitions {
+		attrs := append([]dotAttr{{"label", transitionLabel(t.Transition)}}, changeDOTAttrs(t.Change, t.Details)...)
+		data.Edges = append(data.Edges, dotEdge{From: t.From, To: t.To, Attrs: formatDOTAttrs(attrs)})
+	}
+
+	return g.executeData("dot_diff.tmpl", updated, data)
+}
+
+// changeDOTAttrs returns the attributes drawing an element with the given change
+func changeDOTAttrs(change model.Change, details []string) []dotAttr {
+	switch change {
+	case model.Added:
+		return []dotAttr{{"color", dotAddedColor}, {"fontcolor", dotAddedColor}, {"penwidth", "2"}}
+	case model.Removed:
+		return []dotAttr{{"style", "dashed"}, {"color", dotRemovedColor}, {"fontcolor", dotRemovedColor}}
+	case model.Modified:
+		return []dotAttr{{"color", dotModifiedColor}, {"fontcolor", dotModifiedColor}, {"tooltip", strings.Join(details, "\n")}}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,43 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateDOTDiff(t *testing.T) {
+	old := createOrderStateMachine(t)
+	updated := createOrderStateMachine(t)
+	delete(updated.States, "rejected")
+	updated.Transitions = append(updated.Transitions[:1], updated.Transitions[2:]...)
+	require.NoError(t, updated.AddState(&model.State{Name: "delivered", Final: true}))
+	require.NoError(t, updated.AddEvent(&model.Event{Name: "deliver"}))
+	require.NoError(t, updated.AddTransition(&model.Transition{From: "shipped", To: "delivered", Event: "deliver"}))
+	updated.Transitions[0].Action = "reserveFunds"
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	dot, err := gen.GenerateDOTDiff(old, updated)
+	require.NoError(t, err)
+	src := string(dot)
+
+	assert.True(t, IsGenerated(dot))
+	assert.Contains(t, src, "digraph OrderStateMachine {")
+	assert.Contains(t, src, `  "approved";`)
+	assert.Contains(t, src, `  "delivered" [peripheries="2", color="#38761d", fontcolor="#38761d", penwidth="2"];`)
+	assert.Contains(t, src, `  "rejected" [style="rounded,dashed", color="#cc0000", fontcolor="#cc0000"];`)
+	assert.Contains(t, src, `  "__start" -> "pending";`)
+	assert.Contains(t, src, `  "pending" -> "approved" [label="approve [hasPayment] / reserveFunds", color="#b45f06", fontcolor="#b45f06", tooltip="action chargeCard -> reserveFunds"];`)
+	assert.Contains(t, src, `  "approved" -> "shipped" [label="ship / notifyShipping"];`)
+	assert.Contains(t, src, `  "shipped" -> "delivered" [label="deliver", color="#38761d", fontcolor="#38761d", penwidth="2"];`)
+	assert.Contains(t, src, `  "pending" -> "rejected" [label="reject / sendRejectionEmail", style="dashed", color="#cc0000", fontcolor="#cc0000"];`)
+
+	updated.Initial = "approved"
+	dot, err = gen.GenerateDOTDiff(old, updated)
+	require.NoError(t, err)
+	assert.Contains(t, string(dot), `  "__start" -> "pending" [style="dashed", color="#cc0000", fontcolor="#cc0000"];`)
+	assert.Contains(t, string(dot), `  "__start" -> "approved" [color="#38761d", fontcolor="#38761d", penwidth="2"];`)
+}
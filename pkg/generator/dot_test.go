@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateDOT(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.GetState("shipped").Final = true
+	fsm.GetState("shipped").Tags = []string{"critical"}
+	fsm.GetState("shipped").Description = `Order "left" the warehouse`
+	fsm.GetState("pending").Metadata = map[string]string{"group": "intake"}
+	fsm.GetState("approved").Metadata = map[string]string{"group": "intake", "dot.fillcolor": "lightyellow"}
+	fsm.AddState(&model.State{Name: "archived"})
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	dot, err := gen.GenerateDOT(fsm, DOTOptions{TagStyles: map[string]string{"critical": "color=blue, penwidth=2"}})
+	require.NoError(t, err)
+	src := string(dot)
+
+	assert.True(t, IsGenerated(dot))
+	assert.Contains(t, src, "digraph OrderStateMachine {")
+	assert.Contains(t, src, `  "archived" [style="rounded,dashed", color="#cc0000", fontcolor="#cc0000"];`, "Unreachable states should be highlighted")
+	assert.Contains(t, src, `  "shipped" [tooltip="Order \"left\" the warehouse", peripheries="2", style="rounded,filled", fillcolor="#d9ead3", color="blue", penwidth="2"];`)
+	assert.Contains(t, src, `  "rejected";`)
+	assert.Contains(t, src, `  subgraph "cluster_intake" {
+    label="intake";
+    "approved" [fillcolor="lightyellow"];
+    "pending";
+  }`)
+	assert.Contains(t, src, `  "__start" -> "pending";`)
+	assert.Contains(t, src, `  "pending" -> "approved" [label="approve [hasPayment] / chargeCard"];`)
+
+	byOwner, err := gen.GenerateDOT(fsm, DOTOptions{ClusterBy: "owner"})
+	require.NoError(t, err)
+	assert.NotContains(t, string(byOwner), "subgraph", "States without the cluster key stay ungrouped")
+
+	_, err = gen.GenerateDOT(fsm, DOTOptions{TagStyles: map[string]string{"critical": "blue"}})
+	assert.ErrorContains(t, err, `style of tag "critical": attribute "blue" is not attr=value`)
+}
+
+func TestFormatDOTAttrs(t *testing.T) {
+	assert.Empty(t, formatDOTAttrs(nil))
+	assert.Equal(t, ` [style="filled", color="red"]`, formatDOTAttrs([]dotAttr{
+		{"style", "rounded"},
+		{"color", "red"},
+		{"style", "filled"},
+	}), "Later values override earlier ones in place")
+}
@@ -0,0 +1,25 @@
+package generator
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/golden"
+)
+
+// update rewrites the golden files of TestGolden
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// TestGolden checks the output of the bundled templates for the specs in
+// testdata; run it with -update to accept intended changes
+func TestGolden(t *testing.T) {
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	golden.Run(t, "testdata", map[string]golden.Render{
+		"go":      gen.Generate,
+		"test.go": gen.GenerateTests,
+		"mmd":     gen.GenerateDiagram,
+	})
+}
@@ -0,0 +1,230 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeGenerator_GenerateGRPCService(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	proto, err := gen.GenerateGRPCService(fsm, GRPCOptions{})
+	require.NoError(t, err)
+	src := string(proto)
+
+	assert.True(t, IsGenerated(proto))
+	assert.Equal(t, "order_state_machine_service.proto", GRPCServiceOutputName(fsm))
+	assert.Contains(t, src, "package orders;\n")
+	assert.NotContains(t, src, "option go_package")
+	assert.Contains(t, src, "  rpc GetState(OrderStateMachineGetStateRequest) returns (OrderStateMachineGetStateResponse);")
+	assert.Contains(t, src, "  rpc ListPermittedEvents(OrderStateMachineListPermittedEventsRequest) returns (OrderStateMachineListPermittedEventsResponse);")
+	assert.Contains(t, src, "  rpc FireEvent(OrderStateMachineFireEventRequest) returns (OrderStateMachineFireEventResponse);")
+	assert.Contains(t, src, `message OrderStateMachineFireEventRequest {
+  string id = 1;
+  string event = 2;
+}`)
+
+	proto, err = gen.GenerateGRPCService(fsm, GRPCOptions{ProtoPackage: "acme.orders.v1", GoPackage: "example.com/shop/orders;orders"})
+	require.NoError(t, err)
+	assert.Contains(t, string(proto), "package acme.orders.v1;\n\noption go_package = \"example.com/shop/orders;orders\";\n")
+}
+
+// grpcStubs stand in for the google.golang.org/grpc packages the server uses and
+// for the code protoc generates from the service, so the server can be compiled
+// and run without network access or protoc
+var grpcStubs = map[string][]byte{
+	"go.mod": []byte(`module generated
+
+go 1.21
+
+require google.golang.org/grpc v0.0.0
+
+replace google.golang.org/grpc => ./grpcstub
+`),
+	"grpcstub/go.mod": []byte("module google.golang.org/grpc\n\ngo 1.21\n"),
+	"grpcstub/codes/codes.go": []byte(`package codes
+
+type Code uint32
+
+const (
+	OK                 Code = 0
+	InvalidArgument    Code = 3
+	NotFound           Code = 5
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	Internal           Code = 13
+)
+`),
+	"grpcstub/status/status.go": []byte(`package status
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+)
+
+type statusError struct {
+	code codes.Code
+	msg  string
+}
+
+func (e *statusError) Error() string { return e.msg }
+
+func Error(c codes.Code, msg string) error { return &statusError{c, msg} }
+
+func Errorf(c codes.Code, format string, a ...any) error { return Error(c, fmt.Sprintf(format, a...)) }
+
+func Code(err error) codes.Code {
+	if e, ok := err.(*statusError); ok {
+		return e.code
+	}
+	return codes.OK
+}
+`),
+	"order_state_machine_service.pb.go": []byte(`package orders
+
+import "context"
+
+type OrderStateMachineGetStateRequest struct{ Id string }
+
+func (x *OrderStateMachineGetStateRequest) GetId() string { return x.Id }
+
+type OrderStateMachineGetStateResponse struct{ Id, State string }
+
+type OrderStateMachineListPermittedEventsRequest struct{ Id string }
+
+func (x *OrderStateMachineListPermittedEventsRequest) GetId() string { return x.Id }
+
+type OrderStateMachineListPermittedEventsResponse struct {
+	Id, State string
+	Events    []string
+}
+
+type OrderStateMachineFireEventRequest struct{ Id, Event string }
+
+func (x *OrderStateMachineFireEventRequest) GetId() string    { return x.Id }
+func (x *OrderStateMachineFireEventRequest) GetEvent() string { return x.Event }
+
+type OrderStateMachineFireEventResponse struct{ Id, From, To string }
+
+type OrderStateMachineServiceServer interface {
+	GetState(context.Context, *OrderStateMachineGetStateRequest) (*OrderStateMachineGetStateResponse, error)
+	ListPermittedEvents(context.Context, *OrderStateMachineListPermittedEventsRequest) (*OrderStateMachineListPermittedEventsResponse, error)
+	FireEvent(context.Context, *OrderStateMachineFireEventRequest) (*OrderStateMachineFireEventResponse, error)
+	mustEmbedUnimplementedOrderStateMachineServiceServer()
+}
+
+type UnimplementedOrderStateMachineServiceServer struct{}
+
+func (UnimplementedOrderStateMachineServiceServer) mustEmbedUnimplementedOrderStateMachineServiceServer() {}
+`),
+}
+
+func TestCodeGenerator_GenerateGRPCServer(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	server, err := gen.GenerateGRPCServer(fsm)
+	require.NoError(t, err)
+	assert.True(t, IsGenerated(server))
+	assert.Equal(t, "order_state_machine_grpc_server.go", GRPCServerOutputName(fsm))
+	assert.Contains(t, string(server), "func NewOrderStateMachineServer(store OrderStateMachineStore, newMachine func() *OrderStateMachine) *OrderStateMachineServer {")
+
+	machine, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	store, err := gen.GenerateStore(fsm)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"order_state_machine_fsm.go":         machine,
+		"order_state_machine_store.go":       store,
+		"order_state_machine_grpc_server.go": server,
+		"server_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type memoryStore map[string]OrderStateMachineState
+
+func (m memoryStore) Load(ctx context.Context, id string) (OrderStateMachineState, error) {
+	state, ok := m[id]
+	if !ok {
+		return 0, ErrOrderStateMachineNotFound
+	}
+	return state, nil
+}
+
+func (m memoryStore) Save(ctx context.Context, id string, from, to OrderStateMachineState) error {
+	if m[id] != from {
+		return errors.New("concurrent update")
+	}
+	m[id] = to
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	ctx := context.Background()
+	store := memoryStore{"o1": OrderStateMachineStatePending}
+	paid := false
+	s := NewOrderStateMachineServer(store, func() *OrderStateMachine {
+		return NewOrderStateMachine(OrderStateMachineGuards{
+			HasPayment: func(context.Context, *OrderStateMachineContext) bool { return paid },
+		}, OrderStateMachineActions{})
+	})
+
+	got, err := s.GetState(ctx, &OrderStateMachineGetStateRequest{Id: "o1"})
+	if err != nil || got.State != "pending" {
+		t.Fatalf("GetState = %+v, %v", got, err)
+	}
+	events, err := s.ListPermittedEvents(ctx, &OrderStateMachineListPermittedEventsRequest{Id: "o1"})
+	if err != nil || len(events.Events) != 2 || events.Events[0] != "approve" || events.Events[1] != "reject" {
+		t.Fatalf("ListPermittedEvents = %+v, %v", events, err)
+	}
+
+	_, err = s.FireEvent(ctx, &OrderStateMachineFireEventRequest{Id: "o1", Event: "approve"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("guarded FireEvent error = %v", err)
+	}
+	paid = true
+	fired, err := s.FireEvent(ctx, &OrderStateMachineFireEventRequest{Id: "o1", Event: "approve"})
+	if err != nil || fired.From != "pending" || fired.To != "approved" {
+		t.Fatalf("FireEvent = %+v, %v", fired, err)
+	}
+	if store["o1"] != OrderStateMachineStateApproved {
+		t.Fatalf("stored state = %v", store["o1"])
+	}
+
+	for _, tc := range []struct {
+		id, event string
+		code      codes.Code
+	}{
+		{"o2", "ship", codes.NotFound},
+		{"", "ship", codes.InvalidArgument},
+		{"o1", "refund", codes.InvalidArgument},
+		{"o1", "reject", codes.FailedPrecondition},
+	} {
+		_, err := s.FireEvent(ctx, &OrderStateMachineFireEventRequest{Id: tc.id, Event: tc.event})
+		if status.Code(err) != tc.code {
+			t.Errorf("FireEvent(%q, %q) error = %v, want code %d", tc.id, tc.event, err, tc.code)
+		}
+	}
+}
+`),
+	}
+	for name, content := range grpcStubs {
+		files[name] = content
+	}
+	runGeneratedPackage(t, files)
+}
@@ -0,0 +1,36 @@
+package generator
+
+import (
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// fileHeader renders everything that precedes the package clause of a generated
+// file: the generated marker, the optional banner and stamp, and the build constraint
+func fileHeader(m *model.FSMModel) string {
+	var b strings.Builder
+	b.WriteString(GeneratedMarker + "\n")
+	if m.Header.Copyright != "" {
+		b.WriteString("//\n")
+		for _, line := range strings.Split(m.Header.Copyright, "\n") {
+			b.WriteString(strings.TrimRight("// "+line, " ") + "\n")
+		}
+	}
+
+	if m.Header.Stamp {
+		b.WriteString("//\n")
+		if m.Source.Path != "" {
+			b.WriteString("// Source: " + m.Source.Path + " (sha256:" + m.Source.Checksum + ")\n")
+		}
+		b.WriteString("// Generator: gofsm-gen " + Version + "\n")
+	}
+
+	if m.Header.BuildTags != "" {
+		b.WriteString("\n//go:build " + m.Header.BuildTags + "\n")
+	}
+
+	// Keep the banner from becoming the package doc comment
+	b.WriteString("\n")
+	return b.String()
+}
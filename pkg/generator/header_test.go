@@ -0,0 +1,68 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestFileHeader(t *testing.T) {
+	source := model.Source{Path: "orders/order.yaml", Checksum: "abc123"}
+
+	tests := []struct {
+		name   string
+		header model.Header
+		source model.Source
+		want   string
+	}{
+		{
+			name: "default header is only the marker, apart from the package doc",
+			want: GeneratedMarker + "\n\n",
+		},
+		{
+			name:   "multi-line copyright",
+			header: model.Header{Copyright: "Copyright 2026 Acme Corp.\n\nLicensed under the MIT License."},
+			want: GeneratedMarker + "\n" +
+				"//\n" +
+				"// Copyright 2026 Acme Corp.\n" +
+				"//\n" +
+				"// Licensed under the MIT License.\n" +
+				"\n",
+		},
+		{
+			name:   "stamp with source",
+			header: model.Header{Stamp: true},
+			source: source,
+			want: GeneratedMarker + "\n" +
+				"//\n" +
+				"// Source: orders/order.yaml (sha256:abc123)\n" +
+				"// Generator: gofsm-gen " + Version + "\n" +
+				"\n",
+		},
+		{
+			name:   "stamp without source",
+			header: model.Header{Stamp: true},
+			want: GeneratedMarker + "\n" +
+				"//\n" +
+				"// Generator: gofsm-gen " + Version + "\n" +
+				"\n",
+		},
+		{
+			name:   "build tags",
+			header: model.Header{BuildTags: "!fsm_stub"},
+			want: GeneratedMarker + "\n" +
+				"\n" +
+				"//go:build !fsm_stub\n" +
+				"\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &model.FSMModel{Header: tt.header, Source: tt.source}
+			assert.Equal(t, tt.want, fileHeader(m))
+		})
+	}
+}
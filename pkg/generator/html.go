@@ -0,0 +1,134 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// Layout of the HTML visualization: states are placed in columns by their
+// distance from the initial state, with unreachable states in a last column
+const (
+	htmlColumnWidth = 220
+	htmlRowHeight   = 90
+)
+
+// htmlState is a state node of the HTML visualization
+type htmlState struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Initial     bool   `json:"initial,omitempty"`
+	Final       bool   `json:"final,omitempty"`
+	Unreachable bool   `json:"unreachable,omitempty"`
+	Component   int    `json:"component"`
+	X           int    `json:"x"`
+	Y           int    `json:"y"`
+}
+
+// htmlTransition is an edge of the HTML visualization
+type htmlTransition struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label"`
+}
+
+// htmlComponent is a strongly connected component of the HTML visualization
+type htmlComponent struct {
+	States []string `json:"states"`
+	Cyclic bool     `json:"cyclic,omitempty"`
+}
+
+// htmlGraph is the machine as rendered by the HTML visualization
+type htmlGraph struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	States      []htmlState      `json:"states"`
+	Transitions []htmlTransition `json:"transitions"`
+
+	// Components are the strongly connected components in topological order,
+	// listed as the phases of the machine
+	Components []htmlComponent `json:"components"`
+}
+
+// htmlData is the template data of html.tmpl
+type htmlData struct {
+	*model.FSMModel
+	Graph string
+}
+
+// GenerateHTML generates a self-contained HTML page rendering the given FSM model as
+// an interactive graph that can be panned, zoomed, and explored state by state
+func (g *CodeGenerator) GenerateHTML(m *model.FSMModel) ([]byte, error) {
+	m, err := prepare(m)
+	if err != nil {
+		return nil, err
+	}
+
+	// json.Marshal escapes <, >, and & so the graph is safe inside a script element
+	graph, err := json.Marshal(layoutHTMLGraph(m))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode graph: %w", err)
+	}
+	return g.executeData("html.tmpl", m, htmlData{FSMModel: m, Graph: string(graph)})
+}
+
+// HTMLOutputName returns the conventional HTML visualization file name for a model
+func HTMLOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + ".html"
+}
+
+// layoutHTMLGraph places every state in the column of its breadth-first distance
+// from the initial state; states within a column keep name order
+func layoutHTMLGraph(m *model.FSMModel) htmlGraph {
+	stateGraph := model.NewStateGraph(m)
+	if err := stateGraph.Build(); err != nil {
+		return htmlGraph{}
+	}
+	condensation := stateGraph.Condense()
+
+	transitionsFrom := m.GetTransitionsByState()
+	depth := map[string]int{m.Initial: 0}
+	queue := []string{m.Initial}
+	maxDepth := 0
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, t := range transitionsFrom[from] {
+			if _, seen := depth[t.To]; !seen {
+				depth[t.To] = depth[from] + 1
+				maxDepth = max(maxDepth, depth[t.To])
+				queue = append(queue, t.To)
+			}
+		}
+	}
+
+	graph := htmlGraph{Name: m.Name, Description: m.Description}
+	rows := make(map[int]int)
+	for _, state := range m.GetStatesSlice() {
+		column, reachable := depth[state.Name]
+		if !reachable {
+			column = maxDepth + 1
+		}
+		graph.States = append(graph.States, htmlState{
+			Name:        state.Name,
+			Description: state.Description,
+			Initial:     state.Name == m.Initial,
+			Final:       state.Final,
+			Unreachable: !reachable,
+			Component:   condensation.ComponentOf(state.Name),
+			X:           column * htmlColumnWidth,
+			Y:           rows[column] * htmlRowHeight,
+		})
+		rows[column]++
+	}
+
+	graph.Transitions = make([]htmlTransition, 0, len(m.Transitions))
+	for _, t := range m.Transitions {
+		graph.Transitions = append(graph.Transitions, htmlTransition{From: t.From, To: t.To, Label: transitionLabel(t)})
+	}
+	for i, states := range condensation.Components {
+		graph.Components = append(graph.Components, htmlComponent{States: states, Cyclic: condensation.Cyclic[i]})
+	}
+	return graph
+}
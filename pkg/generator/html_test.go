@@ -0,0 +1,56 @@
+package generator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateHTML(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Description = "Orders </script><script>alert(1)</script>"
+	fsm.GetState("shipped").Final = true
+	fsm.AddState(&model.State{Name: "archived"})
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	page, err := gen.GenerateHTML(fsm)
+	require.NoError(t, err)
+	src := string(page)
+
+	assert.True(t, strings.HasPrefix(src, "<!DOCTYPE html>\n<!-- Code generated by gofsm-gen. DO NOT EDIT. -->\n"))
+	assert.Contains(t, src, "<title>OrderStateMachine state machine</title>")
+	assert.Equal(t, 1, strings.Count(src, "</script>"), "Spec text must not be able to close the script element")
+	assert.NotContains(t, src, "<script src=", "The page must be self-contained")
+
+	line := src[strings.Index(src, "const graph = ")+len("const graph = "):]
+	line = strings.TrimSuffix(line[:strings.Index(line, "\n")], ";")
+	var graph htmlGraph
+	require.NoError(t, json.Unmarshal([]byte(line), &graph))
+
+	assert.Equal(t, "Orders </script><script>alert(1)</script>", graph.Description)
+	positions := make(map[string]htmlState)
+	for _, s := range graph.States {
+		positions[s.Name] = s
+	}
+	assert.True(t, positions["pending"].Initial)
+	assert.Equal(t, 0, positions["pending"].X)
+	assert.Equal(t, htmlColumnWidth, positions["approved"].X)
+	assert.Equal(t, htmlColumnWidth, positions["rejected"].X)
+	assert.Equal(t, htmlRowHeight, positions["rejected"].Y, "States in one column are stacked in name order")
+	assert.Equal(t, 2*htmlColumnWidth, positions["shipped"].X)
+	assert.True(t, positions["shipped"].Final)
+	assert.True(t, positions["archived"].Unreachable)
+	assert.Equal(t, 3*htmlColumnWidth, positions["archived"].X, "Unreachable states follow the last column")
+
+	assert.Equal(t, []htmlComponent{{States: []string{"archived"}}, {States: []string{"pending"}}, {States: []string{"approved"}}, {States: []string{"rejected"}}, {States: []string{"shipped"}}}, graph.Components)
+	assert.Equal(t, 4, positions["shipped"].Component)
+	assert.Contains(t, src, `id="phases"`)
+
+	require.Len(t, graph.Transitions, 3)
+	assert.Equal(t, htmlTransition{From: "pending", To: "approved", Label: "approve [hasPayment] / chargeCard"}, graph.Transitions[0])
+}
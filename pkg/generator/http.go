@@ -0,0 +1,16 @@
+package generator
+
+import "github.com/yourusername/gofsm-gen/pkg/model"
+
+// GenerateHTTPHandler generates net/http handlers that get the state of machine
+// instances kept in the {Name}Store of GenerateStore, list their permitted events,
+// and fire events with the machine context as JSON body. The handlers carry swag
+// OpenAPI annotations.
+func (g *CodeGenerator) GenerateHTTPHandler(m *model.FSMModel) ([]byte, error) {
+	return g.execute("http_server.tmpl", m)
+}
+
+// HTTPHandlerOutputName returns the conventional HTTP handler file name for a model
+func HTTPHandlerOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + "_http.go"
+}
@@ -0,0 +1,107 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateHTTPHandler(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "amount", Type: model.FieldInt}))
+	require.NoError(t, fsm.AddGuardCondition(&model.GuardCondition{Name: "hasPayment", When: "amount > 0"}))
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	handler, err := gen.GenerateHTTPHandler(fsm)
+	require.NoError(t, err)
+	src := string(handler)
+	assert.True(t, IsGenerated(handler))
+	assert.Equal(t, "order_state_machine_http.go", HTTPHandlerOutputName(fsm))
+	assert.Contains(t, src, "func NewOrderStateMachineHandler(store OrderStateMachineStore, newMachine func() *OrderStateMachine) *OrderStateMachineHandler {")
+	assert.Contains(t, src, "//	@Router		/order_state_machine/{id}/events/{event} [post]")
+	assert.Contains(t, src, `//	@Param		event	path		string	true	"Event"	Enums(approve, reject, ship)`)
+
+	machine, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	store, err := gen.GenerateStore(fsm)
+	require.NoError(t, err)
+
+	runGeneratedPackage(t, map[string][]byte{
+		"go.mod":                       []byte("module generated\n\ngo 1.22\n"),
+		"order_state_machine_fsm.go":   machine,
+		"order_state_machine_store.go": store,
+		"order_state_machine_http.go":  handler,
+		"handler_test.go": []byte(`package orders
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type memoryStore map[string]OrderStateMachineState
+
+func (m memoryStore) Load(ctx context.Context, id string) (OrderStateMachineState, error) {
+	state, ok := m[id]
+	if !ok {
+		return 0, ErrOrderStateMachineNotFound
+	}
+	return state, nil
+}
+
+func (m memoryStore) Save(ctx context.Context, id string, from, to OrderStateMachineState) error {
+	if m[id] != from {
+		return errors.New("concurrent update")
+	}
+	m[id] = to
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	store := memoryStore{"o1": OrderStateMachineStatePending}
+	h := NewOrderStateMachineHandler(store, func() *OrderStateMachine {
+		return NewOrderStateMachine(OrderStateMachineGuards{
+			HasPayment: func(_ context.Context, c *OrderStateMachineContext) bool { return c.Amount > 0 },
+		}, OrderStateMachineActions{})
+	})
+	mux := http.NewServeMux()
+	h.Register(mux, "/orders")
+
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+		response           string
+	}{
+		{"GET", "/orders/o1", "", 200, ` + "`" + `{"id":"o1","state":"pending"}` + "`" + `},
+		{"GET", "/orders/o1/events", "", 200, ` + "`" + `{"id":"o1","state":"pending","events":["approve","reject"]}` + "`" + `},
+		{"GET", "/orders/o2", "", 404, ` + "`" + `{"error":"o2: OrderStateMachine instance not found"}` + "`" + `},
+		{"POST", "/orders/o1/events/refund", "", 400, ` + "`" + `{"error":"unknown OrderStateMachine event refund"}` + "`" + `},
+		{"POST", "/orders/o1/events/approve", ` + "`" + `{"amont": 5}` + "`" + `, 400, ""},
+		{"POST", "/orders/o1/events/approve", "", 422, ` + "`" + `{"error":"guard condition failed for transition from pending on approve"}` + "`" + `},
+		{"POST", "/orders/o1/events/approve", ` + "`" + `{"amount": 5}` + "`" + `, 200, ` + "`" + `{"id":"o1","from":"pending","to":"approved"}` + "`" + `},
+		{"GET", "/orders/o1/events", "", 200, ` + "`" + `{"id":"o1","state":"approved","events":["ship"]}` + "`" + `},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.code {
+			t.Errorf("%s %s = %d %s, want %d", tc.method, tc.path, rec.Code, rec.Body, tc.code)
+		}
+		if tc.response != "" && strings.TrimSpace(rec.Body.String()) != tc.response {
+			t.Errorf("%s %s = %s, want %s", tc.method, tc.path, rec.Body, tc.response)
+		}
+	}
+	if store["o1"] != OrderStateMachineStateApproved {
+		t.Errorf("stored state = %v", store["o1"])
+	}
+}
+`),
+	})
+}
@@ -0,0 +1,42 @@
+package generator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// markdownData is the template data of markdown.tmpl
+type markdownData struct {
+	*model.FSMModel
+}
+
+// GenerateMarkdown generates a Markdown document with a transition table and a
+// state reference for the given FSM model, for embedding in design docs
+func (g *CodeGenerator) GenerateMarkdown(m *model.FSMModel) ([]byte, error) {
+	return g.executeData("markdown.tmpl", m, markdownData{FSMModel: m})
+}
+
+// MarkdownOutputName returns the conventional Markdown file name for a model
+func MarkdownOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + ".md"
+}
+
+// TransitionRows returns the transitions ordered by source state and event name,
+// keeping declaration order, which is guard priority, between equal rows
+func (d markdownData) TransitionRows() []*model.Transition {
+	rows := append([]*model.Transition(nil), d.Transitions...)
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].From != rows[j].From {
+			return rows[i].From < rows[j].From
+		}
+		return rows[i].Event < rows[j].Event
+	})
+	return rows
+}
+
+// mdCell escapes text for use in a Markdown table cell
+func mdCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -0,0 +1,40 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateMarkdown(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Description = "Manages the lifecycle of customer orders"
+	fsm.GetState("shipped").Final = true
+	fsm.GetState("approved").ExitAction = "releaseHold"
+	fsm.GetEvent("reject").Description = "Decline | cancel"
+	fallback, _ := model.NewTransition("pending", "rejected", "approve")
+	fsm.AddTransition(fallback)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	doc, err := gen.GenerateMarkdown(fsm)
+	require.NoError(t, err)
+	src := string(doc)
+
+	assert.True(t, strings.HasPrefix(src, "<!-- Code generated by gofsm-gen. DO NOT EDIT. -->\n# OrderStateMachine\n\nManages the lifecycle of customer orders\n"))
+	assert.Contains(t, src, "- Initial state: `pending`\n- Final states: `shipped`\n")
+	assert.Contains(t, src, "| State | Event | Next state | Guard | Action |\n"+
+		"|-------|-------|------------|-------|--------|\n"+
+		"| `approved` | `ship` | `shipped` | - | `notifyShipping` |\n"+
+		"| `pending` | `approve` | `approved` | `hasPayment` | `chargeCard` |\n"+
+		"| `pending` | `approve` | `rejected` | - | - |\n"+
+		"| `pending` | `reject` | `rejected` | - | `sendRejectionEmail` |\n")
+	assert.Contains(t, src, "### `approved`\n\n- Exit action: `releaseHold`\n\n| Event | Next state | Guard | Action |\n")
+	assert.Contains(t, src, "### `pending` (initial)\n\n- Entry action: `logEntry`\n- Exit action: `logExit`\n")
+	assert.Contains(t, src, "### `shipped` (final)\n\n- Entry action: `notifyCustomer`\n\nNo events are accepted in this state.\n")
+	assert.Contains(t, src, "| `reject` | Decline \\| cancel |\n")
+	assert.Contains(t, src, "| `approve` | - |\n")
+}
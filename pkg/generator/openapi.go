@@ -0,0 +1,50 @@
+package generator
+
+import "github.com/yourusername/gofsm-gen/pkg/model"
+
+// OpenAPIOptions controls the OpenAPI export
+type OpenAPIOptions struct {
+	// Version is the info.version of the exported document; empty means "1.0.0"
+	Version string
+}
+
+// openapiData is the template data of openapi.tmpl
+type openapiData struct {
+	*model.FSMModel
+	Version string
+}
+
+// GenerateOpenAPI generates an OpenAPI 3.1 document whose component schemas describe
+// the state and event enums of the given FSM model and an AllowedEvents response model
+func (g *CodeGenerator) GenerateOpenAPI(m *model.FSMModel, opts OpenAPIOptions) ([]byte, error) {
+	data := openapiData{FSMModel: m, Version: opts.Version}
+	if data.Version == "" {
+		data.Version = "1.0.0"
+	}
+	return g.executeData("openapi.tmpl", m, data)
+}
+
+// OpenAPIOutputName returns the conventional OpenAPI file name for a model
+func OpenAPIOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + ".openapi.yaml"
+}
+
+// HasStateDescriptions reports whether any state is described
+func (d openapiData) HasStateDescriptions() bool {
+	for _, state := range d.States {
+		if state.Description != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEventDescriptions reports whether any event is described
+func (d openapiData) HasEventDescriptions() bool {
+	for _, event := range d.Events {
+		if event.Description != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCodeGenerator_GenerateOpenAPI(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Description = "Manages the lifecycle of customer orders"
+	fsm.GetState("shipped").Description = "Order has left the warehouse"
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	doc, err := gen.GenerateOpenAPI(fsm, OpenAPIOptions{Version: "2.1.0"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(doc), "# Code generated by gofsm-gen. DO NOT EDIT.\n"))
+
+	var parsed struct {
+		OpenAPI string `yaml:"openapi"`
+		Info    struct {
+			Title       string `yaml:"title"`
+			Version     string `yaml:"version"`
+			Description string `yaml:"description"`
+		} `yaml:"info"`
+		Components struct {
+			Schemas map[string]struct {
+				Type             string                    `yaml:"type"`
+				Enum             []string                  `yaml:"enum"`
+				EnumDescriptions []string                  `yaml:"x-enum-descriptions"`
+				Required         []string                  `yaml:"required"`
+				Properties       map[string]map[string]any `yaml:"properties"`
+			} `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	require.NoError(t, yaml.Unmarshal(doc, &parsed), "The document must be valid YAML")
+
+	assert.Equal(t, "3.1.0", parsed.OpenAPI)
+	assert.Equal(t, "OrderStateMachine", parsed.Info.Title)
+	assert.Equal(t, "2.1.0", parsed.Info.Version)
+	assert.Equal(t, "Manages the lifecycle of customer orders", parsed.Info.Description)
+
+	schemas := parsed.Components.Schemas
+	state := schemas["OrderStateMachineState"]
+	assert.Equal(t, "string", state.Type)
+	assert.Equal(t, []string{"approved", "pending", "rejected", "shipped"}, state.Enum)
+	assert.Equal(t, []string{"", "", "", "Order has left the warehouse"}, state.EnumDescriptions)
+
+	event := schemas["OrderStateMachineEvent"]
+	assert.Equal(t, []string{"approve", "reject", "ship"}, event.Enum)
+	assert.Nil(t, event.EnumDescriptions, "Undescribed enums should not carry descriptions")
+
+	allowed := schemas["OrderStateMachineAllowedEvents"]
+	assert.Equal(t, []string{"state", "allowed_events"}, allowed.Required)
+	assert.Equal(t, "#/components/schemas/OrderStateMachineState", allowed.Properties["state"]["$ref"])
+	assert.Equal(t, "array", allowed.Properties["allowed_events"]["type"])
+}
@@ -0,0 +1,279 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// FileAction describes what generation would do to a single file
+type FileAction string
+
+const (
+	// FileActionCreate means the file does not exist yet
+	FileActionCreate FileAction = "create"
+	// FileActionUpdate means the file exists with different content
+	FileActionUpdate FileAction = "update"
+	// FileActionDelete means a previously generated file is no longer produced
+	FileActionDelete FileAction = "delete"
+	// FileActionUnchanged means the file exists with identical content
+	FileActionUnchanged FileAction = "unchanged"
+)
+
+// FileChange is the planned change for one output file
+type FileChange struct {
+	// Path is the output file path
+	Path string
+
+	// Action is what would happen to the file
+	Action FileAction
+
+	// Added lists exported API identifiers that would be introduced
+	Added []string
+
+	// Removed lists exported API identifiers that would disappear
+	Removed []string
+}
+
+// Plan is the set of changes generation would make, computed without writing anything
+type Plan struct {
+	Changes []FileChange
+}
+
+// PlannedFile is a file that generation would produce
+type PlannedFile struct {
+	Path    string
+	Content []byte
+}
+
+// NewPlan compares the planned files against the files currently on disk.
+// Paths listed in stale are previously generated files that would be removed.
+func NewPlan(files []PlannedFile, stale []string) (*Plan, error) {
+	plan := &Plan{}
+
+	for _, f := range files {
+		change, err := planFile(f)
+		if err != nil {
+			return nil, err
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	for _, path := range stale {
+		existing, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		plan.Changes = append(plan.Changes, FileChange{
+			Path:    path,
+			Action:  FileActionDelete,
+			Removed: apiSurface(path, existing),
+		})
+	}
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].Path < plan.Changes[j].Path
+	})
+
+	return plan, nil
+}
+
+// planFile computes the change for a single planned file
+func planFile(f PlannedFile) (FileChange, error) {
+	existing, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return FileChange{
+			Path:   f.Path,
+			Action: FileActionCreate,
+			Added:  apiSurface(f.Path, f.Content),
+		}, nil
+	}
+	if err != nil {
+		return FileChange{}, fmt.Errorf("failed to read %s: %w", f.Path, err)
+	}
+
+	if bytes.Equal(existing, f.Content) {
+		return FileChange{Path: f.Path, Action: FileActionUnchanged}, nil
+	}
+
+	added, removed := diffIdentifiers(apiSurface(f.Path, existing), apiSurface(f.Path, f.Content))
+	return FileChange{
+		Path:    f.Path,
+		Action:  FileActionUpdate,
+		Added:   added,
+		Removed: removed,
+	}, nil
+}
+
+// HasChanges returns true if the plan would create, update, or delete any file
+func (p *Plan) HasChanges() bool {
+	for _, c := range p.Changes {
+		if c.Action != FileActionUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of changes with the given action
+func (p *Plan) Count(action FileAction) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Render writes a human-readable summary of the plan
+func (p *Plan) Render(w io.Writer) error {
+	var b strings.Builder
+
+	if !p.HasChanges() {
+		b.WriteString("No changes. Generated files are up to date.\n")
+	} else {
+		b.WriteString("gofsm-gen will perform the following actions:\n\n")
+		for _, c := range p.Changes {
+			if c.Action == FileActionUnchanged {
+				continue
+			}
+			fmt.Fprintf(&b, "  %s %s (%s)\n", actionSymbol(c.Action), c.Path, c.Action)
+			for _, id := range c.Added {
+				fmt.Fprintf(&b, "      + %s\n", id)
+			}
+			for _, id := range c.Removed {
+				fmt.Fprintf(&b, "      - %s\n", id)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d to delete, %d unchanged.\n",
+		p.Count(FileActionCreate), p.Count(FileActionUpdate), p.Count(FileActionDelete), p.Count(FileActionUnchanged))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// actionSymbol returns the plan marker for an action
+func actionSymbol(action FileAction) string {
+	switch action {
+	case FileActionCreate:
+		return "+"
+	case FileActionUpdate:
+		return "~"
+	case FileActionDelete:
+		return "-"
+	case FileActionUnchanged:
+		return " "
+	default:
+		return "?"
+	}
+}
+
+// diffIdentifiers returns identifiers only present in after (added) and only present in before (removed)
+func diffIdentifiers(before, after []string) (added, removed []string) {
+	beforeSet := make(map[string]bool, len(before))
+	for _, id := range before {
+		beforeSet[id] = true
+	}
+	afterSet := make(map[string]bool, len(after))
+	for _, id := range after {
+		afterSet[id] = true
+		if !beforeSet[id] {
+			added = append(added, id)
+		}
+	}
+	for _, id := range before {
+		if !afterSet[id] {
+			removed = append(removed, id)
+		}
+	}
+	return added, removed
+}
+
+// apiSurface returns the exported identifiers declared by a Go source file.
+// Non-Go files and sources that fail to parse have no API surface.
+func apiSurface(path string, src []byte) []string {
+	if !strings.HasSuffix(path, ".go") {
+		return nil
+	}
+
+	file, err := parser.ParseFile(token.NewFileSet(), path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+
+	var ids []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !d.Name.IsExported() {
+				continue
+			}
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				ids = append(ids, fmt.Sprintf("method %s.%s", receiverName(d.Recv.List[0].Type), d.Name.Name))
+			} else {
+				ids = append(ids, "func "+d.Name.Name)
+			}
+		case *ast.GenDecl:
+			ids = append(ids, genDeclIdentifiers(d)...)
+		}
+	}
+
+	sort.Strings(ids)
+	return ids
+}
+
+// genDeclIdentifiers returns exported identifiers from a const, var, or type declaration
+func genDeclIdentifiers(d *ast.GenDecl) []string {
+	var ids []string
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.ValueSpec:
+			for _, name := range s.Names {
+				if name.IsExported() {
+					ids = append(ids, fmt.Sprintf("%s %s", d.Tok, name.Name))
+				}
+			}
+		case *ast.TypeSpec:
+			if !s.Name.IsExported() {
+				continue
+			}
+			ids = append(ids, "type "+s.Name.Name)
+			if st, ok := s.Type.(*ast.StructType); ok {
+				for _, field := range st.Fields.List {
+					for _, name := range field.Names {
+						if name.IsExported() {
+							ids = append(ids, fmt.Sprintf("field %s.%s", s.Name.Name, name.Name))
+						}
+					}
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// receiverName returns the type name of a method receiver
+func receiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	case *ast.Ident:
+		return e.Name
+	case *ast.IndexExpr:
+		return receiverName(e.X)
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestNewPlan_FileActions(t *testing.T) {
+	dir := t.TempDir()
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	fsm := createOrderStateMachine(t)
+	code, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	unchangedPath := filepath.Join(dir, "unchanged_fsm.gen.go")
+	require.NoError(t, os.WriteFile(unchangedPath, code, 0o600))
+
+	stalePath := filepath.Join(dir, "legacy_fsm.gen.go")
+	require.NoError(t, os.WriteFile(stalePath, code, 0o600))
+
+	createPath := filepath.Join(dir, "order_state_machine_fsm.gen.go")
+
+	plan, err := NewPlan([]PlannedFile{
+		{Path: createPath, Content: code},
+		{Path: unchangedPath, Content: code},
+	}, []string{stalePath})
+	require.NoError(t, err)
+
+	require.Len(t, plan.Changes, 3)
+	actions := make(map[string]FileAction)
+	for _, c := range plan.Changes {
+		actions[c.Path] = c.Action
+	}
+	assert.Equal(t, FileActionCreate, actions[createPath])
+	assert.Equal(t, FileActionUnchanged, actions[unchangedPath])
+	assert.Equal(t, FileActionDelete, actions[stalePath])
+	assert.True(t, plan.HasChanges())
+	assert.Equal(t, 1, plan.Count(FileActionCreate))
+}
+
+func TestNewPlan_UpdateReportsAPIChanges(t *testing.T) {
+	dir := t.TempDir()
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	before := createOrderStateMachine(t)
+	oldCode, err := gen.Generate(before)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "order_state_machine_fsm.gen.go")
+	require.NoError(t, os.WriteFile(path, oldCode, 0o600))
+
+	// Rename the guard and add a cancellation path
+	after := createOrderStateMachine(t)
+	after.Transitions[0].Guard = "paymentAuthorized"
+	cancelled, _ := model.NewState("cancelled")
+	require.NoError(t, after.AddState(cancelled))
+	cancel, _ := model.NewEvent("cancel")
+	require.NoError(t, after.AddEvent(cancel))
+	cancelTransition, _ := model.NewTransition("pending", "cancelled", "cancel")
+	require.NoError(t, after.AddTransition(cancelTransition))
+
+	newCode, err := gen.Generate(after)
+	require.NoError(t, err)
+
+	plan, err := NewPlan([]PlannedFile{{Path: path, Content: newCode}}, nil)
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 1)
+
+	change := plan.Changes[0]
+	assert.Equal(t, FileActionUpdate, change.Action)
+	assert.Contains(t, change.Added, "const OrderStateMachineStateCancelled")
+	assert.Contains(t, change.Added, "const OrderStateMachineEventCancel")
+	assert.Contains(t, change.Added, "field OrderStateMachineGuards.PaymentAuthorized")
+	assert.Contains(t, change.Removed, "field OrderStateMachineGuards.HasPayment")
+	assert.NotContains(t, change.Added, "method OrderStateMachine.Transition",
+		"Unchanged API should not be reported")
+}
+
+func TestPlan_Render(t *testing.T) {
+	plan := &Plan{Changes: []FileChange{
+		{Path: "orders/order_fsm.gen.go", Action: FileActionUpdate, Added: []string{"const OrderStateCancelled"}},
+		{Path: "orders/legacy_fsm.gen.go", Action: FileActionDelete},
+		{Path: "doors/door_fsm.gen.go", Action: FileActionUnchanged},
+	}}
+
+	var out strings.Builder
+	require.NoError(t, plan.Render(&out))
+
+	rendered := out.String()
+	assert.Contains(t, rendered, "~ orders/order_fsm.gen.go (update)")
+	assert.Contains(t, rendered, "+ const OrderStateCancelled")
+	assert.Contains(t, rendered, "- orders/legacy_fsm.gen.go (delete)")
+	assert.NotContains(t, rendered, "doors/door_fsm.gen.go")
+	assert.Contains(t, rendered, "Plan: 0 to create, 1 to update, 1 to delete, 1 unchanged.")
+}
+
+func TestPlan_RenderNoChanges(t *testing.T) {
+	plan := &Plan{Changes: []FileChange{{Path: "door_fsm.gen.go", Action: FileActionUnchanged}}}
+
+	var out strings.Builder
+	require.NoError(t, plan.Render(&out))
+
+	assert.False(t, plan.HasChanges())
+	assert.Contains(t, out.String(), "No changes.")
+}
+
+func TestIsGenerated(t *testing.T) {
+	assert.True(t, IsGenerated([]byte(GeneratedMarker+"\npackage orders\n")))
+	assert.False(t, IsGenerated([]byte("package orders\n\nfunc helper() {}\n")))
+}
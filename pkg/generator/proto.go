@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// ProtoOptions controls the Protocol Buffers export
+type ProtoOptions struct {
+	// Package is the proto package name; empty means the Go package of the model
+	Package string
+
+	// TransitionRecord adds a {Name}TransitionRecord message describing one transition
+	TransitionRecord bool
+}
+
+// protoData is the template data of proto.tmpl
+type protoData struct {
+	*model.FSMModel
+	ProtoPackage     string
+	TransitionRecord bool
+}
+
+// protoEnumValue is a single value of an exported proto enum
+type protoEnumValue struct {
+	Name        string
+	Value       int
+	Description string
+}
+
+// GenerateProto generates a .proto file declaring State and Event enums whose
+// values match the generated Go constants of the given FSM model
+func (g *CodeGenerator) GenerateProto(m *model.FSMModel, opts ProtoOptions) ([]byte, error) {
+	m, err := prepare(m)
+	if err != nil {
+		return nil, err
+	}
+	data := protoData{FSMModel: m, ProtoPackage: opts.Package, TransitionRecord: opts.TransitionRecord}
+	if data.ProtoPackage == "" {
+		data.ProtoPackage = m.Package
+	}
+	return g.executeData("proto.tmpl", m, data)
+}
+
+// ProtoOutputName returns the conventional .proto file name for a model
+func ProtoOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + ".proto"
+}
+
+// StateValues returns the values of the state enum ordered by number. Proto3 enums
+// must declare 0, so a STATE_UNSPECIFIED value is added when no state is 0.
+func (d protoData) StateValues() []protoEnumValue {
+	values := make([]protoEnumValue, 0, len(d.States)+1)
+	stateValues := d.GetStateValues()
+	for _, state := range d.GetStatesSlice() {
+		values = append(values, protoEnumValue{
+			Name:        d.protoValueName("state", state.Name),
+			Value:       stateValues[state.Name],
+			Description: state.Description,
+		})
+	}
+	return withProtoZero(values, d.protoValueName("state", "unspecified"))
+}
+
+// EventValues returns the values of the event enum ordered by number. Proto3 enums
+// must declare 0, so an EVENT_UNSPECIFIED value is added when no event is 0.
+func (d protoData) EventValues() []protoEnumValue {
+	values := make([]protoEnumValue, 0, len(d.Events)+1)
+	eventValues := d.GetEventValues()
+	for _, event := range d.GetEventsSlice() {
+		values = append(values, protoEnumValue{
+			Name:        d.protoValueName("event", event.Name),
+			Value:       eventValues[event.Name],
+			Description: event.Description,
+		})
+	}
+	return withProtoZero(values, d.protoValueName("event", "unspecified"))
+}
+
+// protoValueName returns the prefixed UPPER_SNAKE_CASE name of an enum value,
+// following the proto style guide since enum values share the package scope
+func (d protoData) protoValueName(kind, name string) string {
+	return strings.ToUpper(snakeCase(d.Name) + "_" + kind + "_" + snakeCase(name))
+}
+
+// withProtoZero sorts values by number and adds a zero value named zero if none exists
+func withProtoZero(values []protoEnumValue, zero string) []protoEnumValue {
+	sort.Slice(values, func(i, j int) bool { return values[i].Value < values[j].Value })
+	if len(values) == 0 || values[0].Value != 0 {
+		values = append([]protoEnumValue{{Name: zero, Value: 0}}, values...)
+	}
+	return values
+}
@@ -0,0 +1,69 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateProto(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.GetState("shipped").Description = "Order has left the warehouse"
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	proto, err := gen.GenerateProto(fsm, ProtoOptions{})
+	require.NoError(t, err)
+	src := string(proto)
+
+	assert.True(t, IsGenerated(proto))
+	assert.Contains(t, src, `syntax = "proto3";`)
+	assert.Contains(t, src, "package orders;", "The proto package should default to the Go package")
+	assert.Contains(t, src, `enum OrderStateMachineState {
+  ORDER_STATE_MACHINE_STATE_PENDING = 0;
+  ORDER_STATE_MACHINE_STATE_APPROVED = 1;
+  ORDER_STATE_MACHINE_STATE_REJECTED = 2;
+  // Order has left the warehouse
+  ORDER_STATE_MACHINE_STATE_SHIPPED = 3;
+}`)
+	assert.Contains(t, src, `enum OrderStateMachineEvent {
+  ORDER_STATE_MACHINE_EVENT_APPROVE = 0;
+  ORDER_STATE_MACHINE_EVENT_REJECT = 1;
+  ORDER_STATE_MACHINE_EVENT_SHIP = 2;
+}`)
+	assert.NotContains(t, src, "TransitionRecord")
+	assert.NotContains(t, src, "import")
+
+	proto, err = gen.GenerateProto(fsm, ProtoOptions{Package: "acme.orders.v1", TransitionRecord: true})
+	require.NoError(t, err)
+	src = string(proto)
+	assert.Contains(t, src, "package acme.orders.v1;")
+	assert.Contains(t, src, `import "google/protobuf/timestamp.proto";`)
+	assert.Contains(t, src, "message OrderStateMachineTransitionRecord {")
+	assert.Contains(t, src, "OrderStateMachineEvent event = 3;")
+}
+
+func TestCodeGenerator_GenerateProto_ZeroValues(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Options.ZeroState = model.ZeroStateInvalid
+	for i, event := range fsm.GetEventsSlice() {
+		value := i + 1
+		event.Value = &value
+	}
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	proto, err := gen.GenerateProto(fsm, ProtoOptions{})
+	require.NoError(t, err)
+
+	// Proto3 enums must start at zero, so unused zero values get a sentinel
+	src := string(proto)
+	assert.Contains(t, src, "  ORDER_STATE_MACHINE_STATE_UNSPECIFIED = 0;\n  ORDER_STATE_MACHINE_STATE_APPROVED = 1;")
+	assert.Contains(t, src, "  ORDER_STATE_MACHINE_EVENT_UNSPECIFIED = 0;\n  ORDER_STATE_MACHINE_EVENT_APPROVE = 1;")
+	assert.Equal(t, 1, strings.Count(src, "STATE_UNSPECIFIED"))
+}
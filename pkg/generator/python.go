@@ -0,0 +1,27 @@
+package generator
+
+import (
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// pythonData is the template data of python.tmpl
+type pythonData struct {
+	*model.FSMModel
+}
+
+// GeneratePython generates a Python module with Enum states and events, dataclass
+// context and callbacks, and a machine class running them like the Go machine
+func (g *CodeGenerator) GeneratePython(m *model.FSMModel) ([]byte, error) {
+	return g.executeData("python.tmpl", m, pythonData{FSMModel: m})
+}
+
+// PythonOutputName returns the conventional Python file name for a model
+func PythonOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + ".py"
+}
+
+// TransitionMap returns the transitions of every state by event, like the
+// TypeScript transition map
+func (d pythonData) TransitionMap() []tsStateTransitions {
+	return typescriptData{FSMModel: d.FSMModel}.TransitionMap()
+}
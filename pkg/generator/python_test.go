@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GeneratePython(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "amount", Type: model.FieldInt, Description: "Amount is the order total in cents"}))
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "rate", Type: model.FieldFloat}))
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "note", Type: model.FieldString}))
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "express", Type: model.FieldBool}))
+	require.NoError(t, fsm.AddGuardCondition(&model.GuardCondition{Name: "hasPayment", When: "amount > 0"}))
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	py, err := gen.GeneratePython(fsm)
+	require.NoError(t, err)
+	src := string(py)
+
+	assert.Equal(t, "order_state_machine.py", PythonOutputName(fsm))
+	assert.Contains(t, src, "# Code generated by gofsm-gen. DO NOT EDIT.\n\"\"\"OrderStateMachine state machine.\"\"\"\n")
+	assert.Contains(t, src, `class OrderStateMachineState(str, enum.Enum):
+    """OrderStateMachine states."""
+
+    APPROVED = "approved"
+    PENDING = "pending"
+    REJECTED = "rejected"
+    SHIPPED = "shipped"
+`)
+	assert.Contains(t, src, "INITIAL_STATE = OrderStateMachineState.PENDING\n")
+	assert.Contains(t, src, `    #: Amount is the order total in cents
+    amount: int = 0
+    rate: float = 0.0
+    note: str = ""
+    express: bool = False
+`)
+	assert.Contains(t, src, `    #: Must pass when amount > 0
+    has_payment: Optional[Callable[[OrderStateMachineContext], bool]] = None
+`)
+	assert.Contains(t, src, "    charge_card: Optional[Callable[[OrderStateMachineState, OrderStateMachineState, OrderStateMachineContext], None]] = None\n")
+	assert.Contains(t, src, `        OrderStateMachineEvent.APPROVE: (
+            _Transition(OrderStateMachineState.APPROVED, guard="has_payment", action="charge_card"),
+        ),`)
+	assert.Contains(t, src, `_EXIT_ACTIONS: Dict[OrderStateMachineState, str] = {
+    OrderStateMachineState.PENDING: "log_exit",
+}`)
+	assert.Contains(t, src, "class OrderStateMachine:\n")
+}
+
+func TestCodeGenerator_GeneratePython_Runs(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+
+	fsm := createOrderStateMachine(t)
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "amount", Type: model.FieldInt}))
+	fsm.AfterTransition = "touchUpdatedAt"
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	py, err := gen.GeneratePython(fsm)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, PythonOutputName(fsm)), py, 0o644))
+	script := `
+from order_state_machine import *
+
+calls = []
+machine = OrderStateMachine(
+    guards=OrderStateMachineGuards(has_payment=lambda c: c.amount > 0),
+    actions=OrderStateMachineActions(
+        charge_card=lambda f, t, c: calls.append(f"charge {f.value}->{t.value}"),
+        touch_updated_at=lambda f, t, c: calls.append("touch"),
+    ),
+    entry_actions=OrderStateMachineEntryActions(notify_customer=lambda c: calls.append("notify")),
+    exit_actions=OrderStateMachineExitActions(log_exit=lambda c: calls.append("exit")),
+)
+assert machine.state is OrderStateMachineState.PENDING
+assert machine.permitted_events() == [OrderStateMachineEvent.APPROVE, OrderStateMachineEvent.REJECT]
+assert not machine.can_transition("approve")
+try:
+    machine.transition("approve")
+    raise SystemExit("guard did not reject")
+except OrderStateMachineTransitionError as e:
+    assert str(e) == "guard condition failed for transition from pending on approve", e
+    assert e.from_state is OrderStateMachineState.PENDING
+
+machine.context.amount = 100
+machine.transition(OrderStateMachineEvent.APPROVE)
+machine.transition("ship")
+assert machine.state is OrderStateMachineState.SHIPPED
+assert calls == ["exit", "charge pending->approved", "touch", "notify", "touch"], calls
+
+try:
+    machine.transition("ship")
+    raise SystemExit("final state accepted an event")
+except OrderStateMachineTransitionError as e:
+    assert str(e) == "no transitions defined from state shipped", e
+
+machine.restore_state("approved")
+try:
+    machine.transition("approve")
+    raise SystemExit("invalid event accepted")
+except OrderStateMachineTransitionError as e:
+    assert str(e) == "invalid event approve for state approved", e
+print("ok")
+`
+	cmd := exec.Command(python, "-c", script)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Equal(t, "ok\n", string(out))
+}
@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// User region markers delimit code that users write into a generated file and
+// that regeneration keeps. Each is followed by the name of the region.
+const (
+	userRegionBegin = "//gofsm-gen:user-begin "
+	userRegionEnd   = "//gofsm-gen:user-end "
+)
+
+// userRegion is a user code region of a file
+type userRegion struct {
+	name string
+
+	// start and end locate the code between the marker lines
+	start, end int
+}
+
+// userRegions returns the user code regions of src in order. Regions cannot
+// nest, and every region must end, with a marker of the same name.
+func userRegions(src []byte) ([]userRegion, error) {
+	var scanner regionScanner
+	for offset := 0; offset < len(src); {
+		next := bytes.IndexByte(src[offset:], '\n') + 1
+		if next == 0 {
+			next = len(src) - offset
+		}
+		if err := scanner.scan(string(src[offset:offset+next]), offset); err != nil {
+			return nil, err
+		}
+		offset += next
+	}
+	return scanner.finish()
+}
+
+// regionScanner finds the user code regions of a file line by line, so that
+// they can be found in output that is not held in memory whole
+type regionScanner struct {
+	regions []userRegion
+	open    *userRegion
+	line    int
+}
+
+// scan checks the line starting at offset, which includes its newline, for a
+// region marker
+func (s *regionScanner) scan(line string, offset int) error {
+	text := strings.TrimSpace(line)
+	s.line++
+
+	switch {
+	case strings.HasPrefix(text, userRegionBegin):
+		name := strings.TrimSpace(strings.TrimPrefix(text, userRegionBegin))
+		if s.open != nil {
+			return fmt.Errorf("line %d: user region %q starts inside user region %q", s.line, name, s.open.name)
+		}
+		for _, r := range s.regions {
+			if r.name == name {
+				return fmt.Errorf("line %d: user region %q appears twice", s.line, name)
+			}
+		}
+		s.open = &userRegion{name: name, start: offset + len(line)}
+	case strings.HasPrefix(text, userRegionEnd):
+		name := strings.TrimSpace(strings.TrimPrefix(text, userRegionEnd))
+		if s.open == nil || s.open.name != name {
+			return fmt.Errorf("line %d: user region %q ends without starting", s.line, name)
+		}
+		s.open.end = offset
+		s.regions = append(s.regions, *s.open)
+		s.open = nil
+	}
+	return nil
+}
+
+// inside reports whether the lines scanned next are the code of a region
+func (s *regionScanner) inside() bool {
+	return s.open != nil
+}
+
+// finish returns the regions found, failing for a region that does not end
+func (s *regionScanner) finish() ([]userRegion, error) {
+	if s.open != nil {
+		return nil, fmt.Errorf("user region %q does not end", s.open.name)
+	}
+	return s.regions, nil
+}
+
+// PreserveUserRegions returns generated with the code of the user regions of
+// existing, the file it replaces, put back into the regions of the same name.
+// It fails rather than drop code from a region the generated file no longer has.
+func PreserveUserRegions(generated, existing []byte) ([]byte, error) {
+	kept, err := userRegions(existing)
+	if err != nil {
+		return nil, fmt.Errorf("existing file: %w", err)
+	}
+	if len(kept) == 0 {
+		return generated, nil
+	}
+	regions, err := userRegions(generated)
+	if err != nil {
+		return nil, err
+	}
+
+	code := make(map[string][]byte, len(kept))
+	for _, r := range kept {
+		code[r.name] = existing[r.start:r.end]
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, r := range regions {
+		out.Write(generated[last:r.start])
+		if c, ok := code[r.name]; ok {
+			out.Write(c)
+			delete(code, r.name)
+		} else {
+			out.Write(generated[r.start:r.end])
+		}
+		last = r.end
+	}
+	out.Write(generated[last:])
+
+	for _, r := range kept {
+		if c, ok := code[r.name]; ok && len(bytes.TrimSpace(c)) > 0 {
+			return nil, fmt.Errorf("user region %q is no longer generated; move its code elsewhere first", r.name)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// withoutUserCode returns src with its user regions emptied, which is how they
+// were generated, or src itself when its regions are malformed
+func withoutUserCode(src []byte) []byte {
+	regions, err := userRegions(src)
+	if err != nil || len(regions) == 0 {
+		return src
+	}
+	var out bytes.Buffer
+	last := 0
+	for _, r := range regions {
+		out.Write(src[last:r.start])
+		last = r.end
+	}
+	out.Write(src[last:])
+	return out.Bytes()
+}
@@ -0,0 +1,69 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const regionsFile = `package orders
+
+//gofsm-gen:user-begin imports
+//gofsm-gen:user-end imports
+
+const Answer = 42
+
+//gofsm-gen:user-begin helpers
+//gofsm-gen:user-end helpers
+`
+
+func TestPreserveUserRegions(t *testing.T) {
+	existing := strings.Replace(regionsFile, "//gofsm-gen:user-begin helpers\n", "//gofsm-gen:user-begin helpers\nfunc Double() int { return 2 * Answer }\n", 1)
+	generated := strings.Replace(regionsFile, "42", "43", 1)
+
+	preserved, err := PreserveUserRegions([]byte(generated), []byte(existing))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Replace(existing, "42", "43", 1), string(preserved))
+
+	unchanged, err := PreserveUserRegions([]byte(generated), []byte("package orders\n"))
+	require.NoError(t, err)
+	assert.Equal(t, generated, string(unchanged), "files without regions keep nothing")
+
+	_, err = PreserveUserRegions([]byte("package orders\n"), []byte(existing))
+	assert.EqualError(t, err, `user region "helpers" is no longer generated; move its code elsewhere first`)
+
+	dropped, err := PreserveUserRegions([]byte("package orders\n"), []byte(regionsFile))
+	require.NoError(t, err)
+	assert.Equal(t, "package orders\n", string(dropped), "empty regions may go away")
+}
+
+func TestUserRegions_Malformed(t *testing.T) {
+	for src, want := range map[string]string{
+		"//gofsm-gen:user-begin a\n":                                                   `user region "a" does not end`,
+		"//gofsm-gen:user-end a\n":                                                     `line 1: user region "a" ends without starting`,
+		"//gofsm-gen:user-begin a\n//gofsm-gen:user-begin b\n":                         `line 2: user region "b" starts inside user region "a"`,
+		"//gofsm-gen:user-begin a\n//gofsm-gen:user-end a\n//gofsm-gen:user-begin a\n": `line 3: user region "a" appears twice`,
+	} {
+		_, err := userRegions([]byte(src))
+		assert.EqualError(t, err, want, src)
+
+		_, err = PreserveUserRegions([]byte(regionsFile), []byte(src))
+		assert.ErrorContains(t, err, "existing file: "+want)
+	}
+}
+
+func TestReadChecksums_IgnoresUserCode(t *testing.T) {
+	sealed := string(seal([]byte(GeneratedMarker+"\n"+regionsFile), "specsum"))
+	edited := strings.Replace(sealed, "//gofsm-gen:user-begin imports\n", "//gofsm-gen:user-begin imports\nimport \"strings\"\n", 1)
+
+	_, intact, err := ReadChecksums([]byte(edited))
+	require.NoError(t, err)
+	assert.True(t, intact, "code in user regions is not a hand edit")
+
+	edited = strings.Replace(edited, "42", "43", 1)
+	_, intact, err = ReadChecksums([]byte(edited))
+	require.NoError(t, err)
+	assert.False(t, intact)
+}
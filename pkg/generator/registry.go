@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// RegistryOutputName is the file name of the generated registry package
+const RegistryOutputName = "fsm_registry.gen.go"
+
+// RegistryMachine is a machine the registry creates by name: its model and the
+// import path of the package it is generated into
+type RegistryMachine struct {
+	ImportPath string
+	FSM        *model.FSMModel
+}
+
+// registryImport is an import of the registry package
+type registryImport struct {
+	Alias string
+	Path  string
+}
+
+// registryMachine is a machine of the registry with the alias of its package
+type registryMachine struct {
+	*model.FSMModel
+	Alias string
+}
+
+// registryData is the template data of registry.tmpl
+type registryData struct {
+	Package  string
+	Imports  []registryImport
+	Machines []registryMachine
+}
+
+// GenerateRegistry generates the package pkg, which creates the given machines by
+// name behind a Machine interface of state and event names, so that frameworks
+// such as workflow engines can instantiate them dynamically. Machine names must be
+// unique across packages.
+func (g *CodeGenerator) GenerateRegistry(pkg string, machines []RegistryMachine) ([]byte, error) {
+	data := registryData{Package: pkg}
+
+	// Packages are imported under their names, numbered when names repeat or
+	// shadow the registry's own imports
+	aliases := make(map[string]string)
+	taken := map[string]bool{"context": true, "fmt": true, "sort": true, "sync": true}
+	byName := make(map[string]string)
+	for _, m := range machines {
+		if m.ImportPath == "" {
+			return nil, fmt.Errorf("machine %s has no import path", m.FSM.Name)
+		}
+		if other, ok := byName[m.FSM.Name]; ok && other != m.ImportPath {
+			return nil, fmt.Errorf("machine %s is generated into both %s and %s", m.FSM.Name, other, m.ImportPath)
+		}
+		byName[m.FSM.Name] = m.ImportPath
+		fsm, err := prepare(m.FSM)
+		if err != nil {
+			return nil, err
+		}
+
+		alias, ok := aliases[m.ImportPath]
+		if !ok {
+			alias = fsm.Package
+			for n := 2; taken[alias]; n++ {
+				alias = fmt.Sprintf("%s%d", fsm.Package, n)
+			}
+			taken[alias] = true
+			aliases[m.ImportPath] = alias
+			data.Imports = append(data.Imports, registryImport{Alias: alias, Path: m.ImportPath})
+		}
+		data.Machines = append(data.Machines, registryMachine{FSMModel: fsm, Alias: alias})
+	}
+	sort.Slice(data.Imports, func(i, j int) bool { return data.Imports[i].Path < data.Imports[j].Path })
+	sort.Slice(data.Machines, func(i, j int) bool { return data.Machines[i].Name < data.Machines[j].Name })
+
+	var buf bytes.Buffer
+	if err := g.executeTemplate(g.templates, &buf, "registry.tmpl", data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,115 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// registryModel returns a machine with a start and an end state and a finish event
+func registryModel(t *testing.T, name, pkg string) *model.FSMModel {
+	t.Helper()
+	fsm, err := model.NewFSMModel(name, "start")
+	require.NoError(t, err)
+	fsm.Package = pkg
+	for _, s := range []string{"start", "end"} {
+		state, err := model.NewState(s)
+		require.NoError(t, err)
+		require.NoError(t, fsm.AddState(state))
+	}
+	finish, err := model.NewEvent("finish")
+	require.NoError(t, err)
+	require.NoError(t, fsm.AddEvent(finish))
+	transition, err := model.NewTransition("start", "end", "finish")
+	require.NoError(t, err)
+	transition.Guard = "ready"
+	require.NoError(t, fsm.AddTransition(transition))
+	require.NoError(t, fsm.Validate())
+	return fsm
+}
+
+func TestGenerateRegistry(t *testing.T) {
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	order := registryModel(t, "Order", "orders")
+	shipment := registryModel(t, "Shipment", "fmt")
+	legacy := registryModel(t, "LegacyOrder", "orders")
+	registry, err := gen.GenerateRegistry("fsmregistry", []RegistryMachine{
+		{ImportPath: "generated/orders", FSM: order},
+		{ImportPath: "generated/shipping/fmt", FSM: shipment},
+		{ImportPath: "generated/legacy/orders", FSM: legacy},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, string(registry), "\torders \"generated/orders\"\n")
+	assert.Contains(t, string(registry), "\torders2 \"generated/legacy/orders\"\n")
+	assert.Contains(t, string(registry), "\tfmt2 \"generated/shipping/fmt\"\n", "packages do not shadow the registry's imports")
+
+	files := map[string][]byte{"fsmregistry/" + RegistryOutputName: registry}
+	for dir, fsm := range map[string]*model.FSMModel{"orders": order, "shipping/fmt": shipment, "legacy/orders": legacy} {
+		code, err := gen.Generate(fsm)
+		require.NoError(t, err)
+		files[dir+"/"+DefaultOutputName(fsm)] = code
+	}
+	files["fsmregistry/registry_test.go"] = []byte(`package fsmregistry
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"generated/orders"
+)
+
+func TestRegistry(t *testing.T) {
+	if got := Names(); !reflect.DeepEqual(got, []string{"LegacyOrder", "Order", "Shipment"}) {
+		t.Fatalf("Names() = %v", got)
+	}
+	if Lookup("Invoice") != nil {
+		t.Fatal("Lookup found an unknown machine")
+	}
+	if _, err := New("Invoice"); err == nil || err.Error() != "no machine is registered as \"Invoice\"" {
+		t.Fatalf("New(Invoice) = %v", err)
+	}
+
+	m, err := New("Order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.MachineName() != "Order" || m.State() != "start" || !reflect.DeepEqual(m.PermittedEvents(), []string{"finish"}) {
+		t.Fatalf("new machine: %s in %s permitting %v", m.MachineName(), m.State(), m.PermittedEvents())
+	}
+	if err := m.Fire(context.Background(), "launch"); err == nil || err.Error() != "machine Order has no event \"launch\"" {
+		t.Fatalf("Fire(launch) = %v", err)
+	}
+	if err := m.Fire(context.Background(), "finish"); err != nil || m.State() != "end" {
+		t.Fatalf("Fire(finish) = %v in %s", err, m.State())
+	}
+	if err := m.RestoreState("start"); err != nil || m.State() != "start" {
+		t.Fatalf("RestoreState(start) = %v in %s", err, m.State())
+	}
+	if _, ok := m.Unwrap().(*orders.Order); !ok {
+		t.Fatalf("Unwrap() = %T", m.Unwrap())
+	}
+
+	// Registered factories create machines with guards
+	Register("Order", func() Machine {
+		return WrapOrder(orders.NewOrder(orders.OrderGuards{Ready: func(context.Context, *orders.OrderContext) bool { return false }}, orders.OrderActions{}))
+	})
+	if err := Lookup("Order")().Fire(context.Background(), "finish"); err == nil {
+		t.Fatal("the guard of the registered factory did not run")
+	}
+}
+`)
+	runGeneratedPackage(t, files)
+
+	_, err = gen.GenerateRegistry("fsmregistry", []RegistryMachine{
+		{ImportPath: "generated/orders", FSM: order},
+		{ImportPath: "generated/legacy/orders", FSM: order},
+	})
+	assert.EqualError(t, err, "machine Order is generated into both generated/orders and generated/legacy/orders")
+	_, err = gen.GenerateRegistry("fsmregistry", []RegistryMachine{{FSM: order}})
+	assert.EqualError(t, err, "machine Order has no import path")
+}
@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// splitSections lists the files written in split mode and the template section each renders
+var splitSections = []struct {
+	suffix  string
+	section string
+}{
+	{suffix: "_states.go", section: "states"},
+	{suffix: "_events.go", section: "events"},
+	{suffix: "_callbacks.go", section: "callbacks"},
+	{suffix: "_machine.go", section: "machine"},
+}
+
+// GenerateSplit generates the machine as one file per section: the state enum, the
+// event enum, the callback types, and the machine itself. The returned paths are file
+// names relative to the output directory, prefixed with the snake_case machine name.
+func (g *CodeGenerator) GenerateSplit(model *model.FSMModel) ([]PlannedFile, error) {
+	model, err := prepare(model)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIdentifiers(model); err != nil {
+		return nil, err
+	}
+	tmpl, err := g.templatesFor(model)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]PlannedFile, 0, len(splitSections))
+	for _, s := range splitSections {
+		var buf bytes.Buffer
+		for i, name := range []string{"header", "imports", s.section} {
+			if i > 0 {
+				buf.WriteString("\n\n")
+			}
+			if err := g.executeTemplate(tmpl, &buf, name, model); err != nil {
+				return nil, fmt.Errorf("failed to execute template: %w", err)
+			}
+		}
+		buf.WriteString("\n")
+
+		src, err := pruneImports(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("%s section: %w", s.section, err)
+		}
+		files = append(files, PlannedFile{Path: snakeCase(model.Name) + s.suffix, Content: seal(src, model.Source.Checksum)})
+	}
+	return files, nil
+}
+
+// SplitOutputNames returns the file names GenerateSplit produces for a model
+func SplitOutputNames(model *model.FSMModel) []string {
+	names := make([]string, 0, len(splitSections))
+	for _, s := range splitSections {
+		names = append(names, snakeCase(model.Name)+s.suffix)
+	}
+	return names
+}
+
+// SplitTestOutputName returns the test file name used for a model in split mode
+func SplitTestOutputName(model *model.FSMModel) string {
+	return snakeCase(model.Name) + "_test.go"
+}
+
+// pruneImports removes the imports that src does not reference and formats the result
+func pruneImports(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated code: %w", err)
+	}
+
+	used := make(map[string]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	decls := file.Decls[:0]
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+
+		specs := gen.Specs[:0]
+		for _, spec := range gen.Specs {
+			if used[importName(spec.(*ast.ImportSpec))] {
+				specs = append(specs, spec)
+			}
+		}
+		gen.Specs = specs
+		if len(specs) > 0 {
+			decls = append(decls, gen)
+		}
+	}
+	file.Decls = decls
+
+	imports := file.Imports[:0]
+	for _, imp := range file.Imports {
+		if used[importName(imp)] {
+			imports = append(imports, imp)
+		}
+	}
+	file.Imports = imports
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// importName returns the name an import is referred to by in the importing file
+func importName(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+	p, err := strconv.Unquote(imp.Path.Value)
+	if err != nil {
+		return ""
+	}
+	return path.Base(p)
+}
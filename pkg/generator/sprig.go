@@ -0,0 +1,361 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WithSprigFuncs adds SprigFuncs to the template functions, for custom templates
+// written against Sprig. Functions added with WithTemplateFuncs still take
+// precedence.
+func WithSprigFuncs() GeneratorOption {
+	return func(g *CodeGenerator) {
+		g.sprig = true
+	}
+}
+
+// SprigFuncs returns a curated subset of the Sprig template functions
+// (github.com/Masterminds/sprig), with the same names and argument order: the
+// piped value comes last. Sprig functions whose names the built-in functions
+// take, such as title, lower, upper, and quote, are left out, so that enabling
+// the set does not change the output of existing templates.
+func SprigFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		// Strings
+		"trim":       strings.TrimSpace,
+		"trimAll":    func(cutset, s string) string { return strings.Trim(s, cutset) },
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"replace":    func(old, replacement, s string) string { return strings.ReplaceAll(s, old, replacement) },
+		"repeat":     func(count int, s string) string { return strings.Repeat(s, count) },
+		"nospace":    func(s string) string { return strings.Join(strings.Fields(s), "") },
+		"trunc":      sprigTrunc,
+		"abbrev":     sprigAbbrev,
+		"indent":     sprigIndent,
+		"nindent":    func(spaces int, s string) string { return "\n" + sprigIndent(spaces, s) },
+		"squote":     func(v interface{}) string { return "'" + sprigString(v) + "'" },
+		"cat":        sprigCat,
+		"splitList":  func(sep, s string) []string { return strings.Split(s, sep) },
+		"join":       sprigJoin,
+		"toString":   sprigString,
+		"plural":     sprigPlural,
+		"kebabcase":  func(s string) string { return strings.ReplaceAll(snakeCase(s), "_", "-") },
+
+		// Lists
+		"list":      func(items ...interface{}) []interface{} { return items },
+		"first":     sprigFirst,
+		"last":      sprigLast,
+		"rest":      sprigRest,
+		"initial":   sprigInitial,
+		"has":       sprigHas,
+		"uniq":      sprigUniq,
+		"reverse":   sprigReverse,
+		"append":    func(list interface{}, v interface{}) []interface{} { return append(sprigList(list), v) },
+		"concat":    sprigConcat,
+		"sortAlpha": sprigSortAlpha,
+
+		// Dictionaries
+		"dict":   sprigDict,
+		"get":    func(d map[string]interface{}, key string) interface{} { return d[key] },
+		"hasKey": func(d map[string]interface{}, key string) bool { _, ok := d[key]; return ok },
+		"keys":   sprigKeys,
+
+		// Defaults and flow
+		"default":  sprigDefault,
+		"empty":    sprigEmpty,
+		"coalesce": sprigCoalesce,
+		"ternary":  sprigTernary,
+		"toJson":   sprigToJSON,
+		"fail":     func(msg string) (string, error) { return "", errors.New(msg) },
+
+		// Integer math
+		"add":  func(a, b interface{}) int64 { return sprigInt(a) + sprigInt(b) },
+		"add1": func(a interface{}) int64 { return sprigInt(a) + 1 },
+		"sub":  func(a, b interface{}) int64 { return sprigInt(a) - sprigInt(b) },
+		"mul":  func(a, b interface{}) int64 { return sprigInt(a) * sprigInt(b) },
+		"div":  func(a, b interface{}) int64 { return sprigInt(a) / sprigInt(b) },
+		"mod":  func(a, b interface{}) int64 { return sprigInt(a) % sprigInt(b) },
+		"max":  sprigMax,
+		"min":  sprigMin,
+	}
+}
+
+// sprigString converts v to a string as Sprig does: strings and byte slices as
+// they are, nil as the empty string, and anything else with fmt
+func sprigString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sprigInt converts a number, or a string holding one, to an int64; anything
+// else is 0
+func sprigInt(v interface{}) int64 {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return int64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return int64(rv.Float())
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1
+		}
+	case reflect.String:
+		n, _ := strconv.ParseInt(rv.String(), 0, 64)
+		return n
+	}
+	return 0
+}
+
+// sprigList returns the elements of a slice or array as a list; anything else
+// is an empty list
+func sprigList(v interface{}) []interface{} {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	list := make([]interface{}, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list
+}
+
+func sprigTrunc(c int, s string) string {
+	runes := []rune(s)
+	switch {
+	case c < 0 && len(runes)+c > 0:
+		return string(runes[len(runes)+c:])
+	case c >= 0 && len(runes) > c:
+		return string(runes[:c])
+	}
+	return s
+}
+
+func sprigAbbrev(width int, s string) string {
+	if width < 4 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width-3]) + "..."
+}
+
+func sprigIndent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func sprigCat(v ...interface{}) string {
+	parts := make([]string, 0, len(v))
+	for _, s := range v {
+		if s != nil {
+			parts = append(parts, sprigString(s))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func sprigJoin(sep string, v interface{}) string {
+	if s, ok := v.([]string); ok {
+		return strings.Join(s, sep)
+	}
+	list := sprigList(v)
+	parts := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != nil {
+			parts = append(parts, sprigString(item))
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+func sprigPlural(one, many string, count int) string {
+	if count == 1 {
+		return one
+	}
+	return many
+}
+
+func sprigFirst(list interface{}) interface{} {
+	if l := sprigList(list); len(l) > 0 {
+		return l[0]
+	}
+	return nil
+}
+
+func sprigLast(list interface{}) interface{} {
+	if l := sprigList(list); len(l) > 0 {
+		return l[len(l)-1]
+	}
+	return nil
+}
+
+func sprigRest(list interface{}) []interface{} {
+	if l := sprigList(list); len(l) > 0 {
+		return l[1:]
+	}
+	return nil
+}
+
+func sprigInitial(list interface{}) []interface{} {
+	if l := sprigList(list); len(l) > 0 {
+		return l[:len(l)-1]
+	}
+	return nil
+}
+
+func sprigHas(needle interface{}, haystack interface{}) bool {
+	for _, item := range sprigList(haystack) {
+		if reflect.DeepEqual(item, needle) {
+			return true
+		}
+	}
+	return false
+}
+
+func sprigUniq(list interface{}) []interface{} {
+	var unique []interface{}
+	for _, item := range sprigList(list) {
+		if !sprigHas(item, unique) {
+			unique = append(unique, item)
+		}
+	}
+	return unique
+}
+
+func sprigReverse(list interface{}) []interface{} {
+	l := sprigList(list)
+	reversed := make([]interface{}, len(l))
+	for i, item := range l {
+		reversed[len(l)-1-i] = item
+	}
+	return reversed
+}
+
+func sprigConcat(lists ...interface{}) []interface{} {
+	var all []interface{}
+	for _, list := range lists {
+		all = append(all, sprigList(list)...)
+	}
+	return all
+}
+
+func sprigSortAlpha(list interface{}) []string {
+	l := sprigList(list)
+	sorted := make([]string, len(l))
+	for i, item := range l {
+		sorted[i] = sprigString(item)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func sprigDict(v ...interface{}) map[string]interface{} {
+	d := make(map[string]interface{}, len(v)/2)
+	for i := 0; i < len(v); i += 2 {
+		var value interface{}
+		if i+1 < len(v) {
+			value = v[i+1]
+		}
+		d[sprigString(v[i])] = value
+	}
+	return d
+}
+
+func sprigKeys(dicts ...map[string]interface{}) []string {
+	var keys []string
+	for _, d := range dicts {
+		for key := range d {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// sprigEmpty reports whether v is nil or the zero value of its type, or an empty
+// slice, map, or string
+func sprigEmpty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return true
+	}
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
+
+func sprigDefault(d interface{}, given ...interface{}) interface{} {
+	if len(given) == 0 || sprigEmpty(given[0]) {
+		return d
+	}
+	return given[0]
+}
+
+func sprigCoalesce(v ...interface{}) interface{} {
+	for _, item := range v {
+		if !sprigEmpty(item) {
+			return item
+		}
+	}
+	return nil
+}
+
+func sprigTernary(vt, vf interface{}, v bool) interface{} {
+	if v {
+		return vt
+	}
+	return vf
+}
+
+func sprigToJSON(v interface{}) string {
+	out, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+func sprigMax(a interface{}, v ...interface{}) int64 {
+	largest := sprigInt(a)
+	for _, b := range v {
+		if n := sprigInt(b); n > largest {
+			largest = n
+		}
+	}
+	return largest
+}
+
+func sprigMin(a interface{}, v ...interface{}) int64 {
+	smallest := sprigInt(a)
+	for _, b := range v {
+		if n := sprigInt(b); n < smallest {
+			smallest = n
+		}
+	}
+	return smallest
+}
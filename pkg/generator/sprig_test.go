@@ -0,0 +1,64 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSprigFuncs_KeepBuiltins(t *testing.T) {
+	builtins := TemplateFuncs()
+	for name := range SprigFuncs() {
+		_, clash := builtins[name]
+		assert.False(t, clash, "sprig function %s replaces a built-in function", name)
+	}
+}
+
+func TestSprigFuncs(t *testing.T) {
+	dir := t.TempDir()
+	tmpl := `{{.Name | trimSuffix "Machine" | kebabcase}}
+{{list "b" "a" "b" | uniq | sortAlpha | join ", "}}
+{{$states := sortAlpha .GetStateNames}}{{first $states}}..{{last $states}}
+{{"  padded  " | trim | squote}} {{"abcdef" | trunc 3}} {{"abcdefgh" | abbrev 6}}
+{{default "none" .Description}} {{empty .Description}} {{ternary "yes" "no" (has "pending" .GetStateNames)}}
+{{$d := dict "a" 1 "b" 2}}{{get $d "b"}} {{hasKey $d "c"}}
+{{add 1 2}} {{sub 5 7}} {{mul 3 "4"}} {{max 1 9 4}} {{plural "state" "states" (len .GetStateNames)}}
+{{"line one\nline two" | nindent 2}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "state_machine.tmpl"), []byte(tmpl), 0o600))
+
+	_, err := NewCodeGeneratorWithTemplateDir(dir)
+	require.ErrorContains(t, err, "not defined", "sprig functions are opt-in")
+
+	gen, err := NewCodeGeneratorWithTemplateDir(dir, WithSprigFuncs())
+	require.NoError(t, err)
+
+	code, err := gen.Generate(createOrderStateMachine(t))
+	require.NoError(t, err)
+	assert.Equal(t, `order-state
+a, b
+approved..shipped
+'padded' abc abc...
+none true yes
+2 false
+3 -2 12 9 states
+
+  line one
+  line two`, string(code))
+}
+
+func TestWithSprigFuncs_CustomFuncsTakePrecedence(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "state_machine.tmpl"), []byte(`{{trim "x"}}`), 0o600))
+
+	gen, err := NewCodeGeneratorWithTemplateDir(dir,
+		WithTemplateFuncs(map[string]any{"trim": func(s string) string { return "custom " + s }}),
+		WithSprigFuncs(),
+	)
+	require.NoError(t, err)
+	code, err := gen.Generate(createOrderStateMachine(t))
+	require.NoError(t, err)
+	assert.Equal(t, "custom x", string(code))
+}
@@ -0,0 +1,120 @@
+package generator
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// DefaultSQLColumn is the state column constrained by the SQL export by default
+const DefaultSQLColumn = "state"
+
+// SQLOptions controls the SQL export
+type SQLOptions struct {
+	// Table is the table storing the state, optionally schema-qualified; empty
+	// means the snake_case machine name
+	Table string
+
+	// Column is the column holding the state name; empty means DefaultSQLColumn
+	Column string
+}
+
+// sqlIdentifierPattern matches the unquoted identifiers the SQL export accepts, so
+// that table and column names need no quoting
+var sqlIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqlData is the template data of sql.tmpl
+type sqlData struct {
+	*model.FSMModel
+	Table  string
+	Column string
+}
+
+// sqlTransition is a pair of states the state column may change between, with
+// the events that change it
+type sqlTransition struct {
+	From   string
+	To     string
+	Events []string
+
+	// Last marks the final pair of the list
+	Last bool
+}
+
+// EventList returns the events of the state change, comma-separated
+func (t sqlTransition) EventList() string {
+	return strings.Join(t.Events, ", ")
+}
+
+// GenerateSQL generates PostgreSQL statements that enforce the states and
+// transitions of the given FSM model on a table column: a CHECK constraint
+// accepting only state names and a trigger rejecting updates that no transition
+// permits
+func (g *CodeGenerator) GenerateSQL(m *model.FSMModel, opts SQLOptions) ([]byte, error) {
+	data := sqlData{FSMModel: m, Table: opts.Table, Column: opts.Column}
+	if data.Table == "" {
+		data.Table = snakeCase(m.Name)
+	}
+	if data.Column == "" {
+		data.Column = DefaultSQLColumn
+	}
+
+	for _, part := range strings.Split(data.Table, ".") {
+		if !sqlIdentifierPattern.MatchString(part) {
+			return nil, fmt.Errorf("table %q is not a plain or schema-qualified SQL identifier", data.Table)
+		}
+	}
+	if !sqlIdentifierPattern.MatchString(data.Column) {
+		return nil, fmt.Errorf("column %q is not a plain SQL identifier", data.Column)
+	}
+	return g.executeData("sql.tmpl", m, data)
+}
+
+// SQLOutputName returns the conventional SQL file name for a model
+func SQLOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + ".sql"
+}
+
+// Prefix is the prefix of the constraint, function, and trigger names: the table,
+// without its schema, and the column
+func (d sqlData) Prefix() string {
+	table := d.Table[strings.LastIndex(d.Table, ".")+1:]
+	return table + "_" + d.Column
+}
+
+// FunctionName is the name of the trigger function, in the schema of the table
+func (d sqlData) FunctionName() string {
+	if i := strings.LastIndex(d.Table, "."); i >= 0 {
+		return d.Table[:i+1] + d.Prefix() + "_transition"
+	}
+	return d.Prefix() + "_transition"
+}
+
+// Transitions returns the distinct state changes the transitions permit, in
+// declaration order. Self-transitions are left out, since the trigger accepts
+// updates that keep the state.
+func (d sqlData) Transitions() []sqlTransition {
+	var pairs []sqlTransition
+	index := make(map[[2]string]int)
+	for _, t := range d.FSMModel.Transitions {
+		if t.IsSelfTransition() {
+			continue
+		}
+		key := [2]string{t.From, t.To}
+		i, ok := index[key]
+		if !ok {
+			i = len(pairs)
+			index[key] = i
+			pairs = append(pairs, sqlTransition{From: t.From, To: t.To})
+		}
+		if !containsString(pairs[i].Events, t.Event) {
+			pairs[i].Events = append(pairs[i].Events, t.Event)
+		}
+	}
+	if len(pairs) > 0 {
+		pairs[len(pairs)-1].Last = true
+	}
+	return pairs
+}
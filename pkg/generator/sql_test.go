@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateSQL(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	cancel, _ := model.NewTransition("approved", "shipped", "reject")
+	require.NoError(t, fsm.AddTransition(cancel))
+	retry, _ := model.NewTransition("rejected", "rejected", "reject")
+	require.NoError(t, fsm.AddTransition(retry))
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	t.Run("defaults", func(t *testing.T) {
+		sql, err := gen.GenerateSQL(fsm, SQLOptions{})
+		require.NoError(t, err)
+		src := string(sql)
+
+		assert.True(t, strings.HasPrefix(src, "-- Code generated by gofsm-gen. DO NOT EDIT.\n"))
+		assert.Equal(t, "order_state_machine.sql", SQLOutputName(fsm))
+		assert.Contains(t, src, `ALTER TABLE order_state_machine
+  DROP CONSTRAINT IF EXISTS order_state_machine_state_valid,
+  ADD CONSTRAINT order_state_machine_state_valid CHECK (state IN ('approved', 'pending', 'rejected', 'shipped'));`)
+		assert.Contains(t, src, `  IF (OLD.state, NEW.state) IN (
+    ('pending', 'approved'), -- approve
+    ('pending', 'rejected'), -- reject
+    ('approved', 'shipped') -- ship, reject
+  ) THEN`, "State changes should be listed once, without self-transitions")
+		assert.Contains(t, src, "CREATE OR REPLACE FUNCTION order_state_machine_state_transition() RETURNS trigger AS $$")
+		assert.Contains(t, src, `CREATE TRIGGER order_state_machine_state_transition
+  BEFORE UPDATE OF state ON order_state_machine
+  FOR EACH ROW EXECUTE FUNCTION order_state_machine_state_transition();`)
+	})
+
+	t.Run("schema-qualified table", func(t *testing.T) {
+		sql, err := gen.GenerateSQL(fsm, SQLOptions{Table: "shop.orders", Column: "status"})
+		require.NoError(t, err)
+		src := string(sql)
+
+		assert.Contains(t, src, "ADD CONSTRAINT orders_status_valid CHECK (status IN (")
+		assert.Contains(t, src, "CREATE OR REPLACE FUNCTION shop.orders_status_transition() RETURNS trigger AS $$")
+		assert.Contains(t, src, "  IF NEW.status IS NOT DISTINCT FROM OLD.status THEN")
+		assert.Contains(t, src, "DROP TRIGGER IF EXISTS orders_status_transition ON shop.orders;")
+	})
+
+	t.Run("invalid identifiers", func(t *testing.T) {
+		_, err := gen.GenerateSQL(fsm, SQLOptions{Table: "orders; DROP TABLE users"})
+		assert.ErrorContains(t, err, "is not a plain or schema-qualified SQL identifier")
+		_, err = gen.GenerateSQL(fsm, SQLOptions{Column: "state-name"})
+		assert.ErrorContains(t, err, `column "state-name" is not a plain SQL identifier`)
+	})
+}
@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strconv"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// CheckValueStability reports an error if generated would change the numeric value of
+// a state or event constant of m that existing declares, or would give the value of a
+// constant it no longer declares to another constant. Both are sources of the same
+// generated file; sources that fail to parse declare no constants.
+func CheckValueStability(m *model.FSMModel, existing, generated []byte) error {
+	for _, typeName := range []string{m.Name + "State", m.Name + "Event"} {
+		before := enumConstants(existing, typeName)
+		after := enumConstants(generated, typeName)
+
+		owners := make(map[int64]string, len(after))
+		for name, value := range after {
+			owners[value] = name
+		}
+
+		names := make([]string, 0, len(before))
+		for name := range before {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			old := before[name]
+			value, kept := after[name]
+			switch {
+			case kept && value != old:
+				return fmt.Errorf("%s would change value from %d to %d; pin it with `value: %d` to keep persisted values valid", name, old, value, old)
+			case !kept && owners[old] != "":
+				return fmt.Errorf("%s would reuse value %d of the removed constant %s; pin it to an unused value", owners[old], old, name)
+			}
+		}
+	}
+	return nil
+}
+
+// enumConstants returns the integer constants of type typeName declared by src
+func enumConstants(src []byte, typeName string) map[string]int64 {
+	constants := make(map[string]int64)
+
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return constants
+	}
+
+	for _, decl := range file.Decls {
+		d, ok := decl.(*ast.GenDecl)
+		if !ok || d.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range d.Specs {
+			s := spec.(*ast.ValueSpec)
+			if ident, ok := s.Type.(*ast.Ident); !ok || ident.Name != typeName || len(s.Names) != len(s.Values) {
+				continue
+			}
+			for i, name := range s.Names {
+				lit, ok := s.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.INT {
+					continue
+				}
+				if value, err := strconv.ParseInt(lit.Value, 0, 64); err == nil {
+					constants[name.Name] = value
+				}
+			}
+		}
+	}
+	return constants
+}
@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCheckValueStability(t *testing.T) {
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	fsm := createOrderStateMachine(t)
+	existing, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	unchanged, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	assert.NoError(t, CheckValueStability(fsm, existing, unchanged))
+
+	// "cancelled" sorts between "approved" and "rejected" and would shift the automatic values
+	cancelled, err := gen.Generate(withState(t, createOrderStateMachine(t), "cancelled", nil))
+	require.NoError(t, err)
+	err = CheckValueStability(fsm, existing, cancelled)
+	assert.ErrorContains(t, err, "OrderStateMachineStateRejected would change value from 2 to 3; pin it with `value: 2`")
+
+	// Pinning every existing value keeps them stable
+	pinned := createOrderStateMachine(t)
+	for _, state := range pinned.GetStatesSlice() {
+		if state.Name != pinned.Initial {
+			v := pinned.StateValue(state.Name)
+			state.Value = &v
+		}
+	}
+	code, err := gen.Generate(withState(t, pinned, "cancelled", nil))
+	require.NoError(t, err)
+	assert.NoError(t, CheckValueStability(pinned, existing, code))
+
+	// A removed value must not be handed to another constant
+	replaced := createOrderStateMachine(t)
+	delete(replaced.States, "rejected")
+	var kept []*model.Transition
+	for _, tr := range replaced.Transitions {
+		if tr.To != "rejected" {
+			kept = append(kept, tr)
+		}
+	}
+	replaced.Transitions = kept
+	two := 2
+	code, err = gen.Generate(withState(t, replaced, "returned", &two))
+	require.NoError(t, err)
+	err = CheckValueStability(replaced, existing, code)
+	assert.ErrorContains(t, err, "OrderStateMachineStateReturned would reuse value 2 of the removed constant OrderStateMachineStateRejected")
+}
+
+// withState adds a state with an optional pinned value to fsm
+func withState(t *testing.T, fsm *model.FSMModel, name string, value *int) *model.FSMModel {
+	t.Helper()
+	require.NoError(t, fsm.AddState(&model.State{Name: name, Value: value}))
+	return fsm
+}
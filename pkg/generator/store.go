@@ -0,0 +1,14 @@
+package generator
+
+import "github.com/yourusername/gofsm-gen/pkg/model"
+
+// GenerateStore generates the {Name}Store persistence hook shared by the gRPC
+// and HTTP servers, with the event lookup by name they use
+func (g *CodeGenerator) GenerateStore(m *model.FSMModel) ([]byte, error) {
+	return g.execute("store.tmpl", m)
+}
+
+// StoreOutputName returns the conventional store file name for a model
+func StoreOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + "_store.go"
+}
@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// streamBufferSize is the size of the buffer GenerateStream writes through
+const streamBufferSize = 64 << 10
+
+// GenerateStream writes the code Generate returns for model to w as the
+// templates produce it, so that the output of a huge machine is never held in
+// memory whole and memory use stays flat as the machine grows. Generate does not
+// reformat the template output, so neither does GenerateStream; the files of
+// GenerateSplit, whose imports are pruned and which are formatted whole, are not
+// streamed.
+//
+// The spec checksum directive at the top of the file covers the content after
+// it, so a model with a spec checksum is rendered twice: once to compute the
+// checksum and once to write. When rendering fails w may hold part of the
+// output. With WithTemplateDebug the output is buffered as by GenerateTo.
+func (g *CodeGenerator) GenerateStream(model *model.FSMModel, w io.Writer) error {
+	if g.debug != nil {
+		return g.GenerateTo(model, w)
+	}
+	model, err := prepare(model)
+	if err != nil {
+		return err
+	}
+	if err := checkIdentifiers(model); err != nil {
+		return err
+	}
+	tmpl, err := g.templatesFor(model)
+	if err != nil {
+		return err
+	}
+
+	var directive string
+	if model.Source.Checksum != "" {
+		sum := newChecksumWriter()
+		if err := tmpl.ExecuteTemplate(sum, "state_machine.tmpl", model); err != nil {
+			return fmt.Errorf("failed to execute template: %w", err)
+		}
+		if content, sealed := sum.checksum(); sealed {
+			directive = checksumLine(model.Source.Checksum, content)
+		}
+	}
+
+	out := bufio.NewWriterSize(w, streamBufferSize)
+	var dst io.Writer = out
+	if directive != "" {
+		dst = &sealWriter{w: out, directive: directive, skip: len(GeneratedMarker) + 1}
+	}
+	if err := tmpl.ExecuteTemplate(dst, "state_machine.tmpl", model); err != nil {
+		return fmt.Errorf("failed to execute template: %w", err)
+	}
+	return out.Flush()
+}
+
+// checksumWriter computes the content checksum that seal records for the output
+// written to it, line by line, without keeping the output
+type checksumWriter struct {
+	// head is the start of the output, which tells whether seal seals it
+	head []byte
+
+	// line is the unfinished line, and offset the position of its start
+	line   []byte
+	offset int
+
+	// all hashes every line, and code the lines outside the user regions,
+	// which the checksum leaves out unless the regions are malformed
+	all, code hash.Hash
+	regions   regionScanner
+	malformed bool
+}
+
+func newChecksumWriter() *checksumWriter {
+	return &checksumWriter{all: sha256.New(), code: sha256.New()}
+}
+
+// Write implements io.Writer
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if missing := len(GeneratedMarker) + 1 - len(c.head); missing > 0 {
+		c.head = append(c.head, p[:min(missing, len(p))]...)
+	}
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			c.line = append(c.line, p...)
+			break
+		}
+		c.line = append(c.line, p[:i+1]...)
+		c.endLine()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// endLine hashes the finished line
+func (c *checksumWriter) endLine() {
+	c.all.Write(c.line)
+	inside := c.regions.inside()
+	if !c.malformed && c.regions.scan(string(c.line), c.offset) != nil {
+		c.malformed = true
+	}
+	// the code of a region lies between its markers, which are kept
+	if !inside || !c.regions.inside() {
+		c.code.Write(c.line)
+	}
+	c.offset += len(c.line)
+	c.line = c.line[:0]
+}
+
+// checksum returns the content checksum of the output and whether seal would
+// seal it
+func (c *checksumWriter) checksum() (content string, sealed bool) {
+	if len(c.line) > 0 {
+		c.endLine()
+	}
+	if _, err := c.regions.finish(); err != nil {
+		c.malformed = true
+	}
+	sum := c.code
+	if c.malformed {
+		sum = c.all
+	}
+	return hex.EncodeToString(sum.Sum(nil)), bytes.Equal(c.head, []byte(GeneratedMarker+"\n"))
+}
+
+// sealWriter writes the checksum directive after the first skip bytes, the
+// generated marker line
+type sealWriter struct {
+	w         io.Writer
+	directive string
+	skip      int
+}
+
+// Write implements io.Writer
+func (s *sealWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if s.skip > 0 {
+		k := min(s.skip, len(p))
+		if _, err := s.w.Write(p[:k]); err != nil {
+			return 0, err
+		}
+		s.skip -= k
+		p = p[k:]
+		if s.skip == 0 {
+			if _, err := io.WriteString(s.w, s.directive); err != nil {
+				return k, err
+			}
+		}
+	}
+	if _, err := s.w.Write(p); err != nil {
+		return n - len(p), err
+	}
+	return n, nil
+}
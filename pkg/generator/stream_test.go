@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+func TestCodeGenerator_GenerateStream(t *testing.T) {
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	specs, err := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	require.NoError(t, err)
+	require.NotEmpty(t, specs)
+	for _, spec := range specs {
+		t.Run(filepath.Base(spec), func(t *testing.T) {
+			fsm, err := parser.NewYAMLParser().ParseFile(spec)
+			require.NoError(t, err)
+			require.NotEmpty(t, fsm.Source.Checksum)
+			want, err := gen.Generate(fsm)
+			require.NoError(t, err)
+
+			var got bytes.Buffer
+			require.NoError(t, gen.GenerateStream(fsm, &got))
+			assert.Equal(t, string(want), got.String(), "streamed code must match the sealed code of Generate")
+
+			fsm.Source.Checksum = ""
+			want, err = gen.Generate(fsm)
+			require.NoError(t, err)
+			got.Reset()
+			require.NoError(t, gen.GenerateStream(fsm, &got))
+			assert.Equal(t, string(want), got.String())
+		})
+	}
+
+	assert.ErrorContains(t, gen.GenerateStream(nil, io.Discard), "model cannot be nil")
+}
+
+// writeRecorder records the sizes of the writes it receives
+type writeRecorder struct {
+	writes, largest, total int
+}
+
+func (w *writeRecorder) Write(p []byte) (int, error) {
+	w.writes++
+	w.largest = max(w.largest, len(p))
+	w.total += len(p)
+	return len(p), nil
+}
+
+func TestCodeGenerator_GenerateStream_Chunked(t *testing.T) {
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	fsm := newLargeModel(t, 1000)
+	fsm.Source.Checksum = "specsum"
+
+	var w writeRecorder
+	require.NoError(t, gen.GenerateStream(fsm, &w))
+	assert.Greater(t, w.total, 4*streamBufferSize, "the machine must be large enough to stream")
+	assert.Greater(t, w.writes, 1, "the output is written as it is rendered")
+	assert.LessOrEqual(t, w.largest, streamBufferSize, "the output is never buffered whole")
+}
+
+func TestChecksumWriter(t *testing.T) {
+	header := GeneratedMarker + "\n"
+	begin, end := userRegionBegin+"helpers\n", userRegionEnd+"helpers\n"
+	for _, src := range []string{
+		header + "package orders\n",
+		header + "package orders",
+		header + "package orders\n" + begin + end,
+		header + "package orders\n" + begin + "func helper() {}\n\n" + end + "var x = 1\n",
+		header + "package orders\n" + begin + "func helper() {}\n",
+		header + "package orders\n" + end,
+		"package orders\n",
+		"",
+	} {
+		t.Run(fmt.Sprintf("%q", src), func(t *testing.T) {
+			c := newChecksumWriter()
+			for i := range len(src) {
+				_, err := c.Write([]byte(src[i : i+1]))
+				require.NoError(t, err)
+			}
+			content, sealed := c.checksum()
+			assert.Equal(t, contentChecksum(withoutUserCode([]byte(src))), content)
+			assert.Equal(t, bytes.HasPrefix([]byte(src), []byte(header)), sealed)
+		})
+	}
+}
+
+func BenchmarkCodeGenerator_GenerateStream(b *testing.B) {
+	gen, err := NewCodeGenerator()
+	require.NoError(b, err)
+	for _, n := range []int{10, 100, 1000, 5000} {
+		fsm := newLargeModel(b, n)
+		fsm.Source.Checksum = "specsum"
+		b.Run(fmt.Sprintf("states=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := gen.GenerateStream(fsm, io.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// GenerateStubs generates a {Name}CallbacksImpl with a stub method for every
+// guard, action, and entry and exit action of the machine, whose guards pass and
+// whose actions do nothing. The file belongs to the user once written, so with
+// the existing content of the file GenerateStubs returns that content with
+// stubs appended only for the callbacks it has no method for yet. Stubs of
+// callbacks the spec no longer has are kept; OrphanedStubs finds them.
+func (g *CodeGenerator) GenerateStubs(m *model.FSMModel, existing []byte) ([]byte, error) {
+	code, err := g.execute("stubs.tmpl", m)
+	if err != nil || existing == nil {
+		return code, err
+	}
+
+	fset := token.NewFileSet()
+	defined, err := existingStubs(fset, m, existing)
+	if err != nil {
+		return nil, err
+	}
+	stubs, err := parser.ParseFile(fset, "", code, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated stubs: %w", err)
+	}
+
+	result := bytes.Clone(existing)
+	for _, method := range methodsOf(stubs, stubsType(m)) {
+		if defined[method.Name.Name] != nil {
+			continue
+		}
+		start := method.Pos()
+		if method.Doc != nil {
+			start = method.Doc.Pos()
+		}
+		if !bytes.HasSuffix(result, []byte("\n")) {
+			result = append(result, '\n')
+		}
+		result = append(result, '\n')
+		result = append(result, code[fset.Position(start).Offset:fset.Position(method.End()).Offset]...)
+		result = append(result, '\n')
+	}
+	return result, nil
+}
+
+// OrphanedStub is a method of the callback stubs of a machine that no callback
+// of its spec has any longer, such as the stub of a guard that was renamed
+type OrphanedStub struct {
+	Name string
+	Line int
+}
+
+// OrphanedStubs returns the methods of the {Name}CallbacksImpl in existing, the
+// content of a file GenerateStubs wrote, that take a context.Context like the
+// callbacks but match none of the callbacks of the machine, in source order
+func (g *CodeGenerator) OrphanedStubs(m *model.FSMModel, existing []byte) ([]OrphanedStub, error) {
+	code, err := g.execute("stubs.tmpl", m)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	stubs, err := parser.ParseFile(fset, "", code, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated stubs: %w", err)
+	}
+	callbacks := make(map[string]bool)
+	for _, method := range methodsOf(stubs, stubsType(m)) {
+		callbacks[method.Name.Name] = true
+	}
+
+	defined, err := existingStubs(fset, m, existing)
+	if err != nil {
+		return nil, err
+	}
+	var orphans []OrphanedStub
+	for name, method := range defined {
+		if !callbacks[name] && method.Name.IsExported() && takesContext(method) {
+			orphans = append(orphans, OrphanedStub{Name: name, Line: fset.Position(method.Pos()).Line})
+		}
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].Line < orphans[j].Line })
+	return orphans, nil
+}
+
+// existingStubs returns the methods of the stubs type in existing by name
+func existingStubs(fset *token.FileSet, m *model.FSMModel, existing []byte) (map[string]*ast.FuncDecl, error) {
+	file, err := parser.ParseFile(fset, "", existing, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse existing stubs: %w", err)
+	}
+	defined := make(map[string]*ast.FuncDecl)
+	for _, method := range methodsOf(file, stubsType(m)) {
+		defined[method.Name.Name] = method
+	}
+	return defined, nil
+}
+
+// takesContext reports whether the first parameter of fn is a context.Context,
+// as that of every callback is, which tells stubs from helper methods
+func takesContext(fn *ast.FuncDecl) bool {
+	params := fn.Type.Params.List
+	if len(params) == 0 {
+		return false
+	}
+	sel, ok := params[0].Type.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "context" && sel.Sel.Name == "Context"
+}
+
+// stubsType returns the name of the type whose methods are the callback stubs
+func stubsType(m *model.FSMModel) string {
+	return m.Name + "CallbacksImpl"
+}
+
+// methodsOf returns the methods of file whose receiver is the named type or a
+// pointer to it
+func methodsOf(file *ast.File, typeName string) []*ast.FuncDecl {
+	var methods []*ast.FuncDecl
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv == nil || len(fn.Recv.List) == 0 {
+			continue
+		}
+		recv := fn.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		if ident, ok := recv.(*ast.Ident); ok && ident.Name == typeName {
+			methods = append(methods, fn)
+		}
+	}
+	return methods
+}
+
+// StubsOutputName returns the conventional callback stubs file name for a model
+func StubsOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + "_callbacks.go"
+}
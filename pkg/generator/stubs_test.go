@@ -0,0 +1,130 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateStubs(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	require.NoError(t, fsm.AddGuardCondition(&model.GuardCondition{Name: "hasPayment", When: "amount > 0"}))
+	fsm.Options.Callbacks = true
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	stubs, err := gen.GenerateStubs(fsm, nil)
+	require.NoError(t, err)
+	src := string(stubs)
+	assert.False(t, IsGenerated(stubs), "the stubs belong to the user")
+	assert.Equal(t, "order_state_machine_callbacks.go", StubsOutputName(fsm))
+	assert.Contains(t, src, "// HasPayment is the hasPayment guard, which must pass when amount > 0\n"+
+		"func (cb *OrderStateMachineCallbacksImpl) HasPayment(ctx context.Context, c *OrderStateMachineContext) bool {\n"+
+		"\t// TODO: implement the hasPayment guard\n\treturn true\n}")
+	assert.Contains(t, src, "func (cb *OrderStateMachineCallbacksImpl) ChargeCard(ctx context.Context, from, to OrderStateMachineState, c *OrderStateMachineContext) error {")
+	assert.Contains(t, src, "func (cb *OrderStateMachineCallbacksImpl) LogExit(ctx context.Context, c *OrderStateMachineContext) error {")
+
+	machine, err := gen.Generate(fsm)
+	require.NoError(t, err)
+	runGeneratedPackage(t, map[string][]byte{
+		"go.mod":                           []byte("module generated\n\ngo 1.22\n"),
+		"order_state_machine_fsm.go":       machine,
+		"order_state_machine_callbacks.go": stubs,
+		"stubs_test.go": []byte(`package orders
+
+import (
+	"context"
+	"testing"
+)
+
+var _ OrderStateMachineCallbacks = (*OrderStateMachineCallbacksImpl)(nil)
+
+func TestStubs(t *testing.T) {
+	sm := NewOrderStateMachineWithCallbacks(&OrderStateMachineCallbacksImpl{})
+	if err := sm.Transition(context.Background(), OrderStateMachineEventApprove); err != nil {
+		t.Fatal(err)
+	}
+	if sm.State() != OrderStateMachineStateApproved {
+		t.Fatalf("state = %v", sm.State())
+	}
+}
+`),
+	})
+}
+
+func TestCodeGenerator_GenerateStubs_Existing(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	existing := `package orders
+
+import "context"
+
+type OrderStateMachineCallbacksImpl struct{ charged int }
+
+// ChargeCard charges for real
+func (cb *OrderStateMachineCallbacksImpl) ChargeCard(ctx context.Context, from, to OrderStateMachineState, c *OrderStateMachineContext) error {
+	cb.charged++
+	return nil
+}
+
+func (cb OrderStateMachineCallbacksImpl) HasPayment(ctx context.Context, c *OrderStateMachineContext) bool {
+	return cb.charged > 0
+}`
+	stubs, err := gen.GenerateStubs(fsm, []byte(existing))
+	require.NoError(t, err)
+	src := string(stubs)
+	assert.True(t, strings.HasPrefix(src, existing+"\n\n"), "existing code is kept as it is")
+	assert.Equal(t, 1, strings.Count(src, ") ChargeCard("))
+	assert.Equal(t, 1, strings.Count(src, ") HasPayment("), "methods with value receivers count")
+	assert.Contains(t, src, "\n\n// LogEntry is the logEntry state action\nfunc (cb *OrderStateMachineCallbacksImpl) LogEntry(")
+	assert.Contains(t, src, "func (cb *OrderStateMachineCallbacksImpl) SendRejectionEmail(")
+
+	again, err := gen.GenerateStubs(fsm, stubs)
+	require.NoError(t, err)
+	assert.Equal(t, src, string(again), "complete stubs are left alone")
+
+	_, err = gen.GenerateStubs(fsm, []byte("package orders\n\nfunc {"))
+	assert.ErrorContains(t, err, "failed to parse existing stubs")
+}
+
+func TestCodeGenerator_OrphanedStubs(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	stubs, err := gen.GenerateStubs(fsm, nil)
+	require.NoError(t, err)
+	orphans, err := gen.OrphanedStubs(fsm, stubs)
+	require.NoError(t, err)
+	assert.Empty(t, orphans)
+
+	renamed := strings.Replace(string(stubs), ") HasPayment(", ") HasFunds(", 1)
+	renamed += `
+func (cb *OrderStateMachineCallbacksImpl) Audit(ctx context.Context, c *OrderStateMachineContext) error {
+	return nil
+}
+
+func (cb *OrderStateMachineCallbacksImpl) Describe(prefix string) string {
+	return prefix
+}
+
+func (cb *OrderStateMachineCallbacksImpl) audit(ctx context.Context) {}
+`
+	orphans, err = gen.OrphanedStubs(fsm, []byte(renamed))
+	require.NoError(t, err)
+	lines := strings.Split(renamed, "\n")
+	require.Len(t, orphans, 2, "helper methods without a context or unexported are not stubs")
+	assert.Equal(t, "HasFunds", orphans[0].Name)
+	assert.Contains(t, lines[orphans[0].Line-1], ") HasFunds(")
+	assert.Equal(t, "Audit", orphans[1].Name)
+	assert.Contains(t, lines[orphans[1].Line-1], ") Audit(")
+
+	_, err = gen.OrphanedStubs(fsm, []byte("package orders\n\nfunc {"))
+	assert.ErrorContains(t, err, "failed to parse existing stubs")
+}
@@ -1,19 +1,194 @@
 package generator
 
 import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
 )
 
 // TemplateFuncs returns a map of custom template functions
 func TemplateFuncs() map[string]interface{} {
 	return map[string]interface{}{
-		"title":      title,
-		"lower":      strings.ToLower,
-		"upper":      strings.ToUpper,
-		"camelCase":  camelCase,
-		"snakeCase":  snakeCase,
+		"title":           title,
+		"lower":           strings.ToLower,
+		"upper":           strings.ToUpper,
+		"camelCase":       camelCase,
+		"snakeCase":       snakeCase,
+		"fileHeader":      fileHeader,
+		"dotQuote":        dotQuote,
+		"transitionLabel": transitionLabel,
+		"mdCell":          mdCell,
+		"tlaString":       tlaString,
+		"tlaComment":      tlaComment,
+		"stateConst":      stateConst,
+		"eventConst":      eventConst,
+		"pluralize":       pluralize,
+		"quote":           strconv.Quote,
+		"commentWrap":     commentWrap,
+		"docComment":      docComment,
+		"receiverLetter":  receiverLetter,
+		"goType":          goType,
+		"goDuration":      goDuration,
+		"eventGroups":     eventGroups,
+	}
+}
+
+// modelFuncs returns the template functions that follow the naming options of m,
+// replacing the defaults of TemplateFuncs. They keep the options m has now.
+func modelFuncs(m *model.FSMModel) map[string]interface{} {
+	naming := m.Options.Naming
+	return map[string]interface{}{
+		"title": func(s string) string { return pascalCase(s, naming) },
+	}
+}
+
+// pluralize returns the English plural of a noun such as a state or event name,
+// following the regular rules: statuses, entries, events
+func pluralize(s string) string {
+	lower := strings.ToLower(s)
+	runes := []rune(lower)
+	switch {
+	case s == "":
+		return s
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return s + "es"
+	case strings.HasSuffix(lower, "y") && len(runes) > 1 && !strings.ContainsRune("aeiou", runes[len(runes)-2]):
+		return s[:len(s)-1] + "ies"
+	default:
+		return s + "s"
+	}
+}
+
+// commentWrap wraps text into // comment lines of at most width characters,
+// except for words longer than that, and joins them with newlines
+func commentWrap(width int, text string) string {
+	var lines []string
+	var line strings.Builder
+	for _, word := range strings.Fields(text) {
+		if line.Len() > 0 && utf8.RuneCountInString(line.String())+1+utf8.RuneCountInString(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() == 0 {
+			line.WriteString("//")
+		}
+		line.WriteString(" " + word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// paragraphBreak matches the blank lines between paragraphs of a description
+var paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+
+// docComment turns a description into a doc comment whose lines start with
+// indent, wrapping each paragraph to fit 80 columns and separating paragraphs
+// with an empty // line, so that multi-line descriptions stay valid Go
+func docComment(indent, text string) string {
+	width := 80 - len(strings.ReplaceAll(indent, "\t", "    "))
+	var paragraphs []string
+	for _, paragraph := range paragraphBreak.Split(strings.TrimSpace(text), -1) {
+		if wrapped := commentWrap(width, paragraph); wrapped != "" {
+			paragraphs = append(paragraphs, wrapped)
+		}
+	}
+	if len(paragraphs) == 0 {
+		return ""
+	}
+	lines := strings.Split(strings.Join(paragraphs, "\n//\n"), "\n")
+	for i, line := range lines {
+		lines[i] = indent + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// receiverLetter returns the conventional receiver name of methods of the type
+// name: its first letter in lower case
+func receiverLetter(name string) string {
+	first, _ := utf8.DecodeRuneInString(name)
+	if first == utf8.RuneError {
+		return ""
+	}
+	return string(unicode.ToLower(first))
+}
+
+// goType returns the Go type of a context field type such as "float", accepting
+// a model.FieldType or a string
+func goType(fieldType any) string {
+	field := model.ContextField{Type: model.FieldType(fmt.Sprint(fieldType))}
+	return field.GoType()
+}
+
+// goDuration returns a Go expression for d in its largest whole unit, such as
+// 48 * time.Hour
+func goDuration(d time.Duration) string {
+	for _, unit := range []struct {
+		size time.Duration
+		name string
+	}{
+		{time.Hour, "Hour"},
+		{time.Minute, "Minute"},
+		{time.Second, "Second"},
+		{time.Millisecond, "Millisecond"},
+		{time.Microsecond, "Microsecond"},
+	} {
+		if d%unit.size == 0 {
+			return fmt.Sprintf("%d * time.%s", d/unit.size, unit.name)
+		}
 	}
+	return fmt.Sprintf("%d * time.Nanosecond", int64(d))
+}
+
+// eventGroups groups transitions from one state by event, in the order each event
+// first appears, keeping the declaration order of the candidates of an event
+func eventGroups(transitions []*model.Transition) [][]*model.Transition {
+	var groups [][]*model.Transition
+	index := make(map[string]int)
+	for _, t := range transitions {
+		i, ok := index[t.Event]
+		if !ok {
+			i = len(groups)
+			index[t.Event] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], t)
+	}
+	return groups
+}
+
+// stateConst returns the name of the constant of the state name of m
+func stateConst(m *model.FSMModel, name string) string {
+	return constName(m, "State", name)
+}
+
+// eventConst returns the name of the constant of the event name of m
+func eventConst(m *model.FSMModel, name string) string {
+	return constName(m, "Event", name)
+}
+
+// constName returns the name of the constant of kind for name, in the constant
+// style of m
+func constName(m *model.FSMModel, kind, name string) string {
+	ident := pascalCase(name, m.Options.Naming)
+	if m.Options.Naming.ConstantStyleOrDefault() == model.ConstantSuffix {
+		ident += kind
+	} else {
+		ident = kind + ident
+	}
+	if !m.Options.Naming.OmitMachineName {
+		ident = m.Name + ident
+	}
+	return ident
 }
 
 // title converts a string to title case (first letter uppercase)
@@ -27,28 +202,110 @@ func title(s string) string {
 
 // toPascalCase converts a string to PascalCase
 func toPascalCase(s string) string {
+	return pascalCase(s, model.Naming{})
+}
+
+// commonInitialisms are the initialisms Go names write in upper case, after the
+// list of golint, with SKU added. Naming.Initialisms turns them on.
+var commonInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true,
+	"EOF": true, "GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "LHS": true, "QPS": true, "RAM": true, "RHS": true,
+	"RPC": true, "SKU": true, "SLA": true, "SMTP": true, "SQL": true, "SSH": true,
+	"TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true, "UID": true,
+	"URI": true, "URL": true, "UTF8": true, "UUID": true, "VM": true, "XML": true,
+	"XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// isInitialism reports whether naming writes word in upper case
+func isInitialism(word string, naming model.Naming) bool {
+	return naming.IsAcronym(word) || naming.Initialisms && commonInitialisms[strings.ToUpper(word)]
+}
+
+// pascalCase converts a string to PascalCase, writing the acronyms of naming in
+// upper case
+func pascalCase(s string, naming model.Naming) string {
 	if s == "" {
 		return s
 	}
 
 	// Split by common delimiters
 	words := splitWords(s)
+	if len(naming.Acronyms) > 0 || naming.Initialisms {
+		// Keep an initialism already in upper case, as in IDReceived, apart
+		// from the word after it
+		var split []string
+		for _, word := range words {
+			split = append(split, splitCapitals(word)...)
+		}
+		words = split
+	}
 
 	var result strings.Builder
 	for _, word := range words {
 		if word == "" {
 			continue
 		}
-		// Capitalize first letter of each word
-		result.WriteString(strings.ToUpper(string(word[0])))
-		if len(word) > 1 {
-			result.WriteString(strings.ToLower(word[1:]))
+		if isInitialism(word, naming) {
+			result.WriteString(strings.ToUpper(word))
+			continue
 		}
+		// Capitalize first letter of each word, which may take several bytes
+		first, size := utf8.DecodeRuneInString(word)
+		result.WriteRune(unicode.ToUpper(first))
+		result.WriteString(strings.ToLower(word[size:]))
 	}
 
 	return result.String()
 }
 
+// isIdentifier reports whether s is a legal Go identifier: a letter or underscore
+// followed by letters, digits, and underscores, any of which may be non-ASCII
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkIdentifiers checks that the names of m become legal Go identifiers once
+// converted to PascalCase, which a name such as _1 does not
+func checkIdentifiers(m *model.FSMModel) error {
+	if !isIdentifier(m.Name) {
+		return fmt.Errorf("machine name %q is not a Go identifier", m.Name)
+	}
+	for _, names := range []struct {
+		kind  string
+		names []string
+	}{
+		{"state", m.GetStateNames()},
+		{"event", m.GetEventNames()},
+		{"guard", m.GetGuardNames()},
+		{"action", m.GetActionNames()},
+		{"state action", m.GetStateActionNames()},
+	} {
+		for _, name := range names.names {
+			if ident := pascalCase(name, m.Options.Naming); !isIdentifier(ident) {
+				return fmt.Errorf("%s %q becomes %q, which is not a Go identifier", names.kind, name, ident)
+			}
+		}
+	}
+	if m.HasImmutableContext() {
+		// The fields of an immutable context are unexported, so they may be keywords
+		for _, field := range m.Context {
+			if ident := camelCase(field.Name); !isIdentifier(ident) || token.IsKeyword(ident) {
+				return fmt.Errorf("context field %q becomes %q, which is not a Go identifier", field.Name, ident)
+			}
+		}
+	}
+	return nil
+}
+
 // camelCase converts a string to camelCase
 func camelCase(s string) string {
 	if s == "" {
@@ -115,3 +372,15 @@ func splitWords(s string) []string {
 
 	return words
 }
+
+// splitCapitals splits a run of capitals from the capitalized word after it, as
+// IDReceived into ID and Received
+func splitCapitals(word string) []string {
+	runes := []rune(word)
+	for i := 1; i+1 < len(runes); i++ {
+		if unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i+1]) {
+			return append([]string{string(runes[:i])}, splitCapitals(string(runes[i:]))...)
+		}
+	}
+	return []string{word}
+}
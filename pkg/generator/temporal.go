@@ -0,0 +1,15 @@
+package generator
+
+import "github.com/yourusername/gofsm-gen/pkg/model"
+
+// GenerateTemporal generates an adapter that drives the machine from a Temporal
+// workflow: a signal for each event fires it on the machine, and queries report
+// the state and the events it permits
+func (g *CodeGenerator) GenerateTemporal(m *model.FSMModel) ([]byte, error) {
+	return g.execute("temporal.tmpl", m)
+}
+
+// TemporalOutputName returns the conventional Temporal adapter file name for a model
+func TemporalOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + "_temporal.go"
+}
@@ -0,0 +1,230 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// temporalStubs stand in for the go.temporal.io/sdk workflow package the adapter
+// uses. A stub context delivers its queued signals one per Select and is canceled
+// once they run out.
+var temporalStubs = map[string][]byte{
+	"go.mod": []byte(`module generated
+
+go 1.21
+
+require go.temporal.io/sdk v0.0.0
+
+replace go.temporal.io/sdk => ./temporalstub
+`),
+	"temporalstub/go.mod": []byte("module go.temporal.io/sdk\n\ngo 1.21\n"),
+	"temporalstub/workflow/workflow.go": []byte(`package workflow
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+type Signal struct {
+	Name  string
+	Value any
+}
+
+type Context interface {
+	Done() Channel
+	Err() error
+}
+
+type ReceiveChannel interface {
+	Receive(ctx Context, valuePtr interface{}) (more bool)
+}
+
+type Channel interface {
+	ReceiveChannel
+}
+
+type Selector interface {
+	AddReceive(c ReceiveChannel, f func(c ReceiveChannel, more bool)) Selector
+	Select(ctx Context)
+}
+
+type Logger interface {
+	Warn(msg string, keyvals ...interface{})
+}
+
+type TestContext struct {
+	Signals []Signal
+	Warned  []string
+	queries map[string]interface{}
+	err     error
+}
+
+func (c *TestContext) Done() Channel { return &channel{} }
+func (c *TestContext) Err() error    { return c.err }
+
+func (c *TestContext) Query(name string) any {
+	out := reflect.ValueOf(c.queries[name]).Call(nil)
+	return out[0].Interface()
+}
+
+type channel struct{ name string }
+
+func (ch *channel) Receive(ctx Context, valuePtr interface{}) bool {
+	c := ctx.(*TestContext)
+	data, _ := json.Marshal(c.Signals[0].Value)
+	c.Signals = c.Signals[1:]
+	_ = json.Unmarshal(data, valuePtr)
+	return true
+}
+
+type selector struct {
+	cases []*channel
+	funcs []func(ReceiveChannel, bool)
+}
+
+func (s *selector) AddReceive(c ReceiveChannel, f func(c ReceiveChannel, more bool)) Selector {
+	s.cases = append(s.cases, c.(*channel))
+	s.funcs = append(s.funcs, f)
+	return s
+}
+
+func (s *selector) Select(ctx Context) {
+	c := ctx.(*TestContext)
+	if len(c.Signals) == 0 {
+		c.err = errors.New("canceled")
+		return
+	}
+	for i, ch := range s.cases {
+		if ch.name == c.Signals[0].Name {
+			s.funcs[i](ch, true)
+			return
+		}
+	}
+	panic("no handler for signal " + c.Signals[0].Name)
+}
+
+func GetSignalChannel(ctx Context, name string) ReceiveChannel { return &channel{name: name} }
+
+func SetQueryHandler(ctx Context, queryType string, handler interface{}) error {
+	c := ctx.(*TestContext)
+	if c.queries == nil {
+		c.queries = map[string]interface{}{}
+	}
+	c.queries[queryType] = handler
+	return nil
+}
+
+func NewSelector(ctx Context) Selector { return &selector{} }
+
+type logger struct{ c *TestContext }
+
+func (l logger) Warn(msg string, keyvals ...interface{}) { l.c.Warned = append(l.c.Warned, msg) }
+
+func GetLogger(ctx Context) Logger { return logger{ctx.(*TestContext)} }
+`),
+}
+
+func TestCodeGenerator_GenerateTemporal(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.States["shipped"].Final = true
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "amount", Type: model.FieldInt}))
+	require.NoError(t, fsm.AddGuardCondition(&model.GuardCondition{Name: "hasPayment", When: "amount > 0"}))
+	require.NoError(t, fsm.Validate())
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	adapter, err := gen.GenerateTemporal(fsm)
+	require.NoError(t, err)
+	src := string(adapter)
+	assert.True(t, IsGenerated(adapter))
+	assert.Equal(t, "order_state_machine_temporal.go", TemporalOutputName(fsm))
+	assert.Contains(t, src, "\tOrderStateMachineSignalApprove = \"approve\"\n")
+	assert.Contains(t, src, "func RunOrderStateMachineWorkflow(ctx workflow.Context, sm *OrderStateMachine, opts OrderStateMachineWorkflowOptions) (OrderStateMachineState, error) {")
+	assert.Contains(t, src, `	OrderStateMachineStateApproved: {
+		OrderStateMachineEventShip,
+	},`)
+
+	machine, err := gen.Generate(fsm)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"order_state_machine_fsm.go":      machine,
+		"order_state_machine_temporal.go": adapter,
+		"temporal_test.go": []byte(`package orders
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestWorkflow(t *testing.T) {
+	ctx := &workflow.TestContext{Signals: []workflow.Signal{
+		{Name: OrderStateMachineSignalApprove},
+		{Name: OrderStateMachineSignalApprove, Value: OrderStateMachineContext{Amount: 5}},
+		{Name: OrderStateMachineSignalReject},
+		{Name: OrderStateMachineSignalShip},
+	}}
+	var charged bool
+	sm := NewOrderStateMachine(OrderStateMachineGuards{
+		HasPayment: func(_ context.Context, c *OrderStateMachineContext) bool { return c.Amount > 0 },
+	}, OrderStateMachineActions{
+		ChargeCard: func(actx context.Context, from, to OrderStateMachineState, c *OrderStateMachineContext) error {
+			if wctx, ok := OrderStateMachineWorkflowContext(actx); !ok || wctx != workflow.Context(ctx) {
+				t.Error("actions must see the workflow context")
+			}
+			// Queries must not block on the machine while an action runs
+			if state := ctx.Query(OrderStateMachineStateQuery); state != "pending" {
+				t.Errorf("state query during the action = %v", state)
+			}
+			charged = true
+			return nil
+		},
+	})
+
+	var rejected []OrderStateMachineEvent
+	state, err := RunOrderStateMachineWorkflow(ctx, sm, OrderStateMachineWorkflowOptions{
+		Rejected: func(_ workflow.Context, event OrderStateMachineEvent, err error) {
+			rejected = append(rejected, event)
+		},
+	})
+	if err != nil || state != OrderStateMachineStateShipped {
+		t.Fatalf("workflow ended in %s, %v", state, err)
+	}
+	if !charged {
+		t.Error("the card was not charged")
+	}
+	if !reflect.DeepEqual(rejected, []OrderStateMachineEvent{OrderStateMachineEventApprove, OrderStateMachineEventReject}) {
+		t.Errorf("rejected %v", rejected)
+	}
+	if state := ctx.Query(OrderStateMachineStateQuery); state != "shipped" {
+		t.Errorf("state query = %v", state)
+	}
+
+	// Cancellation ends the workflow in the current state
+	ctx = &workflow.TestContext{Signals: []workflow.Signal{{Name: OrderStateMachineSignalReject}, {Name: OrderStateMachineSignalShip}}}
+	state, err = RunOrderStateMachineWorkflow(ctx, NewOrderStateMachine(OrderStateMachineGuards{}, OrderStateMachineActions{}), OrderStateMachineWorkflowOptions{})
+	if err == nil || state != OrderStateMachineStateRejected {
+		t.Fatalf("canceled workflow ended in %s, %v", state, err)
+	}
+	if len(ctx.Warned) != 1 {
+		t.Errorf("rejected events are logged by default, got %v", ctx.Warned)
+	}
+	if events := ctx.Query(OrderStateMachinePermittedEventsQuery); !reflect.DeepEqual(events, []string{}) {
+		t.Errorf("permitted events query = %v", events)
+	}
+}
+`),
+	}
+	for name, content := range temporalStubs {
+		files[name] = content
+	}
+	runGeneratedPackage(t, files)
+}
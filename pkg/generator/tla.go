@@ -0,0 +1,72 @@
+package generator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// tlaData is the template data of tla.tmpl
+type tlaData struct {
+	*model.FSMModel
+}
+
+// GenerateTLA generates a TLA+ module describing the states, events, and
+// transition relation of the given FSM model, for model checking with TLC
+func (g *CodeGenerator) GenerateTLA(m *model.FSMModel) ([]byte, error) {
+	return g.executeData("tla.tmpl", m, tlaData{FSMModel: m})
+}
+
+// TLAOutputName returns the TLA+ file name for a model, which TLC requires to
+// match the module name
+func TLAOutputName(m *model.FSMModel) string {
+	return tlaModuleName(m) + ".tla"
+}
+
+// ModuleName returns the name of the TLA+ module
+func (d tlaData) ModuleName() string {
+	return tlaModuleName(d.FSMModel)
+}
+
+// Relation returns the transitions the machine can take, ordered by source state
+// and event. Guards are abstracted away, so every candidate up to and including the
+// first unguarded one may fire; candidates after it can never be selected.
+func (d tlaData) Relation() []*model.Transition {
+	var relation []*model.Transition
+	settled := make(map[[2]string]bool)
+	for _, t := range d.Transitions {
+		key := [2]string{t.From, t.Event}
+		if settled[key] {
+			continue
+		}
+		relation = append(relation, t)
+		if t.Guard == "" {
+			settled[key] = true
+		}
+	}
+
+	sort.SliceStable(relation, func(i, j int) bool {
+		if relation[i].From != relation[j].From {
+			return relation[i].From < relation[j].From
+		}
+		return relation[i].Event < relation[j].Event
+	})
+	return relation
+}
+
+// tlaModuleName returns the machine name in PascalCase, which is a valid TLA+
+// module identifier
+func tlaModuleName(m *model.FSMModel) string {
+	return toPascalCase(m.Name)
+}
+
+// tlaString quotes s as a TLA+ string literal
+func tlaString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// tlaComment prefixes every line of s with a TLA+ line comment
+func tlaComment(s string) string {
+	return `\* ` + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n\\* ")
+}
@@ -0,0 +1,45 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateTLA(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fsm.Description = "Manages the lifecycle\nof customer orders"
+	fsm.GetState("shipped").Final = true
+	fallback, _ := model.NewTransition("pending", "rejected", "approve")
+	fsm.AddTransition(fallback)
+	unreachable, _ := model.NewTransition("pending", "shipped", "approve")
+	fsm.AddTransition(unreachable)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	module, err := gen.GenerateTLA(fsm)
+	require.NoError(t, err)
+	src := string(module)
+
+	assert.True(t, strings.HasPrefix(src, "\\* Code generated by gofsm-gen. DO NOT EDIT.\n---- MODULE OrderStateMachine ----\n"+
+		"\\* Manages the lifecycle\n\\* of customer orders\n"))
+	assert.Contains(t, src, `States == {"approved", "pending", "rejected", "shipped"}`)
+	assert.Contains(t, src, `Events == {"approve", "reject", "ship"}`)
+	assert.Contains(t, src, `FinalStates == {"shipped"}`)
+	assert.Contains(t, src, "Transitions == {\n"+
+		"      <<\"approved\", \"ship\", \"shipped\">>\n"+
+		"    , <<\"pending\", \"approve\", \"approved\">>\n"+
+		"    , <<\"pending\", \"approve\", \"rejected\">>\n"+
+		"    , <<\"pending\", \"reject\", \"rejected\">>\n"+
+		"}\n")
+	assert.Contains(t, src, "/\\ state = \"pending\"\n")
+	assert.True(t, strings.HasSuffix(src, "\n====\n"))
+	assert.Equal(t, "OrderStateMachine.tla", TLAOutputName(fsm))
+}
+
+func TestTLAString(t *testing.T) {
+	assert.Equal(t, `"say \"hi\"\\n"`, tlaString(`say "hi"\n`))
+}
@@ -0,0 +1,86 @@
+package generator
+
+import (
+	"sort"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// typescriptData is the template data of typescript.tmpl and typescript_machine.tmpl
+type typescriptData struct {
+	*model.FSMModel
+}
+
+// tsEventTargets are the states an event may lead to from one state
+type tsEventTargets struct {
+	Event   string
+	Targets []string
+
+	// Transitions are the transitions of the event, in declaration order
+	Transitions []*model.Transition
+}
+
+// tsStateTransitions are the events permitted in one state
+type tsStateTransitions struct {
+	State  string
+	Events []tsEventTargets
+}
+
+// GenerateTypeScript generates a TypeScript module with string-literal unions
+// for the states and events of the given FSM model and a transition map
+func (g *CodeGenerator) GenerateTypeScript(m *model.FSMModel) ([]byte, error) {
+	return g.executeData("typescript.tmpl", m, typescriptData{FSMModel: m})
+}
+
+// GenerateTypeScriptMachine generates a TypeScript module with the types of
+// GenerateTypeScript and a machine class running the guard and action callbacks
+// of the given FSM model, like the generated Go machine
+func (g *CodeGenerator) GenerateTypeScriptMachine(m *model.FSMModel) ([]byte, error) {
+	return g.executeData("typescript_machine.tmpl", m, typescriptData{FSMModel: m})
+}
+
+// TypeScriptOutputName returns the conventional TypeScript file name for a model
+func TypeScriptOutputName(m *model.FSMModel) string {
+	return snakeCase(m.Name) + ".ts"
+}
+
+// TransitionMap returns, for every state in name order, the permitted events in
+// name order with their target states and transitions in declaration order
+func (d typescriptData) TransitionMap() []tsStateTransitions {
+	states := d.GetStatesSlice()
+	transitions := make([]tsStateTransitions, 0, len(states))
+	transitionsFrom := d.GetTransitionsByState()
+	for _, state := range states {
+		targets := make(map[string]*tsEventTargets)
+		for _, t := range transitionsFrom[state.Name] {
+			event := targets[t.Event]
+			if event == nil {
+				event = &tsEventTargets{Event: t.Event}
+				targets[t.Event] = event
+			}
+			if !containsString(event.Targets, t.To) {
+				event.Targets = append(event.Targets, t.To)
+			}
+			event.Transitions = append(event.Transitions, t)
+		}
+
+		events := make([]tsEventTargets, 0, len(targets))
+		for _, event := range targets {
+			events = append(events, *event)
+		}
+		sort.Slice(events, func(i, j int) bool { return events[i].Event < events[j].Event })
+
+		transitions = append(transitions, tsStateTransitions{State: state.Name, Events: events})
+	}
+	return transitions
+}
+
+// containsString reports whether values includes s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestCodeGenerator_GenerateTypeScript(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	fallback, _ := model.NewTransition("pending", "rejected", "approve")
+	fsm.AddTransition(fallback)
+
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+	ts, err := gen.GenerateTypeScript(fsm)
+	require.NoError(t, err)
+	src := string(ts)
+
+	assert.True(t, IsGenerated(ts))
+	assert.Contains(t, src, `export type OrderStateMachineState =
+  | "approved"
+  | "pending"
+  | "rejected"
+  | "shipped";`)
+	assert.Contains(t, src, `export type OrderStateMachineEvent =
+  | "approve"
+  | "reject"
+  | "ship";`)
+	assert.Contains(t, src, `export const orderStateMachineInitialState: OrderStateMachineState = "pending";`)
+	assert.Contains(t, src, `> = {
+  approved: {
+    ship: ["shipped"],
+  },
+  pending: {
+    approve: ["approved", "rejected"],
+    reject: ["rejected"],
+  },
+  rejected: {},
+  shipped: {},
+};`)
+	assert.Contains(t, src, "export function orderStateMachinePermittedEvents(state: OrderStateMachineState): OrderStateMachineEvent[] {")
+	assert.Contains(t, src, "export function orderStateMachineCanTransition(state: OrderStateMachineState, event: OrderStateMachineEvent): boolean {")
+}
+
+func TestCodeGenerator_GenerateTypeScriptMachine(t *testing.T) {
+	fsm := createOrderStateMachine(t)
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	ts, err := gen.GenerateTypeScriptMachine(fsm)
+	require.NoError(t, err)
+	src := string(ts)
+
+	assert.True(t, IsGenerated(ts))
+	types, err := gen.GenerateTypeScript(fsm)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(src, strings.TrimSuffix(string(types), "\n")), "The machine module should include the exported types")
+
+	assert.Contains(t, src, `export interface OrderStateMachineContext {
+  [key: string]: unknown;
+}`)
+	assert.Contains(t, src, `export interface OrderStateMachineGuards {
+  hasPayment?(context: OrderStateMachineContext): boolean;
+}`)
+	assert.Contains(t, src, "  chargeCard?(from: OrderStateMachineState, to: OrderStateMachineState, context: OrderStateMachineContext): void | Promise<void>;")
+	assert.Contains(t, src, `export interface OrderStateMachineEntryActions {
+  logEntry?(context: OrderStateMachineContext): void | Promise<void>;
+  notifyCustomer?(context: OrderStateMachineContext): void | Promise<void>;
+}`)
+	assert.Contains(t, src, `  pending: {
+    approve: [
+      { to: "approved", guard: "hasPayment", action: "chargeCard" },
+    ],
+    reject: [
+      { to: "rejected", action: "sendRejectionEmail" },
+    ],
+  },
+  rejected: {},`)
+	assert.Contains(t, src, `  pending: { entry: "logEntry", exit: "logExit" },
+  shipped: { entry: "notifyCustomer" },
+};`)
+	assert.Contains(t, src, "export class OrderStateMachine {\n  private current: OrderStateMachineState = orderStateMachineInitialState;")
+	assert.Contains(t, src, "  async transition(event: OrderStateMachineEvent): Promise<void> {")
+
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "amount", Type: model.FieldInt, Description: "Amount is the order total in cents"}))
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "note", Type: model.FieldString}))
+	require.NoError(t, fsm.AddContextField(&model.ContextField{Name: "express", Type: model.FieldBool}))
+	require.NoError(t, fsm.AddGuardCondition(&model.GuardCondition{Name: "hasPayment", When: "amount > 0"}))
+	require.NoError(t, fsm.Validate())
+
+	ts, err = gen.GenerateTypeScriptMachine(fsm)
+	require.NoError(t, err)
+	src = string(ts)
+	assert.Contains(t, src, `export interface OrderStateMachineContext {
+  /** Amount is the order total in cents */
+  amount: number;
+  note: string;
+  express: boolean;
+}`)
+	assert.Contains(t, src, `  return {
+    amount: 0,
+    note: "",
+    express: false,
+  };`)
+	assert.Contains(t, src, `  /** Must pass when amount > 0. */
+  hasPayment?(context: OrderStateMachineContext): boolean;`)
+	assert.NotContains(t, src, "transition.to, this.context);\n    const exit")
+
+	fsm.BeforeTransition = "audit"
+	fsm.AfterTransition = "touchUpdatedAt"
+	ts, err = gen.GenerateTypeScriptMachine(fsm)
+	require.NoError(t, err)
+	src = string(ts)
+	assert.Contains(t, src, "  audit?(from: OrderStateMachineState, to: OrderStateMachineState, context: OrderStateMachineContext): void | Promise<void>;")
+	assert.Contains(t, src, "    await this.callbacks.actions?.audit?.(from, transition.to, this.context);\n    const exit")
+	assert.Contains(t, src, "      await this.callbacks.entryActions?.[entry]?.(this.context);\n    }\n    await this.callbacks.actions?.touchUpdatedAt?.(from, transition.to, this.context);\n")
+}
@@ -0,0 +1,104 @@
+package generator
+
+import (
+	"runtime/debug"
+	"strings"
+)
+
+// Build metadata set at link time by release builds, e.g.
+//
+//	go build -ldflags "-X github.com/yourusername/gofsm-gen/pkg/generator.version=v1.2.0
+//	  -X github.com/yourusername/gofsm-gen/pkg/generator.commit=$(git rev-parse HEAD)
+//	  -X github.com/yourusername/gofsm-gen/pkg/generator.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// develVersion is reported when neither -ldflags nor the module build info name a version
+const develVersion = "devel"
+
+// Build describes the gofsm-gen binary
+type Build struct {
+	// Version is the release or module version
+	Version string
+
+	// Commit is the VCS revision the binary was built from, if known
+	Commit string
+
+	// Date is the commit or build time, if known
+	Date string
+
+	// Modified reports whether the working tree had uncommitted changes
+	Modified bool
+
+	// GoVersion is the Go toolchain that built the binary
+	GoVersion string
+}
+
+// ReadBuild returns the build metadata. Values set with -ldflags win; otherwise
+// the module version and VCS settings recorded by the go command are used, so
+// "go install module@version" and builds from a checkout both identify themselves.
+func ReadBuild() Build {
+	return readBuild(debug.ReadBuildInfo())
+}
+
+func readBuild(info *debug.BuildInfo, ok bool) Build {
+	b := Build{Version: version, Commit: commit, Date: date}
+	if ok {
+		b.GoVersion = info.GoVersion
+		if b.Version == "" && info.Main.Version != "(devel)" {
+			b.Version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if b.Commit == "" {
+					b.Commit = s.Value
+				}
+			case "vcs.time":
+				if b.Date == "" {
+					b.Date = s.Value
+				}
+			case "vcs.modified":
+				b.Modified = s.Value == "true"
+			}
+		}
+	}
+	if b.Version == "" {
+		b.Version = develVersion
+	}
+	return b
+}
+
+// String describes the build on one line, e.g.
+// "gofsm-gen v1.2.0 (commit 1a2b3c4, 2026-01-02T03:04:05Z, go1.25.0)"
+func (b Build) String() string {
+	var details []string
+	if b.Commit != "" {
+		c := b.Commit
+		if len(c) > 12 {
+			c = c[:12]
+		}
+		if b.Modified {
+			c += "+dirty"
+		}
+		details = append(details, "commit "+c)
+	}
+	if b.Date != "" {
+		details = append(details, b.Date)
+	}
+	if b.GoVersion != "" {
+		details = append(details, b.GoVersion)
+	}
+
+	s := "gofsm-gen " + b.Version
+	if len(details) > 0 {
+		s += " (" + strings.Join(details, ", ") + ")"
+	}
+	return s
+}
+
+// Version is the gofsm-gen version recorded in stamped file headers
+var Version = ReadBuild().Version
@@ -0,0 +1,48 @@
+package generator
+
+import (
+	"runtime/debug"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBuild_FromBuildInfo(t *testing.T) {
+	info := &debug.BuildInfo{
+		GoVersion: "go1.25.0",
+		Main:      debug.Module{Version: "v1.2.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "1a2b3c4d5e6f7a8b9c0d"},
+			{Key: "vcs.time", Value: "2026-01-02T03:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	b := readBuild(info, true)
+	assert.Equal(t, Build{
+		Version:   "v1.2.0",
+		Commit:    "1a2b3c4d5e6f7a8b9c0d",
+		Date:      "2026-01-02T03:04:05Z",
+		Modified:  true,
+		GoVersion: "go1.25.0",
+	}, b)
+	assert.Equal(t, "gofsm-gen v1.2.0 (commit 1a2b3c4d5e6f+dirty, 2026-01-02T03:04:05Z, go1.25.0)", b.String())
+}
+
+func TestReadBuild_LinkerFlagsWin(t *testing.T) {
+	defer func(v, c, d string) { version, commit, date = v, c, d }(version, commit, date)
+	version, commit, date = "v2.0.0", "abc1234", "2026-03-04"
+
+	b := readBuild(&debug.BuildInfo{
+		Main:     debug.Module{Version: "v1.2.0"},
+		Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "1a2b3c4"}},
+	}, true)
+	assert.Equal(t, "v2.0.0", b.Version)
+	assert.Equal(t, "abc1234", b.Commit)
+	assert.Equal(t, "2026-03-04", b.Date)
+}
+
+func TestReadBuild_Devel(t *testing.T) {
+	assert.Equal(t, "gofsm-gen devel", readBuild(&debug.BuildInfo{Main: debug.Module{Version: "(devel)"}}, true).String())
+	assert.Equal(t, "devel", readBuild(nil, false).Version)
+}
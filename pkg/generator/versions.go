@@ -0,0 +1,70 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// versionsData is the template data of versions.tmpl
+type versionsData struct {
+	Header   *model.FSMModel
+	Package  string
+	Base     string
+	Versions []*model.FSMModel
+}
+
+// VersionsOutputName returns the conventional name of the file shared by the
+// versions of a machine generated side by side
+func VersionsOutputName(base string) string {
+	return snakeCase(base) + "_versions_fsm.gen.go"
+}
+
+// GenerateVersions generates the declarations shared by the versions of a
+// machine generated side by side into one package: an interface named after the
+// machine that every version implements, the list of versions, and the Logger
+// the versions are configured with. Every model must have been renamed with
+// UseVersionedName, and all must share the package and base name.
+func (g *CodeGenerator) GenerateVersions(versions []*model.FSMModel) ([]byte, error) {
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no versions to generate")
+	}
+
+	versions = append([]*model.FSMModel{}, versions...)
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	seen := make(map[int]bool, len(versions))
+	for i := range versions {
+		m, err := prepare(versions[i])
+		if err != nil {
+			return nil, err
+		}
+		versions[i] = m
+		if m.Base == "" {
+			return nil, fmt.Errorf("machine %s is not generated side by side", m.Name)
+		}
+		if m.Base != versions[0].Base || m.Package != versions[0].Package {
+			return nil, fmt.Errorf("machine %s is not a version of %s in package %s", m.Name, versions[0].Base, versions[0].Package)
+		}
+		if seen[m.Version] {
+			return nil, fmt.Errorf("version %d of %s is defined more than once", m.Version, m.Base)
+		}
+		seen[m.Version] = true
+	}
+
+	// The file stands for several specs, so it records none of them
+	latest := versions[len(versions)-1]
+	data := versionsData{
+		Header:   &model.FSMModel{Header: latest.Header},
+		Package:  latest.Package,
+		Base:     latest.Base,
+		Versions: versions,
+	}
+
+	var buf bytes.Buffer
+	if err := g.executeTemplate(g.templates, &buf, "versions.tmpl", data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+func TestGenerateVersions(t *testing.T) {
+	gen, err := NewCodeGenerator()
+	require.NoError(t, err)
+
+	v1 := registryModel(t, "Order", "orders")
+	v1.Version = 1
+	v2 := registryModel(t, "Order", "orders")
+	v2.Version = 2
+	cancelled, err := model.NewState("cancelled")
+	require.NoError(t, err)
+	require.NoError(t, v2.AddState(cancelled))
+	cancel, err := model.NewEvent("cancel")
+	require.NoError(t, err)
+	require.NoError(t, v2.AddEvent(cancel))
+	transition, err := model.NewTransition("start", "cancelled", "cancel")
+	require.NoError(t, err)
+	require.NoError(t, v2.AddTransition(transition))
+	for _, fsm := range []*model.FSMModel{v1, v2} {
+		fsm.Options.Trace = true
+		fsm.Options.Publisher = true
+		require.NoError(t, fsm.UseVersionedName())
+	}
+
+	versions, err := gen.GenerateVersions([]*model.FSMModel{v2, v1})
+	require.NoError(t, err)
+	assert.Equal(t, "order_versions_fsm.gen.go", VersionsOutputName(v1.Base))
+	assert.Contains(t, string(versions), "var OrderVersions = []int{1, 2}\n", "versions are listed oldest first")
+
+	files := map[string][]byte{"orders/" + VersionsOutputName(v1.Base): versions}
+	for _, fsm := range []*model.FSMModel{v1, v2} {
+		code, err := gen.Generate(fsm)
+		require.NoError(t, err)
+		assert.NotContains(t, string(code), "type Logger interface", "the versions share the Logger")
+		files["orders/"+DefaultOutputName(fsm)] = code
+	}
+	assert.Equal(t, "order_v2_fsm.gen.go", DefaultOutputName(v2))
+	files["orders/versions_test.go"] = []byte(`package orders
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	ready := func(context.Context, *OrderV1Context) bool { return true }
+	machines := []Order{
+		NewOrderV1(OrderV1Guards{Ready: ready}, OrderV1Actions{}, WithValidationModeV1(true)),
+		NewOrderV2(OrderV2Guards{}, OrderV2Actions{}, WithLoggerV2(&noopLogger{})),
+	}
+	if machines[0].Version() != 1 || machines[1].Version() != 2 {
+		t.Fatalf("versions %d and %d", machines[0].Version(), machines[1].Version())
+	}
+	if got := machines[1].PermittedEventNames(); !reflect.DeepEqual(got, []string{"finish", "cancel"}) {
+		t.Fatalf("PermittedEventNames() = %v", got)
+	}
+	if err := machines[0].TransitionByName(context.Background(), "cancel"); err == nil || err.Error() != "OrderV1 has no event \"cancel\"" {
+		t.Fatalf("TransitionByName(cancel) = %v", err)
+	}
+	if err := machines[0].TransitionByName(context.Background(), "finish"); err != nil || machines[0].StateName() != "end" {
+		t.Fatalf("TransitionByName(finish) = %v in %s", err, machines[0].StateName())
+	}
+	if err := machines[1].RestoreState("cancelled"); err != nil || machines[1].StateName() != "cancelled" {
+		t.Fatalf("RestoreState(cancelled) = %v in %s", err, machines[1].StateName())
+	}
+}
+`)
+	runGeneratedPackage(t, files)
+
+	unversioned := registryModel(t, "Order", "orders")
+	_, err = gen.GenerateVersions([]*model.FSMModel{v1, unversioned})
+	assert.EqualError(t, err, "machine Order is not generated side by side")
+	_, err = gen.GenerateVersions([]*model.FSMModel{v1, v1})
+	assert.EqualError(t, err, "version 1 of Order is defined more than once")
+	shipment := registryModel(t, "Shipment", "orders")
+	shipment.Version = 3
+	require.NoError(t, shipment.UseVersionedName())
+	_, err = gen.GenerateVersions([]*model.FSMModel{v1, shipment})
+	assert.EqualError(t, err, "machine ShipmentV3 is not a version of Order in package orders")
+}
@@ -0,0 +1,142 @@
+// Package golden tests generated output against golden files: each spec in a
+// directory is generated and compared with the expected output committed beside
+// it. Run the tests with -update to rewrite the golden files after an intended
+// change, and review the diff before committing it.
+//
+// The harness does not register the -update flag, so that it does not clash with
+// the flag of test packages that have golden files of their own. Test packages
+// define it, as is usual:
+//
+//	var update = flag.Bool("update", false, "rewrite golden files")
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+// updating reports whether the -update flag of the test binary is set, which
+// rewrites the golden files instead of comparing against them
+func updating() bool {
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	update, _ := strconv.ParseBool(f.Value.String())
+	return update
+}
+
+// Render produces one output of a spec, such as CodeGenerator.Generate
+type Render func(m *model.FSMModel) ([]byte, error)
+
+// Run generates every *.yaml spec in dir with each of outputs and compares the
+// result with the golden file <spec>.<output>.golden beside the spec, so that
+// testdata/order.yaml rendered as "go" is checked against
+// testdata/order.go.golden. Each output is rendered twice from freshly parsed
+// specs and must come out the same, so that committed output does not churn.
+func Run(t *testing.T, dir string, outputs map[string]Render) {
+	t.Helper()
+	specs, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) == 0 {
+		t.Fatalf("no *.yaml specs in %s", dir)
+	}
+
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, spec := range specs {
+		base := strings.TrimSuffix(filepath.Base(spec), ".yaml")
+		for _, name := range names {
+			render := outputs[name]
+			golden := filepath.Join(dir, base+"."+name+".golden")
+			t.Run(base+"/"+name, func(t *testing.T) {
+				got := renderStable(t, spec, render)
+				check(t, golden, got)
+			})
+		}
+	}
+}
+
+// renderStable renders spec twice and fails unless both outputs are the same
+func renderStable(t *testing.T, spec string, render Render) []byte {
+	t.Helper()
+	var outputs [2][]byte
+	for i := range outputs {
+		m, err := parser.NewYAMLParser().ParseFile(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if outputs[i], err = render(m); err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Fatalf("%s: output differs between runs:\n%s", spec, difference(outputs[0], outputs[1]))
+	}
+	return outputs[0]
+}
+
+// check compares got with the golden file, or rewrites it with -update
+func check(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if updating() {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(golden)
+	if os.IsNotExist(err) {
+		t.Fatalf("%s does not exist; run the test with -update to create it", golden)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, got) {
+		t.Errorf("output does not match %s; run the test with -update if the change is intended:\n%s", golden, difference(want, got))
+	}
+}
+
+// contextLines is the number of equal lines shown before the first difference
+const contextLines = 2
+
+// difference describes the first line where want and got differ, with the lines
+// before it
+func difference(want, got []byte) string {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	i := 0
+	for i < len(wantLines) && i < len(gotLines) && wantLines[i] == gotLines[i] {
+		i++
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "first difference at line %d:\n", i+1)
+	for j := max(i-contextLines, 0); j < i; j++ {
+		fmt.Fprintf(&b, "  %s\n", wantLines[j])
+	}
+	if i < len(wantLines) {
+		fmt.Fprintf(&b, "- %s\n", wantLines[i])
+	}
+	if i < len(gotLines) {
+		fmt.Fprintf(&b, "+ %s\n", gotLines[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package golden
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// update is the flag test packages define, which the harness must not define too
+var update = flag.Bool("update", false, "rewrite golden files")
+
+const spec = `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+  - name: unlocked
+events:
+  - unlock
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+`
+
+func TestRun_Update(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "door.yaml"), []byte(spec), 0o600))
+	outputs := map[string]Render{
+		"txt": func(m *model.FSMModel) ([]byte, error) { return []byte(m.Name + " starts " + m.Initial + "\n"), nil },
+	}
+
+	defer func(old bool) { *update = old }(*update)
+	*update = true
+	Run(t, dir, outputs)
+
+	golden, err := os.ReadFile(filepath.Join(dir, "door.txt.golden"))
+	require.NoError(t, err)
+	assert.Equal(t, "DoorLock starts locked\n", string(golden))
+
+	*update = false
+	Run(t, dir, outputs)
+}
+
+func TestDifference(t *testing.T) {
+	assert.Equal(t, "first difference at line 3:\n  a\n  b\n- c\n+ d\n", difference([]byte("a\nb\nc\n"), []byte("a\nb\nd\n")))
+	assert.Equal(t, "first difference at line 2:\n  a\n- b\n", difference([]byte("a\nb"), []byte("a")))
+}
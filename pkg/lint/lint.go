@@ -0,0 +1,489 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// Severity is how seriously a finding is reported
+type Severity string
+
+const (
+	// SeverityError marks findings that should fail CI
+	SeverityError Severity = "error"
+
+	// SeverityWarning marks findings that are reported without failing CI by default
+	SeverityWarning Severity = "warning"
+
+	// SeverityOff disables a rule
+	SeverityOff Severity = "off"
+)
+
+// ParseSeverity converts a configured severity name to a Severity
+func ParseSeverity(s string) (Severity, error) {
+	switch severity := Severity(s); severity {
+	case SeverityError, SeverityWarning, SeverityOff:
+		return severity, nil
+	default:
+		return "", fmt.Errorf("unknown severity %q (use error, warning, or off)", s)
+	}
+}
+
+// Finding is a problem found in a model
+type Finding struct {
+	// Rule is the ID of the rule that reported the finding
+	Rule string `json:"rule"`
+
+	// Severity is the severity of the finding
+	Severity Severity `json:"severity"`
+
+	// Message describes the problem
+	Message string `json:"message"`
+
+	// State is the state the finding is about, if any
+	State string `json:"state,omitempty"`
+
+	// Event is the event the finding is about, if any
+	Event string `json:"event,omitempty"`
+}
+
+// Rule is a check run against valid models
+type Rule struct {
+	// ID identifies the rule in configuration and reports
+	ID string
+
+	// Severity is the default severity of the rule's findings
+	Severity Severity
+
+	// Description summarizes what the rule reports
+	Description string
+
+	check func(m *model.FSMModel) []Finding
+}
+
+// nondeterministicTransition is the ID of the rule reporting conflicts, which
+// models also fail validation for
+const nondeterministicTransition = "nondeterministic-transition"
+
+// cycleLimit is the most cycles the cycle rule reports for one model
+const cycleLimit = 20
+
+// rules are the lint rules, sorted by ID
+var rules = []Rule{
+	{
+		ID:          "contradictory-guard",
+		Severity:    SeverityError,
+		Description: "the condition of a guard requires contradicting values of a context field, so its transitions never fire",
+		check:       checkContradictoryGuards,
+	},
+	{
+		ID:          "cycle",
+		Severity:    SeverityOff,
+		Description: "runs can return to a state, which workflows that must not loop can forbid",
+		check:       checkCycles,
+	},
+	{
+		ID:          "dead-end-state",
+		Severity:    SeverityWarning,
+		Description: "a reachable state that is not final has no outgoing transitions",
+		check:       checkDeadEndStates,
+	},
+	{
+		ID:          "deprecated-state",
+		Severity:    SeverityWarning,
+		Description: "a transition from a state that is not deprecated leads to a deprecated state",
+		check:       checkDeprecatedStates,
+	},
+	{
+		ID:          "equivalent-states",
+		Severity:    SeverityWarning,
+		Description: "reachable states behave the same and could be merged into one",
+		check:       checkEquivalentStates,
+	},
+	{
+		ID:          "final-state-exit",
+		Severity:    SeverityError,
+		Description: "a final state has outgoing transitions",
+		check:       checkFinalStateExits,
+	},
+	{
+		ID:          nondeterministicTransition,
+		Severity:    SeverityError,
+		Description: "a state has several unguarded transitions, or several with the same guard, on one event",
+		check:       checkNondeterministicTransitions,
+	},
+	{
+		ID:          "property-violation",
+		Severity:    SeverityError,
+		Description: "a run of the machine violates a never, never_followed_by, or eventually property",
+		check:       checkProperties,
+	},
+	{
+		ID:          "shadowed-transition",
+		Severity:    SeverityError,
+		Description: "a transition follows an unguarded transition, or one whose guard condition its own implies, on the same state and event, so it never fires",
+		check:       checkShadowedTransitions,
+	},
+	{
+		ID:          "trap-cycle",
+		Severity:    SeverityWarning,
+		Description: "runs can enter a cycle of states from which no final state is reachable",
+		check:       checkTrapCycles,
+	},
+	{
+		ID:          "unhandled-event",
+		Severity:    SeverityOff,
+		Description: "a reachable state that is not final neither handles nor ignores the event",
+		check:       checkUnhandledEvents,
+	},
+	{
+		ID:          "unreachable-state",
+		Severity:    SeverityWarning,
+		Description: "no sequence of transitions leads to the state from the initial state",
+		check:       checkUnreachableStates,
+	},
+	{
+		ID:          "unused-event",
+		Severity:    SeverityWarning,
+		Description: "the event does not trigger any transition",
+		check:       checkUnusedEvents,
+	},
+}
+
+// Rules returns the lint rules, sorted by ID
+func Rules() []Rule {
+	return append([]Rule(nil), rules...)
+}
+
+// CheckSeverities rejects severities configured for rules that do not exist
+func CheckSeverities(severities map[string]Severity) error {
+	for id := range severities {
+		if !isRule(id) {
+			ids := make([]string, 0, len(rules))
+			for _, rule := range rules {
+				ids = append(ids, rule.ID)
+			}
+			return fmt.Errorf("unknown lint rule %q (use %s)", id, strings.Join(ids, ", "))
+		}
+	}
+	return nil
+}
+
+// Run checks a valid model against every rule that is not turned off. Severities
+// override the default severity of rules by ID.
+func Run(m *model.FSMModel, severities map[string]Severity) ([]Finding, error) {
+	if err := CheckSeverities(severities); err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, rule := range rules {
+		findings = append(findings, rule.report(rule.check(m), severities)...)
+	}
+	return findings, nil
+}
+
+// Nondeterminism returns the nondeterministic-transition findings of a model that
+// failed validation because of conflicts, so they are reported like the findings
+// of Run
+func Nondeterminism(err *model.NondeterminismError, severities map[string]Severity) ([]Finding, error) {
+	if err := CheckSeverities(severities); err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.ID == nondeterministicTransition {
+			return rule.report(conflictFindings(err.Conflicts), severities), nil
+		}
+	}
+	return nil, nil
+}
+
+// report sets the rule and configured severity of the rule's findings, dropping
+// them when the rule is turned off
+func (r Rule) report(findings []Finding, severities map[string]Severity) []Finding {
+	severity := r.Severity
+	if configured, ok := severities[r.ID]; ok {
+		severity = configured
+	}
+	if severity == SeverityOff {
+		return nil
+	}
+
+	for i := range findings {
+		findings[i].Rule = r.ID
+		findings[i].Severity = severity
+	}
+	return findings
+}
+
+// isRule reports whether id names a lint rule
+func isRule(id string) bool {
+	for _, rule := range rules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func checkContradictoryGuards(m *model.FSMModel) []Finding {
+	var findings []Finding
+	for _, t := range m.Transitions {
+		a, b, found := m.GuardPredicate(t.Guard).Contradiction()
+		if !found {
+			continue
+		}
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("transition from %q to %q on %q never fires: guard %q requires %s and %s", t.From, t.To, t.Event, t.Guard, a, b),
+			State:   t.From,
+			Event:   t.Event,
+		})
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].State < findings[j].State })
+	return findings
+}
+
+func checkCycles(m *model.FSMModel) []Finding {
+	graph := model.NewStateGraph(m)
+	if err := graph.Build(); err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, cycle := range graph.FindCycles(cycleLimit) {
+		findings = append(findings, Finding{
+			Message: "runs can loop: " + model.FormatPath(cycle[0].From, cycle),
+			State:   cycle[0].From,
+		})
+	}
+	return findings
+}
+
+func checkDeadEndStates(m *model.FSMModel) []Finding {
+	unreachable := make(map[string]bool)
+	for _, name := range m.GetUnreachableStateNames() {
+		unreachable[name] = true
+	}
+
+	var findings []Finding
+	for _, state := range m.GetStatesSlice() {
+		if state.Final || unreachable[state.Name] || len(m.GetTransitionsFrom(state.Name)) > 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("state %q has no outgoing transitions; mark it final if runs end there", state.Name),
+			State:   state.Name,
+		})
+	}
+	return findings
+}
+
+func checkDeprecatedStates(m *model.FSMModel) []Finding {
+	var findings []Finding
+	for _, t := range m.Transitions {
+		to, from := m.GetState(t.To), m.GetState(t.From)
+		// Transitions among deprecated states only drain existing runs
+		if to == nil || !to.Deprecated || from == nil || from.Deprecated {
+			continue
+		}
+		message := fmt.Sprintf("transition from %q on %q leads to deprecated state %q", t.From, t.Event, t.To)
+		if to.DeprecationReason != "" {
+			message += ": " + to.DeprecationReason
+		}
+		findings = append(findings, Finding{
+			Message: message,
+			State:   t.From,
+			Event:   t.Event,
+		})
+	}
+	return findings
+}
+
+func checkEquivalentStates(m *model.FSMModel) []Finding {
+	graph := model.NewStateGraph(m)
+	if err := graph.Build(); err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, group := range graph.EquivalentStates() {
+		names := make([]string, len(group))
+		for i, name := range group {
+			names[i] = fmt.Sprintf("%q", name)
+		}
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("states %s have the same transitions to equivalent states; consider merging them", strings.Join(names, ", ")),
+			State:   group[0],
+		})
+	}
+	return findings
+}
+
+func checkFinalStateExits(m *model.FSMModel) []Finding {
+	var findings []Finding
+	for _, name := range m.GetFinalStateNames() {
+		for _, t := range m.GetTransitionsFrom(name) {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("final state %q has a transition to %q on %q", name, t.To, t.Event),
+				State:   name,
+				Event:   t.Event,
+			})
+		}
+	}
+	return findings
+}
+
+func checkNondeterministicTransitions(m *model.FSMModel) []Finding {
+	graph := model.NewStateGraph(m)
+	if err := graph.Build(); err != nil {
+		return nil
+	}
+
+	return conflictFindings(graph.FindNondeterminism())
+}
+
+// conflictFindings describes each conflict as a finding
+func conflictFindings(conflicts []model.Conflict) []Finding {
+	var findings []Finding
+	for _, conflict := range conflicts {
+		findings = append(findings, Finding{
+			Message: conflict.String() + "; the generated machine cannot choose between them",
+			State:   conflict.State,
+			Event:   conflict.Event,
+		})
+	}
+	return findings
+}
+
+func checkProperties(m *model.FSMModel) []Finding {
+	graph := model.NewStateGraph(m)
+	if err := graph.Build(); err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, p := range m.Properties {
+		counterexample := graph.CheckProperty(p)
+		if counterexample == nil {
+			continue
+		}
+		message := fmt.Sprintf("property %q is violated by the run %s", p.Name, counterexample)
+		if p.Kind == model.PropertyEventually {
+			message += fmt.Sprintf(", which never reaches %q", p.Target)
+		}
+		findings = append(findings, Finding{Message: message})
+	}
+	return findings
+}
+
+func checkShadowedTransitions(m *model.FSMModel) []Finding {
+	var findings []Finding
+	earlier := make(map[[2]string][]*model.Transition)
+	for _, t := range m.Transitions {
+		key := [2]string{t.From, t.Event}
+		if reason := shadowedBy(m, t, earlier[key]); reason != "" {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("transition from %q to %q on %q never fires because %s", t.From, t.To, t.Event, reason),
+				State:   t.From,
+				Event:   t.Event,
+			})
+		}
+		earlier[key] = append(earlier[key], t)
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].State < findings[j].State })
+	return findings
+}
+
+// shadowedBy returns why one of the earlier transitions on the same state and
+// event always fires instead of t, or "" when t can fire
+func shadowedBy(m *model.FSMModel, t *model.Transition, earlier []*model.Transition) string {
+	predicate := m.GuardPredicate(t.Guard)
+	if _, _, found := predicate.Contradiction(); found {
+		// Reported by contradictory-guard
+		predicate = nil
+	}
+
+	for _, e := range earlier {
+		switch {
+		case e.Guard == "" && t.Guard == "":
+			// Reported by nondeterministic-transition
+			return ""
+		case e.Guard == "":
+			return fmt.Sprintf("the unguarded transition to %q is declared first", e.To)
+		case predicate != nil && e.Guard != t.Guard:
+			if first := m.GuardPredicate(e.Guard); first != nil && predicate.Implies(first) {
+				return fmt.Sprintf("its guard %q implies guard %q of the transition to %q declared first", t.Guard, e.Guard, e.To)
+			}
+		}
+	}
+	return ""
+}
+
+func checkTrapCycles(m *model.FSMModel) []Finding {
+	graph := model.NewStateGraph(m)
+	if err := graph.Build(); err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, trap := range graph.FindTraps() {
+		findings = append(findings, Finding{
+			Message: trap.String(),
+			State:   trap.States[0],
+		})
+	}
+	return findings
+}
+
+func checkUnhandledEvents(m *model.FSMModel) []Finding {
+	graph := model.NewStateGraph(m)
+	if err := graph.Build(); err != nil {
+		return nil
+	}
+
+	var findings []Finding
+	for _, pair := range graph.UnhandledPairs() {
+		state := m.GetState(pair.State)
+		if state.Final || !graph.IsReachable(state.Name) || state.Ignores(pair.Event) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("state %q does not handle event %q; add a transition or list the event under ignore", pair.State, pair.Event),
+			State:   pair.State,
+			Event:   pair.Event,
+		})
+	}
+	return findings
+}
+
+func checkUnreachableStates(m *model.FSMModel) []Finding {
+	var findings []Finding
+	for _, name := range m.GetUnreachableStateNames() {
+		findings = append(findings, Finding{
+			Message: fmt.Sprintf("state %q is not reachable from the initial state %q", name, m.Initial),
+			State:   name,
+		})
+	}
+	return findings
+}
+
+func checkUnusedEvents(m *model.FSMModel) []Finding {
+	used := make(map[string]bool)
+	for _, t := range m.Transitions {
+		used[t.Event] = true
+	}
+
+	var findings []Finding
+	for _, event := range m.GetEventsSlice() {
+		if !used[event.Name] {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("event %q does not trigger any transition", event.Name),
+				Event:   event.Name,
+			})
+		}
+	}
+	return findings
+}
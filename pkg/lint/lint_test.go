@@ -0,0 +1,324 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+const orderSpec = `
+machine:
+  name: OrderStateMachine
+  initial: pending
+
+states:
+  - name: pending
+  - name: approved
+  - name: shipped
+    final: true
+  - name: archived
+  - name: lost
+
+events:
+  - approve
+  - ship
+  - recall
+  - audit
+
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+  - from: pending
+    to: shipped
+    on: approve
+    guard: isPrepaid
+  - from: approved
+    to: shipped
+    on: ship
+  - from: shipped
+    to: pending
+    on: recall
+  - from: lost
+    to: archived
+    on: ship
+`
+
+func parseSpec(t *testing.T, spec string) *model.FSMModel {
+	t.Helper()
+	fsm, err := parser.NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	return fsm
+}
+
+func TestRun(t *testing.T) {
+	findings, err := Run(parseSpec(t, orderSpec), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Finding{
+		{Rule: "final-state-exit", Severity: SeverityError, Message: `final state "shipped" has a transition to "pending" on "recall"`, State: "shipped", Event: "recall"},
+		{Rule: "shadowed-transition", Severity: SeverityError, Message: `transition from "pending" to "shipped" on "approve" never fires because the unguarded transition to "approved" is declared first`, State: "pending", Event: "approve"},
+		{Rule: "unreachable-state", Severity: SeverityWarning, Message: `state "archived" is not reachable from the initial state "pending"`, State: "archived"},
+		{Rule: "unreachable-state", Severity: SeverityWarning, Message: `state "lost" is not reachable from the initial state "pending"`, State: "lost"},
+		{Rule: "unused-event", Severity: SeverityWarning, Message: `event "audit" does not trigger any transition`, Event: "audit"},
+	}, findings)
+}
+
+func TestRun_DeadEndState(t *testing.T) {
+	spec := strings.Replace(orderSpec, "    final: true\n", "", 1)
+	spec = strings.Replace(spec, "  - from: shipped\n    to: pending\n    on: recall\n", "", 1)
+
+	findings, err := Run(parseSpec(t, spec), map[string]Severity{"unreachable-state": SeverityOff, "unused-event": SeverityOff, "shadowed-transition": SeverityWarning})
+	require.NoError(t, err)
+
+	assert.Equal(t, []Finding{
+		{Rule: "dead-end-state", Severity: SeverityWarning, Message: `state "shipped" has no outgoing transitions; mark it final if runs end there`, State: "shipped"},
+		{Rule: "shadowed-transition", Severity: SeverityWarning, Message: `transition from "pending" to "shipped" on "approve" never fires because the unguarded transition to "approved" is declared first`, State: "pending", Event: "approve"},
+	}, findings)
+}
+
+func TestRun_DeprecatedState(t *testing.T) {
+	spec := strings.Replace(orderSpec, "  - name: approved\n", "  - name: approved\n    deprecated: Orders ship on payment\n", 1)
+	spec = strings.Replace(spec, "  - name: archived\n  - name: lost\n", "  - name: archived\n    deprecated: true\n  - name: lost\n    deprecated: true\n", 1)
+
+	findings, err := Run(parseSpec(t, spec), map[string]Severity{"final-state-exit": SeverityOff, "shadowed-transition": SeverityOff, "unreachable-state": SeverityOff, "unused-event": SeverityOff})
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "deprecated-state", Severity: SeverityWarning, Message: `transition from "pending" on "approve" leads to deprecated state "approved": Orders ship on payment`, State: "pending", Event: "approve"},
+	}, findings, "transitions between deprecated states are not reported")
+}
+
+func TestRun_RejectsUnknownRules(t *testing.T) {
+	_, err := Run(parseSpec(t, orderSpec), map[string]Severity{"unreachable-states": SeverityOff})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown lint rule "unreachable-states"`)
+}
+
+func TestParseSeverity(t *testing.T) {
+	severity, err := ParseSeverity("warning")
+	require.NoError(t, err)
+	assert.Equal(t, SeverityWarning, severity)
+
+	_, err = ParseSeverity("fatal")
+	assert.EqualError(t, err, `unknown severity "fatal" (use error, warning, or off)`)
+}
+
+func TestRules_SortedByID(t *testing.T) {
+	ids := make([]string, 0, len(Rules()))
+	for _, rule := range Rules() {
+		ids = append(ids, rule.ID)
+		assert.NotEmpty(t, rule.Description)
+	}
+	assert.IsIncreasing(t, ids)
+}
+
+func TestRun_NondeterministicTransition(t *testing.T) {
+	m := parseSpec(t, orderSpec)
+	require.NoError(t, m.AddTransition(&model.Transition{From: "approved", To: "pending", Event: "ship"}))
+
+	findings, err := Run(m, map[string]Severity{"unreachable-state": SeverityOff, "unused-event": SeverityOff, "final-state-exit": SeverityOff})
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "nondeterministic-transition", Severity: SeverityError, Message: `state "approved" has 2 unguarded transitions on "ship" (to "shipped", "pending"); the generated machine cannot choose between them`, State: "approved", Event: "ship"},
+		{Rule: "shadowed-transition", Severity: SeverityError, Message: `transition from "pending" to "shipped" on "approve" never fires because the unguarded transition to "approved" is declared first`, State: "pending", Event: "approve"},
+	}, findings, "An unguarded duplicate should be reported as nondeterministic, not shadowed")
+
+	var nondeterminism *model.NondeterminismError
+	require.ErrorAs(t, m.Validate(), &nondeterminism)
+	findings, err = Nondeterminism(nondeterminism, map[string]Severity{"nondeterministic-transition": SeverityWarning})
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, SeverityWarning, findings[0].Severity)
+	assert.Equal(t, "approved", findings[0].State)
+}
+
+func TestRun_UnhandledEvent(t *testing.T) {
+	spec := strings.Replace(orderSpec, "  - name: approved\n", "  - name: approved\n    ignore: [approve, recall]\n", 1)
+	severities := map[string]Severity{"unhandled-event": SeverityWarning, "unreachable-state": SeverityOff, "unused-event": SeverityOff}
+
+	findings, err := Run(parseSpec(t, spec), severities)
+	require.NoError(t, err)
+
+	var unhandled []Finding
+	for _, f := range findings {
+		if f.Rule == "unhandled-event" {
+			unhandled = append(unhandled, f)
+		}
+	}
+	assert.Equal(t, []Finding{
+		{Rule: "unhandled-event", Severity: SeverityWarning, Message: `state "approved" does not handle event "audit"; add a transition or list the event under ignore`, State: "approved", Event: "audit"},
+		{Rule: "unhandled-event", Severity: SeverityWarning, Message: `state "pending" does not handle event "audit"; add a transition or list the event under ignore`, State: "pending", Event: "audit"},
+		{Rule: "unhandled-event", Severity: SeverityWarning, Message: `state "pending" does not handle event "recall"; add a transition or list the event under ignore`, State: "pending", Event: "recall"},
+		{Rule: "unhandled-event", Severity: SeverityWarning, Message: `state "pending" does not handle event "ship"; add a transition or list the event under ignore`, State: "pending", Event: "ship"},
+	}, unhandled, "Final, unreachable, and ignored pairs should not be reported")
+
+	findings, err = Run(parseSpec(t, spec), nil)
+	require.NoError(t, err)
+	for _, f := range findings {
+		assert.NotEqual(t, "unhandled-event", f.Rule, "The rule should be off by default")
+	}
+}
+
+func TestRun_TrapCycle(t *testing.T) {
+	spec := strings.Replace(orderSpec, "  - from: lost\n", "  - from: approved\n    to: pending\n    on: recall\n  - from: lost\n", 1)
+	spec = strings.Replace(spec, "  - from: approved\n    to: shipped\n    on: ship\n", "", 1)
+	severities := map[string]Severity{"final-state-exit": SeverityOff, "shadowed-transition": SeverityOff, "unreachable-state": SeverityOff, "unused-event": SeverityOff}
+
+	findings, err := Run(parseSpec(t, spec), severities)
+	require.NoError(t, err)
+	assert.Empty(t, findings, "The guarded transition to shipped should let runs finish")
+
+	spec = strings.Replace(spec, "    guard: isPrepaid\n", "", 1)
+	spec = strings.Replace(spec, "  - from: pending\n    to: shipped\n    on: approve\n", "", 1)
+	findings, err = Run(parseSpec(t, spec), severities)
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "trap-cycle", Severity: SeverityWarning, Message: `no final state is reachable from "approved", "pending" (cycle approved --recall--> pending --approve--> approved)`, State: "approved"},
+	}, findings)
+}
+
+func TestRun_Cycle(t *testing.T) {
+	m := parseSpec(t, orderSpec)
+	severities := map[string]Severity{"cycle": SeverityError}
+	for _, rule := range Rules() {
+		if rule.ID != "cycle" {
+			severities[rule.ID] = SeverityOff
+		}
+	}
+
+	findings, err := Run(m, severities)
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "cycle", Severity: SeverityError, Message: `runs can loop: approved --ship--> shipped --recall--> pending --approve--> approved`, State: "approved"},
+		{Rule: "cycle", Severity: SeverityError, Message: `runs can loop: pending --approve [isPrepaid]--> shipped --recall--> pending`, State: "pending"},
+	}, findings)
+}
+
+func TestRun_PropertyViolation(t *testing.T) {
+	spec := orderSpec + `
+properties:
+  - name: approves_once
+    never: [approved, approve]
+  - name: ships
+    eventually: shipped
+  - name: no_reship
+    kind: never_followed_by
+    state: shipped
+    then: approved
+`
+	severities := map[string]Severity{"final-state-exit": SeverityOff, "shadowed-transition": SeverityOff, "unreachable-state": SeverityOff, "unused-event": SeverityOff}
+
+	findings, err := Run(parseSpec(t, spec), severities)
+	require.NoError(t, err)
+	assert.Empty(t, findings, "Runs end in the final shipped state")
+
+	spec = strings.Replace(spec, "    final: true\n", "", 1)
+	spec = strings.Replace(spec, "  - name: ships\n    eventually: shipped\n", "  - name: audited\n    eventually: audit\n", 1)
+	findings, err = Run(parseSpec(t, spec), severities)
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "property-violation", Severity: SeverityError, Message: `property "approves_once" is violated by the run pending --approve--> approved --ship--> shipped --recall--> pending --approve--> approved`},
+		{Rule: "property-violation", Severity: SeverityError, Message: `property "audited" is violated by the run pending, then repeats pending --approve--> approved --ship--> shipped --recall--> pending, which never reaches "audit"`},
+		{Rule: "property-violation", Severity: SeverityError, Message: `property "no_reship" is violated by the run pending --approve [isPrepaid]--> shipped --recall--> pending --approve--> approved`},
+	}, findings)
+}
+
+func TestRun_EquivalentStates(t *testing.T) {
+	spec := strings.Replace(orderSpec, "  - name: archived\n", "  - name: archived\n  - name: held\n", 1)
+	spec = strings.Replace(spec, "  - from: approved\n", "  - from: pending\n    to: held\n    on: recall\n  - from: held\n    to: shipped\n    on: ship\n  - from: approved\n", 1)
+
+	findings, err := Run(parseSpec(t, spec), map[string]Severity{"final-state-exit": SeverityOff, "shadowed-transition": SeverityOff, "unreachable-state": SeverityOff})
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "equivalent-states", Severity: SeverityWarning, Message: `states "approved", "held" have the same transitions to equivalent states; consider merging them`, State: "approved"},
+		{Rule: "unused-event", Severity: SeverityWarning, Message: `event "audit" does not trigger any transition`, Event: "audit"},
+	}, findings)
+}
+
+const paymentSpec = `
+machine:
+  name: Payment
+  initial: pending
+
+states:
+  - name: pending
+  - name: captured
+    final: true
+  - name: review
+  - name: refunded
+    final: true
+
+events:
+  - capture
+  - refund
+  - approve
+
+context:
+  - name: amount
+    type: int
+  - name: disputed
+    type: bool
+
+guards:
+  - name: isPositive
+    when: amount > 0
+  - name: isLarge
+    when: amount > 10000
+  - name: isTiny
+    when: amount > 0 && amount < 1
+  - name: isUndisputed
+    when: "!disputed"
+  - name: isDisputedRefund
+    when: disputed && !disputed
+
+transitions:
+  - from: pending
+    to: captured
+    on: capture
+    guard: isPositive
+  - from: pending
+    to: review
+    on: capture
+    guard: isLarge
+  - from: pending
+    to: refunded
+    on: refund
+    guard: isTiny
+  - from: review
+    to: captured
+    on: approve
+    guard: isUndisputed
+  - from: review
+    to: refunded
+    on: refund
+    guard: isDisputedRefund
+`
+
+func TestRun_ContradictoryGuard(t *testing.T) {
+	findings, err := Run(parseSpec(t, paymentSpec), map[string]Severity{"shadowed-transition": SeverityOff})
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "contradictory-guard", Severity: SeverityError, Message: `transition from "pending" to "refunded" on "refund" never fires: guard "isTiny" requires amount > 0 and amount < 1`, State: "pending", Event: "refund"},
+		{Rule: "contradictory-guard", Severity: SeverityError, Message: `transition from "review" to "refunded" on "refund" never fires: guard "isDisputedRefund" requires disputed and !disputed`, State: "review", Event: "refund"},
+	}, findings)
+}
+
+func TestRun_ShadowedByImpliedGuard(t *testing.T) {
+	findings, err := Run(parseSpec(t, paymentSpec), map[string]Severity{"contradictory-guard": SeverityOff})
+	require.NoError(t, err)
+	assert.Equal(t, []Finding{
+		{Rule: "shadowed-transition", Severity: SeverityError, Message: `transition from "pending" to "review" on "capture" never fires because its guard "isLarge" implies guard "isPositive" of the transition to "captured" declared first`, State: "pending", Event: "capture"},
+	}, findings)
+
+	// Declared the other way around, large payments are reviewed and the rest captured
+	swapped := strings.Replace(paymentSpec, "    to: captured\n    on: capture\n    guard: isPositive\n  - from: pending\n    to: review\n    on: capture\n    guard: isLarge\n",
+		"    to: review\n    on: capture\n    guard: isLarge\n  - from: pending\n    to: captured\n    on: capture\n    guard: isPositive\n", 1)
+	require.NotEqual(t, paymentSpec, swapped)
+	findings, err = Run(parseSpec(t, swapped), map[string]Severity{"contradictory-guard": SeverityOff})
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
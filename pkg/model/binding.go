@@ -0,0 +1,147 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BindingORM is the ORM whose model a binding wraps
+type BindingORM string
+
+const (
+	// BindingGorm binds a gorm model
+	BindingGorm BindingORM = "gorm"
+
+	// BindingEnt binds an ent entity
+	BindingEnt BindingORM = "ent"
+)
+
+// BindingStorage is how the state field of a bound model holds the state
+type BindingStorage string
+
+const (
+	// BindingStorageName stores the state name, for string fields (the default)
+	BindingStorageName BindingStorage = "name"
+
+	// BindingStorageValue stores the integer value of the state, for integer fields
+	BindingStorageValue BindingStorage = "value"
+)
+
+// bindingTypePattern matches a possibly package-qualified Go type name
+var bindingTypePattern = regexp.MustCompile(`^(?:([a-zA-Z_][a-zA-Z0-9_]*)\.)?[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Binding binds the machine to the state field of an ORM model, for which a
+// wrapper loading, transitioning, and saving the model is generated
+type Binding struct {
+	// ORM is the ORM of the model
+	ORM BindingORM
+
+	// Model is the Go type of the gorm model or ent entity, e.g. "Order" or "ent.Order"
+	Model string
+
+	// Field is the exported field of the model holding the state
+	Field string
+
+	// Type is the Go type of Field, e.g. "string" or "order.Status"; empty means string
+	Type string
+
+	// Storage is whether Field holds state names or values; empty means BindingStorageName
+	Storage BindingStorage
+
+	// ID is the Go type of the primary key of ent entities; empty means int
+	ID string
+}
+
+// TypeOrDefault returns the Go type of the state field, defaulting to string
+func (b *Binding) TypeOrDefault() string {
+	if b.Type == "" {
+		return "string"
+	}
+	return b.Type
+}
+
+// StorageOrDefault returns how the state field holds the state, defaulting to BindingStorageName
+func (b *Binding) StorageOrDefault() BindingStorage {
+	if b.Storage == "" {
+		return BindingStorageName
+	}
+	return b.Storage
+}
+
+// IDOrDefault returns the Go type of the primary key of ent entities, defaulting to int
+func (b *Binding) IDOrDefault() string {
+	if b.ID == "" {
+		return "int"
+	}
+	return b.ID
+}
+
+// ModelName returns the name of the model type without its package qualifier
+func (b *Binding) ModelName() string {
+	return b.Model[strings.LastIndex(b.Model, ".")+1:]
+}
+
+// ModelPackage returns the package qualifier of the model type, or "" for local types
+func (b *Binding) ModelPackage() string {
+	return typePackage(b.Model)
+}
+
+// packages returns the package qualifiers of the types the binding refers to
+func (b *Binding) packages() []string {
+	var pkgs []string
+	for _, typ := range []string{b.Model, b.TypeOrDefault(), b.IDOrDefault()} {
+		if pkg := typePackage(typ); pkg != "" {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+// validate checks the binding against the name and imports of the machine
+func (b *Binding) validate(machine string, imports []string) error {
+	switch b.ORM {
+	case BindingGorm, BindingEnt:
+	default:
+		return fmt.Errorf("orm %q is not supported (use gorm or ent)", b.ORM)
+	}
+	if !domainTypePattern.MatchString(b.Model) || strings.HasPrefix(b.Model, "*") {
+		return fmt.Errorf("model %q is not an exported Go type name such as Order or ent.Order", b.Model)
+	}
+	if b.Model == machine {
+		return fmt.Errorf("model %q has the name of the machine", b.Model)
+	}
+	if b.ORM == BindingEnt && b.ModelPackage() == "" {
+		return fmt.Errorf("ent model %q must be qualified with the ent package, such as ent.Order", b.Model)
+	}
+	if first, _ := utf8.DecodeRuneInString(b.Field); !validNamePattern.MatchString(b.Field) || !unicode.IsUpper(first) {
+		return fmt.Errorf("field %q is not an exported Go field name", b.Field)
+	}
+	if !bindingTypePattern.MatchString(b.TypeOrDefault()) {
+		return fmt.Errorf("type %q is not a Go type name", b.Type)
+	}
+	if !bindingTypePattern.MatchString(b.IDOrDefault()) {
+		return fmt.Errorf("id %q is not a Go type name", b.ID)
+	}
+	switch b.StorageOrDefault() {
+	case BindingStorageName, BindingStorageValue:
+	default:
+		return fmt.Errorf("storage %q is not supported (use name or value)", b.Storage)
+	}
+	for _, pkg := range b.packages() {
+		if !hasImportNamed(imports, pkg) {
+			return fmt.Errorf("package %q is not listed in imports", pkg)
+		}
+	}
+	return nil
+}
+
+// typePackage returns the package qualifier of a Go type name, or ""
+func typePackage(typ string) string {
+	if m := bindingTypePattern.FindStringSubmatch(strings.TrimPrefix(typ, "*")); m != nil {
+		return m[1]
+	}
+	return ""
+}
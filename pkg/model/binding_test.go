@@ -0,0 +1,98 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinding_Validate(t *testing.T) {
+	imports := []string{"github.com/acme/shop/ent", "github.com/acme/shop/ent/order"}
+
+	tests := []struct {
+		name    string
+		binding Binding
+		wantErr string
+	}{
+		{
+			name:    "gorm model",
+			binding: Binding{ORM: BindingGorm, Model: "Order", Field: "Status"},
+		},
+		{
+			name:    "ent entity with an enum field",
+			binding: Binding{ORM: BindingEnt, Model: "ent.Order", Field: "Status", Type: "order.Status"},
+		},
+		{
+			name:    "integer values",
+			binding: Binding{ORM: BindingGorm, Model: "Order", Field: "State", Type: "int16", Storage: BindingStorageValue},
+		},
+		{
+			name:    "unsupported orm",
+			binding: Binding{ORM: "sqlx", Model: "Order", Field: "Status"},
+			wantErr: `orm "sqlx" is not supported`,
+		},
+		{
+			name:    "pointer model",
+			binding: Binding{ORM: BindingGorm, Model: "*Order", Field: "Status"},
+			wantErr: "is not an exported Go type name",
+		},
+		{
+			name:    "model named like the machine",
+			binding: Binding{ORM: BindingGorm, Model: "OrderStateMachine", Field: "Status"},
+			wantErr: "has the name of the machine",
+		},
+		{
+			name:    "unqualified ent entity",
+			binding: Binding{ORM: BindingEnt, Model: "Order", Field: "Status"},
+			wantErr: "must be qualified with the ent package",
+		},
+		{
+			name:    "unexported field",
+			binding: Binding{ORM: BindingGorm, Model: "Order", Field: "status"},
+			wantErr: `field "status" is not an exported Go field name`,
+		},
+		{
+			name:    "unknown storage",
+			binding: Binding{ORM: BindingGorm, Model: "Order", Field: "Status", Storage: "json"},
+			wantErr: `storage "json" is not supported`,
+		},
+		{
+			name:    "package not imported",
+			binding: Binding{ORM: BindingEnt, Model: "ent.Order", Field: "Status", ID: "uuid.UUID"},
+			wantErr: `package "uuid" is not listed in imports`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.binding.validate("OrderStateMachine", imports)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestBinding_Defaults(t *testing.T) {
+	b := &Binding{ORM: BindingEnt, Model: "ent.Order", Field: "Status"}
+	assert.Equal(t, "string", b.TypeOrDefault())
+	assert.Equal(t, BindingStorageName, b.StorageOrDefault())
+	assert.Equal(t, "int", b.IDOrDefault())
+	assert.Equal(t, "Order", b.ModelName())
+	assert.Equal(t, "ent", b.ModelPackage())
+}
+
+func TestFSMModel_Binding(t *testing.T) {
+	fsm := newShippingFSM()
+	fsm.Imports = []string{"github.com/acme/shop/billing", "github.com/acme/shop/ent"}
+	fsm.Binding = &Binding{ORM: BindingEnt, Model: "ent.Order", Field: "Status"}
+	require.NoError(t, fsm.Validate())
+	assert.Equal(t, []string{"github.com/acme/shop/ent"}, fsm.GetBindingImports(),
+		"Only imports referenced by the binding are generated")
+
+	fsm.Binding.Field = ""
+	assert.ErrorContains(t, fsm.Validate(), "invalid binding")
+}
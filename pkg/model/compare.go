@@ -0,0 +1,202 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Change is how an element differs between two versions of a machine
+type Change string
+
+const (
+	// Unchanged marks an element both versions define alike
+	Unchanged Change = ""
+
+	// Added marks an element only the new version defines
+	Added Change = "added"
+
+	// Removed marks an element only the old version defines
+	Removed Change = "removed"
+
+	// Modified marks an element both versions define, but differently
+	Modified Change = "modified"
+)
+
+// StateChange is a state of either version and how it changed
+type StateChange struct {
+	*State
+	Change Change
+
+	// Details describe a modification, e.g. "entry action a -> b"
+	Details []string
+}
+
+// EventChange is an event of either version and how it changed
+type EventChange struct {
+	Name   string
+	Change Change
+}
+
+// TransitionChange is a transition of either version and how it changed. Transitions
+// are matched by source, event, guard, and target, so a retargeted transition is
+// removed and added; a modified one only changed its action.
+type TransitionChange struct {
+	*Transition
+	Change Change
+
+	// Details describe a modification, e.g. "action a -> b"
+	Details []string
+}
+
+// MachineDiff is the difference between two versions of a machine
+type MachineDiff struct {
+	// OldInitial and NewInitial are the initial states of both versions
+	OldInitial string
+	NewInitial string
+
+	// States are the states of both versions, sorted by name
+	States []StateChange
+
+	// Events are the events of both versions, sorted by name
+	Events []EventChange
+
+	// Transitions are the transitions of the new version in declaration order,
+	// followed by those the old version removed in theirs
+	Transitions []TransitionChange
+}
+
+// Compare returns the difference between the old and updated versions of a machine
+func Compare(old, updated *FSMModel) *MachineDiff {
+	d := &MachineDiff{OldInitial: old.Initial, NewInitial: updated.Initial}
+
+	for _, name := range unionKeys(old.States, updated.States) {
+		before, after := old.States[name], updated.States[name]
+		switch {
+		case before == nil:
+			d.States = append(d.States, StateChange{State: after, Change: Added})
+		case after == nil:
+			d.States = append(d.States, StateChange{State: before, Change: Removed})
+		default:
+			details := stateDetails(before, after)
+			change := Unchanged
+			if len(details) > 0 {
+				change = Modified
+			}
+			d.States = append(d.States, StateChange{State: after, Change: change, Details: details})
+		}
+	}
+
+	for _, name := range unionKeys(old.Events, updated.Events) {
+		change := Unchanged
+		switch {
+		case old.Events[name] == nil:
+			change = Added
+		case updated.Events[name] == nil:
+			change = Removed
+		}
+		d.Events = append(d.Events, EventChange{Name: name, Change: change})
+	}
+
+	before := make(map[transitionKey]*Transition, len(old.Transitions))
+	for _, t := range old.Transitions {
+		before[keyOf(t)] = t
+	}
+	kept := make(map[transitionKey]bool, len(updated.Transitions))
+	for _, t := range updated.Transitions {
+		prev, ok := before[keyOf(t)]
+		if !ok {
+			d.Transitions = append(d.Transitions, TransitionChange{Transition: t, Change: Added})
+			continue
+		}
+		kept[keyOf(t)] = true
+
+		var details []string
+		if prev.Action != t.Action {
+			details = append(details, "action "+changeOf(prev.Action, t.Action))
+		}
+		change := Unchanged
+		if len(details) > 0 {
+			change = Modified
+		}
+		d.Transitions = append(d.Transitions, TransitionChange{Transition: t, Change: change, Details: details})
+	}
+	for _, t := range old.Transitions {
+		if !kept[keyOf(t)] {
+			d.Transitions = append(d.Transitions, TransitionChange{Transition: t, Change: Removed})
+		}
+	}
+	return d
+}
+
+// Empty reports whether both versions define the same machine
+func (d *MachineDiff) Empty() bool {
+	if d.OldInitial != d.NewInitial {
+		return false
+	}
+	for _, s := range d.States {
+		if s.Change != Unchanged {
+			return false
+		}
+	}
+	for _, e := range d.Events {
+		if e.Change != Unchanged {
+			return false
+		}
+	}
+	for _, t := range d.Transitions {
+		if t.Change != Unchanged {
+			return false
+		}
+	}
+	return true
+}
+
+// transitionKey identifies a transition across versions
+type transitionKey struct {
+	from, event, guard, to string
+}
+
+func keyOf(t *Transition) transitionKey {
+	return transitionKey{t.From, t.Event, t.Guard, t.To}
+}
+
+// stateDetails describes how the behavior of a state changed
+func stateDetails(before, after *State) []string {
+	var details []string
+	if before.EntryAction != after.EntryAction {
+		details = append(details, "entry action "+changeOf(before.EntryAction, after.EntryAction))
+	}
+	if before.ExitAction != after.ExitAction {
+		details = append(details, "exit action "+changeOf(before.ExitAction, after.ExitAction))
+	}
+	if before.Final != after.Final {
+		details = append(details, fmt.Sprintf("final %t -> %t", before.Final, after.Final))
+	}
+	return details
+}
+
+// changeOf renders a changed name, with "none" for an empty one
+func changeOf(before, after string) string {
+	if before == "" {
+		before = "none"
+	}
+	if after == "" {
+		after = "none"
+	}
+	return before + " -> " + after
+}
+
+// unionKeys returns the keys of both maps, sorted
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
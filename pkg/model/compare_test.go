@@ -0,0 +1,81 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompare(t *testing.T) {
+	old := newPathGraph(t).FSM
+	updated := newPathGraph(t).FSM
+	assert.True(t, Compare(old, updated).Empty())
+
+	// review is gone along with its transitions and the flag and clear events
+	delete(updated.States, "review")
+	delete(updated.Events, "flag")
+	delete(updated.Events, "clear")
+	var kept []*Transition
+	for _, tr := range updated.Transitions {
+		if tr.From != "review" && tr.To != "review" {
+			kept = append(kept, tr)
+		}
+	}
+	updated.Transitions = kept
+
+	require.NoError(t, updated.AddState(&State{Name: "cancelled", Final: true}))
+	require.NoError(t, updated.AddEvent(&Event{Name: "cancel"}))
+	require.NoError(t, updated.AddTransition(&Transition{From: "pending", To: "cancelled", Event: "cancel"}))
+	updated.GetState("shipped").EntryAction = "notifyCustomer"
+	updated.Transitions[0].Action = "chargeCard"
+	updated.Initial = "approved"
+
+	d := Compare(old, updated)
+	assert.False(t, d.Empty())
+	assert.Equal(t, "pending", d.OldInitial)
+	assert.Equal(t, "approved", d.NewInitial)
+
+	states := make(map[string]StateChange)
+	for _, s := range d.States {
+		states[s.Name] = s
+	}
+	assert.Len(t, d.States, 6, "States of both versions are listed")
+	assert.Equal(t, Added, states["cancelled"].Change)
+	assert.Equal(t, Removed, states["review"].Change)
+	assert.Equal(t, Unchanged, states["archived"].Change)
+	assert.Equal(t, Modified, states["shipped"].Change)
+	assert.Equal(t, []string{"entry action none -> notifyCustomer"}, states["shipped"].Details)
+
+	assert.Equal(t, []EventChange{
+		{Name: "approve"}, {Name: "archive"}, {Name: "cancel", Change: Added},
+		{Name: "clear", Change: Removed}, {Name: "flag", Change: Removed}, {Name: "ship"},
+	}, d.Events)
+
+	var transitions []string
+	for _, tr := range d.Transitions {
+		transitions = append(transitions, string(tr.Change)+" "+FormatPath(tr.From, []*Transition{tr.Transition}))
+	}
+	assert.Equal(t, []string{
+		"modified pending --approve--> approved",
+		" approved --ship--> shipped",
+		"added pending --cancel--> cancelled",
+		"removed pending --flag--> review",
+		"removed review --clear--> approved",
+		"removed review --clear--> pending",
+		"removed review --ship--> shipped",
+	}, transitions, "Kept and added transitions come in the new order, removed ones after them")
+	assert.Equal(t, []string{"action none -> chargeCard"}, d.Transitions[0].Details)
+}
+
+func TestCompare_RetargetedTransition(t *testing.T) {
+	old := newPathGraph(t).FSM
+	updated := newPathGraph(t).FSM
+	updated.Transitions[0].To = "approved"
+
+	d := Compare(old, updated)
+	assert.Equal(t, Added, d.Transitions[0].Change, "A transition to another state is a new transition")
+	assert.Equal(t, "approved", d.Transitions[0].To)
+	assert.Equal(t, Removed, d.Transitions[len(d.Transitions)-1].Change)
+	assert.Equal(t, "review", d.Transitions[len(d.Transitions)-1].To)
+}
@@ -0,0 +1,160 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// CompatIssue is a change between two versions of a machine after which state
+// values persisted by the old version no longer load as the same state
+type CompatIssue struct {
+	// State is the state of the old version whose persisted values are affected
+	State string `json:"state"`
+
+	// Message describes the change
+	Message string `json:"message"`
+}
+
+// StateMigration maps a state of the old version to the state of the new version
+// its persisted rows should be migrated to. To is empty when no state of the new
+// version could be suggested.
+type StateMigration struct {
+	From      string `json:"from"`
+	FromValue int    `json:"from_value"`
+	To        string `json:"to,omitempty"`
+	ToValue   int    `json:"to_value,omitempty"`
+}
+
+// CompatReport lists the changes between two versions of a machine that break
+// persisted states, with a migration map that repairs them
+type CompatReport struct {
+	Machine    string           `json:"machine"`
+	Issues     []CompatIssue    `json:"issues"`
+	Migrations []StateMigration `json:"migrations"`
+}
+
+// CheckCompat reports the states of old whose persisted names or integer values
+// the updated version no longer reads back as the same state: removed or renamed
+// states, states whose value changes, and values that now name another state.
+// A removed state that the updated version migrates is only reported while its
+// value does not load too; any other is suggested to have been renamed to the
+// added state handling the most of the same events.
+func CheckCompat(old, updated *FSMModel) *CompatReport {
+	r := &CompatReport{Machine: updated.Name, Issues: []CompatIssue{}, Migrations: []StateMigration{}}
+
+	oldValues, newValues := old.GetStateValues(), updated.GetStateValues()
+	newOwners := make(map[int]string, len(newValues))
+	for name, value := range newValues {
+		newOwners[value] = name
+	}
+
+	var removed, added []string
+	for _, s := range Compare(old, updated).States {
+		switch s.Change {
+		case Removed:
+			if updated.GetLegacyState(s.Name) == nil {
+				removed = append(removed, s.Name)
+			}
+		case Added:
+			added = append(added, s.Name)
+		}
+	}
+	renames := guessRenames(old, updated, removed, added)
+
+	for _, name := range old.GetStateNames() {
+		before := oldValues[name]
+		if legacy := updated.GetLegacyState(name); legacy != nil {
+			if (legacy.Value != nil && *legacy.Value == before) || newOwners[before] == legacy.To {
+				continue
+			}
+			message := fmt.Sprintf("state %s (value %d) migrates to %s by name, but persisted %d no longer loads; add `value: %d` to its migration", name, before, legacy.To, before, before)
+			if owner := newOwners[before]; owner != "" {
+				message = fmt.Sprintf("state %s (value %d) migrates to %s by name, but persisted %d now loads as %s", name, before, legacy.To, before, owner)
+			}
+			r.Issues = append(r.Issues, CompatIssue{State: name, Message: message})
+			r.Migrations = append(r.Migrations, StateMigration{From: name, FromValue: before, To: legacy.To, ToValue: updated.StateValue(legacy.To)})
+			continue
+		}
+		if updated.GetState(name) == nil {
+			message := fmt.Sprintf("state %s (value %d) was removed; persisted %q and %d no longer load", name, before, name, before)
+			if owner := newOwners[before]; owner != "" {
+				message = fmt.Sprintf("state %s (value %d) was removed; persisted %q no longer loads and %d now loads as %s", name, before, name, before, owner)
+			}
+			if to := renames[name]; to != "" {
+				message += fmt.Sprintf(" (renamed to %s?)", to)
+			}
+			r.Issues = append(r.Issues, CompatIssue{State: name, Message: message})
+
+			migration := StateMigration{From: name, FromValue: before, To: renames[name]}
+			if migration.To != "" {
+				migration.ToValue = updated.StateValue(migration.To)
+			}
+			r.Migrations = append(r.Migrations, migration)
+			continue
+		}
+
+		after := newValues[name]
+		if after == before {
+			continue
+		}
+		message := fmt.Sprintf("state %s changes value %d -> %d; pin it with `value: %d` or migrate persisted values", name, before, after, before)
+		if owner := newOwners[before]; owner != "" {
+			message = fmt.Sprintf("state %s changes value %d -> %d, and persisted %d now loads as %s; pin it with `value: %d` or migrate persisted values", name, before, after, before, owner, before)
+		}
+		r.Issues = append(r.Issues, CompatIssue{State: name, Message: message})
+		r.Migrations = append(r.Migrations, StateMigration{From: name, FromValue: before, To: name, ToValue: after})
+	}
+
+	sort.Slice(r.Issues, func(i, j int) bool { return r.Issues[i].State < r.Issues[j].State })
+	sort.Slice(r.Migrations, func(i, j int) bool { return r.Migrations[i].From < r.Migrations[j].From })
+	return r
+}
+
+// guessRenames pairs removed states with the added states they were most likely
+// renamed to: the one sharing the most events handled in and out. Ties are not
+// guessed.
+func guessRenames(old, updated *FSMModel, removed, added []string) map[string]string {
+	renames := make(map[string]string)
+	taken := make(map[string]bool)
+	for _, from := range removed {
+		was := eventSignature(old, from)
+		best, bestScore, tie := "", 0, false
+		for _, to := range added {
+			if taken[to] {
+				continue
+			}
+			score := 0
+			for sig := range eventSignature(updated, to) {
+				if was[sig] {
+					score++
+				}
+			}
+			switch {
+			case score > bestScore:
+				best, bestScore, tie = to, score, false
+			case score == bestScore && score > 0:
+				tie = true
+			}
+		}
+		if best != "" && !tie {
+			renames[from] = best
+			taken[best] = true
+		}
+	}
+	return renames
+}
+
+// eventSignature returns the events a state handles and is entered by, marked
+// with their direction
+func eventSignature(m *FSMModel, state string) map[string]bool {
+	sig := make(map[string]bool)
+	for _, t := range m.Transitions {
+		if t.From == state {
+			sig["out:"+t.Event] = true
+		}
+		if t.To == state {
+			sig["in:"+t.Event] = true
+		}
+	}
+	return sig
+}
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckCompat(t *testing.T) {
+	old := newPathGraph(t).FSM
+	assert.Empty(t, CheckCompat(old, newPathGraph(t).FSM).Issues)
+
+	// The initial pending is 0, the others are approved 1, archived 2, review 3, shipped 4
+	updated := newPathGraph(t).FSM
+	delete(updated.States, "review")
+	assert.NoError(t, updated.AddState(&State{Name: "in_review"}))
+	for _, tr := range updated.Transitions {
+		if tr.From == "review" {
+			tr.From = "in_review"
+		}
+		if tr.To == "review" {
+			tr.To = "in_review"
+		}
+	}
+	delete(updated.States, "archived")
+
+	// Now approved 1, in_review 2, shipped 3
+	r := CheckCompat(old, updated)
+	assert.Equal(t, "OrderStateMachine", r.Machine)
+	assert.Equal(t, []CompatIssue{
+		{State: "archived", Message: `state archived (value 2) was removed; persisted "archived" no longer loads and 2 now loads as in_review`},
+		{State: "review", Message: `state review (value 3) was removed; persisted "review" no longer loads and 3 now loads as shipped (renamed to in_review?)`},
+		{State: "shipped", Message: "state shipped changes value 4 -> 3; pin it with `value: 4` or migrate persisted values"},
+	}, r.Issues)
+	assert.Equal(t, []StateMigration{
+		{From: "archived", FromValue: 2},
+		{From: "review", FromValue: 3, To: "in_review", ToValue: 2},
+		{From: "shipped", FromValue: 4, To: "shipped", ToValue: 3},
+	}, r.Migrations)
+}
+
+func TestCheckCompat_PinnedValues(t *testing.T) {
+	old := newPathGraph(t).FSM
+	updated := newPathGraph(t).FSM
+	for _, name := range []string{"approved", "pending", "review", "shipped"} {
+		value := old.StateValue(name)
+		updated.GetState(name).Value = &value
+	}
+	delete(updated.States, "archived")
+	assert.NoError(t, updated.AddState(&State{Name: "cancelled"}))
+
+	r := CheckCompat(old, updated)
+	assert.Equal(t, []CompatIssue{
+		{State: "archived", Message: `state archived (value 2) was removed; persisted "archived" no longer loads and 2 now loads as cancelled`},
+	}, r.Issues, "Pinned states keep their values; the tie-free rename guess needs shared events")
+	assert.Equal(t, []StateMigration{{From: "archived", FromValue: 2}}, r.Migrations)
+
+	delete(updated.States, "cancelled")
+	assert.Equal(t, []CompatIssue{
+		{State: "archived", Message: `state archived (value 2) was removed; persisted "archived" and 2 no longer load`},
+	}, CheckCompat(old, updated).Issues)
+}
+
+func TestCheckCompat_DeclaredMigrations(t *testing.T) {
+	old := newPathGraph(t).FSM
+
+	// The initial pending is 0, the others are approved 1, archived 2, review 3, shipped 4
+	updated := newPathGraph(t).FSM
+	delete(updated.States, "archived")
+	delete(updated.States, "review")
+	for _, name := range []string{"approved", "shipped"} {
+		value := old.StateValue(name)
+		updated.GetState(name).Value = &value
+	}
+	assert.NoError(t, updated.AddLegacyState(&LegacyState{Name: "archived", To: "shipped"}))
+	reviewValue := 3
+	assert.NoError(t, updated.AddLegacyState(&LegacyState{Name: "review", Value: &reviewValue, To: "pending"}))
+
+	r := CheckCompat(old, updated)
+	assert.Equal(t, []CompatIssue{
+		{State: "archived", Message: "state archived (value 2) migrates to shipped by name, but persisted 2 no longer loads; add `value: 2` to its migration"},
+	}, r.Issues)
+	assert.Equal(t, []StateMigration{{From: "archived", FromValue: 2, To: "shipped", ToValue: 4}}, r.Migrations)
+
+	archivedValue := 2
+	updated.GetLegacyState("archived").Value = &archivedValue
+	assert.Empty(t, CheckCompat(old, updated).Issues)
+}
@@ -0,0 +1,90 @@
+package model
+
+import "fmt"
+
+// FieldType is the type of a context field
+type FieldType string
+
+const (
+	// FieldInt is a whole number, generated as int
+	FieldInt FieldType = "int"
+
+	// FieldFloat is a number, generated as float64
+	FieldFloat FieldType = "float"
+
+	// FieldString is text, generated as string
+	FieldString FieldType = "string"
+
+	// FieldBool is a flag, generated as bool
+	FieldBool FieldType = "bool"
+)
+
+// ContextField is a field of the generated machine context that guard conditions
+// can refer to
+type ContextField struct {
+	// Name is the name of the field in the spec and in guard conditions
+	Name string
+
+	// Type is the type of the field
+	Type FieldType
+
+	// Description is an optional human-readable description
+	Description string
+}
+
+// GoType returns the Go type of the generated struct field
+func (c *ContextField) GoType() string {
+	if c.Type == FieldFloat {
+		return "float64"
+	}
+	return string(c.Type)
+}
+
+// ZeroValue returns the Go literal of the zero value of the generated struct field
+func (c *ContextField) ZeroValue() string {
+	switch c.Type {
+	case FieldString:
+		return `""`
+	case FieldBool:
+		return "false"
+	default:
+		return "0"
+	}
+}
+
+// NonZeroValue returns the Go literal of a value of the generated struct field
+// other than its zero value, such as a test uses to set a required field
+func (c *ContextField) NonZeroValue() string {
+	switch c.Type {
+	case FieldString:
+		return `"set"`
+	case FieldBool:
+		return "true"
+	default:
+		return "1"
+	}
+}
+
+// numeric reports whether the field holds a number
+func (c *ContextField) numeric() bool {
+	return c.Type == FieldInt || c.Type == FieldFloat
+}
+
+// Validate checks if the context field is valid
+func (c *ContextField) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("context field name cannot be empty")
+	}
+
+	if !validNamePattern.MatchString(c.Name) {
+		return fmt.Errorf("context field name %q contains invalid characters (use only letters, digits, and underscores)", c.Name)
+	}
+
+	switch c.Type {
+	case FieldInt, FieldFloat, FieldString, FieldBool:
+		return nil
+	default:
+		return fmt.Errorf("context field %q has type %q, which is not one of %q, %q, %q, %q",
+			c.Name, c.Type, FieldInt, FieldFloat, FieldString, FieldBool)
+	}
+}
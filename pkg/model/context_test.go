@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextField_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		field   ContextField
+		wantErr string
+	}{
+		{name: "int", field: ContextField{Name: "amount", Type: FieldInt}},
+		{name: "float", field: ContextField{Name: "score", Type: FieldFloat}},
+		{name: "string", field: ContextField{Name: "currency", Type: FieldString}},
+		{name: "bool", field: ContextField{Name: "paid", Type: FieldBool}},
+		{name: "empty name", field: ContextField{Type: FieldInt}, wantErr: "context field name cannot be empty"},
+		{name: "invalid name", field: ContextField{Name: "total-amount", Type: FieldInt}, wantErr: "contains invalid characters"},
+		{name: "unknown type", field: ContextField{Name: "at", Type: "time"}, wantErr: `context field "at" has type "time"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.field.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestContextField_GoType(t *testing.T) {
+	assert.Equal(t, "int", (&ContextField{Type: FieldInt}).GoType())
+	assert.Equal(t, "float64", (&ContextField{Type: FieldFloat}).GoType())
+	assert.Equal(t, "string", (&ContextField{Type: FieldString}).GoType())
+	assert.Equal(t, "bool", (&ContextField{Type: FieldBool}).GoType())
+}
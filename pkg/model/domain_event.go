@@ -0,0 +1,79 @@
+package model
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// domainTypePattern matches a possibly pointer, possibly package-qualified Go type name
+var domainTypePattern = regexp.MustCompile(`^\*?(?:([a-zA-Z_][a-zA-Z0-9_]*)\.)?[A-Z][a-zA-Z0-9_]*$`)
+
+// DomainEvent maps a domain event type of the application to a machine event
+type DomainEvent struct {
+	// Type is the Go type of the domain event, e.g. "billing.PaymentCaptured" or "*OrderPlaced"
+	Type string
+
+	// Event is the machine event fired when a value of Type is handled
+	Event string
+}
+
+// Package returns the package qualifier of the domain event type, or "" for local types
+func (d *DomainEvent) Package() string {
+	if m := domainTypePattern.FindStringSubmatch(d.Type); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Validate checks that the mapping names a well-formed type, an imported package, and a defined event
+func (d *DomainEvent) Validate(events map[string]*Event, imports []string) error {
+	if !domainTypePattern.MatchString(d.Type) {
+		return fmt.Errorf("domain event type %q is not an exported Go type name such as billing.PaymentCaptured", d.Type)
+	}
+
+	if pkg := d.Package(); pkg != "" && !hasImportNamed(imports, pkg) {
+		return fmt.Errorf("domain event type %q uses package %q, which is not listed in imports", d.Type, pkg)
+	}
+
+	if _, exists := events[d.Event]; !exists {
+		return fmt.Errorf("domain event type %q maps to undefined event %q", d.Type, d.Event)
+	}
+
+	return nil
+}
+
+// ImportName returns the package name an import path is referred to by
+func ImportName(importPath string) string {
+	return path.Base(importPath)
+}
+
+// hasImportNamed reports whether one of imports is referred to by name
+func hasImportNamed(imports []string, name string) bool {
+	for _, imp := range imports {
+		if ImportName(imp) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// validateImports checks that import paths are non-empty and their package names distinct
+func validateImports(imports []string) error {
+	seen := make(map[string]string, len(imports))
+	for _, imp := range imports {
+		if strings.TrimSpace(imp) == "" {
+			return fmt.Errorf("import path cannot be empty")
+		}
+		name := ImportName(imp)
+		if !validNamePattern.MatchString(name) {
+			return fmt.Errorf("import %q does not end in a valid package name", imp)
+		}
+		if other, exists := seen[name]; exists {
+			return fmt.Errorf("imports %q and %q have the same package name %q", other, imp, name)
+		}
+		seen[name] = imp
+	}
+	return nil
+}
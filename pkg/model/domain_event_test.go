@@ -0,0 +1,101 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainEvent_Validate(t *testing.T) {
+	events := map[string]*Event{"approve": {Name: "approve"}}
+	imports := []string{"github.com/acme/shop/billing"}
+
+	tests := []struct {
+		name        string
+		domainEvent DomainEvent
+		wantPackage string
+		wantErr     string
+	}{
+		{
+			name:        "qualified type",
+			domainEvent: DomainEvent{Type: "billing.PaymentCaptured", Event: "approve"},
+			wantPackage: "billing",
+		},
+		{
+			name:        "pointer type",
+			domainEvent: DomainEvent{Type: "*billing.PaymentCaptured", Event: "approve"},
+			wantPackage: "billing",
+		},
+		{
+			name:        "local type",
+			domainEvent: DomainEvent{Type: "OrderPlaced", Event: "approve"},
+		},
+		{
+			name:        "unexported type",
+			domainEvent: DomainEvent{Type: "billing.paymentCaptured", Event: "approve"},
+			wantErr:     "is not an exported Go type name",
+		},
+		{
+			name:        "package not imported",
+			domainEvent: DomainEvent{Type: "shipping.Dispatched", Event: "approve"},
+			wantPackage: "shipping",
+			wantErr:     `uses package "shipping", which is not listed in imports`,
+		},
+		{
+			name:        "undefined event",
+			domainEvent: DomainEvent{Type: "billing.PaymentCaptured", Event: "capture"},
+			wantPackage: "billing",
+			wantErr:     `maps to undefined event "capture"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantPackage, tt.domainEvent.Package())
+
+			err := tt.domainEvent.Validate(events, imports)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFSMModel_DomainEvents(t *testing.T) {
+	fsm := newShippingFSM()
+	fsm.Imports = []string{"github.com/acme/shop/billing", "github.com/acme/shop/warehouse"}
+
+	require.NoError(t, fsm.AddDomainEvent(&DomainEvent{Type: "billing.PaymentCaptured", Event: "approve"}))
+	assert.ErrorContains(t, fsm.AddDomainEvent(&DomainEvent{Type: "billing.PaymentCaptured", Event: "ship"}),
+		`already mapped to "approve"`)
+	require.NoError(t, fsm.Validate())
+
+	assert.Equal(t, []string{"github.com/acme/shop/billing"}, fsm.GetDomainEventImports(),
+		"Only imports referenced by domain event types are generated")
+
+	fsm.Imports = append(fsm.Imports, "github.com/other/billing")
+	assert.ErrorContains(t, fsm.Validate(), `have the same package name "billing"`)
+}
+
+func TestFSMModel_EventPayloads(t *testing.T) {
+	fsm := newShippingFSM()
+	fsm.Imports = []string{"github.com/acme/shop/billing", "github.com/acme/shop/warehouse"}
+	require.NoError(t, fsm.AddDomainEvent(&DomainEvent{Type: "billing.PaymentCaptured", Event: "approve"}))
+	require.NoError(t, fsm.Validate())
+	assert.Empty(t, fsm.GetPayloadEvents())
+	assert.False(t, fsm.HasRequiredPayloadFields())
+
+	fsm.Events["approve"].Payload = &EventPayload{Type: "billing.Approval"}
+	fsm.Events["ship"].Payload = &EventPayload{Type: "warehouse.Parcel", Required: []string{"Carrier"}}
+	require.NoError(t, fsm.Validate())
+	require.Len(t, fsm.GetPayloadEvents(), 2)
+	assert.Equal(t, "approve", fsm.GetPayloadEvents()[0].Name)
+	assert.True(t, fsm.HasRequiredPayloadFields())
+	assert.Equal(t, []string{"github.com/acme/shop/billing", "github.com/acme/shop/warehouse"}, fsm.GetEventTypeImports())
+
+	fsm.Events["ship"].Payload.Type = "parcel"
+	assert.ErrorContains(t, fsm.Validate(), `invalid event "ship": payload type "parcel" is not an exported Go type name`)
+}
@@ -1,6 +1,11 @@
 package model
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 // Event represents an event that can trigger state transitions
 type Event struct {
@@ -9,6 +14,61 @@ type Event struct {
 
 	// Description is an optional human-readable description
 	Description string
+
+	// Weight is the relative probability of this event being chosen by the
+	// generated chaos helpers; zero means the default weight of 1
+	Weight int
+
+	// Value pins the numeric value of the generated constant; nil assigns one automatically
+	Value *int
+
+	// Deprecated marks an event kept only for existing callers; its constant is
+	// marked deprecated
+	Deprecated bool
+
+	// DeprecationReason optionally explains the deprecation, such as what to use instead
+	DeprecationReason string
+
+	// Payload optionally declares the payload the event carries, which gets the
+	// event a generated constructor validating it
+	Payload *EventPayload
+}
+
+// EventPayload declares the payload of an event
+type EventPayload struct {
+	// Type is the Go struct type of the payload, e.g. "ApproveRequest" or
+	// "billing.Refund"; constructors take a pointer to it
+	Type string
+
+	// Required lists the exported fields of Type that must not be zero
+	Required []string
+}
+
+// Package returns the package qualifier of the payload type, or "" for local types
+func (p *EventPayload) Package() string {
+	return typePackage(p.Type)
+}
+
+// validate checks that the payload names an exported type of an imported package
+// and distinct exported fields
+func (p *EventPayload) validate(imports []string) error {
+	if !domainTypePattern.MatchString(p.Type) || strings.HasPrefix(p.Type, "*") {
+		return fmt.Errorf("payload type %q is not an exported Go type name such as ApproveRequest or billing.Refund", p.Type)
+	}
+	if pkg := p.Package(); pkg != "" && !hasImportNamed(imports, pkg) {
+		return fmt.Errorf("payload type %q uses package %q, which is not listed in imports", p.Type, pkg)
+	}
+	seen := make(map[string]bool, len(p.Required))
+	for _, field := range p.Required {
+		if first, _ := utf8.DecodeRuneInString(field); !validNamePattern.MatchString(field) || !unicode.IsUpper(first) {
+			return fmt.Errorf("required payload field %q is not an exported Go field name", field)
+		}
+		if seen[field] {
+			return fmt.Errorf("required payload field %q is listed twice", field)
+		}
+		seen[field] = true
+	}
+	return nil
 }
 
 // NewEvent creates a new Event with the given name
@@ -26,6 +86,14 @@ func NewEvent(name string) (*Event, error) {
 	}, nil
 }
 
+// ChaosWeight returns the effective weight used for random event selection
+func (e *Event) ChaosWeight() int {
+	if e.Weight == 0 {
+		return 1
+	}
+	return e.Weight
+}
+
 // Validate checks if the event is valid
 func (e *Event) Validate() error {
 	if e.Name == "" {
@@ -36,5 +104,17 @@ func (e *Event) Validate() error {
 		return fmt.Errorf("event name %q contains invalid characters (use only letters, digits, and underscores)", e.Name)
 	}
 
+	if e.Weight < 0 {
+		return fmt.Errorf("event %q weight cannot be negative", e.Name)
+	}
+
+	if e.Value != nil && *e.Value < 0 {
+		return fmt.Errorf("event %q value cannot be negative", e.Name)
+	}
+
+	if e.DeprecationReason != "" && !e.Deprecated {
+		return fmt.Errorf("event %q has a deprecation reason but is not deprecated", e.Name)
+	}
+
 	return nil
 }
@@ -71,6 +71,23 @@ func TestEvent_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid deprecated event with a reason",
+			event: &Event{
+				Name:              "approve",
+				Deprecated:        true,
+				DeprecationReason: "Use another event instead",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid deprecation reason without deprecation",
+			event: &Event{
+				Name:              "approve",
+				DeprecationReason: "Use another event instead",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid event with empty name",
 			event: &Event{
@@ -85,6 +102,14 @@ func TestEvent_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid negative chaos weight",
+			event: &Event{
+				Name:   "cancel",
+				Weight: -1,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -99,3 +124,83 @@ func TestEvent_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestEvent_ChaosWeight(t *testing.T) {
+	tests := []struct {
+		name  string
+		event *Event
+		want  int
+	}{
+		{
+			name:  "unset weight defaults to one",
+			event: &Event{Name: "approve"},
+			want:  1,
+		},
+		{
+			name:  "frequent heartbeat event",
+			event: &Event{Name: "heartbeat", Weight: 10},
+			want:  10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.event.ChaosWeight())
+		})
+	}
+}
+
+func TestEventPayload_Validate(t *testing.T) {
+	imports := []string{"github.com/acme/shop/billing"}
+
+	tests := []struct {
+		name        string
+		payload     EventPayload
+		wantPackage string
+		wantErr     string
+	}{
+		{
+			name:    "local type with required fields",
+			payload: EventPayload{Type: "ApproveRequest", Required: []string{"ApproverID", "Amount"}},
+		},
+		{
+			name:        "qualified type",
+			payload:     EventPayload{Type: "billing.Refund"},
+			wantPackage: "billing",
+		},
+		{
+			name:    "pointer type",
+			payload: EventPayload{Type: "*ApproveRequest"},
+			wantErr: "is not an exported Go type name",
+		},
+		{
+			name:        "package not imported",
+			payload:     EventPayload{Type: "shipping.Parcel"},
+			wantPackage: "shipping",
+			wantErr:     `uses package "shipping", which is not listed in imports`,
+		},
+		{
+			name:    "unexported field",
+			payload: EventPayload{Type: "ApproveRequest", Required: []string{"amount"}},
+			wantErr: `required payload field "amount" is not an exported Go field name`,
+		},
+		{
+			name:    "repeated field",
+			payload: EventPayload{Type: "ApproveRequest", Required: []string{"Amount", "Amount"}},
+			wantErr: `required payload field "Amount" is listed twice`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantPackage, tt.payload.Package())
+
+			err := tt.payload.validate(imports)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package model
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
 
 // FSMModel represents the complete finite state machine model
 type FSMModel struct {
@@ -24,6 +29,55 @@ type FSMModel struct {
 
 	// Description is an optional human-readable description
 	Description string
+
+	// Version numbers the revisions of a machine's definition; zero means the
+	// spec is unversioned
+	Version int
+
+	// Base is the name shared by the versions of a machine generated side by
+	// side, which is suffixed with the version to form Name; empty otherwise
+	Base string
+
+	// BeforeTransition is the optional action run on every transition once its
+	// guard passes, before the exit action of the source state
+	BeforeTransition string
+
+	// AfterTransition is the optional action run on every transition after the
+	// entry action of the target state
+	AfterTransition string
+
+	// Options controls optional features of the generated code
+	Options Options
+
+	// Properties are checked against simulated runs by the generated tests
+	Properties []*Property
+
+	// LegacyStates are states of earlier versions whose persisted values load as
+	// declared states
+	LegacyStates []*LegacyState
+
+	// Context are the declared fields of the generated machine context
+	Context []*ContextField
+
+	// Guards declare the conditions of guards over the context fields; guards
+	// without one are opaque to analysis
+	Guards []*GuardCondition
+
+	// Imports are the import paths of packages that declare domain event types
+	// and the types of the binding
+	Imports []string
+
+	// DomainEvents map domain event types to machine events
+	DomainEvents []*DomainEvent
+
+	// Binding binds the machine to the state field of an ORM model, if any
+	Binding *Binding
+
+	// Header configures the top of generated files
+	Header Header
+
+	// Source identifies the spec the model was parsed from, if any
+	Source Source
 }
 
 // NewFSMModel creates a new FSMModel with the given name and initial state
@@ -106,8 +160,154 @@ func (f *FSMModel) AddTransition(transition *Transition) error {
 	return nil
 }
 
+// UseVersionedName renames the machine after its version for side-by-side
+// generation, keeping its name as Base. It is a no-op for a machine renamed
+// already.
+func (f *FSMModel) UseVersionedName() error {
+	if f.Base != "" {
+		return nil
+	}
+	if f.Version <= 0 {
+		return fmt.Errorf("machine %s needs a version to be generated side by side", f.Name)
+	}
+	f.Base = f.Name
+	f.Name = fmt.Sprintf("%sV%d", f.Name, f.Version)
+	f.Options.SideBySide = true
+	return nil
+}
+
+// VersionSuffix returns the suffix distinguishing the package-level declarations
+// of a machine generated side by side, such as its options, from those of its
+// other versions; it is empty otherwise
+func (f *FSMModel) VersionSuffix() string {
+	if f.Base == "" {
+		return ""
+	}
+	return fmt.Sprintf("V%d", f.Version)
+}
+
+// AddProperty adds a model-level property to the FSM
+func (f *FSMModel) AddProperty(property *Property) error {
+	if property == nil {
+		return fmt.Errorf("cannot add nil property")
+	}
+
+	for _, existing := range f.Properties {
+		if existing.Name == property.Name {
+			return fmt.Errorf("property %q already exists", property.Name)
+		}
+	}
+
+	f.Properties = append(f.Properties, property)
+	return nil
+}
+
+// AddLegacyState declares a state of an earlier version and the state it migrates to
+func (f *FSMModel) AddLegacyState(legacy *LegacyState) error {
+	if legacy == nil {
+		return fmt.Errorf("cannot add nil legacy state")
+	}
+
+	if existing := f.GetLegacyState(legacy.Name); existing != nil {
+		return fmt.Errorf("legacy state %q already migrates to %q", legacy.Name, existing.To)
+	}
+
+	f.LegacyStates = append(f.LegacyStates, legacy)
+	return nil
+}
+
+// AddContextField declares a field of the machine context
+func (f *FSMModel) AddContextField(field *ContextField) error {
+	if field == nil {
+		return fmt.Errorf("cannot add nil context field")
+	}
+
+	for _, existing := range f.Context {
+		if existing.Name == field.Name {
+			return fmt.Errorf("context field %q already exists", field.Name)
+		}
+	}
+
+	f.Context = append(f.Context, field)
+	return nil
+}
+
+// AddGuardCondition declares the condition a guard checks
+func (f *FSMModel) AddGuardCondition(guard *GuardCondition) error {
+	if guard == nil {
+		return fmt.Errorf("cannot add nil guard condition")
+	}
+
+	if f.GetGuardCondition(guard.Name) != nil {
+		return fmt.Errorf("guard %q already has a condition", guard.Name)
+	}
+
+	f.Guards = append(f.Guards, guard)
+	return nil
+}
+
+// GetGuardCondition returns the declared condition of a guard, or nil
+func (f *FSMModel) GetGuardCondition(name string) *GuardCondition {
+	for _, guard := range f.Guards {
+		if guard.Name == name {
+			return guard
+		}
+	}
+	return nil
+}
+
+// IsPureGuard reports whether the named guard is declared pure
+func (f *FSMModel) IsPureGuard(name string) bool {
+	guard := f.GetGuardCondition(name)
+	return guard != nil && guard.Pure
+}
+
+// HasPureGuards reports whether any guard is declared pure
+func (f *FSMModel) HasPureGuards() bool {
+	for _, guard := range f.Guards {
+		if guard.Pure {
+			return true
+		}
+	}
+	return false
+}
+
+// GuardPredicate returns the parsed condition of a guard, or nil when the guard
+// has no condition or no valid one
+func (f *FSMModel) GuardPredicate(name string) Predicate {
+	guard := f.GetGuardCondition(name)
+	if guard == nil {
+		return nil
+	}
+	p, err := guard.Predicate(f.Context)
+	if err != nil {
+		return nil
+	}
+	return p
+}
+
+// AddDomainEvent maps a domain event type to a machine event
+func (f *FSMModel) AddDomainEvent(domainEvent *DomainEvent) error {
+	if domainEvent == nil {
+		return fmt.Errorf("cannot add nil domain event")
+	}
+
+	for _, existing := range f.DomainEvents {
+		if existing.Type == domainEvent.Type {
+			return fmt.Errorf("domain event type %q is already mapped to %q", domainEvent.Type, existing.Event)
+		}
+	}
+
+	f.DomainEvents = append(f.DomainEvents, domainEvent)
+	return nil
+}
+
 // Validate checks if the FSM model is valid
 func (f *FSMModel) Validate() error {
+	if f.Version < 0 {
+		return fmt.Errorf("machine version %d cannot be negative", f.Version)
+	}
+
 	// Check that initial state is defined
 	if _, exists := f.States[f.Initial]; !exists {
 		return fmt.Errorf("initial state %q is not defined", f.Initial)
@@ -137,6 +337,21 @@ func (f *FSMModel) Validate() error {
 		}
 	}
 
+	// Validate pinned enum values
+	if err := f.validateValues(); err != nil {
+		return err
+	}
+
+	// Validate the migrations of legacy states
+	if err := f.validateLegacyStates(); err != nil {
+		return err
+	}
+
+	// Validate ignored events
+	if err := f.validateIgnoredEvents(); err != nil {
+		return err
+	}
+
 	// Validate all transitions
 	for _, transition := range f.Transitions {
 		if err := transition.Validate(); err != nil {
@@ -144,9 +359,205 @@ func (f *FSMModel) Validate() error {
 		}
 	}
 
+	// Validate inverse and compensating transitions
+	for _, t := range f.Transitions {
+		if err := f.validateUndo(t, "inverse", t.Inverse); err != nil {
+			return err
+		}
+		if err := f.validateUndo(t, "compensation", t.Compensate); err != nil {
+			return err
+		}
+	}
+
+	// Validate the context fields and guard conditions
+	if err := f.validateGuardConditions(); err != nil {
+		return err
+	}
+
+	// Validate the context states require
+	if err := f.validateRequiredContext(); err != nil {
+		return err
+	}
+
+	// Validate all properties
+	for _, property := range f.Properties {
+		if err := property.Validate(f.States, f.Events); err != nil {
+			return fmt.Errorf("invalid property: %w", err)
+		}
+	}
+
+	// Validate domain event mappings
+	if err := validateImports(f.Imports); err != nil {
+		return fmt.Errorf("invalid imports: %w", err)
+	}
+	for _, domainEvent := range f.DomainEvents {
+		if err := domainEvent.Validate(f.Events, f.Imports); err != nil {
+			return fmt.Errorf("invalid domain event: %w", err)
+		}
+	}
+
+	// Validate event payloads
+	for _, event := range f.GetPayloadEvents() {
+		if err := event.Payload.validate(f.Imports); err != nil {
+			return fmt.Errorf("invalid event %q: %w", event.Name, err)
+		}
+	}
+
+	// Validate the ORM binding
+	if f.Binding != nil {
+		if err := f.Binding.validate(f.Name, f.Imports); err != nil {
+			return fmt.Errorf("invalid binding: %w", err)
+		}
+	}
+
+	// Validate the generated file header
+	if err := f.Header.validate(); err != nil {
+		return fmt.Errorf("invalid header: %w", err)
+	}
+
+	// Validate generation options
+	if err := f.Options.validate(f.States); err != nil {
+		return fmt.Errorf("invalid options: %w", err)
+	}
+	if f.Options.Callbacks {
+		if err := f.validateCallbackNames(); err != nil {
+			return fmt.Errorf("invalid options: %w", err)
+		}
+	}
+
+	// Reject transitions the generated machine cannot choose between. This runs
+	// last, so a *NondeterminismError means the model is otherwise valid.
+	graph := NewStateGraph(f)
+	if err := graph.Build(); err != nil {
+		return err
+	}
+	if conflicts := graph.FindNondeterminism(); len(conflicts) > 0 {
+		return &NondeterminismError{Conflicts: conflicts}
+	}
+
+	return nil
+}
+
+// validateGuardConditions checks that the context fields are valid and that every
+// guard condition refers to them and belongs to a guard transitions use
+func (f *FSMModel) validateGuardConditions() error {
+	for _, field := range f.Context {
+		if err := field.Validate(); err != nil {
+			return fmt.Errorf("invalid context: %w", err)
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, name := range f.GetGuardNames() {
+		used[name] = true
+	}
+	for _, guard := range f.Guards {
+		if err := guard.Validate(f.Context); err != nil {
+			return fmt.Errorf("invalid guard: %w", err)
+		}
+		if !used[guard.Name] {
+			return fmt.Errorf("invalid guard: guard %q is not used by any transition", guard.Name)
+		}
+	}
+	return nil
+}
+
+// validateRequiredContext checks that the context fields states require are
+// declared and required once per state
+func (f *FSMModel) validateRequiredContext() error {
+	for _, state := range f.GetStatesSlice() {
+		seen := make(map[string]bool)
+		for _, name := range state.RequiredContext {
+			if f.GetContextField(name) == nil {
+				return fmt.Errorf("state %q requires undefined context field %q", state.Name, name)
+			}
+			if seen[name] {
+				return fmt.Errorf("state %q requires context field %q more than once", state.Name, name)
+			}
+			seen[name] = true
+		}
+	}
+	return nil
+}
+
+// validateCallbackNames checks that guards, transition actions, and state
+// actions do not share names, which the methods of the callbacks interface would
+// otherwise declare twice with different signatures
+func (f *FSMModel) validateCallbackNames() error {
+	kinds := make(map[string]string)
+	for _, group := range []struct {
+		kind  string
+		names []string
+	}{
+		{"a guard", f.GetGuardNames()},
+		{"an action", f.GetActionNames()},
+		{"a state action", f.GetStateActionNames()},
+	} {
+		for _, name := range group.names {
+			if kind, ok := kinds[name]; ok {
+				return fmt.Errorf("callbacks need distinct names, but %q is both %s and %s", name, kind, group.kind)
+			}
+			kinds[name] = group.kind
+		}
+	}
+	return nil
+}
+
+// validateUndo checks that event, the inverse or compensation (kind) of t if not
+// empty, has a transition leading back from the target of t to its source
+func (f *FSMModel) validateUndo(t *Transition, kind, event string) error {
+	if event == "" {
+		return nil
+	}
+	if _, exists := f.Events[event]; !exists {
+		return fmt.Errorf("invalid transition: transition from %q on %q has undefined %s event %q", t.From, t.Event, kind, event)
+	}
+	for _, back := range f.GetTransitionsFrom(t.To) {
+		if back.Event == event && back.To == t.From {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid transition: transition from %q on %q has %s %q, but %q has no transition on it back to %q",
+		t.From, t.Event, kind, event, t.To, t.From)
+}
+
+// validateIgnoredEvents checks that every event a state ignores is defined, listed
+// once, and has no transition from the state
+func (f *FSMModel) validateIgnoredEvents() error {
+	for _, state := range f.GetStatesSlice() {
+		seen := make(map[string]bool)
+		for _, event := range state.IgnoredEvents {
+			if _, exists := f.Events[event]; !exists {
+				return fmt.Errorf("state %q ignores undefined event %q", state.Name, event)
+			}
+			if seen[event] {
+				return fmt.Errorf("state %q ignores event %q more than once", state.Name, event)
+			}
+			seen[event] = true
+			for _, t := range f.GetTransitionsFrom(state.Name) {
+				if t.Event == event {
+					return fmt.Errorf("state %q ignores event %q but has a transition on it to %q", state.Name, event, t.To)
+				}
+			}
+		}
+	}
 	return nil
 }
 
+// NondeterminismError reports transitions the generated machine cannot choose between
+type NondeterminismError struct {
+	// Conflicts are the conflicting transitions, sorted by state, event, and guard
+	Conflicts []Conflict
+}
+
+func (e *NondeterminismError) Error() string {
+	conflicts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		conflicts[i] = c.String()
+	}
+	return "nondeterministic transitions: " + strings.Join(conflicts, "; ")
+}
+
 // GetState returns the state with the given name, or nil if not found
 func (f *FSMModel) GetState(name string) *State {
 	return f.States[name]
@@ -168,6 +579,28 @@ func (f *FSMModel) GetTransitionsFrom(stateName string) []*Transition {
 	return transitions
 }
 
+// GetTransitionsByState returns the transitions from each state in declaration
+// order. Templates look states up in it rather than calling GetTransitionsFrom,
+// which scans every transition, for each state of a large machine.
+func (f *FSMModel) GetTransitionsByState() map[string][]*Transition {
+	transitions := make(map[string][]*Transition, len(f.States))
+	for _, t := range f.Transitions {
+		transitions[t.From] = append(transitions[t.From], t)
+	}
+	return transitions
+}
+
+// TransitionIndex returns the position of t among the transitions in declaration
+// order, or -1 when t is not a transition of the machine
+func (f *FSMModel) TransitionIndex(t *Transition) int {
+	for i, candidate := range f.Transitions {
+		if candidate == t {
+			return i
+		}
+	}
+	return -1
+}
+
 // GetTransitionsTo returns all transitions to the given state
 func (f *FSMModel) GetTransitionsTo(stateName string) []*Transition {
 	transitions := make([]*Transition, 0)
@@ -179,38 +612,367 @@ func (f *FSMModel) GetTransitionsTo(stateName string) []*Transition {
 	return transitions
 }
 
-// GetStateNames returns all state names (for template compatibility)
+// GetStateNames returns all state names sorted, so that output built from them is
+// stable (for template compatibility)
 func (f *FSMModel) GetStateNames() []string {
 	names := make([]string, 0, len(f.States))
 	for name := range f.States {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
-// GetEventNames returns all event names (for template compatibility)
+// GetEventNames returns all event names sorted, so that output built from them is
+// stable (for template compatibility)
 func (f *FSMModel) GetEventNames() []string {
 	names := make([]string, 0, len(f.Events))
 	for name := range f.Events {
 		names = append(names, name)
 	}
+	sort.Strings(names)
 	return names
 }
 
-// GetStatesSlice returns states as a slice (for template compatibility)
+// GetStatesSlice returns states as a slice sorted by name (for template compatibility)
 func (f *FSMModel) GetStatesSlice() []*State {
 	states := make([]*State, 0, len(f.States))
 	for _, state := range f.States {
 		states = append(states, state)
 	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
 	return states
 }
 
-// GetEventsSlice returns events as a slice (for template compatibility)
+// GetEventsSlice returns events as a slice sorted by name (for template compatibility)
 func (f *FSMModel) GetEventsSlice() []*Event {
 	events := make([]*Event, 0, len(f.Events))
 	for _, event := range f.Events {
 		events = append(events, event)
 	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Name < events[j].Name })
 	return events
 }
+
+// GetUnreachableStateNames returns the names of states that no sequence of
+// transitions leads to from the initial state, sorted. Guards are ignored.
+func (f *FSMModel) GetUnreachableStateNames() []string {
+	outgoing := make(map[string][]*Transition)
+	for _, t := range f.Transitions {
+		outgoing[t.From] = append(outgoing[t.From], t)
+	}
+
+	reached := map[string]bool{f.Initial: true}
+	queue := []string{f.Initial}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		for _, t := range outgoing[from] {
+			if !reached[t.To] {
+				reached[t.To] = true
+				queue = append(queue, t.To)
+			}
+		}
+	}
+
+	var names []string
+	for name := range f.States {
+		if !reached[name] {
+			names = append(names, name)
+		}
+	}
+	return uniqueSorted(names)
+}
+
+// GetFinalStateNames returns the names of all final states, sorted
+func (f *FSMModel) GetFinalStateNames() []string {
+	var names []string
+	for _, state := range f.States {
+		if state.Final {
+			names = append(names, state.Name)
+		}
+	}
+	return uniqueSorted(names)
+}
+
+// PropertyMaxSteps returns the length limit of simulated runs, which is long
+// enough for every reaches_final property to observe a violation
+func (f *FSMModel) PropertyMaxSteps() int {
+	steps := DefaultPropertyMaxSteps
+	for _, property := range f.Properties {
+		if property.Kind == PropertyReachesFinal && property.Within > steps {
+			steps = property.Within
+		}
+	}
+	return steps
+}
+
+// GetDomainEventImports returns the imports used by domain event types, sorted
+func (f *FSMModel) GetDomainEventImports() []string {
+	var used []string
+	for _, imp := range f.Imports {
+		for _, domainEvent := range f.DomainEvents {
+			if domainEvent.Package() == ImportName(imp) {
+				used = append(used, imp)
+				break
+			}
+		}
+	}
+	return uniqueSorted(used)
+}
+
+// UnhandledEventPolicy returns the policy for events the named state has no
+// transition on: its own, else the one of the machine, else UnhandledEventError
+func (f *FSMModel) UnhandledEventPolicy(stateName string) UnhandledEventPolicy {
+	if state := f.States[stateName]; state != nil && state.UnhandledEvent != "" {
+		return state.UnhandledEvent
+	}
+	if f.Options.UnhandledEvent != "" {
+		return f.Options.UnhandledEvent
+	}
+	return UnhandledEventError
+}
+
+// GetInverseTransitions returns the transitions that declare an inverse event, in
+// declaration order
+func (f *FSMModel) GetInverseTransitions() []*Transition {
+	var transitions []*Transition
+	for _, t := range f.Transitions {
+		if t.Inverse != "" {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions
+}
+
+// GetCompensatedTransitions returns the transitions that declare a compensating
+// event, in declaration order
+func (f *FSMModel) GetCompensatedTransitions() []*Transition {
+	var transitions []*Transition
+	for _, t := range f.Transitions {
+		if t.Compensate != "" {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions
+}
+
+// GetCompensatingEvents returns the events that compensate transitions, sorted
+func (f *FSMModel) GetCompensatingEvents() []string {
+	var events []string
+	for _, t := range f.GetCompensatedTransitions() {
+		events = append(events, t.Compensate)
+	}
+	return uniqueSorted(events)
+}
+
+// HasImmutableContext reports whether the context is generated immutable, which
+// takes the immutable_context option and context fields to make immutable
+func (f *FSMModel) HasImmutableContext() bool {
+	return f.Options.ImmutableContext && len(f.Context) > 0
+}
+
+// GetContextField returns the context field with the given name, or nil
+func (f *FSMModel) GetContextField(name string) *ContextField {
+	for _, field := range f.Context {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// HasRequiredContext reports whether any state requires context fields
+func (f *FSMModel) HasRequiredContext() bool {
+	for _, state := range f.States {
+		if len(state.RequiredContext) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRequiredContextFields returns the context fields any state requires, in
+// declaration order
+func (f *FSMModel) GetRequiredContextFields() []*ContextField {
+	var fields []*ContextField
+	for _, field := range f.Context {
+		for _, state := range f.States {
+			if slices.Contains(state.RequiredContext, field.Name) {
+				fields = append(fields, field)
+				break
+			}
+		}
+	}
+	return fields
+}
+
+// HasIgnoredEvents reports whether any state ignores events
+func (f *FSMModel) HasIgnoredEvents() bool {
+	for _, state := range f.States {
+		if len(state.IgnoredEvents) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSLAs reports whether any state or transition declares a max duration
+func (f *FSMModel) HasSLAs() bool {
+	for _, state := range f.States {
+		if state.MaxDuration > 0 {
+			return true
+		}
+	}
+	for _, t := range f.Transitions {
+		if t.MaxDuration > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPayloadEvents returns the events that declare a payload, sorted by name
+func (f *FSMModel) GetPayloadEvents() []*Event {
+	var events []*Event
+	for _, event := range f.GetEventsSlice() {
+		if event.Payload != nil {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// HasRequiredPayloadFields reports whether any event payload has required fields
+func (f *FSMModel) HasRequiredPayloadFields() bool {
+	for _, event := range f.GetPayloadEvents() {
+		if len(event.Payload.Required) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetEventTypeImports returns the imports used by domain event and event payload
+// types, sorted
+func (f *FSMModel) GetEventTypeImports() []string {
+	used := f.GetDomainEventImports()
+	for _, imp := range f.Imports {
+		for _, event := range f.GetPayloadEvents() {
+			if event.Payload.Package() == ImportName(imp) {
+				used = append(used, imp)
+				break
+			}
+		}
+	}
+	return uniqueSorted(used)
+}
+
+// GetBindingImports returns the imports used by the types of the binding, sorted
+func (f *FSMModel) GetBindingImports() []string {
+	if f.Binding == nil {
+		return nil
+	}
+	var used []string
+	for _, imp := range f.Imports {
+		for _, pkg := range f.Binding.packages() {
+			if pkg == ImportName(imp) {
+				used = append(used, imp)
+				break
+			}
+		}
+	}
+	return uniqueSorted(used)
+}
+
+// HasTransition returns true if any transition leaves the given state on the given event
+func (f *FSMModel) HasTransition(stateName, eventName string) bool {
+	for _, t := range f.Transitions {
+		if t.From == stateName && t.Event == eventName {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCandidates returns the transitions from the given state on the given event in
+// declaration order. The first of them whose guard passes is taken.
+func (f *FSMModel) GetCandidates(stateName, eventName string) []*Transition {
+	var candidates []*Transition
+	for _, t := range f.Transitions {
+		if t.From == stateName && t.Event == eventName {
+			candidates = append(candidates, t)
+		}
+	}
+	return candidates
+}
+
+// GetGuardNames returns the distinct guard names referenced by transitions, sorted
+func (f *FSMModel) GetGuardNames() []string {
+	names := make([]string, 0)
+	for _, t := range f.Transitions {
+		names = append(names, t.Guard)
+	}
+	return uniqueSorted(names)
+}
+
+// GetActionNames returns the distinct transition action names, sorted, including
+// the before_transition and after_transition actions of the machine
+func (f *FSMModel) GetActionNames() []string {
+	names := []string{f.BeforeTransition, f.AfterTransition}
+	for _, t := range f.Transitions {
+		names = append(names, t.Action)
+	}
+	return uniqueSorted(names)
+}
+
+// GetDescribedTransitions returns the transitions with a description whose guard
+// or action is callback, in declaration order, for documenting the callback
+func (f *FSMModel) GetDescribedTransitions(callback string) []*Transition {
+	var described []*Transition
+	for _, t := range f.Transitions {
+		if t.Description != "" && (t.Guard == callback || t.Action == callback) {
+			described = append(described, t)
+		}
+	}
+	return described
+}
+
+// GetEntryActionNames returns the distinct state entry action names, sorted
+func (f *FSMModel) GetEntryActionNames() []string {
+	names := make([]string, 0)
+	for _, s := range f.States {
+		names = append(names, s.EntryAction)
+	}
+	return uniqueSorted(names)
+}
+
+// GetExitActionNames returns the distinct state exit action names, sorted
+func (f *FSMModel) GetExitActionNames() []string {
+	names := make([]string, 0)
+	for _, s := range f.States {
+		names = append(names, s.ExitAction)
+	}
+	return uniqueSorted(names)
+}
+
+// GetStateActionNames returns the distinct names of state entry and exit actions,
+// which share a signature, sorted
+func (f *FSMModel) GetStateActionNames() []string {
+	return uniqueSorted(append(f.GetEntryActionNames(), f.GetExitActionNames()...))
+}
+
+// uniqueSorted returns the non-empty distinct values of names in sorted order
+func uniqueSorted(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -281,6 +282,21 @@ func TestFSMModel_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "nondeterministic transitions",
+			setup: func() *FSMModel {
+				fsm, _ := NewFSMModel("OrderStateMachine", "pending")
+				fsm.AddState(&State{Name: "pending"})
+				fsm.AddState(&State{Name: "approved"})
+				fsm.AddState(&State{Name: "rejected"})
+				fsm.AddEvent(&Event{Name: "review"})
+				fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "review"})
+				fsm.AddTransition(&Transition{From: "pending", To: "rejected", Event: "review"})
+				return fsm
+			},
+			wantErr: true,
+			errMsg:  `nondeterministic transitions: state "pending" has 2 unguarded transitions on "review" (to "approved", "rejected")`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -300,6 +316,152 @@ func TestFSMModel_Validate(t *testing.T) {
 	}
 }
 
+func TestFSMModel_ValidateGuardConditions(t *testing.T) {
+	newModel := func(t *testing.T) *FSMModel {
+		fsm, err := NewFSMModel("OrderStateMachine", "pending")
+		require.NoError(t, err)
+		fsm.AddState(&State{Name: "pending"})
+		fsm.AddState(&State{Name: "approved"})
+		fsm.AddEvent(&Event{Name: "approve"})
+		fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve", Guard: "hasPayment"})
+		require.NoError(t, fsm.AddContextField(&ContextField{Name: "amount", Type: FieldInt}))
+		return fsm
+	}
+
+	fsm := newModel(t)
+	require.NoError(t, fsm.AddGuardCondition(&GuardCondition{Name: "hasPayment", When: "amount > 0"}))
+	assert.NoError(t, fsm.Validate())
+	assert.Equal(t, "amount > 0", fsm.GuardPredicate("hasPayment").String())
+	assert.Nil(t, fsm.GuardPredicate("isFraud"))
+
+	assert.ErrorContains(t, fsm.AddContextField(&ContextField{Name: "amount", Type: FieldFloat}), `context field "amount" already exists`)
+	assert.ErrorContains(t, fsm.AddGuardCondition(&GuardCondition{Name: "hasPayment", When: "amount > 1"}), `guard "hasPayment" already has a condition`)
+
+	fsm = newModel(t)
+	require.NoError(t, fsm.AddGuardCondition(&GuardCondition{Name: "isFraud", When: "amount > 0"}))
+	assert.EqualError(t, fsm.Validate(), `invalid guard: guard "isFraud" is not used by any transition`)
+
+	fsm = newModel(t)
+	require.NoError(t, fsm.AddGuardCondition(&GuardCondition{Name: "hasPayment", When: "total > 0"}))
+	assert.EqualError(t, fsm.Validate(), `invalid guard: guard "hasPayment": condition "total > 0": "total" is not a declared context field`)
+
+	fsm = newModel(t)
+	require.NoError(t, fsm.AddContextField(&ContextField{Name: "at", Type: "time"}))
+	assert.ErrorContains(t, fsm.Validate(), "invalid context:")
+}
+
+func TestFSMModel_ValidateRequiredContext(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "shipped", RequiredContext: []string{"trackingNumber"}})
+	fsm.AddEvent(&Event{Name: "ship"})
+	fsm.AddTransition(&Transition{From: "pending", To: "shipped", Event: "ship"})
+	require.NoError(t, fsm.AddContextField(&ContextField{Name: "trackingNumber", Type: FieldString}))
+
+	assert.NoError(t, fsm.Validate())
+	assert.True(t, fsm.HasRequiredContext())
+	assert.Equal(t, []*ContextField{fsm.GetContextField("trackingNumber")}, fsm.GetRequiredContextFields())
+	assert.Equal(t, FieldString, fsm.GetContextField("trackingNumber").Type)
+	assert.Nil(t, fsm.GetContextField("carrier"))
+
+	fsm.GetState("shipped").RequiredContext = []string{"carrier"}
+	assert.EqualError(t, fsm.Validate(), `state "shipped" requires undefined context field "carrier"`)
+
+	fsm.GetState("shipped").RequiredContext = []string{"trackingNumber", "trackingNumber"}
+	assert.EqualError(t, fsm.Validate(), `state "shipped" requires context field "trackingNumber" more than once`)
+
+	fsm.GetState("shipped").RequiredContext = nil
+	assert.False(t, fsm.HasRequiredContext())
+	assert.Empty(t, fsm.GetRequiredContextFields())
+}
+
+func TestFSMModel_ValidateUndo(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "approved"})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddEvent(&Event{Name: "unapprove"})
+	fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve", Inverse: "unapprove"})
+	fsm.AddTransition(&Transition{From: "approved", To: "pending", Event: "unapprove"})
+
+	assert.NoError(t, fsm.Validate())
+	inverses := fsm.GetInverseTransitions()
+	require.Len(t, inverses, 1)
+	assert.Equal(t, "approve", inverses[0].Event)
+
+	inverses[0].Inverse = "cancel"
+	assert.EqualError(t, fsm.Validate(), `invalid transition: transition from "pending" on "approve" has undefined inverse event "cancel"`)
+
+	inverses[0].Inverse = "approve"
+	assert.EqualError(t, fsm.Validate(), `invalid transition: transition from "pending" on "approve" has inverse "approve", but "approved" has no transition on it back to "pending"`)
+
+	inverses[0].Inverse = ""
+	assert.Empty(t, fsm.GetCompensatedTransitions())
+	inverses[0].Compensate = "unapprove"
+	assert.NoError(t, fsm.Validate())
+	assert.Len(t, fsm.GetCompensatedTransitions(), 1)
+	assert.Equal(t, []string{"unapprove"}, fsm.GetCompensatingEvents())
+
+	inverses[0].Compensate = "cancel"
+	assert.EqualError(t, fsm.Validate(), `invalid transition: transition from "pending" on "approve" has undefined compensation event "cancel"`)
+
+	inverses[0].Compensate = "approve"
+	assert.EqualError(t, fsm.Validate(), `invalid transition: transition from "pending" on "approve" has compensation "approve", but "approved" has no transition on it back to "pending"`)
+}
+
+func TestFSMModel_HasSLAs(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "approved"})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve"})
+	assert.False(t, fsm.HasSLAs())
+
+	fsm.Transitions[0].MaxDuration = time.Hour
+	assert.True(t, fsm.HasSLAs())
+	fsm.Transitions[0].MaxDuration = 0
+	fsm.GetState("approved").MaxDuration = 48 * time.Hour
+	assert.True(t, fsm.HasSLAs())
+	assert.NoError(t, fsm.Validate())
+
+	fsm.GetState("approved").MaxDuration = -time.Hour
+	assert.EqualError(t, fsm.Validate(), `invalid state: state "approved" max duration cannot be negative`)
+	fsm.GetState("approved").MaxDuration = 0
+	fsm.Transitions[0].MaxDuration = -time.Hour
+	assert.EqualError(t, fsm.Validate(), `invalid transition: transition from "pending" on "approve" max duration cannot be negative`)
+}
+
+func TestFSMModel_UnhandledEventPolicy(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "shipped", UnhandledEvent: UnhandledEventIgnore})
+	fsm.AddEvent(&Event{Name: "ship"})
+	fsm.AddTransition(&Transition{From: "pending", To: "shipped", Event: "ship"})
+	require.NoError(t, fsm.Validate())
+
+	assert.Equal(t, UnhandledEventError, fsm.UnhandledEventPolicy("pending"))
+	assert.Equal(t, UnhandledEventIgnore, fsm.UnhandledEventPolicy("shipped"))
+
+	fsm.Options.UnhandledEvent = UnhandledEventLog
+	assert.Equal(t, UnhandledEventLog, fsm.UnhandledEventPolicy("pending"))
+	assert.Equal(t, UnhandledEventIgnore, fsm.UnhandledEventPolicy("shipped"), "states override the machine")
+
+	fsm.Options.UnhandledEvent = "drop"
+	assert.EqualError(t, fsm.Validate(), `invalid options: unhandled event policy "drop" is not one of "error", "ignore", "log"`)
+
+	fsm.Options.UnhandledEvent = ""
+	fsm.Options.IdempotencyKeys = -1
+	assert.EqualError(t, fsm.Validate(), "invalid options: idempotency keys -1 cannot be negative")
+
+	fsm.Options.IdempotencyKeys = 0
+	fsm.GetState("shipped").UnhandledEvent = "drop"
+	assert.EqualError(t, fsm.Validate(), `invalid state: state "shipped": unhandled event policy "drop" is not one of "error", "ignore", "log"`)
+}
+
 func TestFSMModel_GetState(t *testing.T) {
 	fsm, err := NewFSMModel("OrderStateMachine", "pending")
 	require.NoError(t, err)
@@ -415,8 +577,10 @@ func TestFSMModel_GetTransitionsFrom(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			transitions := fsm.GetTransitionsFrom(tt.stateName)
 			assert.Len(t, transitions, tt.wantCount)
+			assert.Len(t, fsm.GetTransitionsByState()[tt.stateName], tt.wantCount)
 		})
 	}
+	assert.Equal(t, []*Transition{t1, t2}, fsm.GetTransitionsByState()["pending"], "Transitions keep declaration order")
 }
 
 func TestFSMModel_GetTransitionsTo(t *testing.T) {
@@ -459,3 +623,166 @@ func TestFSMModel_GetTransitionsTo(t *testing.T) {
 		})
 	}
 }
+
+func TestFSMModel_TransitionIndex(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "approved"})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddEvent(&Event{Name: "reopen"})
+
+	t1 := &Transition{From: "pending", To: "approved", Event: "approve"}
+	t2 := &Transition{From: "approved", To: "pending", Event: "reopen"}
+	fsm.AddTransition(t1)
+	fsm.AddTransition(t2)
+
+	assert.Equal(t, 0, fsm.TransitionIndex(t1))
+	assert.Equal(t, 1, fsm.TransitionIndex(t2))
+	assert.Equal(t, -1, fsm.TransitionIndex(&Transition{From: "pending", To: "approved", Event: "approve"}),
+		"An equal transition that is not part of the machine has no index")
+}
+
+func TestFSMModel_GetUnreachableStateNames(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+
+	for _, name := range []string{"pending", "approved", "shipped", "archived", "legacy"} {
+		fsm.AddState(&State{Name: name})
+	}
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddEvent(&Event{Name: "ship"})
+	fsm.AddEvent(&Event{Name: "restore"})
+	fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve"})
+	fsm.AddTransition(&Transition{From: "approved", To: "shipped", Event: "ship"})
+	fsm.AddTransition(&Transition{From: "archived", To: "pending", Event: "restore"})
+
+	assert.Equal(t, []string{"archived", "legacy"}, fsm.GetUnreachableStateNames(),
+		"States are unreachable unless a path leads to them from the initial state")
+}
+
+func TestFSMModel_GetStatesSlice_SortedByName(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+
+	for _, name := range []string{"shipped", "pending", "approved"} {
+		fsm.AddState(&State{Name: name})
+	}
+	for _, name := range []string{"ship", "approve"} {
+		fsm.AddEvent(&Event{Name: name})
+	}
+
+	states := fsm.GetStatesSlice()
+	require.Len(t, states, 3)
+	assert.Equal(t, []string{"approved", "pending", "shipped"},
+		[]string{states[0].Name, states[1].Name, states[2].Name},
+		"Enum values are derived from the name order and must be stable")
+
+	events := fsm.GetEventsSlice()
+	require.Len(t, events, 2)
+	assert.Equal(t, "approve", events[0].Name)
+	assert.Equal(t, "ship", events[1].Name)
+
+	assert.Equal(t, []string{"approved", "pending", "shipped"}, fsm.GetStateNames())
+	assert.Equal(t, []string{"approve", "ship"}, fsm.GetEventNames())
+}
+
+func TestFSMModel_HasTransition(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "approved"})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddEvent(&Event{Name: "ship"})
+	fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve"})
+
+	assert.True(t, fsm.HasTransition("pending", "approve"))
+	assert.False(t, fsm.HasTransition("pending", "ship"), "ship is not handled in pending")
+	assert.False(t, fsm.HasTransition("approved", "approve"), "approved has no outgoing transitions")
+}
+
+func TestFSMModel_GetCandidates(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "approved"})
+	fsm.AddState(&State{Name: "on_hold"})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddEvent(&Event{Name: "ship"})
+	guarded := &Transition{From: "pending", To: "approved", Event: "approve", Guard: "hasPayment"}
+	fallback := &Transition{From: "pending", To: "on_hold", Event: "approve"}
+	fsm.AddTransition(guarded)
+	fsm.AddTransition(&Transition{From: "approved", To: "on_hold", Event: "approve"})
+	fsm.AddTransition(fallback)
+
+	assert.Equal(t, []*Transition{guarded, fallback}, fsm.GetCandidates("pending", "approve"), "in declaration order")
+	assert.Empty(t, fsm.GetCandidates("pending", "ship"))
+}
+
+func TestFSMModel_CallbackNames(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+
+	fsm.AddState(&State{Name: "pending", EntryAction: "logEntry", ExitAction: "logExit"})
+	fsm.AddState(&State{Name: "approved", EntryAction: "logEntry"})
+	fsm.AddState(&State{Name: "cancelled"})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddEvent(&Event{Name: "cancel"})
+
+	// The same guard and action protect several transitions
+	fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve", Guard: "isAuthorized", Action: "audit"})
+	fsm.AddTransition(&Transition{From: "pending", To: "cancelled", Event: "cancel", Guard: "isAuthorized", Action: "audit"})
+	fsm.AddTransition(&Transition{From: "approved", To: "cancelled", Event: "cancel", Guard: "canRefund", Action: "refund"})
+
+	assert.Equal(t, []string{"canRefund", "isAuthorized"}, fsm.GetGuardNames())
+	assert.Equal(t, []string{"audit", "refund"}, fsm.GetActionNames())
+	assert.Equal(t, []string{"logEntry"}, fsm.GetEntryActionNames())
+	assert.Equal(t, []string{"logExit"}, fsm.GetExitActionNames())
+	assert.Equal(t, []string{"logEntry", "logExit"}, fsm.GetStateActionNames())
+
+	assert.Empty(t, fsm.GetDescribedTransitions("audit"), "transitions without a description are left out")
+	fsm.Transitions[1].Description = "Cancel before approval"
+	fsm.Transitions[2].Description = "Refund after approval"
+	assert.Equal(t, []*Transition{fsm.Transitions[1]}, fsm.GetDescribedTransitions("isAuthorized"))
+	assert.Equal(t, []*Transition{fsm.Transitions[1]}, fsm.GetDescribedTransitions("audit"))
+	assert.Equal(t, []*Transition{fsm.Transitions[2]}, fsm.GetDescribedTransitions("refund"))
+}
+
+func TestFSMModel_UseVersionedName(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+	assert.Empty(t, fsm.VersionSuffix())
+
+	assert.EqualError(t, fsm.UseVersionedName(), "machine OrderStateMachine needs a version to be generated side by side")
+
+	fsm.Version = 2
+	require.NoError(t, fsm.UseVersionedName())
+	assert.Equal(t, "OrderStateMachineV2", fsm.Name)
+	assert.Equal(t, "OrderStateMachine", fsm.Base)
+	assert.Equal(t, "V2", fsm.VersionSuffix())
+	assert.True(t, fsm.Options.SideBySide)
+
+	require.NoError(t, fsm.UseVersionedName())
+	assert.Equal(t, "OrderStateMachineV2", fsm.Name, "renaming again keeps the name")
+}
+
+func TestFSMModel_ValidateCallbackNames(t *testing.T) {
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+
+	fsm.AddState(&State{Name: "pending", ExitAction: "audit"})
+	fsm.AddState(&State{Name: "approved", EntryAction: "audit"})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve", Guard: "isAuthorized", Action: "charge"})
+	fsm.Options.Callbacks = true
+	assert.NoError(t, fsm.Validate(), "entry and exit actions share a signature")
+
+	fsm.States["pending"].ExitAction = "charge"
+	assert.EqualError(t, fsm.Validate(), `invalid options: callbacks need distinct names, but "charge" is both an action and a state action`)
+
+	fsm.Options.Callbacks = false
+	assert.NoError(t, fsm.Validate(), "the names only need to be distinct for the callbacks interface")
+}
@@ -1,5 +1,13 @@
 package model
 
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
 // StateGraph represents a graph-based view of the FSM for analysis
 type StateGraph struct {
 	// FSM is the underlying FSM model
@@ -52,19 +60,35 @@ func (g *StateGraph) computeReachability() {
 	g.reachable = visited
 }
 
-// dfs performs depth-first search to find all reachable states
+// dfs performs depth-first search to find all reachable states. It keeps an
+// explicit stack, as do the other searches of the graph, so that machines with
+// thousands of states cannot overflow the goroutine stack.
 func (g *StateGraph) dfs(state string, visited map[string]bool) {
 	if visited[state] {
 		return
 	}
 
 	visited[state] = true
-
-	for _, transition := range g.adjacencyList[state] {
-		g.dfs(transition.To, visited)
+	stack := []string{state}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, transition := range g.adjacencyList[current] {
+			if !visited[transition.To] {
+				visited[transition.To] = true
+				stack = append(stack, transition.To)
+			}
+		}
 	}
 }
 
+// searchFrame is a state on the stack of a depth-first search and the index of
+// the next of its outgoing transitions to follow
+type searchFrame struct {
+	state string
+	next  int
+}
+
 // GetOutgoingTransitions returns all transitions leaving the given state
 func (g *StateGraph) GetOutgoingTransitions(state string) []*Transition {
 	if transitions, exists := g.adjacencyList[state]; exists {
@@ -86,6 +110,53 @@ func (g *StateGraph) IsReachable(state string) bool {
 	return g.reachable[state]
 }
 
+// ReachableFrom returns the states that some sequence of transitions leads to
+// from the given state, including the state itself, sorted. Guards are ignored.
+func (g *StateGraph) ReachableFrom(state string) []string {
+	if _, ok := g.adjacencyList[state]; !ok {
+		return nil
+	}
+	visited := make(map[string]bool)
+	g.dfs(state, visited)
+
+	states := make([]string, 0, len(visited))
+	for name := range visited {
+		states = append(states, name)
+	}
+	sort.Strings(states)
+	return states
+}
+
+// CanReach reports whether some sequence of transitions leads from the given
+// state to any of the targets; every state reaches itself. Guards are ignored.
+func (g *StateGraph) CanReach(state string, targets ...string) bool {
+	return g.coReachable(targets)[state]
+}
+
+// coReachable returns the states from which any of the targets can be reached,
+// found by walking the transitions backwards from the targets
+func (g *StateGraph) coReachable(targets []string) map[string]bool {
+	reached := make(map[string]bool)
+	var queue []string
+	for _, target := range targets {
+		if _, ok := g.reverseAdjacencyList[target]; ok && !reached[target] {
+			reached[target] = true
+			queue = append(queue, target)
+		}
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, t := range g.reverseAdjacencyList[state] {
+			if !reached[t.From] {
+				reached[t.From] = true
+				queue = append(queue, t.From)
+			}
+		}
+	}
+	return reached
+}
+
 // GetUnreachableStates returns a list of states that are not reachable from the initial state
 func (g *StateGraph) GetUnreachableStates() []string {
 	unreachable := make([]string, 0)
@@ -101,35 +172,609 @@ func (g *StateGraph) GetUnreachableStates() []string {
 
 // HasCycles returns true if the graph contains cycles
 func (g *StateGraph) HasCycles() bool {
-	visited := make(map[string]bool)
-	recStack := make(map[string]bool)
+	for _, cyclic := range g.Condense().Cyclic {
+		if cyclic {
+			return true
+		}
+	}
+	return false
+}
 
-	for stateName := range g.FSM.States {
-		if !visited[stateName] {
-			if g.hasCycleUtil(stateName, visited, recStack) {
-				return true
+// FindCycles returns the elementary cycles of the graph, paths that return to
+// their first state without visiting any other state twice, stopping after limit
+// cycles. Each cycle starts at its state that sorts first; cycles are ordered by
+// that state, then by length, then by the declaration order of their
+// transitions. Cycles through the same states on the same events that differ
+// only in guards are reported once. A limit of 0 or less returns every cycle.
+func (g *StateGraph) FindCycles(limit int) [][]*Transition {
+	names := g.FSM.GetStateNames()
+	sort.Strings(names)
+	rank := make(map[string]int, len(names))
+	for i, name := range names {
+		rank[name] = i
+	}
+	c := g.Condense()
+
+	var cycles [][]*Transition
+	seen := make(map[string]bool)
+	for _, start := range names {
+		component := c.ComponentOf(start)
+		if !c.Cyclic[component] {
+			continue
+		}
+
+		// Only visit the states of the component that sort after start, so
+		// each cycle is found once, from its first state
+		var found [][]*Transition
+		onPath := map[string]bool{start: true}
+		var path []*Transition
+		frames := []searchFrame{{state: start}}
+		for len(frames) > 0 && (limit <= 0 || len(cycles)+len(found) < limit) {
+			frame := &frames[len(frames)-1]
+			transitions := g.adjacencyList[frame.state]
+			if frame.next == len(transitions) {
+				frames = frames[:len(frames)-1]
+				if len(path) > 0 {
+					onPath[frame.state] = false
+					path = path[:len(path)-1]
+				}
+				continue
+			}
+			t := transitions[frame.next]
+			frame.next++
+
+			if c.ComponentOf(t.To) != component || rank[t.To] < rank[start] {
+				continue
+			}
+			if t.To == start {
+				cycle := append(append([]*Transition{}, path...), t)
+				if key := cycleKey(cycle); !seen[key] {
+					seen[key] = true
+					found = append(found, cycle)
+				}
+				continue
+			}
+			if onPath[t.To] {
+				continue
 			}
+			onPath[t.To] = true
+			path = append(path, t)
+			frames = append(frames, searchFrame{state: t.To})
+		}
+
+		sort.SliceStable(found, func(i, j int) bool { return len(found[i]) < len(found[j]) })
+		cycles = append(cycles, found...)
+		if limit > 0 && len(cycles) >= limit {
+			break
 		}
 	}
+	return cycles
+}
 
-	return false
+// cycleKey identifies a cycle by its states and events
+func cycleKey(cycle []*Transition) string {
+	var b strings.Builder
+	for _, t := range cycle {
+		b.WriteString(t.From + "\x00" + t.Event + "\x00")
+	}
+	return b.String()
 }
 
-// hasCycleUtil is a utility function for cycle detection using DFS
-func (g *StateGraph) hasCycleUtil(state string, visited, recStack map[string]bool) bool {
-	visited[state] = true
-	recStack[state] = true
+// FormatPath renders a path as its states joined by the events between them,
+// e.g. "pending --approve [hasPayment]--> approved --ship--> shipped"
+func FormatPath(from string, path []*Transition) string {
+	var b strings.Builder
+	b.WriteString(from)
+	for _, t := range path {
+		b.WriteString(" --" + t.Event)
+		if t.Guard != "" {
+			b.WriteString(" [" + t.Guard + "]")
+		}
+		b.WriteString("--> " + t.To)
+	}
+	return b.String()
+}
+
+// ShortestPath returns the fewest transitions that lead from one state to another,
+// preferring earlier declared transitions between paths of equal length. It returns
+// an empty path when from and to are the same state, and nil when to cannot be
+// reached. Guards are ignored.
+func (g *StateGraph) ShortestPath(from, to string) []*Transition {
+	if from == to {
+		return []*Transition{}
+	}
 
-	for _, transition := range g.adjacencyList[state] {
-		if !visited[transition.To] {
-			if g.hasCycleUtil(transition.To, visited, recStack) {
-				return true
+	via := map[string]*Transition{from: nil}
+	queue := []string{from}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, t := range g.adjacencyList[state] {
+			if _, seen := via[t.To]; seen {
+				continue
 			}
-		} else if recStack[transition.To] {
-			return true
+			via[t.To] = t
+			if t.To == to {
+				var path []*Transition
+				for step := t; step != nil; step = via[step.From] {
+					path = append(path, step)
+				}
+				slices.Reverse(path)
+				return path
+			}
+			queue = append(queue, t.To)
 		}
 	}
+	return nil
+}
 
-	recStack[state] = false
-	return false
+// SimplePaths returns every path of at most maxLen transitions from one state to
+// another that visits no state twice, shortest first and otherwise in declaration
+// order of their transitions. Guards are ignored.
+func (g *StateGraph) SimplePaths(from, to string, maxLen int) [][]*Transition {
+	if from == to {
+		return [][]*Transition{{}}
+	}
+
+	var paths [][]*Transition
+	// Skip states that cannot lead to the target, whose paths are dead ends
+	useful := g.coReachable([]string{to})
+	visited := map[string]bool{from: true}
+	var path []*Transition
+	frames := []searchFrame{{state: from}}
+	for len(frames) > 0 {
+		frame := &frames[len(frames)-1]
+		transitions := g.adjacencyList[frame.state]
+		if frame.next == len(transitions) || len(path) == maxLen {
+			frames = frames[:len(frames)-1]
+			if len(path) > 0 {
+				visited[frame.state] = false
+				path = path[:len(path)-1]
+			}
+			continue
+		}
+		t := transitions[frame.next]
+		frame.next++
+
+		if visited[t.To] || !useful[t.To] {
+			continue
+		}
+		if t.To == to {
+			paths = append(paths, append(append([]*Transition{}, path...), t))
+			continue
+		}
+		visited[t.To] = true
+		path = append(path, t)
+		frames = append(frames, searchFrame{state: t.To})
+	}
+
+	sort.SliceStable(paths, func(i, j int) bool { return len(paths[i]) < len(paths[j]) })
+	return paths
+}
+
+// Conflict is a state and event with transitions the machine cannot choose
+// between: several unguarded transitions, or several with the same guard
+type Conflict struct {
+	// State is the state the transitions leave
+	State string
+
+	// Event is the event the transitions are triggered by
+	Event string
+
+	// Guard is the guard the transitions share; empty when they are unguarded
+	Guard string
+
+	// Transitions are the conflicting transitions in declaration order
+	Transitions []*Transition
+}
+
+func (c Conflict) String() string {
+	targets := make([]string, len(c.Transitions))
+	for i, t := range c.Transitions {
+		targets[i] = fmt.Sprintf("%q", t.To)
+	}
+	kind := "unguarded transitions"
+	if c.Guard != "" {
+		kind = fmt.Sprintf("transitions with guard %q", c.Guard)
+	}
+	return fmt.Sprintf("state %q has %d %s on %q (to %s)", c.State, len(c.Transitions), kind, c.Event, strings.Join(targets, ", "))
+}
+
+// FindNondeterminism returns the conflicts of the machine, sorted by state, event,
+// and guard. A guarded transition followed by an unguarded fallback is not a
+// conflict, since the guard decides between them.
+func (g *StateGraph) FindNondeterminism() []Conflict {
+	states := make([]string, 0, len(g.adjacencyList))
+	for state := range g.adjacencyList {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	var conflicts []Conflict
+	for _, state := range states {
+		groups := make(map[[2]string][]*Transition)
+		for _, t := range g.adjacencyList[state] {
+			key := [2]string{t.Event, t.Guard}
+			groups[key] = append(groups[key], t)
+		}
+
+		var found []Conflict
+		for key, transitions := range groups {
+			if len(transitions) > 1 {
+				found = append(found, Conflict{State: state, Event: key[0], Guard: key[1], Transitions: transitions})
+			}
+		}
+		sort.Slice(found, func(i, j int) bool {
+			if found[i].Event != found[j].Event {
+				return found[i].Event < found[j].Event
+			}
+			return found[i].Guard < found[j].Guard
+		})
+		conflicts = append(conflicts, found...)
+	}
+	return conflicts
+}
+
+// StateEvent is a state and an event
+type StateEvent struct {
+	State string
+	Event string
+}
+
+// UnhandledPairs returns every state and event with no transition from the state
+// on the event, sorted by state and event. Final states and ignored events are
+// included; callers decide which gaps matter.
+func (g *StateGraph) UnhandledPairs() []StateEvent {
+	var pairs []StateEvent
+	for _, state := range g.FSM.GetStatesSlice() {
+		handled := make(map[string]bool)
+		for _, t := range g.adjacencyList[state.Name] {
+			handled[t.Event] = true
+		}
+		for _, event := range g.FSM.GetEventsSlice() {
+			if !handled[event.Name] {
+				pairs = append(pairs, StateEvent{State: state.Name, Event: event.Name})
+			}
+		}
+	}
+	return pairs
+}
+
+// Trap is a cycle of reachable states that runs of the machine can enter but from
+// which no final state can be reached
+type Trap struct {
+	// States are the states of the strongly connected component, sorted
+	States []string
+
+	// Cycle is a shortest cycle through the first of the states
+	Cycle []*Transition
+}
+
+func (t Trap) String() string {
+	names := make([]string, len(t.States))
+	for i, s := range t.States {
+		names[i] = fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("no final state is reachable from %s (cycle %s)", strings.Join(names, ", "), FormatPath(t.States[0], t.Cycle))
+}
+
+// FindTraps returns the traps of the machine, sorted by their first state. A
+// trap is a reachable strongly connected component with at least one
+// transition inside it and no path to a final state. Machines without final
+// states run forever by design and have no traps. States without outgoing
+// transitions are not traps; they are dead ends. Guards are ignored.
+func (g *StateGraph) FindTraps() []Trap {
+	finals := g.FSM.GetFinalStateNames()
+	if len(finals) == 0 {
+		return nil
+	}
+
+	canFinish := g.coReachable(finals)
+
+	var traps []Trap
+	for _, component := range g.SCCs() {
+		first := component[0]
+		if canFinish[first] || !g.reachable[first] {
+			continue
+		}
+		if cycle := g.shortestCycle(first); cycle != nil {
+			traps = append(traps, Trap{States: component, Cycle: cycle})
+		}
+	}
+	sort.Slice(traps, func(i, j int) bool { return traps[i].States[0] < traps[j].States[0] })
+	return traps
+}
+
+// shortestCycle returns the fewest transitions that lead from a state back to
+// itself, or nil when the state is on no cycle
+func (g *StateGraph) shortestCycle(state string) []*Transition {
+	var shortest []*Transition
+	for _, t := range g.adjacencyList[state] {
+		rest := g.ShortestPath(t.To, state)
+		if rest == nil {
+			continue
+		}
+		if shortest == nil || len(rest)+1 < len(shortest) {
+			shortest = append([]*Transition{t}, rest...)
+		}
+	}
+	return shortest
+}
+
+// SCCs returns the strongly connected components of the graph: the largest sets of
+// states that can each be reached from the others. Each component is sorted, and
+// the components are in the topological order of Condense.
+func (g *StateGraph) SCCs() [][]string {
+	return g.Condense().Components
+}
+
+// Condensation is the graph of the strongly connected components of a machine.
+// It has no cycles, so it shows the phases runs pass through: a run can move from
+// a component only to components after it.
+type Condensation struct {
+	// Components are the strongly connected components, each sorted, in
+	// topological order; ties are broken by the name of their first state
+	Components [][]string
+
+	// Edges are the indices of the components that transitions lead to from each
+	// component, sorted
+	Edges [][]int
+
+	// Cyclic reports for each component whether a transition stays inside it,
+	// that is, whether runs can loop there
+	Cyclic []bool
+
+	component map[string]int
+}
+
+// ComponentOf returns the index of the component of a state, or -1 when the
+// state is not defined
+func (c *Condensation) ComponentOf(state string) int {
+	if i, ok := c.component[state]; ok {
+		return i
+	}
+	return -1
+}
+
+// Depth returns the number of components on the longest chain of the
+// condensation, that is, the most phases a run can pass through
+func (c *Condensation) Depth() int {
+	depth := make([]int, len(c.Components))
+	longest := 0
+	for i := len(c.Components) - 1; i >= 0; i-- {
+		depth[i] = 1
+		for _, next := range c.Edges[i] {
+			depth[i] = max(depth[i], depth[next]+1)
+		}
+		longest = max(longest, depth[i])
+	}
+	return longest
+}
+
+// Condense builds the condensation of the graph. Guards are ignored.
+func (g *StateGraph) Condense() *Condensation {
+	components := g.tarjan()
+	component := make(map[string]int)
+	for i, states := range components {
+		for _, state := range states {
+			component[state] = i
+		}
+	}
+
+	// Order the components topologically, taking the ready component with the
+	// smallest first state at each step so the order does not depend on map
+	// iteration
+	successors := make([]map[int]bool, len(components))
+	indegree := make([]int, len(components))
+	cyclic := make([]bool, len(components))
+	for i, states := range components {
+		successors[i] = make(map[int]bool)
+		for _, state := range states {
+			for _, t := range g.adjacencyList[state] {
+				j := component[t.To]
+				if j == i {
+					cyclic[i] = true
+				} else if !successors[i][j] {
+					successors[i][j] = true
+					indegree[j]++
+				}
+			}
+		}
+	}
+	ready := &componentQueue{components: components}
+	for i := range components {
+		if indegree[i] == 0 {
+			ready.indices = append(ready.indices, i)
+		}
+	}
+	heap.Init(ready)
+	var order []int
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		order = append(order, i)
+		for j := range successors[i] {
+			if indegree[j]--; indegree[j] == 0 {
+				heap.Push(ready, j)
+			}
+		}
+	}
+
+	position := make([]int, len(components))
+	for pos, i := range order {
+		position[i] = pos
+	}
+	c := &Condensation{
+		Components: make([][]string, len(order)),
+		Edges:      make([][]int, len(order)),
+		Cyclic:     make([]bool, len(order)),
+		component:  make(map[string]int, len(component)),
+	}
+	for pos, i := range order {
+		c.Components[pos] = components[i]
+		c.Cyclic[pos] = cyclic[i]
+		c.Edges[pos] = []int{}
+		for j := range successors[i] {
+			c.Edges[pos] = append(c.Edges[pos], position[j])
+		}
+		sort.Ints(c.Edges[pos])
+	}
+	for state, i := range component {
+		c.component[state] = position[i]
+	}
+	return c
+}
+
+// tarjan returns the strongly connected components of the graph, each sorted,
+// using Tarjan's algorithm
+func (g *StateGraph) tarjan() [][]string {
+	names := g.FSM.GetStateNames()
+	sort.Strings(names)
+
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	open := func(state string) {
+		index[state] = len(index)
+		lowlink[state] = index[state]
+		stack = append(stack, state)
+		onStack[state] = true
+	}
+	for _, name := range names {
+		if _, seen := index[name]; seen {
+			continue
+		}
+		open(name)
+		frames := []searchFrame{{state: name}}
+		for len(frames) > 0 {
+			frame := &frames[len(frames)-1]
+			state := frame.state
+			transitions := g.adjacencyList[state]
+			if frame.next < len(transitions) {
+				to := transitions[frame.next].To
+				frame.next++
+				if _, seen := index[to]; !seen {
+					open(to)
+					frames = append(frames, searchFrame{state: to})
+				} else if onStack[to] {
+					lowlink[state] = min(lowlink[state], index[to])
+				}
+				continue
+			}
+
+			// Every transition of the state is followed: return to its parent
+			frames = frames[:len(frames)-1]
+			if len(frames) > 0 {
+				parent := frames[len(frames)-1].state
+				lowlink[parent] = min(lowlink[parent], lowlink[state])
+			}
+			if lowlink[state] == index[state] {
+				var component []string
+				for {
+					top := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[top] = false
+					component = append(component, top)
+					if top == state {
+						break
+					}
+				}
+				sort.Strings(component)
+				components = append(components, component)
+			}
+		}
+	}
+	return components
+}
+
+// componentQueue is a heap of component indices ordered by the first state of
+// the components
+type componentQueue struct {
+	indices    []int
+	components [][]string
+}
+
+func (q *componentQueue) Len() int { return len(q.indices) }
+
+func (q *componentQueue) Less(i, j int) bool {
+	return q.components[q.indices[i]][0] < q.components[q.indices[j]][0]
+}
+
+func (q *componentQueue) Swap(i, j int) { q.indices[i], q.indices[j] = q.indices[j], q.indices[i] }
+
+func (q *componentQueue) Push(x any) { q.indices = append(q.indices, x.(int)) }
+
+func (q *componentQueue) Pop() any {
+	i := q.indices[len(q.indices)-1]
+	q.indices = q.indices[:len(q.indices)-1]
+	return i
+}
+
+// EquivalentStates returns the groups of reachable states that behave the same
+// and could be merged into one: they agree on being final and on their entry and
+// exit actions, and every event takes them, through the same guards and actions in
+// the same order, to states that are themselves equivalent. States without
+// outgoing transitions are never equivalent: they differ only in name, which
+// usually records a distinct outcome such as shipped or rejected. Each group is sorted,
+// and the groups are sorted by their first state. Guards are compared by name.
+func (g *StateGraph) EquivalentStates() [][]string {
+	names := g.FSM.GetStateNames()
+	sort.Strings(names)
+
+	// Start from the classes of what a state does itself, then split classes by
+	// where their transitions lead until no class splits (Moore's algorithm)
+	class := make(map[string]int)
+	classes := 0
+	partition := func(signature func(state string) string) {
+		ids := make(map[string]int)
+		next := make(map[string]int, len(names))
+		for _, name := range names {
+			key := signature(name)
+			if _, ok := ids[key]; !ok {
+				ids[key] = len(ids)
+			}
+			next[name] = ids[key]
+		}
+		class, classes = next, len(ids)
+	}
+	partition(func(name string) string {
+		s := g.FSM.States[name]
+		if len(g.adjacencyList[name]) == 0 {
+			return "\x00" + name
+		}
+		return fmt.Sprintf("%t\x00%s\x00%s", s.Final, s.EntryAction, s.ExitAction)
+	})
+	for {
+		before := classes
+		partition(func(name string) string {
+			var b strings.Builder
+			fmt.Fprintf(&b, "%d", class[name])
+			transitions := append([]*Transition(nil), g.adjacencyList[name]...)
+			sort.SliceStable(transitions, func(i, j int) bool { return transitions[i].Event < transitions[j].Event })
+			for _, t := range transitions {
+				fmt.Fprintf(&b, "\x00%s\x00%s\x00%s\x00%d", t.Event, t.Guard, t.Action, class[t.To])
+			}
+			return b.String()
+		})
+		if classes == before {
+			break
+		}
+	}
+
+	members := make(map[int][]string)
+	for _, name := range names {
+		if g.reachable[name] {
+			members[class[name]] = append(members[class[name]], name)
+		}
+	}
+	var groups [][]string
+	for _, group := range members {
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
 }
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -319,3 +320,364 @@ func TestStateGraph_HasCycles(t *testing.T) {
 		})
 	}
 }
+
+// pathEvents returns the events of a path
+func pathEvents(path []*Transition) []string {
+	events := make([]string, 0, len(path))
+	for _, t := range path {
+		events = append(events, t.Event)
+	}
+	return events
+}
+
+func newPathGraph(t *testing.T) *StateGraph {
+	t.Helper()
+	fsm, err := NewFSMModel("OrderStateMachine", "pending")
+	require.NoError(t, err)
+	for _, name := range []string{"pending", "approved", "review", "shipped", "archived"} {
+		require.NoError(t, fsm.AddState(&State{Name: name}))
+	}
+	for _, name := range []string{"approve", "flag", "clear", "ship", "archive"} {
+		require.NoError(t, fsm.AddEvent(&Event{Name: name}))
+	}
+	for _, tr := range []*Transition{
+		{From: "pending", To: "review", Event: "flag"},
+		{From: "pending", To: "approved", Event: "approve"},
+		{From: "review", To: "approved", Event: "clear"},
+		{From: "review", To: "pending", Event: "clear"},
+		{From: "approved", To: "shipped", Event: "ship"},
+		{From: "review", To: "shipped", Event: "ship"},
+	} {
+		require.NoError(t, fsm.AddTransition(tr))
+	}
+
+	graph := NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+	return graph
+}
+
+func TestStateGraph_ShortestPath(t *testing.T) {
+	graph := newPathGraph(t)
+
+	assert.Equal(t, []string{"flag", "ship"}, pathEvents(graph.ShortestPath("pending", "shipped")))
+	assert.Equal(t, []string{"approve"}, pathEvents(graph.ShortestPath("pending", "approved")))
+	assert.Empty(t, graph.ShortestPath("pending", "pending"))
+	assert.NotNil(t, graph.ShortestPath("pending", "pending"))
+	assert.Nil(t, graph.ShortestPath("pending", "archived"))
+	assert.Nil(t, graph.ShortestPath("shipped", "pending"))
+}
+
+func TestStateGraph_SimplePaths(t *testing.T) {
+	graph := newPathGraph(t)
+
+	var paths [][]string
+	for _, path := range graph.SimplePaths("pending", "shipped", 5) {
+		paths = append(paths, pathEvents(path))
+	}
+	assert.Equal(t, [][]string{
+		{"flag", "ship"},
+		{"approve", "ship"},
+		{"flag", "clear", "ship"},
+	}, paths)
+
+	assert.Len(t, graph.SimplePaths("pending", "shipped", 2), 2)
+	assert.Empty(t, graph.SimplePaths("pending", "archived", 5))
+}
+
+func TestStateGraph_FindNondeterminism(t *testing.T) {
+	graph := newPathGraph(t)
+
+	conflicts := graph.FindNondeterminism()
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "review", conflicts[0].State)
+	assert.Equal(t, "clear", conflicts[0].Event)
+	assert.Empty(t, conflicts[0].Guard)
+	assert.Equal(t, `state "review" has 2 unguarded transitions on "clear" (to "approved", "pending")`, conflicts[0].String())
+
+	fsm := graph.FSM
+	require.NoError(t, fsm.AddTransition(&Transition{From: "pending", To: "shipped", Event: "approve", Guard: "isPrepaid"}))
+	require.NoError(t, fsm.AddTransition(&Transition{From: "pending", To: "archived", Event: "approve", Guard: "isPrepaid"}))
+	graph = NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+
+	conflicts = graph.FindNondeterminism()
+	require.Len(t, conflicts, 2)
+	assert.Equal(t, `state "pending" has 2 transitions with guard "isPrepaid" on "approve" (to "shipped", "archived")`, conflicts[0].String())
+	assert.Equal(t, "review", conflicts[1].State)
+}
+
+func TestStateGraph_FindNondeterminism_GuardedFallback(t *testing.T) {
+	graph := newPathGraph(t)
+	require.NoError(t, graph.FSM.AddTransition(&Transition{From: "approved", To: "archived", Event: "ship", Guard: "isDigital"}))
+	graph = NewStateGraph(graph.FSM)
+	require.NoError(t, graph.Build())
+
+	for _, conflict := range graph.FindNondeterminism() {
+		assert.NotEqual(t, "approved", conflict.State, "A guarded transition with an unguarded fallback is deterministic")
+	}
+}
+
+func TestStateGraph_UnhandledPairs(t *testing.T) {
+	fsm, err := NewFSMModel("Door", "closed")
+	require.NoError(t, err)
+	require.NoError(t, fsm.AddState(&State{Name: "closed"}))
+	require.NoError(t, fsm.AddState(&State{Name: "open"}))
+	require.NoError(t, fsm.AddEvent(&Event{Name: "open_door"}))
+	require.NoError(t, fsm.AddEvent(&Event{Name: "close_door"}))
+	require.NoError(t, fsm.AddTransition(&Transition{From: "closed", To: "open", Event: "open_door"}))
+
+	graph := NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+	assert.Equal(t, []StateEvent{
+		{State: "closed", Event: "close_door"},
+		{State: "open", Event: "close_door"},
+		{State: "open", Event: "open_door"},
+	}, graph.UnhandledPairs())
+}
+
+func TestStateGraph_FindTraps(t *testing.T) {
+	graph := newPathGraph(t)
+	assert.Empty(t, graph.FindTraps(), "Machines without final states should have no traps")
+
+	graph.FSM.States["shipped"].Final = true
+	require.NoError(t, graph.FSM.AddState(&State{Name: "on_hold"}))
+	require.NoError(t, graph.FSM.AddState(&State{Name: "escalated"}))
+	require.NoError(t, graph.FSM.AddEvent(&Event{Name: "hold"}))
+	require.NoError(t, graph.FSM.AddEvent(&Event{Name: "escalate"}))
+	for _, tr := range []*Transition{
+		{From: "approved", To: "on_hold", Event: "hold"},
+		{From: "on_hold", To: "escalated", Event: "escalate"},
+		{From: "escalated", To: "on_hold", Event: "hold", Guard: "isStale"},
+		{From: "archived", To: "archived", Event: "archive"},
+	} {
+		require.NoError(t, graph.FSM.AddTransition(tr))
+	}
+	graph = NewStateGraph(graph.FSM)
+	require.NoError(t, graph.Build())
+
+	traps := graph.FindTraps()
+	require.Len(t, traps, 1, "Unreachable cycles and cycles that can finish should not be traps")
+	assert.Equal(t, []string{"escalated", "on_hold"}, traps[0].States)
+	assert.Equal(t, []string{"hold", "escalate"}, pathEvents(traps[0].Cycle))
+	assert.Equal(t, `no final state is reachable from "escalated", "on_hold" (cycle escalated --hold [isStale]--> on_hold --escalate--> escalated)`, traps[0].String())
+}
+
+func TestStateGraph_FindTraps_SelfLoop(t *testing.T) {
+	fsm, err := NewFSMModel("Job", "queued")
+	require.NoError(t, err)
+	require.NoError(t, fsm.AddState(&State{Name: "queued"}))
+	require.NoError(t, fsm.AddState(&State{Name: "retrying"}))
+	require.NoError(t, fsm.AddState(&State{Name: "done", Final: true}))
+	require.NoError(t, fsm.AddEvent(&Event{Name: "fail"}))
+	require.NoError(t, fsm.AddEvent(&Event{Name: "finish"}))
+	require.NoError(t, fsm.AddTransition(&Transition{From: "queued", To: "done", Event: "finish"}))
+	require.NoError(t, fsm.AddTransition(&Transition{From: "queued", To: "retrying", Event: "fail"}))
+	require.NoError(t, fsm.AddTransition(&Transition{From: "retrying", To: "retrying", Event: "fail"}))
+
+	graph := NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+
+	traps := graph.FindTraps()
+	require.Len(t, traps, 1)
+	assert.Equal(t, []string{"retrying"}, traps[0].States)
+	assert.Equal(t, `no final state is reachable from "retrying" (cycle retrying --fail--> retrying)`, traps[0].String())
+}
+
+func TestStateGraph_SCCs(t *testing.T) {
+	graph := newPathGraph(t)
+
+	assert.Equal(t, [][]string{{"archived"}, {"pending", "review"}, {"approved"}, {"shipped"}}, graph.SCCs())
+}
+
+func TestStateGraph_Condense(t *testing.T) {
+	graph := newPathGraph(t)
+
+	c := graph.Condense()
+	assert.Equal(t, [][]string{{"archived"}, {"pending", "review"}, {"approved"}, {"shipped"}}, c.Components)
+	assert.Equal(t, [][]int{{}, {2, 3}, {3}, {}}, c.Edges)
+	assert.Equal(t, []bool{false, true, false, false}, c.Cyclic)
+	assert.Equal(t, 1, c.ComponentOf("review"))
+	assert.Equal(t, -1, c.ComponentOf("missing"))
+	assert.Equal(t, 3, c.Depth())
+}
+
+func TestStateGraph_FindCycles(t *testing.T) {
+	graph := newPathGraph(t)
+	assert.Len(t, graph.FindCycles(0), 1)
+
+	require.NoError(t, graph.FSM.AddEvent(&Event{Name: "reset"}))
+	for _, tr := range []*Transition{
+		{From: "approved", To: "pending", Event: "reset"},
+		{From: "review", To: "pending", Event: "clear", Guard: "isClean"},
+		{From: "shipped", To: "shipped", Event: "ship"},
+	} {
+		require.NoError(t, graph.FSM.AddTransition(tr))
+	}
+	graph = NewStateGraph(graph.FSM)
+	require.NoError(t, graph.Build())
+
+	var cycles []string
+	for _, cycle := range graph.FindCycles(0) {
+		cycles = append(cycles, FormatPath(cycle[0].From, cycle))
+	}
+	assert.Equal(t, []string{
+		"approved --reset--> pending --approve--> approved",
+		"approved --reset--> pending --flag--> review --clear--> approved",
+		"pending --flag--> review --clear--> pending",
+		"shipped --ship--> shipped",
+	}, cycles, "The guarded copy of review --clear--> pending should be reported once")
+
+	assert.Len(t, graph.FindCycles(2), 2)
+}
+
+func TestStateGraph_ReachableFrom(t *testing.T) {
+	graph := newPathGraph(t)
+
+	assert.Equal(t, []string{"approved", "pending", "review", "shipped"}, graph.ReachableFrom("review"))
+	assert.Equal(t, []string{"approved", "shipped"}, graph.ReachableFrom("approved"))
+	assert.Equal(t, []string{"archived"}, graph.ReachableFrom("archived"))
+	assert.Nil(t, graph.ReachableFrom("missing"))
+}
+
+func TestStateGraph_CanReach(t *testing.T) {
+	graph := newPathGraph(t)
+
+	assert.True(t, graph.CanReach("pending", "shipped"))
+	assert.True(t, graph.CanReach("approved", "pending", "shipped"), "Reaching any target should be enough")
+	assert.True(t, graph.CanReach("archived", "archived"), "Every state reaches itself")
+	assert.False(t, graph.CanReach("shipped", "pending"))
+	assert.False(t, graph.CanReach("pending", "archived"))
+	assert.False(t, graph.CanReach("pending"))
+	assert.False(t, graph.CanReach("pending", "missing"))
+}
+
+// newLargeGraph builds a machine of n states in a chain, where every hundredth
+// state can also return to the start of its block and the last state is final,
+// like the machines generated by product configurators
+func newLargeGraph(b *testing.B, n int) *StateGraph {
+	b.Helper()
+	name := func(i int) string { return fmt.Sprintf("s%05d", i) }
+	fsm, err := NewFSMModel("Configurator", name(0))
+	require.NoError(b, err)
+	require.NoError(b, fsm.AddEvent(&Event{Name: "next"}))
+	require.NoError(b, fsm.AddEvent(&Event{Name: "back"}))
+	for i := 0; i < n; i++ {
+		require.NoError(b, fsm.AddState(&State{Name: name(i), Final: i == n-1}))
+	}
+	for i := 0; i < n-1; i++ {
+		require.NoError(b, fsm.AddTransition(&Transition{From: name(i), To: name(i + 1), Event: "next"}))
+		if i%100 == 99 {
+			require.NoError(b, fsm.AddTransition(&Transition{From: name(i), To: name(i - 99), Event: "back"}))
+		}
+	}
+
+	graph := NewStateGraph(fsm)
+	require.NoError(b, graph.Build())
+	return graph
+}
+
+func BenchmarkStateGraph_Build(b *testing.B) {
+	fsm := newLargeGraph(b, 10000).FSM
+	for b.Loop() {
+		NewStateGraph(fsm).Build()
+	}
+}
+
+func BenchmarkStateGraph_Condense(b *testing.B) {
+	graph := newLargeGraph(b, 10000)
+	for b.Loop() {
+		graph.Condense()
+	}
+}
+
+func BenchmarkStateGraph_HasCycles(b *testing.B) {
+	graph := newLargeGraph(b, 10000)
+	for b.Loop() {
+		graph.HasCycles()
+	}
+}
+
+func BenchmarkStateGraph_FindCycles(b *testing.B) {
+	graph := newLargeGraph(b, 10000)
+	for b.Loop() {
+		graph.FindCycles(100)
+	}
+}
+
+func BenchmarkStateGraph_FindTraps(b *testing.B) {
+	graph := newLargeGraph(b, 10000)
+	for b.Loop() {
+		graph.FindTraps()
+	}
+}
+
+func BenchmarkStateGraph_ShortestPath(b *testing.B) {
+	graph := newLargeGraph(b, 10000)
+	for b.Loop() {
+		graph.ShortestPath("s00000", "s09999")
+	}
+}
+
+func BenchmarkStateGraph_SimplePaths(b *testing.B) {
+	graph := newLargeGraph(b, 10000)
+	for b.Loop() {
+		graph.SimplePaths("s00000", "s09999", 10000)
+	}
+}
+
+func BenchmarkFSMModel_GetUnreachableStateNames(b *testing.B) {
+	fsm := newLargeGraph(b, 10000).FSM
+	for b.Loop() {
+		fsm.GetUnreachableStateNames()
+	}
+}
+
+func TestStateGraph_EquivalentStates(t *testing.T) {
+	fsm, err := NewFSMModel("Ticket", "open")
+	require.NoError(t, err)
+	for _, s := range []*State{
+		{Name: "open"},
+		{Name: "triaged_bug"},
+		{Name: "triaged_feature"},
+		{Name: "triaged_chore", EntryAction: "notifyOps"},
+		{Name: "resolved", Final: true},
+		{Name: "wont_fix", Final: true},
+		{Name: "orphan"},
+	} {
+		require.NoError(t, fsm.AddState(s))
+	}
+	for _, name := range []string{"bug", "feature", "chore", "resolve", "decline"} {
+		require.NoError(t, fsm.AddEvent(&Event{Name: name}))
+	}
+	for _, tr := range []*Transition{
+		{From: "open", To: "triaged_bug", Event: "bug"},
+		{From: "open", To: "triaged_feature", Event: "feature"},
+		{From: "open", To: "triaged_chore", Event: "chore"},
+		{From: "triaged_bug", To: "resolved", Event: "resolve"},
+		{From: "triaged_bug", To: "wont_fix", Event: "decline"},
+		{From: "triaged_feature", To: "wont_fix", Event: "decline"},
+		{From: "triaged_feature", To: "resolved", Event: "resolve"},
+		{From: "triaged_chore", To: "resolved", Event: "resolve"},
+		{From: "triaged_chore", To: "wont_fix", Event: "decline"},
+		{From: "orphan", To: "resolved", Event: "resolve"},
+		{From: "orphan", To: "wont_fix", Event: "decline"},
+	} {
+		require.NoError(t, fsm.AddTransition(tr))
+	}
+	graph := NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+
+	assert.Equal(t, [][]string{{"triaged_bug", "triaged_feature"}}, graph.EquivalentStates(),
+		"States with other entry actions, without transitions, or unreachable should not be merged")
+
+	fsm.Transitions[5].Guard = "isPlanned"
+	graph = NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+	assert.Empty(t, graph.EquivalentStates(), "Guards should tell states apart")
+
+	fsm.Transitions[5].Guard = ""
+	fsm.Transitions[5].To = "resolved"
+	graph = NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+	assert.Empty(t, graph.EquivalentStates(), "Distinct outcomes should tell states apart")
+}
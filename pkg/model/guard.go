@@ -0,0 +1,427 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GuardCondition declares what a guard checks as a predicate over context fields,
+// such as `amount > 0 && currency == "EUR"`, so that analysis can reason about the
+// guard instead of treating it as an opaque name. The generated machine still
+// calls the guard function; the condition documents what it must implement.
+type GuardCondition struct {
+	// Name is the guard name transitions refer to
+	Name string
+
+	// When is the predicate: comparisons of a context field with a constant joined
+	// by &&, where a bool field may stand alone or negated with !. A pure guard may
+	// leave it empty, for its callback alone to decide.
+	When string
+
+	// Description is an optional human-readable description
+	Description string
+
+	// Pure marks a guard whose result does not change while the machine evaluates
+	// one event, such as a check against a remote service, so that the evaluation
+	// runs it at most once and reuses its result
+	Pure bool
+}
+
+// Validate checks that the condition parses against the context fields, unless
+// the guard is pure and has none
+func (g *GuardCondition) Validate(fields []*ContextField) error {
+	if g.Name == "" {
+		return fmt.Errorf("guard name cannot be empty")
+	}
+	if g.When == "" && g.Pure {
+		return nil
+	}
+	if _, err := g.Predicate(fields); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Predicate parses the condition against the context fields
+func (g *GuardCondition) Predicate(fields []*ContextField) (Predicate, error) {
+	p, err := parsePredicate(g.When, fields)
+	if err != nil {
+		return nil, fmt.Errorf("guard %q: condition %q: %w", g.Name, g.When, err)
+	}
+	return p, nil
+}
+
+// Comparison compares a context field with a constant. Conditions on bool fields
+// are normalized to == comparisons.
+type Comparison struct {
+	// Field is the name of the context field
+	Field string
+
+	// Type is the type of the field
+	Type FieldType
+
+	// Op is one of ==, !=, <, <=, >, and >=
+	Op string
+
+	// Value is a float64 for numeric fields, a string, or a bool
+	Value any
+}
+
+// String renders the comparison as it would be written in a condition
+func (c Comparison) String() string {
+	switch v := c.Value.(type) {
+	case bool:
+		if v {
+			return c.Field
+		}
+		return "!" + c.Field
+	case string:
+		return c.Field + " " + c.Op + " " + strconv.Quote(v)
+	default:
+		return c.Field + " " + c.Op + " " + strconv.FormatFloat(v.(float64), 'g', -1, 64)
+	}
+}
+
+// Predicate is a conjunction of comparisons
+type Predicate []Comparison
+
+// String renders the predicate as a condition
+func (p Predicate) String() string {
+	terms := make([]string, len(p))
+	for i, c := range p {
+		terms[i] = c.String()
+	}
+	return strings.Join(terms, " && ")
+}
+
+// Contradiction returns the first pair of terms that no value of their field
+// satisfies together. Only pairs are compared, so contradictions that need three
+// or more terms go unnoticed.
+func (p Predicate) Contradiction() (Comparison, Comparison, bool) {
+	for i, a := range p {
+		for _, b := range p[i+1:] {
+			if !a.overlaps(b) {
+				return a, b, true
+			}
+		}
+	}
+	return Comparison{}, Comparison{}, false
+}
+
+// Implies reports whether every context satisfying p also satisfies q. Each term
+// of q must follow from a single term of p, so some implications go unnoticed.
+func (p Predicate) Implies(q Predicate) bool {
+	for _, t := range q {
+		implied := false
+		for _, s := range p {
+			if s.within(t) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps reports whether some value satisfies both comparisons
+func (c Comparison) overlaps(o Comparison) bool {
+	if c.Field != o.Field {
+		return true
+	}
+	if c.Type == FieldInt || c.Type == FieldFloat {
+		return c.interval().overlaps(o.interval())
+	}
+	switch {
+	case c.Op == "==" && o.Op == "==":
+		return c.Value == o.Value
+	case c.Op == "==" || o.Op == "==":
+		return c.Value != o.Value
+	default:
+		return true
+	}
+}
+
+// within reports whether every value satisfying c satisfies o
+func (c Comparison) within(o Comparison) bool {
+	if c.Field != o.Field {
+		return false
+	}
+	if c.Type == FieldInt || c.Type == FieldFloat {
+		return c.interval().within(o.interval())
+	}
+	switch {
+	case o.Op == "==":
+		return c.Op == "==" && c.Value == o.Value
+	case c.Op == "==":
+		return c.Value != o.Value
+	default:
+		return c.Value == o.Value
+	}
+}
+
+// interval is the set of numbers a comparison allows: the range from lo to hi, or
+// with except every number but lo
+type interval struct {
+	lo, hi         float64
+	loOpen, hiOpen bool
+	except         bool
+}
+
+// interval returns the numbers a numeric comparison allows. Strict bounds on int
+// fields are made inclusive, so that x > 1 && x < 2 is recognized as empty.
+func (c Comparison) interval() interval {
+	v := c.Value.(float64)
+	inf := math.Inf(1)
+	switch c.Op {
+	case "==":
+		return interval{lo: v, hi: v}
+	case "!=":
+		return interval{lo: v, hi: v, except: true}
+	case "<":
+		if c.Type == FieldInt {
+			return interval{lo: -inf, hi: v - 1}
+		}
+		return interval{lo: -inf, hi: v, hiOpen: true}
+	case "<=":
+		return interval{lo: -inf, hi: v}
+	case ">":
+		if c.Type == FieldInt {
+			return interval{lo: v + 1, hi: inf}
+		}
+		return interval{lo: v, loOpen: true, hi: inf}
+	default:
+		return interval{lo: v, hi: inf}
+	}
+}
+
+// contains reports whether the interval allows v
+func (r interval) contains(v float64) bool {
+	inside := (v > r.lo || (v == r.lo && !r.loOpen)) && (v < r.hi || (v == r.hi && !r.hiOpen))
+	return inside != r.except
+}
+
+// overlaps reports whether some number is in both intervals
+func (r interval) overlaps(o interval) bool {
+	switch {
+	case r.except && o.except:
+		return true
+	case r.except:
+		return !(o.lo == r.lo && o.hi == r.lo && !o.loOpen && !o.hiOpen)
+	case o.except:
+		return o.overlaps(r)
+	}
+
+	lo, loOpen := r.lo, r.loOpen
+	if o.lo > lo || (o.lo == lo && o.loOpen) {
+		lo, loOpen = o.lo, o.loOpen
+	}
+	hi, hiOpen := r.hi, r.hiOpen
+	if o.hi < hi || (o.hi == hi && o.hiOpen) {
+		hi, hiOpen = o.hi, o.hiOpen
+	}
+	return lo < hi || (lo == hi && !loOpen && !hiOpen)
+}
+
+// within reports whether every number in r is in o
+func (r interval) within(o interval) bool {
+	switch {
+	case o.except:
+		if r.except {
+			return r.lo == o.lo
+		}
+		return !r.contains(o.lo)
+	case r.except:
+		return false
+	}
+	loOK := o.lo < r.lo || (o.lo == r.lo && (!o.loOpen || r.loOpen))
+	hiOK := r.hi < o.hi || (r.hi == o.hi && (!o.hiOpen || r.hiOpen))
+	return loOK && hiOK
+}
+
+// predicateToken is a lexical token of a condition
+type predicateToken struct {
+	kind string // ident, number, string, op, not, and
+	text string
+}
+
+// parsePredicate parses a condition and checks it against the context fields
+func parsePredicate(when string, fields []*ContextField) (Predicate, error) {
+	tokens, err := lexPredicate(when)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("condition cannot be empty")
+	}
+
+	byName := make(map[string]*ContextField, len(fields))
+	for _, f := range fields {
+		byName[f.Name] = f
+	}
+
+	var p Predicate
+	for len(tokens) > 0 {
+		var term []predicateToken
+		for len(tokens) > 0 && tokens[0].kind != "and" {
+			term, tokens = append(term, tokens[0]), tokens[1:]
+		}
+		if len(tokens) > 0 {
+			tokens = tokens[1:]
+			if len(tokens) == 0 {
+				return nil, fmt.Errorf("&& must be followed by a comparison")
+			}
+		}
+
+		c, err := parseComparison(term, byName)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p, c)
+	}
+	return p, nil
+}
+
+// parseComparison parses one term: "field op constant", "field", or "!field"
+func parseComparison(term []predicateToken, fields map[string]*ContextField) (Comparison, error) {
+	negated := len(term) > 0 && term[0].kind == "not"
+	if negated {
+		term = term[1:]
+	}
+	if len(term) == 0 || term[0].kind != "ident" {
+		return Comparison{}, fmt.Errorf("expected a context field")
+	}
+
+	field, ok := fields[term[0].text]
+	if !ok {
+		return Comparison{}, fmt.Errorf("%q is not a declared context field", term[0].text)
+	}
+	c := Comparison{Field: field.Name, Type: field.Type}
+
+	if negated || len(term) == 1 {
+		if field.Type != FieldBool {
+			return Comparison{}, fmt.Errorf("%s field %q must be compared with a constant", field.Type, field.Name)
+		}
+		if len(term) > 1 {
+			return Comparison{}, fmt.Errorf("a negated field cannot be compared")
+		}
+		c.Op, c.Value = "==", !negated
+		return c, nil
+	}
+
+	if len(term) != 3 || term[1].kind != "op" {
+		return Comparison{}, fmt.Errorf("expected field, operator, and constant in %q", joinTokens(term))
+	}
+	c.Op = term[1].text
+	value := term[2]
+
+	switch field.Type {
+	case FieldInt, FieldFloat:
+		if value.kind != "number" {
+			return Comparison{}, fmt.Errorf("%s field %q must be compared with a number", field.Type, field.Name)
+		}
+		v, err := strconv.ParseFloat(value.text, 64)
+		if err != nil {
+			return Comparison{}, fmt.Errorf("invalid number %q", value.text)
+		}
+		if field.Type == FieldInt && v != math.Trunc(v) {
+			return Comparison{}, fmt.Errorf("int field %q cannot be compared with %s", field.Name, value.text)
+		}
+		c.Value = v
+	case FieldString:
+		if value.kind != "string" {
+			return Comparison{}, fmt.Errorf("string field %q must be compared with a quoted string", field.Name)
+		}
+		if c.Op != "==" && c.Op != "!=" {
+			return Comparison{}, fmt.Errorf("string field %q can only be compared with == or !=", field.Name)
+		}
+		c.Value = value.text
+	case FieldBool:
+		if value.kind != "ident" || (value.text != "true" && value.text != "false") {
+			return Comparison{}, fmt.Errorf("bool field %q must be compared with true or false", field.Name)
+		}
+		if c.Op != "==" && c.Op != "!=" {
+			return Comparison{}, fmt.Errorf("bool field %q can only be compared with == or !=", field.Name)
+		}
+		c.Value = (value.text == "true") == (c.Op == "==")
+		c.Op = "=="
+	}
+	return c, nil
+}
+
+// lexPredicate splits a condition into tokens
+func lexPredicate(s string) ([]predicateToken, error) {
+	var tokens []predicateToken
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.HasPrefix(s[i:], "&&"):
+			tokens = append(tokens, predicateToken{kind: "and", text: "&&"})
+			i += 2
+		case strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], "<="), strings.HasPrefix(s[i:], ">="):
+			tokens = append(tokens, predicateToken{kind: "op", text: s[i : i+2]})
+			i += 2
+		case r == '<' || r == '>':
+			tokens = append(tokens, predicateToken{kind: "op", text: s[i : i+1]})
+			i++
+		case r == '!':
+			tokens = append(tokens, predicateToken{kind: "not", text: "!"})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			text, err := strconv.Unquote(s[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:end+1])
+			}
+			tokens = append(tokens, predicateToken{kind: "string", text: text})
+			i = end + 1
+		case r == '-' || r == '.' || unicode.IsDigit(r):
+			end := i + 1
+			for end < len(s) && (unicode.IsDigit(rune(s[end])) || strings.ContainsRune(".eE", rune(s[end])) ||
+				(strings.ContainsRune("+-", rune(s[end])) && strings.ContainsRune("eE", rune(s[end-1])))) {
+				end++
+			}
+			tokens = append(tokens, predicateToken{kind: "number", text: s[i:end]})
+			i = end
+		case r == '_' || unicode.IsLetter(r):
+			end := i + 1
+			for end < len(s) && (s[end] == '_' || unicode.IsLetter(rune(s[end])) || unicode.IsDigit(rune(s[end]))) {
+				end++
+			}
+			tokens = append(tokens, predicateToken{kind: "ident", text: s[i:end]})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected %q; conditions support ==, !=, <, <=, >, >=, !, and &&", s[i:i+1])
+		}
+	}
+	return tokens, nil
+}
+
+// joinTokens renders tokens for error messages
+func joinTokens(tokens []predicateToken) string {
+	texts := make([]string, len(tokens))
+	for i, t := range tokens {
+		texts[i] = t.text
+		if t.kind == "string" {
+			texts[i] = strconv.Quote(t.text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
@@ -0,0 +1,137 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var paymentContext = []*ContextField{
+	{Name: "amount", Type: FieldInt},
+	{Name: "score", Type: FieldFloat},
+	{Name: "currency", Type: FieldString},
+	{Name: "paid", Type: FieldBool},
+}
+
+func mustPredicate(t *testing.T, when string) Predicate {
+	t.Helper()
+	p, err := (&GuardCondition{Name: "g", When: when}).Predicate(paymentContext)
+	require.NoError(t, err)
+	return p
+}
+
+func TestGuardCondition_Predicate(t *testing.T) {
+	p := mustPredicate(t, `amount >= 10 && currency == "EUR" && !paid && score < 0.5`)
+	assert.Equal(t, Predicate{
+		{Field: "amount", Type: FieldInt, Op: ">=", Value: 10.0},
+		{Field: "currency", Type: FieldString, Op: "==", Value: "EUR"},
+		{Field: "paid", Type: FieldBool, Op: "==", Value: false},
+		{Field: "score", Type: FieldFloat, Op: "<", Value: 0.5},
+	}, p)
+	assert.Equal(t, `amount >= 10 && currency == "EUR" && !paid && score < 0.5`, p.String())
+
+	assert.Equal(t, "paid", mustPredicate(t, "paid").String())
+	assert.Equal(t, "!paid", mustPredicate(t, "paid != true").String())
+	assert.Equal(t, "amount > -5", mustPredicate(t, "amount>-5").String())
+	assert.Equal(t, `currency != "a && b"`, mustPredicate(t, `currency != "a && b"`).String())
+}
+
+func TestGuardCondition_PredicateErrors(t *testing.T) {
+	tests := []struct {
+		when    string
+		wantErr string
+	}{
+		{when: "", wantErr: "condition cannot be empty"},
+		{when: "total > 0", wantErr: `"total" is not a declared context field`},
+		{when: "amount", wantErr: `int field "amount" must be compared with a constant`},
+		{when: "amount > 1.5", wantErr: `int field "amount" cannot be compared with 1.5`},
+		{when: `amount == "1"`, wantErr: `int field "amount" must be compared with a number`},
+		{when: "currency == EUR", wantErr: "must be compared with a quoted string"},
+		{when: `currency < "EUR"`, wantErr: "can only be compared with == or !="},
+		{when: "paid > false", wantErr: "can only be compared with == or !="},
+		{when: "paid == 1", wantErr: "must be compared with true or false"},
+		{when: "amount > 0 &&", wantErr: "&& must be followed by a comparison"},
+		{when: "amount > 0 || paid", wantErr: `unexpected "|"`},
+		{when: "amount 0", wantErr: "expected field, operator, and constant"},
+		{when: `currency == "EUR`, wantErr: "unterminated string"},
+		{when: "!amount", wantErr: "must be compared with a constant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			_, err := (&GuardCondition{Name: "g", When: tt.when}).Predicate(paymentContext)
+			assert.ErrorContains(t, err, tt.wantErr)
+			assert.ErrorContains(t, err, `guard "g": condition`)
+		})
+	}
+}
+
+func TestGuardCondition_ValidatePureCallback(t *testing.T) {
+	assert.NoError(t, (&GuardCondition{Name: "g", Pure: true}).Validate(paymentContext))
+	assert.ErrorContains(t, (&GuardCondition{Name: "g"}).Validate(paymentContext), "condition cannot be empty")
+	assert.ErrorContains(t, (&GuardCondition{Name: "g", When: "total > 0", Pure: true}).Validate(paymentContext), "is not a declared context field")
+}
+
+func TestPredicate_Contradiction(t *testing.T) {
+	tests := []struct {
+		when string
+		want string // the contradicting pair, or empty
+	}{
+		{when: "amount > 0 && amount < 10"},
+		{when: "amount > 10 && amount < 5", want: "amount > 10 / amount < 5"},
+		{when: "amount > 1 && amount < 2", want: "amount > 1 / amount < 2"},
+		{when: "score > 1 && score < 2"},
+		{when: "score > 1 && score <= 1", want: "score > 1 / score <= 1"},
+		{when: "amount >= 1 && amount <= 1"},
+		{when: "amount == 3 && amount != 3", want: "amount == 3 / amount != 3"},
+		{when: "amount != 3 && amount != 4"},
+		{when: "amount != 3 && amount > 2"},
+		{when: `currency == "EUR" && currency == "USD"`, want: `currency == "EUR" / currency == "USD"`},
+		{when: `currency == "EUR" && currency != "USD"`},
+		{when: "paid && !paid", want: "paid / !paid"},
+		{when: "paid && amount > 0 && !paid", want: "paid / !paid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.when, func(t *testing.T) {
+			a, b, found := mustPredicate(t, tt.when).Contradiction()
+			if tt.want == "" {
+				assert.False(t, found, "unexpected contradiction %s / %s", a, b)
+				return
+			}
+			require.True(t, found)
+			assert.Equal(t, tt.want, a.String()+" / "+b.String())
+		})
+	}
+}
+
+func TestPredicate_Implies(t *testing.T) {
+	tests := []struct {
+		p, q string
+		want bool
+	}{
+		{p: "amount > 100", q: "amount > 50", want: true},
+		{p: "amount > 50", q: "amount > 100", want: false},
+		{p: "amount >= 10", q: "amount > 9", want: true},
+		{p: "score >= 10", q: "score > 9.5", want: true},
+		{p: "score >= 10", q: "score > 10", want: false},
+		{p: "amount == 5", q: "amount != 4", want: true},
+		{p: "amount == 5", q: "amount != 5", want: false},
+		{p: "amount > 5", q: "amount != 3", want: true},
+		{p: "amount != 3", q: "amount != 3", want: true},
+		{p: "amount != 3", q: "amount > 5", want: false},
+		{p: `amount > 100 && currency == "EUR"`, q: "amount > 0", want: true},
+		{p: "amount > 100", q: `amount > 0 && currency == "EUR"`, want: false},
+		{p: `currency == "EUR"`, q: `currency != "USD"`, want: true},
+		{p: `currency != "USD"`, q: `currency == "EUR"`, want: false},
+		{p: "paid && amount > 0", q: "paid", want: true},
+		{p: "!paid", q: "paid", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.p+" => "+tt.q, func(t *testing.T) {
+			assert.Equal(t, tt.want, mustPredicate(t, tt.p).Implies(mustPredicate(t, tt.q)))
+		})
+	}
+}
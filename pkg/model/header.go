@@ -0,0 +1,47 @@
+package model
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"strings"
+)
+
+// Header configures the comment block and build constraint at the top of generated files
+type Header struct {
+	// Copyright is a custom banner, such as a copyright or license notice; it may span several lines
+	Copyright string
+
+	// BuildTags is a build constraint expression, e.g. "!fsm_stub", emitted as a //go:build line
+	BuildTags string
+
+	// Stamp records the spec path, spec checksum, and generator version in the header
+	Stamp bool
+}
+
+// IsDefault reports whether the header adds nothing to the generated marker
+func (h Header) IsDefault() bool {
+	return h.Copyright == "" && h.BuildTags == "" && !h.Stamp
+}
+
+// validate checks that the build constraint is well-formed
+func (h Header) validate() error {
+	if h.BuildTags == "" {
+		return nil
+	}
+	if strings.Contains(h.BuildTags, "\n") {
+		return fmt.Errorf("build tags %q must be a single line", h.BuildTags)
+	}
+	if _, err := constraint.Parse("//go:build " + h.BuildTags); err != nil {
+		return fmt.Errorf("invalid build tags %q: %w", h.BuildTags, err)
+	}
+	return nil
+}
+
+// Source identifies the spec a model was parsed from
+type Source struct {
+	// Path is the spec file path as given to the generator
+	Path string
+
+	// Checksum is the hex-encoded SHA-256 of the spec file contents
+	Checksum string
+}
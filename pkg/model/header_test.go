@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeader_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		buildTags string
+		wantErr   string
+	}{
+		{name: "no build tags"},
+		{name: "negated tag", buildTags: "!fsm_stub"},
+		{name: "expression", buildTags: "linux && (amd64 || arm64)"},
+		{name: "incomplete expression", buildTags: "linux &&", wantErr: `invalid build tags "linux &&"`},
+		{name: "multiple lines", buildTags: "linux\ndarwin", wantErr: "must be a single line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := newShippingFSM()
+			fsm.Header.BuildTags = tt.buildTags
+
+			err := fsm.Validate()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHeader_IsDefault(t *testing.T) {
+	assert.True(t, Header{}.IsDefault())
+	assert.False(t, Header{Stamp: true}.IsDefault())
+	assert.False(t, Header{Copyright: "Copyright 2026 Acme Corp."}.IsDefault())
+}
@@ -0,0 +1,83 @@
+package model
+
+import "fmt"
+
+// LegacyState is a state of an earlier version of the machine. Values persisted
+// with its name, or with its integer value when one is given, load as the state To.
+type LegacyState struct {
+	// Name is the name the state was persisted with
+	Name string
+
+	// Value is the integer value the state was persisted with; nil when the
+	// machine only ever persisted it by name
+	Value *int
+
+	// To is the declared state persisted values of the legacy state load as
+	To string
+
+	// Description is an optional human-readable description
+	Description string
+}
+
+// Validate checks that the legacy state names no declared state and migrates to one
+func (l *LegacyState) Validate(states map[string]*State) error {
+	if l.Name == "" {
+		return fmt.Errorf("legacy state name cannot be empty")
+	}
+
+	if !validNamePattern.MatchString(l.Name) {
+		return fmt.Errorf("legacy state name %q contains invalid characters (use only letters, digits, and underscores)", l.Name)
+	}
+
+	if _, exists := states[l.Name]; exists {
+		return fmt.Errorf("legacy state %q is a declared state", l.Name)
+	}
+
+	if _, exists := states[l.To]; !exists {
+		return fmt.Errorf("legacy state %q migrates to %q, which is not defined", l.Name, l.To)
+	}
+
+	if l.Value != nil && *l.Value == 0 {
+		return fmt.Errorf("legacy state %q cannot use value 0, which is reserved for the zero value", l.Name)
+	}
+	return nil
+}
+
+// GetLegacyState returns the legacy state with the given name, or nil
+func (f *FSMModel) GetLegacyState(name string) *LegacyState {
+	for _, legacy := range f.LegacyStates {
+		if legacy.Name == name {
+			return legacy
+		}
+	}
+	return nil
+}
+
+// validateLegacyStates checks the legacy states and that their values are
+// distinct from each other and from the values of the declared states
+func (f *FSMModel) validateLegacyStates() error {
+	owners := make(map[int]string, len(f.States))
+	for name, value := range f.GetStateValues() {
+		owners[value] = name
+	}
+
+	legacyOwners := make(map[int]string, len(f.LegacyStates))
+	for _, legacy := range f.LegacyStates {
+		if err := legacy.Validate(f.States); err != nil {
+			return fmt.Errorf("invalid migration: %w", err)
+		}
+		if legacy.Value == nil {
+			continue
+		}
+
+		value := *legacy.Value
+		if owner, taken := owners[value]; taken {
+			return fmt.Errorf("invalid migration: legacy state %q and state %q both use value %d", legacy.Name, owner, value)
+		}
+		if owner, taken := legacyOwners[value]; taken {
+			return fmt.Errorf("invalid migration: legacy states %q and %q both use value %d", owner, legacy.Name, value)
+		}
+		legacyOwners[value] = legacy.Name
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyState_Validate(t *testing.T) {
+	states := map[string]*State{"pending": {Name: "pending"}, "approved": {Name: "approved"}}
+	zero := 0
+
+	tests := []struct {
+		name    string
+		legacy  LegacyState
+		wantErr string
+	}{
+		{name: "valid", legacy: LegacyState{Name: "review", To: "approved"}},
+		{name: "empty name", legacy: LegacyState{To: "approved"}, wantErr: "legacy state name cannot be empty"},
+		{name: "invalid name", legacy: LegacyState{Name: "in-review", To: "approved"}, wantErr: "contains invalid characters"},
+		{name: "declared state", legacy: LegacyState{Name: "pending", To: "approved"}, wantErr: `legacy state "pending" is a declared state`},
+		{name: "undefined target", legacy: LegacyState{Name: "review", To: "shipped"}, wantErr: `legacy state "review" migrates to "shipped", which is not defined`},
+		{name: "zero value", legacy: LegacyState{Name: "review", Value: &zero, To: "approved"}, wantErr: "cannot use value 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.legacy.Validate(states)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFSMModel_LegacyStates(t *testing.T) {
+	fsm := newPathGraph(t).FSM
+	value := 2
+	require.NoError(t, fsm.AddLegacyState(&LegacyState{Name: "cancelled", Value: &value, To: "archived"}))
+	assert.EqualError(t, fsm.AddLegacyState(&LegacyState{Name: "cancelled", To: "pending"}), `legacy state "cancelled" already migrates to "archived"`)
+	assert.Equal(t, "archived", fsm.GetLegacyState("cancelled").To)
+	assert.Nil(t, fsm.GetLegacyState("pending"))
+
+	// Automatic values skip the values of legacy states
+	assert.Equal(t, 1, fsm.StateValue("approved"))
+	assert.Equal(t, 3, fsm.StateValue("archived"))
+	require.NoError(t, fsm.validateLegacyStates())
+
+	pinned := 2
+	fsm.GetState("shipped").Value = &pinned
+	assert.EqualError(t, fsm.validateLegacyStates(), `invalid migration: legacy state "cancelled" and state "shipped" both use value 2`)
+
+	fsm.GetState("shipped").Value = nil
+	require.NoError(t, fsm.AddLegacyState(&LegacyState{Name: "voided", Value: &value, To: "archived"}))
+	assert.EqualError(t, fsm.validateLegacyStates(), `invalid migration: legacy states "cancelled" and "voided" both use value 2`)
+}
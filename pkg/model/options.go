@@ -0,0 +1,270 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// UnknownStatePolicy controls how persisted values that do not name a state are restored
+type UnknownStatePolicy string
+
+const (
+	// UnknownStateError rejects unknown values with an error (the default)
+	UnknownStateError UnknownStatePolicy = "error"
+
+	// UnknownStateQuarantine maps unknown values to a designated quarantine state
+	UnknownStateQuarantine UnknownStatePolicy = "quarantine"
+
+	// UnknownStateHandler delegates unknown values to a user-supplied handler function
+	UnknownStateHandler UnknownStatePolicy = "handler"
+)
+
+// ZeroStatePolicy controls what the zero value of the generated state type means
+type ZeroStatePolicy string
+
+const (
+	// ZeroStateInitial makes the zero value the initial state (the default)
+	ZeroStateInitial ZeroStatePolicy = "initial"
+
+	// ZeroStateUnspecified reserves the zero value for an explicit Unspecified sentinel
+	ZeroStateUnspecified ZeroStatePolicy = "unspecified"
+
+	// ZeroStateInvalid leaves the zero value undeclared so that using it is an error
+	ZeroStateInvalid ZeroStatePolicy = "invalid"
+)
+
+// UnhandledEventPolicy controls what generated machines do with events the
+// current state has no transition on
+type UnhandledEventPolicy string
+
+const (
+	// UnhandledEventError rejects such events with an error (the default)
+	UnhandledEventError UnhandledEventPolicy = "error"
+
+	// UnhandledEventIgnore drops such events silently
+	UnhandledEventIgnore UnhandledEventPolicy = "ignore"
+
+	// UnhandledEventLog drops such events, logging them
+	UnhandledEventLog UnhandledEventPolicy = "log"
+)
+
+// validate checks that p is a known policy; empty is allowed
+func (p UnhandledEventPolicy) validate() error {
+	switch p {
+	case "", UnhandledEventError, UnhandledEventIgnore, UnhandledEventLog:
+		return nil
+	default:
+		return fmt.Errorf("unhandled event policy %q is not one of %q, %q, %q",
+			p, UnhandledEventError, UnhandledEventIgnore, UnhandledEventLog)
+	}
+}
+
+// ReentrancyPolicy controls how generated machines handle transitions fired from
+// the guards and actions of one of their transitions, which otherwise deadlock
+type ReentrancyPolicy string
+
+const (
+	// ReentrancyError makes such transitions fail with ErrReentrant{Name}Transition
+	ReentrancyError ReentrancyPolicy = "error"
+
+	// ReentrancyQueue queues the events of such transitions, which are handled once
+	// the current transition completes
+	ReentrancyQueue ReentrancyPolicy = "queue"
+)
+
+// ConstantStyle controls where the kind of a state or event constant is written
+type ConstantStyle string
+
+const (
+	// ConstantPrefix writes the kind before the name, as in OrderStatePending (the default)
+	ConstantPrefix ConstantStyle = "prefix"
+
+	// ConstantSuffix writes the kind after the name, as in OrderPendingState
+	ConstantSuffix ConstantStyle = "suffix"
+)
+
+// Naming controls the identifiers of the generated code
+type Naming struct {
+	// Constants is the style of state and event constants; empty means ConstantPrefix
+	Constants ConstantStyle
+
+	// OmitMachineName leaves the machine name out of state and event constants
+	OmitMachineName bool
+
+	// Acronyms are the words written in upper case when names are converted to
+	// PascalCase, such as ID in OrderID
+	Acronyms []string
+
+	// Initialisms adds the common Go initialisms, such as ID, HTTP, and SKU, to
+	// the acronyms
+	Initialisms bool
+}
+
+// ConstantStyleOrDefault returns the configured constant style, defaulting to ConstantPrefix
+func (n Naming) ConstantStyleOrDefault() ConstantStyle {
+	if n.Constants == "" {
+		return ConstantPrefix
+	}
+	return n.Constants
+}
+
+// IsAcronym reports whether word is one of the acronyms, ignoring case
+func (n Naming) IsAcronym(word string) bool {
+	for _, acronym := range n.Acronyms {
+		if strings.EqualFold(acronym, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the constant style and that acronyms are single words
+func (n Naming) validate() error {
+	switch n.ConstantStyleOrDefault() {
+	case ConstantPrefix, ConstantSuffix:
+	default:
+		return fmt.Errorf("constant style %q is not one of %q, %q", n.Constants, ConstantPrefix, ConstantSuffix)
+	}
+	for _, acronym := range n.Acronyms {
+		if acronym == "" || strings.IndexFunc(acronym, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0 {
+			return fmt.Errorf("acronym %q must be a word of letters and digits", acronym)
+		}
+	}
+	return nil
+}
+
+// Options controls optional features of the generated code
+type Options struct {
+	// ChaosHelpers generates FireRandomPermitted and RunChaos for chaos testing
+	ChaosHelpers bool
+
+	// Coverage generates counters recording how often each transition fires and a
+	// function writing them as a coverage profile
+	Coverage bool
+
+	// Trace generates a trace recorder that machines can be configured with to record
+	// the events they handle for replay against a spec
+	Trace bool
+
+	// Publisher generates a publisher interface that machines can be configured with
+	// to publish a record of every successful transition
+	Publisher bool
+
+	// UnknownState is the policy for restoring persisted values not in the state enum;
+	// empty means UnknownStateError
+	UnknownState UnknownStatePolicy
+
+	// QuarantineState is the state unknown values are mapped to under UnknownStateQuarantine
+	QuarantineState string
+
+	// ZeroState is the meaning of the zero value of the state type; empty means ZeroStateInitial
+	ZeroState ZeroStatePolicy
+
+	// StableValues makes generation refuse to change or reuse the numeric value of a
+	// state or event constant already present in the previously generated code
+	StableValues bool
+
+	// SideBySide generates the machine under a name suffixed with its version, so
+	// that several versions of it can be generated into the same package
+	SideBySide bool
+
+	// Callbacks generates a callbacks interface with a method for every guard and
+	// action, and a constructor taking an implementation of it
+	Callbacks bool
+
+	// UserRegions adds regions to the generated file whose code regeneration
+	// keeps, for hand-written imports and helpers
+	UserRegions bool
+
+	// ImmutableContext generates the context fields as unexported, with getters
+	// and copy-on-write WithX updaters, so that callbacks change the context only
+	// through Update{Name}Context
+	ImmutableContext bool
+
+	// UnhandledEvent is the policy for events the current state has no transition
+	// on, unless the state overrides it; empty means UnhandledEventError
+	UnhandledEvent UnhandledEventPolicy
+
+	// IdempotencyKeys is the number of idempotency keys per state TransitionWithKey
+	// remembers; zero generates no TransitionWithKey
+	IdempotencyKeys int
+
+	// RecoverPanics makes transitions recover panics of guards and actions and
+	// return them as errors
+	RecoverPanics bool
+
+	// Reentrancy is the policy for transitions fired from the guards and actions
+	// of a transition; empty generates no detection
+	Reentrancy ReentrancyPolicy
+
+	// Naming controls the identifiers of the generated code
+	Naming Naming
+}
+
+// ZeroStatePolicyOrDefault returns the configured zero-value policy, defaulting to ZeroStateInitial
+func (o Options) ZeroStatePolicyOrDefault() ZeroStatePolicy {
+	if o.ZeroState == "" {
+		return ZeroStateInitial
+	}
+	return o.ZeroState
+}
+
+// UnknownStatePolicyOrDefault returns the configured unknown-state policy, defaulting to UnknownStateError
+func (o Options) UnknownStatePolicyOrDefault() UnknownStatePolicy {
+	if o.UnknownState == "" {
+		return UnknownStateError
+	}
+	return o.UnknownState
+}
+
+// validate checks the options against the states of the machine
+func (o Options) validate(states map[string]*State) error {
+	switch o.UnknownStatePolicyOrDefault() {
+	case UnknownStateError, UnknownStateHandler:
+		if o.QuarantineState != "" {
+			return fmt.Errorf("quarantine state %q requires the %q unknown state policy", o.QuarantineState, UnknownStateQuarantine)
+		}
+	case UnknownStateQuarantine:
+		if o.QuarantineState == "" {
+			return fmt.Errorf("unknown state policy %q requires a quarantine state", UnknownStateQuarantine)
+		}
+		if _, exists := states[o.QuarantineState]; !exists {
+			return fmt.Errorf("quarantine state %q is not defined", o.QuarantineState)
+		}
+	default:
+		return fmt.Errorf("unknown state policy %q is not one of %q, %q, %q",
+			o.UnknownState, UnknownStateError, UnknownStateQuarantine, UnknownStateHandler)
+	}
+
+	if err := o.Naming.validate(); err != nil {
+		return err
+	}
+
+	switch o.ZeroStatePolicyOrDefault() {
+	case ZeroStateInitial, ZeroStateInvalid:
+	case ZeroStateUnspecified:
+		if _, exists := states["unspecified"]; exists {
+			return fmt.Errorf("state %q collides with the zero value sentinel of the %q zero state policy", "unspecified", ZeroStateUnspecified)
+		}
+	default:
+		return fmt.Errorf("zero state policy %q is not one of %q, %q, %q",
+			o.ZeroState, ZeroStateInitial, ZeroStateUnspecified, ZeroStateInvalid)
+	}
+
+	if o.IdempotencyKeys < 0 {
+		return fmt.Errorf("idempotency keys %d cannot be negative", o.IdempotencyKeys)
+	}
+
+	if err := o.UnhandledEvent.validate(); err != nil {
+		return err
+	}
+
+	switch o.Reentrancy {
+	case "", ReentrancyError, ReentrancyQueue:
+	default:
+		return fmt.Errorf("reentrancy policy %q is not one of %q, %q", o.Reentrancy, ReentrancyError, ReentrancyQueue)
+	}
+
+	return nil
+}
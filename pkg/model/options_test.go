@@ -0,0 +1,188 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newLegacyOrderFSM creates an order machine with a state reserved for unmigrated records
+func newLegacyOrderFSM() *FSMModel {
+	fsm, _ := NewFSMModel("OrderStateMachine", "pending")
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "approved"})
+	fsm.AddState(&State{Name: "legacy"})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve"})
+	return fsm
+}
+
+func TestOptions_UnknownStateValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     UnknownStatePolicy
+		quarantine string
+		wantErr    string
+	}{
+		{
+			name:   "default policy rejects unknown values",
+			policy: "",
+		},
+		{
+			name:   "explicit error policy",
+			policy: UnknownStateError,
+		},
+		{
+			name:       "quarantine into legacy state",
+			policy:     UnknownStateQuarantine,
+			quarantine: "legacy",
+		},
+		{
+			name:   "handler policy",
+			policy: UnknownStateHandler,
+		},
+		{
+			name:    "quarantine without target state",
+			policy:  UnknownStateQuarantine,
+			wantErr: "requires a quarantine state",
+		},
+		{
+			name:       "quarantine into undefined state",
+			policy:     UnknownStateQuarantine,
+			quarantine: "archived",
+			wantErr:    `quarantine state "archived" is not defined`,
+		},
+		{
+			name:       "quarantine state with error policy",
+			policy:     UnknownStateError,
+			quarantine: "legacy",
+			wantErr:    "requires the \"quarantine\" unknown state policy",
+		},
+		{
+			name:    "unsupported policy",
+			policy:  "ignore",
+			wantErr: `unknown state policy "ignore"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := newLegacyOrderFSM()
+			fsm.Options.UnknownState = tt.policy
+			fsm.Options.QuarantineState = tt.quarantine
+
+			err := fsm.Validate()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptions_UnknownStatePolicyOrDefault(t *testing.T) {
+	assert.Equal(t, UnknownStateError, Options{}.UnknownStatePolicyOrDefault())
+	assert.Equal(t, UnknownStateHandler, Options{UnknownState: UnknownStateHandler}.UnknownStatePolicyOrDefault())
+}
+
+func TestOptions_ZeroStateValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ZeroStatePolicy
+		states  []string
+		wantErr string
+	}{
+		{
+			name:   "default policy",
+			policy: "",
+		},
+		{
+			name:   "unspecified sentinel",
+			policy: ZeroStateUnspecified,
+		},
+		{
+			name:   "invalid zero value",
+			policy: ZeroStateInvalid,
+		},
+		{
+			name:    "sentinel collides with declared state",
+			policy:  ZeroStateUnspecified,
+			states:  []string{"unspecified"},
+			wantErr: `state "unspecified" collides with the zero value sentinel`,
+		},
+		{
+			name:    "unsupported policy",
+			policy:  "nil",
+			wantErr: `zero state policy "nil"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := newLegacyOrderFSM()
+			for _, name := range tt.states {
+				fsm.AddState(&State{Name: name})
+			}
+			fsm.Options.ZeroState = tt.policy
+
+			err := fsm.Validate()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestOptions_ZeroStatePolicyOrDefault(t *testing.T) {
+	assert.Equal(t, ZeroStateInitial, Options{}.ZeroStatePolicyOrDefault())
+	assert.Equal(t, ZeroStateInvalid, Options{ZeroState: ZeroStateInvalid}.ZeroStatePolicyOrDefault())
+}
+
+func TestOptions_NamingValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		naming  Naming
+		wantErr string
+	}{
+		{
+			name: "default naming",
+		},
+		{
+			name:   "suffixed constants without the machine name",
+			naming: Naming{Constants: ConstantSuffix, OmitMachineName: true, Acronyms: []string{"ID", "http2"}},
+		},
+		{
+			name:    "unsupported constant style",
+			naming:  Naming{Constants: "infix"},
+			wantErr: `constant style "infix" is not one of "prefix", "suffix"`,
+		},
+		{
+			name:    "acronym with a separator",
+			naming:  Naming{Acronyms: []string{"ID", "A-B"}},
+			wantErr: `acronym "A-B" must be a word of letters and digits`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := newLegacyOrderFSM()
+			fsm.Options.Naming = tt.naming
+
+			err := fsm.Validate()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNaming_Defaults(t *testing.T) {
+	assert.Equal(t, ConstantPrefix, Naming{}.ConstantStyleOrDefault())
+	assert.True(t, Naming{Acronyms: []string{"URL"}}.IsAcronym("url"))
+	assert.False(t, Naming{}.IsAcronym("id"))
+}
@@ -0,0 +1,289 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+)
+
+// PropertyKind identifies a model-level property checked by generated simulation tests
+type PropertyKind string
+
+const (
+	// PropertyNeverFollowedBy requires that Then is never visited after State
+	PropertyNeverFollowedBy PropertyKind = "never_followed_by"
+
+	// PropertyReachesFinal requires every run to reach a final state within Within events
+	PropertyReachesFinal PropertyKind = "reaches_final"
+
+	// PropertyNever requires that no run visits the states and fires the events of
+	// Sequence in that order, with anything in between
+	PropertyNever PropertyKind = "never"
+
+	// PropertyEventually requires every run to visit the state or fire the event
+	// named by Target
+	PropertyEventually PropertyKind = "eventually"
+)
+
+// DefaultPropertyMaxSteps bounds the length of simulated runs when no property needs longer ones
+const DefaultPropertyMaxSteps = 100
+
+// Property is a model-level property that must hold for every run of the machine
+type Property struct {
+	// Name is the unique identifier for this property
+	Name string
+
+	// Kind selects which property is checked
+	Kind PropertyKind
+
+	// State is the state after which Then must never occur (never_followed_by)
+	State string
+
+	// Then is the state that must never follow State (never_followed_by)
+	Then string
+
+	// Within is the maximum number of events before a final state is reached (reaches_final)
+	Within int
+
+	// Sequence are the states and events that must never occur in order (never)
+	Sequence []string
+
+	// Target is the state or event every run must reach (eventually)
+	Target string
+
+	// Description is an optional human-readable description
+	Description string
+}
+
+// Validate checks that the property is well-formed for the given states and events
+func (p *Property) Validate(states map[string]*State, events map[string]*Event) error {
+	if p.Name == "" {
+		return fmt.Errorf("property name cannot be empty")
+	}
+
+	if !validNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("property name %q contains invalid characters (use only letters, digits, and underscores)", p.Name)
+	}
+
+	switch p.Kind {
+	case PropertyNeverFollowedBy:
+		for _, name := range []string{p.State, p.Then} {
+			if name == "" {
+				return fmt.Errorf("property %q requires both state and then", p.Name)
+			}
+			if _, exists := states[name]; !exists {
+				return fmt.Errorf("property %q: state %q is not defined", p.Name, name)
+			}
+		}
+	case PropertyReachesFinal:
+		if p.Within <= 0 {
+			return fmt.Errorf("property %q: within must be positive, got %d", p.Name, p.Within)
+		}
+		hasFinal := false
+		for _, state := range states {
+			hasFinal = hasFinal || state.Final
+		}
+		if !hasFinal {
+			return fmt.Errorf("property %q requires at least one final state", p.Name)
+		}
+	case PropertyNever:
+		if len(p.Sequence) == 0 {
+			return fmt.Errorf("property %q requires a sequence of states and events", p.Name)
+		}
+		for _, name := range p.Sequence {
+			if err := p.validateStep(name, states, events); err != nil {
+				return err
+			}
+		}
+	case PropertyEventually:
+		if p.Target == "" {
+			return fmt.Errorf("property %q requires a state or event to reach", p.Name)
+		}
+		if err := p.validateStep(p.Target, states, events); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("property %q has unsupported kind %q (use %q, %q, %q, or %q)",
+			p.Name, p.Kind, PropertyNeverFollowedBy, PropertyReachesFinal, PropertyNever, PropertyEventually)
+	}
+
+	return nil
+}
+
+// validateStep checks that name refers to exactly one state or event
+func (p *Property) validateStep(name string, states map[string]*State, events map[string]*Event) error {
+	_, isState := states[name]
+	_, isEvent := events[name]
+	switch {
+	case isState && isEvent:
+		return fmt.Errorf("property %q: %q is both a state and an event", p.Name, name)
+	case !isState && !isEvent:
+		return fmt.Errorf("property %q: %q is not a defined state or event", p.Name, name)
+	}
+	return nil
+}
+
+// Counterexample is a run of the machine that violates a property, found in the
+// graph with every guard allowing its transition
+type Counterexample struct {
+	// From is the state the run starts in
+	From string
+
+	// Path are the transitions of the run
+	Path []*Transition
+
+	// Loop are the transitions the run then repeats forever, starting and ending
+	// in the last state of Path; empty when the run ends or is cut short at the
+	// violation
+	Loop []*Transition
+}
+
+func (c *Counterexample) String() string {
+	s := FormatPath(c.From, c.Path)
+	if len(c.Loop) > 0 {
+		s += ", then repeats " + FormatPath(c.Loop[0].From, c.Loop)
+	}
+	return s
+}
+
+// CheckProperty searches the graph for a run that violates a never,
+// never_followed_by, or eventually property and returns the shortest one it
+// finds, or nil when the property holds. Guards are assumed to allow every
+// transition, so a counterexample may need guards that can never pass together,
+// but a property that holds here holds for the generated machine. Runs end in
+// final states. Other kinds of properties are not checked and return nil.
+func (g *StateGraph) CheckProperty(p *Property) *Counterexample {
+	switch p.Kind {
+	case PropertyNeverFollowedBy:
+		return g.checkNever([]string{p.State, p.Then})
+	case PropertyNever:
+		return g.checkNever(p.Sequence)
+	case PropertyEventually:
+		return g.checkEventually(p.Target)
+	default:
+		return nil
+	}
+}
+
+// checkNever searches breadth first over the states paired with the number of
+// steps of sequence already seen, so the first run to see them all is shortest
+func (g *StateGraph) checkNever(sequence []string) *Counterexample {
+	type node struct {
+		state   string
+		matched int
+	}
+	advance := func(matched int, name string) int {
+		if matched < len(sequence) && sequence[matched] == name {
+			matched++
+		}
+		return matched
+	}
+
+	start := node{g.FSM.Initial, advance(0, g.FSM.Initial)}
+	if start.matched == len(sequence) {
+		return &Counterexample{From: g.FSM.Initial, Path: []*Transition{}}
+	}
+	via := map[node]*Transition{start: nil}
+	from := map[node]node{}
+	queue := []node{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if state := g.FSM.GetState(current.state); state != nil && state.Final {
+			continue
+		}
+		for _, t := range g.adjacencyList[current.state] {
+			next := node{t.To, advance(advance(current.matched, t.Event), t.To)}
+			if _, seen := via[next]; seen {
+				continue
+			}
+			via[next] = t
+			from[next] = current
+			if next.matched == len(sequence) {
+				var path []*Transition
+				for n := next; via[n] != nil; n = from[n] {
+					path = append(path, via[n])
+				}
+				slices.Reverse(path)
+				return &Counterexample{From: g.FSM.Initial, Path: path}
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
+// checkEventually looks for a run that avoids target: one that reaches a final
+// state or a state without transitions, or failing that one that loops forever
+func (g *StateGraph) checkEventually(target string) *Counterexample {
+	if g.FSM.Initial == target {
+		return nil
+	}
+	avoids := func(t *Transition) bool { return t.Event != target && t.To != target }
+
+	via := map[string]*Transition{g.FSM.Initial: nil}
+	queue := []string{g.FSM.Initial}
+	pathTo := func(state string) []*Transition {
+		var path []*Transition
+		for t := via[state]; t != nil; t = via[t.From] {
+			path = append(path, t)
+		}
+		slices.Reverse(path)
+		return path
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		if s := g.FSM.GetState(state); (s != nil && s.Final) || len(g.adjacencyList[state]) == 0 {
+			return &Counterexample{From: g.FSM.Initial, Path: pathTo(state)}
+		}
+		for _, t := range g.adjacencyList[state] {
+			if _, seen := via[t.To]; !seen && avoids(t) {
+				via[t.To] = t
+				queue = append(queue, t.To)
+			}
+		}
+	}
+
+	// Every avoiding run continues, so look for a cycle among the states they
+	// reach: a transition back to a state on the stack of a depth-first search
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	color := make(map[string]int)
+	color[g.FSM.Initial] = onStack
+	frames := []searchFrame{{state: g.FSM.Initial}}
+	var stack []*Transition
+	for len(frames) > 0 {
+		frame := &frames[len(frames)-1]
+		transitions := g.adjacencyList[frame.state]
+		if frame.next == len(transitions) {
+			color[frame.state] = done
+			frames = frames[:len(frames)-1]
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			continue
+		}
+		t := transitions[frame.next]
+		frame.next++
+		if !avoids(t) {
+			continue
+		}
+		switch color[t.To] {
+		case onStack:
+			i := 0
+			for i < len(stack) && stack[i].From != t.To {
+				i++
+			}
+			loop := append(append([]*Transition{}, stack[i:]...), t)
+			return &Counterexample{From: g.FSM.Initial, Path: pathTo(t.To), Loop: loop}
+		case unvisited:
+			color[t.To] = onStack
+			stack = append(stack, t)
+			frames = append(frames, searchFrame{state: t.To})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newShippingFSM creates an order machine whose shipped state is final
+func newShippingFSM() *FSMModel {
+	fsm, _ := NewFSMModel("OrderStateMachine", "pending")
+	fsm.AddState(&State{Name: "pending"})
+	fsm.AddState(&State{Name: "approved"})
+	fsm.AddState(&State{Name: "shipped", Final: true})
+	fsm.AddEvent(&Event{Name: "approve"})
+	fsm.AddEvent(&Event{Name: "ship"})
+	fsm.AddTransition(&Transition{From: "pending", To: "approved", Event: "approve"})
+	fsm.AddTransition(&Transition{From: "approved", To: "shipped", Event: "ship"})
+	return fsm
+}
+
+func TestProperty_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		property Property
+		noFinal  bool
+		wantErr  string
+	}{
+		{
+			name:     "never followed by",
+			property: Property{Name: "no_reopen", Kind: PropertyNeverFollowedBy, State: "shipped", Then: "pending"},
+		},
+		{
+			name:     "reaches final",
+			property: Property{Name: "terminates", Kind: PropertyReachesFinal, Within: 5},
+		},
+		{
+			name:     "never",
+			property: Property{Name: "no_ship_before_approval", Kind: PropertyNever, Sequence: []string{"pending", "ship"}},
+		},
+		{
+			name:     "eventually",
+			property: Property{Name: "ships", Kind: PropertyEventually, Target: "shipped"},
+		},
+		{
+			name:     "never without sequence",
+			property: Property{Name: "nothing", Kind: PropertyNever},
+			wantErr:  "requires a sequence of states and events",
+		},
+		{
+			name:     "never with undefined step",
+			property: Property{Name: "no_refund", Kind: PropertyNever, Sequence: []string{"shipped", "refund"}},
+			wantErr:  `"refund" is not a defined state or event`,
+		},
+		{
+			name:     "eventually without target",
+			property: Property{Name: "ships", Kind: PropertyEventually},
+			wantErr:  "requires a state or event to reach",
+		},
+		{
+			name:     "missing name",
+			property: Property{Kind: PropertyReachesFinal, Within: 5},
+			wantErr:  "property name cannot be empty",
+		},
+		{
+			name:     "invalid name",
+			property: Property{Name: "never reopen", Kind: PropertyReachesFinal, Within: 5},
+			wantErr:  "contains invalid characters",
+		},
+		{
+			name:     "never followed by without then",
+			property: Property{Name: "no_reopen", Kind: PropertyNeverFollowedBy, State: "shipped"},
+			wantErr:  "requires both state and then",
+		},
+		{
+			name:     "never followed by undefined state",
+			property: Property{Name: "no_reopen", Kind: PropertyNeverFollowedBy, State: "delivered", Then: "pending"},
+			wantErr:  `state "delivered" is not defined`,
+		},
+		{
+			name:     "reaches final without bound",
+			property: Property{Name: "terminates", Kind: PropertyReachesFinal},
+			wantErr:  "within must be positive",
+		},
+		{
+			name:     "reaches final without final states",
+			property: Property{Name: "terminates", Kind: PropertyReachesFinal, Within: 5},
+			noFinal:  true,
+			wantErr:  "requires at least one final state",
+		},
+		{
+			name:     "unsupported kind",
+			property: Property{Name: "live", Kind: "always"},
+			wantErr:  `unsupported kind "always"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := newShippingFSM()
+			if tt.noFinal {
+				fsm.GetState("shipped").Final = false
+			}
+
+			err := tt.property.Validate(fsm.States, fsm.Events)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestFSMModel_AddProperty(t *testing.T) {
+	fsm := newShippingFSM()
+
+	require.NoError(t, fsm.AddProperty(&Property{Name: "terminates", Kind: PropertyReachesFinal, Within: 5}))
+	assert.Error(t, fsm.AddProperty(nil))
+	assert.ErrorContains(t, fsm.AddProperty(&Property{Name: "terminates", Kind: PropertyReachesFinal, Within: 9}),
+		`property "terminates" already exists`)
+
+	require.NoError(t, fsm.AddProperty(&Property{Name: "bad", Kind: PropertyNeverFollowedBy, State: "shipped", Then: "lost"}))
+	assert.ErrorContains(t, fsm.Validate(), "invalid property")
+}
+
+func TestFSMModel_PropertyMaxSteps(t *testing.T) {
+	fsm := newShippingFSM()
+	assert.Equal(t, DefaultPropertyMaxSteps, fsm.PropertyMaxSteps())
+	assert.Equal(t, []string{"shipped"}, fsm.GetFinalStateNames())
+
+	fsm.AddProperty(&Property{Name: "terminates", Kind: PropertyReachesFinal, Within: 250})
+	assert.Equal(t, 250, fsm.PropertyMaxSteps(), "runs must be long enough to observe a missed bound")
+}
+
+func TestStateGraph_CheckProperty(t *testing.T) {
+	fsm := newShippingFSM()
+	fsm.AddState(&State{Name: "on_hold"})
+	fsm.AddState(&State{Name: "cancelled", Final: true})
+	fsm.AddEvent(&Event{Name: "hold"})
+	fsm.AddEvent(&Event{Name: "release"})
+	fsm.AddEvent(&Event{Name: "cancel"})
+	fsm.AddTransition(&Transition{From: "approved", To: "on_hold", Event: "hold"})
+	fsm.AddTransition(&Transition{From: "on_hold", To: "approved", Event: "release", Guard: "isCleared"})
+	graph := NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+
+	check := func(p Property) string {
+		if c := graph.CheckProperty(&p); c != nil {
+			return c.String()
+		}
+		return ""
+	}
+
+	assert.Empty(t, check(Property{Kind: PropertyNever, Sequence: []string{"shipped", "approve"}}), "Runs end in final states")
+	assert.Equal(t, "pending --approve--> approved --hold--> on_hold --release [isCleared]--> approved",
+		check(Property{Kind: PropertyNever, Sequence: []string{"hold", "approved"}}))
+	assert.Equal(t, "pending", check(Property{Kind: PropertyNever, Sequence: []string{"pending"}}))
+	assert.Equal(t, "pending --approve--> approved --hold--> on_hold --release [isCleared]--> approved",
+		check(Property{Kind: PropertyNeverFollowedBy, State: "on_hold", Then: "approved"}))
+
+	assert.Empty(t, check(Property{Kind: PropertyEventually, Target: "approve"}))
+	assert.Equal(t, "pending --approve--> approved, then repeats approved --hold--> on_hold --release [isCleared]--> approved",
+		check(Property{Kind: PropertyEventually, Target: "shipped"}), "Every run that avoids shipping loops")
+
+	fsm.AddTransition(&Transition{From: "on_hold", To: "cancelled", Event: "cancel"})
+	graph = NewStateGraph(fsm)
+	require.NoError(t, graph.Build())
+	assert.Equal(t, "pending --approve--> approved --hold--> on_hold --cancel--> cancelled",
+		check(Property{Kind: PropertyEventually, Target: "shipped"}), "A run that ends is preferred to a loop")
+
+	assert.Nil(t, graph.CheckProperty(&Property{Kind: PropertyReachesFinal, Within: 1}))
+}
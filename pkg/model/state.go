@@ -3,6 +3,7 @@ package model
 import (
 	"fmt"
 	"regexp"
+	"time"
 )
 
 // State represents a single state in the finite state machine
@@ -18,10 +19,47 @@ type State struct {
 
 	// Description is an optional human-readable description
 	Description string
+
+	// Final marks a state in which runs of the machine end
+	Final bool
+
+	// Value pins the numeric value of the generated constant; nil assigns one automatically
+	Value *int
+
+	// Tags label the state for tooling such as diagram styling
+	Tags []string
+
+	// Metadata holds custom key-value data used by exporters
+	Metadata map[string]string
+
+	// IgnoredEvents are events the state deliberately has no transition for: the
+	// generated machine accepts them as no-ops and the unhandled-event lint rule
+	// does not report them
+	IgnoredEvents []string
+
+	// Deprecated marks a state kept only for persisted machines; its constant is
+	// marked deprecated and lint warns about transitions into it
+	Deprecated bool
+
+	// DeprecationReason optionally explains the deprecation, such as what to use instead
+	DeprecationReason string
+
+	// UnhandledEvent overrides the unhandled event policy of the machine for this
+	// state; empty keeps it
+	UnhandledEvent UnhandledEventPolicy
+
+	// RequiredContext are the context fields that must be set for a transition into
+	// the state to complete
+	RequiredContext []string
+
+	// MaxDuration is the SLA of the state, the longest a machine is expected to stay
+	// in it; zero means none
+	MaxDuration time.Duration
 }
 
-// validNamePattern matches valid Go identifiers (letters, digits, underscores)
-var validNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+// validNamePattern matches valid Go identifiers (letters, digits, underscores),
+// which may be non-ASCII as in Go
+var validNamePattern = regexp.MustCompile(`^[\p{L}_][\p{L}\p{Nd}_]*$`)
 
 // NewState creates a new State with the given name
 func NewState(name string) (*State, error) {
@@ -68,5 +106,37 @@ func (s *State) Validate() error {
 		return fmt.Errorf("state name %q contains invalid characters (use only letters, digits, and underscores)", s.Name)
 	}
 
+	if s.Value != nil && *s.Value < 0 {
+		return fmt.Errorf("state %q value cannot be negative", s.Name)
+	}
+
+	for _, tag := range s.Tags {
+		if tag == "" {
+			return fmt.Errorf("state %q has an empty tag", s.Name)
+		}
+	}
+
+	if s.DeprecationReason != "" && !s.Deprecated {
+		return fmt.Errorf("state %q has a deprecation reason but is not deprecated", s.Name)
+	}
+
+	if err := s.UnhandledEvent.validate(); err != nil {
+		return fmt.Errorf("state %q: %w", s.Name, err)
+	}
+
+	if s.MaxDuration < 0 {
+		return fmt.Errorf("state %q max duration cannot be negative", s.Name)
+	}
+
 	return nil
 }
+
+// Ignores reports whether event is one of the events the state ignores
+func (s *State) Ignores(event string) bool {
+	for _, ignored := range s.IgnoredEvents {
+		if ignored == event {
+			return true
+		}
+	}
+	return false
+}
@@ -22,11 +22,26 @@ func TestState_NewState(t *testing.T) {
 			stateName: "in_progress",
 			wantErr:   false,
 		},
+		{
+			name:      "valid non-ASCII state name",
+			stateName: "zahlung_bestätigt",
+			wantErr:   false,
+		},
+		{
+			name:      "valid state name in a script without case",
+			stateName: "保留中",
+			wantErr:   false,
+		},
 		{
 			name:      "empty state name",
 			stateName: "",
 			wantErr:   true,
 		},
+		{
+			name:      "state name starting with a digit",
+			stateName: "1st",
+			wantErr:   true,
+		},
 		{
 			name:      "state name with spaces",
 			stateName: "in progress",
@@ -126,6 +141,23 @@ func TestState_Validate(t *testing.T) {
 		state   *State
 		wantErr bool
 	}{
+		{
+			name: "valid deprecated state with a reason",
+			state: &State{
+				Name:              "pending",
+				Deprecated:        true,
+				DeprecationReason: "Use another state instead",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid deprecation reason without deprecation",
+			state: &State{
+				Name:              "pending",
+				DeprecationReason: "Use another state instead",
+			},
+			wantErr: true,
+		},
 		{
 			name: "valid state with no actions",
 			state: &State{
@@ -164,6 +196,22 @@ func TestState_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid state with tags",
+			state: &State{
+				Name: "shipped",
+				Tags: []string{"critical"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid state with empty tag",
+			state: &State{
+				Name: "shipped",
+				Tags: []string{""},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
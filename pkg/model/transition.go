@@ -1,6 +1,9 @@
 package model
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Transition represents a state transition in the finite state machine
 type Transition struct {
@@ -21,6 +24,18 @@ type Transition struct {
 
 	// Description is an optional human-readable description
 	Description string
+
+	// Inverse is the optional event whose transition from To back to From undoes
+	// this transition, which TransitionAll rolls back with
+	Inverse string
+
+	// Compensate is the optional event whose transition from To back to From
+	// compensates this transition, which Compensate walks back the history with
+	Compensate string
+
+	// MaxDuration overrides the SLA of the target state for the stays this
+	// transition begins; zero keeps the SLA of the state
+	MaxDuration time.Duration
 }
 
 // NewTransition creates a new Transition
@@ -78,6 +93,10 @@ func (t *Transition) Validate() error {
 		return fmt.Errorf("event cannot be empty")
 	}
 
+	if t.MaxDuration < 0 {
+		return fmt.Errorf("transition from %q on %q max duration cannot be negative", t.From, t.Event)
+	}
+
 	return nil
 }
 
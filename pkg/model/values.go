@@ -0,0 +1,139 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StateValue returns the generated enum value of the named state, or -1 if it is not defined.
+// States with a pinned value keep it. Under the initial zero state policy an unpinned initial
+// state is 0; the remaining unpinned states are numbered from 1 in name order, skipping
+// pinned values and the values of legacy states. Under the other policies 0 is never assigned.
+func (f *FSMModel) StateValue(name string) int {
+	if _, exists := f.States[name]; !exists {
+		return -1
+	}
+	return f.GetStateValues()[name]
+}
+
+// GetStateValues returns the generated enum values of all states by name, as
+// StateValue does for one. Looking a value up in it is cheaper than calling
+// StateValue, which numbers every state, for each state of a large machine.
+func (f *FSMModel) GetStateValues() map[string]int {
+	initialIsZero := f.Options.ZeroStatePolicyOrDefault() == ZeroStateInitial
+
+	var names []string
+	pinned := make(map[int]bool)
+	for _, legacy := range f.LegacyStates {
+		if legacy.Value != nil {
+			pinned[*legacy.Value] = true
+		}
+	}
+	for _, s := range f.GetStatesSlice() {
+		switch {
+		case s.Value != nil:
+			pinned[*s.Value] = true
+		case !(initialIsZero && s.Name == f.Initial):
+			names = append(names, s.Name)
+		}
+	}
+
+	values := autoValues(names, pinned, 1)
+	for name, s := range f.States {
+		switch {
+		case s.Value != nil:
+			values[name] = *s.Value
+		case initialIsZero && name == f.Initial:
+			values[name] = 0
+		}
+	}
+	return values
+}
+
+// EventValue returns the generated enum value of the named event, or -1 if it is not defined.
+// Events with a pinned value keep it; the others are numbered from 0 in name order,
+// skipping pinned values.
+func (f *FSMModel) EventValue(name string) int {
+	if _, exists := f.Events[name]; !exists {
+		return -1
+	}
+	return f.GetEventValues()[name]
+}
+
+// GetEventValues returns the generated enum values of all events by name, as
+// EventValue does for one
+func (f *FSMModel) GetEventValues() map[string]int {
+	var names []string
+	pinned := make(map[int]bool)
+	for _, e := range f.GetEventsSlice() {
+		if e.Value != nil {
+			pinned[*e.Value] = true
+		} else {
+			names = append(names, e.Name)
+		}
+	}
+
+	values := autoValues(names, pinned, 0)
+	for name, e := range f.Events {
+		if e.Value != nil {
+			values[name] = *e.Value
+		}
+	}
+	return values
+}
+
+// autoValues numbers names in order from start, skipping the pinned values
+func autoValues(names []string, pinned map[int]bool, start int) map[string]int {
+	values := make(map[string]int, len(names))
+	next := start
+	for _, name := range names {
+		for pinned[next] {
+			next++
+		}
+		values[name] = next
+		next++
+	}
+	return values
+}
+
+// validateValues checks that pinned values respect the zero state policy and that
+// no two states or two events end up with the same value
+func (f *FSMModel) validateValues() error {
+	policy := f.Options.ZeroStatePolicyOrDefault()
+	for _, state := range f.GetStatesSlice() {
+		if state.Value == nil {
+			continue
+		}
+		switch {
+		case policy == ZeroStateInitial && state.Name == f.Initial && *state.Value != 0:
+			return fmt.Errorf("initial state %q must have value 0 under the %q zero state policy", state.Name, policy)
+		case policy == ZeroStateInitial && state.Name != f.Initial && *state.Value == 0:
+			return fmt.Errorf("state %q cannot use value 0, which is reserved for the initial state %q", state.Name, f.Initial)
+		case policy != ZeroStateInitial && *state.Value == 0:
+			return fmt.Errorf("state %q cannot use value 0, which is reserved for the zero value under the %q zero state policy", state.Name, policy)
+		}
+	}
+
+	if err := checkDistinctValues("states", f.GetStateValues()); err != nil {
+		return err
+	}
+	return checkDistinctValues("events", f.GetEventValues())
+}
+
+// checkDistinctValues reports the first two names, in name order, sharing a value
+func checkDistinctValues(kind string, values map[string]int) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	owners := make(map[int]string, len(names))
+	for _, name := range names {
+		v := values[name]
+		if owner, taken := owners[v]; taken {
+			return fmt.Errorf("%s %q and %q both use value %d", kind, owner, name, v)
+		}
+		owners[v] = name
+	}
+	return nil
+}
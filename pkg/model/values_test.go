@@ -0,0 +1,137 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSMModel_StateValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ZeroStatePolicy
+		want   map[string]int
+	}{
+		{
+			name:   "initial state is the zero value",
+			policy: ZeroStateInitial,
+			want:   map[string]int{"pending": 0, "approved": 1, "shipped": 2, "cancelled": -1},
+		},
+		{
+			name:   "unspecified sentinel reserves zero",
+			policy: ZeroStateUnspecified,
+			want:   map[string]int{"approved": 1, "pending": 2, "shipped": 3},
+		},
+		{
+			name:   "invalid zero value",
+			policy: ZeroStateInvalid,
+			want:   map[string]int{"approved": 1, "pending": 2, "shipped": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm, err := NewFSMModel("OrderStateMachine", "pending")
+			require.NoError(t, err)
+			for _, name := range []string{"shipped", "pending", "approved"} {
+				fsm.AddState(&State{Name: name})
+			}
+			fsm.Options.ZeroState = tt.policy
+
+			for state, want := range tt.want {
+				assert.Equal(t, want, fsm.StateValue(state), state)
+			}
+		})
+	}
+}
+
+func TestFSMModel_PinnedValues(t *testing.T) {
+	pin := func(v int) *int { return &v }
+
+	fsm := newShippingFSM()
+	fsm.AddState(&State{Name: "cancelled"})
+	fsm.GetState("shipped").Value = pin(1)
+	fsm.GetEvent("ship").Value = pin(0)
+	require.NoError(t, fsm.Validate())
+
+	assert.Equal(t, 0, fsm.StateValue("pending"), "The unpinned initial state stays zero")
+	assert.Equal(t, 1, fsm.StateValue("shipped"))
+	assert.Equal(t, 2, fsm.StateValue("approved"), "Automatic values skip pinned ones")
+	assert.Equal(t, 3, fsm.StateValue("cancelled"))
+	assert.Equal(t, 0, fsm.EventValue("ship"))
+	assert.Equal(t, 1, fsm.EventValue("approve"))
+	assert.Equal(t, -1, fsm.EventValue("refund"))
+
+	assert.Equal(t, map[string]int{"pending": 0, "shipped": 1, "approved": 2, "cancelled": 3}, fsm.GetStateValues())
+	assert.Equal(t, map[string]int{"ship": 0, "approve": 1}, fsm.GetEventValues())
+}
+
+func TestFSMModel_ValidateValues(t *testing.T) {
+	pin := func(v int) *int { return &v }
+
+	tests := []struct {
+		name    string
+		policy  ZeroStatePolicy
+		states  map[string]int
+		events  map[string]int
+		wantErr string
+	}{
+		{
+			name:    "pinned states collide",
+			states:  map[string]int{"approved": 3, "shipped": 3},
+			wantErr: `states "approved" and "shipped" both use value 3`,
+		},
+		{
+			name:    "pinned events collide",
+			events:  map[string]int{"approve": 4, "ship": 4},
+			wantErr: `events "approve" and "ship" both use value 4`,
+		},
+		{
+			name:    "initial state pinned away from zero",
+			states:  map[string]int{"pending": 5},
+			wantErr: `initial state "pending" must have value 0`,
+		},
+		{
+			name:    "zero reserved for the initial state",
+			states:  map[string]int{"shipped": 0},
+			wantErr: `state "shipped" cannot use value 0, which is reserved for the initial state "pending"`,
+		},
+		{
+			name:    "zero reserved for the sentinel",
+			policy:  ZeroStateUnspecified,
+			states:  map[string]int{"pending": 0},
+			wantErr: `reserved for the zero value under the "unspecified" zero state policy`,
+		},
+		{
+			name:    "negative value",
+			events:  map[string]int{"ship": -1},
+			wantErr: `event "ship" value cannot be negative`,
+		},
+		{
+			name:   "pinned values leave room for automatic ones",
+			policy: ZeroStateInvalid,
+			states: map[string]int{"pending": 7, "shipped": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsm := newShippingFSM()
+			fsm.Options.ZeroState = tt.policy
+			for name, v := range tt.states {
+				fsm.GetState(name).Value = pin(v)
+			}
+			for name, v := range tt.events {
+				fsm.GetEvent(name).Value = pin(v)
+			}
+
+			err := fsm.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
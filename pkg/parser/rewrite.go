@@ -0,0 +1,633 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// RenameState returns the spec src with the state from renamed to to in every
+// section that refers to it, and the number of references renamed. Only the
+// renamed names change; comments and formatting are kept.
+func RenameState(src []byte, from, to string) ([]byte, int, error) {
+	s, err := newSpecSource(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := checkRename("state", s.stateNames(), from, to); err != nil {
+		return nil, 0, err
+	}
+
+	refs := s.stateRefs(from)
+	for _, ref := range refs {
+		if err := s.replace(ref, to); err != nil {
+			return nil, 0, err
+		}
+	}
+	out, err := s.apply()
+	return out, len(refs), err
+}
+
+// RenameEvent returns the spec src with the event from renamed to to in every
+// section that refers to it, and the number of references renamed. Only the
+// renamed names change; comments and formatting are kept.
+func RenameEvent(src []byte, from, to string) ([]byte, int, error) {
+	s, err := newSpecSource(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := checkRename("event", s.eventNames(), from, to); err != nil {
+		return nil, 0, err
+	}
+
+	refs := s.eventRefs(from)
+	for _, ref := range refs {
+		if err := s.replace(ref, to); err != nil {
+			return nil, 0, err
+		}
+	}
+	out, err := s.apply()
+	return out, len(refs), err
+}
+
+// RemoveState returns the spec src without the state name and the transitions
+// from and to it, and the number of transitions removed. A state that other
+// sections still refer to, such as the initial state, cannot be removed.
+func RemoveState(src []byte, name string) ([]byte, int, error) {
+	s, err := newSpecSource(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !s.stateNames()[name] {
+		return nil, 0, fmt.Errorf("state %q is not defined", name)
+	}
+
+	var blocking []string
+	for _, ref := range s.stateRefs(name) {
+		if ref.section != "states" && ref.section != "transitions" {
+			blocking = append(blocking, fmt.Sprintf("%s (line %d)", ref.section, ref.node.Line))
+		}
+	}
+	if len(blocking) > 0 {
+		return nil, 0, fmt.Errorf("state %q is still referenced by %s", name, strings.Join(blocking, ", "))
+	}
+
+	removed := 0
+	for _, section := range []string{"states", "transitions"} {
+		seq := mappingValue(s.root, section)
+		if seq == nil || seq.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		var indices []int
+		for i, item := range seq.Content {
+			switch section {
+			case "states":
+				if scalarValue(item, "name") == name {
+					indices = append(indices, i)
+				}
+			case "transitions":
+				if scalarValue(item, "from") == name || scalarValue(item, "to") == name {
+					indices = append(indices, i)
+				}
+			}
+		}
+		if section == "transitions" {
+			removed = len(indices)
+		}
+		if err := s.removeItems(seq, indices); err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", section, err)
+		}
+	}
+
+	out, err := s.apply()
+	return out, removed, err
+}
+
+// AddState returns the spec src with the state name appended to its states
+func AddState(src []byte, name string) ([]byte, error) {
+	s, err := newSpecSource(src)
+	if err != nil {
+		return nil, err
+	}
+	if s.stateNames()[name] {
+		return nil, fmt.Errorf("state %q is already defined", name)
+	}
+	if _, err := model.NewState(name); err != nil {
+		return nil, err
+	}
+
+	if err := s.appendItem("states", specItem{fields: [][2]string{{"name", name}}}); err != nil {
+		return nil, err
+	}
+	return s.apply()
+}
+
+// AddEvent returns the spec src with the event name appended to its events
+func AddEvent(src []byte, name string) ([]byte, error) {
+	s, err := newSpecSource(src)
+	if err != nil {
+		return nil, err
+	}
+	if s.eventNames()[name] {
+		return nil, fmt.Errorf("event %q is already defined", name)
+	}
+	if _, err := model.NewEvent(name); err != nil {
+		return nil, err
+	}
+
+	if err := s.appendItem("events", specItem{scalar: name}); err != nil {
+		return nil, err
+	}
+	return s.apply()
+}
+
+// AddTransition returns the spec src with the transition appended to its
+// transitions. Its guard and action are written only if set.
+func AddTransition(src []byte, t *model.Transition) ([]byte, error) {
+	s, err := newSpecSource(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+
+	item := specItem{fields: [][2]string{{"from", t.From}, {"to", t.To}, {"on", t.Event}}}
+	if t.Guard != "" {
+		item.fields = append(item.fields, [2]string{"guard", t.Guard})
+	}
+	if t.Action != "" {
+		item.fields = append(item.fields, [2]string{"action", t.Action})
+	}
+	// Names are written as plain scalars, so they must not need quoting
+	for _, field := range item.fields {
+		if !plainNamePattern.MatchString(field[1]) {
+			return nil, fmt.Errorf("%s %q contains invalid characters (use only letters, digits, and underscores)", field[0], field[1])
+		}
+	}
+
+	if err := s.appendItem("transitions", item); err != nil {
+		return nil, err
+	}
+	return s.apply()
+}
+
+// RemoveTransition returns the spec src without the transition at index i of its
+// transitions
+func RemoveTransition(src []byte, i int) ([]byte, error) {
+	s, err := newSpecSource(src)
+	if err != nil {
+		return nil, err
+	}
+	seq := mappingValue(s.root, "transitions")
+	if seq == nil || seq.Kind != yaml.SequenceNode || i < 0 || i >= len(seq.Content) {
+		return nil, fmt.Errorf("transition %d is not defined", i)
+	}
+
+	if err := s.removeItems(seq, []int{i}); err != nil {
+		return nil, fmt.Errorf("transitions: %w", err)
+	}
+	return s.apply()
+}
+
+// checkRename checks that from names a defined state or event and that to is a
+// valid name no other one has
+func checkRename(kind string, names map[string]bool, from, to string) error {
+	switch {
+	case !names[from]:
+		return fmt.Errorf("%s %q is not defined", kind, from)
+	case names[to]:
+		return fmt.Errorf("%s %q is already defined", kind, to)
+	}
+	if kind == "state" {
+		_, err := model.NewState(to)
+		return err
+	}
+	_, err := model.NewEvent(to)
+	return err
+}
+
+// specRef is a scalar of the spec that refers to a state or event
+type specRef struct {
+	// section is the top-level key the scalar is found under
+	section string
+	node    *yaml.Node
+}
+
+// specEdit replaces src[start:end] with text
+type specEdit struct {
+	start, end int
+	text       string
+}
+
+// specSource is the source of a spec with its node tree and pending edits
+type specSource struct {
+	src        []byte
+	root       *yaml.Node
+	lineStarts []int
+	edits      []specEdit
+}
+
+func newSpecSource(src []byte) (*specSource, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(src, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("spec is not a YAML mapping")
+	}
+
+	s := &specSource{src: src, root: doc.Content[0], lineStarts: []int{0}}
+	for i, c := range src {
+		if c == '\n' {
+			s.lineStarts = append(s.lineStarts, i+1)
+		}
+	}
+	return s, nil
+}
+
+// stateNames returns the names of the states the spec declares
+func (s *specSource) stateNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, item := range sequenceItems(s.root, "states") {
+		names[scalarValue(item, "name")] = true
+	}
+	return names
+}
+
+// eventNames returns the names of the events the spec declares
+func (s *specSource) eventNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, item := range sequenceItems(s.root, "events") {
+		names[eventName(item).Value] = true
+	}
+	return names
+}
+
+// stateRefs returns the scalars naming the state
+func (s *specSource) stateRefs(name string) []specRef {
+	return refsNaming(s.stateScalars(), name)
+}
+
+// stateScalars returns the scalars of every section that name states
+func (s *specSource) stateScalars() []specRef {
+	var refs []specRef
+	add := func(section string, node *yaml.Node) {
+		if node != nil && node.Kind == yaml.ScalarNode {
+			refs = append(refs, specRef{section, node})
+		}
+	}
+
+	add("machine", mappingValue(mappingValue(s.root, "machine"), "initial"))
+	for _, item := range sequenceItems(s.root, "states") {
+		add("states", mappingValue(item, "name"))
+	}
+	for _, item := range sequenceItems(s.root, "transitions") {
+		add("transitions", mappingValue(item, "from"))
+		add("transitions", mappingValue(item, "to"))
+	}
+	options := mappingValue(s.root, "options")
+	for _, key := range []string{"unknown_state", "quarantine_state", "zero_state"} {
+		add("options", mappingValue(options, key))
+	}
+	for _, item := range sequenceItems(s.root, "migrations") {
+		add("migrations", mappingValue(item, "to"))
+	}
+	for _, item := range sequenceItems(s.root, "properties") {
+		for _, key := range []string{"state", "then", "eventually"} {
+			add("properties", mappingValue(item, key))
+		}
+		for _, step := range sequenceItems(item, "never") {
+			add("properties", step)
+		}
+	}
+	return refs
+}
+
+// eventRefs returns the scalars naming the event
+func (s *specSource) eventRefs(name string) []specRef {
+	return refsNaming(s.eventScalars(), name)
+}
+
+// eventScalars returns the scalars of every section that name events
+func (s *specSource) eventScalars() []specRef {
+	var refs []specRef
+	add := func(section string, node *yaml.Node) {
+		if node != nil && node.Kind == yaml.ScalarNode {
+			refs = append(refs, specRef{section, node})
+		}
+	}
+
+	for _, item := range sequenceItems(s.root, "events") {
+		add("events", eventName(item))
+	}
+	for _, item := range sequenceItems(s.root, "states") {
+		for _, key := range []string{"ignore", "ignores"} {
+			for _, ignored := range sequenceItems(item, key) {
+				add("states", ignored)
+			}
+		}
+	}
+	for _, item := range sequenceItems(s.root, "transitions") {
+		add("transitions", mappingValue(item, "on"))
+	}
+	for _, item := range sequenceItems(s.root, "domain_events") {
+		add("domain_events", mappingValue(item, "event"))
+	}
+	for _, item := range sequenceItems(s.root, "properties") {
+		add("properties", mappingValue(item, "eventually"))
+		for _, step := range sequenceItems(item, "never") {
+			add("properties", step)
+		}
+	}
+	return refs
+}
+
+// refsNaming returns the refs whose scalar is name
+func refsNaming(refs []specRef, name string) []specRef {
+	var named []specRef
+	for _, ref := range refs {
+		if ref.node.Value == name {
+			named = append(named, ref)
+		}
+	}
+	return named
+}
+
+// replace replaces the name in a referring scalar, keeping its quotes
+func (s *specSource) replace(ref specRef, text string) error {
+	start := s.offset(ref.node)
+	switch ref.node.Style {
+	case 0:
+	case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+		start++
+	default:
+		return fmt.Errorf("line %d: cannot rewrite %q written as a block scalar", ref.node.Line, ref.node.Value)
+	}
+
+	end := start + len(ref.node.Value)
+	if end > len(s.src) || string(s.src[start:end]) != ref.node.Value {
+		return fmt.Errorf("line %d: cannot rewrite %q written with escapes", ref.node.Line, ref.node.Value)
+	}
+	s.edits = append(s.edits, specEdit{start, end, text})
+	return nil
+}
+
+// plainNamePattern matches the names AddTransition writes unquoted
+var plainNamePattern = regexp.MustCompile(`^[\p{L}_][\p{L}\p{Nd}_]*$`)
+
+// specItem is an item appended to a sequence: a scalar, or a mapping of keys to
+// scalars in order
+type specItem struct {
+	scalar string
+	fields [][2]string
+}
+
+// flow returns the item as it is written in flow style
+func (it specItem) flow() string {
+	if it.fields == nil {
+		return it.scalar
+	}
+	pairs := make([]string, len(it.fields))
+	for i, field := range it.fields {
+		pairs[i] = field[0] + ": " + field[1]
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// block returns the lines of the item in a block sequence whose dashes are
+// indented by indent
+func (it specItem) block(indent string) string {
+	if it.fields == nil {
+		return indent + "- " + it.scalar + "\n"
+	}
+	var b strings.Builder
+	for i, field := range it.fields {
+		prefix := indent + "  "
+		if i == 0 {
+			prefix = indent + "- "
+		}
+		fmt.Fprintf(&b, "%s%s: %s\n", prefix, field[0], field[1])
+	}
+	return b.String()
+}
+
+// appendItem appends an item to the sequence under section, in the style of
+// the sequence and its last item. A missing section is added at the end.
+func (s *specSource) appendItem(section string, item specItem) error {
+	seq := mappingValue(s.root, section)
+	switch {
+	case seq == nil:
+		text := "\n" + section + ":\n" + item.block("  ")
+		if len(s.src) > 0 && s.src[len(s.src)-1] != '\n' {
+			text = "\n" + text
+		}
+		s.edits = append(s.edits, specEdit{len(s.src), len(s.src), text})
+		return nil
+	case seq.Kind != yaml.SequenceNode:
+		return fmt.Errorf("line %d: %s is not a sequence", seq.Line, section)
+	case seq.Style&yaml.FlowStyle != 0:
+		if len(seq.Content) == 0 {
+			// Just inside the opening bracket
+			at := s.offset(seq) + 1
+			s.edits = append(s.edits, specEdit{at, at, item.flow()})
+			return nil
+		}
+		at := s.flowEnd(seq.Content[len(seq.Content)-1])
+		s.edits = append(s.edits, specEdit{at, at, ", " + item.flow()})
+		return nil
+	}
+
+	last := seq.Content[len(seq.Content)-1]
+	lineStart, dash, end, ok := s.blockItem(last)
+	if !ok {
+		return fmt.Errorf("line %d: cannot append after an item that does not start on the line of its dash", last.Line)
+	}
+	indent := string(s.src[lineStart : lineStart+dash])
+	text := item.block(indent)
+	if last.Style&yaml.FlowStyle != 0 {
+		text = indent + "- " + item.flow() + "\n"
+	}
+	if end == len(s.src) && end > 0 && s.src[end-1] != '\n' {
+		text = "\n" + text
+	}
+	s.edits = append(s.edits, specEdit{end, end, text})
+	return nil
+}
+
+// removeItems removes the items at the given ascending indices of a sequence
+func (s *specSource) removeItems(seq *yaml.Node, indices []int) error {
+	if seq.Style&yaml.FlowStyle == 0 {
+		for _, i := range indices {
+			if err := s.removeBlockItem(seq.Content[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Consecutive items of a flow sequence are removed together with the
+	// separators between them and the one before or after the run
+	for len(indices) > 0 {
+		first, last := indices[0], indices[0]
+		for len(indices) > 1 && indices[1] == last+1 {
+			indices = indices[1:]
+			last = indices[0]
+		}
+		indices = indices[1:]
+
+		switch {
+		case last+1 < len(seq.Content):
+			s.edits = append(s.edits, specEdit{s.offset(seq.Content[first]), s.offset(seq.Content[last+1]), ""})
+		case first > 0:
+			s.edits = append(s.edits, specEdit{s.flowEnd(seq.Content[first-1]), s.flowEnd(seq.Content[last]), ""})
+		default:
+			s.edits = append(s.edits, specEdit{s.offset(seq.Content[first]), s.flowEnd(seq.Content[last]), ""})
+		}
+	}
+	return nil
+}
+
+// removeBlockItem removes the lines of an item of a block sequence: the line of
+// its dash and the non-blank lines indented further that follow it
+func (s *specSource) removeBlockItem(item *yaml.Node) error {
+	lineStart, _, end, ok := s.blockItem(item)
+	if !ok {
+		return fmt.Errorf("line %d: cannot remove an item that does not start on the line of its dash", item.Line)
+	}
+	s.edits = append(s.edits, specEdit{lineStart, end, ""})
+	return nil
+}
+
+// blockItem returns the offset of the line of the dash of an item of a block
+// sequence, the column of its dash, and the offset just past the non-blank
+// lines indented further that follow it. It reports false for an item that does
+// not start on the line of its dash.
+func (s *specSource) blockItem(item *yaml.Node) (lineStart, dash, end int, ok bool) {
+	lineStart = s.lineStarts[item.Line-1]
+	dash = bytes.LastIndexByte(s.src[lineStart:s.offset(item)], '-')
+	if dash < 0 || strings.TrimSpace(string(s.src[lineStart:lineStart+dash])) != "" {
+		return 0, 0, 0, false
+	}
+
+	end = len(s.src)
+	for line := item.Line; line < len(s.lineStarts); line++ {
+		text := s.src[s.lineStarts[line]:]
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			text = text[:i]
+		}
+		trimmed := bytes.TrimLeft(text, " ")
+		if len(bytes.TrimSpace(trimmed)) == 0 || len(text)-len(trimmed) <= dash {
+			end = s.lineStarts[line]
+			break
+		}
+	}
+	return lineStart, dash, end, true
+}
+
+// offset returns the byte offset of a node in the source
+func (s *specSource) offset(node *yaml.Node) int {
+	start := s.lineStarts[node.Line-1]
+	// Columns count characters, not bytes
+	for col := 1; col < node.Column && start < len(s.src); col++ {
+		_, size := utf8.DecodeRune(s.src[start:])
+		start += size
+	}
+	return start
+}
+
+// flowEnd returns the offset just past an item of a flow sequence
+func (s *specSource) flowEnd(item *yaml.Node) int {
+	depth := 0
+	var quote byte
+	for i := s.offset(item); i < len(s.src); i++ {
+		c := s.src[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case c == ',' && depth == 0:
+			return i
+		}
+	}
+	return len(s.src)
+}
+
+// apply returns the source with the edits applied
+func (s *specSource) apply() ([]byte, error) {
+	sort.Slice(s.edits, func(i, j int) bool { return s.edits[i].start < s.edits[j].start })
+
+	var out bytes.Buffer
+	pos := 0
+	for _, e := range s.edits {
+		if e.start < pos {
+			return nil, fmt.Errorf("conflicting edits at offset %d", e.start)
+		}
+		out.Write(s.src[pos:e.start])
+		out.WriteString(e.text)
+		pos = e.end
+	}
+	out.Write(s.src[pos:])
+	return out.Bytes(), nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scalarValue returns the value of key in a mapping node, or ""
+func scalarValue(node *yaml.Node, key string) string {
+	if value := mappingValue(node, key); value != nil && value.Kind == yaml.ScalarNode {
+		return value.Value
+	}
+	return ""
+}
+
+// sequenceItems returns the items of the sequence under key in a mapping node
+func sequenceItems(node *yaml.Node, key string) []*yaml.Node {
+	if seq := mappingValue(node, key); seq != nil && seq.Kind == yaml.SequenceNode {
+		return seq.Content
+	}
+	return nil
+}
+
+// eventName returns the scalar naming an event in the simple or extended syntax
+func eventName(item *yaml.Node) *yaml.Node {
+	if item.Kind == yaml.ScalarNode {
+		return item
+	}
+	if name := mappingValue(item, "name"); name != nil {
+		return name
+	}
+	return &yaml.Node{}
+}
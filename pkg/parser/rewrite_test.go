@@ -0,0 +1,261 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+const rewriteSpec = `# Order lifecycle
+machine:
+  name: Order
+  initial: pending # where every order starts
+
+states:
+  - name: pending
+  # manual review for risky orders
+  - name: review
+    description: Waiting for a human
+    ignore: [flag]
+  - name: "approved"
+  - {name: shipped, final: true}
+
+events: [approve, flag, {name: ship, weight: 2}]
+
+transitions:
+  - {from: pending, to: approved, on: approve}
+  - {from: pending, to: review, on: flag}
+  - from: review
+    to: approved
+    on: approve   # cleared by a human
+  - {from: approved, to: shipped, on: ship}
+
+migrations:
+  - {from: accepted_legacy, to: approved}
+
+properties:
+  - {name: no_review_after_approval, kind: never_followed_by, state: approved, then: review}
+  - {name: ships, never: [pending, ship]}
+`
+
+func TestRenameState(t *testing.T) {
+	out, n, err := RenameState([]byte(rewriteSpec), "approved", "accepted")
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, `# Order lifecycle
+machine:
+  name: Order
+  initial: pending # where every order starts
+
+states:
+  - name: pending
+  # manual review for risky orders
+  - name: review
+    description: Waiting for a human
+    ignore: [flag]
+  - name: "accepted"
+  - {name: shipped, final: true}
+
+events: [approve, flag, {name: ship, weight: 2}]
+
+transitions:
+  - {from: pending, to: accepted, on: approve}
+  - {from: pending, to: review, on: flag}
+  - from: review
+    to: accepted
+    on: approve   # cleared by a human
+  - {from: accepted, to: shipped, on: ship}
+
+migrations:
+  - {from: accepted_legacy, to: accepted}
+
+properties:
+  - {name: no_review_after_approval, kind: never_followed_by, state: accepted, then: review}
+  - {name: ships, never: [pending, ship]}
+`, string(out))
+
+	fsm, err := NewYAMLParser().Parse(bytes.NewReader(out))
+	require.NoError(t, err)
+	require.NoError(t, fsm.Validate())
+	assert.NotNil(t, fsm.GetState("accepted"))
+
+	out, n, err = RenameState([]byte(rewriteSpec), "pending", "created")
+	require.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Contains(t, string(out), "  initial: created # where every order starts\n")
+	assert.Contains(t, string(out), "  - {name: ships, never: [created, ship]}\n")
+
+	_, _, err = RenameState([]byte(rewriteSpec), "missing", "other")
+	assert.EqualError(t, err, `state "missing" is not defined`)
+	_, _, err = RenameState([]byte(rewriteSpec), "review", "shipped")
+	assert.EqualError(t, err, `state "shipped" is already defined`)
+	_, _, err = RenameState([]byte(rewriteSpec), "review", "in-review")
+	assert.ErrorContains(t, err, "contains invalid characters")
+}
+
+func TestRenameEvent(t *testing.T) {
+	out, n, err := RenameEvent([]byte(rewriteSpec), "flag", "escalate")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Contains(t, string(out), "    ignore: [escalate]\n")
+	assert.Contains(t, string(out), "events: [approve, escalate, {name: ship, weight: 2}]\n")
+	assert.Contains(t, string(out), "  - {from: pending, to: review, on: escalate}\n")
+
+	spelled := strings.Replace(rewriteSpec, "ignore: [flag]", "ignores: [flag]", 1)
+	out, n, err = RenameEvent([]byte(spelled), "flag", "escalate")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Contains(t, string(out), "    ignores: [escalate]\n")
+
+	out, n, err = RenameEvent([]byte(rewriteSpec), "ship", "dispatch")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Contains(t, string(out), "{name: dispatch, weight: 2}]\n")
+	assert.Contains(t, string(out), "never: [pending, dispatch]}\n")
+
+	_, _, err = RenameEvent([]byte(rewriteSpec), "approve", "flag")
+	assert.EqualError(t, err, `event "flag" is already defined`)
+}
+
+func TestRemoveState(t *testing.T) {
+	spec := `machine: {name: Order, initial: pending}
+states:
+  - name: pending
+  # manual review for risky orders
+  - name: review
+    description: Waiting for a human
+  - name: approved
+events: [approve, flag]
+transitions:
+  - {from: pending, to: approved, on: approve}
+  - {from: pending, to: review, on: flag}
+  - from: review
+    to: approved
+    on: approve   # cleared by a human
+# the end
+`
+	out, n, err := RemoveState([]byte(spec), "review")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, `machine: {name: Order, initial: pending}
+states:
+  - name: pending
+  # manual review for risky orders
+  - name: approved
+events: [approve, flag]
+transitions:
+  - {from: pending, to: approved, on: approve}
+# the end
+`, string(out))
+
+	_, _, err = RemoveState([]byte(rewriteSpec), "review")
+	assert.EqualError(t, err, `state "review" is still referenced by properties (line 29)`)
+	_, _, err = RemoveState([]byte(rewriteSpec), "pending")
+	assert.ErrorContains(t, err, `state "pending" is still referenced by machine (line 4)`)
+	_, _, err = RemoveState([]byte(rewriteSpec), "missing")
+	assert.EqualError(t, err, `state "missing" is not defined`)
+}
+
+func TestRemoveState_FlowSequences(t *testing.T) {
+	spec := `machine: {name: Order, initial: a}
+states: [{name: a}, {name: b}, {name: c}, {name: d}]
+events: [go]
+transitions: [{from: a, to: b, on: go}, {from: b, to: c, on: go}, {from: c, to: d, on: go}, {from: d, to: d, on: go}]
+`
+	out, n, err := RemoveState([]byte(spec), "d")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, `machine: {name: Order, initial: a}
+states: [{name: a}, {name: b}, {name: c}]
+events: [go]
+transitions: [{from: a, to: b, on: go}, {from: b, to: c, on: go}]
+`, string(out))
+
+	out, n, err = RemoveState([]byte(spec), "b")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, `machine: {name: Order, initial: a}
+states: [{name: a}, {name: c}, {name: d}]
+events: [go]
+transitions: [{from: c, to: d, on: go}, {from: d, to: d, on: go}]
+`, string(out))
+}
+
+func TestAddState(t *testing.T) {
+	out, err := AddState([]byte(rewriteSpec), "cancelled")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "  - {name: shipped, final: true}\n  - {name: cancelled}\n\nevents:")
+
+	out, err = AddState([]byte("machine: {name: Order, initial: a}\nstates:\n  - name: a\n    final: true"), "b")
+	require.NoError(t, err)
+	assert.Equal(t, "machine: {name: Order, initial: a}\nstates:\n  - name: a\n    final: true\n  - name: b\n", string(out))
+
+	out, err = AddState([]byte("machine: {name: Order, initial: a}\nstates: []\n"), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "machine: {name: Order, initial: a}\nstates: [{name: a}]\n", string(out))
+
+	out, err = AddState([]byte("machine: {name: Order, initial: a}\n"), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "machine: {name: Order, initial: a}\n\nstates:\n  - name: a\n", string(out))
+
+	_, err = AddState([]byte(rewriteSpec), "review")
+	assert.EqualError(t, err, `state "review" is already defined`)
+	_, err = AddState([]byte(rewriteSpec), "on hold")
+	assert.ErrorContains(t, err, "contains invalid characters")
+	_, err = AddState([]byte("states: pending\n"), "a")
+	assert.EqualError(t, err, "line 1: states is not a sequence")
+}
+
+func TestAddEvent(t *testing.T) {
+	out, err := AddEvent([]byte(rewriteSpec), "cancel")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "events: [approve, flag, {name: ship, weight: 2}, cancel]\n")
+
+	out, err = AddEvent([]byte("events:\n  - approve # first\n"), "ship")
+	require.NoError(t, err)
+	assert.Equal(t, "events:\n  - approve # first\n  - ship\n", string(out))
+
+	_, err = AddEvent([]byte(rewriteSpec), "ship")
+	assert.EqualError(t, err, `event "ship" is already defined`)
+}
+
+func TestAddTransition(t *testing.T) {
+	transition, err := model.NewTransition("review", "shipped", "ship")
+	require.NoError(t, err)
+	require.NoError(t, transition.WithGuard("paid"))
+	out, err := AddTransition([]byte(rewriteSpec), transition)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "  - {from: approved, to: shipped, on: ship}\n  - {from: review, to: shipped, on: ship, guard: paid}\n\nmigrations:")
+
+	fsm, err := NewYAMLParser().Parse(bytes.NewReader(out))
+	require.NoError(t, err)
+	require.NoError(t, fsm.Validate())
+	assert.True(t, fsm.HasTransition("review", "ship"))
+
+	out, err = AddTransition([]byte("transitions:\n  - from: a\n    to: b\n    on: go\n"), &model.Transition{From: "b", To: "a", Event: "back", Action: "undo"})
+	require.NoError(t, err)
+	assert.Equal(t, "transitions:\n  - from: a\n    to: b\n    on: go\n  - from: b\n    to: a\n    on: back\n    action: undo\n", string(out))
+
+	_, err = AddTransition([]byte(rewriteSpec), &model.Transition{From: "review", To: "shipped"})
+	assert.EqualError(t, err, "event cannot be empty")
+	_, err = AddTransition([]byte(rewriteSpec), &model.Transition{From: "review", To: "shipped", Event: "ship", Guard: "a: b"})
+	assert.ErrorContains(t, err, `guard "a: b" contains invalid characters`)
+}
+
+func TestRemoveTransition(t *testing.T) {
+	out, err := RemoveTransition([]byte(rewriteSpec), 2)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "  - {from: pending, to: review, on: flag}\n  - {from: approved, to: shipped, on: ship}\n")
+
+	out, err = RemoveTransition([]byte("transitions: [{from: a, to: b, on: go}, {from: b, to: a, on: back}]\n"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "transitions: [{from: b, to: a, on: back}]\n", string(out))
+
+	_, err = RemoveTransition([]byte(rewriteSpec), 4)
+	assert.EqualError(t, err, "transition 4 is not defined")
+}
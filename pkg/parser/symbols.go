@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SymbolKind is what a name in a spec refers to
+type SymbolKind string
+
+const (
+	// SymbolState is the name of a state
+	SymbolState SymbolKind = "state"
+
+	// SymbolEvent is the name of an event
+	SymbolEvent SymbolKind = "event"
+)
+
+// Symbol is a scalar of a spec that names a state or event, for editor tooling
+type Symbol struct {
+	// Kind is whether the scalar names a state or an event
+	Kind SymbolKind
+
+	// Name is the state or event name
+	Name string
+
+	// Section is the top-level key the scalar is found under
+	Section string
+
+	// Line and Column are the 1-based position of the name, inside any quotes;
+	// columns count characters
+	Line, Column int
+
+	// Declaration reports whether the scalar declares the name in the states or
+	// events section rather than referring to it
+	Declaration bool
+}
+
+// End returns the column just past the name
+func (s Symbol) End() int {
+	return s.Column + utf8.RuneCountInString(s.Name)
+}
+
+// Symbols returns the scalars of the spec src that name states and events, in
+// source order. Scalars that may name either, such as the eventually step of a
+// property, are returned once for each kind.
+func Symbols(src []byte) ([]Symbol, error) {
+	s, err := newSpecSource(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var symbols []Symbol
+	add := func(kind SymbolKind, declaring string, refs []specRef) {
+		for _, ref := range refs {
+			column := ref.node.Column
+			if ref.node.Style == yaml.DoubleQuotedStyle || ref.node.Style == yaml.SingleQuotedStyle {
+				column++
+			}
+			symbols = append(symbols, Symbol{
+				Kind:        kind,
+				Name:        ref.node.Value,
+				Section:     ref.section,
+				Line:        ref.node.Line,
+				Column:      column,
+				Declaration: ref.section == declaring,
+			})
+		}
+	}
+	add(SymbolState, "states", s.stateScalars())
+	add(SymbolEvent, "events", s.eventScalars())
+
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].Line != symbols[j].Line {
+			return symbols[i].Line < symbols[j].Line
+		}
+		return symbols[i].Column < symbols[j].Column
+	})
+	return symbols, nil
+}
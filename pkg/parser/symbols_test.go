@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymbols(t *testing.T) {
+	symbols, err := Symbols([]byte(`machine:
+  name: Order
+  initial: pending
+states:
+  - name: pending
+    ignore: [flag]
+  - name: "approved"
+events: [approve, flag]
+transitions:
+  - {from: pending, to: approved, on: approve}
+properties:
+  - {name: approves, kind: leads_to, state: pending, eventually: approved}
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []Symbol{
+		{Kind: SymbolState, Name: "pending", Section: "machine", Line: 3, Column: 12},
+		{Kind: SymbolState, Name: "pending", Section: "states", Line: 5, Column: 11, Declaration: true},
+		{Kind: SymbolEvent, Name: "flag", Section: "states", Line: 6, Column: 14},
+		{Kind: SymbolState, Name: "approved", Section: "states", Line: 7, Column: 12, Declaration: true},
+		{Kind: SymbolEvent, Name: "approve", Section: "events", Line: 8, Column: 10, Declaration: true},
+		{Kind: SymbolEvent, Name: "flag", Section: "events", Line: 8, Column: 19, Declaration: true},
+		{Kind: SymbolState, Name: "pending", Section: "transitions", Line: 10, Column: 12},
+		{Kind: SymbolState, Name: "approved", Section: "transitions", Line: 10, Column: 25},
+		{Kind: SymbolEvent, Name: "approve", Section: "transitions", Line: 10, Column: 39},
+		{Kind: SymbolState, Name: "pending", Section: "properties", Line: 12, Column: 45},
+		{Kind: SymbolState, Name: "approved", Section: "properties", Line: 12, Column: 66},
+		{Kind: SymbolEvent, Name: "approved", Section: "properties", Line: 12, Column: 66},
+	}, symbols)
+	assert.Equal(t, 20, symbols[3].End())
+
+	_, err = Symbols([]byte("- not a mapping\n"))
+	assert.EqualError(t, err, "spec is not a YAML mapping")
+}
@@ -0,0 +1,484 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// YAMLParser parses YAML state machine definitions into FSM models
+type YAMLParser struct{}
+
+// NewYAMLParser creates a new YAML parser
+func NewYAMLParser() *YAMLParser {
+	return &YAMLParser{}
+}
+
+// YAMLDefinition is the document structure of a YAML state machine definition
+type YAMLDefinition struct {
+	Machine      MachineDefinition       `yaml:"machine"`
+	States       []StateDefinition       `yaml:"states"`
+	Events       []EventDefinition       `yaml:"events"`
+	Transitions  []TransitionDefinition  `yaml:"transitions"`
+	Context      []ContextDefinition     `yaml:"context"`
+	Guards       []GuardDefinition       `yaml:"guards"`
+	Options      OptionsDefinition       `yaml:"options"`
+	Properties   []PropertyDefinition    `yaml:"properties"`
+	Migrations   []MigrationDefinition   `yaml:"migrations"`
+	Imports      []string                `yaml:"imports"`
+	DomainEvents []DomainEventDefinition `yaml:"domain_events"`
+	Binding      *BindingDefinition      `yaml:"binding"`
+	Header       HeaderDefinition        `yaml:"header"`
+}
+
+// HeaderDefinition is the header section of a YAML definition
+type HeaderDefinition struct {
+	Copyright string `yaml:"copyright,omitempty"`
+	BuildTags string `yaml:"build_tags,omitempty"`
+	Stamp     bool   `yaml:"stamp,omitempty"`
+}
+
+// OptionsDefinition is the options section of a YAML definition
+type OptionsDefinition struct {
+	Chaos            bool             `yaml:"chaos,omitempty"`
+	Coverage         bool             `yaml:"coverage,omitempty"`
+	Trace            bool             `yaml:"trace,omitempty"`
+	Publisher        bool             `yaml:"publisher,omitempty"`
+	UnknownState     string           `yaml:"unknown_state,omitempty"`
+	QuarantineState  string           `yaml:"quarantine_state,omitempty"`
+	ZeroState        string           `yaml:"zero_state,omitempty"`
+	StableValues     bool             `yaml:"stable_values,omitempty"`
+	SideBySide       bool             `yaml:"side_by_side,omitempty"`
+	Callbacks        bool             `yaml:"callbacks,omitempty"`
+	UserRegions      bool             `yaml:"user_regions,omitempty"`
+	ImmutableContext bool             `yaml:"immutable_context,omitempty"`
+	UnhandledEvent   string           `yaml:"unhandled_event,omitempty"`
+	IdempotencyKeys  int              `yaml:"idempotency_keys,omitempty"`
+	RecoverPanics    bool             `yaml:"recover_panics,omitempty"`
+	Reentrancy       string           `yaml:"reentrancy,omitempty"`
+	Naming           NamingDefinition `yaml:"naming,omitempty"`
+
+	// Removed options, decoded only to name their replacements
+	Validation      any `yaml:"validation,omitempty"`
+	Logging         any `yaml:"logging,omitempty"`
+	Metrics         any `yaml:"metrics,omitempty"`
+	ZeroAllocation  any `yaml:"zero_allocation,omitempty"`
+	ConcurrencySafe any `yaml:"concurrency_safe,omitempty"`
+}
+
+// NamingDefinition is the naming section of the options. MachineName is a
+// pointer because constants include the machine name unless it is false.
+type NamingDefinition struct {
+	Constants   string   `yaml:"constants,omitempty"`
+	MachineName *bool    `yaml:"machine_name,omitempty"`
+	Acronyms    []string `yaml:"acronyms,omitempty"`
+	Initialisms bool     `yaml:"initialisms,omitempty"`
+}
+
+// MachineDefinition is the machine section of a YAML definition
+type MachineDefinition struct {
+	Name        string `yaml:"name"`
+	Initial     string `yaml:"initial"`
+	Package     string `yaml:"package,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	Version     int    `yaml:"version,omitempty"`
+
+	BeforeTransition string `yaml:"before_transition,omitempty"`
+	AfterTransition  string `yaml:"after_transition,omitempty"`
+
+	// Context is a removed key, decoded only to name its replacement
+	Context any `yaml:"context,omitempty"`
+}
+
+// StateDefinition is a single entry of the states section
+type StateDefinition struct {
+	Name        string                `yaml:"name"`
+	Entry       string                `yaml:"entry,omitempty"`
+	Exit        string                `yaml:"exit,omitempty"`
+	Description string                `yaml:"description,omitempty"`
+	Final       bool                  `yaml:"final,omitempty"`
+	Value       *int                  `yaml:"value,omitempty"`
+	Tags        []string              `yaml:"tags,omitempty"`
+	Metadata    map[string]any        `yaml:"metadata,omitempty"`
+	Ignore      []string              `yaml:"ignore,omitempty"`
+	Ignores     []string              `yaml:"ignores,omitempty"`
+	Deprecated  DeprecationDefinition `yaml:"deprecated,omitempty"`
+	Requires    []string              `yaml:"requires,omitempty"`
+	Unhandled   string                `yaml:"unhandled_event,omitempty"`
+	MaxDuration DurationDefinition    `yaml:"max_duration,omitempty"`
+}
+
+// EventDefinition is a single entry of the events section.
+// Events may be written either as a plain name or as a mapping.
+type EventDefinition struct {
+	Name        string                `yaml:"name"`
+	Description string                `yaml:"description,omitempty"`
+	Weight      int                   `yaml:"weight,omitempty"`
+	Value       *int                  `yaml:"value,omitempty"`
+	Deprecated  DeprecationDefinition `yaml:"deprecated,omitempty"`
+	Payload     *PayloadDefinition    `yaml:"payload,omitempty"`
+}
+
+// PayloadDefinition is the payload field of an event
+type PayloadDefinition struct {
+	Type     string   `yaml:"type"`
+	Required []string `yaml:"required,omitempty"`
+}
+
+// UnmarshalYAML accepts both the simple (scalar) and extended (mapping) event syntax
+func (e *EventDefinition) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		e.Name = node.Value
+		return nil
+	}
+
+	type plain EventDefinition
+	return node.Decode((*plain)(e))
+}
+
+// DeprecationDefinition is the deprecated field of a state or event, written
+// either as a bool or as the reason for the deprecation, which implies true
+type DeprecationDefinition struct {
+	Deprecated bool
+	Reason     string
+}
+
+// UnmarshalYAML accepts both `deprecated: true` and `deprecated: <reason>`
+func (d *DeprecationDefinition) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: deprecated must be true, false, or a reason", node.Line)
+	}
+	if node.Tag == "!!bool" {
+		return node.Decode(&d.Deprecated)
+	}
+	d.Reason = strings.TrimSpace(node.Value)
+	d.Deprecated = d.Reason != ""
+	return nil
+}
+
+// DurationDefinition is a duration written like a Go duration, such as 48h or 1h30m
+type DurationDefinition time.Duration
+
+// UnmarshalYAML parses the duration with time.ParseDuration
+func (d *DurationDefinition) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: a duration must be a string such as 48h", node.Line)
+	}
+	duration, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q: a duration must be a string such as 48h", node.Line, node.Value)
+	}
+	*d = DurationDefinition(duration)
+	return nil
+}
+
+// TransitionDefinition is a single entry of the transitions section
+type TransitionDefinition struct {
+	From        string             `yaml:"from"`
+	To          string             `yaml:"to"`
+	On          string             `yaml:"on"`
+	Guard       string             `yaml:"guard,omitempty"`
+	Action      string             `yaml:"action,omitempty"`
+	Description string             `yaml:"description,omitempty"`
+	Inverse     string             `yaml:"inverse,omitempty"`
+	Compensate  string             `yaml:"compensate,omitempty"`
+	MaxDuration DurationDefinition `yaml:"max_duration,omitempty"`
+}
+
+// ContextDefinition is a single entry of the context section
+type ContextDefinition struct {
+	Name        string `yaml:"name"`
+	Type        string `yaml:"type"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// GuardDefinition is a single entry of the guards section
+type GuardDefinition struct {
+	Name        string `yaml:"name"`
+	When        string `yaml:"when,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	Pure        bool   `yaml:"pure,omitempty"`
+}
+
+// PropertyDefinition is a single entry of the properties section
+type PropertyDefinition struct {
+	Name        string `yaml:"name"`
+	Kind        string `yaml:"kind"`
+	State       string `yaml:"state,omitempty"`
+	Then        string `yaml:"then,omitempty"`
+	Within      int    `yaml:"within,omitempty"`
+	Description string `yaml:"description,omitempty"`
+
+	// Never and Eventually declare never and eventually properties; kind may be
+	// left out with them
+	Never      []string `yaml:"never,omitempty"`
+	Eventually string   `yaml:"eventually,omitempty"`
+}
+
+// MigrationDefinition is a single entry of the migrations section
+type MigrationDefinition struct {
+	From        string `yaml:"from"`
+	To          string `yaml:"to"`
+	Value       *int   `yaml:"value,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// DomainEventDefinition is a single entry of the domain_events section
+type DomainEventDefinition struct {
+	Type  string `yaml:"type"`
+	Event string `yaml:"event"`
+}
+
+// BindingDefinition is the binding section of a YAML definition
+type BindingDefinition struct {
+	ORM     string `yaml:"orm"`
+	Model   string `yaml:"model"`
+	Field   string `yaml:"field"`
+	Type    string `yaml:"type,omitempty"`
+	Storage string `yaml:"storage,omitempty"`
+	ID      string `yaml:"id,omitempty"`
+}
+
+// ParseFile parses the YAML definition stored at path and records the path
+// and checksum of the file as the model's source
+func (p *YAMLParser) ParseFile(path string) (*model.FSMModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spec file: %w", err)
+	}
+
+	fsm, err := p.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	sum := sha256.Sum256(data)
+	fsm.Source = model.Source{
+		Path:     filepath.ToSlash(path),
+		Checksum: hex.EncodeToString(sum[:]),
+	}
+	return fsm, nil
+}
+
+// Parse decodes a YAML definition from r and builds a validated FSM model. Keys
+// the definition does not know, such as a misspelled guard, are errors.
+func (p *YAMLParser) Parse(r io.Reader) (*model.FSMModel, error) {
+	var def YAMLDefinition
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&def); err != nil {
+		return nil, fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	if err := def.checkRemovedKeys(); err != nil {
+		return nil, err
+	}
+
+	return p.buildModel(&def)
+}
+
+// checkRemovedKeys returns an error naming the replacement of the first key def
+// uses that earlier versions documented but never acted on
+func (def *YAMLDefinition) checkRemovedKeys() error {
+	removed := []struct {
+		key         string
+		used        bool
+		replacement string
+	}{
+		{"machine.context", def.Machine.Context != nil, "the context struct is always named <Machine>Context; declare its fields in the top-level context section"},
+		{"options.validation", def.Options.Validation != nil, "pass WithValidationMode to the constructor of the machine"},
+		{"options.logging", def.Options.Logging != nil, "pass WithLogger to the constructor of the machine"},
+		{"options.metrics", def.Options.Metrics != nil, "observe transitions with options.publisher"},
+		{"options.zero_allocation", def.Options.ZeroAllocation != nil, "pass WithZeroAllocation to the constructor of the machine"},
+		{"options.concurrency_safe", def.Options.ConcurrencySafe != nil, "remove it, generated machines are always safe for concurrent use"},
+	}
+	for _, r := range removed {
+		if r.used {
+			return fmt.Errorf("%s is no longer supported: %s", r.key, r.replacement)
+		}
+	}
+	return nil
+}
+
+// buildModel converts a decoded definition into an FSM model
+func (p *YAMLParser) buildModel(def *YAMLDefinition) (*model.FSMModel, error) {
+	fsm, err := model.NewFSMModel(def.Machine.Name, def.Machine.Initial)
+	if err != nil {
+		return nil, fmt.Errorf("invalid machine section: %w", err)
+	}
+	fsm.Package = def.Machine.Package
+	fsm.Description = def.Machine.Description
+	fsm.Version = def.Machine.Version
+	fsm.BeforeTransition = def.Machine.BeforeTransition
+	fsm.AfterTransition = def.Machine.AfterTransition
+	fsm.Options.ChaosHelpers = def.Options.Chaos
+	fsm.Options.Coverage = def.Options.Coverage
+	fsm.Options.Trace = def.Options.Trace
+	fsm.Options.Publisher = def.Options.Publisher
+	fsm.Options.UnknownState = model.UnknownStatePolicy(def.Options.UnknownState)
+	fsm.Options.QuarantineState = def.Options.QuarantineState
+	fsm.Options.ZeroState = model.ZeroStatePolicy(def.Options.ZeroState)
+	fsm.Options.StableValues = def.Options.StableValues
+	fsm.Options.SideBySide = def.Options.SideBySide
+	fsm.Options.Callbacks = def.Options.Callbacks
+	fsm.Options.UserRegions = def.Options.UserRegions
+	fsm.Options.ImmutableContext = def.Options.ImmutableContext
+	fsm.Options.UnhandledEvent = model.UnhandledEventPolicy(def.Options.UnhandledEvent)
+	fsm.Options.IdempotencyKeys = def.Options.IdempotencyKeys
+	fsm.Options.RecoverPanics = def.Options.RecoverPanics
+	fsm.Options.Reentrancy = model.ReentrancyPolicy(def.Options.Reentrancy)
+	fsm.Options.Naming = model.Naming{
+		Constants:       model.ConstantStyle(def.Options.Naming.Constants),
+		OmitMachineName: def.Options.Naming.MachineName != nil && !*def.Options.Naming.MachineName,
+		Acronyms:        def.Options.Naming.Acronyms,
+		Initialisms:     def.Options.Naming.Initialisms,
+	}
+
+	for _, s := range def.States {
+		state, err := model.NewState(s.Name)
+		if err != nil {
+			return nil, err
+		}
+		state.EntryAction = s.Entry
+		state.ExitAction = s.Exit
+		state.Description = s.Description
+		state.Final = s.Final
+		state.Value = s.Value
+		state.Tags = s.Tags
+		state.IgnoredEvents = append(s.Ignore, s.Ignores...)
+		state.Deprecated = s.Deprecated.Deprecated
+		state.DeprecationReason = s.Deprecated.Reason
+		state.RequiredContext = s.Requires
+		state.UnhandledEvent = model.UnhandledEventPolicy(s.Unhandled)
+		state.MaxDuration = time.Duration(s.MaxDuration)
+		if len(s.Metadata) > 0 {
+			state.Metadata = make(map[string]string, len(s.Metadata))
+			for key, value := range s.Metadata {
+				state.Metadata[key] = fmt.Sprint(value)
+			}
+		}
+
+		if err := fsm.AddState(state); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range def.Events {
+		event, err := model.NewEvent(e.Name)
+		if err != nil {
+			return nil, err
+		}
+		event.Description = e.Description
+		event.Weight = e.Weight
+		event.Value = e.Value
+		event.Deprecated = e.Deprecated.Deprecated
+		event.DeprecationReason = e.Deprecated.Reason
+		if e.Payload != nil {
+			event.Payload = &model.EventPayload{Type: e.Payload.Type, Required: e.Payload.Required}
+		}
+
+		if err := fsm.AddEvent(event); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, t := range def.Transitions {
+		transition, err := model.NewTransition(t.From, t.To, t.On)
+		if err != nil {
+			return nil, fmt.Errorf("transition #%d: %w", i+1, err)
+		}
+		transition.Guard = t.Guard
+		transition.Action = t.Action
+		transition.Description = t.Description
+		transition.Inverse = t.Inverse
+		transition.Compensate = t.Compensate
+		transition.MaxDuration = time.Duration(t.MaxDuration)
+
+		if err := fsm.AddTransition(transition); err != nil {
+			return nil, fmt.Errorf("transition #%d: %w", i+1, err)
+		}
+	}
+
+	for _, cd := range def.Context {
+		field := &model.ContextField{Name: cd.Name, Type: model.FieldType(cd.Type), Description: cd.Description}
+		if err := fsm.AddContextField(field); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, gd := range def.Guards {
+		guard := &model.GuardCondition{Name: gd.Name, When: gd.When, Description: gd.Description, Pure: gd.Pure}
+		if err := fsm.AddGuardCondition(guard); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, pd := range def.Properties {
+		property := &model.Property{
+			Name:        pd.Name,
+			Kind:        model.PropertyKind(pd.Kind),
+			State:       pd.State,
+			Then:        pd.Then,
+			Within:      pd.Within,
+			Sequence:    pd.Never,
+			Target:      pd.Eventually,
+			Description: pd.Description,
+		}
+		if property.Kind == "" {
+			switch {
+			case pd.Never != nil:
+				property.Kind = model.PropertyNever
+			case pd.Eventually != "":
+				property.Kind = model.PropertyEventually
+			}
+		}
+		if err := fsm.AddProperty(property); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, md := range def.Migrations {
+		legacy := &model.LegacyState{Name: md.From, Value: md.Value, To: md.To, Description: md.Description}
+		if err := fsm.AddLegacyState(legacy); err != nil {
+			return nil, err
+		}
+	}
+
+	fsm.Header = model.Header{
+		Copyright: strings.TrimRight(def.Header.Copyright, "\n"),
+		BuildTags: def.Header.BuildTags,
+		Stamp:     def.Header.Stamp,
+	}
+
+	fsm.Imports = def.Imports
+	for _, dd := range def.DomainEvents {
+		if err := fsm.AddDomainEvent(&model.DomainEvent{Type: dd.Type, Event: dd.Event}); err != nil {
+			return nil, err
+		}
+	}
+	if b := def.Binding; b != nil {
+		fsm.Binding = &model.Binding{
+			ORM:     model.BindingORM(b.ORM),
+			Model:   b.Model,
+			Field:   b.Field,
+			Type:    b.Type,
+			Storage: model.BindingStorage(b.Storage),
+			ID:      b.ID,
+		}
+	}
+
+	if err := fsm.Validate(); err != nil {
+		return nil, err
+	}
+
+	return fsm, nil
+}
@@ -0,0 +1,984 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+const orderSpec = `
+machine:
+  name: OrderStateMachine
+  initial: pending
+  package: orders
+  description: Manages the lifecycle of customer orders
+
+states:
+  - name: pending
+    entry: logEntry
+    exit: logExit
+  - name: approved
+  - name: rejected
+  - name: shipped
+    description: Order has left the warehouse
+
+events:
+  - approve
+  - reject
+  - name: ship
+    description: Mark order as shipped
+
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+    guard: hasPayment
+    action: chargeCard
+  - from: pending
+    to: rejected
+    on: reject
+  - from: approved
+    to: shipped
+    on: ship
+    action: notifyShipping
+`
+
+func TestYAMLParser_ParseOrderStateMachine(t *testing.T) {
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(orderSpec))
+	require.NoError(t, err)
+
+	assert.Equal(t, "OrderStateMachine", fsm.Name)
+	assert.Equal(t, "pending", fsm.Initial)
+	assert.Equal(t, "orders", fsm.Package)
+	assert.Equal(t, "Manages the lifecycle of customer orders", fsm.Description)
+	assert.Len(t, fsm.States, 4)
+	assert.Len(t, fsm.Events, 3)
+	assert.Len(t, fsm.Transitions, 3)
+
+	pending := fsm.GetState("pending")
+	require.NotNil(t, pending)
+	assert.Equal(t, "logEntry", pending.EntryAction)
+	assert.Equal(t, "logExit", pending.ExitAction)
+	assert.Equal(t, "Order has left the warehouse", fsm.GetState("shipped").Description)
+
+	assert.Equal(t, "Mark order as shipped", fsm.GetEvent("ship").Description)
+
+	approve := fsm.GetTransitionsFrom("pending")[0]
+	assert.Equal(t, "approved", approve.To)
+	assert.Equal(t, "hasPayment", approve.Guard)
+	assert.Equal(t, "chargeCard", approve.Action)
+}
+
+func TestYAMLParser_ParseErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "malformed document",
+			yaml:    "machine: [unterminated",
+			wantErr: "failed to decode YAML",
+		},
+		{
+			name: "misspelled transition key",
+			yaml: `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+  - name: unlocked
+events:
+  - unlock
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+    gaurd: hasKey
+`,
+			wantErr: "field gaurd not found",
+		},
+		{
+			name: "machine key under options",
+			yaml: `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+events:
+  - unlock
+options:
+  before_transition: audit
+`,
+			wantErr: "field before_transition not found",
+		},
+		{
+			name: "removed machine context key",
+			yaml: `
+machine:
+  name: DoorLock
+  initial: locked
+  context: DoorContext
+states:
+  - name: locked
+events:
+  - unlock
+`,
+			wantErr: "machine.context is no longer supported: the context struct is always named <Machine>Context",
+		},
+		{
+			name: "removed option",
+			yaml: `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+events:
+  - unlock
+options:
+  logging: true
+`,
+			wantErr: "options.logging is no longer supported: pass WithLogger",
+		},
+		{
+			name: "missing machine name",
+			yaml: `
+machine:
+  initial: locked
+states:
+  - name: locked
+events:
+  - unlock
+`,
+			wantErr: "machine name cannot be empty",
+		},
+		{
+			name: "initial state not declared",
+			yaml: `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: unlocked
+events:
+  - unlock
+`,
+			wantErr: `initial state "locked" is not defined`,
+		},
+		{
+			name: "duplicate state",
+			yaml: `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+  - name: locked
+events:
+  - unlock
+`,
+			wantErr: `state "locked" already exists`,
+		},
+		{
+			name: "transition to undeclared state",
+			yaml: `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+events:
+  - unlock
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+`,
+			wantErr: `transition #1: to state "unlocked" is not defined`,
+		},
+		{
+			name: "invalid state name",
+			yaml: `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: half locked
+events:
+  - unlock
+`,
+			wantErr: "contains invalid characters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewYAMLParser().Parse(strings.NewReader(tt.yaml))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestYAMLParser_ParseChaosOptions(t *testing.T) {
+	spec := `
+machine:
+  name: Connection
+  initial: disconnected
+states:
+  - name: disconnected
+  - name: connected
+events:
+  - connect
+  - name: heartbeat
+    weight: 10
+transitions:
+  - from: disconnected
+    to: connected
+    on: connect
+  - from: connected
+    to: connected
+    on: heartbeat
+options:
+  chaos: true
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	assert.True(t, fsm.Options.ChaosHelpers)
+	assert.Equal(t, 10, fsm.GetEvent("heartbeat").ChaosWeight())
+	assert.Equal(t, 1, fsm.GetEvent("connect").ChaosWeight())
+	assert.False(t, fsm.Options.Coverage)
+}
+
+func TestYAMLParser_ParseCoverageAndTraceOptions(t *testing.T) {
+	spec := `
+machine:
+  name: Connection
+  initial: disconnected
+states:
+  - name: disconnected
+  - name: connected
+events:
+  - connect
+transitions:
+  - from: disconnected
+    to: connected
+    on: connect
+options:
+  coverage: true
+  trace: true
+  callbacks: true
+  user_regions: true
+  immutable_context: true
+  recover_panics: true
+  idempotency_keys: 100
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	assert.True(t, fsm.Options.Coverage)
+	assert.True(t, fsm.Options.Trace)
+	assert.True(t, fsm.Options.Callbacks)
+	assert.True(t, fsm.Options.UserRegions)
+	assert.True(t, fsm.Options.ImmutableContext)
+	assert.True(t, fsm.Options.RecoverPanics)
+	assert.Equal(t, 100, fsm.Options.IdempotencyKeys)
+}
+
+func TestYAMLParser_ParseUnknownStateOptions(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: shipped
+  - name: legacy
+events:
+  - ship
+transitions:
+  - from: pending
+    to: shipped
+    on: ship
+options:
+  unknown_state: quarantine
+  quarantine_state: legacy
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, model.UnknownStateQuarantine, fsm.Options.UnknownState)
+	assert.Equal(t, "legacy", fsm.Options.QuarantineState)
+
+	invalid := strings.Replace(spec, "quarantine_state: legacy", "quarantine_state: archived", 1)
+	_, err = NewYAMLParser().Parse(strings.NewReader(invalid))
+	assert.ErrorContains(t, err, `quarantine state "archived" is not defined`)
+}
+
+func TestYAMLParser_ParseZeroStateOption(t *testing.T) {
+	spec := `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+  - name: unlocked
+events:
+  - unlock
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+options:
+  zero_state: unspecified
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, model.ZeroStateUnspecified, fsm.Options.ZeroState)
+
+	invalid := strings.Replace(spec, "zero_state: unspecified", "zero_state: none", 1)
+	_, err = NewYAMLParser().Parse(strings.NewReader(invalid))
+	assert.ErrorContains(t, err, `zero state policy "none"`)
+}
+
+func TestYAMLParser_ParsePinnedValues(t *testing.T) {
+	spec := `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+  - name: unlocked
+    value: 3
+events:
+  - name: unlock
+    value: 2
+  - lock
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+options:
+  stable_values: true
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.True(t, fsm.Options.StableValues)
+	assert.Nil(t, fsm.GetState("locked").Value)
+	require.NotNil(t, fsm.GetState("unlocked").Value)
+	assert.Equal(t, 3, *fsm.GetState("unlocked").Value)
+	assert.Equal(t, 2, fsm.EventValue("unlock"))
+	assert.Equal(t, 0, fsm.EventValue("lock"))
+
+	invalid := strings.Replace(spec, "value: 3", "value: 0", 1)
+	_, err = NewYAMLParser().Parse(strings.NewReader(invalid))
+	assert.ErrorContains(t, err, `state "unlocked" cannot use value 0`)
+}
+
+func TestYAMLParser_ParseTransitionInterceptors(t *testing.T) {
+	spec := `
+machine:
+  name: DoorLock
+  initial: locked
+  before_transition: audit
+  after_transition: touchUpdatedAt
+states:
+  - name: locked
+  - name: unlocked
+events:
+  - unlock
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+    action: audit
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, "audit", fsm.BeforeTransition)
+	assert.Equal(t, "touchUpdatedAt", fsm.AfterTransition)
+	assert.Equal(t, []string{"audit", "touchUpdatedAt"}, fsm.GetActionNames(), "actions are listed once")
+}
+
+func TestYAMLParser_ParseVersion(t *testing.T) {
+	spec := `
+machine:
+  name: DoorLock
+  initial: locked
+  version: 2
+states:
+  - name: locked
+  - name: unlocked
+events:
+  - unlock
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+options:
+  side_by_side: true
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, 2, fsm.Version)
+	assert.True(t, fsm.Options.SideBySide)
+	assert.Equal(t, "DoorLock", fsm.Name, "the machine is renamed only for generation")
+
+	invalid := strings.Replace(spec, "version: 2", "version: -1", 1)
+	_, err = NewYAMLParser().Parse(strings.NewReader(invalid))
+	assert.ErrorContains(t, err, "machine version -1 cannot be negative")
+}
+
+func TestYAMLParser_ParseNaming(t *testing.T) {
+	spec := `
+machine:
+  name: DoorLock
+  initial: locked
+states:
+  - name: locked
+  - name: unlocked
+events:
+  - unlock
+transitions:
+  - from: locked
+    to: unlocked
+    on: unlock
+options:
+  naming:
+    constants: suffix
+    machine_name: false
+    acronyms: [ID, URL]
+    initialisms: true
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, model.Naming{Constants: model.ConstantSuffix, OmitMachineName: true, Acronyms: []string{"ID", "URL"}, Initialisms: true}, fsm.Options.Naming)
+
+	fsm, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "machine_name: false", "machine_name: true", 1)))
+	require.NoError(t, err)
+	assert.False(t, fsm.Options.Naming.OmitMachineName)
+
+	invalid := strings.Replace(spec, "constants: suffix", "constants: infix", 1)
+	_, err = NewYAMLParser().Parse(strings.NewReader(invalid))
+	assert.ErrorContains(t, err, `constant style "infix" is not one of "prefix", "suffix"`)
+}
+
+func TestYAMLParser_ParseTagsAndMetadata(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+    tags: [intake, customer_facing]
+    metadata:
+      group: intake
+      timeout: 300
+  - name: shipped
+events:
+  - ship
+transitions:
+  - from: pending
+    to: shipped
+    on: ship
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	pending := fsm.GetState("pending")
+	assert.Equal(t, []string{"intake", "customer_facing"}, pending.Tags)
+	assert.Equal(t, map[string]string{"group": "intake", "timeout": "300"}, pending.Metadata)
+	assert.Nil(t, fsm.GetState("shipped").Metadata)
+}
+
+func TestYAMLParser_ParseIgnoredEvents(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+    ignore: [cancel]
+  - name: shipped
+events:
+  - ship
+  - cancel
+transitions:
+  - from: pending
+    to: shipped
+    on: ship
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cancel"}, fsm.GetState("pending").IgnoredEvents)
+	assert.True(t, fsm.HasIgnoredEvents())
+	assert.True(t, fsm.GetState("pending").Ignores("cancel"))
+	assert.False(t, fsm.GetState("shipped").Ignores("cancel"))
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "ignore: [cancel]", "ignore: [ship]", 1)))
+	assert.ErrorContains(t, err, `state "pending" ignores event "ship" but has a transition on it to "shipped"`)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "ignore: [cancel]", "ignore: [refund]", 1)))
+	assert.ErrorContains(t, err, `state "pending" ignores undefined event "refund"`)
+
+	fsm, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "ignore: [cancel]", "ignores: [cancel]", 1)))
+	require.NoError(t, err)
+	assert.True(t, fsm.GetState("pending").Ignores("cancel"), "ignores is accepted as well")
+	assert.False(t, fsm.GetState("pending").Ignores("ship"))
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "ignore: [cancel]", "ignore: [cancel]\n    ignores: [cancel]", 1)))
+	assert.ErrorContains(t, err, `state "pending" ignores event "cancel" more than once`)
+}
+
+func TestYAMLParser_ParseRequiredContext(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: shipped
+    requires: [trackingNumber]
+events:
+  - ship
+context:
+  - name: trackingNumber
+    type: string
+transitions:
+  - from: pending
+    to: shipped
+    on: ship
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trackingNumber"}, fsm.GetState("shipped").RequiredContext)
+	assert.Empty(t, fsm.GetState("pending").RequiredContext)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "requires: [trackingNumber]", "requires: [carrier]", 1)))
+	assert.ErrorContains(t, err, `state "shipped" requires undefined context field "carrier"`)
+}
+
+func TestYAMLParser_ParseUndoEvents(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: approved
+events:
+  - approve
+  - unapprove
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+    inverse: unapprove
+    compensate: unapprove
+  - from: approved
+    to: pending
+    on: unapprove
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, "unapprove", fsm.Transitions[0].Inverse)
+	assert.Equal(t, "unapprove", fsm.Transitions[0].Compensate)
+	assert.Empty(t, fsm.Transitions[1].Inverse)
+	assert.Empty(t, fsm.Transitions[1].Compensate)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "inverse: unapprove", "inverse: approve", 1)))
+	assert.ErrorContains(t, err, `has inverse "approve", but "approved" has no transition on it back to "pending"`)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "compensate: unapprove", "compensate: refund", 1)))
+	assert.ErrorContains(t, err, `has undefined compensation event "refund"`)
+}
+
+func TestYAMLParser_ParseMaxDuration(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: approved
+    max_duration: 48h
+events:
+  - approve
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+    max_duration: 1h30m
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, 48*time.Hour, fsm.GetState("approved").MaxDuration)
+	assert.Zero(t, fsm.GetState("pending").MaxDuration)
+	assert.Equal(t, 90*time.Minute, fsm.Transitions[0].MaxDuration)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "48h", "2d", 1)))
+	assert.ErrorContains(t, err, `line 8: invalid duration "2d": a duration must be a string such as 48h`)
+}
+
+func TestYAMLParser_ParseUnhandledEvent(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: shipped
+    unhandled_event: ignore
+events:
+  - ship
+transitions:
+  - from: pending
+    to: shipped
+    on: ship
+options:
+  unhandled_event: log
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, model.UnhandledEventLog, fsm.Options.UnhandledEvent)
+	assert.Equal(t, model.UnhandledEventIgnore, fsm.GetState("shipped").UnhandledEvent)
+	assert.Empty(t, fsm.GetState("pending").UnhandledEvent)
+}
+
+func TestYAMLParser_ParseDeprecation(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: held
+    deprecated: true
+  - name: shipped
+    deprecated: false
+events:
+  - ship
+  - name: hold
+    deprecated: Orders are no longer held
+transitions:
+  - from: pending
+    to: shipped
+    on: ship
+  - from: pending
+    to: held
+    on: hold
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.True(t, fsm.GetState("held").Deprecated)
+	assert.Empty(t, fsm.GetState("held").DeprecationReason)
+	assert.False(t, fsm.GetState("shipped").Deprecated)
+	assert.False(t, fsm.GetState("pending").Deprecated)
+	assert.True(t, fsm.GetEvent("hold").Deprecated)
+	assert.Equal(t, "Orders are no longer held", fsm.GetEvent("hold").DeprecationReason)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "deprecated: true", "deprecated: [true]", 1)))
+	assert.ErrorContains(t, err, "deprecated must be true, false, or a reason")
+}
+
+func TestYAMLParser_ParseProperties(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: shipped
+    final: true
+events:
+  - ship
+transitions:
+  - from: pending
+    to: shipped
+    on: ship
+properties:
+  - name: no_reopen_after_ship
+    kind: never_followed_by
+    state: shipped
+    then: pending
+    description: Shipped orders never return to pending
+  - name: terminates
+    kind: reaches_final
+    within: 10
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	assert.True(t, fsm.GetState("shipped").Final)
+	require.Len(t, fsm.Properties, 2)
+	assert.Equal(t, model.PropertyNeverFollowedBy, fsm.Properties[0].Kind)
+	assert.Equal(t, "pending", fsm.Properties[0].Then)
+	assert.Equal(t, "Shipped orders never return to pending", fsm.Properties[0].Description)
+	assert.Equal(t, 10, fsm.Properties[1].Within)
+
+	invalid := strings.Replace(spec, "    final: true\n", "", 1)
+	_, err = NewYAMLParser().Parse(strings.NewReader(invalid))
+	assert.ErrorContains(t, err, "requires at least one final state")
+}
+
+func TestYAMLParser_ParseGuardConditions(t *testing.T) {
+	spec := `
+machine:
+  name: Payment
+  initial: pending
+states:
+  - name: pending
+  - name: captured
+  - name: review
+events:
+  - capture
+context:
+  - name: amount
+    type: int
+    description: Amount in cents
+  - name: currency
+    type: string
+guards:
+  - name: isSmall
+    when: amount > 0 && amount <= 10000
+  - name: isLarge
+    when: amount > 10000 && currency == "EUR"
+    description: Large payments are reviewed
+    pure: true
+transitions:
+  - from: pending
+    to: captured
+    on: capture
+    guard: isSmall
+  - from: pending
+    to: review
+    on: capture
+    guard: isLarge
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	assert.Equal(t, []*model.ContextField{
+		{Name: "amount", Type: model.FieldInt, Description: "Amount in cents"},
+		{Name: "currency", Type: model.FieldString},
+	}, fsm.Context)
+	require.Len(t, fsm.Guards, 2)
+	assert.Equal(t, &model.GuardCondition{Name: "isLarge", When: `amount > 10000 && currency == "EUR"`, Description: "Large payments are reviewed", Pure: true}, fsm.Guards[1])
+	assert.True(t, fsm.HasPureGuards())
+	assert.True(t, fsm.IsPureGuard("isLarge"))
+	assert.False(t, fsm.IsPureGuard("isSmall"))
+	assert.False(t, fsm.IsPureGuard("undeclared"))
+	assert.Equal(t, `amount > 10000 && currency == "EUR"`, fsm.GuardPredicate("isLarge").String())
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "amount > 0", "total > 0", 1)))
+	assert.ErrorContains(t, err, `"total" is not a declared context field`)
+
+	callback := strings.Replace(spec, "    when: amount > 10000 && currency == \"EUR\"\n", "", 1)
+	fsm, err = NewYAMLParser().Parse(strings.NewReader(callback))
+	require.NoError(t, err, "a pure guard may leave the decision to its callback")
+	assert.True(t, fsm.IsPureGuard("isLarge"))
+	assert.Nil(t, fsm.GuardPredicate("isLarge"))
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(callback, "    pure: true\n", "", 1)))
+	assert.ErrorContains(t, err, `guard "isLarge": condition "": condition cannot be empty`)
+}
+
+func TestYAMLParser_ParseMigrations(t *testing.T) {
+	spec := `
+machine:
+  name: Order
+  initial: pending
+states:
+  - name: pending
+  - name: in_review
+events:
+  - flag
+transitions:
+  - {from: pending, to: in_review, on: flag}
+migrations:
+  - from: review
+    to: in_review
+    value: 5
+    description: Renamed in v2
+  - {from: escalated, to: in_review}
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	value := 5
+	assert.Equal(t, []*model.LegacyState{
+		{Name: "review", Value: &value, To: "in_review", Description: "Renamed in v2"},
+		{Name: "escalated", To: "in_review"},
+	}, fsm.LegacyStates)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "to: in_review}", "to: closed}", 1)))
+	assert.ErrorContains(t, err, `invalid migration: legacy state "escalated" migrates to "closed", which is not defined`)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(spec + "  - {from: review, to: pending}\n"))
+	assert.ErrorContains(t, err, `legacy state "review" already migrates to "in_review"`)
+}
+
+func TestYAMLParser_ParseSequenceProperties(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: shipped
+    final: true
+events:
+  - ship
+transitions:
+  - from: pending
+    to: shipped
+    on: ship
+properties:
+  - name: ships_once
+    never: [shipped, ship]
+  - name: ships
+    eventually: shipped
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	require.Len(t, fsm.Properties, 2)
+	assert.Equal(t, model.PropertyNever, fsm.Properties[0].Kind)
+	assert.Equal(t, []string{"shipped", "ship"}, fsm.Properties[0].Sequence)
+	assert.Equal(t, model.PropertyEventually, fsm.Properties[1].Kind)
+	assert.Equal(t, "shipped", fsm.Properties[1].Target)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "eventually: shipped", "eventually: delivered", 1)))
+	assert.ErrorContains(t, err, `property "ships": "delivered" is not a defined state or event`)
+}
+
+func TestYAMLParser_ParseDomainEvents(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: approved
+events:
+  - approve
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+imports:
+  - github.com/acme/shop/billing
+domain_events:
+  - type: billing.PaymentCaptured
+    event: approve
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github.com/acme/shop/billing"}, fsm.Imports)
+	require.Len(t, fsm.DomainEvents, 1)
+	assert.Equal(t, "billing.PaymentCaptured", fsm.DomainEvents[0].Type)
+	assert.Equal(t, "approve", fsm.DomainEvents[0].Event)
+
+	invalid := strings.Replace(spec, "  - github.com/acme/shop/billing\n", "  - github.com/acme/shop/payments\n", 1)
+	_, err = NewYAMLParser().Parse(strings.NewReader(invalid))
+	assert.ErrorContains(t, err, `uses package "billing", which is not listed in imports`)
+}
+
+func TestYAMLParser_ParseEventPayloads(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+states:
+  - name: pending
+  - name: approved
+events:
+  - name: approve
+    payload:
+      type: ApproveRequest
+      required: [ApproverID, Amount]
+  - reject
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, &model.EventPayload{Type: "ApproveRequest", Required: []string{"ApproverID", "Amount"}}, fsm.Events["approve"].Payload)
+	assert.Nil(t, fsm.Events["reject"].Payload)
+
+	invalid := strings.Replace(spec, "type: ApproveRequest", "type: billing.ApproveRequest", 1)
+	_, err = NewYAMLParser().Parse(strings.NewReader(invalid))
+	assert.ErrorContains(t, err, `uses package "billing", which is not listed in imports`)
+}
+
+func TestYAMLParser_ParseBinding(t *testing.T) {
+	spec := orderSpec + `
+imports:
+  - github.com/acme/shop/ent
+binding:
+  orm: ent
+  model: ent.Order
+  field: State
+  type: int
+  storage: value
+  id: int64
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	require.NotNil(t, fsm.Binding)
+	assert.Equal(t, model.Binding{ORM: model.BindingEnt, Model: "ent.Order", Field: "State", Type: "int", Storage: model.BindingStorageValue, ID: "int64"}, *fsm.Binding)
+
+	fsm, err = NewYAMLParser().Parse(strings.NewReader(orderSpec))
+	require.NoError(t, err)
+	assert.Nil(t, fsm.Binding)
+
+	_, err = NewYAMLParser().Parse(strings.NewReader(strings.Replace(spec, "orm: ent", "orm: sqlx", 1)))
+	assert.ErrorContains(t, err, `invalid binding: orm "sqlx" is not supported`)
+}
+
+func TestYAMLParser_ParseHeader(t *testing.T) {
+	spec := orderSpec + `
+header:
+  copyright: |
+    Copyright 2026 Acme Corp.
+    All rights reserved.
+  build_tags: "!fsm_stub"
+  stamp: true
+`
+	fsm, err := NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	assert.Equal(t, "Copyright 2026 Acme Corp.\nAll rights reserved.", fsm.Header.Copyright)
+	assert.Equal(t, "!fsm_stub", fsm.Header.BuildTags)
+	assert.True(t, fsm.Header.Stamp)
+	assert.Empty(t, fsm.Source.Path, "Parse without a file has no source")
+}
+
+func TestYAMLParser_ParseFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "order.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(orderSpec), 0o600))
+
+	fsm, err := NewYAMLParser().ParseFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "OrderStateMachine", fsm.Name)
+	assert.Equal(t, filepath.ToSlash(path), fsm.Source.Path)
+	assert.Len(t, fsm.Source.Checksum, 64, "Checksum should be a hex-encoded SHA-256")
+
+	again, err := NewYAMLParser().ParseFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, fsm.Source.Checksum, again.Source.Checksum, "Checksum must be deterministic")
+
+	_, err = NewYAMLParser().ParseFile(filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+// Package plugin implements the protocol of gofsm-gen plugins: programs that
+// gofsm-gen runs for every spec it generates with -plugin, which observe the
+// parsed model and can contribute extra output files, such as company-specific
+// audit code.
+//
+// gofsm-gen writes a Request as JSON to the standard input of the plugin and
+// reads a Response as JSON from its standard output; the standard error of the
+// plugin is passed through. A plugin fails by reporting an error in the response
+// or by exiting with a non-zero status. Plugins written in Go can use Serve:
+//
+//	func main() {
+//		if err := plugin.Serve(os.Stdin, os.Stdout, generate); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// ProtocolVersion is the version of the protocol, which changes only when
+// requests or responses change incompatibly
+const ProtocolVersion = 1
+
+// Request is what a plugin receives for a spec
+type Request struct {
+	ProtocolVersion int `json:"protocol_version"`
+
+	// GeneratorVersion is the version of the gofsm-gen running the plugin
+	GeneratorVersion string `json:"generator_version"`
+
+	// Spec is the path of the spec, and OutputDir the directory its generated
+	// files are written to, which the paths of contributed files are relative to
+	Spec      string `json:"spec"`
+	OutputDir string `json:"output_dir"`
+
+	// Model is the parsed model, with the package and options the flags and
+	// configuration resolved
+	Model *model.FSMModel `json:"model"`
+}
+
+// Response is what a plugin answers
+type Response struct {
+	// Files are the files the plugin contributes
+	Files []File `json:"files,omitempty"`
+
+	// Error fails generation with a message for the user
+	Error string `json:"error,omitempty"`
+}
+
+// File is a file a plugin contributes. Its path is slash-separated and relative
+// to the output directory of the request, which it cannot leave.
+type File struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Func generates the files of a plugin for a request
+type Func func(req *Request) ([]File, error)
+
+// Serve reads a request from in, calls generate, and writes the response to out.
+// Errors of generate are reported in the response; the returned error is for
+// requests that cannot be read or answered.
+func Serve(in io.Reader, out io.Writer, generate Func) error {
+	var req Request
+	if err := json.NewDecoder(in).Decode(&req); err != nil {
+		return fmt.Errorf("failed to decode request: %w", err)
+	}
+
+	var resp Response
+	if req.ProtocolVersion != ProtocolVersion {
+		resp.Error = fmt.Sprintf("protocol version %d is not supported (want %d)", req.ProtocolVersion, ProtocolVersion)
+	} else if files, err := generate(&req); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Files = files
+	}
+	if err := json.NewEncoder(out).Encode(&resp); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+// Exec runs the plugin program at path with req and returns the files it
+// contributes, passing its standard error to stderr. A path without a separator
+// is looked up in PATH.
+func Exec(path string, req *Request, stderr io.Writer) ([]File, error) {
+	if req.ProtocolVersion == 0 {
+		req.ProtocolVersion = ProtocolVersion
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: failed to encode request: %w", path, err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("plugin %s: failed to decode response: %w", path, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", path, resp.Error)
+	}
+	for _, f := range resp.Files {
+		if f.Path == "" || !filepath.IsLocal(filepath.FromSlash(f.Path)) {
+			return nil, fmt.Errorf("plugin %s: file %q is not a relative path inside the output directory", path, f.Path)
+		}
+	}
+	return resp.Files, nil
+}
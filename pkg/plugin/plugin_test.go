@@ -0,0 +1,135 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// helperEnv makes the test binary act as the plugin the value names, so that
+// Exec has a program to run
+const helperEnv = "GOFSMGEN_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(helperEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "exit":
+		fmt.Fprintln(os.Stderr, "audit: out of coffee")
+		os.Exit(3)
+	case "garbage":
+		fmt.Println("not json")
+	case "escape":
+		serve(func(*Request) ([]File, error) {
+			return []File{{Path: "../outside.go", Content: "package outside\n"}}, nil
+		})
+	default:
+		serve(audit)
+	}
+	os.Exit(0)
+}
+
+// serve runs generate as the plugin of the process
+func serve(generate Func) {
+	if err := Serve(os.Stdin, os.Stdout, generate); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// audit lists the states of the machine, and fails for a machine without any
+func audit(req *Request) ([]File, error) {
+	if len(req.Model.States) == 0 {
+		return nil, errors.New("nothing to audit")
+	}
+	return []File{{
+		Path:    "audit/" + strings.ToLower(req.Model.Name) + ".txt",
+		Content: fmt.Sprintf("%s %s in %s: %s\n", req.GeneratorVersion, req.Model.Name, req.OutputDir, strings.Join(req.Model.GetStateNames(), ",")),
+	}}, nil
+}
+
+// newRequest returns a request for a door machine
+func newRequest(t *testing.T) *Request {
+	t.Helper()
+	fsm, err := model.NewFSMModel("Door", "closed")
+	require.NoError(t, err)
+	require.NoError(t, fsm.AddState(&model.State{Name: "closed"}))
+	require.NoError(t, fsm.AddState(&model.State{Name: "open"}))
+	require.NoError(t, fsm.AddEvent(&model.Event{Name: "open"}))
+	require.NoError(t, fsm.AddTransition(&model.Transition{From: "closed", To: "open", Event: "open"}))
+	return &Request{GeneratorVersion: "v1.2.3", Spec: "door.yaml", OutputDir: "doors", Model: fsm}
+}
+
+func TestServe(t *testing.T) {
+	req := newRequest(t)
+	req.ProtocolVersion = ProtocolVersion
+	respond := func(req *Request) Response {
+		t.Helper()
+		data, err := json.Marshal(req)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		require.NoError(t, Serve(bytes.NewReader(data), &out, audit))
+		var resp Response
+		require.NoError(t, json.Unmarshal(out.Bytes(), &resp))
+		return resp
+	}
+
+	resp := respond(req)
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, []File{{Path: "audit/door.txt", Content: "v1.2.3 Door in doors: closed,open\n"}}, resp.Files,
+		"the model arrives as parsed")
+
+	req.Model.States = nil
+	assert.Equal(t, Response{Error: "nothing to audit"}, respond(req))
+
+	req.ProtocolVersion = ProtocolVersion + 1
+	assert.Contains(t, respond(req).Error, "protocol version 2 is not supported")
+
+	err := Serve(strings.NewReader("{"), &bytes.Buffer{}, audit)
+	assert.ErrorContains(t, err, "failed to decode request")
+}
+
+func TestExec(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	run := func(t *testing.T, mode string, req *Request) ([]File, string, error) {
+		t.Helper()
+		t.Setenv(helperEnv, mode)
+		var stderr bytes.Buffer
+		files, err := Exec(exe, req, &stderr)
+		return files, stderr.String(), err
+	}
+
+	t.Run("files", func(t *testing.T) {
+		files, _, err := run(t, "audit", newRequest(t))
+		require.NoError(t, err)
+		assert.Equal(t, []File{{Path: "audit/door.txt", Content: "v1.2.3 Door in doors: closed,open\n"}}, files)
+	})
+	t.Run("error", func(t *testing.T) {
+		req := newRequest(t)
+		req.Model.States = nil
+		_, _, err := run(t, "audit", req)
+		assert.EqualError(t, err, "plugin "+exe+": nothing to audit")
+	})
+	t.Run("exit", func(t *testing.T) {
+		_, stderr, err := run(t, "exit", newRequest(t))
+		assert.ErrorContains(t, err, "exit status 3")
+		assert.Equal(t, "audit: out of coffee\n", stderr, "the standard error of the plugin is passed through")
+	})
+	t.Run("garbage", func(t *testing.T) {
+		_, _, err := run(t, "garbage", newRequest(t))
+		assert.ErrorContains(t, err, "failed to decode response")
+	})
+	t.Run("escape", func(t *testing.T) {
+		_, _, err := run(t, "escape", newRequest(t))
+		assert.ErrorContains(t, err, `file "../outside.go" is not a relative path inside the output directory`)
+	})
+}
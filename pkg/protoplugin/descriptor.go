@@ -0,0 +1,284 @@
+package protoplugin
+
+// machineOption is the decoded gofsm.Machine option of an enum
+type machineOption struct {
+	name        string
+	initial     string
+	description string
+	transitions []transitionOption
+}
+
+// transitionOption is a decoded gofsm.Transition of a machine option
+type transitionOption struct {
+	from        string
+	to          string
+	on          string
+	guard       string
+	action      string
+	description string
+}
+
+// stateOption is the decoded gofsm.State option of an enum value
+type stateOption struct {
+	entry       string
+	exit        string
+	final       bool
+	description string
+}
+
+// decodeRequest decodes a CodeGeneratorRequest
+func decodeRequest(b []byte) (*request, error) {
+	fields, err := decodeFields(b)
+	if err != nil {
+		return nil, err
+	}
+	req := &request{}
+	for _, f := range fields {
+		switch {
+		case f.Num == 1 && f.Type == wireBytes:
+			req.filesToGenerate = append(req.filesToGenerate, f.String())
+		case f.Num == 2 && f.Type == wireBytes:
+			req.parameter = f.String()
+		case f.Num == 15 && f.Type == wireBytes:
+			file, err := decodeFile(f.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			req.protoFiles = append(req.protoFiles, file)
+		}
+	}
+	return req, nil
+}
+
+// decodeFile decodes a FileDescriptorProto
+func decodeFile(b []byte) (*protoFile, error) {
+	fields, err := decodeFields(b)
+	if err != nil {
+		return nil, err
+	}
+	file := &protoFile{}
+	for _, f := range fields {
+		if f.Type != wireBytes {
+			continue
+		}
+		switch f.Num {
+		case 1:
+			file.name = f.String()
+		case 4:
+			enums, err := decodeMessageEnums(f.Bytes, "")
+			if err != nil {
+				return nil, err
+			}
+			file.enums = append(file.enums, enums...)
+		case 5:
+			enum, err := decodeEnum(f.Bytes, "")
+			if err != nil {
+				return nil, err
+			}
+			file.enums = append(file.enums, enum)
+		case 8:
+			options, err := decodeFields(f.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range options {
+				if o.Num == 11 && o.Type == wireBytes {
+					file.goPackage = o.String()
+				}
+			}
+		}
+	}
+	return file, nil
+}
+
+// decodeMessageEnums decodes the enums declared in a DescriptorProto and the
+// messages nested in it. prefix is the prefix of the names of the enclosing
+// messages.
+func decodeMessageEnums(b []byte, prefix string) ([]*protoEnum, error) {
+	fields, err := decodeFields(b)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		if f.Num == 1 && f.Type == wireBytes {
+			prefix += f.String() + "_"
+		}
+	}
+
+	var enums []*protoEnum
+	for _, f := range fields {
+		if f.Type != wireBytes {
+			continue
+		}
+		switch f.Num {
+		case 3:
+			nested, err := decodeMessageEnums(f.Bytes, prefix)
+			if err != nil {
+				return nil, err
+			}
+			enums = append(enums, nested...)
+		case 4:
+			enum, err := decodeEnum(f.Bytes, prefix)
+			if err != nil {
+				return nil, err
+			}
+			enums = append(enums, enum)
+		}
+	}
+	return enums, nil
+}
+
+// decodeEnum decodes an EnumDescriptorProto
+func decodeEnum(b []byte, prefix string) (*protoEnum, error) {
+	fields, err := decodeFields(b)
+	if err != nil {
+		return nil, err
+	}
+	enum := &protoEnum{}
+	var options []byte
+	for _, f := range fields {
+		if f.Type != wireBytes {
+			continue
+		}
+		switch f.Num {
+		case 1:
+			enum.name = prefix + f.String()
+		case 2:
+			value, err := decodeEnumValue(f.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			enum.values = append(enum.values, value)
+		case 3:
+			options = append(options, f.Bytes...)
+		}
+	}
+	if enum.machine, err = extension(options, machineExtension); err != nil {
+		return nil, err
+	}
+	return enum, nil
+}
+
+// decodeEnumValue decodes an EnumValueDescriptorProto
+func decodeEnumValue(b []byte) (*protoEnumValue, error) {
+	fields, err := decodeFields(b)
+	if err != nil {
+		return nil, err
+	}
+	value := &protoEnumValue{}
+	var options []byte
+	for _, f := range fields {
+		switch {
+		case f.Num == 1 && f.Type == wireBytes:
+			value.name = f.String()
+		case f.Num == 2 && f.Type == wireVarint:
+			value.number = int32(f.Varint)
+		case f.Num == 3 && f.Type == wireBytes:
+			options = append(options, f.Bytes...)
+		}
+	}
+	if value.state, err = extension(options, stateExtension); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// extension returns the message extension field num of an options message, or
+// nil if it is not set. Occurrences of messages are concatenated, which merges
+// them as protobuf parsers do, so callers pass all occurrences of the options.
+func extension(options []byte, num int) ([]byte, error) {
+	fields, err := decodeFields(options)
+	if err != nil {
+		return nil, err
+	}
+	var ext []byte
+	for _, f := range fields {
+		if f.Num == num && f.Type == wireBytes {
+			if ext == nil {
+				ext = []byte{}
+			}
+			ext = append(ext, f.Bytes...)
+		}
+	}
+	return ext, nil
+}
+
+// decodeMachine decodes a gofsm.Machine option
+func decodeMachine(b []byte) (*machineOption, error) {
+	fields, err := decodeFields(b)
+	if err != nil {
+		return nil, err
+	}
+	m := &machineOption{}
+	for _, f := range fields {
+		if f.Type != wireBytes {
+			continue
+		}
+		switch f.Num {
+		case 1:
+			m.name = f.String()
+		case 2:
+			m.initial = f.String()
+		case 3:
+			m.description = f.String()
+		case 4:
+			t, err := decodeTransition(f.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			m.transitions = append(m.transitions, t)
+		}
+	}
+	return m, nil
+}
+
+// decodeTransition decodes a gofsm.Transition
+func decodeTransition(b []byte) (transitionOption, error) {
+	var t transitionOption
+	fields, err := decodeFields(b)
+	if err != nil {
+		return t, err
+	}
+	for _, f := range fields {
+		if f.Type != wireBytes {
+			continue
+		}
+		switch f.Num {
+		case 1:
+			t.from = f.String()
+		case 2:
+			t.to = f.String()
+		case 3:
+			t.on = f.String()
+		case 4:
+			t.guard = f.String()
+		case 5:
+			t.action = f.String()
+		case 6:
+			t.description = f.String()
+		}
+	}
+	return t, nil
+}
+
+// decodeState decodes a gofsm.State option
+func decodeState(b []byte) (stateOption, error) {
+	var s stateOption
+	fields, err := decodeFields(b)
+	if err != nil {
+		return s, err
+	}
+	for _, f := range fields {
+		switch {
+		case f.Num == 1 && f.Type == wireBytes:
+			s.entry = f.String()
+		case f.Num == 2 && f.Type == wireBytes:
+			s.exit = f.String()
+		case f.Num == 3 && f.Type == wireVarint:
+			s.final = f.Varint != 0
+		case f.Num == 4 && f.Type == wireBytes:
+			s.description = f.String()
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,359 @@
+// Package protoplugin implements protoc-gen-gofsm, a protoc plugin generating
+// state machines from enums annotated with the options of proto/gofsm/options.proto.
+//
+// The plugin speaks the protoc plugin protocol directly: it decodes the few
+// fields of the CodeGeneratorRequest it needs and encodes the response by hand,
+// so the generator does not depend on the protobuf runtime.
+package protoplugin
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// Field numbers of the gofsm extensions, as declared in proto/gofsm/options.proto
+const (
+	machineExtension = 51700
+	stateExtension   = 51701
+)
+
+// featureProto3Optional is the CodeGeneratorResponse feature flag for proto3
+// optional fields, which the plugin supports since it ignores fields
+const featureProto3Optional = 1
+
+// request is the part of a CodeGeneratorRequest the plugin reads
+type request struct {
+	filesToGenerate []string
+	parameter       string
+	protoFiles      []*protoFile
+}
+
+// protoFile is the part of a FileDescriptorProto the plugin reads
+type protoFile struct {
+	name      string
+	goPackage string
+	enums     []*protoEnum
+}
+
+// protoEnum is an enum declared in a file, with the gofsm machine option if set
+type protoEnum struct {
+	// name is the name of the enum prefixed by the names of the messages it is
+	// nested in, as protoc-gen-go names the Go type, e.g. "Order_Status"
+	name    string
+	values  []*protoEnumValue
+	machine []byte
+}
+
+// protoEnumValue is a value of an enum, with the gofsm state option if set
+type protoEnumValue struct {
+	name   string
+	number int32
+	state  []byte
+}
+
+// params are the plugin parameters passed with --gofsm_opt
+type params struct {
+	sourceRelative bool
+	options        model.Options
+}
+
+// Run reads a CodeGeneratorRequest from in and writes the CodeGeneratorResponse
+// to out. Errors in the proto files are reported to protoc in the response; the
+// returned error is for requests that cannot be read or answered.
+func Run(in io.Reader, out io.Writer) error {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	req, err := decodeRequest(data)
+	if err != nil {
+		return fmt.Errorf("failed to decode request: %w", err)
+	}
+
+	var resp []byte
+	files, err := generate(req)
+	if err != nil {
+		resp = appendBytesField(resp, 1, []byte(err.Error()))
+	}
+	resp = appendVarintField(resp, 2, featureProto3Optional)
+	for _, f := range files {
+		var file []byte
+		file = appendBytesField(file, 1, []byte(f.Path))
+		file = appendBytesField(file, 15, f.Content)
+		resp = appendBytesField(resp, 15, file)
+	}
+
+	if _, err := out.Write(resp); err != nil {
+		return fmt.Errorf("failed to write response: %w", err)
+	}
+	return nil
+}
+
+// generate generates a machine for every annotated enum of the files to generate
+func generate(req *request) ([]generator.OutputFile, error) {
+	p, err := parseParams(req.parameter)
+	if err != nil {
+		return nil, err
+	}
+	gen, err := generator.NewCodeGenerator()
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*protoFile, len(req.protoFiles))
+	for _, f := range req.protoFiles {
+		byName[f.name] = f
+	}
+
+	var files []generator.OutputFile
+	for _, name := range req.filesToGenerate {
+		f, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: file to generate is missing from the request", name)
+		}
+		for _, e := range f.enums {
+			if e.machine == nil {
+				continue
+			}
+			m, err := buildModel(f, e, p.options)
+			if err != nil {
+				return nil, fmt.Errorf("%s: enum %s: %w", f.name, e.name, err)
+			}
+			code, err := gen.Generate(m)
+			if err != nil {
+				return nil, fmt.Errorf("%s: enum %s: %w", f.name, e.name, err)
+			}
+			files = append(files, generator.OutputFile{
+				Path:    path.Join(outputDir(f, p), generator.DefaultOutputName(m)),
+				Content: code,
+			})
+		}
+	}
+	return files, nil
+}
+
+// parseParams parses the comma-separated plugin parameter
+func parseParams(parameter string) (params, error) {
+	var p params
+	for _, param := range strings.Split(parameter, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		switch key {
+		case "":
+		case "paths":
+			switch value {
+			case "import":
+				p.sourceRelative = false
+			case "source_relative":
+				p.sourceRelative = true
+			default:
+				return p, fmt.Errorf("paths=%s is not supported (use import or source_relative)", value)
+			}
+		case "chaos":
+			p.options.ChaosHelpers = true
+		case "coverage":
+			p.options.Coverage = true
+		case "trace":
+			p.options.Trace = true
+		case "publisher":
+			p.options.Publisher = true
+		default:
+			return p, fmt.Errorf("unknown parameter %q", param)
+		}
+	}
+	return p, nil
+}
+
+// outputDir returns the directory the machines of f are written to, relative to
+// the output directory given to protoc
+func outputDir(f *protoFile, p params) string {
+	if p.sourceRelative {
+		return path.Dir(f.name)
+	}
+	importPath, _, _ := strings.Cut(f.goPackage, ";")
+	return importPath
+}
+
+// goPackageName returns the Go package name of a go_package option: the name
+// after a semicolon, or else the last element of the import path
+func goPackageName(goPackage string) string {
+	importPath, name, ok := strings.Cut(goPackage, ";")
+	if !ok {
+		name = path.Base(importPath)
+	}
+	return name
+}
+
+// buildModel builds the machine declared by the options of e
+func buildModel(f *protoFile, e *protoEnum, options model.Options) (*model.FSMModel, error) {
+	def, err := decodeMachine(e.machine)
+	if err != nil {
+		return nil, fmt.Errorf("invalid machine option: %w", err)
+	}
+	if f.goPackage == "" {
+		return nil, fmt.Errorf("the file has no go_package option")
+	}
+
+	states, unspecified, err := stateNames(e)
+	if err != nil {
+		return nil, err
+	}
+	var zero string
+	for _, v := range e.values {
+		if v.number == 0 && states[v.name] != "" {
+			zero = v.name
+		}
+	}
+	switch {
+	case unspecified:
+		options.ZeroState = model.ZeroStateUnspecified
+	case zero == "":
+		options.ZeroState = model.ZeroStateInvalid
+	}
+
+	name := def.name
+	if name == "" {
+		name = strings.ReplaceAll(e.name, "_", "") + "Machine"
+	}
+	initial := def.initial
+	if initial == "" {
+		initial = zero
+	}
+	if initial == "" {
+		return nil, fmt.Errorf("the machine sets no initial state and no state is numbered 0")
+	}
+	initialState, err := lookupState(states, initial)
+	if err != nil {
+		return nil, fmt.Errorf("initial: %w", err)
+	}
+
+	m, err := model.NewFSMModel(name, initialState)
+	if err != nil {
+		return nil, err
+	}
+	m.Package = goPackageName(f.goPackage)
+	m.Description = def.description
+	m.Options = options
+
+	for _, v := range e.values {
+		if states[v.name] == "" {
+			continue
+		}
+		state, err := model.NewState(states[v.name])
+		if err != nil {
+			return nil, err
+		}
+		if v.state != nil {
+			opts, err := decodeState(v.state)
+			if err != nil {
+				return nil, fmt.Errorf("value %s: invalid state option: %w", v.name, err)
+			}
+			state.EntryAction = opts.entry
+			state.ExitAction = opts.exit
+			state.Final = opts.final
+			state.Description = opts.description
+		}
+		value := int(v.number)
+		state.Value = &value
+		if err := m.AddState(state); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, t := range def.transitions {
+		from, err := lookupState(states, t.from)
+		if err != nil {
+			return nil, fmt.Errorf("transition #%d: from: %w", i+1, err)
+		}
+		to, err := lookupState(states, t.to)
+		if err != nil {
+			return nil, fmt.Errorf("transition #%d: to: %w", i+1, err)
+		}
+		if t.on != "" && m.GetEvent(t.on) == nil {
+			event, err := model.NewEvent(t.on)
+			if err != nil {
+				return nil, fmt.Errorf("transition #%d: %w", i+1, err)
+			}
+			if err := m.AddEvent(event); err != nil {
+				return nil, err
+			}
+		}
+		transition, err := model.NewTransition(from, to, t.on)
+		if err != nil {
+			return nil, fmt.Errorf("transition #%d: %w", i+1, err)
+		}
+		transition.Guard = t.guard
+		transition.Action = t.action
+		transition.Description = t.description
+		if err := m.AddTransition(transition); err != nil {
+			return nil, fmt.Errorf("transition #%d: %w", i+1, err)
+		}
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// stateNames maps the values of e to the names of their states. Values are
+// named in lower snake case without the prefix naming the enum, if all values
+// share it, so ORDER_STATUS_PAYMENT_DUE of OrderStatus becomes payment_due. A
+// value numbered 0 and named UNSPECIFIED is the zero value sentinel rather than a
+// state; it is left out of the map and reported by the second result.
+func stateNames(e *protoEnum) (map[string]string, bool, error) {
+	prefix := upperSnake(e.name[strings.LastIndex(e.name, "_")+1:]) + "_"
+	for _, v := range e.values {
+		if !strings.HasPrefix(v.name, prefix) || v.name == prefix {
+			prefix = ""
+			break
+		}
+	}
+
+	names := make(map[string]string, len(e.values))
+	unspecified := false
+	for _, v := range e.values {
+		if v.number < 0 {
+			return nil, false, fmt.Errorf("value %s: negative numbers cannot be state values", v.name)
+		}
+		name := strings.ToLower(strings.TrimPrefix(v.name, prefix))
+		if v.number == 0 && name == "unspecified" {
+			unspecified = true
+			continue
+		}
+		names[v.name] = name
+	}
+	return names, unspecified, nil
+}
+
+// upperSnake converts a CamelCase name to UPPER_SNAKE_CASE, the case of the
+// enum value prefixes of the style guide
+func upperSnake(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(name[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// lookupState returns the state of a value given by its full name, such as
+// ORDER_STATUS_PENDING, or by the name of its state, such as pending
+func lookupState(states map[string]string, name string) (string, error) {
+	if state, ok := states[name]; ok && state != "" {
+		return state, nil
+	}
+	for _, state := range states {
+		if state == name {
+			return state, nil
+		}
+	}
+	return "", fmt.Errorf("%q is not a state of the enum", name)
+}
@@ -0,0 +1,232 @@
+package protoplugin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// message encodes a message from pairs of field numbers and values: strings and
+// byte slices as length-delimited fields and ints as varints
+func message(fields ...any) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 2 {
+		num := fields[i].(int)
+		switch v := fields[i+1].(type) {
+		case string:
+			b = appendBytesField(b, num, []byte(v))
+		case []byte:
+			b = appendBytesField(b, num, v)
+		case int:
+			b = appendVarintField(b, num, uint64(v))
+		}
+	}
+	return b
+}
+
+// enumValue encodes an EnumValueDescriptorProto with an optional state option
+func enumValue(name string, number int, state []byte) []byte {
+	b := message(1, name, 2, number)
+	if state != nil {
+		b = appendBytesField(b, 3, message(stateExtension, state))
+	}
+	return b
+}
+
+// orderStatus encodes the OrderStatus enum with its machine option
+func orderStatus() []byte {
+	machine := message(
+		3, "Order lifecycle",
+		4, message(1, "ORDER_STATUS_PENDING", 2, "ORDER_STATUS_APPROVED", 3, "approve", 4, "hasPayment", 5, "chargeCard"),
+		4, message(1, "pending", 2, "rejected", 3, "reject"),
+		4, message(1, "approved", 2, "ORDER_STATUS_SHIPPED", 3, "ship"),
+	)
+	return message(
+		1, "OrderStatus",
+		2, enumValue("ORDER_STATUS_UNSPECIFIED", 0, nil),
+		2, enumValue("ORDER_STATUS_PENDING", 1, message(1, "logEntry")),
+		2, enumValue("ORDER_STATUS_APPROVED", 2, nil),
+		2, enumValue("ORDER_STATUS_REJECTED", 3, message(3, 1)),
+		2, enumValue("ORDER_STATUS_SHIPPED", 4, message(3, 1, 4, "Handed to the carrier")),
+		3, message(machineExtension, message(2, "ORDER_STATUS_PENDING")),
+		3, message(machineExtension, machine),
+	)
+}
+
+// ordersFile encodes orders/v1/orders.proto declaring the given enums
+func ordersFile(enums ...[]byte) []byte {
+	b := message(1, "orders/v1/orders.proto", 2, "orders.v1")
+	for _, e := range enums {
+		b = appendBytesField(b, 5, e)
+	}
+	return appendBytesField(b, 8, message(11, "example.com/shop/gen/orders/v1;ordersv1"))
+}
+
+// generatedFile is a file of a decoded CodeGeneratorResponse
+type generatedFile struct {
+	name    string
+	content string
+}
+
+// run runs the plugin on a request and decodes the response
+func run(t *testing.T, req []byte) (string, []generatedFile) {
+	t.Helper()
+	var out bytes.Buffer
+	require.NoError(t, Run(bytes.NewReader(req), &out))
+
+	fields, err := decodeFields(out.Bytes())
+	require.NoError(t, err)
+	var errMsg string
+	var files []generatedFile
+	for _, f := range fields {
+		switch f.Num {
+		case 1:
+			errMsg = f.String()
+		case 2:
+			assert.Equal(t, uint64(featureProto3Optional), f.Varint)
+		case 15:
+			fileFields, err := decodeFields(f.Bytes)
+			require.NoError(t, err)
+			var file generatedFile
+			for _, ff := range fileFields {
+				switch ff.Num {
+				case 1:
+					file.name = ff.String()
+				case 15:
+					file.content = ff.String()
+				}
+			}
+			files = append(files, file)
+		}
+	}
+	return errMsg, files
+}
+
+func TestRun(t *testing.T) {
+	options := message(1, "gofsm/options.proto", 2, "gofsm", 5, message(1, "Machine"))
+	req := message(1, "orders/v1/orders.proto", 15, options, 15, ordersFile(orderStatus(), message(1, "Priority", 2, enumValue("PRIORITY_LOW", 0, nil))))
+
+	errMsg, files := run(t, req)
+	require.Empty(t, errMsg)
+	require.Len(t, files, 1)
+	assert.Equal(t, "example.com/shop/gen/orders/v1/order_status_machine_fsm.gen.go", files[0].name)
+
+	src := files[0].content
+	assert.Contains(t, src, "package ordersv1\n")
+	assert.Contains(t, src, "type OrderStatusMachine struct")
+	assert.Contains(t, src, "OrderStatusMachineStateUnspecified OrderStatusMachineState = 0")
+	assert.Contains(t, src, "OrderStatusMachineStatePending OrderStatusMachineState = 1")
+	assert.Contains(t, src, "OrderStatusMachineStateShipped OrderStatusMachineState = 4")
+	assert.Contains(t, src, "OrderStatusMachineEventApprove")
+	assert.Contains(t, src, "HasPayment")
+	assert.Contains(t, src, "LogEntry")
+}
+
+func TestRun_Parameters(t *testing.T) {
+	nested := message(
+		1, "Shipment",
+		4, message(
+			1, "Stage",
+			2, enumValue("PACKED", 0, nil),
+			2, enumValue("IN_TRANSIT", 1, nil),
+			2, enumValue("DELIVERED", 2, message(3, 1)),
+			3, message(machineExtension, message(
+				4, message(1, "packed", 2, "in_transit", 3, "dispatch"),
+				4, message(1, "IN_TRANSIT", 2, "DELIVERED", 3, "deliver"),
+			)),
+		),
+	)
+	file := message(1, "shipping/shipping.proto", 4, nested, 8, message(11, "example.com/shop/gen/shipping"))
+	req := message(1, "shipping/shipping.proto", 2, "paths=source_relative,trace", 15, file)
+
+	errMsg, files := run(t, req)
+	require.Empty(t, errMsg)
+	require.Len(t, files, 1)
+	assert.Equal(t, "shipping/shipment_stage_machine_fsm.gen.go", files[0].name)
+	assert.Contains(t, files[0].content, "package shipping\n")
+	assert.Contains(t, files[0].content, "ShipmentStageMachineStatePacked ShipmentStageMachineState = 0")
+	assert.Contains(t, files[0].content, "ShipmentStageMachineStateInTransit ShipmentStageMachineState = 1")
+	assert.Contains(t, files[0].content, "ShipmentStageMachineTrace")
+}
+
+func TestRun_Errors(t *testing.T) {
+	badState := message(1, "Stage", 2, enumValue("STAGE_PACKED", 0, nil),
+		3, message(machineExtension, message(4, message(1, "packed", 2, "lost", 3, "lose"))))
+	noGoPackage := message(1, "stage.proto", 5, badState)
+
+	tests := []struct {
+		name string
+		req  []byte
+		want string
+	}{
+		{
+			name: "unknown parameter",
+			req:  message(1, "orders/v1/orders.proto", 2, "paths=import,verbose", 15, ordersFile(orderStatus())),
+			want: `unknown parameter "verbose"`,
+		},
+		{
+			name: "unknown paths",
+			req:  message(1, "orders/v1/orders.proto", 2, "paths=flat", 15, ordersFile(orderStatus())),
+			want: "paths=flat is not supported",
+		},
+		{
+			name: "missing file",
+			req:  message(1, "orders/v1/orders.proto"),
+			want: "orders/v1/orders.proto: file to generate is missing from the request",
+		},
+		{
+			name: "no go_package",
+			req:  message(1, "stage.proto", 15, noGoPackage),
+			want: "stage.proto: enum Stage: the file has no go_package option",
+		},
+		{
+			name: "unknown state",
+			req:  message(1, "orders/v1/orders.proto", 15, ordersFile(badState)),
+			want: `enum Stage: transition #1: to: "lost" is not a state of the enum`,
+		},
+		{
+			name: "no initial state",
+			req: message(1, "orders/v1/orders.proto", 15, ordersFile(message(1, "Stage",
+				2, enumValue("STAGE_UNSPECIFIED", 0, nil), 2, enumValue("STAGE_PACKED", 1, nil),
+				3, message(machineExtension, message(4, message(1, "packed", 2, "packed", 3, "repack")))))),
+			want: "the machine sets no initial state and no state is numbered 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errMsg, files := run(t, tt.req)
+			assert.Contains(t, errMsg, tt.want)
+			assert.Empty(t, files)
+		})
+	}
+}
+
+func TestRun_MalformedRequest(t *testing.T) {
+	err := Run(bytes.NewReader([]byte{0x0a, 0x05, 'a'}), &bytes.Buffer{})
+	assert.ErrorIs(t, err, errTruncated)
+}
+
+func TestStateNames(t *testing.T) {
+	names, unspecified, err := stateNames(&protoEnum{name: "Order_PaymentStatus", values: []*protoEnumValue{
+		{name: "PAYMENT_STATUS_UNSPECIFIED", number: 0},
+		{name: "PAYMENT_STATUS_PAYMENT_DUE", number: 1},
+	}})
+	require.NoError(t, err)
+	assert.True(t, unspecified)
+	assert.Equal(t, map[string]string{"PAYMENT_STATUS_PAYMENT_DUE": "payment_due"}, names)
+
+	// Values not sharing the prefix keep their whole names
+	names, unspecified, err = stateNames(&protoEnum{name: "Stage", values: []*protoEnumValue{
+		{name: "STAGE_PACKED", number: 0},
+		{name: "DELIVERED", number: 1},
+	}})
+	require.NoError(t, err)
+	assert.False(t, unspecified)
+	assert.Equal(t, map[string]string{"STAGE_PACKED": "stage_packed", "DELIVERED": "delivered"}, names)
+
+	_, _, err = stateNames(&protoEnum{name: "Stage", values: []*protoEnumValue{{name: "STAGE_GONE", number: -1}}})
+	assert.ErrorContains(t, err, "value STAGE_GONE: negative numbers cannot be state values")
+}
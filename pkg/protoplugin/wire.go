@@ -0,0 +1,110 @@
+package protoplugin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Protocol buffer wire types used by the plugin messages
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errTruncated is returned for messages that end within a field
+var errTruncated = errors.New("truncated protocol buffer message")
+
+// field is a decoded field of a protocol buffer message. Varint holds the value
+// of varint fields and Bytes the contents of length-delimited ones.
+type field struct {
+	Num    int
+	Type   int
+	Varint uint64
+	Bytes  []byte
+}
+
+// String returns the contents of a length-delimited field as a string
+func (f field) String() string {
+	return string(f.Bytes)
+}
+
+// decodeFields splits a message into its fields in wire order. Fields of fixed
+// width are skipped, since no field the plugin reads has one.
+func decodeFields(b []byte) ([]field, error) {
+	var fields []field
+	for len(b) > 0 {
+		key, n := decodeVarint(b)
+		if n == 0 {
+			return nil, errTruncated
+		}
+		b = b[n:]
+		f := field{Num: int(key >> 3), Type: int(key & 7)}
+		switch f.Type {
+		case wireVarint:
+			f.Varint, n = decodeVarint(b)
+			if n == 0 {
+				return nil, errTruncated
+			}
+			b = b[n:]
+		case wireBytes:
+			size, n := decodeVarint(b)
+			if n == 0 || uint64(len(b)-n) < size {
+				return nil, errTruncated
+			}
+			f.Bytes = b[n : n+int(size)]
+			b = b[n+int(size):]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errTruncated
+			}
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errTruncated
+			}
+			b = b[4:]
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d of field %d", f.Type, f.Num)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// decodeVarint returns the varint at the start of b and its length, which is
+// zero when b holds no complete varint
+func decodeVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < len(b) && i < 10; i++ {
+		v |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// appendVarint appends v as a varint
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+// appendVarintField appends a varint field
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	b = appendVarint(b, uint64(num)<<3|wireVarint)
+	return appendVarint(b, v)
+}
+
+// appendBytesField appends a length-delimited field, such as a string or an
+// embedded message
+func appendBytesField(b []byte, num int, data []byte) []byte {
+	b = appendVarint(b, uint64(num)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(data)))
+	return append(b, data...)
+}
@@ -0,0 +1,49 @@
+package protoplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeFields(t *testing.T) {
+	var b []byte
+	b = appendVarintField(b, 2, 300)
+	b = appendBytesField(b, 15, []byte("hello"))
+	b = append(b, 3<<3|wireFixed32, 1, 2, 3, 4)
+	b = append(b, 4<<3|wireFixed64, 1, 2, 3, 4, 5, 6, 7, 8)
+	b = appendVarintField(b, 5, ^uint64(0))
+
+	fields, err := decodeFields(b)
+	require.NoError(t, err)
+	require.Len(t, fields, 5)
+	assert.Equal(t, field{Num: 2, Type: wireVarint, Varint: 300}, fields[0])
+	assert.Equal(t, "hello", fields[1].String())
+	assert.Equal(t, 15, fields[1].Num)
+	assert.Equal(t, 3, fields[2].Num)
+	assert.Equal(t, 4, fields[3].Num)
+	assert.Equal(t, int32(-1), int32(fields[4].Varint))
+}
+
+func TestDecodeFields_Malformed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated key", []byte{0x80}},
+		{"truncated varint", []byte{0x08, 0x80}},
+		{"truncated bytes", []byte{0x0a, 0x03, 'a'}},
+		{"truncated fixed32", []byte{0x0d, 1, 2}},
+		{"truncated fixed64", []byte{0x09, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeFields(tt.data)
+			assert.ErrorIs(t, err, errTruncated)
+		})
+	}
+
+	_, err := decodeFields([]byte{0x0b})
+	assert.ErrorContains(t, err, "unsupported wire type 3 of field 1")
+}
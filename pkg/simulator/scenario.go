@@ -0,0 +1,138 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// Scenario is a sequence of events with the states the machine must reach
+type Scenario struct {
+	// Name identifies the scenario in reports
+	Name string `yaml:"name"`
+
+	// Guards are the guard outcomes for the whole scenario; guards not listed pass
+	Guards map[string]bool `yaml:"guards,omitempty"`
+
+	// Steps are the events to fire, in order
+	Steps []ScenarioStep `yaml:"steps"`
+}
+
+// ScenarioStep fires one event and checks the outcome
+type ScenarioStep struct {
+	// Fire is the event to fire
+	Fire string `yaml:"fire"`
+
+	// Expect is the state the machine must be in afterwards; empty skips the check
+	Expect string `yaml:"expect,omitempty"`
+
+	// Rejected requires the event to be rejected, leaving the state unchanged
+	Rejected bool `yaml:"rejected,omitempty"`
+
+	// Guards override the scenario's guard outcomes for this step
+	Guards map[string]bool `yaml:"guards,omitempty"`
+}
+
+// scenarioFile is the document structure of a scenario file
+type scenarioFile struct {
+	Scenarios []Scenario `yaml:"scenarios"`
+}
+
+// ParseScenarios decodes a YAML scenario file
+func ParseScenarios(r io.Reader) ([]Scenario, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	var file scenarioFile
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode scenarios: %w", err)
+	}
+	if len(file.Scenarios) == 0 {
+		return nil, fmt.Errorf("no scenarios defined")
+	}
+
+	for i, scenario := range file.Scenarios {
+		if scenario.Name == "" {
+			return nil, fmt.Errorf("scenario %d: name cannot be empty", i+1)
+		}
+		for j, step := range scenario.Steps {
+			if step.Fire == "" {
+				return nil, fmt.Errorf("scenario %q: step %d: fire cannot be empty", scenario.Name, j+1)
+			}
+			if step.Rejected && step.Expect != "" {
+				return nil, fmt.Errorf("scenario %q: step %d: rejected cannot be combined with expect", scenario.Name, j+1)
+			}
+		}
+	}
+	return file.Scenarios, nil
+}
+
+// DivergenceError reports the first step at which a scenario and the model disagree
+type DivergenceError struct {
+	// Scenario is the name of the scenario
+	Scenario string
+
+	// Step is the 1-based index of the step
+	Step int
+
+	// Event is the event fired at the step
+	Event string
+
+	// Reason describes the divergence
+	Reason string
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("scenario %q: step %d (%s): %s", e.Scenario, e.Step, e.Event, e.Reason)
+}
+
+// Run plays the scenario against m from its initial state and returns a
+// *DivergenceError for the first step whose outcome differs from the scenario
+func (s Scenario) Run(m *model.FSMModel) error {
+	sim := New(m)
+	for i, step := range s.Steps {
+		diverged := func(format string, args ...any) error {
+			return &DivergenceError{Scenario: s.Name, Step: i + 1, Event: step.Fire, Reason: fmt.Sprintf(format, args...)}
+		}
+
+		if step.Expect != "" && m.GetState(step.Expect) == nil {
+			return diverged("expected state %q is not defined", step.Expect)
+		}
+
+		from := sim.State()
+		_, err := sim.Fire(step.Fire, s.guards(step))
+		var rejected *RejectedError
+		switch {
+		case errors.As(err, &rejected):
+			if !step.Rejected {
+				return diverged("%v", err)
+			}
+		case err != nil:
+			return diverged("%v", err)
+		case step.Rejected:
+			return diverged("expected the event to be rejected in %s, but the machine moved to %s", from, sim.State())
+		}
+
+		if step.Expect != "" && sim.State() != step.Expect {
+			return diverged("expected state %s, got %s", step.Expect, sim.State())
+		}
+	}
+	return nil
+}
+
+// guards returns the guard outcomes of a step
+func (s Scenario) guards(step ScenarioStep) GuardFunc {
+	return func(guard string) (bool, error) {
+		if pass, ok := step.Guards[guard]; ok {
+			return pass, nil
+		}
+		if pass, ok := s.Guards[guard]; ok {
+			return pass, nil
+		}
+		return true, nil
+	}
+}
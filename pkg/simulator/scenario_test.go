@@ -0,0 +1,113 @@
+package simulator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const orderScenarios = `
+scenarios:
+  - name: happy path
+    steps:
+      - fire: approve
+        expect: approved
+      - fire: ship
+        expect: shipped
+
+  - name: fraud is rejected
+    guards:
+      hasPayment: false
+    steps:
+      - fire: ship
+        rejected: true
+      - fire: approve
+        expect: rejected
+
+  - name: unpaid order ships
+    steps:
+      - fire: approve
+        guards:
+          hasPayment: false
+          isFraud: false
+        expect: approved
+`
+
+func TestScenario_Run(t *testing.T) {
+	scenarios, err := ParseScenarios(strings.NewReader(orderScenarios))
+	require.NoError(t, err)
+	require.Len(t, scenarios, 3)
+
+	fsm := newSimulator(t).model
+	assert.NoError(t, scenarios[0].Run(fsm))
+	assert.NoError(t, scenarios[1].Run(fsm))
+
+	err = scenarios[2].Run(fsm)
+	var divergence *DivergenceError
+	require.True(t, errors.As(err, &divergence))
+	assert.Equal(t, 1, divergence.Step)
+	assert.EqualError(t, err, `scenario "unpaid order ships": step 1 (approve): event "approve" is rejected in state "pending": guard "isFraud" failed`)
+}
+
+func TestScenario_RunDivergences(t *testing.T) {
+	fsm := newSimulator(t).model
+
+	tests := []struct {
+		name     string
+		steps    []ScenarioStep
+		expected string
+	}{
+		{
+			name:     "wrong state",
+			steps:    []ScenarioStep{{Fire: "reject", Expect: "approved"}},
+			expected: `step 1 (reject): expected state approved, got rejected`,
+		},
+		{
+			name:     "not rejected",
+			steps:    []ScenarioStep{{Fire: "approve"}, {Fire: "ship", Rejected: true}},
+			expected: `step 2 (ship): expected the event to be rejected in approved, but the machine moved to shipped`,
+		},
+		{
+			name:     "unknown state",
+			steps:    []ScenarioStep{{Fire: "approve", Expect: "aproved"}},
+			expected: `step 1 (approve): expected state "aproved" is not defined`,
+		},
+		{
+			name:     "unknown event",
+			steps:    []ScenarioStep{{Fire: "cancel"}},
+			expected: `step 1 (cancel): unknown event "cancel"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Scenario{Name: tt.name, Steps: tt.steps}.Run(fsm)
+			assert.EqualError(t, err, `scenario "`+tt.name+`": `+tt.expected)
+		})
+	}
+}
+
+func TestParseScenarios_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"empty", "scenarios: []\n", "no scenarios defined"},
+		{"missing name", "scenarios:\n  - steps: [{fire: approve}]\n", "scenario 1: name cannot be empty"},
+		{"missing event", "scenarios:\n  - name: a\n    steps: [{expect: approved}]\n", `scenario "a": step 1: fire cannot be empty`},
+		{"conflicting outcome", "scenarios:\n  - name: a\n    steps: [{fire: approve, expect: approved, rejected: true}]\n", `scenario "a": step 1: rejected cannot be combined with expect`},
+		{"unknown key", "scenarios:\n  - name: a\n    steps: [{fire: approve, expected: approved}]\n", "field expected not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseScenarios(strings.NewReader(tt.input))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.expected)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package simulator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// GuardFunc decides whether the named guard passes
+type GuardFunc func(guard string) (bool, error)
+
+// AssumeGuards is a GuardFunc under which every guard passes
+func AssumeGuards(string) (bool, error) { return true, nil }
+
+// Step is one transition taken by the simulator
+type Step struct {
+	// From is the state the machine left
+	From string
+
+	// Event is the event that was fired
+	Event string
+
+	// To is the state the machine entered
+	To string
+
+	// Guard is the guard that passed, if any
+	Guard string
+
+	// Action is the transition action that ran, if any
+	Action string
+
+	// ExitAction is the exit action of From that ran, if any
+	ExitAction string
+
+	// EntryAction is the entry action of To that ran, if any
+	EntryAction string
+}
+
+// RejectedError reports an event the current state does not accept
+type RejectedError struct {
+	// State is the state the event was fired in
+	State string
+
+	// Event is the rejected event
+	Event string
+
+	// FailedGuards lists the guards that failed, in declaration order; empty means
+	// the state has no transition on the event at all
+	FailedGuards []string
+}
+
+func (e *RejectedError) Error() string {
+	if len(e.FailedGuards) == 0 {
+		return fmt.Sprintf("event %q is not permitted in state %q", e.Event, e.State)
+	}
+	return fmt.Sprintf("event %q is rejected in state %q: guard %q failed", e.Event, e.State, e.FailedGuards[len(e.FailedGuards)-1])
+}
+
+// Simulator runs a state machine model without generating code. Transitions on
+// the same state and event are tried in declaration order, and the first one whose
+// guard passes, or that has no guard, is taken.
+type Simulator struct {
+	model *model.FSMModel
+	state string
+	trace []Step
+}
+
+// New creates a simulator in the initial state of m
+func New(m *model.FSMModel) *Simulator {
+	return &Simulator{model: m, state: m.Initial}
+}
+
+// State returns the current state
+func (s *Simulator) State() string {
+	return s.state
+}
+
+// IsFinal reports whether the current state is final
+func (s *Simulator) IsFinal() bool {
+	state := s.model.GetState(s.state)
+	return state != nil && state.Final
+}
+
+// Trace returns the transitions taken since the simulator was created or reset
+func (s *Simulator) Trace() []Step {
+	return append([]Step(nil), s.trace...)
+}
+
+// Reset returns the simulator to the initial state and clears the trace
+func (s *Simulator) Reset() {
+	s.state = s.model.Initial
+	s.trace = nil
+}
+
+// Permitted returns the events with a transition from the current state, sorted.
+// Their guards may still reject them.
+func (s *Simulator) Permitted() []string {
+	seen := make(map[string]bool)
+	var events []string
+	for _, t := range s.model.GetTransitionsFrom(s.state) {
+		if !seen[t.Event] {
+			seen[t.Event] = true
+			events = append(events, t.Event)
+		}
+	}
+	sort.Strings(events)
+	return events
+}
+
+// Candidates returns the transitions from the current state on event, in the
+// order they are tried
+func (s *Simulator) Candidates(event string) []*model.Transition {
+	var candidates []*model.Transition
+	for _, t := range s.model.GetTransitionsFrom(s.state) {
+		if t.Event == event {
+			candidates = append(candidates, t)
+		}
+	}
+	return candidates
+}
+
+// Fire takes the first transition on event from the current state whose guard
+// passes according to guards. It returns a *RejectedError if none does.
+func (s *Simulator) Fire(event string, guards GuardFunc) (Step, error) {
+	if s.model.GetEvent(event) == nil {
+		return Step{}, fmt.Errorf("unknown event %q", event)
+	}
+
+	rejected := &RejectedError{State: s.state, Event: event}
+	for _, t := range s.Candidates(event) {
+		if t.Guard != "" {
+			ok, err := guards(t.Guard)
+			if err != nil {
+				return Step{}, err
+			}
+			if !ok {
+				rejected.FailedGuards = append(rejected.FailedGuards, t.Guard)
+				continue
+			}
+		}
+
+		step := Step{From: t.From, Event: t.Event, To: t.To, Guard: t.Guard, Action: t.Action}
+		if from := s.model.GetState(t.From); from != nil {
+			step.ExitAction = from.ExitAction
+		}
+		if to := s.model.GetState(t.To); to != nil {
+			step.EntryAction = to.EntryAction
+		}
+		s.state = t.To
+		s.trace = append(s.trace, step)
+		return step, nil
+	}
+	return Step{}, rejected
+}
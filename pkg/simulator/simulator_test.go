@@ -0,0 +1,132 @@
+package simulator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/model"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+const orderSpec = `
+machine:
+  name: OrderStateMachine
+  initial: pending
+
+states:
+  - name: pending
+    exit: logExit
+  - name: approved
+  - name: rejected
+    final: true
+  - name: shipped
+    entry: notifyCustomer
+    final: true
+
+events:
+  - approve
+  - reject
+  - ship
+
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+    guard: hasPayment
+    action: chargeCard
+  - from: pending
+    to: rejected
+    on: approve
+    guard: isFraud
+  - from: pending
+    to: rejected
+    on: reject
+  - from: approved
+    to: shipped
+    on: ship
+`
+
+func newSimulator(t *testing.T) *Simulator {
+	t.Helper()
+	fsm, err := parser.NewYAMLParser().Parse(strings.NewReader(orderSpec))
+	require.NoError(t, err)
+	return New(fsm)
+}
+
+// answers returns a GuardFunc that passes the listed guards
+func answers(passing ...string) GuardFunc {
+	return func(guard string) (bool, error) {
+		for _, p := range passing {
+			if p == guard {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+func TestSimulator_Fire(t *testing.T) {
+	sim := newSimulator(t)
+	assert.Equal(t, "pending", sim.State())
+	assert.Equal(t, []string{"approve", "reject"}, sim.Permitted())
+
+	step, err := sim.Fire("approve", AssumeGuards)
+	require.NoError(t, err)
+	assert.Equal(t, Step{From: "pending", Event: "approve", To: "approved", Guard: "hasPayment", Action: "chargeCard", ExitAction: "logExit"}, step)
+
+	step, err = sim.Fire("ship", AssumeGuards)
+	require.NoError(t, err)
+	assert.Equal(t, "notifyCustomer", step.EntryAction)
+	assert.True(t, sim.IsFinal())
+	assert.Empty(t, sim.Permitted())
+
+	assert.Equal(t, []string{"approved", "shipped"}, []string{sim.Trace()[0].To, sim.Trace()[1].To})
+
+	sim.Reset()
+	assert.Equal(t, "pending", sim.State())
+	assert.Empty(t, sim.Trace())
+}
+
+func TestSimulator_FireTriesCandidatesInOrder(t *testing.T) {
+	sim := newSimulator(t)
+
+	step, err := sim.Fire("approve", answers("isFraud"))
+	require.NoError(t, err)
+	assert.Equal(t, "rejected", step.To)
+}
+
+func TestSimulator_FireRejected(t *testing.T) {
+	sim := newSimulator(t)
+
+	_, err := sim.Fire("approve", answers())
+	var rejected *RejectedError
+	require.True(t, errors.As(err, &rejected))
+	assert.Equal(t, []string{"hasPayment", "isFraud"}, rejected.FailedGuards)
+	assert.EqualError(t, err, `event "approve" is rejected in state "pending": guard "isFraud" failed`)
+
+	_, err = sim.Fire("ship", AssumeGuards)
+	assert.EqualError(t, err, `event "ship" is not permitted in state "pending"`)
+
+	_, err = sim.Fire("teleport", AssumeGuards)
+	assert.EqualError(t, err, `unknown event "teleport"`)
+
+	assert.Equal(t, "pending", sim.State())
+	assert.Empty(t, sim.Trace())
+}
+
+func TestSimulator_GuardError(t *testing.T) {
+	sim := newSimulator(t)
+
+	_, err := sim.Fire("approve", func(string) (bool, error) { return false, errors.New("no answer") })
+	assert.EqualError(t, err, "no answer")
+	assert.Equal(t, "pending", sim.State())
+}
+
+func TestNew_StartsInInitialState(t *testing.T) {
+	fsm, err := model.NewFSMModel("Door", "closed")
+	require.NoError(t, err)
+	assert.Equal(t, "closed", New(fsm).State())
+}
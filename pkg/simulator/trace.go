@@ -0,0 +1,140 @@
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/yourusername/gofsm-gen/pkg/model"
+)
+
+// Trace is a recording of the events handled by generated machines, as exported
+// by the TraceRecorder of a machine generated with the trace option
+type Trace struct {
+	// Machine is the name of the machine that recorded the trace
+	Machine string `json:"machine"`
+
+	// Entries are the handled events, oldest first
+	Entries []TraceEntry `json:"entries"`
+}
+
+// TraceEntry is one recorded event. From and To are equal when the event was
+// rejected; Error is set when the transition failed.
+type TraceEntry struct {
+	Time        time.Time `json:"time"`
+	Event       string    `json:"event"`
+	PayloadHash string    `json:"payload_hash,omitempty"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// ReadTrace decodes a JSON trace
+func ReadTrace(r io.Reader) (Trace, error) {
+	var t Trace
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return Trace{}, fmt.Errorf("failed to decode trace: %w", err)
+	}
+	if t.Machine == "" {
+		return Trace{}, fmt.Errorf("trace names no machine")
+	}
+	for i, e := range t.Entries {
+		if e.Event == "" || e.From == "" || e.To == "" {
+			return Trace{}, fmt.Errorf("entry %d: event, from, and to are required", i+1)
+		}
+	}
+	return t, nil
+}
+
+// Regression is a recorded event that the model would now handle differently
+type Regression struct {
+	// Entry is the 1-based index of the entry in the trace
+	Entry int
+
+	// Event is the recorded event
+	Event string
+
+	// Reason describes how the model differs from the recording
+	Reason string
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("entry %d (%s): %s", r.Entry, r.Event, r.Reason)
+}
+
+// Replay checks every entry of the trace against m, starting each from the state
+// it was recorded in, so that one regression does not hide the ones after it.
+// Guards are assumed to have decided as they did when the trace was recorded: a
+// recorded transition is a regression only when no transition of the model on the
+// event leads to the same state, and a rejected event only when the model has an
+// unguarded transition on it that could not have failed.
+func (t Trace) Replay(m *model.FSMModel) []Regression {
+	var regressions []Regression
+	for i, e := range t.Entries {
+		if reason := replayEntry(m, e); reason != "" {
+			regressions = append(regressions, Regression{Entry: i + 1, Event: e.Event, Reason: reason})
+		}
+	}
+	return regressions
+}
+
+// replayEntry returns why m disagrees with a recorded entry, or "" when it agrees
+func replayEntry(m *model.FSMModel, e TraceEntry) string {
+	switch {
+	case m.GetEvent(e.Event) == nil:
+		return fmt.Sprintf("event %q is no longer defined", e.Event)
+	case m.GetState(e.From) == nil:
+		return fmt.Sprintf("state %q is no longer defined", e.From)
+	case m.GetState(e.To) == nil:
+		return fmt.Sprintf("state %q is no longer defined", e.To)
+	}
+
+	// Transitions are tried in declaration order, so those after the first
+	// unguarded one are never taken
+	sim := &Simulator{model: m, state: e.From}
+	candidates := sim.Candidates(e.Event)
+	for i, c := range candidates {
+		if c.Guard == "" {
+			candidates = candidates[:i+1]
+			break
+		}
+	}
+
+	// An error that leaves the state unchanged is a rejection or a failed action;
+	// it is a regression only if no candidate could have failed
+	if e.From == e.To && e.Error != "" {
+		exitAction := m.GetState(e.From).ExitAction != ""
+		for _, c := range candidates {
+			if exitAction || c.Action != "" || (c.To == e.From && m.GetState(c.To).EntryAction != "") {
+				return ""
+			}
+			if c.Guard == "" {
+				return fmt.Sprintf("was rejected in %s (%s), but the event now always moves to %s", e.From, e.Error, c.To)
+			}
+		}
+		return ""
+	}
+
+	for _, c := range candidates {
+		if c.To == e.To {
+			return ""
+		}
+	}
+	if len(candidates) == 0 {
+		// Ignored events, and unhandled ones of states whose policy drops them, are
+		// accepted without moving
+		if e.From == e.To && (m.GetState(e.From).Ignores(e.Event) || m.UnhandledEventPolicy(e.From) != model.UnhandledEventError) {
+			return ""
+		}
+		return fmt.Sprintf("moved %s -> %s, but %s no longer accepts the event", e.From, e.To, e.From)
+	}
+	var targets []string
+	for _, c := range candidates {
+		targets = append(targets, c.To)
+	}
+	return fmt.Sprintf("moved %s -> %s, but the event now leads to %s", e.From, e.To, strings.Join(targets, " or "))
+}
@@ -0,0 +1,170 @@
+package simulator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+const checkoutSpec = `
+machine:
+  name: Checkout
+  initial: pending
+
+states:
+  - name: pending
+  - name: approved
+  - name: shipped
+    final: true
+  - name: cancelled
+    final: true
+
+events:
+  - approve
+  - ship
+  - cancel
+
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+    guard: hasPayment
+  - from: pending
+    to: cancelled
+    on: cancel
+  - from: approved
+    to: shipped
+    on: ship
+  - from: approved
+    to: cancelled
+    on: cancel
+    action: refund
+`
+
+func TestReadTrace(t *testing.T) {
+	trace, err := ReadTrace(strings.NewReader(`{
+  "machine": "Checkout",
+  "entries": [
+    {"time": "2026-03-01T10:00:00Z", "event": "approve", "payload_hash": "sha256:ab", "from": "pending", "to": "approved"}
+  ]
+}`))
+	require.NoError(t, err)
+	assert.Equal(t, "Checkout", trace.Machine)
+	assert.Equal(t, []TraceEntry{{
+		Time:        time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		Event:       "approve",
+		PayloadHash: "sha256:ab",
+		From:        "pending",
+		To:          "approved",
+	}}, trace.Entries)
+
+	tests := []struct {
+		name    string
+		trace   string
+		wantErr string
+	}{
+		{name: "invalid JSON", trace: `{"machine":`, wantErr: "failed to decode trace"},
+		{name: "unknown field", trace: `{"machine": "Checkout", "events": []}`, wantErr: "unknown field"},
+		{name: "no machine", trace: `{"entries": []}`, wantErr: "trace names no machine"},
+		{name: "incomplete entry", trace: `{"machine": "Checkout", "entries": [{"event": "approve", "from": "pending"}]}`,
+			wantErr: "entry 1: event, from, and to are required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadTrace(strings.NewReader(tt.trace))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestTrace_Replay(t *testing.T) {
+	fsm, err := parser.NewYAMLParser().Parse(strings.NewReader(checkoutSpec))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		entry TraceEntry
+		want  string
+	}{
+		{name: "recorded transition", entry: TraceEntry{Event: "approve", From: "pending", To: "approved"}},
+		{name: "rejected without transition", entry: TraceEntry{Event: "ship", From: "pending", To: "pending", Error: "invalid event ship for state pending"}},
+		{name: "rejected by guard", entry: TraceEntry{Event: "approve", From: "pending", To: "pending", Error: "guard condition failed"}},
+		{name: "failed action", entry: TraceEntry{Event: "cancel", From: "approved", To: "approved", Error: "transition action failed: declined"}},
+		{
+			name:  "rejection now accepted",
+			entry: TraceEntry{Event: "cancel", From: "pending", To: "pending", Error: "invalid event cancel for state pending"},
+			want:  "was rejected in pending (invalid event cancel for state pending), but the event now always moves to cancelled",
+		},
+		{
+			name:  "different target",
+			entry: TraceEntry{Event: "ship", From: "approved", To: "cancelled"},
+			want:  "moved approved -> cancelled, but the event now leads to shipped",
+		},
+		{
+			name:  "no longer accepted",
+			entry: TraceEntry{Event: "ship", From: "shipped", To: "approved"},
+			want:  "moved shipped -> approved, but shipped no longer accepts the event",
+		},
+		{name: "removed event", entry: TraceEntry{Event: "refund", From: "approved", To: "cancelled"}, want: `event "refund" is no longer defined`},
+		{name: "removed state", entry: TraceEntry{Event: "cancel", From: "archived", To: "cancelled"}, want: `state "archived" is no longer defined`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regressions := Trace{Machine: "Checkout", Entries: []TraceEntry{tt.entry}}.Replay(fsm)
+			if tt.want == "" {
+				assert.Empty(t, regressions)
+				return
+			}
+			require.Len(t, regressions, 1)
+			assert.Equal(t, tt.want, regressions[0].Reason)
+		})
+	}
+}
+
+func TestTrace_ReplayDroppedEvents(t *testing.T) {
+	spec := strings.Replace(checkoutSpec, "  - name: shipped\n    final: true\n", "  - name: shipped\n    final: true\n    unhandled_event: ignore\n", 1)
+	fsm, err := parser.NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	trace := Trace{Machine: "Checkout", Entries: []TraceEntry{
+		{Event: "cancel", From: "shipped", To: "shipped"},
+		{Event: "ship", From: "pending", To: "pending"},
+	}}
+	regressions := trace.Replay(fsm)
+	require.Len(t, regressions, 1, "only shipped drops unhandled events")
+	assert.Equal(t, "entry 2 (ship): moved pending -> pending, but pending no longer accepts the event", regressions[0].String())
+}
+
+func TestTrace_ReplayIgnoredEvents(t *testing.T) {
+	spec := strings.Replace(checkoutSpec, "  - name: pending\n", "  - name: pending\n    ignores: [ship]\n", 1)
+	fsm, err := parser.NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	trace := Trace{Machine: "Checkout", Entries: []TraceEntry{
+		{Event: "ship", From: "pending", To: "pending"},
+		{Event: "ship", From: "cancelled", To: "cancelled"},
+	}}
+	regressions := trace.Replay(fsm)
+	require.Len(t, regressions, 1, "only pending ignores ship")
+	assert.Equal(t, 2, regressions[0].Entry)
+}
+
+func TestTrace_ReplayReportsEveryRegression(t *testing.T) {
+	fsm, err := parser.NewYAMLParser().Parse(strings.NewReader(checkoutSpec))
+	require.NoError(t, err)
+
+	trace := Trace{Machine: "Checkout", Entries: []TraceEntry{
+		{Event: "approve", From: "pending", To: "approved"},
+		{Event: "ship", From: "approved", To: "cancelled"},
+		{Event: "cancel", From: "pending", To: "pending", Error: "invalid event"},
+	}}
+
+	regressions := trace.Replay(fsm)
+	require.Len(t, regressions, 2)
+	assert.Equal(t, "entry 2 (ship): moved approved -> cancelled, but the event now leads to shipped", regressions[0].String())
+	assert.Equal(t, 3, regressions[1].Entry)
+}
@@ -0,0 +1,264 @@
+// Package vet finds misuse of gofsm-gen generated machines in the code using
+// them: writes to the state of a machine that bypass its transitions,
+// comparisons of generated enums with integer literals, and switches over
+// generated enums missing cases. cmd/gofsm-vet runs it as a go vet tool.
+package vet
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+)
+
+// ignoreDirective exempts a switch from the exhaustiveness check, as for the
+// exhaustive linter whose directives the generated code uses
+const ignoreDirective = "//exhaustive:ignore"
+
+// Pass is a type-checked package to analyze
+type Pass struct {
+	Fset      *token.FileSet
+	Files     []*ast.File
+	Pkg       *types.Package
+	TypesInfo *types.Info
+
+	// Facts returns the facts of an imported package by its path, or nil if it
+	// has none; nil means no imported package has facts
+	Facts func(path string) *Facts
+}
+
+// Diagnostic is a misuse of generated code
+type Diagnostic struct {
+	Pos     token.Pos
+	Message string
+}
+
+// analysis is the state of a run of the analyzer over a pass
+type analysis struct {
+	pass  *Pass
+	own   *Facts
+	diags []Diagnostic
+}
+
+// Run analyzes the files of pass that are not generated and returns the
+// diagnostics in source order. Generated code is trusted to uphold its own
+// invariants.
+func Run(pass *Pass) []Diagnostic {
+	a := &analysis{pass: pass, own: CollectFacts(pass.Files)}
+	for _, f := range pass.Files {
+		if ast.IsGenerated(f) {
+			continue
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range n.Lhs {
+					a.checkWrite(lhs)
+				}
+			case *ast.IncDecStmt:
+				a.checkWrite(n.X)
+			case *ast.CompositeLit:
+				a.checkLiteral(n)
+			case *ast.BinaryExpr:
+				a.checkComparison(n)
+			case *ast.SwitchStmt:
+				a.checkSwitch(f, n)
+			}
+			return true
+		})
+	}
+	sort.SliceStable(a.diags, func(i, j int) bool { return a.diags[i].Pos < a.diags[j].Pos })
+	return a.diags
+}
+
+// report records a diagnostic
+func (a *analysis) report(pos token.Pos, format string, args ...any) {
+	a.diags = append(a.diags, Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// facts returns the facts of the package with the given path
+func (a *analysis) facts(path string) *Facts {
+	if path == a.pass.Pkg.Path() {
+		return a.own
+	}
+	if a.pass.Facts == nil {
+		return nil
+	}
+	return a.pass.Facts(path)
+}
+
+// named returns the named type of t, looking through pointers
+func named(t types.Type) *types.Named {
+	n, _ := types.Unalias(deref(t)).(*types.Named)
+	if n == nil || n.Obj().Pkg() == nil {
+		return nil
+	}
+	return n
+}
+
+// machine returns the generated machine type t is, or points to, or nil
+func (a *analysis) machine(t types.Type) *types.Named {
+	n := named(t)
+	if n == nil {
+		return nil
+	}
+	if facts := a.facts(n.Obj().Pkg().Path()); facts != nil && facts.IsMachine(n.Obj().Name()) {
+		return n
+	}
+	return nil
+}
+
+// enum returns the generated enum type t is and the constants a switch over it
+// must handle, or nil
+func (a *analysis) enum(t types.Type) (*types.Named, []string) {
+	n, _ := types.Unalias(t).(*types.Named)
+	if n == nil || n.Obj().Pkg() == nil {
+		return nil, nil
+	}
+	facts := a.facts(n.Obj().Pkg().Path())
+	if facts == nil {
+		return nil, nil
+	}
+	members, ok := facts.Enums[n.Obj().Name()]
+	if !ok {
+		return nil, nil
+	}
+	return n, members
+}
+
+// checkWrite reports assignments to the state field of a machine
+func (a *analysis) checkWrite(expr ast.Expr) {
+	sel, ok := ast.Unparen(expr).(*ast.SelectorExpr)
+	if !ok {
+		return
+	}
+	selection := a.pass.TypesInfo.Selections[sel]
+	if selection == nil || selection.Kind() != types.FieldVal || selection.Obj().Name() != stateField {
+		return
+	}
+
+	// The field belongs to the struct reached through any embedded fields
+	owner := selection.Recv()
+	index := selection.Index()
+	for _, i := range index[:len(index)-1] {
+		st, ok := deref(owner).Underlying().(*types.Struct)
+		if !ok {
+			return
+		}
+		owner = st.Field(i).Type()
+	}
+	if m := a.machine(owner); m != nil {
+		a.report(sel.Sel.Pos(), "direct write to the state of %s bypasses its transitions; fire events with Transition or load states with RestoreState", m.Obj().Name())
+	}
+}
+
+// checkLiteral reports composite literals of machines setting the state field
+func (a *analysis) checkLiteral(lit *ast.CompositeLit) {
+	m := a.machine(a.pass.TypesInfo.TypeOf(lit))
+	if m == nil {
+		return
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key, ok := kv.Key.(*ast.Ident); ok && key.Name == stateField {
+				a.report(kv.Pos(), "direct write to the state of %s bypasses its transitions; create machines with New%s", m.Obj().Name(), m.Obj().Name())
+			}
+		}
+	}
+}
+
+// checkComparison reports comparisons of generated enums with integer literals
+func (a *analysis) checkComparison(expr *ast.BinaryExpr) {
+	switch expr.Op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+	default:
+		return
+	}
+	for _, pair := range [][2]ast.Expr{{expr.X, expr.Y}, {expr.Y, expr.X}} {
+		if isIntLiteral(pair[0]) || !isIntLiteral(pair[1]) {
+			continue
+		}
+		if n, _ := a.enum(a.pass.TypesInfo.TypeOf(pair[0])); n != nil {
+			a.report(pair[1].Pos(), "comparison of %s with the integer literal %s; use its generated constants", n.Obj().Name(), literalText(pair[1]))
+		}
+	}
+}
+
+// checkSwitch reports switches over generated enums that miss constants or
+// compare with integer literals
+func (a *analysis) checkSwitch(f *ast.File, sw *ast.SwitchStmt) {
+	if sw.Tag == nil {
+		return
+	}
+	n, members := a.enum(a.pass.TypesInfo.TypeOf(sw.Tag))
+	if n == nil {
+		return
+	}
+
+	covered := make(map[string]bool)
+	for _, stmt := range sw.Body.List {
+		for _, expr := range stmt.(*ast.CaseClause).List {
+			if isIntLiteral(expr) {
+				a.report(expr.Pos(), "case of %s with the integer literal %s; use its generated constants", n.Obj().Name(), literalText(expr))
+			}
+			if tv, ok := a.pass.TypesInfo.Types[expr]; ok && tv.Value != nil {
+				covered[tv.Value.ExactString()] = true
+			}
+		}
+	}
+	if a.ignored(f, sw) {
+		return
+	}
+
+	var missing []string
+	scope := n.Obj().Pkg().Scope()
+	for _, name := range members {
+		c, ok := scope.Lookup(name).(*types.Const)
+		if ok && !covered[c.Val().ExactString()] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		a.report(sw.Pos(), "switch over %s is missing cases for %s", n.Obj().Name(), strings.Join(missing, ", "))
+	}
+}
+
+// ignored reports whether sw is preceded by the ignore directive
+func (a *analysis) ignored(f *ast.File, sw *ast.SwitchStmt) bool {
+	line := a.pass.Fset.Position(sw.Pos()).Line
+	for _, group := range f.Comments {
+		if a.pass.Fset.Position(group.End()).Line == line-1 && hasDirective(group, ignoreDirective) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIntLiteral reports whether expr is an integer literal, possibly signed
+func isIntLiteral(expr ast.Expr) bool {
+	expr = ast.Unparen(expr)
+	if u, ok := expr.(*ast.UnaryExpr); ok && (u.Op == token.SUB || u.Op == token.ADD) {
+		expr = ast.Unparen(u.X)
+	}
+	lit, ok := expr.(*ast.BasicLit)
+	return ok && lit.Kind == token.INT
+}
+
+// literalText returns the source text of a possibly signed integer literal
+func literalText(expr ast.Expr) string {
+	expr = ast.Unparen(expr)
+	if u, ok := expr.(*ast.UnaryExpr); ok {
+		return u.Op.String() + literalText(u.X)
+	}
+	return expr.(*ast.BasicLit).Value
+}
+
+// deref returns the element type of pointers and t otherwise
+func deref(t types.Type) types.Type {
+	if ptr, ok := types.Unalias(t).(*types.Pointer); ok {
+		return ptr.Elem()
+	}
+	return t
+}
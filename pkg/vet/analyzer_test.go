@@ -0,0 +1,162 @@
+package vet
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ordersGenerated is a trimmed-down machine as gofsm-gen generates it, with the
+// zero value reserved for the unspecified sentinel
+const ordersGenerated = `// Code generated by gofsm-gen. DO NOT EDIT.
+package orders
+
+type OrderState int
+
+//exhaustive:enforce
+const (
+	// OrderStateUnspecified is the zero value and marks a state that was never set
+	OrderStateUnspecified OrderState = 0
+	OrderStatePending OrderState = 1
+	OrderStateApproved OrderState = 2
+	OrderStateShipped OrderState = 3
+)
+
+type Order struct {
+	currentState OrderState
+}
+
+func NewOrder() *Order { return &Order{currentState: OrderStatePending} }
+
+func (sm *Order) State() OrderState { return sm.currentState }
+`
+
+// checkPackage type-checks the files of a package, importing the packages
+// checked before it, and runs the analyzer over it
+func checkPackage(t *testing.T, fset *token.FileSet, path string, sources map[string]string, imports map[string]*types.Package, facts map[string]*Facts) ([]string, *types.Package) {
+	t.Helper()
+	var files []*ast.File
+	for name, src := range sources {
+		f, err := parser.ParseFile(fset, name, src, parser.ParseComments)
+		require.NoError(t, err)
+		files = append(files, f)
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	tc := &types.Config{Importer: importerFunc(func(path string) (*types.Package, error) { return imports[path], nil })}
+	pkg, err := tc.Check(path, fset, files, info)
+	require.NoError(t, err)
+
+	pass := &Pass{Fset: fset, Files: files, Pkg: pkg, TypesInfo: info, Facts: func(path string) *Facts { return facts[path] }}
+	facts[path] = CollectFacts(files)
+	var diags []string
+	for _, d := range Run(pass) {
+		diags = append(diags, fset.Position(d.Pos).String()+": "+d.Message)
+	}
+	return diags, pkg
+}
+
+func TestRun_SamePackage(t *testing.T) {
+	fset := token.NewFileSet()
+	diags, _ := checkPackage(t, fset, "example.com/orders", map[string]string{
+		"orders_fsm.gen.go": ordersGenerated,
+		"orders.go": `package orders
+
+type audited struct {
+	*Order
+}
+
+func reset(sm *Order, a audited) {
+	sm.currentState = OrderStatePending
+	(sm.currentState)++
+	a.currentState = OrderStateApproved
+	_ = &Order{currentState: OrderStateShipped}
+	_ = sm.currentState == OrderStatePending
+}
+`,
+	}, nil, map[string]*Facts{})
+
+	assert.Equal(t, []string{
+		"orders.go:8:5: direct write to the state of Order bypasses its transitions; fire events with Transition or load states with RestoreState",
+		"orders.go:9:6: direct write to the state of Order bypasses its transitions; fire events with Transition or load states with RestoreState",
+		"orders.go:10:4: direct write to the state of Order bypasses its transitions; fire events with Transition or load states with RestoreState",
+		"orders.go:11:13: direct write to the state of Order bypasses its transitions; create machines with NewOrder",
+	}, diags)
+}
+
+func TestRun_ImportingPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	facts := map[string]*Facts{}
+	_, orders := checkPackage(t, fset, "example.com/orders", map[string]string{"orders_fsm.gen.go": ordersGenerated}, nil, facts)
+
+	diags, _ := checkPackage(t, fset, "example.com/app", map[string]string{
+		"app.go": `package app
+
+import "example.com/orders"
+
+type Level int
+
+const High Level = 1
+
+func describe(sm *orders.Order, level Level) string {
+	if sm.State() == 2 || -1 != sm.State() || sm.State() > (3) {
+		return "approved"
+	}
+	if level == 1 || sm.State() == orders.OrderStatePending {
+		return "high"
+	}
+
+	switch sm.State() {
+	case orders.OrderStatePending, 2:
+		return "open"
+	default:
+		return ""
+	}
+}
+
+func exhaustive(s orders.OrderState) bool {
+	switch s {
+	case orders.OrderStatePending, orders.OrderStateApproved:
+		return true
+	case orders.OrderStateShipped:
+	}
+
+	//exhaustive:ignore
+	switch s {
+	case orders.OrderStateShipped:
+		return true
+	}
+
+	switch level := High; level {
+	case High:
+	}
+	return false
+}
+`,
+	}, map[string]*types.Package{"example.com/orders": orders}, facts)
+
+	assert.Equal(t, []string{
+		"app.go:10:19: comparison of OrderState with the integer literal 2; use its generated constants",
+		"app.go:10:24: comparison of OrderState with the integer literal -1; use its generated constants",
+		"app.go:10:57: comparison of OrderState with the integer literal 3; use its generated constants",
+		"app.go:17:2: switch over OrderState is missing cases for OrderStateShipped",
+		"app.go:18:33: case of OrderState with the integer literal 2; use its generated constants",
+	}, diags)
+}
+
+func TestRun_SkipsGeneratedFiles(t *testing.T) {
+	fset := token.NewFileSet()
+	diags, _ := checkPackage(t, fset, "example.com/orders", map[string]string{
+		"orders_fsm.gen.go": ordersGenerated + `
+func restore(sm *Order) { sm.currentState = 1 }
+`,
+	}, nil, map[string]*Facts{})
+	assert.Empty(t, diags)
+}
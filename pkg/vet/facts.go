@@ -0,0 +1,128 @@
+package vet
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+)
+
+// stateField is the field of generated machines holding the current state
+const stateField = "currentState"
+
+// exhaustiveDirective marks the constant blocks of generated enums
+const exhaustiveDirective = "//exhaustive:enforce"
+
+// Facts are what the analyzer learns from the gofsm-gen generated files of a
+// package, so that packages importing it can be checked against them
+type Facts struct {
+	// Enums maps each generated enum type to the constants a switch over it must
+	// handle, which leave out the zero value sentinel of the unspecified policy
+	Enums map[string][]string `json:"enums,omitempty"`
+
+	// Machines are the generated machine types
+	Machines []string `json:"machines,omitempty"`
+}
+
+// IsMachine reports whether name is a generated machine type
+func (f *Facts) IsMachine(name string) bool {
+	for _, machine := range f.Machines {
+		if machine == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CollectFacts collects the facts of the gofsm-gen generated files among files
+func CollectFacts(files []*ast.File) *Facts {
+	facts := &Facts{Enums: make(map[string][]string)}
+	for _, f := range files {
+		if !isGenerated(f) {
+			continue
+		}
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			switch gen.Tok {
+			case token.CONST:
+				if hasDirective(gen.Doc, exhaustiveDirective) {
+					collectEnum(facts, gen)
+				}
+			case token.TYPE:
+				for _, spec := range gen.Specs {
+					if ts := spec.(*ast.TypeSpec); hasStateField(ts) {
+						facts.Machines = append(facts.Machines, ts.Name.Name)
+					}
+				}
+			}
+		}
+	}
+	return facts
+}
+
+// collectEnum records the constants of the constant block of a generated enum
+func collectEnum(facts *Facts, block *ast.GenDecl) {
+	for _, spec := range block.Specs {
+		vs := spec.(*ast.ValueSpec)
+		typ, ok := vs.Type.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		for _, name := range vs.Names {
+			// The sentinel is the only documented constant of a block
+			if vs.Doc != nil && name.Name == typ.Name+"Unspecified" {
+				continue
+			}
+			facts.Enums[typ.Name] = append(facts.Enums[typ.Name], name.Name)
+		}
+	}
+}
+
+// hasStateField reports whether a type declaration is a struct with the state
+// field of generated machines
+func hasStateField(ts *ast.TypeSpec) bool {
+	st, ok := ts.Type.(*ast.StructType)
+	if !ok {
+		return false
+	}
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if name.Name == stateField {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isGenerated reports whether f carries the gofsm-gen marker above its package clause
+func isGenerated(f *ast.File) bool {
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, c := range group.List {
+			if strings.TrimSpace(c.Text) == generator.GeneratedMarker {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasDirective reports whether a comment group contains the given directive
+func hasDirective(group *ast.CommentGroup, directive string) bool {
+	if group == nil {
+		return false
+	}
+	for _, c := range group.List {
+		if strings.TrimSpace(c.Text) == directive {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package vet
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yourusername/gofsm-gen/pkg/generator"
+	fsmparser "github.com/yourusername/gofsm-gen/pkg/parser"
+)
+
+func TestCollectFacts(t *testing.T) {
+	spec := `
+machine:
+  name: OrderStateMachine
+  initial: pending
+  package: orders
+options:
+  zero_state: unspecified
+states:
+  - name: pending
+  - name: approved
+events:
+  - approve
+  - cancel
+transitions:
+  - from: pending
+    to: approved
+    on: approve
+`
+	m, err := fsmparser.NewYAMLParser().Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+	gen, err := generator.NewCodeGenerator()
+	require.NoError(t, err)
+	code, err := gen.Generate(m)
+	require.NoError(t, err)
+
+	fset := token.NewFileSet()
+	generated, err := parser.ParseFile(fset, "orders_fsm.gen.go", code, parser.ParseComments)
+	require.NoError(t, err)
+	// Hand-written files declaring the same shapes are not generated machines
+	handwritten, err := parser.ParseFile(fset, "orders.go", `package orders
+
+type Shadow struct{ currentState int }
+
+//exhaustive:enforce
+const (
+	ShadowA Shadow2 = 1
+)
+
+type Shadow2 int
+`, parser.ParseComments)
+	require.NoError(t, err)
+
+	facts := CollectFacts([]*ast.File{generated, handwritten})
+	assert.Equal(t, []string{"OrderStateMachine"}, facts.Machines)
+	assert.Equal(t, map[string][]string{
+		"OrderStateMachineState": {"OrderStateMachineStateApproved", "OrderStateMachineStatePending"},
+		"OrderStateMachineEvent": {"OrderStateMachineEventApprove", "OrderStateMachineEventCancel"},
+	}, facts.Enums)
+	assert.True(t, facts.IsMachine("OrderStateMachine"))
+	assert.False(t, facts.IsMachine("Shadow"))
+}
@@ -0,0 +1,250 @@
+package vet
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+)
+
+// config is the package description go vet passes to vet tools in a JSON file.
+// Dependencies are described before the packages importing them, with
+// VetxOnly set, so that their facts are in place when the importers are checked.
+type config struct {
+	ID                        string
+	Compiler                  string
+	Dir                       string
+	ImportPath                string
+	GoVersion                 string
+	GoFiles                   []string
+	ImportMap                 map[string]string
+	PackageFile               map[string]string
+	PackageVetx               map[string]string
+	VetxOnly                  bool
+	VetxOutput                string
+	SucceedOnTypecheckFailure bool
+
+	// Stdout is the file newer go commands read the JSON output from; empty
+	// means standard output
+	Stdout string
+}
+
+// analyzerName names the analyzer in the JSON output read by go vet
+const analyzerName = "gofsmvet"
+
+// Main runs the analyzer as a go vet tool, answering the version and flag
+// queries of go vet and checking the package described by a config file. It
+// returns the process exit code.
+func Main(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gofsm-vet", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	version := fs.String("V", "", "print the version and exit")
+	printFlags := fs.Bool("flags", false, "print the analyzer flags in JSON and exit")
+	jsonOutput := fs.Bool("json", false, "write diagnostics to stdout in JSON")
+	// Flags go vet may pass to every tool; the analyzer suggests no fixes
+	fs.Bool("fix", false, "apply suggested fixes")
+	fs.Bool("diff", false, "print suggested fixes as diffs")
+	fs.Int("c", -1, "lines of context to print with diagnostics")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	switch {
+	case *version != "":
+		id, err := buildID()
+		if err != nil {
+			fmt.Fprintf(stderr, "gofsm-vet: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "gofsm-vet version devel buildID=%s\n", id)
+		return 0
+	case *printFlags:
+		fmt.Fprintln(stdout, "[]")
+		return 0
+	case fs.NArg() != 1:
+		fmt.Fprintln(stderr, "usage: go vet -vettool=$(which gofsm-vet) [packages]")
+		return 2
+	}
+
+	cfg, fset, diags, err := checkConfig(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "gofsm-vet: %v\n", err)
+		return 1
+	}
+	if len(diags) == 0 {
+		return 0
+	}
+	if *jsonOutput {
+		if err := writeJSON(stdout, cfg, fset, diags); err != nil {
+			fmt.Fprintf(stderr, "gofsm-vet: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	for _, d := range diags {
+		fmt.Fprintf(stderr, "%s: %s\n", fset.Position(d.Pos), d.Message)
+	}
+	return 1
+}
+
+// jsonDiagnostic is a diagnostic in the JSON output read by go vet
+type jsonDiagnostic struct {
+	Posn    string `json:"posn"`
+	End     string `json:"end"`
+	Message string `json:"message"`
+}
+
+// writeJSON writes diagnostics in the JSON output of go vet tools, which maps
+// package IDs to analyzer names to diagnostics, to stdout or the file of cfg
+func writeJSON(stdout io.Writer, cfg *config, fset *token.FileSet, diags []Diagnostic) error {
+	out := make([]jsonDiagnostic, len(diags))
+	for i, d := range diags {
+		posn := fset.Position(d.Pos).String()
+		out[i] = jsonDiagnostic{Posn: posn, End: posn, Message: d.Message}
+	}
+	data, err := json.Marshal(map[string]map[string][]jsonDiagnostic{cfg.ID: {analyzerName: out}})
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if cfg.Stdout != "" {
+		return os.WriteFile(cfg.Stdout, data, 0o644)
+	}
+	_, err = stdout.Write(data)
+	return err
+}
+
+// buildID identifies the executable, so that go vet caches results per version
+func buildID() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// checkConfig checks the package described by the config file at path and
+// returns the config and the diagnostics
+func checkConfig(path string) (*config, *token.FileSet, []Diagnostic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cfg := &config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	fset := token.NewFileSet()
+	files := make([]*ast.File, 0, len(cfg.GoFiles))
+	for _, name := range cfg.GoFiles {
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			if cfg.SucceedOnTypecheckFailure {
+				return cfg, fset, nil, nil
+			}
+			return nil, nil, nil, err
+		}
+		files = append(files, f)
+	}
+
+	if cfg.VetxOutput != "" {
+		facts, err := json.Marshal(CollectFacts(files))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := os.WriteFile(cfg.VetxOutput, facts, 0o644); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if cfg.VetxOnly {
+		return cfg, fset, nil, nil
+	}
+
+	pass, err := typeCheck(cfg, fset, files)
+	if err != nil {
+		if cfg.SucceedOnTypecheckFailure {
+			return cfg, fset, nil, nil
+		}
+		return nil, nil, nil, err
+	}
+	return cfg, fset, Run(pass), nil
+}
+
+// typeCheck type-checks the package of cfg against the export data of its
+// dependencies and returns the pass over it
+func typeCheck(cfg *config, fset *token.FileSet, files []*ast.File) (*Pass, error) {
+	exports := importer.ForCompiler(fset, cfg.Compiler, func(path string) (io.ReadCloser, error) {
+		file, ok := cfg.PackageFile[path]
+		if !ok {
+			return nil, fmt.Errorf("no export data for %q", path)
+		}
+		return os.Open(file)
+	})
+	tc := &types.Config{
+		Importer: importerFunc(func(importPath string) (*types.Package, error) {
+			if importPath == "unsafe" {
+				return types.Unsafe, nil
+			}
+			path, ok := cfg.ImportMap[importPath]
+			if !ok {
+				return nil, fmt.Errorf("cannot resolve import %q", importPath)
+			}
+			return exports.Import(path)
+		}),
+		Sizes:     types.SizesFor(cfg.Compiler, build.Default.GOARCH),
+		GoVersion: cfg.GoVersion,
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	pkg, err := tc.Check(cfg.ImportPath, fset, files, info)
+	if err != nil {
+		return nil, err
+	}
+
+	facts := make(map[string]*Facts)
+	return &Pass{
+		Fset:      fset,
+		Files:     files,
+		Pkg:       pkg,
+		TypesInfo: info,
+		Facts: func(path string) *Facts {
+			if f, ok := facts[path]; ok {
+				return f
+			}
+			var f *Facts
+			if data, err := os.ReadFile(cfg.PackageVetx[path]); err == nil {
+				f = &Facts{}
+				if json.Unmarshal(data, f) != nil {
+					f = nil
+				}
+			}
+			facts[path] = f
+			return f
+		},
+	}, nil
+}
+
+// importerFunc adapts a function to types.Importer
+type importerFunc func(path string) (*types.Package, error)
+
+// Import implements types.Importer
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
@@ -0,0 +1,129 @@
+package vet
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes the orders package with a misuse and a vet config
+// describing it, and returns the path of the config
+func writeConfig(t *testing.T, cfg config) string {
+	t.Helper()
+	dir := t.TempDir()
+	gen := filepath.Join(dir, "orders_fsm.gen.go")
+	src := filepath.Join(dir, "orders.go")
+	require.NoError(t, os.WriteFile(gen, []byte(ordersGenerated), 0o600))
+	require.NoError(t, os.WriteFile(src, []byte("package orders\n\nfunc reset(sm *Order) { sm.currentState = 1 }\n"), 0o600))
+
+	cfg.ID = "example.com/orders"
+	cfg.Compiler = "gc"
+	cfg.ImportPath = "example.com/orders"
+	cfg.GoFiles = []string{gen, src}
+	cfg.VetxOutput = filepath.Join(dir, "vet.out")
+	data, err := json.Marshal(cfg)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "vet.cfg")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestMain_Handshake(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, Main([]string{"-V=full"}, &stdout, &stderr))
+	assert.Regexp(t, `^gofsm-vet version devel buildID=[0-9a-f]{64}\n$`, stdout.String())
+
+	stdout.Reset()
+	require.Equal(t, 0, Main([]string{"-flags"}, &stdout, &stderr))
+	assert.Equal(t, "[]\n", stdout.String())
+
+	assert.Equal(t, 2, Main(nil, &stdout, &stderr))
+	assert.Contains(t, stderr.String(), "usage: go vet -vettool=$(which gofsm-vet) [packages]")
+}
+
+func TestMain_Config(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	path := writeConfig(t, config{})
+	assert.Equal(t, 1, Main([]string{path}, &stdout, &stderr))
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), "orders.go:3:28: direct write to the state of Order bypasses its transitions")
+
+	var facts Facts
+	data, err := os.ReadFile(filepath.Join(filepath.Dir(path), "vet.out"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &facts))
+	assert.Equal(t, []string{"Order"}, facts.Machines)
+
+	// JSON output goes to the file named by the config, and is not a failure
+	stderr.Reset()
+	out := filepath.Join(t.TempDir(), "vet.stdout")
+	path = writeConfig(t, config{Stdout: out})
+	assert.Equal(t, 0, Main([]string{"-json", path}, &stdout, &stderr))
+	assert.Empty(t, stderr.String())
+	data, err = os.ReadFile(out)
+	require.NoError(t, err)
+	var tree map[string]map[string][]jsonDiagnostic
+	require.NoError(t, json.Unmarshal(data, &tree))
+	diags := tree["example.com/orders"][analyzerName]
+	require.Len(t, diags, 1)
+	assert.Contains(t, diags[0].Message, "direct write to the state of Order")
+
+	// Dependencies only record their facts
+	path = writeConfig(t, config{VetxOnly: true})
+	assert.Equal(t, 0, Main([]string{path}, &stdout, &stderr))
+	assert.FileExists(t, filepath.Join(filepath.Dir(path), "vet.out"))
+}
+
+func TestGoVet(t *testing.T) {
+	if testing.Short() {
+		t.Skip("running go vet is skipped in short mode")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	tool := filepath.Join(t.TempDir(), "gofsm-vet")
+	build := exec.Command(goBin, "build", "-o", tool, "github.com/yourusername/gofsm-gen/cmd/gofsm-vet")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":                   "module shop\n\ngo 1.21\n",
+		"orders/orders_fsm.gen.go": ordersGenerated,
+		"app/app.go": `package app
+
+import "shop/orders"
+
+func Open(sm *orders.Order) bool {
+	switch sm.State() {
+	case orders.OrderStatePending:
+		return true
+	}
+	return sm.State() == 2
+}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	vet := exec.Command(goBin, "vet", "-vettool="+tool, "./...")
+	vet.Dir = dir
+	vet.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOWORK=off")
+	out, err = vet.CombinedOutput()
+	require.Error(t, err, "go vet must fail on misuse")
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	assert.Contains(t, lines, "app/app.go:6:2: switch over OrderState is missing cases for OrderStateApproved, OrderStateShipped")
+	assert.Contains(t, lines, "app/app.go:10:23: comparison of OrderState with the integer literal 2; use its generated constants")
+}